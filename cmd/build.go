@@ -1,14 +1,18 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gookit/color"
@@ -16,19 +20,63 @@ import (
 	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
 	"github.com/khulnasoft/blazedock/pkg/blazedock/cache/local"
 	"github.com/khulnasoft/blazedock/pkg/blazedock/cache/remote"
+	"github.com/khulnasoft/blazedock/pkg/prettyprint"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
 )
 
 // buildCmd represents the build command
 var buildCmd = &cobra.Command{
 	Use:   "build [targetPackage]",
 	Short: "Builds a package",
-	Args:  cobra.MaximumNArgs(1),
+	Long: `Builds a package and all of its dependencies.
+
+The target can also be a pattern which selects several packages, e.g. components/api/... builds all packages of the
+components in components/api and below, some-component:* all packages of some-component. Patterns are globs on the
+component and package name, where ** matches any number of component path segments.
+
+Using --changed-since <ref>, blazedock builds the packages affected by the changes since the merge base of ref and HEAD,
+including uncommitted changes: packages whose sources or BUILD.yaml changed and all packages which transitively depend
+on them. If a target is given as well, only the affected packages it selects are built.
+
+Using --test, blazedock builds the packages whose tests are affected by a change, i.e. the changed packages and all
+packages which transitively depend on them, as far as they have tests. The changed packages are the target packages,
+or those affected by the changes since --changed-since. The selected tests are listed before the build starts.
+Tests which passed for the same package version before are not run again, instead their output is replayed.
+Use --force-test to run them anyway.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completePackageNames,
 	Run: func(cmd *cobra.Command, args []string) {
-		_, pkg, _, _ := getTarget(args, false)
-		if pkg == nil {
-			log.Fatal("build needs a package")
+		var (
+			pkgs        []*blazedock.Package
+			ref, _      = cmd.Flags().GetString("changed-since")
+			affected, _ = cmd.Flags().GetBool("test")
+		)
+		switch {
+		case affected:
+			pkgs = getAffectedTests(cmd, args, ref)
+			if len(pkgs) == 0 {
+				fmt.Println("no tests are affected")
+				return
+			}
+		case ref != "":
+			pkgs = getChangedPackages(cmd, args, ref)
+			if len(pkgs) == 0 {
+				fmt.Printf("no package changed since %s\n", ref)
+				return
+			}
+		default:
+			pkgs = getTargetPackages(args)
+			if len(pkgs) == 0 {
+				log.Fatal("build needs a package")
+			}
+		}
+		if printKey, _ := cmd.Flags().GetBool("print-key"); printKey {
+			for _, pkg := range pkgs {
+				printCacheKey(pkg)
+			}
+			return
 		}
 		opts, localCache := getBuildOpts(cmd)
 
@@ -37,12 +85,28 @@ var buildCmd = &cobra.Command{
 			save, _  = cmd.Flags().GetString("save")
 			serve, _ = cmd.Flags().GetString("serve")
 		)
+		if len(pkgs) > 1 {
+			if watch || save != "" || serve != "" {
+				log.Fatalf("--watch, --save and --serve need a single package, but %d packages were selected", len(pkgs))
+			}
+			// all packages are built as one build graph, hence the dependencies they share are built only once
+			err := blazedock.BuildAll(pkgs, opts...)
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		pkg := pkgs[0]
 		if watch {
+			// stop watching cleanly on SIGINT
+			watchCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
 			err := blazedock.Build(pkg, opts...)
 			if err != nil {
 				log.Fatal(err)
 			}
-			ctx, cancel := context.WithCancel(context.Background())
+			ctx, cancel := context.WithCancel(watchCtx)
 			if save != "" {
 				saveBuildResult(ctx, save, localCache, pkg)
 			}
@@ -50,15 +114,18 @@ var buildCmd = &cobra.Command{
 				go serveBuildResult(ctx, serve, localCache, pkg)
 			}
 
-			evt, errs := blazedock.WatchSources(context.Background(), append(pkg.GetTransitiveDependencies(), pkg), 2*time.Second)
+			evt, errs := blazedock.WatchSources(watchCtx, append(pkg.GetTransitiveDependencies(), pkg), 2*time.Second)
 			for {
 				select {
 				case <-evt:
+					fmt.Printf("\n%s\n\n", color.Gray.Sprintf("──── sources changed, rebuilding %s (%s) ────", pkg.FullName(), time.Now().Format(time.TimeOnly)))
+
+					// The local cache stays in place between builds, hence only packages whose sources changed are rebuilt.
 					_, pkg, _, _ := getTarget(args, false)
 					err := blazedock.Build(pkg, opts...)
 					if err == nil {
 						cancel()
-						ctx, cancel = context.WithCancel(context.Background())
+						ctx, cancel = context.WithCancel(watchCtx)
 						if save != "" {
 							saveBuildResult(ctx, save, localCache, pkg)
 						}
@@ -69,7 +136,12 @@ var buildCmd = &cobra.Command{
 						log.Error(err)
 					}
 				case err = <-errs:
+					cancel()
 					log.Fatal(err)
+				case <-watchCtx.Done():
+					cancel()
+					log.Info("stopped watching")
+					return
 				}
 			}
 		}
@@ -87,6 +159,82 @@ var buildCmd = &cobra.Command{
 	},
 }
 
+// getChangedPackages returns the packages affected by the changes since ref. If args name a target, only the
+// affected packages it selects are returned.
+func getChangedPackages(cmd *cobra.Command, args []string, ref string) []*blazedock.Package {
+	ws, err := getWorkspace()
+	if err != nil {
+		log.Fatal(err)
+	}
+	files, err := ws.Git.ChangedFiles(ref)
+	if err != nil {
+		log.WithError(err).Fatalf("cannot find the files changed since %s", ref)
+	}
+	for i, fn := range files {
+		files[i] = filepath.Join(ws.Git.WorkingCopyLoc, fn)
+	}
+	directOnly, _ := cmd.Flags().GetBool("changed-only-direct")
+	pkgs := ws.AffectedPackages(files, directOnly)
+
+	if len(args) > 0 {
+		selected := make(map[string]struct{})
+		for _, p := range getTargetPackages(args) {
+			selected[p.FullName()] = struct{}{}
+		}
+		n := 0
+		for _, p := range pkgs {
+			if _, ok := selected[p.FullName()]; ok {
+				pkgs[n] = p
+				n++
+			}
+		}
+		pkgs = pkgs[:n]
+	}
+
+	if len(pkgs) > 0 {
+		fmt.Printf("packages affected by the changes since %s:\n", ref)
+		for _, p := range pkgs {
+			fmt.Printf("\t%s\n", p.FullName())
+		}
+	}
+	return pkgs
+}
+
+// getAffectedTests returns the packages whose tests are affected by the changed packages and lists them. The changed
+// packages are those affected by the changes since ref or, without a ref, the target packages.
+func getAffectedTests(cmd *cobra.Command, args []string, ref string) []*blazedock.Package {
+	if dontTest, _ := cmd.Flags().GetBool("dont-test"); dontTest {
+		log.Fatal("--test and --dont-test are mutually exclusive")
+	}
+
+	var pkgs []*blazedock.Package
+	if ref != "" {
+		pkgs = getChangedPackages(cmd, args, ref)
+	} else {
+		pkgs = getTargetPackages(args)
+		if len(pkgs) == 0 {
+			log.Fatal("--test needs a package or --changed-since")
+		}
+		if directOnly, _ := cmd.Flags().GetBool("changed-only-direct"); !directOnly {
+			pkgs = blazedock.WithTransitiveDependants(pkgs)
+		}
+	}
+
+	var res []*blazedock.Package
+	for _, p := range pkgs {
+		if p.HasTests() {
+			res = append(res, p)
+		}
+	}
+	if len(res) > 0 {
+		fmt.Printf("running the tests of:\n")
+		for _, p := range res {
+			fmt.Printf("\t%s\n", p.FullName())
+		}
+	}
+	return res
+}
+
 func serveBuildResult(ctx context.Context, addr string, localCache cache.LocalCache, pkg *blazedock.Package) {
 	br, exists := localCache.Location(pkg)
 	if !exists {
@@ -157,42 +305,223 @@ func init() {
 	buildCmd.Flags().String("serve", "", "After a successful build this starts a webserver on the given address serving the build result (e.g. --serve localhost:8080)")
 	buildCmd.Flags().String("save", "", "After a successful build this saves the build result as tar.gz file in the local filesystem (e.g. --save build-result.tar.gz)")
 	buildCmd.Flags().Bool("watch", false, "Watch source files and re-build on change")
-
+	buildCmd.Flags().String("changed-since", "", "Build only the packages affected by the changes since this Git ref, e.g. origin/main")
+	buildCmd.Flags().Bool("changed-only-direct", false, "With --changed-since or --test, skip the packages which only depend on the changed packages")
+	buildCmd.Flags().Bool("test", false, "Build the packages whose tests are affected by changes to the target packages, or by the changes since --changed-since")
+	buildCmd.Flags().Bool("force-test", false, "With --test, run the tests even if they passed for the same package version before")
+	buildCmd.Flags().Bool("explain", false, "Explain why packages are rebuilt by comparing their inputs against their previous build in the local cache")
+	buildCmd.Flags().Bool("print-key", false, "Print the cache key of the package and the inputs it is computed from instead of building the package")
 }
 
-func addBuildFlags(cmd *cobra.Command) {
-	cacheDefault := os.Getenv("BLAZEDOCK_DEFAULT_CACHE_LEVEL")
-	if cacheDefault == "" {
-		cacheDefault = "remote"
+// printCacheKey prints the cache key (version) of a package together with the inputs it's computed from,
+// in the order they enter the hash. Comparing the output of two machines shows why a package misses the cache.
+func printCacheKey(pkg *blazedock.Package) {
+	key, err := pkg.Version()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var manifest bytes.Buffer
+	err = pkg.WriteVersionManifest(&manifest)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	deps := make(map[string]string)
+	for _, dep := range pkg.GetDependencies() {
+		ver, err := dep.Version()
+		if err != nil {
+			log.Fatal(err)
+		}
+		deps[fmt.Sprintf("%s.%s", dep.FullName(), ver)] = dep.FullName()
+	}
+	srcs, err := pkg.ContentManifest()
+	if err != nil {
+		log.Fatal(err)
+	}
+	isSource := make(map[string]bool, len(srcs))
+	for _, src := range srcs {
+		isSource[src] = true
 	}
 
-	// Never use all CPUs, leave one free for other processes
-	cpus := runtime.NumCPU()
-	if cpus > 2 {
-		cpus--
+	type keyInput struct {
+		Kind  string `json:"kind" yaml:"kind"`
+		Name  string `json:"name,omitempty" yaml:"name,omitempty"`
+		Value string `json:"value" yaml:"value"`
+	}
+	type keyDesc struct {
+		Package string     `json:"package" yaml:"package"`
+		Key     string     `json:"key" yaml:"key"`
+		Inputs  []keyInput `json:"inputs" yaml:"inputs"`
 	}
+	desc := keyDesc{Package: pkg.FullName(), Key: key}
+	for _, line := range strings.Split(pkg.C.W.Redact(manifest.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var in keyInput
+		if name, ok := deps[line]; ok {
+			in = keyInput{Kind: "dependency", Name: name, Value: strings.TrimPrefix(line, name+".")}
+		} else if isSource[line] {
+			idx := strings.LastIndex(line, ":")
+			in = keyInput{Kind: "source", Name: line[:idx], Value: line[idx+1:]}
+		} else if arg, ok := strings.CutPrefix(line, "arg "); ok {
+			name, value, _ := strings.Cut(arg, ": ")
+			in = keyInput{Kind: "arg", Name: name, Value: value}
+		} else if kind, value, ok := strings.Cut(line, ": "); ok {
+			in = keyInput{Kind: kind, Value: value}
+		} else {
+			in = keyInput{Kind: "unknown", Value: line}
+		}
+		desc.Inputs = append(desc.Inputs, in)
+	}
+
+	w := &prettyprint.Writer{
+		Out:    os.Stdout,
+		Format: prettyprint.TemplateFormat,
+		FormatString: `{{ .Package }}{{"\t"}}{{ .Key }}{{"\n"}}` +
+			`{{ range .Inputs }}{{"\t"}}{{ .Kind }}{{ if .Name }} {{ .Name }}{{ end }}{{"\t"}}{{ .Value }}{{"\n"}}{{ end }}`,
+	}
+	err = w.Write(desc)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
 
-	cmd.Flags().StringP("cache", "c", cacheDefault, "Configures the caching behaviour: none=no caching, local=local caching only, remote-pull=download from remote but never upload, remote-push=push to remote cache only but don't download, remote=use all configured caches")
+func addBuildFlags(cmd *cobra.Command) {
+	cpus := runtime.GOMAXPROCS(0)
+
+	cmd.Flags().StringP("cache", "c", "", "Configures the caching behaviour: none=no caching, local=local caching only, remote-pull=download from remote but never upload, remote-push=push to remote cache only but don't download, remote=use all configured caches (defaults to $BLAZEDOCK_DEFAULT_CACHE_LEVEL, the defaultCacheLevel of the workspace or remote)")
 	cmd.Flags().Bool("dry-run", false, "Don't actually build but stop after showing what would need to be built")
+	cmd.Flags().Bool("cache-report", false, "Print a summary of cache hits and misses once the build has finished")
+	cmd.Flags().Bool("timing-report", false, "Print the build duration of each package, slowest first, once the build has finished")
+	cmd.Flags().String("timing-json", "", "Writes the build duration of each package as JSON to a file once the build has finished")
 	cmd.Flags().String("dump-plan", "", "Writes the build plan as JSON to a file. Use \"-\" to write the build plan to stderr.")
 	cmd.Flags().Bool("werft", false, "Produce werft CI compatible output")
+	cmd.Flags().Bool("no-progress", false, "Don't show the progress of the build below its output. The progress is only shown on interactive terminals outside of CI.")
 	cmd.Flags().Bool("dont-test", false, "Disable all package-level tests (defaults to false)")
 	cmd.Flags().Bool("dont-compress", false, "Disable compression of build artifacts (defaults to false)")
+	cmd.Flags().String("cache-compression", os.Getenv(blazedock.EnvvarCacheCompression), "Compression of build artifacts: gzip, zstd or none (defaults to $BLAZEDOCK_CACHE_COMPRESSION or gzip)")
 	cmd.Flags().Bool("jailed-execution", false, "Run all build commands using runc (defaults to false)")
+	cmd.Flags().Bool("hermetic", false, "Run build commands with only PATH, HOME and the envPassthrough variables of the host environment (defaults to false)")
 	cmd.Flags().UintP("max-concurrent-tasks", "j", uint(cpus), "Limit the number of max concurrent build tasks - set to 0 to disable the limit")
+	cmd.Flags().Uint("jobs", uint(cpus), "Number of packages built concurrently. Alias for --max-concurrent-tasks")
+	cmd.Flags().Bool("keep-build-dir", false, "Keep the build directories of all packages, e.g. to inspect their intermediate files (defaults to false)")
+	cmd.Flags().Bool("keep-failed", os.Getenv(blazedock.EnvvarKeepFailed) == "true", "Keep the build directories of packages which failed to build and print their path (defaults to $BLAZEDOCK_KEEP_FAILED)")
+	cmd.Flags().Duration("timeout", 0, "Cancel the build commands of packages which don't set a timeout of their own once they've been running for this long, e.g. 30m (defaults to no timeout)")
+	cmd.Flags().Int("test-retries", 0, "Re-run failing build and test commands up to this many times before failing packages which don't set retries of their own, e.g. because of flaky tests")
+	cmd.Flags().Bool("fail-fast", true, "Stop building once a package fails. Use --fail-fast=false to build all packages whose dependencies succeeded and report all failures at the end")
 	cmd.Flags().String("coverage-output-path", "", "Output path where test coverage file will be copied after running tests")
 	cmd.Flags().StringToString("docker-build-options", nil, "Options passed to all 'docker build' commands")
 	cmd.Flags().String("report", "", "Generate a HTML report after the build has finished. (e.g. --report myreport.html)")
+	cmd.Flags().String("test-report", "", "Write the test results of all packages to a file once the build has finished (e.g. --test-report junit:results.xml)")
 	cmd.Flags().String("report-segment", os.Getenv("BLAZEDOCK_SEGMENT_KEY"), "Report build events to segment using the segment key (defaults to $BLAZEDOCK_SEGMENT_KEY)")
 	cmd.Flags().Bool("report-github", os.Getenv("GITHUB_OUTPUT") != "", "Report package build success/failure to GitHub Actions using the GITHUB_OUTPUT environment variable")
+	cmd.Flags().Bool("offline", os.Getenv(blazedock.EnvvarOffline) == "true", "Never read from or write to the remote cache and fail if a package is neither in the local cache nor buildable offline (defaults to $BLAZEDOCK_OFFLINE)")
+	cmd.Flags().Bool("no-cache-upload", false, "Download from the remote cache but never upload build artifacts to it, e.g. for builds of untrusted changes (see also $BLAZEDOCK_REMOTE_CACHE_READONLY)")
+	cmd.Flags().Bool("remote-cache-insecure", false, "Skip TLS certificate verification when talking to an S3-compatible or HTTP remote cache")
+	cmd.Flags().String("profile", "", "Applies the flag defaults of a profile defined in the WORKSPACE.yaml. Flags set on the command line take precedence.")
+
+	// We apply the profile before the command runs rather than in the root command's PersistentPreRun,
+	// because cobra skips the latter for subcommands which bring a PersistentPreRun of their own.
+	preRun := cmd.PreRun
+	cmd.PreRun = func(cmd *cobra.Command, args []string) {
+		resolved, err := applyProfile(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if resolved != nil {
+			log.WithFields(resolved).Debug("resolved profile flags")
+		}
+		if preRun != nil {
+			preRun(cmd, args)
+		}
+	}
+}
+
+// applyProfile sets the flags of the profile selected using --profile, unless they were set
+// explicitly on the command line. It returns the effective value of all flags named by the profile.
+func applyProfile(cmd *cobra.Command) (log.Fields, error) {
+	if cmd.Flags().Lookup("profile") == nil {
+		return nil, nil
+	}
+	name, _ := cmd.Flags().GetString("profile")
+	if name == "" {
+		return nil, nil
+	}
+
+	ws, err := blazedock.LoadWorkspaceConfig(workspace)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot load profiles: %w", err)
+	}
+	profile, ok := ws.Profiles[name]
+	if !ok {
+		return nil, xerrors.Errorf("unknown profile: %s", name)
+	}
+
+	resolved := make(log.Fields, len(profile.Flags)+1)
+	resolved["profile"] = name
+	for flagName, value := range profile.Flags {
+		flag := cmd.Flags().Lookup(flagName)
+		if flag == nil {
+			return nil, xerrors.Errorf("profile %s sets unknown flag %s", name, flagName)
+		}
+		if !flag.Changed {
+			err = cmd.Flags().Set(flagName, value)
+			if err != nil {
+				return nil, xerrors.Errorf("profile %s: invalid value for flag %s: %w", name, flagName, err)
+			}
+		}
+		resolved[flagName] = flag.Value.String()
+	}
+	return resolved, nil
+}
+
+// getCacheLevel determines the cache level of a build. --cache-level takes precedence over --cache, followed by
+// $BLAZEDOCK_DEFAULT_CACHE_LEVEL and the default cache level of the workspace. Without any of them we use all caches.
+func getCacheLevel(cmd *cobra.Command) (blazedock.CacheLevel, error) {
+	if flag := cmd.Flags().Lookup("cache-level"); flag != nil && flag.Changed {
+		switch lvl := blazedock.CacheLevel(flag.Value.String()); lvl {
+		case blazedock.CacheNone, blazedock.CacheLocal, blazedock.CacheRemote:
+			return lvl, nil
+		default:
+			return "", xerrors.Errorf("invalid --cache-level %s: must be one of none, local or remote", lvl)
+		}
+	}
+	if cm, _ := cmd.Flags().GetString("cache"); cm != "" {
+		return blazedock.CacheLevel(cm), nil
+	}
+	if cm := os.Getenv(EnvvarDefaultCacheLevel); cm != "" {
+		return blazedock.CacheLevel(cm), nil
+	}
+
+	ws, err := blazedock.LoadWorkspaceConfig(workspace)
+	if err != nil {
+		log.WithError(err).Debug("cannot load workspace config - not using its default cache level")
+	} else if ws.DefaultCacheLevel != blazedock.CacheUnspecified {
+		return ws.DefaultCacheLevel, nil
+	}
+	return blazedock.CacheRemote, nil
 }
 
 func getBuildOpts(cmd *cobra.Command) ([]blazedock.BuildOption, cache.LocalCache) {
-	cm, _ := cmd.Flags().GetString("cache")
-	log.WithField("cacheMode", cm).Debug("configuring caches")
-	cacheLevel := blazedock.CacheLevel(cm)
+	cacheLevel, err := getCacheLevel(cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.WithField("cacheMode", cacheLevel).Debug("configuring caches")
 
-	remoteCache := getRemoteCache()
+	offline, err := cmd.Flags().GetBool("offline")
+	if err != nil {
+		log.Fatal(err)
+	}
+	var remoteCache cache.RemoteCache
+	if offline {
+		log.Debug("offline - not using the remote cache")
+		remoteCache = remote.NewNoRemoteCache()
+	} else {
+		remoteCache = getRemoteCache(cmd)
+	}
 	switch cacheLevel {
 	case blazedock.CacheNone, blazedock.CacheLocal:
 		remoteCache = remote.NewNoRemoteCache()
@@ -204,21 +533,18 @@ func getBuildOpts(cmd *cobra.Command) ([]blazedock.BuildOption, cache.LocalCache
 	default:
 		log.Fatalf("invalid cache level: %s", cacheLevel)
 	}
+	if noUpload, _ := cmd.Flags().GetBool("no-cache-upload"); noUpload {
+		remoteCache = withReadOnlyRemoteCache(remoteCache, "--no-cache-upload is set")
+	}
 
-	var (
-		localCacheLoc string
-		err           error
-	)
+	var localCacheLoc string
 	if cacheLevel == blazedock.CacheNone {
 		localCacheLoc, err = os.MkdirTemp("", "blazedock")
 		if err != nil {
 			log.Fatal(err)
 		}
 	} else {
-		localCacheLoc = os.Getenv(blazedock.EnvvarCacheDir)
-		if localCacheLoc == "" {
-			localCacheLoc = filepath.Join(os.TempDir(), "blazedock", "cache")
-		}
+		localCacheLoc = getLocalCacheLocation()
 	}
 	// Ensure cache directory exists with proper permissions
 	if err := os.MkdirAll(localCacheLoc, 0755); err != nil {
@@ -252,8 +578,45 @@ func getBuildOpts(cmd *cobra.Command) ([]blazedock.BuildOption, cache.LocalCache
 		}
 	}
 
+	var cacheReport io.Writer
+	if cr, _ := cmd.Flags().GetBool("cache-report"); cr {
+		cacheReport = os.Stdout
+	}
+
+	var explain io.Writer
+	if ex, _ := cmd.Flags().GetBool("explain"); ex {
+		explain = os.Stdout
+	}
+
+	// --test and --force-test are only registered on the build command
+	var (
+		testCache, _ = cmd.Flags().GetBool("test")
+		forceTest, _ = cmd.Flags().GetBool("force-test")
+	)
+
+	var timingReport, timingJSON io.Writer
+	if tr, _ := cmd.Flags().GetBool("timing-report"); tr {
+		timingReport = os.Stdout
+	}
+	if fn, _ := cmd.Flags().GetString("timing-json"); fn != "" {
+		// The report is written once the build has finished, hence we leave closing the file to the OS
+		f, err := os.OpenFile(fn, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		timingJSON = f
+	}
+
 	var reporter blazedock.CompositeReporter
-	reporter = append(reporter, blazedock.NewConsoleReporter())
+	if logFormat == logFormatJSON {
+		reporter = append(reporter, blazedock.NewJSONReporter(os.Stdout))
+	} else if progress := getProgressReporter(cmd); progress != nil {
+		// everything printed during the build has to go through the progress reporter, lest it garbles the status line
+		log.SetOutput(progress.Writer(os.Stderr))
+		reporter = append(reporter, blazedock.NewConsoleReporterTo(progress.Writer(os.Stdout)), progress)
+	} else {
+		reporter = append(reporter, blazedock.NewConsoleReporter())
+	}
 
 	if werftlog, err := cmd.Flags().GetBool("werft"); err != nil {
 		log.Fatal(err)
@@ -265,6 +628,15 @@ func getBuildOpts(cmd *cobra.Command) ([]blazedock.BuildOption, cache.LocalCache
 	} else if report != "" {
 		reporter = append(reporter, blazedock.NewHTMLReporter(report))
 	}
+	if testReport, err := cmd.Flags().GetString("test-report"); err != nil {
+		log.Fatal(err)
+	} else if testReport != "" {
+		format, fn, ok := strings.Cut(testReport, ":")
+		if !ok || format != "junit" || fn == "" {
+			log.Fatalf("unsupported test report %q: use junit:<path>", testReport)
+		}
+		reporter = append(reporter, blazedock.NewJUnitReporter(fn, localCache))
+	}
 	if segmentkey, err := cmd.Flags().GetString("report-segment"); err != nil {
 		log.Fatal(err)
 	} else if segmentkey != "" {
@@ -275,6 +647,9 @@ func getBuildOpts(cmd *cobra.Command) ([]blazedock.BuildOption, cache.LocalCache
 	} else if github {
 		reporter = append(reporter, blazedock.NewGitHubReporter())
 	}
+	if fn := os.Getenv(EnvvarBuildTrace); fn != "" {
+		reporter = append(reporter, blazedock.NewChromeTraceReporter(fn))
+	}
 
 	dontTest, err := cmd.Flags().GetBool("dont-test")
 	if err != nil {
@@ -285,6 +660,39 @@ func getBuildOpts(cmd *cobra.Command) ([]blazedock.BuildOption, cache.LocalCache
 	if err != nil {
 		log.Fatal(err)
 	}
+	if cmd.Flags().Changed("jobs") {
+		maxConcurrentTasks, _ = cmd.Flags().GetUint("jobs")
+	}
+
+	hermetic, err := cmd.Flags().GetBool("hermetic")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	failFast, err := cmd.Flags().GetBool("fail-fast")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	keepBuildDir, err := cmd.Flags().GetBool("keep-build-dir")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	keepFailed, err := cmd.Flags().GetBool("keep-failed")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	testRetries, err := cmd.Flags().GetInt("test-retries")
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	coverageOutputPath, _ := cmd.Flags().GetString("coverage-output-path")
 	if coverageOutputPath != "" {
@@ -306,12 +714,24 @@ func getBuildOpts(cmd *cobra.Command) ([]blazedock.BuildOption, cache.LocalCache
 	if err != nil {
 		log.Fatal(err)
 	}
+	cacheCompression, err := cmd.Flags().GetString("cache-compression")
+	if err != nil {
+		log.Fatal(err)
+	}
+	compression, err := blazedock.ParseCompressionAlgorithm(cacheCompression)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	return []blazedock.BuildOption{
 		blazedock.WithLocalCache(localCache),
 		blazedock.WithRemoteCache(remoteCache),
 		blazedock.WithDryRun(dryrun),
 		blazedock.WithBuildPlan(planOutlet),
+		blazedock.WithCacheReport(cacheReport),
+		blazedock.WithExplain(explain),
+		blazedock.WithTimingReport(timingReport),
+		blazedock.WithTimingReportJSON(timingJSON),
 		blazedock.WithReporter(reporter),
 		blazedock.WithDontTest(dontTest),
 		blazedock.WithMaxConcurrentTasks(int64(maxConcurrentTasks)),
@@ -319,9 +739,52 @@ func getBuildOpts(cmd *cobra.Command) ([]blazedock.BuildOption, cache.LocalCache
 		blazedock.WithDockerBuildOptions(&dockerBuildOptions),
 		blazedock.WithJailedExecution(jailedExecution),
 		blazedock.WithCompressionDisabled(dontCompress),
+		blazedock.WithCacheCompression(compression),
+		blazedock.WithOffline(offline),
+		blazedock.WithFailFast(failFast),
+		blazedock.WithHermetic(hermetic),
+		blazedock.WithKeepBuildDir(keepBuildDir),
+		blazedock.WithKeepFailed(keepFailed),
+		blazedock.WithTimeout(timeout),
+		blazedock.WithTestRetries(testRetries),
+		blazedock.WithTestCache(testCache),
+		blazedock.WithForceTest(testCache && forceTest),
 	}, localCache
 }
 
+// ciEnvironmentVariables are set by CI systems. A build running in CI never shows its progress.
+var ciEnvironmentVariables = []string{"CI", "CONTINUOUS_INTEGRATION", "BUILD_NUMBER", "GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE", "CIRCLECI", "JENKINS_URL", "TEAMCITY_VERSION", "TF_BUILD", "WERFT_SERVICE_HOST"}
+
+// getProgressReporter returns a reporter showing the build progress on the terminal, or nil if the progress is not
+// to be shown: because --no-progress or --werft is set, stdout is no interactive terminal or we're running in CI.
+func getProgressReporter(cmd *cobra.Command) *blazedock.ProgressReporter {
+	if noProgress, _ := cmd.Flags().GetBool("no-progress"); noProgress {
+		return nil
+	}
+	if werft, _ := cmd.Flags().GetBool("werft"); werft {
+		return nil
+	}
+	if !color.IsTerminal(os.Stdout.Fd()) || os.Getenv("TERM") == "dumb" {
+		return nil
+	}
+	for _, env := range ciEnvironmentVariables {
+		if v := os.Getenv(env); v != "" && v != "false" {
+			log.WithField("env", env).Debug("running in CI - not showing the build progress")
+			return nil
+		}
+	}
+	return blazedock.NewProgressReporter(os.Stdout)
+}
+
+// getLocalCacheLocation returns the location of the persistent local build cache
+func getLocalCacheLocation() string {
+	loc := os.Getenv(blazedock.EnvvarCacheDir)
+	if loc == "" {
+		loc = filepath.Join(os.TempDir(), "blazedock", "cache")
+	}
+	return loc
+}
+
 type pushOnlyRemoteCache struct {
 	C cache.RemoteCache
 }
@@ -338,6 +801,10 @@ func (c *pushOnlyRemoteCache) Upload(ctx context.Context, src cache.LocalCache,
 	return c.C.Upload(ctx, src, pkgs)
 }
 
+func (c *pushOnlyRemoteCache) Delete(ctx context.Context, pkgs []cache.Package) error {
+	return c.C.Delete(ctx, pkgs)
+}
+
 type pullOnlyRemoteCache struct {
 	C cache.RemoteCache
 }
@@ -350,36 +817,134 @@ func (c *pullOnlyRemoteCache) Download(ctx context.Context, dst cache.LocalCache
 	return c.C.Download(ctx, dst, pkgs)
 }
 
+func (c *pullOnlyRemoteCache) VerifiesDownloads() bool {
+	return cache.VerifiesDownloads(c.C)
+}
+
 func (c *pullOnlyRemoteCache) Upload(ctx context.Context, src cache.LocalCache, pkgs []cache.Package) error {
 	return nil
 }
 
-func getRemoteCache() cache.RemoteCache {
+func (c *pullOnlyRemoteCache) Delete(ctx context.Context, pkgs []cache.Package) error {
+	return xerrors.Errorf("cannot delete from the remote cache: the cache level only allows pulling from it")
+}
+
+// readOnlyRemoteCache downloads from the remote cache but never writes to it, e.g. for builds of untrusted changes
+type readOnlyRemoteCache struct {
+	C cache.RemoteCache
+	// Reason explains why writes are suppressed
+	Reason string
+}
+
+// withReadOnlyRemoteCache suppresses all writes to the remote cache
+func withReadOnlyRemoteCache(rc cache.RemoteCache, reason string) cache.RemoteCache {
+	switch rc.(type) {
+	case *remote.NoRemoteCache, *readOnlyRemoteCache:
+		return rc
+	}
+	return &readOnlyRemoteCache{C: rc, Reason: reason}
+}
+
+func (c *readOnlyRemoteCache) ExistingPackages(ctx context.Context, pkgs []cache.Package) (map[cache.Package]struct{}, error) {
+	return c.C.ExistingPackages(ctx, pkgs)
+}
+
+func (c *readOnlyRemoteCache) Download(ctx context.Context, dst cache.LocalCache, pkgs []cache.Package) error {
+	return c.C.Download(ctx, dst, pkgs)
+}
+
+func (c *readOnlyRemoteCache) VerifiesDownloads() bool {
+	return cache.VerifiesDownloads(c.C)
+}
+
+func (c *readOnlyRemoteCache) Upload(ctx context.Context, src cache.LocalCache, pkgs []cache.Package) error {
+	if len(pkgs) > 0 {
+		log.WithField("reason", c.Reason).Infof("the remote cache is read-only - not uploading %d build artifacts", len(pkgs))
+	}
+	return nil
+}
+
+func (c *readOnlyRemoteCache) Delete(ctx context.Context, pkgs []cache.Package) error {
+	return xerrors.Errorf("cannot delete from the remote cache: the remote cache is read-only (%s)", c.Reason)
+}
+
+// getRemoteCache returns the remote cache configured through the environment. It is read-only if
+// $BLAZEDOCK_REMOTE_CACHE_READONLY is set to true.
+func getRemoteCache(cmd *cobra.Command) cache.RemoteCache {
+	rc := newRemoteCache(cmd)
+	if os.Getenv(EnvvarRemoteCacheReadonly) == "true" {
+		rc = withReadOnlyRemoteCache(rc, EnvvarRemoteCacheReadonly+" is set")
+	}
+	return rc
+}
+
+func newRemoteCache(cmd *cobra.Command) cache.RemoteCache {
 	remoteCacheBucket := os.Getenv(EnvvarRemoteCacheBucket)
 	remoteStorage := os.Getenv(EnvvarRemoteCacheStorage)
+	remoteCachePrefix := os.Getenv(EnvvarRemoteCachePrefix)
 	if remoteCacheBucket != "" {
 		switch remoteStorage {
 		case "GCP":
 			return remote.NewGSUtilCache(
 				&cache.RemoteConfig{
 					BucketName: remoteCacheBucket,
+					Prefix:     remoteCachePrefix,
 				},
 			)
 		case "AWS":
+			insecure, _ := cmd.Flags().GetBool("remote-cache-insecure")
+
 			rc, err := remote.NewS3Cache(
 				&cache.RemoteConfig{
-					BucketName: remoteCacheBucket,
+					BucketName:         remoteCacheBucket,
+					Endpoint:           getRemoteCacheEndpoint(),
+					InsecureSkipVerify: insecure,
+					Prefix:             remoteCachePrefix,
 				},
 			)
 			if err != nil {
 				log.Fatalf("cannot access remote S3 cache: %v", err)
 			}
 
+			return rc
+		case "HTTP":
+			httpCfg := remote.HTTPConfig{
+				Token: os.Getenv(EnvvarRemoteCacheToken),
+			}
+			if retries := os.Getenv(EnvvarRemoteCacheRetries); retries != "" {
+				n, err := strconv.Atoi(retries)
+				if err != nil {
+					log.Fatalf("invalid %s: %v", EnvvarRemoteCacheRetries, err)
+				}
+				httpCfg.MaxAttempts = n
+			}
+			if backoff := os.Getenv(EnvvarRemoteCacheBackoff); backoff != "" {
+				d, err := time.ParseDuration(backoff)
+				if err != nil {
+					log.Fatalf("invalid %s: %v", EnvvarRemoteCacheBackoff, err)
+				}
+				httpCfg.Backoff = d
+			}
+			insecure, _ := cmd.Flags().GetBool("remote-cache-insecure")
+
+			rc, err := remote.NewHTTPCache(
+				&cache.RemoteConfig{
+					BucketName:         remoteCacheBucket,
+					InsecureSkipVerify: insecure,
+					Prefix:             remoteCachePrefix,
+				},
+				httpCfg,
+			)
+			if err != nil {
+				log.Fatalf("cannot access remote HTTP cache: %v", err)
+			}
+
 			return rc
 		default:
 			return remote.NewGSUtilCache(
 				&cache.RemoteConfig{
 					BucketName: remoteCacheBucket,
+					Prefix:     remoteCachePrefix,
 				},
 			)
 		}
@@ -387,3 +952,16 @@ func getRemoteCache() cache.RemoteCache {
 
 	return remote.NewNoRemoteCache()
 }
+
+// getRemoteCacheEndpoint returns the endpoint of an S3-compatible remote cache. $BLAZEDOCK_REMOTE_CACHE_ENDPOINT
+// takes precedence over remoteCache.endpoint in the WORKSPACE.yaml.
+func getRemoteCacheEndpoint() string {
+	if endpoint := os.Getenv(EnvvarRemoteCacheEndpoint); endpoint != "" {
+		return endpoint
+	}
+	ws, err := blazedock.LoadWorkspaceConfig(workspace)
+	if err != nil {
+		log.WithError(err).Warn("cannot read remote cache endpoint from workspace - using the default S3 endpoint")
+	}
+	return ws.RemoteCache.Endpoint
+}
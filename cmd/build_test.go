@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/cobra"
+)
+
+func TestApplyProfile(t *testing.T) {
+	const workspaceYAML = `profiles:
+  ci:
+    flags:
+      dont-test: "true"
+      coverage-output-path: /tmp/coverage
+`
+
+	type Expectation struct {
+		DontTest           bool
+		CoverageOutputPath string
+	}
+	tests := []struct {
+		Name        string
+		Args        []string
+		Expectation Expectation
+	}{
+		{
+			Name:        "no profile",
+			Args:        []string{"sub"},
+			Expectation: Expectation{},
+		},
+		{
+			Name:        "profile",
+			Args:        []string{"sub", "--profile", "ci"},
+			Expectation: Expectation{DontTest: true, CoverageOutputPath: "/tmp/coverage"},
+		},
+		{
+			Name:        "command line takes precedence",
+			Args:        []string{"sub", "--profile", "ci", "--dont-test=false", "--coverage-output-path", "cover.out"},
+			Expectation: Expectation{DontTest: false, CoverageOutputPath: "cover.out"},
+		},
+	}
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "WORKSPACE.yaml"), []byte(workspaceYAML), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldWorkspace := workspace
+	workspace = dir
+	t.Cleanup(func() { workspace = oldWorkspace })
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var act Expectation
+			// both commands bring their own PersistentPreRun, so cobra only runs the one of the subcommand
+			parent := &cobra.Command{
+				Use:              "parent",
+				PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+			}
+			sub := &cobra.Command{
+				Use:              "sub",
+				PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+				Run: func(cmd *cobra.Command, args []string) {
+					act.DontTest, _ = cmd.Flags().GetBool("dont-test")
+					act.CoverageOutputPath, _ = cmd.Flags().GetString("coverage-output-path")
+				},
+			}
+			addBuildFlags(sub)
+			parent.AddCommand(sub)
+			parent.SetArgs(test.Args)
+
+			err := parent.Execute()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("applyProfile() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
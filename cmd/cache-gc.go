@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache/local"
+)
+
+// cacheGCCmd represents the cache gc command
+var cacheGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Removes build artifacts from the local cache which no package in the workspace references",
+	Long: `Removes build artifacts from the local cache which no package in the workspace references.
+
+Using --max-age, artifacts which were last modified before the given duration are removed even if they're still
+referenced. Using --max-size, the least recently used artifacts are removed until the cache fits the given budget
+(e.g. 500MB or 20GB). The remote cache is never touched.
+
+Identical artifacts are stored once, even if several versions produced them. Artifacts cached before blazedock
+deduplicated them are deduplicated when they're kept, and the space deduplication saves is reported.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ws, err := getWorkspace()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var (
+			maxAge, _  = cmd.Flags().GetDuration("max-age")
+			maxSize, _ = cmd.Flags().GetString("max-size")
+			dryRun, _  = cmd.Flags().GetBool("dry-run")
+		)
+		opts := local.GCOptions{
+			MaxAge: maxAge,
+			DryRun: dryRun,
+		}
+		if maxSize != "" {
+			opts.MaxSize, err = parseByteSize(maxSize)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		live := make([]cache.Package, 0, len(ws.Packages))
+		for _, pkg := range ws.Packages {
+			live = append(live, pkg)
+		}
+
+		fsc, err := local.NewFilesystemCache(getLocalCacheLocation())
+		if err != nil {
+			log.Fatal(err)
+		}
+		res, err := fsc.GC(live, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		verb := "reclaimed"
+		if dryRun {
+			verb = "would reclaim"
+			for _, e := range res.Evicted {
+				fmt.Printf("would delete %s (%s, %d bytes)\n", e.Path, e.Reason, e.Size)
+			}
+		}
+		fmt.Printf("%s %d entries (%d bytes), %d bytes remain in %s\n", verb, len(res.Evicted), res.ReclaimedBytes, res.RemainingBytes, fsc.Origin)
+		if res.DedupedBytes > 0 {
+			fmt.Printf("deduplication saves %d bytes: identical artifacts are stored once\n", res.DedupedBytes)
+		}
+	},
+}
+
+// parseByteSize parses sizes like 1024, 512KB, 20GB. Units are powers of 1024.
+func parseByteSize(s string) (int64, error) {
+	units := []struct {
+		Suffix string
+		Factor int64
+	}{
+		{"TB", 1 << 40},
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	}
+
+	in := strings.ToUpper(strings.TrimSpace(s))
+	factor := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(in, u.Suffix) {
+			in = strings.TrimSpace(strings.TrimSuffix(in, u.Suffix))
+			factor = u.Factor
+			break
+		}
+	}
+	n, err := strconv.ParseInt(in, 10, 64)
+	if err != nil || n < 0 {
+		return 0, xerrors.Errorf("invalid size %q: expected a number of bytes, optionally followed by KB, MB, GB or TB", s)
+	}
+	return n * factor, nil
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheGCCmd)
+	cacheGCCmd.Flags().Duration("max-age", 0, "Also remove referenced artifacts last modified longer ago than this (e.g. 168h)")
+	cacheGCCmd.Flags().String("max-size", "", "Remove the least recently used artifacts until the cache is no larger than this (e.g. 20GB)")
+	cacheGCCmd.Flags().Bool("dry-run", false, "Print what would be removed without removing anything")
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache/local"
+)
+
+// cacheVerifyCmd represents the cache verify command
+var cacheVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Checks all build artifacts in the local cache against their recorded checksums",
+	Long: `Checks all build artifacts in the local cache against their recorded checksums and reports corrupted ones.
+
+Corrupted artifacts are removed from the cache s.t. the next build downloads or builds them again, unless --dry-run
+is given. Artifacts produced by versions of blazedock which did not record checksums cannot be verified and are
+reported as such. Exits with a non-zero status if corrupted artifacts were found.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		fsc, err := local.NewFilesystemCache(getLocalCacheLocation())
+		if err != nil {
+			log.Fatal(err)
+		}
+		res, err := fsc.Verify(dryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var corrupted, unverified int
+		for _, e := range res {
+			switch e.Status {
+			case local.VerifyCorrupted:
+				corrupted++
+				if dryRun {
+					fmt.Printf("corrupted %s: %v\n", e.Path, e.Error)
+				} else {
+					fmt.Printf("removed corrupted %s: %v\n", e.Path, e.Error)
+				}
+			case local.VerifyNoChecksum:
+				unverified++
+				log.WithField("path", e.Path).Debug("no checksum recorded")
+			}
+		}
+		fmt.Printf("verified %d entries in %s: %d corrupted, %d without checksum\n", len(res), fsc.Origin, corrupted, unverified)
+		if corrupted > 0 {
+			log.Fatalf("found %d corrupted build artifacts", corrupted)
+		}
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheVerifyCmd)
+	cacheVerifyCmd.Flags().Bool("dry-run", false, "Report corrupted artifacts without removing them")
+}
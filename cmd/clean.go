@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache/local"
+)
+
+// cleanCmd represents the clean command
+var cleanCmd = &cobra.Command{
+	Use:   "clean <package>",
+	Short: "Removes a package from the build cache s.t. it is rebuilt",
+	Long: `Removes the build artifact of a package from the local cache s.t. the next build rebuilds it.
+
+Using --with-dependents, the artifacts of all packages which transitively depend on the package are removed as well.
+Using --remote, the artifacts are also deleted from the configured remote cache.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completePackageNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, pkg, _, _ := getTarget(args, false)
+		if pkg == nil {
+			log.Fatal("clean needs a package")
+		}
+
+		var (
+			withDependents, _ = cmd.Flags().GetBool("with-dependents")
+			withRemote, _     = cmd.Flags().GetBool("remote")
+		)
+		pkgs := []*blazedock.Package{pkg}
+		if withDependents {
+			dependents := pkg.TransitiveDependants()
+			sort.Slice(dependents, func(i, j int) bool { return dependents[i].FullName() < dependents[j].FullName() })
+			pkgs = append(pkgs, dependents...)
+		}
+
+		fsc, err := local.NewFilesystemCache(getLocalCacheLocation())
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, p := range pkgs {
+			removed, err := fsc.Remove(p)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if len(removed) == 0 {
+				fmt.Printf("%s is not in the local cache\n", p.FullName())
+				continue
+			}
+			for _, fn := range removed {
+				fmt.Printf("removed %s (%s)\n", fn, p.FullName())
+			}
+		}
+
+		if !withRemote {
+			return
+		}
+		rpkgs := make([]cache.Package, 0, len(pkgs))
+		for _, p := range pkgs {
+			rpkgs = append(rpkgs, p)
+		}
+		err = getRemoteCache(cmd).Delete(context.Background(), rpkgs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, p := range pkgs {
+			fmt.Printf("deleted %s from the remote cache\n", p.FullName())
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cleanCmd)
+	cleanCmd.Flags().Bool("with-dependents", false, "Also remove all packages which transitively depend on the package")
+	cleanCmd.Flags().Bool("remote", false, "Also delete the packages from the remote cache")
+	cleanCmd.Flags().Bool("remote-cache-insecure", false, "Skip TLS certificate verification when talking to an S3-compatible or HTTP remote cache")
+}
@@ -23,16 +23,38 @@ type variantDescription struct {
 		Include []string `json:"include" yaml:"include"`
 		Exclude []string `json:"exclude" yaml:"exclude"`
 	} `json:"srcs" yaml:"srcs"`
-	Environment []string                                 `json:"env" yaml:"env"`
-	Config      map[blazedock.PackageType]configDescription `json:"config" yaml:"config"`
+	ExcludedComponents []string                                    `json:"excludedComponents,omitempty" yaml:"excludedComponents,omitempty"`
+	Environment        []string                                    `json:"env" yaml:"env"`
+	DefaultArgs        map[string]string                           `json:"defaultArgs,omitempty" yaml:"defaultArgs,omitempty"`
+	Config             map[blazedock.PackageType]configDescription `json:"config" yaml:"config"`
+}
+
+func newVariantDescription(v *blazedock.PackageVariant) variantDescription {
+	desc := variantDescription{
+		Name:               v.Name,
+		ExcludedComponents: v.Components.Exclude,
+		Environment:        v.Environment,
+		DefaultArgs:        v.ArgumentDefaults,
+		Config:             make(map[blazedock.PackageType]configDescription),
+	}
+	desc.Sources.Exclude = v.Sources.Exclude
+	desc.Sources.Include = v.Sources.Include
+	for _, t := range []blazedock.PackageType{blazedock.DockerPackage, blazedock.GenericPackage, blazedock.GoPackage, blazedock.YarnPackage, blazedock.RustPackage, blazedock.PythonPackage} {
+		vntcfg, ok := v.Config(t)
+		if !ok {
+			continue
+		}
+		desc.Config[t] = newConfigDescription(t, vntcfg)
+	}
+	return desc
 }
 
 // collectCmd represents the collect command
 var collectCmd = &cobra.Command{
-	Use:       "collect [components|packages|scripts|files]",
+	Use:       "collect [components|packages|scripts|files|variants]",
 	Short:     "Collects all packages in a workspace",
 	Args:      cobra.MatchAll(cobra.OnlyValidArgs, cobra.MaximumNArgs(1)),
-	ValidArgs: []string{"components", "packages", "scripts", "scripts", "files"},
+	ValidArgs: []string{"components", "packages", "scripts", "files", "variants"},
 	Run: func(cmd *cobra.Command, args []string) {
 		workspace, err := getWorkspace()
 		if err != nil {
@@ -152,20 +174,7 @@ var collectCmd = &cobra.Command{
 			}
 			decs := make([]variantDescription, len(workspace.Variants))
 			for i, v := range workspace.Variants {
-				decs[i] = variantDescription{
-					Name:        v.Name,
-					Environment: v.Environment,
-					Config:      make(map[blazedock.PackageType]configDescription),
-				}
-				decs[i].Sources.Exclude = v.Sources.Exclude
-				decs[i].Sources.Include = v.Sources.Include
-				for _, t := range []blazedock.PackageType{blazedock.DockerPackage, blazedock.GenericPackage, blazedock.GoPackage, blazedock.YarnPackage} {
-					vntcfg, ok := v.Config(t)
-					if !ok {
-						continue
-					}
-					decs[i].Config[t] = newConfigDescription(t, vntcfg)
-				}
+				decs[i] = newVariantDescription(v)
 			}
 			err = w.Write(decs)
 			if err != nil {
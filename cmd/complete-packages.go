@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// completePackagesCmd represents the __complete-packages command
+var completePackagesCmd = &cobra.Command{
+	Use:    "__complete-packages",
+	Short:  "Lists the names of all packages in the workspace for shell completion",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		names, err := cachedPackageNames()
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := bufio.NewWriter(os.Stdout)
+		for _, n := range names {
+			fmt.Fprintln(w, n)
+		}
+		w.Flush()
+	},
+}
+
+// completePackageNames completes the first argument with the names of all packages in the workspace.
+// It's the counterpart of __blazedock_parse_get for shells which use cobra's dynamic completion, e.g. zsh and fish.
+func completePackageNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	names, err := cachedPackageNames()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	res := make([]string, 0, len(names))
+	for _, n := range names {
+		if strings.HasPrefix(n, toComplete) {
+			res = append(res, n)
+		}
+	}
+	return res, cobra.ShellCompDirectiveNoFileComp
+}
+
+// cachedPackageNames returns the sorted names of all packages in the workspace. Loading a large workspace takes
+// a while, hence the names are cached on disk. The cache is keyed by the workspace location and the selected variants,
+// and is valid as long as none of the files which declare packages changed.
+func cachedPackageNames() ([]string, error) {
+	root, err := filepath.Abs(workspace)
+	if err != nil {
+		return nil, err
+	}
+	state, err := workspaceState(root)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	cacheDir = filepath.Join(cacheDir, "blazedock", "completion")
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s", root, strings.Join(variants, ","))))
+	fn := filepath.Join(cacheDir, fmt.Sprintf("%x", key[:16]))
+
+	if fc, err := os.ReadFile(fn); err == nil {
+		fields := strings.Fields(string(fc))
+		if len(fields) > 0 && fields[0] == state {
+			return fields[1:], nil
+		}
+	}
+
+	ws, err := getWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(ws.Packages))
+	for name := range ws.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// failing to cache the names only costs us time on the next completion
+	err = writeCompletionCache(cacheDir, fn, state, names)
+	if err != nil {
+		log.WithError(err).Debug("cannot cache package names for completion")
+	}
+	return names, nil
+}
+
+// workspaceState hashes the location and mtime of the WORKSPACE.yaml, its companion files and all BUILD.yaml files
+// of the workspace at root. Finding the BUILD.yaml files is cheap compared to loading the components they declare.
+func workspaceState(root string) (string, error) {
+	components, err := blazedock.ComponentFiles(root)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for _, fn := range append([]string{
+		filepath.Join(root, "WORKSPACE.yaml"),
+		filepath.Join(root, "WORKSPACE.args.yaml"),
+		filepath.Join(root, ".blazedockignore"),
+	}, components...) {
+		stat, err := os.Stat(fn)
+		if os.IsNotExist(err) {
+			fmt.Fprintf(h, "%s\x00-\x00", fn)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", fn, stat.ModTime().UnixNano())
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// writeCompletionCache atomically replaces the cache file
+func writeCompletionCache(cacheDir, fn, state string, names []string) error {
+	err := os.MkdirAll(cacheDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(cacheDir, ".names-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(state + "\n" + strings.Join(names, "\n") + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), fn)
+}
+
+func init() {
+	rootCmd.AddCommand(completePackagesCmd)
+}
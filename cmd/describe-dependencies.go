@@ -62,6 +62,10 @@ func printDepTree(pkg *blazedock.Package, indent int) {
 		tpe = "go"
 	case blazedock.YarnPackage:
 		tpe = "yarn"
+	case blazedock.RustPackage:
+		tpe = "rust"
+	case blazedock.PythonPackage:
+		tpe = "python"
 	}
 
 	fmt.Printf("%*s%s %s\n", indent, "", color.Gray.Sprintf("[%7s]", tpe), pkg.FullName())
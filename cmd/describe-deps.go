@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/prettyprint"
+)
+
+// describeDepsCmd represents the describeDeps command
+var describeDepsCmd = &cobra.Command{
+	Use:   "deps <package>",
+	Short: "Lists the transitive dependencies of a package",
+	Long: `Lists all packages a package depends on, directly or transitively, together with their type and version.
+Unlike "describe dependencies" each dependency is listed once, which makes the output suitable for feeding inventory and SBOM tooling.`,
+	Args: cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) >= 1 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completePackageNames(cmd, nil, toComplete)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		var (
+			directOnly, _ = cmd.Flags().GetBool("direct-only")
+			types, _      = cmd.Flags().GetStringSlice("type")
+		)
+		typeFilter := make(map[blazedock.PackageType]bool, len(types))
+		for _, t := range types {
+			tpe := blazedock.PackageType(t)
+			switch tpe {
+			case blazedock.DockerPackage, blazedock.GenericPackage, blazedock.GoPackage, blazedock.YarnPackage, blazedock.RustPackage, blazedock.PythonPackage:
+			default:
+				log.Fatalf("unknown package type: %s", t)
+			}
+			typeFilter[tpe] = true
+		}
+
+		_, pkg, _, _ := getTarget(args, false)
+		if pkg == nil {
+			log.Fatal("deps needs a package")
+		}
+
+		var deps []*blazedock.Package
+		if directOnly {
+			deps = pkg.GetDependencies()
+		} else {
+			deps = pkg.GetTransitiveDependencies()
+		}
+
+		type depDesc struct {
+			Name    string `json:"name" yaml:"name"`
+			Type    string `json:"type" yaml:"type"`
+			Version string `json:"version" yaml:"version"`
+		}
+		desc := make([]depDesc, 0, len(deps))
+		for _, dep := range deps {
+			if len(typeFilter) > 0 && !typeFilter[dep.Type] {
+				continue
+			}
+			version, err := dep.Version()
+			if err != nil {
+				log.Fatal(err)
+			}
+			desc = append(desc, depDesc{Name: dep.FullName(), Type: string(dep.Type), Version: version})
+		}
+		sort.Slice(desc, func(i, j int) bool { return desc[i].Name < desc[j].Name })
+
+		w := getWriterFromFlags(cmd)
+		if w.Format == prettyprint.TemplateFormat && w.FormatString == "" {
+			w.FormatString = `{{ range . }}{{ .Name }}{{"\t"}}{{ .Type }}{{"\n"}}{{ end }}`
+		}
+		err := w.Write(desc)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	describeCmd.AddCommand(describeDepsCmd)
+	addFormatFlags(describeDepsCmd)
+	describeDepsCmd.Flags().Bool("direct-only", false, "only list the direct dependencies of the package")
+	describeDepsCmd.Flags().StringSlice("type", nil, "only list dependencies of this type, e.g. go, yarn or docker (can be used multiple times)")
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache/local"
+	"github.com/khulnasoft/blazedock/pkg/prettyprint"
+)
+
+// describeDigestCmd represents the describeDigest command
+var describeDigestCmd = &cobra.Command{
+	Use:   "digest <package>",
+	Short: "Prints the sha256 digest of a package's build artifact",
+	Long: `Prints the sha256 digest of a package's build artifact in the local cache.
+The package must have been built (or downloaded from the remote cache) before.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, pkg, _, _ := getTarget(args, false)
+		if pkg == nil {
+			log.Fatal("digest needs a package")
+		}
+
+		fsc, err := local.NewFilesystemCache(getLocalCacheLocation())
+		if err != nil {
+			log.Fatal(err)
+		}
+		fn, ok := fsc.Location(pkg)
+		if !ok {
+			log.Fatalf("%s is not built - run blazedock build %s first", pkg.FullName(), pkg.FullName())
+		}
+		sum, err := cache.Checksum(fn)
+		if err != nil {
+			log.WithError(err).Fatal("cannot compute digest")
+		}
+		version, err := pkg.Version()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		desc := struct {
+			Package  string `json:"package" yaml:"package"`
+			Version  string `json:"version" yaml:"version"`
+			Artifact string `json:"artifact" yaml:"artifact"`
+			Digest   string `json:"sha256" yaml:"sha256"`
+		}{
+			Package:  pkg.FullName(),
+			Version:  version,
+			Artifact: fn,
+			Digest:   sum,
+		}
+
+		w := getWriterFromFlags(cmd)
+		if w.Format == prettyprint.TemplateFormat && w.FormatString == "" {
+			w.FormatString = `{{ .Digest }}{{"\n"}}`
+		}
+		err = w.Write(desc)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	describeCmd.AddCommand(describeDigestCmd)
+	addFormatFlags(describeDigestCmd)
+}
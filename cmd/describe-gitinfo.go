@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/prettyprint"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -13,18 +14,23 @@ var describeGitInfoCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		comp, pkg, script, _ := getTarget(args, false)
 
+		var opts []blazedock.GitOption
+		if dirtyFiles, _ := cmd.Flags().GetBool("dirty-files"); dirtyFiles {
+			opts = append(opts, blazedock.WithDirtyPaths())
+		}
+
 		var (
 			nfo *blazedock.GitInfo
 			loc string
 		)
 		if comp != nil {
-			nfo = comp.Git()
+			nfo = comp.Git(opts...)
 			loc = comp.Origin
 		} else if pkg != nil {
-			nfo = pkg.C.Git()
+			nfo = pkg.C.Git(opts...)
 			loc = pkg.C.Origin
 		} else if script != nil {
-			nfo = script.C.Git()
+			nfo = script.C.Git(opts...)
 			loc = script.C.Origin
 		} else {
 			log.Fatal("no target given - try passing a package or component")
@@ -34,20 +40,45 @@ var describeGitInfoCmd = &cobra.Command{
 			log.WithField("loc", loc).Fatal("not a Git working copy")
 		}
 		w := getWriterFromFlags(cmd)
-		if w.FormatString == "" {
+		if w.Format == prettyprint.TemplateFormat && w.FormatString == "" {
 			w.FormatString = `dirty:	{{.Dirty }}
 origin:	{{ .Origin }}
 commit:	{{ .Commit }}
-`
+short commit:	{{ .ShortCommit }}
+{{ range .DirtyFiles }}dirty file:	{{ . }}
+{{ end }}`
 		}
-		err := w.Write(nfo)
+		err := w.Write(newGitInfoDescription(nfo, loc))
 		if err != nil {
 			log.WithError(err).Fatal("cannot write git info")
 		}
 	},
 }
 
+type gitInfoDescription struct {
+	Location       string   `json:"location" yaml:"location"`
+	WorkingCopyLoc string   `json:"workingCopy" yaml:"workingCopy"`
+	Commit         string   `json:"commit" yaml:"commit"`
+	ShortCommit    string   `json:"shortCommit" yaml:"shortCommit"`
+	Origin         string   `json:"origin" yaml:"origin"`
+	Dirty          bool     `json:"dirty" yaml:"dirty"`
+	DirtyFiles     []string `json:"dirtyFiles,omitempty" yaml:"dirtyFiles,omitempty"`
+}
+
+func newGitInfoDescription(nfo *blazedock.GitInfo, loc string) gitInfoDescription {
+	return gitInfoDescription{
+		Location:       loc,
+		WorkingCopyLoc: nfo.WorkingCopyLoc,
+		Commit:         nfo.Commit,
+		ShortCommit:    nfo.ShortCommit,
+		Origin:         nfo.Origin,
+		Dirty:          nfo.IsDirty(),
+		DirtyFiles:     nfo.DirtyPaths,
+	}
+}
+
 func init() {
 	describeCmd.AddCommand(describeGitInfoCmd)
 	addFormatFlags(describeGitInfoCmd)
+	describeGitInfoCmd.Flags().Bool("dirty-files", false, "list the modified and untracked files of the component")
 }
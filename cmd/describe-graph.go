@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/prettyprint"
+)
+
+// describeGraphCmd represents the describeGraph command
+var describeGraphCmd = &cobra.Command{
+	Use:   "graph <package>",
+	Short: "Describes the dependency graph of a package in Graphviz's dot format or as JSON/YAML",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, pkg, _, _ := getTarget(args, false)
+		if pkg == nil {
+			log.Fatal("graph needs a package")
+		}
+
+		direction, _ := cmd.Flags().GetString("direction")
+		g, err := newPackageGraph(pkg, direction)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		format, _ := cmd.Flags().GetString("format")
+		if format == "dot" {
+			err = g.WriteDot(os.Stdout)
+		} else {
+			w := &prettyprint.Writer{Out: os.Stdout, Format: prettyprint.Format(format)}
+			err = w.Write(g)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+type packageGraph struct {
+	Root  string             `json:"root" yaml:"root"`
+	Nodes []packageGraphNode `json:"nodes" yaml:"nodes"`
+	Edges []packageGraphEdge `json:"edges" yaml:"edges"`
+}
+
+type packageGraphNode struct {
+	Name string `json:"name" yaml:"name"`
+	Type string `json:"type" yaml:"type"`
+}
+
+// packageGraphEdge points from a package to one of its dependencies
+type packageGraphEdge struct {
+	From       string `json:"from" yaml:"from"`
+	To         string `json:"to" yaml:"to"`
+	Transitive bool   `json:"transitive,omitempty" yaml:"transitive,omitempty"`
+}
+
+// newPackageGraph builds the graph of all dependencies or dependents of root. Besides the direct edges between all packages
+// in the graph, root is connected to every package it is only related to transitively.
+func newPackageGraph(root *blazedock.Package, direction string) (*packageGraph, error) {
+	var related []*blazedock.Package
+	switch direction {
+	case "deps":
+		related = root.GetTransitiveDependencies()
+	case "dependents":
+		related = root.TransitiveDependants()
+	default:
+		return nil, xerrors.Errorf("unknown direction %q - valid values are deps and dependents", direction)
+	}
+
+	var (
+		members = map[string]*blazedock.Package{root.FullName(): root}
+		res     = &packageGraph{Root: root.FullName()}
+	)
+	for _, p := range related {
+		members[p.FullName()] = p
+	}
+	for name, p := range members {
+		res.Nodes = append(res.Nodes, packageGraphNode{Name: name, Type: string(p.Type)})
+		for _, dep := range p.GetDependencies() {
+			if _, ok := members[dep.FullName()]; !ok {
+				continue
+			}
+			res.Edges = append(res.Edges, packageGraphEdge{From: name, To: dep.FullName()})
+		}
+	}
+
+	direct := make(map[string]struct{})
+	for _, e := range res.Edges {
+		if e.From == root.FullName() || e.To == root.FullName() {
+			direct[e.From+"->"+e.To] = struct{}{}
+		}
+	}
+	for _, p := range related {
+		e := packageGraphEdge{From: root.FullName(), To: p.FullName(), Transitive: true}
+		if direction == "dependents" {
+			e.From, e.To = e.To, e.From
+		}
+		if _, ok := direct[e.From+"->"+e.To]; ok {
+			continue
+		}
+		res.Edges = append(res.Edges, e)
+	}
+
+	sort.Slice(res.Nodes, func(i, j int) bool { return res.Nodes[i].Name < res.Nodes[j].Name })
+	sort.Slice(res.Edges, func(i, j int) bool {
+		if res.Edges[i].From != res.Edges[j].From {
+			return res.Edges[i].From < res.Edges[j].From
+		}
+		return res.Edges[i].To < res.Edges[j].To
+	})
+	return res, nil
+}
+
+// WriteDot writes the graph in Graphviz's dot format. Transitive edges are dashed.
+func (g *packageGraph) WriteDot(out io.Writer) error {
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(out, format, args...)
+	}
+
+	printf("digraph G {\n")
+	for _, n := range g.Nodes {
+		attrs := fmt.Sprintf("label=%q, type=%q", n.Name, n.Type)
+		if n.Name == g.Root {
+			attrs += ", style=bold"
+		}
+		printf("  %q [%s];\n", n.Name, attrs)
+	}
+	for _, e := range g.Edges {
+		if e.Transitive {
+			printf("  %q -> %q [transitive=true, style=dashed];\n", e.From, e.To)
+		} else {
+			printf("  %q -> %q;\n", e.From, e.To)
+		}
+	}
+	printf("}\n")
+	return err
+}
+
+func init() {
+	describeCmd.AddCommand(describeGraphCmd)
+
+	describeGraphCmd.Flags().StringP("format", "o", "dot", "the graph format. Valid choices are: dot, json or yaml")
+	describeGraphCmd.Flags().String("direction", "deps", "show what the package depends on (deps) or what depends on it (dependents)")
+}
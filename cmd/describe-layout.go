@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/prettyprint"
+)
+
+// describeLayoutCmd represents the describeLayout command
+var describeLayoutCmd = &cobra.Command{
+	Use:   "layout <package>",
+	Short: "Lists where the dependencies of a package are placed in its build dir",
+	Long: `Lists the dependencies blazedock unpacks in the build dir of a package and their location relative to it, sorted by location.
+Dependencies which share a location overwrite each other and are marked as collisions. Locations set in the layout of the package are marked as explicit.`,
+	Args: cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) >= 1 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completePackageNames(cmd, nil, toComplete)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		_, pkg, _, _ := getTarget(args, false)
+		if pkg == nil {
+			log.Fatal("layout needs a package")
+		}
+
+		w := getWriterFromFlags(cmd)
+		if w.Format == prettyprint.TemplateFormat && w.FormatString == "" {
+			w.FormatString = `{{ range . }}{{ .Location }}{{"\t"}}{{ .Dependency }}` +
+				`{{ if .Explicit }}{{"\t"}}(explicit){{ end }}{{ if .Collision }}{{"\t"}}(collision){{ end }}{{"\n"}}{{ end }}`
+		}
+		err := w.Write(newLayoutDescription(pkg))
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+type layoutEntryDescription struct {
+	Location   string `json:"location" yaml:"location"`
+	Dependency string `json:"dependency" yaml:"dependency"`
+	Explicit   bool   `json:"explicit,omitempty" yaml:"explicit,omitempty"`
+	Collision  bool   `json:"collision,omitempty" yaml:"collision,omitempty"`
+}
+
+func newLayoutDescription(pkg *blazedock.Package) []layoutEntryDescription {
+	layout := pkg.BuildLayout()
+	taken := make(map[string]int, len(layout))
+	for _, e := range layout {
+		taken[e.Location]++
+	}
+
+	res := make([]layoutEntryDescription, 0, len(layout))
+	for _, e := range layout {
+		_, explicit := pkg.Layout[e.Dependency.FullName()]
+		res = append(res, layoutEntryDescription{
+			Location:   e.Location,
+			Dependency: e.Dependency.FullName(),
+			Explicit:   explicit,
+			Collision:  taken[e.Location] > 1,
+		})
+	}
+	return res
+}
+
+func init() {
+	describeCmd.AddCommand(describeLayoutCmd)
+	addFormatFlags(describeLayoutCmd)
+}
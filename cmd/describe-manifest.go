@@ -1,7 +1,8 @@
 package cmd
 
 import (
-	"os"
+	"bytes"
+	"fmt"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -17,10 +18,12 @@ var describeManifestCmd = &cobra.Command{
 			log.Fatal("manifest needs a package")
 		}
 
-		err := pkg.WriteVersionManifest(os.Stdout)
+		var manifest bytes.Buffer
+		err := pkg.WriteVersionManifest(&manifest)
 		if err != nil {
 			log.Fatal(err)
 		}
+		fmt.Print(pkg.C.W.Redact(manifest.String()))
 	},
 }
 
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/prettyprint"
+)
+
+// describePackageCmd represents the describePackage command
+var describePackageCmd = &cobra.Command{
+	Use:   "package <package>",
+	Short: "Prints the fully resolved configuration of a package",
+	Long: `Prints a package the way blazedock builds it, i.e. after applying the selected variants and build arguments.
+Unlike "describe <package>" this includes the complete package config and the pre- and post-build commands.
+Values of config fields and environment variables whose names suggest a secret (e.g. *_TOKEN) are redacted and listed under "redacted".
+So are the values of secret build arguments, wherever they were substituted into the package.`,
+	Args: cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) >= 1 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completePackageNames(cmd, nil, toComplete)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		_, pkg, _, _ := getTarget(args, false)
+		if pkg == nil {
+			log.Fatal("package needs a package")
+		}
+
+		desc, err := newResolvedPackageDescription(pkg)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		w := getWriterFromFlags(cmd)
+		if w.Format == prettyprint.TemplateFormat && w.FormatString == "" {
+			w.FormatString = `{{ .Name }}{{"\t"}}{{ .Type }}{{"\t"}}{{ .Version }}{{"\n"}}` +
+				`{{ if .Variant }}variant:{{"\t"}}{{ .Variant }}{{"\n"}}{{ end }}` +
+				`{{ if .Sources }}sources:{{"\n"}}{{ range .Sources }}{{"\t"}}{{ . }}{{"\n"}}{{ end }}{{ end }}` +
+				`{{ if .Dependencies }}dependencies:{{"\n"}}{{ range $dep, $loc := .Layout }}{{"\t"}}{{ $dep }}{{"\t"}}{{ $loc }}{{"\n"}}{{ end }}{{ end }}` +
+				`{{ if .Env }}env:{{"\n"}}{{ range .Env }}{{"\t"}}{{ . }}{{"\n"}}{{ end }}{{ end }}` +
+				`{{ if .PreBuild }}preBuild:{{"\n"}}{{ range .PreBuild }}{{"\t"}}{{ . }}{{"\n"}}{{ end }}{{ end }}` +
+				`{{ if .PostBuild }}postBuild:{{"\n"}}{{ range .PostBuild }}{{"\t"}}{{ . }}{{"\n"}}{{ end }}{{ end }}` +
+				`{{ if .Config }}config:{{"\n"}}{{ range $k, $v := .Config }}{{"\t"}}{{ $k }}: {{ $v }}{{"\n"}}{{ end }}{{ end }}`
+		}
+		err = w.Write(desc)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+type resolvedPackageDescription struct {
+	Name         string                 `json:"name" yaml:"name"`
+	Type         string                 `json:"type" yaml:"type"`
+	Version      string                 `json:"version" yaml:"version"`
+	Component    string                 `json:"component" yaml:"component"`
+	Variant      string                 `json:"variant,omitempty" yaml:"variant,omitempty"`
+	Ephemeral    bool                   `json:"ephemeral,omitempty" yaml:"ephemeral,omitempty"`
+	Sources      []string               `json:"sources,omitempty" yaml:"sources,omitempty"`
+	Dependencies []string               `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
+	Layout       map[string]string      `json:"layout,omitempty" yaml:"layout,omitempty"`
+	ArgDeps      []string               `json:"argdeps,omitempty" yaml:"argdeps,omitempty"`
+	Env          []string               `json:"env,omitempty" yaml:"env,omitempty"`
+	Prep         [][]string             `json:"prep,omitempty" yaml:"prep,omitempty"`
+	PreBuild     [][]string             `json:"preBuild,omitempty" yaml:"preBuild,omitempty"`
+	PostBuild    [][]string             `json:"postBuild,omitempty" yaml:"postBuild,omitempty"`
+	Config       map[string]interface{} `json:"config,omitempty" yaml:"config,omitempty"`
+	Redacted     []string               `json:"redacted,omitempty" yaml:"redacted,omitempty"`
+}
+
+func newResolvedPackageDescription(pkg *blazedock.Package) (*resolvedPackageDescription, error) {
+	version, err := pkg.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &resolvedPackageDescription{
+		Name:      pkg.FullName(),
+		Type:      string(pkg.Type),
+		Version:   version,
+		Component: pkg.C.Name,
+		Ephemeral: pkg.Ephemeral,
+		Prep:      redactCommands(pkg.C.W, pkg.PreparationCommands),
+		PreBuild:  redactCommands(pkg.C.W, pkg.PreBuildCommands),
+		PostBuild: redactCommands(pkg.C.W, pkg.PostBuildCommands),
+	}
+	if vnt := pkg.C.W.SelectedVariant; vnt != nil {
+		res.Variant = vnt.Name
+	}
+
+	for _, src := range pkg.Sources {
+		if rel, err := filepath.Rel(pkg.C.Origin, src); err == nil && !strings.HasPrefix(rel, "..") {
+			src = rel
+		}
+		res.Sources = append(res.Sources, src)
+	}
+	sort.Strings(res.Sources)
+
+	if deps := pkg.GetDependencies(); len(deps) > 0 {
+		res.Layout = make(map[string]string, len(deps))
+		for _, dep := range deps {
+			res.Dependencies = append(res.Dependencies, dep.FullName())
+			res.Layout[dep.FullName()] = pkg.BuildLayoutLocation(dep)
+		}
+		sort.Strings(res.Dependencies)
+	}
+
+	for _, argdep := range pkg.ArgumentDependencies {
+		// argdeps are resolved to "name: value"
+		if name, _, ok := strings.Cut(argdep, ": "); ok && pkg.C.W.IsSecretArgument(name) {
+			argdep = name + ": " + blazedock.RedactedValue
+			res.Redacted = append(res.Redacted, "arg."+name)
+		}
+		res.ArgDeps = append(res.ArgDeps, argdep)
+	}
+
+	for _, env := range pkg.Environment {
+		if name, _, ok := strings.Cut(env, "="); ok && blazedock.LooksLikeSecret(name) {
+			env = name + "=" + blazedock.RedactedValue
+			res.Redacted = append(res.Redacted, "env."+name)
+		}
+		res.Env = append(res.Env, pkg.C.W.Redact(env))
+	}
+
+	// we go through YAML to list all config fields under the names they have in the BUILD.yaml
+	fc, err := yaml.Marshal(pkg.Config)
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal([]byte(pkg.C.W.Redact(string(fc))), &res.Config)
+	if err != nil {
+		return nil, err
+	}
+	res.Redacted = append(res.Redacted, redactSecrets("config", res.Config)...)
+	sort.Strings(res.Redacted)
+
+	return res, nil
+}
+
+// redactCommands replaces the values of secret build arguments substituted into the commands
+func redactCommands(ws *blazedock.Workspace, cmds [][]string) [][]string {
+	if cmds == nil {
+		return nil
+	}
+	res := make([][]string, 0, len(cmds))
+	for _, c := range cmds {
+		rc := make([]string, len(c))
+		for i, arg := range c {
+			rc[i] = ws.Redact(arg)
+		}
+		res = append(res, rc)
+	}
+	return res
+}
+
+// redactSecrets replaces the values of all fields which look like secrets in place and returns their paths
+func redactSecrets(path string, cfg map[string]interface{}) (redacted []string) {
+	for k, v := range cfg {
+		p := path + "." + k
+		if blazedock.LooksLikeSecret(k) {
+			cfg[k] = blazedock.RedactedValue
+			redacted = append(redacted, p)
+			continue
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			redacted = append(redacted, redactSecrets(p, v)...)
+		case []interface{}:
+			for i, e := range v {
+				s, ok := e.(string)
+				if !ok {
+					continue
+				}
+				// lists of KEY=VALUE pairs, e.g. environment variables
+				if name, _, ok := strings.Cut(s, "="); ok && blazedock.LooksLikeSecret(name) {
+					v[i] = name + "=" + blazedock.RedactedValue
+					redacted = append(redacted, p+"."+name)
+				}
+			}
+		}
+	}
+	return redacted
+}
+
+func init() {
+	describeCmd.AddCommand(describePackageCmd)
+	addFormatFlags(describePackageCmd)
+}
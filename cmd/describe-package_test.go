@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	type Expectation struct {
+		Config   map[string]interface{}
+		Redacted []string
+	}
+	tests := []struct {
+		Name        string
+		Config      map[string]interface{}
+		Expectation Expectation
+	}{
+		{
+			Name:        "no secrets",
+			Config:      map[string]interface{}{"dockerfile": "Dockerfile", "squash": true},
+			Expectation: Expectation{Config: map[string]interface{}{"dockerfile": "Dockerfile", "squash": true}},
+		},
+		{
+			Name:   "top-level field",
+			Config: map[string]interface{}{"dockerfile": "Dockerfile", "apiToken": "s3cr3t"},
+			Expectation: Expectation{
+				Config:   map[string]interface{}{"dockerfile": "Dockerfile", "apiToken": blazedock.RedactedValue},
+				Redacted: []string{"config.apiToken"},
+			},
+		},
+		{
+			Name: "nested field",
+			Config: map[string]interface{}{
+				"buildArgs": map[string]interface{}{"VERSION": "1.0", "NPM_TOKEN": "s3cr3t"},
+			},
+			Expectation: Expectation{
+				Config: map[string]interface{}{
+					"buildArgs": map[string]interface{}{"VERSION": "1.0", "NPM_TOKEN": blazedock.RedactedValue},
+				},
+				Redacted: []string{"config.buildArgs.NPM_TOKEN"},
+			},
+		},
+		{
+			Name: "key value list",
+			Config: map[string]interface{}{
+				"env": []interface{}{"GOOS=linux", "DB_PASSWORD=s3cr3t", 42},
+			},
+			Expectation: Expectation{
+				Config: map[string]interface{}{
+					"env": []interface{}{"GOOS=linux", "DB_PASSWORD=" + blazedock.RedactedValue, 42},
+				},
+				Redacted: []string{"config.env.DB_PASSWORD"},
+			},
+		},
+		{
+			Name:   "secret map is redacted as a whole",
+			Config: map[string]interface{}{"secrets": map[string]interface{}{"a": "b"}},
+			Expectation: Expectation{
+				Config:   map[string]interface{}{"secrets": blazedock.RedactedValue},
+				Redacted: []string{"config.secrets"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			redacted := redactSecrets("config", test.Config)
+			sort.Strings(redacted)
+
+			act := Expectation{Config: test.Config, Redacted: redacted}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("redactSecrets() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
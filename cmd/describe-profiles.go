@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/prettyprint"
+)
+
+// describeProfilesCmd represents the describeProfiles command
+var describeProfilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "Lists the profiles defined in the workspace",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ws, err := blazedock.LoadWorkspaceConfig(workspace)
+		if err != nil {
+			log.Fatal(err)
+		}
+		profiles := ws.Profiles
+
+		type profileDesc struct {
+			Name        string            `json:"name" yaml:"name"`
+			Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+			Flags       map[string]string `json:"flags" yaml:"flags"`
+		}
+
+		desc := make([]profileDesc, 0, len(profiles))
+		for name, p := range profiles {
+			desc = append(desc, profileDesc{Name: name, Description: p.Description, Flags: p.Flags})
+		}
+		sort.Slice(desc, func(i, j int) bool { return desc[i].Name < desc[j].Name })
+
+		w := getWriterFromFlags(cmd)
+		if w.Format == prettyprint.TemplateFormat && w.FormatString == "" {
+			w.FormatString = `{{ range . }}{{ .Name }}:{{"\t"}}{{ .Description }}{{"\n"}}{{ range $k, $v := .Flags }}{{"\t"}}--{{ $k }}={{ $v }}{{"\n"}}{{ end }}{{ end }}`
+		}
+		err = w.Write(desc)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	describeCmd.AddCommand(describeProfilesCmd)
+	addFormatFlags(describeProfilesCmd)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/prettyprint"
+)
+
+// describeVariantsCmd represents the describeVariants command
+var describeVariantsCmd = &cobra.Command{
+	Use:   "variants",
+	Short: "Lists the variants defined in the workspace and what they override",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ws, err := blazedock.LoadWorkspaceConfig(workspace)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		desc := make([]variantDescription, 0, len(ws.Variants))
+		for _, v := range ws.Variants {
+			d := newVariantDescription(v)
+			// we list the config the variant sets rather than the resulting package config,
+			// as only the fields set in the variant override those of a package.
+			d.Config = make(map[blazedock.PackageType]configDescription, len(v.RawConfig))
+			for t, node := range v.RawConfig {
+				var cfg configDescription
+				err := node.Decode(&cfg)
+				if err != nil {
+					log.WithError(err).WithField("variant", v.Name).Fatal("cannot decode variant config")
+				}
+				d.Config[t] = cfg
+			}
+			desc = append(desc, d)
+		}
+
+		w := getWriterFromFlags(cmd)
+		if w.Format == prettyprint.TemplateFormat && w.FormatString == "" {
+			w.FormatString = `{{ range . }}{{ .Name }}:{{"\n"}}` +
+				`{{ range .Sources.Include }}{{"\t"}}+src {{ . }}{{"\n"}}{{ end }}` +
+				`{{ range .Sources.Exclude }}{{"\t"}}-src {{ . }}{{"\n"}}{{ end }}` +
+				`{{ range .ExcludedComponents }}{{"\t"}}-component {{ . }}{{"\n"}}{{ end }}` +
+				`{{ range .Environment }}{{"\t"}}env {{ . }}{{"\n"}}{{ end }}` +
+				`{{ range $k, $v := .DefaultArgs }}{{"\t"}}arg {{ $k }}={{ $v }}{{"\n"}}{{ end }}` +
+				`{{ range $t, $cfg := .Config }}{{ range $k, $v := $cfg }}{{"\t"}}{{ $t }}.{{ $k }}: {{ $v }}{{"\n"}}{{ end }}{{ end }}` +
+				`{{ end }}`
+		}
+		err = w.Write(desc)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	describeCmd.AddCommand(describeVariantsCmd)
+	addFormatFlags(describeVariantsCmd)
+}
@@ -15,9 +15,10 @@ import (
 
 // describeCmd represents the describe command
 var describeCmd = &cobra.Command{
-	Use:   "describe <component|package>",
-	Short: "Describes a single component or package",
-	Args:  cobra.MaximumNArgs(2),
+	Use:               "describe <component|package|pattern>",
+	Short:             "Describes a single component or package, or all packages matching a pattern",
+	Args:              cobra.MaximumNArgs(2),
+	ValidArgsFunction: completePackageNames,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) == 2 {
 			cmdname := args[0]
@@ -41,12 +42,17 @@ var describeCmd = &cobra.Command{
 			return
 		}
 
+		w := getWriterFromFlags(cmd)
+		if len(args) == 1 && blazedock.IsTargetPattern(args[0]) {
+			describePackages(w, getTargetPackages(args))
+			return
+		}
+
 		comp, pkg, _, exists := getTarget(args, false)
 		if !exists {
 			return
 		}
 
-		w := getWriterFromFlags(cmd)
 		if pkg != nil {
 			describePackage(w, pkg)
 			return
@@ -102,6 +108,28 @@ func getTarget(args []string, findScript bool) (comp *blazedock.Component, pkg *
 	return
 }
 
+// getTargetPackages returns the packages a target pattern (see blazedock.IsTargetPattern) matches, or the package
+// named by the target otherwise
+func getTargetPackages(args []string) []*blazedock.Package {
+	if len(args) == 0 || !blazedock.IsTargetPattern(args[0]) {
+		_, pkg, _, _ := getTarget(args, false)
+		if pkg == nil {
+			return nil
+		}
+		return []*blazedock.Package{pkg}
+	}
+
+	workspace, err := getWorkspace()
+	if err != nil {
+		log.Fatal(err)
+	}
+	pkgs, err := workspace.FindPackages(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	return pkgs
+}
+
 func absPackageName(workspace blazedock.Workspace, name string) string {
 	if strings.HasPrefix(name, ".:") {
 		wd, err := os.Getwd()
@@ -153,9 +181,15 @@ func newMetadataDescription(pkg *blazedock.Package) packageMetadataDescription {
 	}
 }
 
+type packageComponentDescription struct {
+	Name   string `json:"name" yaml:"name"`
+	Origin string `json:"origin" yaml:"origin"`
+}
+
 type packageDescription struct {
 	Metadata           packageMetadataDescription   `json:"metadata" yaml:"metadata"`
 	Type               string                       `json:"type" yaml:"type"`
+	Component          packageComponentDescription  `json:"component" yaml:"component"`
 	Manifest           map[string]string            `json:"manifest" yaml:"manifest"`
 	ArgDeps            []string                     `json:"argdeps,omitempty" yaml:"argdeps,omitempty"`
 	Dependencies       []packageMetadataDescription `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
@@ -192,6 +226,7 @@ func newPackageDesription(pkg *blazedock.Package) packageDescription {
 	return packageDescription{
 		Metadata:           newMetadataDescription(pkg),
 		Type:               string(pkg.Type),
+		Component:          packageComponentDescription{Name: pkg.C.Name, Origin: pkg.C.Origin},
 		ArgDeps:            pkg.ArgumentDependencies,
 		Dependencies:       deps,
 		Layout:             layout,
@@ -215,6 +250,7 @@ func newConfigDescription(tpe blazedock.PackageType, c blazedock.PackageConfig)
 		cfg["dockerfile"] = c.Dockerfile
 		cfg["image"] = c.Image
 		cfg["squash"] = c.Squash
+		cfg["platforms"] = c.Platforms
 	case blazedock.GenericPackage:
 		c := c.(blazedock.GenericPkgConfig)
 		cfg["commands"] = c.Commands
@@ -240,6 +276,20 @@ func newConfigDescription(tpe blazedock.PackageType, c blazedock.PackageConfig)
 			"install": c.Commands.Install,
 			"test":    c.Commands.Test,
 		}
+	case blazedock.RustPackage:
+		c := c.(blazedock.RustPkgConfig)
+		cfg["binaries"] = c.Binaries
+		cfg["dontTest"] = c.DontTest
+		cfg["features"] = c.Features
+		cfg["noDefaultFeatures"] = c.NoDefaultFeatures
+		cfg["profile"] = c.Profile
+		cfg["target"] = c.Target
+	case blazedock.PythonPackage:
+		c := c.(blazedock.PythonPkgConfig)
+		cfg["buildCommand"] = c.BuildCommand
+		cfg["packaging"] = c.Packaging
+		cfg["pythonVersion"] = c.PythonVersion
+		cfg["requirements"] = c.Requirements
 	}
 	return cfg
 }
@@ -286,6 +336,25 @@ Sources:
 	}
 }
 
+// describePackages describes all packages matched by a target pattern. Structured formats produce a list of package descriptions.
+func describePackages(out *prettyprint.Writer, pkgs []*blazedock.Package) {
+	if out.Format == prettyprint.TemplateFormat {
+		for _, pkg := range pkgs {
+			describePackage(out, pkg)
+		}
+		return
+	}
+
+	desc := make([]packageDescription, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		desc = append(desc, newPackageDesription(pkg))
+	}
+	err := out.Write(desc)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
 type componentDescription struct {
 	Name      string                       `json:"name" yaml:"name"`
 	Origin    string                       `json:"origin" yaml:"origin"`
@@ -0,0 +1,397 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gookit/color"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache/remote"
+	"github.com/khulnasoft/blazedock/pkg/kms"
+	"github.com/spf13/cobra"
+)
+
+// doctorStatus is the outcome of a doctor check
+type doctorStatus int
+
+const (
+	doctorOK doctorStatus = iota
+	doctorWarn
+	doctorFail
+)
+
+func (s doctorStatus) String() string {
+	switch s {
+	case doctorOK:
+		return color.Green.Sprint("OK  ")
+	case doctorWarn:
+		return color.Yellow.Sprint("WARN")
+	default:
+		return color.Red.Sprint("FAIL")
+	}
+}
+
+// doctorResult is the result of a single doctor check
+type doctorResult struct {
+	Name    string
+	Status  doctorStatus
+	Message string
+	// Hint tells how to fix a warning or failure
+	Hint string
+}
+
+// doctorTools are the toolchains blazedock calls, with a hint how to install them
+var doctorTools = []struct {
+	Name string
+	Hint string
+}{
+	{Name: "go", Hint: "install Go from https://go.dev/dl/"},
+	{Name: "yarn", Hint: "install yarn, e.g. using corepack enable or npm install -g yarn"},
+	{Name: "pnpm", Hint: "install pnpm, e.g. using corepack enable or npm install -g pnpm"},
+	{Name: "docker", Hint: "install Docker from https://docs.docker.com/get-docker/"},
+}
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Checks the environment blazedock runs in for common problems",
+	Long: `Checks the environment blazedock runs in for common problems: whether the workspace can be found and loaded,
+the local cache directory is writable, the remote cache is reachable with the configured credentials, the toolchains
+the workspace needs are installed and the provenance key loads.
+
+Every check reports OK, WARN or FAIL, together with a hint how to fix the problem. Doctor exits with a non-zero
+exit code if any check fails.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		ws, res := doctorWorkspace(workspace, getWorkspace)
+		results := []doctorResult{
+			res,
+			doctorCacheDir(getLocalCacheLocation()),
+			doctorRemoteCache(ctx, cmd),
+		}
+		results = append(results, doctorToolchains(ws, exec.LookPath)...)
+		results = append(results, doctorProvenanceKey(doctorProvenanceKeyPath()))
+
+		if printDoctorResults(os.Stdout, results) {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().Bool("remote-cache-insecure", false, "Skip TLS certificate verification when talking to an S3-compatible or HTTP remote cache")
+}
+
+// printDoctorResults prints the results together with the hints how to fix warnings and failures.
+// It returns true if any check failed.
+func printDoctorResults(out io.Writer, results []doctorResult) (failed bool) {
+	for _, r := range results {
+		fmt.Fprintf(out, "%s  %-16s %s\n", r.Status, r.Name, r.Message)
+		if r.Status != doctorOK && r.Hint != "" {
+			fmt.Fprintf(out, "      %-16s ↳ %s\n", "", r.Hint)
+		}
+		if r.Status == doctorFail {
+			failed = true
+		}
+	}
+	return failed
+}
+
+// doctorWorkspace loads the workspace in path using load. The workspace is nil if it cannot be loaded.
+func doctorWorkspace(path string, load func() (blazedock.Workspace, error)) (*blazedock.Workspace, doctorResult) {
+	res := doctorResult{Name: "workspace"}
+	root, err := filepath.Abs(path)
+	if err != nil {
+		root = path
+	}
+	if _, err := os.Stat(filepath.Join(root, "WORKSPACE.yaml")); err != nil {
+		res.Status = doctorFail
+		res.Message = fmt.Sprintf("no WORKSPACE.yaml found in %s", root)
+		res.Hint = fmt.Sprintf("run blazedock within a workspace, or point --workspace or $%s to its root", EnvvarWorkspaceRoot)
+		return nil, res
+	}
+
+	ws, err := load()
+	if err != nil {
+		res.Status = doctorFail
+		res.Message = fmt.Sprintf("cannot load the workspace in %s: %v", root, err)
+		res.Hint = "fix the WORKSPACE.yaml and BUILD.yaml files - blazedock vet helps to find problems in them"
+		return nil, res
+	}
+	res.Message = fmt.Sprintf("%s with %d packages", ws.Origin, len(ws.Packages))
+	return &ws, res
+}
+
+// doctorCacheDir checks that the local cache directory loc can be written to
+func doctorCacheDir(loc string) doctorResult {
+	res := doctorResult{
+		Name: "local cache",
+		Hint: fmt.Sprintf("set $%s to a directory you can write to", blazedock.EnvvarCacheDir),
+	}
+
+	err := os.MkdirAll(loc, 0755)
+	if err != nil {
+		res.Status = doctorFail
+		res.Message = fmt.Sprintf("cannot create %s: %v", loc, err)
+		return res
+	}
+	f, err := os.CreateTemp(loc, ".blazedock-doctor-*")
+	if err != nil {
+		res.Status = doctorFail
+		res.Message = fmt.Sprintf("%s is not writable: %v", loc, err)
+		return res
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	res.Message = fmt.Sprintf("%s is writable", loc)
+	return res
+}
+
+// doctorRemoteCache checks that the remote cache configured through the environment can be reached
+func doctorRemoteCache(ctx context.Context, cmd *cobra.Command) doctorResult {
+	res := doctorResult{Name: "remote cache"}
+
+	bucket := os.Getenv(EnvvarRemoteCacheBucket)
+	if bucket == "" {
+		res.Message = fmt.Sprintf("not configured - set $%s to share build artifacts", EnvvarRemoteCacheBucket)
+		return res
+	}
+	if os.Getenv(blazedock.EnvvarOffline) == "true" {
+		res.Status = doctorWarn
+		res.Message = fmt.Sprintf("%s is configured, but $%s disables the remote cache", bucket, blazedock.EnvvarOffline)
+		res.Hint = fmt.Sprintf("unset $%s to use the remote cache", blazedock.EnvvarOffline)
+		return res
+	}
+
+	insecure, _ := cmd.Flags().GetBool("remote-cache-insecure")
+	switch storage := os.Getenv(EnvvarRemoteCacheStorage); storage {
+	case "", "GCP":
+		return doctorGSUtilBucket(ctx, res, bucket)
+	case "AWS":
+		return doctorS3Bucket(ctx, res, bucket, insecure)
+	case "HTTP":
+		return doctorHTTPCache(ctx, res, bucket, insecure)
+	default:
+		res.Status = doctorFail
+		res.Message = fmt.Sprintf("unknown storage provider %q", storage)
+		res.Hint = fmt.Sprintf("set $%s to GCP, AWS or HTTP", EnvvarRemoteCacheStorage)
+		return res
+	}
+}
+
+func doctorGSUtilBucket(ctx context.Context, res doctorResult, bucket string) doctorResult {
+	if _, err := exec.LookPath("gsutil"); err != nil {
+		res.Status = doctorFail
+		res.Message = "the GCP remote cache needs gsutil, which is not in the PATH"
+		res.Hint = "install the Google Cloud SDK from https://cloud.google.com/sdk/docs/install and run gcloud auth login"
+		return res
+	}
+
+	url := "gs://" + bucket
+	out, err := exec.CommandContext(ctx, "gsutil", "ls", "-b", url).CombinedOutput()
+	if err != nil {
+		res.Status = doctorFail
+		res.Message = fmt.Sprintf("cannot access %s: %s", url, doctorCommandError(out, err))
+		res.Hint = "check that the bucket exists and gsutil is authenticated (gcloud auth login) with access to it"
+		return res
+	}
+	res.Message = fmt.Sprintf("%s is reachable using gsutil", url)
+	return res
+}
+
+func doctorS3Bucket(ctx context.Context, res doctorResult, bucket string, insecure bool) doctorResult {
+	awsCfg, err := config.LoadDefaultConfig(ctx)
+	if err == nil {
+		_, err = awsCfg.Credentials.Retrieve(ctx)
+	}
+	if err != nil {
+		res.Status = doctorFail
+		res.Message = fmt.Sprintf("the AWS remote cache needs AWS credentials: %v", err)
+		res.Hint = "configure AWS credentials, see https://docs.aws.amazon.com/cli/latest/userguide/cli-configure-files.html"
+		return res
+	}
+	if insecure {
+		awsCfg.HTTPClient = awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+			if tr.TLSClientConfig == nil {
+				tr.TLSClientConfig = &tls.Config{}
+			}
+			//nolint:gosec
+			tr.TLSClientConfig.InsecureSkipVerify = true
+		})
+	}
+
+	endpoint := getRemoteCacheEndpoint()
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	_, err = client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		res.Status = doctorFail
+		res.Message = fmt.Sprintf("cannot access S3 bucket %s: %v", bucket, err)
+		res.Hint = "check that the bucket exists, the AWS region is set and the credentials may read and write the bucket"
+		return res
+	}
+	res.Message = fmt.Sprintf("S3 bucket %s is reachable", bucket)
+	if endpoint != "" {
+		res.Message += " at " + endpoint
+	}
+	return res
+}
+
+func doctorHTTPCache(ctx context.Context, res doctorResult, baseURL string, insecure bool) doctorResult {
+	storage, err := remote.NewHTTPStorage(baseURL, insecure, remote.HTTPConfig{
+		Token:       os.Getenv(EnvvarRemoteCacheToken),
+		MaxAttempts: 1,
+	})
+	if err != nil {
+		res.Status = doctorFail
+		res.Message = err.Error()
+		res.Hint = fmt.Sprintf("set $%s to the base URL of the artifact server", EnvvarRemoteCacheBucket)
+		return res
+	}
+
+	// the object doesn't have to exist - a cache miss proves the server answers
+	_, err = storage.HasObject(ctx, "blazedock-doctor")
+	if err != nil {
+		res.Status = doctorFail
+		res.Message = fmt.Sprintf("cannot access %s: %v", baseURL, err)
+		var authErr *remote.AuthError
+		if errors.As(err, &authErr) {
+			res.Hint = fmt.Sprintf("set $%s to a valid bearer token", EnvvarRemoteCacheToken)
+		} else {
+			res.Hint = "check that the artifact server is running and reachable from this machine"
+		}
+		return res
+	}
+	res.Message = fmt.Sprintf("%s is reachable", baseURL)
+	return res
+}
+
+// doctorToolchains checks that the toolchains can be found using lookPath, usually exec.LookPath.
+// A missing toolchain fails only if the workspace has packages which need it.
+func doctorToolchains(ws *blazedock.Workspace, lookPath func(file string) (string, error)) []doctorResult {
+	users := make(map[string]int)
+	if ws != nil {
+		for _, pkg := range ws.Packages {
+			switch pkg.Type {
+			case blazedock.GoPackage:
+				users["go"]++
+			case blazedock.DockerPackage:
+				users["docker"]++
+			case blazedock.YarnPackage:
+				if cfg, ok := pkg.Config.(blazedock.YarnPkgConfig); ok && cfg.PackageManager == blazedock.PackageManagerPnpm {
+					users["pnpm"]++
+				} else {
+					users["yarn"]++
+				}
+			}
+		}
+	}
+
+	var res []doctorResult
+	for _, tool := range doctorTools {
+		if tool.Name == "pnpm" && users["pnpm"] == 0 {
+			// pnpm is opt-in per package, hence it's only worth mentioning when the workspace uses it
+			continue
+		}
+
+		r := doctorResult{Name: tool.Name, Hint: tool.Hint}
+		path, err := lookPath(tool.Name)
+		switch {
+		case err == nil:
+			r.Message = path
+		case users[tool.Name] > 0:
+			r.Status = doctorFail
+			r.Message = fmt.Sprintf("not in the PATH, but %d packages of the workspace need it", users[tool.Name])
+		case ws == nil:
+			r.Status = doctorWarn
+			r.Message = "not in the PATH - cannot tell whether the workspace needs it"
+		default:
+			r.Status = doctorWarn
+			r.Message = "not in the PATH - no package of the workspace needs it"
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// doctorProvenanceKeyPath returns the provenance key configured through $BLAZEDOCK_PROVENANCE_KEYPATH or the WORKSPACE.yaml
+func doctorProvenanceKeyPath() string {
+	if keyPath := os.Getenv("BLAZEDOCK_PROVENANCE_KEYPATH"); keyPath != "" {
+		return keyPath
+	}
+
+	// we read the config only to find the key: loading the workspace would fail if the key doesn't load
+	ws, err := blazedock.LoadWorkspaceConfig(workspace)
+	if err != nil {
+		return ""
+	}
+	if ws.Provenance.KeyURI != "" {
+		return ws.Provenance.KeyURI
+	}
+	return ws.Provenance.KeyPath
+}
+
+// doctorProvenanceKey checks that the provenance key in keyPath loads
+func doctorProvenanceKey(keyPath string) doctorResult {
+	res := doctorResult{
+		Name: "provenance key",
+		Hint: "point $BLAZEDOCK_PROVENANCE_KEYPATH or provenance.key in the WORKSPACE.yaml to an in-toto private key, or provenance.keyURI to a KMS key",
+	}
+
+	if keyPath == "" {
+		res.Message = "not configured"
+		return res
+	}
+
+	if kms.IsKeyURI(keyPath) {
+		_, err := kms.NewSigner(keyPath)
+		if err != nil {
+			res.Status = doctorFail
+			res.Message = err.Error()
+			return res
+		}
+		res.Message = fmt.Sprintf("%s references a KMS key - the KMS is contacted when signing", keyPath)
+		return res
+	}
+
+	var key in_toto.Key
+	err := key.LoadKeyDefaults(keyPath)
+	if err != nil {
+		res.Status = doctorFail
+		res.Message = fmt.Sprintf("cannot load %s: %v", keyPath, err)
+		return res
+	}
+	res.Message = fmt.Sprintf("%s loads (%s key %s)", keyPath, key.KeyType, key.KeyID)
+	return res
+}
+
+// doctorCommandError returns the output of a failed command, or the error if there was no output
+func doctorCommandError(out []byte, err error) string {
+	if msg := strings.TrimSpace(string(out)); msg != "" {
+		return msg
+	}
+	return err.Error()
+}
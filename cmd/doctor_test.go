@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func TestDoctorWorkspace(t *testing.T) {
+	withWorkspace := t.TempDir()
+	if err := os.WriteFile(filepath.Join(withWorkspace, "WORKSPACE.yaml"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name        string
+		Path        string
+		Load        func() (blazedock.Workspace, error)
+		Expectation doctorStatus
+	}{
+		{
+			Name: "loads",
+			Path: withWorkspace,
+			Load: func() (blazedock.Workspace, error) {
+				return blazedock.Workspace{Origin: withWorkspace}, nil
+			},
+			Expectation: doctorOK,
+		},
+		{
+			Name: "does not load",
+			Path: withWorkspace,
+			Load: func() (blazedock.Workspace, error) {
+				return blazedock.Workspace{}, errors.New("invalid BUILD.yaml")
+			},
+			Expectation: doctorFail,
+		},
+		{
+			Name: "no workspace",
+			Path: t.TempDir(),
+			Load: func() (blazedock.Workspace, error) {
+				t.Error("loaded a workspace without WORKSPACE.yaml")
+				return blazedock.Workspace{}, nil
+			},
+			Expectation: doctorFail,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			ws, res := doctorWorkspace(test.Path, test.Load)
+			if res.Status != test.Expectation {
+				t.Errorf("expected %v, got %v: %s", test.Expectation, res.Status, res.Message)
+			}
+			if (ws != nil) != (test.Expectation == doctorOK) {
+				t.Errorf("unexpected workspace %v for status %v", ws, res.Status)
+			}
+		})
+	}
+}
+
+func TestDoctorCacheDir(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name        string
+		Location    string
+		Expectation doctorStatus
+	}{
+		{Name: "existing", Location: t.TempDir(), Expectation: doctorOK},
+		{Name: "not yet existing", Location: filepath.Join(t.TempDir(), "cache"), Expectation: doctorOK},
+		{Name: "not a directory", Location: filepath.Join(file, "cache"), Expectation: doctorFail},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			res := doctorCacheDir(test.Location)
+			if res.Status != test.Expectation {
+				t.Errorf("expected %v, got %v: %s", test.Expectation, res.Status, res.Message)
+			}
+		})
+	}
+}
+
+func TestDoctorToolchains(t *testing.T) {
+	ws := &blazedock.Workspace{
+		Packages: map[string]*blazedock.Package{
+			"app:lib": {PackageInternal: blazedock.PackageInternal{Name: "lib", Type: blazedock.GoPackage}},
+		},
+	}
+
+	tests := []struct {
+		Name        string
+		Workspace   *blazedock.Workspace
+		Installed   []string
+		Expectation map[string]doctorStatus
+	}{
+		{
+			Name:        "all installed",
+			Workspace:   ws,
+			Installed:   []string{"go", "yarn", "docker"},
+			Expectation: map[string]doctorStatus{"go": doctorOK, "yarn": doctorOK, "docker": doctorOK},
+		},
+		{
+			Name:        "unused toolchains missing",
+			Workspace:   ws,
+			Installed:   []string{"go"},
+			Expectation: map[string]doctorStatus{"go": doctorOK, "yarn": doctorWarn, "docker": doctorWarn},
+		},
+		{
+			Name:        "needed toolchain missing",
+			Workspace:   ws,
+			Installed:   []string{"yarn", "docker"},
+			Expectation: map[string]doctorStatus{"go": doctorFail, "yarn": doctorOK, "docker": doctorOK},
+		},
+		{
+			Name:        "no workspace",
+			Installed:   []string{"docker"},
+			Expectation: map[string]doctorStatus{"go": doctorWarn, "yarn": doctorWarn, "docker": doctorOK},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			lookPath := func(file string) (string, error) {
+				for _, tool := range test.Installed {
+					if tool == file {
+						return "/usr/bin/" + file, nil
+					}
+				}
+				return "", exec.ErrNotFound
+			}
+
+			act := make(map[string]doctorStatus)
+			for _, res := range doctorToolchains(test.Workspace, lookPath) {
+				act[res.Name] = res.Status
+			}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("doctorToolchains() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDoctorProvenanceKey(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "provenance.key")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name        string
+		KeyPath     string
+		Expectation doctorStatus
+	}{
+		{Name: "not configured", Expectation: doctorOK},
+		{Name: "loads", KeyPath: keyPath, Expectation: doctorOK},
+		{Name: "missing", KeyPath: filepath.Join(t.TempDir(), "does-not-exist.key"), Expectation: doctorFail},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			res := doctorProvenanceKey(test.KeyPath)
+			if res.Status != test.Expectation {
+				t.Errorf("expected %v, got %v: %s", test.Expectation, res.Status, res.Message)
+			}
+		})
+	}
+}
+
+func TestPrintDoctorResults(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Results     []doctorResult
+		Expectation bool
+	}{
+		{
+			Name:    "all ok",
+			Results: []doctorResult{{Name: "workspace"}, {Name: "go"}},
+		},
+		{
+			Name:    "warnings only",
+			Results: []doctorResult{{Name: "workspace"}, {Name: "yarn", Status: doctorWarn, Hint: "install yarn"}},
+		},
+		{
+			Name:        "failure",
+			Results:     []doctorResult{{Name: "workspace"}, {Name: "go", Status: doctorFail, Hint: "install Go"}, {Name: "yarn", Status: doctorWarn}},
+			Expectation: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var out bytes.Buffer
+			failed := printDoctorResults(&out, test.Results)
+			if failed != test.Expectation {
+				t.Errorf("expected failed=%v, got %v", test.Expectation, failed)
+			}
+			for _, r := range test.Results {
+				if !bytes.Contains(out.Bytes(), []byte(r.Name)) {
+					t.Errorf("result %s was not printed:\n%s", r.Name, out.String())
+				}
+				if r.Hint != "" && !bytes.Contains(out.Bytes(), []byte(r.Hint)) {
+					t.Errorf("hint of %s was not printed:\n%s", r.Name, out.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// fishCompletionCmd represents the fishCompletion command
+var fishCompletionCmd = &cobra.Command{
+	Use:    "fish-completion",
+	Short:  "Provides fish completion for blazedock. Use with `blazedock fish-completion | source`",
+	Hidden: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return rootCmd.GenFishCompletion(os.Stdout, true)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fishCompletionCmd)
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/spf13/cobra"
 
@@ -26,51 +27,69 @@ var fmtCmd = &cobra.Command{
 			for _, comp := range ws.Components {
 				fns = append(fns, filepath.Join(comp.Origin, "BUILD.yaml"))
 			}
+			sort.Strings(fns)
 		}
 
 		var (
-			inPlace, _ = cmd.Flags().GetBool("in-place")
-			fix, _     = cmd.Flags().GetBool("fix")
+			inPlace, _  = cmd.Flags().GetBool("in-place")
+			fix, _      = cmd.Flags().GetBool("fix")
+			check, _    = cmd.Flags().GetBool("check")
+			unformatted int
 		)
+		if check && inPlace {
+			return fmt.Errorf("--check and --in-place are exclusive")
+		}
 		for _, fn := range fns {
-			err := formatBuildYaml(fn, inPlace, fix)
+			changed, err := formatBuildYaml(fn, inPlace, check, fix)
 			if err != nil {
 				return err
 			}
+			if check && changed {
+				fmt.Println(fn)
+				unformatted++
+			}
+		}
+		if unformatted > 0 {
+			return fmt.Errorf("%d BUILD.yaml files are not formatted - run blazedock fmt -i to fix them", unformatted)
 		}
 
 		return nil
 	},
 }
 
-func formatBuildYaml(fn string, inPlace, fix bool) error {
-	f, err := os.OpenFile(fn, os.O_RDWR, 0644)
+// formatBuildYaml formats a BUILD.yaml file and reports whether formatting changes it. In check mode the file is left as is,
+// in-place mode rewrites the file if it changed. Otherwise the formatted file is printed to stdout.
+func formatBuildYaml(fn string, inPlace, check, fix bool) (changed bool, err error) {
+	fc, err := os.ReadFile(fn)
 	if err != nil {
-		return err
+		return false, err
 	}
-	defer f.Close()
 
-	var out io.Writer = os.Stdout
-	if inPlace {
-		buf := bytes.NewBuffer(nil)
-		out = buf
-		//nolint:errcheck
-		defer func() {
-			f.Seek(0, 0)
-			f.Truncate(0)
-
-			io.Copy(f, buf)
-		}()
-	} else {
-		fmt.Printf("---\n# %s\n", fn)
-	}
-
-	err = blazedock.FormatBUILDyaml(out, f, fix)
+	buf := bytes.NewBuffer(nil)
+	err = blazedock.FormatBUILDyaml(buf, bytes.NewReader(fc), fix)
 	if err != nil {
-		return err
+		return false, err
 	}
+	// empty BUILD.yaml files are ok
+	changed = len(fc) > 0 && !bytes.Equal(buf.Bytes(), fc)
 
-	return nil
+	switch {
+	case check:
+		return changed, nil
+	case inPlace:
+		if !changed {
+			return false, nil
+		}
+		stat, err := os.Stat(fn)
+		if err != nil {
+			return changed, err
+		}
+		return changed, os.WriteFile(fn, buf.Bytes(), stat.Mode().Perm())
+	default:
+		fmt.Printf("---\n# %s\n", fn)
+		_, err = io.Copy(os.Stdout, buf)
+		return changed, err
+	}
 }
 
 func init() {
@@ -78,4 +97,5 @@ func init() {
 
 	fmtCmd.Flags().BoolP("in-place", "i", false, "format file in place rather than printing it to stdout")
 	fmtCmd.Flags().BoolP("fix", "f", false, "fix issues other than formatting (e.g. deprecated package types)")
+	fmtCmd.Flags().Bool("check", false, "list files which are not formatted and exit with a non-zero code if there are any, rather than printing them")
 }
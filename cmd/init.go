@@ -19,12 +19,16 @@ var (
 		blazedock.DockerPackage: dockerfileCandidates,
 		blazedock.GoPackage:     {"go.mod", "go.sum"},
 		blazedock.YarnPackage:   {"package.json", "yarn.lock"},
+		blazedock.RustPackage:   {"Cargo.toml", "Cargo.lock"},
+		blazedock.PythonPackage: {"requirements.txt", "pyproject.toml"},
 	}
 	initPackageGenerator = map[blazedock.PackageType]func(name string) ([]byte, error){
 		blazedock.DockerPackage:  initDockerPackage,
 		blazedock.GoPackage:      initGoPackage,
 		blazedock.YarnPackage:    initYarnPackage,
 		blazedock.GenericPackage: initGenericPackage,
+		blazedock.RustPackage:    initRustPackage,
+		blazedock.PythonPackage:  initPythonPackage,
 	}
 )
 
@@ -33,7 +37,7 @@ var initCmd = &cobra.Command{
 	Use:       "init <name>",
 	Short:     "Initializes a new blazedock package (and component if need be) in the current directory",
 	Args:      cobra.ExactArgs(1),
-	ValidArgs: []string{"go", "yarn", "docker", "generic"},
+	ValidArgs: []string{"go", "yarn", "docker", "generic", "rust", "python"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var tpe blazedock.PackageType
 		if tper, _ := cmd.Flags().GetString("type"); tper != "" {
@@ -160,6 +164,30 @@ config:
 `, name)), nil
 }
 
+func initRustPackage(name string) ([]byte, error) {
+	return []byte(fmt.Sprintf(`name: %s
+type: rust
+srcs:
+  - Cargo.toml
+  - Cargo.lock
+  - "src/**/*.rs"
+config:
+  profile: release
+`, name)), nil
+}
+
+func initPythonPackage(name string) ([]byte, error) {
+	return []byte(fmt.Sprintf(`name: %s
+type: python
+srcs:
+  - requirements.txt
+  - "**/*.py"
+config:
+  packaging: site-packages
+  requirements: requirements.txt
+`, name)), nil
+}
+
 func initGenericPackage(name string) ([]byte, error) {
 	fs, err := os.ReadDir(".")
 	if err != nil {
@@ -21,22 +21,36 @@ var linkCmd = &cobra.Command{
 		}
 		_, pkg, _, _ := getTarget(args, false)
 
+		force, _ := cmd.Flags().GetBool("force")
+		onlyChanged, _ := cmd.Flags().GetBool("only-changed-deps")
+		opts := []linker.LinkOption{linker.WithForce(force), linker.WithOnlyChangedDeps(onlyChanged)}
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			opts = append(opts, linker.WithDryRun(os.Stdout))
+		}
+
 		switch val, _ := cmd.Flags().GetString("go-link"); val {
 		case "auto":
 			if _, ferr := os.Stat(filepath.Join(ws.Origin, "go.work")); ferr == nil {
-				err = linker.LinkGoWorkspace(&ws)
+				err = linker.LinkGoWorkspace(&ws, opts...)
 			} else {
-				err = linker.LinkGoModules(&ws, pkg)
+				err = linker.LinkGoModules(&ws, pkg, opts...)
 			}
 		case "module":
-			err = linker.LinkGoModules(&ws, pkg)
+			err = linker.LinkGoModules(&ws, pkg, opts...)
 		case "workspace":
-			err = linker.LinkGoWorkspace(&ws)
+			err = linker.LinkGoWorkspace(&ws, opts...)
 		}
 		if err != nil {
 			return err
 		}
 
+		if ok, _ := cmd.Flags().GetBool("yarn-workspace-link"); ok {
+			err = linker.LinkYarnWorkspace(&ws, opts...)
+			if err != nil {
+				return err
+			}
+		}
+
 		if ok, _ := cmd.Flags().GetBool("yarn2-link"); ok {
 			err = linker.LinkYarnPackagesWithYarn2(&ws)
 			if err != nil {
@@ -54,5 +68,9 @@ func init() {
 	rootCmd.AddCommand(linkCmd)
 
 	linkCmd.Flags().Bool("yarn2-link", false, "link yarn packages using yarn2 resolutions")
+	linkCmd.Flags().Bool("yarn-workspace-link", false, "link yarn packages by adding them to the workspaces of the package.json in the workspace root")
 	linkCmd.Flags().String("go-link", "auto", "link Go modules or workspace. Valid values are auto, module or workspace")
+	linkCmd.Flags().Bool("dry-run", false, "print a diff of the Go module changes instead of writing them")
+	linkCmd.Flags().Bool("force", false, "override replace directives in go.mod files which were not added by blazedock")
+	linkCmd.Flags().Bool("only-changed-deps", false, "leave go.mod files untouched whose blazedock replace directives are up to date")
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/provutil"
+)
+
+// provenanceAggregateCmd represents the provenance aggregate command
+var provenanceAggregateCmd = &cobra.Command{
+	Use:   "aggregate <package|target pattern>",
+	Short: "Produces a single attestation covering all (previously built) packages of a target",
+	Long: `Produces a single attestation covering all (previously built) packages of a target, e.g. of //... after a
+build of the whole workspace.
+
+The subjects of the attestation are the cached build artifacts of the packages. Its materials are the union of
+the materials of all statements in the packages' attestation bundles. The attestation is signed like the
+provenance of each package is, and written as attestation bundle with a single entry.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pkgs := getTargetPackages(args)
+		if len(pkgs) == 0 {
+			log.Fatal("provenance aggregate needs a package")
+		}
+		_, localCache := getBuildOpts(cmd)
+
+		var stmts []*provutil.Statement
+		for _, pkg := range pkgs {
+			fn, ok := localCache.Location(pkg)
+			if !ok {
+				log.Fatalf("%s is not built", pkg.FullName())
+			}
+			s, err := readProvenanceStatements("", fn, pkg)
+			if err != nil {
+				log.WithError(err).Fatalf("cannot read attestation bundle of %s", pkg.FullName())
+			}
+			stmts = append(stmts, s...)
+		}
+
+		env, err := blazedock.AggregateProvenance(args[0], pkgs, localCache, provutil.UnionMaterials(stmts))
+		if err != nil {
+			log.WithError(err).Fatal("cannot aggregate provenance")
+		}
+
+		var out io.Writer = os.Stdout
+		if fn, _ := cmd.Flags().GetString("output"); fn != "" {
+			f, err := os.Create(fn)
+			if err != nil {
+				log.WithError(err).Fatal("cannot create output file")
+			}
+			defer f.Close()
+			out = f
+		}
+		err = json.NewEncoder(out).Encode(env)
+		if err != nil {
+			log.WithError(err).Fatal("cannot write aggregate attestation")
+		}
+	},
+}
+
+func init() {
+	provenanceAggregateCmd.Flags().String("output", "", "write the attestation bundle to this file rather than stdout")
+
+	provenanceCmd.AddCommand(provenanceAggregateCmd)
+	addBuildFlags(provenanceAggregateCmd)
+}
@@ -2,16 +2,22 @@ package cmd
 
 import (
 	"encoding/base64"
-	"encoding/json"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache/local"
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache/remote"
+	"github.com/khulnasoft/blazedock/pkg/kms"
 	"github.com/khulnasoft/blazedock/pkg/provutil"
+	"github.com/khulnasoft/blazedock/pkg/sigstore"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
 	"sigs.k8s.io/bom/pkg/provenance"
 )
 
@@ -30,11 +36,11 @@ var provenanceAssertCmd = &cobra.Command{
 		if signed, err := cmd.Flags().GetBool("signed"); err != nil {
 			log.Fatal(err)
 		} else if signed {
-			log.Warn("checking signatures is most likely broken and will probably return false results")
-
 			var keyPath string
 			if pkg == nil {
 				keyPath = os.Getenv("BLAZEDOCK_PROVENANCE_KEYPATH")
+			} else if pkg.C.W.Provenance.KeyURI != "" {
+				keyPath = pkg.C.W.Provenance.KeyURI
 			} else {
 				keyPath = pkg.C.W.Provenance.KeyPath
 			}
@@ -42,12 +48,20 @@ var provenanceAssertCmd = &cobra.Command{
 				log.Fatal("no key path specified - use the BLAZEDOCK_PROVENANCE_KEYPATH to specify one")
 			}
 
-			var key in_toto.Key
-			err := key.LoadKeyDefaults(keyPath)
-			if err != nil {
-				log.WithError(err).Fatal("cannot load key from " + pkg.C.W.Provenance.KeyPath)
+			if kms.IsKeyURI(keyPath) {
+				signer, err := kms.NewSigner(keyPath)
+				if err != nil {
+					log.Fatal(err)
+				}
+				assertions = append(assertions, provutil.AssertSignedWithKMS(signer))
+			} else {
+				var key in_toto.Key
+				err := key.LoadKeyDefaults(keyPath)
+				if err != nil {
+					log.WithError(err).Fatal("cannot load key from " + keyPath)
+				}
+				assertions = append(assertions, provutil.AssertSignedWith(key))
 			}
-			assertions = append(assertions, provutil.AssertSignedWith(key))
 		}
 		if do, err := cmd.Flags().GetBool("built-with-blazedock"); err != nil {
 			log.Fatal(err)
@@ -64,9 +78,63 @@ var provenanceAssertCmd = &cobra.Command{
 		} else if do {
 			assertions = append(assertions, provutil.AssertGitMaterialOnly)
 		}
+		if do, err := cmd.Flags().GetBool("require-digests"); err != nil {
+			log.Fatal(err)
+		} else if do {
+			assertions = append(assertions, provutil.AssertSubjectsHaveDigests)
+		}
+
+		if subject, err := cmd.Flags().GetString("signed-by-subject"); err != nil {
+			log.Fatal(err)
+		} else if subject != "" {
+			issuer, _ := cmd.Flags().GetString("signed-by-issuer")
+			if issuer == "" {
+				log.Fatal("--signed-by-subject requires --signed-by-issuer")
+			}
+			fulcioURL, _ := cmd.Flags().GetString("fulcio-url")
+			rekorURL, _ := cmd.Flags().GetString("rekor-url")
+			root := sigstore.NewTrustRoot(fulcioURL)
+			root.Rekor = sigstore.NewRekor(rekorURL)
+			assertions = append(assertions, provutil.AssertSignedByIdentityWithTrustRoot(root, issuer, subject))
+		}
+		if buildArgs, err := cmd.Flags().GetStringArray("with-build-arg"); err != nil {
+			log.Fatal(err)
+		} else {
+			for _, arg := range buildArgs {
+				key, value, ok := strings.Cut(arg, "=")
+				if !ok {
+					log.Fatalf("invalid --with-build-arg %s: expected key=value", arg)
+				}
+				assertions = append(assertions, provutil.AssertBuildArg(key, value))
+			}
+		}
+		if domains, err := cmd.Flags().GetStringArray("material-domain"); err != nil {
+			log.Fatal(err)
+		} else if len(domains) > 0 {
+			assertions = append(assertions, provutil.AssertMaterialsFromDomains(domains))
+		}
+
+		if do, err := cmd.Flags().GetBool("in-rekor"); err != nil {
+			log.Fatal(err)
+		} else if do {
+			rekorURL, _ := cmd.Flags().GetString("rekor-url")
+			assertions = append(assertions, provutil.AssertInRekor(rekorURL))
+		}
+
+		if do, err := cmd.Flags().GetBool("reproducible"); err != nil {
+			log.Fatal(err)
+		} else if do {
+			if pkg == nil {
+				log.Fatal("--reproducible requires a package")
+			}
+			rebuilt, err := rebuildProvenance(cmd, pkg)
+			if err != nil {
+				log.WithError(err).Fatal("cannot rebuild package")
+			}
+			assertions = append(assertions, provutil.AssertReproducible(rebuilt))
+		}
 
 		var failures []provutil.Violation
-		stmt := provenance.NewSLSAStatement()
 		assert := func(env *provenance.Envelope) error {
 			if env.PayloadType != in_toto.PayloadType {
 				log.Warnf("only supporting %s payloads, not %s - skipping", in_toto.PayloadType, env.PayloadType)
@@ -79,7 +147,7 @@ var provenanceAssertCmd = &cobra.Command{
 			if err != nil {
 				return err
 			}
-			err = json.Unmarshal(raw, &stmt)
+			stmt, err := provutil.ParseStatement(raw)
 			if err != nil {
 				return err
 			}
@@ -136,11 +204,89 @@ func getProvenanceTarget(cmd *cobra.Command, args []string) (bundleFN, pkgFN str
 	return
 }
 
+// rebuildProvenance builds pkg from scratch in a temporary build dir and cache, and returns the provenance statement
+// of the rebuild. Dependencies are taken from the regular local cache, so that only pkg itself is rebuilt.
+func rebuildProvenance(cmd *cobra.Command, pkg *blazedock.Package) ([]*provutil.Statement, error) {
+	if !pkg.C.W.Provenance.Enabled {
+		return nil, xerrors.Errorf("provenance is disabled in this workspace")
+	}
+
+	scratch, err := os.MkdirTemp("", "blazedock-rebuild")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(scratch)
+
+	scratchCache, err := local.NewFilesystemCache(filepath.Join(scratch, "cache"))
+	if err != nil {
+		return nil, err
+	}
+	// the build context picks up the build dir from the environment
+	err = os.Setenv(blazedock.EnvvarBuildDir, filepath.Join(scratch, "build"))
+	if err != nil {
+		return nil, err
+	}
+
+	opts, localCache := getBuildOpts(cmd)
+	opts = append(opts,
+		blazedock.WithLocalCache(&rebuildCache{Target: pkg, Scratch: scratchCache, Deps: localCache}),
+		blazedock.WithRemoteCache(remote.NewNoRemoteCache()),
+	)
+	err = blazedock.Build(pkg, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	fn, ok := scratchCache.Location(pkg)
+	if !ok {
+		return nil, xerrors.Errorf("rebuild of %s produced no artifact", pkg.FullName())
+	}
+	stmts, err := readProvenanceStatements("", fn, pkg)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot read provenance of rebuild: %w", err)
+	}
+
+	var res []*provutil.Statement
+	for _, stmt := range stmts {
+		if stmt.EntryPoint == pkg.FullName() {
+			res = append(res, stmt)
+		}
+	}
+	if len(res) == 0 {
+		return nil, xerrors.Errorf("rebuild of %s has no provenance statement for the package itself", pkg.FullName())
+	}
+	return res, nil
+}
+
+// rebuildCache places the build artifact of the target package in a scratch cache and serves all other
+// packages from the regular local cache
+type rebuildCache struct {
+	Target  *blazedock.Package
+	Scratch cache.LocalCache
+	Deps    cache.LocalCache
+}
+
+func (c *rebuildCache) Location(pkg cache.Package) (path string, exists bool) {
+	if pkg.FullName() == c.Target.FullName() {
+		return c.Scratch.Location(pkg)
+	}
+	return c.Deps.Location(pkg)
+}
+
 func init() {
 	provenanceAssertCmd.Flags().Bool("signed", false, "ensure that all entries in the attestation bundle are signed and valid under the given key")
+	provenanceAssertCmd.Flags().String("signed-by-subject", "", "ensure that all entries in the attestation bundle are signed keyless by this identity, e.g. an email address or workflow URI")
+	provenanceAssertCmd.Flags().String("signed-by-issuer", "", "the OIDC issuer of the identity given in --signed-by-subject, e.g. https://token.actions.githubusercontent.com")
+	provenanceAssertCmd.Flags().String("fulcio-url", sigstore.DefaultFulcioURL, "the Fulcio instance which issued the certificates of keyless signatures")
 	provenanceAssertCmd.Flags().Bool("built-with-blazedock", false, "ensure that all entries in the attestation bundle are built by blazedock")
 	provenanceAssertCmd.Flags().String("built-with-blazedock-version", "", "ensure that all entries in the attestation bundle are built by a specific blazedock version")
 	provenanceAssertCmd.Flags().Bool("git-only", false, "ensure that all entries in the attestation bundle are built directly from Git (i.e. only have git material entries)")
+	provenanceAssertCmd.Flags().Bool("require-digests", false, "ensure that all entries in the attestation bundle have at least one subject and every subject has a sha256 digest")
+	provenanceAssertCmd.Flags().Bool("in-rekor", false, "ensure that all entries in the attestation bundle are signed and recorded in the Rekor transparency log")
+	provenanceAssertCmd.Flags().String("rekor-url", provutil.DefaultRekorURL, "the Rekor transparency log used by --in-rekor and to establish when keyless signatures were made")
+	provenanceAssertCmd.Flags().Bool("reproducible", false, "rebuild the package from scratch and ensure the rebuild produces the same subjects as the cached build")
+	provenanceAssertCmd.Flags().StringArray("with-build-arg", nil, "ensure that all entries in the attestation bundle were built with this build argument, given as key=value (can be given multiple times)")
+	provenanceAssertCmd.Flags().StringArray("material-domain", nil, "ensure that all material of the entries in the attestation bundle originates from this host (can be given multiple times)")
 
 	addBuildFlags(provenanceAssertCmd)
 	provenanceCmd.AddCommand(provenanceAssertCmd)
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"io"
+	"os"
+
+	"github.com/in-toto/in-toto-golang/in_toto"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+	"sigs.k8s.io/bom/pkg/provenance"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/prettyprint"
+	"github.com/khulnasoft/blazedock/pkg/provutil"
+)
+
+// provenanceDiffCmd represents the provenance diff command
+var provenanceDiffCmd = &cobra.Command{
+	Use:   "diff <package|file://pathToAFile> <package|file://pathToAFile>",
+	Short: "Compares the provenance of two (previously built) packages or attestation bundles",
+	Long: `Compares the provenance of two (previously built) packages or attestation bundles.
+
+Statements are matched by the package they were produced for. For each package present in both bundles,
+differences in the builder ID, the materials and the invocation parameters are reported.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var bundles [2][]*provutil.Statement
+		for i := range bundles {
+			bundleFN, pkgFN, pkg, err := getProvenanceTarget(cmd, args[i:i+1])
+			if err != nil {
+				log.WithError(err).Fatal("cannot locate bundle")
+			}
+			bundles[i], err = readProvenanceStatements(bundleFN, pkgFN, pkg)
+			if err != nil {
+				log.WithError(err).Fatalf("cannot read attestation bundle of %s", args[i])
+			}
+		}
+
+		diff := provutil.DiffBundles(bundles[0], bundles[1])
+
+		w := getWriterFromFlags(cmd)
+		if w.Format == prettyprint.TemplateFormat && w.FormatString == "" {
+			if diff.Empty() {
+				log.Info("no differences")
+				return
+			}
+			w.FormatString = `{{- range .Added }}+ {{ . }}{{"\n"}}{{ end -}}
+{{- range .Removed }}- {{ . }}{{"\n"}}{{ end -}}
+{{- range .Changed }}~ {{ .EntryPoint }}{{"\n"}}
+{{- if .BuilderID }}  builder{{"\t"}}{{ .BuilderID.Old }} -> {{ .BuilderID.New }}{{"\n"}}{{ end -}}
+{{- range .Materials }}  material {{ .Kind }}{{"\t"}}{{ .URI }}{{ if eq .Kind "digest-changed" }} ({{ range $k, $v := .Old }}{{ $k }}:{{ $v }}{{ end }} -> {{ range $k, $v := .New }}{{ $k }}:{{ $v }}{{ end }}){{ end }}{{"\n"}}{{ end -}}
+{{- range .Parameters }}  parameter {{ .Name }}{{"\t"}}{{ .Old }} -> {{ .New }}{{"\n"}}{{ end -}}
+{{ end }}`
+		}
+		err := w.Write(diff)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// readProvenanceStatements decodes all in-toto statements of an attestation bundle, which is either read from
+// bundleFN or from the cached archive pkgFN of pkg
+func readProvenanceStatements(bundleFN, pkgFN string, pkg *blazedock.Package) ([]*provutil.Statement, error) {
+	var res []*provutil.Statement
+	collect := func(env *provenance.Envelope) error {
+		if env.PayloadType != in_toto.PayloadType {
+			log.Warnf("only supporting %s payloads, not %s - skipping", in_toto.PayloadType, env.PayloadType)
+			return nil
+		}
+		raw, err := base64.StdEncoding.DecodeString(env.Payload)
+		if err != nil {
+			return err
+		}
+		stmt, err := provutil.ParseStatement(raw)
+		if err != nil {
+			return xerrors.Errorf("cannot parse statement: %w", err)
+		}
+		res = append(res, stmt)
+		return nil
+	}
+
+	if pkg == nil {
+		f, err := os.Open(bundleFN)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return res, provutil.DecodeBundle(f, collect)
+	}
+
+	err := blazedock.AccessAttestationBundleInCachedArchive(pkgFN, func(bundle io.Reader) error {
+		return provutil.DecodeBundle(bundle, collect)
+	})
+	return res, err
+}
+
+func init() {
+	provenanceCmd.AddCommand(provenanceDiffCmd)
+	addFormatFlags(provenanceDiffCmd)
+	addBuildFlags(provenanceDiffCmd)
+}
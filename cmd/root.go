@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"runtime/trace"
 	"strings"
+	"time"
 
 	"github.com/gookit/color"
 	log "github.com/sirupsen/logrus"
@@ -24,14 +27,41 @@ const (
 
 	// EnvvarRemoteCacheStorage configures a Remote Storage Provider. Default is GCP
 	EnvvarRemoteCacheStorage = "BLAZEDOCK_REMOTE_CACHE_STORAGE"
+
+	// EnvvarRemoteCacheEndpoint configures a custom endpoint for S3-compatible remote storage
+	EnvvarRemoteCacheEndpoint = "BLAZEDOCK_REMOTE_CACHE_ENDPOINT"
+
+	// EnvvarRemoteCacheToken configures the bearer token sent to the HTTP remote cache
+	EnvvarRemoteCacheToken = "BLAZEDOCK_REMOTE_CACHE_TOKEN"
+
+	// EnvvarRemoteCacheRetries configures how often requests to the HTTP remote cache are attempted
+	EnvvarRemoteCacheRetries = "BLAZEDOCK_REMOTE_CACHE_RETRIES"
+
+	// EnvvarRemoteCacheBackoff configures how long to wait before retrying a request to the HTTP remote cache
+	EnvvarRemoteCacheBackoff = "BLAZEDOCK_REMOTE_CACHE_BACKOFF"
+
+	// EnvvarRemoteCachePrefix configures a path all objects in the remote cache are placed under
+	EnvvarRemoteCachePrefix = "BLAZEDOCK_REMOTE_CACHE_PREFIX"
+
+	// EnvvarRemoteCacheReadonly makes blazedock download from the remote cache but never write to it
+	EnvvarRemoteCacheReadonly = "BLAZEDOCK_REMOTE_CACHE_READONLY"
+
+	// EnvvarBuildTrace names a file the package builds are written to in the Chrome Trace Event format
+	EnvvarBuildTrace = "BLAZEDOCK_BUILD_TRACE"
+
+	// EnvvarLogFormat selects the log format unless one is set on the command line
+	EnvvarLogFormat = "BLAZEDOCK_LOG_FORMAT"
+
+	// EnvvarDefaultCacheLevel configures the cache level of builds unless one is set on the command line
+	EnvvarDefaultCacheLevel = "BLAZEDOCK_DEFAULT_CACHE_LEVEL"
 )
 
 const (
 	bashCompletionFunc = `__blazedock_parse_get()
 {
     local blazedock_output out
-    if blazedock_output=$(blazedock collect 2>/dev/null); then
-        out=($(echo "${blazedock_output}" | awk '{print $1}'))
+    if blazedock_output=$(blazedock __complete-packages 2>/dev/null); then
+        out=(${blazedock_output})
         COMPREPLY=( $( compgen -W "${out[*]}" -- "$cur" ) )
     fi
 }
@@ -57,11 +87,22 @@ __blazedock_custom_func() {
 `
 )
 
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
 var (
-	workspace string
-	buildArgs []string
-	verbose   bool
-	variant   string
+	workspace        string
+	buildArgs        []string
+	buildArgsFiles   []string
+	allowUnknownArgs bool
+	verbose          bool
+	logFormat        string
+	variants         []string
+
+	// redactionHook keeps the values of secret build arguments out of all log output
+	redactionHook = &blazedock.RedactionHook{}
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -80,23 +121,44 @@ var rootCmd = &cobra.Command{
 Blazedock is configured exclusively through the WORKSPACE/BUILD files and environment variables. The following environment
 variables have an effect on blazedock:
        <light_blue>BLAZEDOCK_WORKSPACE_ROOT</>  Contains the path where to look for a WORKSPACE file. Can also be set using --workspace.
- <light_blue>BLAZEDOCK_REMOTE_CACHE_STORAGE</>  Defines the remote caching storage provider. Valid values are "GCP" and "AWS". Defaults to "GCP".
+ <light_blue>BLAZEDOCK_REMOTE_CACHE_STORAGE</>  Defines the remote caching storage provider. Valid values are "GCP", "AWS" and "HTTP". Defaults to "GCP".
   <light_blue>BLAZEDOCK_REMOTE_CACHE_BUCKET</>  Enables remote caching using GCP or S3 buckets. Required credentials depend on the storage provider:
                              - GCP: blazedock expects "gsutil" in the path configured and authenticated so that it can work with the bucket.
                              - AWS: blazedock expects that AWS credentials have been provided and with read/write access to the S3 bucket.
                                For details on configuring AWS credentials see https://docs.aws.amazon.com/cli/latest/userguide/cli-configure-files.html
+                             - HTTP: the bucket is the base URL of an artifact server supporting GET, HEAD and PUT requests.
+<light_blue>BLAZEDOCK_REMOTE_CACHE_ENDPOINT</>  Points the "AWS" remote cache to an S3-compatible service (e.g. MinIO) using path-style addressing.
+                              Overrides remoteCache.endpoint in the WORKSPACE.yaml.
+   <light_blue>BLAZEDOCK_REMOTE_CACHE_TOKEN</>  Bearer token sent to the "HTTP" remote cache.
+ <light_blue>BLAZEDOCK_REMOTE_CACHE_RETRIES</>  Number of attempts made for requests to the "HTTP" remote cache which fail due to network or server errors. Defaults to 3.
+ <light_blue>BLAZEDOCK_REMOTE_CACHE_BACKOFF</>  Time to wait before retrying a request to the "HTTP" remote cache, doubled for every further retry. Defaults to 200ms.
+              <light_blue>BLAZEDOCK_OFFLINE</>  Set to "true" to never touch the remote cache. Same as --offline.
             <light_blue>BLAZEDOCK_CACHE_DIR</>  Location of the local build cache. The directory does not have to exist yet.
+    <light_blue>BLAZEDOCK_CACHE_COMPRESSION</>  Compression of build artifacts: "gzip", "zstd" or "none". Defaults to "gzip".
             <light_blue>BLAZEDOCK_BUILD_DIR</>  Working location of blazedock (i.e. where the actual builds happen). This location will see heavy I/O
                               which makes it advisable to place this on a fast SSD or in RAM.
            <light_blue>BLAZEDOCK_YARN_MUTEX</>  Configures the mutex flag blazedock will pass to yarn. Defaults to "network".
                               See https://yarnpkg.com/lang/en/docs/cli/#toc-concurrency-and-mutex for possible values.
-  <light_blue>BLAZEDOCK_DEFAULT_CACHE_LEVEL</>  Sets the default cache level for builds. Defaults to "remote".
+       <light_blue>BLAZEDOCK_PNPM_STORE_DIR</>  Configures the store directory blazedock will pass to pnpm. Defaults to a pnpm-store directory in the build dir.
+  <light_blue>BLAZEDOCK_DEFAULT_CACHE_LEVEL</>  Sets the default cache level for builds. Overrides defaultCacheLevel in the WORKSPACE.yaml,
+                              --cache-level and --cache take precedence. Defaults to "remote".
          <light_blue>BLAZEDOCK_EXPERIMENTAL</>  Enables experimental blazedock features and commands.
+          <light_blue>BLAZEDOCK_BUILD_TRACE</>  Writes all package builds and cache hits as Chrome Trace Event JSON to this file.
+                              Open it in chrome://tracing or https://ui.perfetto.dev.
+           <light_blue>BLAZEDOCK_LOG_FORMAT</>  Sets the log format: "text" or "json". Same as --log-format.
 `),
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		if verbose {
 			log.SetLevel(log.DebugLevel)
 		}
+		switch logFormat {
+		case logFormatText:
+			// logrus logs text by default
+		case logFormatJSON:
+			log.SetFormatter(&log.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+		default:
+			log.Fatalf("unknown log format %q: valid formats are %s and %s", logFormat, logFormatText, logFormatJSON)
+		}
 	},
 	BashCompletionFunction: bashCompletionFunc,
 }
@@ -143,9 +205,19 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVarP(&workspace, "workspace", "w", workspaceRoot, "Workspace root")
 	rootCmd.PersistentFlags().StringArrayVarP(&buildArgs, "build-arg", "D", []string{}, "pass arguments to BUILD files")
-	rootCmd.PersistentFlags().StringVar(&variant, "variant", "", "selects a package variant")
+	rootCmd.PersistentFlags().StringArrayVar(&buildArgsFiles, "build-args-file", []string{}, "read build arguments from a file of key=value lines (can be used multiple times, --build-arg takes precedence)")
+	rootCmd.PersistentFlags().BoolVar(&allowUnknownArgs, "allow-unknown-args", false, "accept build arguments the WORKSPACE.yaml does not declare")
+	rootCmd.PersistentFlags().StringArrayVar(&variants, "variant", []string{}, "selects a package variant (can be used multiple times to combine variants)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enables verbose logging")
+	defaultLogFormat := os.Getenv(EnvvarLogFormat)
+	if defaultLogFormat == "" {
+		defaultLogFormat = logFormatText
+	}
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", defaultLogFormat, "log format: text or json (json also reports build progress as newline-delimited JSON events)")
+	rootCmd.PersistentFlags().String("cache-level", "", "overrides the cache level of builds for this invocation: none, local or remote (takes precedence over --cache and $BLAZEDOCK_DEFAULT_CACHE_LEVEL)")
 	rootCmd.PersistentFlags().Bool("dut", false, "used for testing only - doesn't actually do anything")
+
+	log.AddHook(redactionHook)
 }
 
 func getWorkspace() (blazedock.Workspace, error) {
@@ -154,15 +226,33 @@ func getWorkspace() (blazedock.Workspace, error) {
 		return blazedock.Workspace{}, err
 	}
 
-	return blazedock.FindWorkspace(workspace, args, variant, os.Getenv("BLAZEDOCK_PROVENANCE_KEYPATH"))
+	ws, err := blazedock.FindWorkspace(workspace, args, "", os.Getenv("BLAZEDOCK_PROVENANCE_KEYPATH"),
+		blazedock.WithVariants(variants...),
+		blazedock.WithAllowUnknownArguments(allowUnknownArgs),
+	)
+	var unknownArgs *blazedock.UnknownArgumentsError
+	if errors.As(err, &unknownArgs) {
+		return ws, fmt.Errorf("%w (use --allow-unknown-args to pass them anyway)", err)
+	}
+	if err != nil {
+		return ws, err
+	}
+	redactionHook.SetWorkspace(&ws)
+	return ws, nil
 }
 
 func getBuildArgs() (blazedock.Arguments, error) {
-	if len(buildArgs) == 0 {
+	if len(buildArgs) == 0 && len(buildArgsFiles) == 0 {
 		return nil, nil
 	}
 
 	res := make(blazedock.Arguments)
+	for _, fn := range buildArgsFiles {
+		err := readBuildArgsFile(fn, res)
+		if err != nil {
+			return nil, err
+		}
+	}
 	for _, arg := range buildArgs {
 		segs := strings.Split(arg, "=")
 		if len(segs) < 2 {
@@ -173,6 +263,33 @@ func getBuildArgs() (blazedock.Arguments, error) {
 	return res, nil
 }
 
+// readBuildArgsFile adds the key=value lines of a build args file to args. Blank lines and lines starting with # are ignored.
+func readBuildArgsFile(fn string, args blazedock.Arguments) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return xerrors.Errorf("cannot read build args file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for ln := 1; scanner.Scan(); ln++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return xerrors.Errorf("%s:%d: invalid build argument (format is key=value): %s", fn, ln, line)
+		}
+		args[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return xerrors.Errorf("cannot read build args file %s: %w", fn, err)
+	}
+	return nil
+}
+
 func addExperimentalCommand(parent, child *cobra.Command) {
 	if os.Getenv("BLAZEDOCK_EXPERIMENTAL") != "true" {
 		return
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func TestReadBuildArgsFile(t *testing.T) {
+	type Expectation struct {
+		Args  blazedock.Arguments
+		Error string
+	}
+	tests := []struct {
+		Name        string
+		Content     string
+		Args        blazedock.Arguments
+		Expectation Expectation
+	}{
+		{
+			Name:        "key value pairs",
+			Content:     "VERSION=1.0\nGOOS=linux\nLDFLAGS=-X main.a=b\n",
+			Expectation: Expectation{Args: blazedock.Arguments{"VERSION": "1.0", "GOOS": "linux", "LDFLAGS": "-X main.a=b"}},
+		},
+		{
+			Name:        "comments and blank lines",
+			Content:     "# release settings\n\nVERSION=1.0\n  # indented comment\n   \n",
+			Expectation: Expectation{Args: blazedock.Arguments{"VERSION": "1.0"}},
+		},
+		{
+			Name:        "empty value",
+			Content:     "VERSION=\n",
+			Expectation: Expectation{Args: blazedock.Arguments{"VERSION": ""}},
+		},
+		{
+			Name:        "duplicates",
+			Content:     "VERSION=1.0\nVERSION=2.0\n",
+			Expectation: Expectation{Args: blazedock.Arguments{"VERSION": "2.0"}},
+		},
+		{
+			Name:        "overrides existing arguments",
+			Content:     "VERSION=2.0\n",
+			Args:        blazedock.Arguments{"VERSION": "1.0", "GOOS": "linux"},
+			Expectation: Expectation{Args: blazedock.Arguments{"VERSION": "2.0", "GOOS": "linux"}},
+		},
+		{
+			Name:        "missing separator",
+			Content:     "VERSION=1.0\nGOOS\n",
+			Expectation: Expectation{Args: blazedock.Arguments{"VERSION": "1.0"}, Error: "build.args:2: invalid build argument (format is key=value): GOOS"},
+		},
+		{
+			Name:        "missing key",
+			Content:     "=1.0\n",
+			Expectation: Expectation{Args: blazedock.Arguments{}, Error: "build.args:1: invalid build argument (format is key=value): =1.0"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), "build.args")
+			if err := os.WriteFile(fn, []byte(test.Content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			args := test.Args
+			if args == nil {
+				args = blazedock.Arguments{}
+			}
+			var act Expectation
+			if err := readBuildArgsFile(fn, args); err != nil {
+				act.Error = strings.TrimPrefix(err.Error(), filepath.Dir(fn)+string(filepath.Separator))
+			}
+			act.Args = args
+
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("readBuildArgsFile() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		err := readBuildArgsFile(filepath.Join(t.TempDir(), "does-not-exist"), blazedock.Arguments{})
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected a not-exist error, got %v", err)
+		}
+	})
+}
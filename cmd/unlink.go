@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/linker"
+)
+
+// unlinkCmd represents the unlink command
+var unlinkCmd = &cobra.Command{
+	Use:   "unlink [package]",
+	Short: "Removes the links produced by blazedock link",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ws, err := getWorkspace()
+		if err != nil {
+			return err
+		}
+
+		var pkg *blazedock.Package
+		if len(args) > 0 {
+			var exists bool
+			pkg, exists = ws.Packages[absPackageName(ws, args[0])]
+			if !exists {
+				return xerrors.Errorf("package \"%s\" does not exist", args[0])
+			}
+		}
+
+		var opts []linker.LinkOption
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			opts = append(opts, linker.WithDryRun(os.Stdout))
+		}
+
+		err = linker.UnlinkGoModules(&ws, pkg, opts...)
+		if err != nil {
+			return err
+		}
+
+		// yarn workspaces are linked for the whole workspace, never for a single package
+		if _, ferr := os.Stat(filepath.Join(ws.Origin, "package.json")); ferr == nil && pkg == nil {
+			return linker.UnlinkYarnWorkspace(&ws, opts...)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(unlinkCmd)
+
+	unlinkCmd.Flags().Bool("dry-run", false, "print a diff of the changes instead of writing them")
+}
@@ -6,6 +6,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
 	"github.com/khulnasoft/blazedock/pkg/prettyprint"
 	"github.com/khulnasoft/blazedock/pkg/vet"
 )
@@ -15,22 +16,23 @@ var vetCmd = &cobra.Command{
 	Use:   "vet [ls]",
 	Short: "Validates the blazedock workspace",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		ws, err := getWorkspace()
+		if err != nil {
+			return err
+		}
+		err = vet.LoadExternalChecks(ws)
+		if err != nil {
+			return err
+		}
+
 		w := getWriterFromFlags(cmd)
 		if len(args) > 0 && args[0] == "ls" {
 			if w.FormatString == "" && w.Format == prettyprint.TemplateFormat {
 				w.FormatString = `{{ range . -}}
-{{ .Name }}{{"\t"}}{{ .Description }}
+{{ .Info.Name }}{{"\t"}}{{ .Info.Description }}
 {{ end }}`
 			}
-			err := w.Write(vet.Checks())
-			if err != nil {
-				return err
-			}
-		}
-
-		ws, err := getWorkspace()
-		if err != nil {
-			return err
+			return w.Write(vet.Checks())
 		}
 
 		var opts []vet.RunOpt
@@ -40,7 +42,17 @@ var vetCmd = &cobra.Command{
 		if pkgs, _ := cmd.Flags().GetStringArray("packages"); len(pkgs) > 0 {
 			idx := make(vet.StringSet)
 			for _, p := range pkgs {
-				idx[p] = struct{}{}
+				if !blazedock.IsTargetPattern(p) {
+					idx[p] = struct{}{}
+					continue
+				}
+				matches, err := ws.FindPackages(p)
+				if err != nil {
+					return err
+				}
+				for _, m := range matches {
+					idx[m.FullName()] = struct{}{}
+				}
 			}
 			opts = append(opts, vet.OnPackages(idx))
 		}
@@ -73,11 +85,15 @@ var vetCmd = &cobra.Command{
 
 		if w.FormatString == "" && w.Format == prettyprint.TemplateFormat {
 			w.FormatString = `{{ range . }}
-{{"\033"}}[90m{{ if .Package -}}📦{{"\t"}}{{ .Package.FullName }}{{ else if .Component }}🗃️{{"\t"}}{{ .Component.Name }}{{ end }}
+{{"\033"}}[90m{{ if .Package -}}📦{{"\t"}}{{ .Package.FullName }}{{ else if .Component }}🗃️{{"\t"}}{{ .Component.Name }}{{ else }}🏠{{"\t"}}workspace{{ end }}
 ✔️ {{ .Check }}{{"\033"}}[0m
 {{ if .Error -}}❌{{ else }}⚠️{{ end -}}{{"\t"}}{{ .Description }}
 {{ end }}`
 		}
+		if findings == nil {
+			// make sure JSON consumers see an empty list rather than null
+			findings = []vet.Finding{}
+		}
 		err = w.Write(findings)
 		if err != nil {
 			return err
@@ -97,7 +113,7 @@ func init() {
 	rootCmd.AddCommand(vetCmd)
 
 	vetCmd.Flags().StringArray("checks", nil, "run these checks only")
-	vetCmd.Flags().StringArray("packages", nil, "run checks on these packages only")
+	vetCmd.Flags().StringArray("packages", nil, "run checks on these packages only. Accepts patterns like components/api/... or some-component:*")
 	vetCmd.Flags().StringArray("components", nil, "run checks on these components only")
 	vetCmd.Flags().Bool("ignore-warnings", false, "ignores all warnings")
 	addFormatFlags(vetCmd)
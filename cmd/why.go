@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+// whyCmd represents the why command
+var whyCmd = &cobra.Command{
+	Use:   "why <package> <dependency>",
+	Short: "Explains why a package depends on another package",
+	Long: `Explains why a package depends on another package by printing the shortest
+chain of dependencies leading from the first to the second package.
+
+With --all-paths every distinct chain up to --max-depth dependencies long is printed, shortest first.`,
+	Args: cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) >= 2 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completePackageNames(cmd, nil, toComplete)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ws, err := getWorkspace()
+		if err != nil {
+			return err
+		}
+
+		var pkgs [2]*blazedock.Package
+		for i, arg := range args {
+			name := absPackageName(ws, arg)
+			pkg, ok := ws.Packages[name]
+			if !ok {
+				return fmt.Errorf("package \"%s\" does not exist", name)
+			}
+			pkgs[i] = pkg
+		}
+		from, to := pkgs[0], pkgs[1]
+
+		var paths [][]*blazedock.Package
+		if all, _ := cmd.Flags().GetBool("all-paths"); all {
+			maxDepth, _ := cmd.Flags().GetInt("max-depth")
+			if maxDepth < 1 {
+				return fmt.Errorf("--max-depth must be at least 1")
+			}
+			paths = from.DependencyPaths(to, maxDepth)
+			if len(paths) == 0 && from.DependencyPath(to) != nil {
+				fmt.Printf("%s does not depend on %s within %d dependencies\n", from.FullName(), to.FullName(), maxDepth)
+				return nil
+			}
+		} else if path := from.DependencyPath(to); path != nil {
+			paths = [][]*blazedock.Package{path}
+		}
+
+		if len(paths) == 0 {
+			fmt.Printf("%s does not depend on %s\n", from.FullName(), to.FullName())
+			return nil
+		}
+		for _, path := range paths {
+			names := make([]string, len(path))
+			for i, p := range path {
+				names[i] = p.FullName()
+			}
+			fmt.Println(strings.Join(names, " -> "))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(whyCmd)
+	whyCmd.Flags().Bool("all-paths", false, "Print every distinct dependency path instead of the shortest one")
+	whyCmd.Flags().Int("max-depth", 10, "Maximum number of dependencies on a path printed by --all-paths")
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// zshCompletionCmd represents the zshCompletion command
+var zshCompletionCmd = &cobra.Command{
+	Use:    "zsh-completion",
+	Short:  "Provides zsh completion for blazedock. Use with `source <(blazedock zsh-completion)`",
+	Hidden: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return rootCmd.GenZshCompletion(os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(zshCompletionCmd)
+}
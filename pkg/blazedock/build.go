@@ -2,7 +2,6 @@ package blazedock
 
 import (
 	"archive/tar"
-	"compress/gzip"
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
@@ -14,11 +13,17 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"runtime/trace"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/in-toto/in-toto-golang/in_toto"
@@ -43,6 +48,74 @@ func (p PkgNotBuiltErr) Error() string {
 	return fmt.Sprintf("package \"%s\" is not built", p.Package.FullName())
 }
 
+// PkgSkippedErr is used when a package was not built because a package it depends on failed to build, or,
+// when building fail-fast, because another package failed already
+type PkgSkippedErr struct {
+	Package *Package
+	// FailedDependencies are the names of the (transitive) dependencies which failed to build
+	FailedDependencies []string
+}
+
+func (p PkgSkippedErr) Error() string {
+	if len(p.FailedDependencies) == 0 {
+		return fmt.Sprintf("package \"%s\" was skipped because another package failed to build", p.Package.FullName())
+	}
+	return fmt.Sprintf("package \"%s\" was skipped because its dependencies failed to build: %s", p.Package.FullName(), strings.Join(p.FailedDependencies, ", "))
+}
+
+// PkgTimeoutErr is used when the build commands of a package did not finish within the package's timeout
+type PkgTimeoutErr struct {
+	Package *Package
+	Timeout time.Duration
+	// Err is the error of the build command which was cancelled
+	Err error
+}
+
+func (p PkgTimeoutErr) Error() string {
+	return fmt.Sprintf("package \"%s\" timed out: its build commands did not finish within %s", p.Package.FullName(), p.Timeout)
+}
+
+func (p PkgTimeoutErr) Unwrap() error {
+	return p.Err
+}
+
+// BuildFailedErr is returned by Build when packages failed to build
+type BuildFailedErr struct {
+	// Failed maps the names of the packages which failed to build to their error
+	Failed map[string]error
+	// Skipped maps the names of the packages which were not built to the reason why
+	Skipped map[string]PkgSkippedErr
+}
+
+func (e *BuildFailedErr) Error() string {
+	var (
+		failed  = make([]string, 0, len(e.Failed))
+		skipped = make([]string, 0, len(e.Skipped))
+	)
+	for name := range e.Failed {
+		failed = append(failed, name)
+	}
+	for name := range e.Skipped {
+		skipped = append(skipped, name)
+	}
+	sort.Strings(failed)
+	sort.Strings(skipped)
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "build failed: %d packages failed, %d packages skipped", len(failed), len(skipped))
+	for _, name := range failed {
+		fmt.Fprintf(&msg, "\n\tfailed:  %s", name)
+	}
+	for _, name := range skipped {
+		if deps := e.Skipped[name].FailedDependencies; len(deps) > 0 {
+			fmt.Fprintf(&msg, "\n\tskipped: %s (%s failed)", name, strings.Join(deps, ", "))
+		} else {
+			fmt.Fprintf(&msg, "\n\tskipped: %s", name)
+		}
+	}
+	return msg.String()
+}
+
 // PackageBuildStatus denotes the status of a package during build
 type PackageBuildStatus string
 
@@ -68,9 +141,18 @@ type buildContext struct {
 	mu                 sync.Mutex
 	newlyBuiltPackages map[string]*Package
 
-	pkgLockCond *sync.Cond
-	pkgLocks    map[string]struct{}
-	buildLimit  *semaphore.Weighted
+	pkgLockCond  *sync.Cond
+	pkgLocks     map[string]struct{}
+	pkgBuildErrs map[string]error
+	buildLimit   *semaphore.Weighted
+
+	timing *TimingReport
+	// testLogs records the output of the test phase of the packages, s.t. it can be stored in the test cache
+	testLogs *testLogRecorder
+	// retest are the packages whose tests must run, although they may be in the local cache
+	retest map[*Package]struct{}
+	// traceCtx carries the runtime/trace task of the build. Package builds are recorded as sub-tasks.
+	traceCtx context.Context
 }
 
 const (
@@ -80,11 +162,31 @@ const (
 	// EnvvarBuildDir names the environment variable we take the build dir location from
 	EnvvarBuildDir = "BLAZEDOCK_BUILD_DIR"
 
+	// EnvvarOffline names the environment variable which, when set to true, makes blazedock build without
+	// touching the remote cache
+	EnvvarOffline = "BLAZEDOCK_OFFLINE"
+
+	// EnvvarKeepFailed names the environment variable which, when set to true, makes blazedock keep the build
+	// directories of packages which failed to build
+	EnvvarKeepFailed = "BLAZEDOCK_KEEP_FAILED"
+
+	// EnvvarCacheCompression names the environment variable we take the compression of build artifacts from.
+	// Valid values are gzip, zstd and none. Defaults to gzip.
+	EnvvarCacheCompression = "BLAZEDOCK_CACHE_COMPRESSION"
+
 	// EnvvarYarnMutex configures the mutex flag blazedock will pass to yarn.
 	// See https://yarnpkg.com/lang/en/docs/cli/#toc-concurrency-and-mutex for possible values.
 	// Defaults to "network".
 	EnvvarYarnMutex = "BLAZEDOCK_YARN_MUTEX"
 
+	// EnvvarPnpmStoreDir configures the store directory blazedock will pass to pnpm.
+	// Defaults to a pnpm-store directory in the build dir.
+	EnvvarPnpmStoreDir = "BLAZEDOCK_PNPM_STORE_DIR"
+
+	// EnvvarCacheSalt names the environment variable whose value is mixed into the version of every package.
+	// Changing it gives all packages new versions, and hence starts an empty cache namespace.
+	EnvvarCacheSalt = "BLAZEDOCK_CACHE_SALT"
+
 	// dockerImageNamesFiles is the name of the file store in poushed Docker build artifacts
 	// which contains the names of the Docker images we just pushed
 	dockerImageNamesFiles = "imgnames.txt"
@@ -98,9 +200,11 @@ const (
 // Increment this value if you change any of the build procedures.
 var buildProcessVersions = map[PackageType]int{
 	YarnPackage:    7,
-	GoPackage:      2,
+	GoPackage:      3,
 	DockerPackage:  3,
 	GenericPackage: 1,
+	RustPackage:    1,
+	PythonPackage:  1,
 }
 
 func newBuildContext(options buildOptions) (ctx *buildContext, err error) {
@@ -117,6 +221,10 @@ func newBuildContext(options buildOptions) (ctx *buildContext, err error) {
 	if err := os.MkdirAll(buildDir, 0755); err != nil {
 		log.WithError(err).Fatal("failed to create build directory")
 	}
+	buildDir, err = normalizeBuildDir(buildDir)
+	if err != nil {
+		return nil, err
+	}
 
 	var buildLimit *semaphore.Weighted
 	if options.MaxConcurrentTasks > 0 {
@@ -152,9 +260,15 @@ func newBuildContext(options buildOptions) (ctx *buildContext, err error) {
 		newlyBuiltPackages: make(map[string]*Package),
 		pkgLockCond:        sync.NewCond(&sync.Mutex{}),
 		pkgLocks:           make(map[string]struct{}),
+		pkgBuildErrs:       make(map[string]error),
+		retest:             make(map[*Package]struct{}),
 		buildLimit:         buildLimit,
 		blazedockHash:         hex.EncodeToString(blazedockHash.Sum(nil)),
+		timing:             newTimingReport(),
+		testLogs:           newTestLogRecorder(),
+		traceCtx:           context.Background(),
 	}
+	ctx.Reporter = CompositeReporter{ctx.Reporter, ctx.testLogs}
 
 	err = os.MkdirAll(buildDir, 0755)
 	if err != nil {
@@ -163,18 +277,85 @@ func newBuildContext(options buildOptions) (ctx *buildContext, err error) {
 	return ctx, nil
 }
 
+// normalizeBuildDir turns the build dir into a clean, absolute path without symlinks.
+// Packages are built in <buildDir>/<name>.<version>, and tools which embed their working directory in the
+// build output would otherwise produce different artifacts depending on how BLAZEDOCK_BUILD_DIR is spelled.
+func normalizeBuildDir(dir string) (string, error) {
+	res, err := filepath.Abs(dir)
+	if err != nil {
+		return "", xerrors.Errorf("cannot normalize build dir %s: %w", dir, err)
+	}
+	res, err = filepath.EvalSymlinks(res)
+	if err != nil {
+		return "", xerrors.Errorf("cannot normalize build dir %s: %w", dir, err)
+	}
+	return res, nil
+}
+
 func (c *buildContext) BuildDir() string {
 	return c.buildDir
 }
 
+// checkBuildableOffline fails if any of the packages, none of which are in the local cache, cannot be built
+// without network access. It lists all such packages rather than failing on the first one mid-build.
+func checkBuildableOffline(pkgs []*Package) error {
+	var unavailable []string
+	for _, p := range pkgs {
+		reason := p.offlineBuildObstacle()
+		if reason == "" {
+			continue
+		}
+		unavailable = append(unavailable, fmt.Sprintf("%s (%s)", p.FullName(), reason))
+	}
+	if len(unavailable) == 0 {
+		return nil
+	}
+	sort.Strings(unavailable)
+	return xerrors.Errorf("offline build is impossible: the following packages are neither in the local cache nor buildable offline:\n  %s", strings.Join(unavailable, "\n  "))
+}
+
+// offlineBuildObstacle returns why this package cannot be built without network access, or an empty string if it can.
+// Build commands may still access the network, e.g. to download Go modules, but they can be satisfied by local caches.
+func (p *Package) offlineBuildObstacle() string {
+	cfg, ok := p.Config.(DockerPkgConfig)
+	if !ok {
+		return ""
+	}
+	if len(cfg.Image) > 0 {
+		return "pushes images to " + strings.Join(cfg.Image, ", ")
+	}
+	// we always build with --pull, which needs the registries of the base images
+	return "docker build pulls base images"
+}
+
+// archiveCompression configures the compression of build artifacts. Compressed artifacts are named .tar.gz
+// whichever algorithm produced them, so that local and remote caches find them regardless of this setting.
+// Readers detect the actual compression from the archive header.
+func (c *buildContext) archiveCompression() func(*TarOptions) {
+	algo := c.Compression
+	if c.DontCompress {
+		algo = NoCompr
+	}
+	return func(opts *TarOptions) {
+		opts.UseCompression = algo != NoCompr
+		opts.CompressionAlgorithm = algo
+		opts.OutputExtension = getFileExtension(Gzip)
+	}
+}
+
 // ObtainBuildLock attempts to obtain the exclusive permission to build a package.
 // If someone else is already building this package, this function blocks until that's done.
 // When the returned haveLock is true, the caller is expected to build the package and upon finishing to call ReleaseBuildLock.
-// If haveLock is false, no build or lock release must be performed.
+// If haveLock is false, no build or lock release must be performed. Use BuildError to find out if that other build failed.
 func (c *buildContext) ObtainBuildLock(p *Package) (haveLock bool) {
 	key := p.FullName()
 
 	c.pkgLockCond.L.Lock()
+	if _, failed := c.pkgBuildErrs[key]; failed {
+		// we've tried to build this package before - don't try again
+		c.pkgLockCond.L.Unlock()
+		return false
+	}
 	if _, ok := c.pkgLocks[key]; !ok {
 		// no one's holding the lock at the moment
 		c.pkgLocks[key] = struct{}{}
@@ -191,16 +372,64 @@ func (c *buildContext) ObtainBuildLock(p *Package) (haveLock bool) {
 	return false
 }
 
-// ReleaseBuildLock signals the end of a package build
-func (c *buildContext) ReleaseBuildLock(p *Package) {
+// ReleaseBuildLock signals the end of a package build. If the build failed, the error is kept for BuildError.
+func (c *buildContext) ReleaseBuildLock(p *Package, buildErr error) {
 	key := p.FullName()
 
 	c.pkgLockCond.L.Lock()
 	delete(c.pkgLocks, key)
+	if buildErr != nil {
+		c.pkgBuildErrs[key] = buildErr
+	}
 	c.pkgLockCond.Broadcast()
 	c.pkgLockCond.L.Unlock()
 }
 
+// BuildError returns the error of a failed package build, or nil if the package has not failed to build.
+func (c *buildContext) BuildError(p *Package) error {
+	c.pkgLockCond.L.Lock()
+	defer c.pkgLockCond.L.Unlock()
+
+	return c.pkgBuildErrs[p.FullName()]
+}
+
+// mustRetest returns true if the tests of the package must run, although it may be in the local cache
+func (c *buildContext) mustRetest(p *Package) bool {
+	_, ok := c.retest[p]
+	return ok
+}
+
+// BuildFailures returns the packages which failed to build so far, and those which were skipped
+func (c *buildContext) BuildFailures() *BuildFailedErr {
+	c.pkgLockCond.L.Lock()
+	defer c.pkgLockCond.L.Unlock()
+
+	res := &BuildFailedErr{
+		Failed:  make(map[string]error),
+		Skipped: make(map[string]PkgSkippedErr),
+	}
+	for name, err := range c.pkgBuildErrs {
+		var skipped PkgSkippedErr
+		if errors.As(err, &skipped) && skipped.Package.FullName() == name {
+			res.Skipped[name] = skipped
+			continue
+		}
+		res.Failed[name] = err
+	}
+	return res
+}
+
+// abortBuild returns an error if the package must not be built because the build is fail-fast and another package failed already
+func (c *buildContext) abortBuild(p *Package) error {
+	if !c.FailFast {
+		return nil
+	}
+	if len(c.BuildFailures().Failed) == 0 {
+		return nil
+	}
+	return PkgSkippedErr{Package: p}
+}
+
 // LimitConcurrentBuilds blocks until there is a free slot to acutally build.
 // This function effectively limits the number of concurrent builds.
 // We do not do this limiting as part of the build lock, because that would block
@@ -258,12 +487,26 @@ type buildOptions struct {
 	Reporter               Reporter
 	DryRun                 bool
 	BuildPlan              io.Writer
+	CacheReport            io.Writer
+	Explain                io.Writer
+	TimingReport           io.Writer
+	TimingReportJSON       io.Writer
 	DontCompress           bool
+	Compression            CompressionAlgorithm
 	DontTest               bool
 	MaxConcurrentTasks     int64
 	CoverageOutputPath     string
 	DockerBuildOptions     *DockerBuildOptions
 	JailedExecution        bool
+	Offline                bool
+	FailFast               bool
+	Hermetic               bool
+	KeepBuildDir           bool
+	KeepFailed             bool
+	Timeout                time.Duration
+	TestRetries            int
+	TestCache              bool
+	ForceTest              bool
 
 	context *buildContext
 }
@@ -314,6 +557,39 @@ func WithBuildPlan(out io.Writer) BuildOption {
 	}
 }
 
+// WithExplain writes why each package which is not in the local cache needs to be built to the writer before the build
+// starts, by comparing its inputs against those of the previous build of the package in the local cache
+func WithExplain(out io.Writer) BuildOption {
+	return func(opts *buildOptions) error {
+		opts.Explain = out
+		return nil
+	}
+}
+
+// WithCacheReport writes a summary of the cache hits and misses to the writer once the build is done
+func WithCacheReport(out io.Writer) BuildOption {
+	return func(opts *buildOptions) error {
+		opts.CacheReport = out
+		return nil
+	}
+}
+
+// WithTimingReport writes the build duration of each package, slowest first, to the writer once the build is done
+func WithTimingReport(out io.Writer) BuildOption {
+	return func(opts *buildOptions) error {
+		opts.TimingReport = out
+		return nil
+	}
+}
+
+// WithTimingReportJSON writes the build duration of each package as JSON to the writer once the build is done
+func WithTimingReportJSON(out io.Writer) BuildOption {
+	return func(opts *buildOptions) error {
+		opts.TimingReportJSON = out
+		return nil
+	}
+}
+
 // WithDontTest disables package-level tests
 func WithDontTest(dontTest bool) BuildOption {
 	return func(opts *buildOptions) error {
@@ -365,6 +641,104 @@ func WithCompressionDisabled(dontCompress bool) BuildOption {
 	}
 }
 
+// WithFailFast stops building packages once a package failed to build. Without it, blazedock builds all packages
+// whose dependencies were built successfully and reports all failures once the build is done.
+func WithFailFast(failFast bool) BuildOption {
+	return func(opts *buildOptions) error {
+		opts.FailFast = failFast
+		return nil
+	}
+}
+
+// WithHermetic runs build commands with a scrubbed environment. Of the host environment they only get to see
+// PATH, HOME and the variables allowlisted using envPassthrough.
+func WithHermetic(hermetic bool) BuildOption {
+	return func(opts *buildOptions) error {
+		opts.Hermetic = hermetic
+		return nil
+	}
+}
+
+// WithKeepBuildDir keeps the build directories of all packages, whether they built successfully or not.
+// By default they are removed once the package is built.
+func WithKeepBuildDir(keep bool) BuildOption {
+	return func(opts *buildOptions) error {
+		opts.KeepBuildDir = keep
+		return nil
+	}
+}
+
+// WithKeepFailed keeps the build directories of packages which failed to build, s.t. the failing command can be
+// reproduced. By default they are removed.
+func WithKeepFailed(keep bool) BuildOption {
+	return func(opts *buildOptions) error {
+		opts.KeepFailed = keep
+		return nil
+	}
+}
+
+// WithTimeout cancels the build commands of packages which don't set a timeout of their own once they've been
+// running for longer than timeout. Zero disables the timeout.
+func WithTimeout(timeout time.Duration) BuildOption {
+	return func(opts *buildOptions) error {
+		opts.Timeout = timeout
+		return nil
+	}
+}
+
+// WithTestRetries re-runs the failing build and test commands of packages which don't set retries of their own up
+// to n times before the package build fails. Zero disables retries.
+func WithTestRetries(n int) BuildOption {
+	return func(opts *buildOptions) error {
+		if n < 0 {
+			return xerrors.Errorf("test retries must not be negative")
+		}
+		opts.TestRetries = n
+		return nil
+	}
+}
+
+// WithTestCache serves the tests of the package passed to Build from the test cache: if its tests passed when it was
+// built, their output is replayed. Otherwise the package is rebuilt s.t. its tests run, even if it is in the local cache,
+// e.g. because it was built with tests disabled or downloaded from the remote cache. Tests are cached per package
+// version, hence they rerun whenever the sources, including the test sources, or dependencies change.
+func WithTestCache(enabled bool) BuildOption {
+	return func(opts *buildOptions) error {
+		opts.TestCache = enabled
+		return nil
+	}
+}
+
+// WithForceTest bypasses the test cache (see WithTestCache) and always runs the tests of the package passed to Build
+func WithForceTest(force bool) BuildOption {
+	return func(opts *buildOptions) error {
+		opts.ForceTest = force
+		return nil
+	}
+}
+
+// WithOffline builds without reading from or writing to the remote cache. Packages which are not in the
+// local cache must be buildable without network access, otherwise the build fails before it starts.
+func WithOffline(offline bool) BuildOption {
+	return func(opts *buildOptions) error {
+		opts.Offline = offline
+		return nil
+	}
+}
+
+// WithCacheCompression selects the compression algorithm of build artifacts. WithCompressionDisabled takes precedence.
+func WithCacheCompression(algo CompressionAlgorithm) BuildOption {
+	return func(opts *buildOptions) error {
+		switch algo {
+		case Gzip, Zstd, NoCompr:
+		default:
+			return xerrors.Errorf("unsupported compression algorithm: %s", algo)
+		}
+		opts.Compression = algo
+		return nil
+	}
+}
+
 func withBuildContext(ctx *buildContext) BuildOption {
 	return func(opts *buildOptions) error {
 		opts.context = ctx
@@ -378,6 +752,8 @@ func applyBuildOpts(opts []BuildOption) (buildOptions, error) {
 		Reporter:    NewConsoleReporter(),
 		RemoteCache: remote.NewNoRemoteCache(),
 		DryRun:      false,
+		Compression: Gzip,
+		FailFast:    true,
 	}
 	for _, opt := range opts {
 		err := opt(&options)
@@ -388,13 +764,23 @@ func applyBuildOpts(opts []BuildOption) (buildOptions, error) {
 	if options.LocalCache == nil {
 		return options, xerrors.Errorf("cannot build without local cache. Use WithLocalCache() to configure one")
 	}
+	if options.Offline {
+		options.RemoteCache = remote.NewNoRemoteCache()
+		options.AdditionalRemoteCaches = nil
+	}
 
 	return options, nil
 }
 
-// Build builds the packages in the order they're given. It's the callers responsibility to ensure the dependencies are built
-// in order.
+// Build builds the package and its dependencies
 func Build(pkg *Package, opts ...BuildOption) (err error) {
+	return BuildAll([]*Package{pkg}, opts...)
+}
+
+// BuildAll builds several packages as one build graph, s.t. the dependencies they share are built only once.
+// Unless the build is fail-fast, all packages are built which can be built, and the failures of all of them
+// are returned at the end.
+func BuildAll(pkgs []*Package, opts ...BuildOption) (err error) {
 	options, err := applyBuildOpts(opts)
 	if err != nil {
 		return err
@@ -409,27 +795,105 @@ func Build(pkg *Package, opts ...BuildOption) (err error) {
 		return err
 	}
 
-	requirements := pkg.GetTransitiveDependencies()
-	allpkg := append(requirements, pkg)
+	names := make([]string, len(pkgs))
+	for i, p := range pkgs {
+		names[i] = p.FullName()
+	}
+	traceCtx, task := trace.NewTask(ctx.traceCtx, "build "+strings.Join(names, " "))
+	defer task.End()
+	ctx.traceCtx = traceCtx
+
+	if ctx.TimingReport != nil || ctx.TimingReportJSON != nil {
+		defer func() {
+			ctx.timing.finish()
+			if ctx.TimingReport != nil {
+				if rerr := ctx.timing.Write(ctx.TimingReport); rerr != nil {
+					log.WithError(rerr).Warn("cannot write timing report")
+				}
+			}
+			if ctx.TimingReportJSON != nil {
+				if rerr := ctx.timing.WriteJSON(ctx.TimingReportJSON); rerr != nil {
+					log.WithError(rerr).Warn("cannot write timing report")
+				}
+			}
+		}()
+	}
+
+	// Tests are cached separately from the build artifact, as the artifact may have been built without running them
+	testLogs := make(map[*Package][]byte)
+	for _, pkg := range pkgs {
+		if (!ctx.TestCache && !ctx.ForceTest) || ctx.DontTest || !pkg.HasTests() {
+			continue
+		}
+		if !ctx.ForceTest {
+			testLog, err := cachedTestLog(pkg, ctx.LocalCache)
+			if err != nil {
+				return err
+			}
+			if testLog != nil {
+				testLogs[pkg] = testLog
+				continue
+			}
+		}
+		ctx.retest[pkg] = struct{}{}
+	}
+
+	// owned are the packages which a target adds to the build graph, i.e. which no target before it depends on
+	var (
+		allpkg []*Package
+		owned  = make(map[*Package][]*Package, len(pkgs))
+		seen   = make(map[*Package]struct{})
+	)
+	for _, pkg := range pkgs {
+		for _, p := range append(pkg.GetTransitiveDependencies(), pkg) {
+			if _, exists := seen[p]; exists {
+				continue
+			}
+			seen[p] = struct{}{}
+			allpkg = append(allpkg, p)
+			owned[pkg] = append(owned[pkg], p)
+		}
+	}
 
 	pkgsInLocalCache := make(map[*Package]struct{})
 	var pkgsToCheckRemoteCache []*Package
+	lookupRegion := trace.StartRegion(traceCtx, "local cache lookup")
 	for _, p := range allpkg {
 		if p.Ephemeral {
 			// Ephemeral packages will always need to be build
 			continue
 		}
+		if ctx.mustRetest(p) {
+			// the package is built s.t. its tests run, hence neither cache can serve it
+			continue
+		}
 
-		if _, exists := ctx.LocalCache.Location(p); exists {
+		t0 := time.Now()
+		loc, exists := ctx.LocalCache.Location(p)
+		exists = exists && verifyCachedArtifact(p, loc, false)
+		ctx.timing.recordLookup(p, time.Since(t0))
+		if exists {
 			pkgsInLocalCache[p] = struct{}{}
 			continue
 		}
 
 		pkgsToCheckRemoteCache = append(pkgsToCheckRemoteCache, p)
 	}
+	lookupRegion.End()
+
+	if ctx.Offline {
+		err = checkBuildableOffline(pkgsToCheckRemoteCache)
+		if err != nil {
+			return err
+		}
+	}
 
 	pkgsToCheckRemoteCacheCache := toPackageInterface(pkgsToCheckRemoteCache)
+	t0 := time.Now()
+	lookupRegion = trace.StartRegion(traceCtx, "remote cache lookup")
 	pkgsInRemoteCache, err := ctx.RemoteCache.ExistingPackages(context.Background(), pkgsToCheckRemoteCacheCache)
+	lookupRegion.End()
+	ctx.timing.RemoteLookup = time.Since(t0)
 	if err != nil {
 		return err
 	}
@@ -437,7 +901,9 @@ func Build(pkg *Package, opts ...BuildOption) (err error) {
 	pkgsInRemoteCacheMap := toPackageMap(pkgsInRemoteCache)
 
 	pkgsWillBeDownloaded := make(map[*Package]struct{})
-	pkg.packagesToDownload(pkgsInLocalCache, pkgsInRemoteCacheMap, pkgsWillBeDownloaded)
+	for _, pkg := range pkgs {
+		pkg.packagesToDownload(pkgsInLocalCache, pkgsInRemoteCacheMap, pkgsWillBeDownloaded)
+	}
 
 	pkgstatus := make(map[*Package]PackageBuildStatus)
 	unresolvedArgs := make(map[string][]string)
@@ -487,16 +953,70 @@ func Build(pkg *Package, opts ...BuildOption) (err error) {
 		pkgsToDownloadCache[i] = p
 	}
 
+	t0 = time.Now()
+	downloadRegion := trace.StartRegion(traceCtx, "remote cache download")
 	err = ctx.RemoteCache.Download(context.Background(), ctx.LocalCache, pkgsToDownloadCache)
+	downloadRegion.End()
+	ctx.timing.Download = time.Since(t0)
 	if err != nil {
 		return err
 	}
+	for _, p := range downloadVerified(ctx, pkgsToDownload) {
+		// the package could not be downloaded intact and will be built instead
+		pkgstatus[p] = PackageNotBuiltYet
+	}
+	for _, p := range pkgsToDownload {
+		if pkgstatus[p] == PackageDownloaded {
+			dedupeCachedArtifact(ctx.LocalCache, p)
+		}
+	}
+	ctx.timing.recordCacheStatus(pkgstatus)
 
-	ctx.Reporter.BuildStarted(pkg, pkgstatus)
+	if ctx.Explain != nil {
+		err = explainRebuilds(ctx.Explain, allpkg, pkgstatus, ctx.LocalCache)
+		if err != nil {
+			return err
+		}
+	}
+
+	cacheReport := newCacheReport(allpkg, pkgstatus, ctx.LocalCache)
+	if ctx.CacheReport != nil {
+		defer func() {
+			rerr := cacheReport.Write(ctx.CacheReport)
+			if rerr != nil {
+				log.WithError(rerr).Warn("cannot write cache report")
+			}
+		}()
+	}
+
+	// every package is reported as part of the first target which needs it, as if the targets were built one after the other
+	targetErrs := make([]error, len(pkgs))
+	for _, pkg := range pkgs {
+		status := make(map[*Package]PackageBuildStatus, len(owned[pkg]))
+		for _, p := range owned[pkg] {
+			status[p] = pkgstatus[p]
+		}
+		ctx.Reporter.BuildStarted(pkg, status)
+	}
 	defer func(err *error) {
-		ctx.Reporter.BuildFinished(pkg, *err)
+		for i, pkg := range pkgs {
+			perr := *err
+			var failures *BuildFailedErr
+			if len(pkgs) > 1 && errors.As(perr, &failures) {
+				// each target reports whether it was built itself
+				perr = targetErrs[i]
+			}
+			ctx.Reporter.BuildFinished(pkg, perr)
+		}
 	}(&err)
 
+	for _, pkg := range pkgs {
+		if testLog, ok := testLogs[pkg]; ok {
+			log.WithField("package", pkg.FullName()).Info("tests passed for this version before - replaying their output (use --force-test to rerun them)")
+			ctx.Reporter.PackageBuildLog(pkg, false, testLog)
+		}
+	}
+
 	if len(unresolvedArgs) != 0 {
 		var msg string
 		for arg, pkgs := range unresolvedArgs {
@@ -508,7 +1028,7 @@ func Build(pkg *Package, opts ...BuildOption) (err error) {
 
 	if ctx.BuildPlan != nil {
 		log.Debug("writing build plan")
-		err = writeBuildPlan(ctx.BuildPlan, pkg, pkgstatus)
+		err = writeBuildPlan(ctx.BuildPlan, pkgs, pkgstatus)
 		if err != nil {
 			return err
 		}
@@ -519,12 +1039,36 @@ func Build(pkg *Package, opts ...BuildOption) (err error) {
 		return nil
 	}
 
-	buildErr := pkg.build(ctx)
+	// The targets are built like the dependencies of a package: all of them, even if one of them fails
+	g := new(errgroup.Group)
+	for i, pkg := range pkgs {
+		i, pkg := i, pkg
+		g.Go(func() error {
+			targetErrs[i] = pkg.build(ctx)
+			return nil
+		})
+	}
+	_ = g.Wait()
 
 	// Check for build errors immediately and return if there are any
-	if buildErr != nil {
-		// We deliberately swallow the target package build error as that will have already been reported using the reporter.
-		return xerrors.Errorf("build failed")
+	if slices.ContainsFunc(targetErrs, func(err error) bool { return err != nil }) {
+		// The package build errors have already been reported using the reporter, hence we only list the packages.
+		failures := ctx.BuildFailures()
+		if len(failures.Failed) == 0 {
+			// the targets failed before any build started, e.g. because their version could not be computed
+			for i, pkg := range pkgs {
+				if targetErrs[i] != nil {
+					failures.Failed[pkg.FullName()] = targetErrs[i]
+				}
+			}
+		}
+		if !ctx.FailFast {
+			// when building all we can, the successfully built packages are worth sharing
+			if cacheErr := ctx.RemoteCache.Upload(context.Background(), ctx.LocalCache, toPackageInterface(ctx.GetNewPackagesForCache())); cacheErr != nil {
+				log.WithError(cacheErr).Warn("cannot upload successfully built packages to the remote cache")
+			}
+		}
+		return failures
 	}
 
 	// Only proceed with cache upload if build succeeded
@@ -543,7 +1087,98 @@ func Build(pkg *Package, opts ...BuildOption) (err error) {
 	return nil
 }
 
-func writeBuildPlan(out io.Writer, pkg *Package, status map[*Package]PackageBuildStatus) error {
+// CacheStatus describes where the build result of a package came from
+type CacheStatus string
+
+const (
+	// CacheStatusLocalHit means the package was found in the local cache
+	CacheStatusLocalHit CacheStatus = "local-hit"
+	// CacheStatusRemoteHit means the package was downloaded from the remote cache
+	CacheStatusRemoteHit CacheStatus = "remote-hit"
+	// CacheStatusUnused means the package exists in the remote cache, but no package in this build needs it
+	CacheStatusUnused CacheStatus = "unused"
+	// CacheStatusMiss means the package has to be built
+	CacheStatusMiss CacheStatus = "miss"
+)
+
+// CacheReport lists the cache status of all packages of a build
+type CacheReport struct {
+	Entries         []CacheReportEntry
+	DownloadedBytes int64
+}
+
+// CacheReportEntry is the cache status of a single package
+type CacheReportEntry struct {
+	Package string
+	Key     string
+	Status  CacheStatus
+	Size    int64
+}
+
+// newCacheReport resolves the cache status of all packages. It must be called after the download from the
+// remote cache, because a download can fail in which case the package needs to be rebuilt after all.
+func newCacheReport(pkgs []*Package, status map[*Package]PackageBuildStatus, localCache cache.LocalCache) *CacheReport {
+	var rep CacheReport
+	for _, p := range pkgs {
+		key, err := p.Version()
+		if err != nil {
+			key = "unknown"
+		}
+
+		entry := CacheReportEntry{
+			Package: p.FullName(),
+			Key:     key,
+			Status:  CacheStatusMiss,
+		}
+		switch status[p] {
+		case PackageBuilt:
+			entry.Status = CacheStatusLocalHit
+		case PackageInRemoteCache:
+			entry.Status = CacheStatusUnused
+		case PackageDownloaded:
+			fn, exists := localCache.Location(p)
+			if !exists {
+				break
+			}
+			entry.Status = CacheStatusRemoteHit
+			if stat, err := os.Stat(fn); err == nil {
+				entry.Size = stat.Size()
+				rep.DownloadedBytes += entry.Size
+			}
+		}
+		log.WithFields(log.Fields{
+			"package":  entry.Package,
+			"cacheKey": entry.Key,
+			"status":   entry.Status,
+		}).Info("resolved cache status")
+
+		rep.Entries = append(rep.Entries, entry)
+	}
+	sort.Slice(rep.Entries, func(i, j int) bool { return rep.Entries[i].Package < rep.Entries[j].Package })
+
+	return &rep
+}
+
+// Write prints the report as table followed by a summary line
+func (rep *CacheReport) Write(out io.Writer) error {
+	counts := make(map[CacheStatus]int)
+	tw := tabwriter.NewWriter(out, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tSTATUS\tCACHE KEY\tSIZE")
+	for _, e := range rep.Entries {
+		counts[e.Status]++
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", e.Package, e.Status, e.Key, e.Size)
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(out, "\nlocal hits: %d, remote hits: %d, unused: %d, rebuilds: %d, downloaded: %d bytes\n",
+		counts[CacheStatusLocalHit], counts[CacheStatusRemoteHit], counts[CacheStatusUnused], counts[CacheStatusMiss], rep.DownloadedBytes)
+	return err
+}
+
+func writeBuildPlan(out io.Writer, pkgs []*Package, status map[*Package]PackageBuildStatus) error {
 	// BuildStep is a list of packages that can be built in parallel
 	type BuildStep []string
 
@@ -565,7 +1200,9 @@ func writeBuildPlan(out io.Writer, pkg *Package, status map[*Package]PackageBuil
 	}
 
 	idx := make(map[*Package]int)
-	walk(pkg, idx, 0)
+	for _, pkg := range pkgs {
+		walk(pkg, idx, 0)
+	}
 
 	var md int
 	for _, d := range idx {
@@ -600,29 +1237,62 @@ func (p *Package) buildDependencies(buildctx *buildContext) error {
 		return nil
 	}
 
-	// Use errgroup to simplify error handling and synchronization
-	g := new(errgroup.Group)
-
-	for _, dep := range deps {
+	// We build all dependencies, even if one of them fails, and collect which ones failed
+	var (
+		g    = new(errgroup.Group)
+		errs = make([]error, len(deps))
+	)
+	for i, dep := range deps {
 		// Capture the dependency in a local variable to avoid closure issues
-		d := dep
+		i, d := i, dep
 		g.Go(func() error {
-			return d.build(buildctx)
+			errs[i] = d.build(buildctx)
+			return nil
 		})
 	}
+	_ = g.Wait()
 
-	// Wait for all goroutines to complete, returning the first error encountered
-	return g.Wait()
-}
+	var (
+		failed  = make(map[string]struct{})
+		aborted bool
+	)
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		var skipped PkgSkippedErr
+		if !errors.As(err, &skipped) {
+			failed[deps[i].FullName()] = struct{}{}
+			continue
+		}
+		// a dependency without failed dependencies was skipped because the build is fail-fast
+		aborted = aborted || len(skipped.FailedDependencies) == 0
+		for _, f := range skipped.FailedDependencies {
+			failed[f] = struct{}{}
+		}
+	}
+	if len(failed) == 0 && !aborted {
+		return nil
+	}
 
-func (p *Package) build(buildctx *buildContext) error {
+	res := PkgSkippedErr{Package: p}
+	for f := range failed {
+		res.FailedDependencies = append(res.FailedDependencies, f)
+	}
+	sort.Strings(res.FailedDependencies)
+	return res
+}
+
+func (p *Package) build(buildctx *buildContext) (err error) {
 	// Try to obtain lock for building this package
 	doBuild := buildctx.ObtainBuildLock(p)
 	if !doBuild {
-		// Another goroutine is already building this package
-		return nil
+		// Another goroutine has built this package already. If that failed, so must all packages depending on it.
+		return buildctx.BuildError(p)
 	}
-	defer buildctx.ReleaseBuildLock(p)
+	defer func() {
+		buildctx.ReleaseBuildLock(p, err)
+	}()
 
 	// Get package version
 	version, err := p.Version()
@@ -632,15 +1302,30 @@ func (p *Package) build(buildctx *buildContext) error {
 
 	// Build dependencies first
 	if err := p.buildDependencies(buildctx); err != nil {
+		log.WithField("package", p.FullName()).WithError(err).Debug("skipping package")
 		return err
 	}
 
-	// Skip if package is already built (except for ephemeral packages)
-	if _, alreadyBuilt := buildctx.LocalCache.Location(p); !p.Ephemeral && alreadyBuilt {
+	// Skip if package is already built (except for ephemeral packages and packages whose tests must run)
+	if loc, alreadyBuilt := buildctx.LocalCache.Location(p); !p.Ephemeral && !buildctx.mustRetest(p) && alreadyBuilt {
 		log.WithField("package", p.FullName()).Debug("already built")
+		if p.PostBuildAlways {
+			return runCachedPostBuildCommands(context.Background(), buildctx, p, loc)
+		}
 		return nil
 	}
 
+	if err := buildctx.abortBuild(p); err != nil {
+		return err
+	}
+
+	_, task := trace.NewTask(buildctx.traceCtx, p.FullName())
+	defer task.End()
+	buildStart := time.Now()
+	defer func() {
+		buildctx.timing.recordBuild(p, time.Since(buildStart), err)
+	}()
+
 	// Initialize package build report
 	pkgRep := &PackageBuildReport{
 		phaseEnter: make(map[PackageBuildPhase]time.Time),
@@ -659,10 +1344,27 @@ func (p *Package) build(buildctx *buildContext) error {
 	}()
 
 	// Prepare build directory
-	builddir := filepath.Join(buildctx.BuildDir(), p.FilesystemSafeName()+"."+version)
-	if err := prepareDirectory(builddir); err != nil {
+	builddir, releaseBuildDir, err := buildctx.packageBuildDir(p, version)
+	if err != nil {
 		return err
 	}
+	defer func() {
+		defer releaseBuildDir()
+
+		var skipped PkgSkippedErr
+		switch {
+		case err == nil && buildctx.KeepBuildDir:
+			return
+		case err != nil && errors.As(err, &skipped):
+			// the package was not built, hence there is nothing to inspect
+		case err != nil && (buildctx.KeepFailed || buildctx.KeepBuildDir):
+			log.WithField("package", p.FullName()).Warnf("kept the build directory of the failed package: %s", builddir)
+			return
+		}
+		if rerr := os.RemoveAll(builddir); rerr != nil {
+			log.WithError(rerr).WithField("dir", builddir).Warn("cannot remove build directory")
+		}
+	}()
 
 	// Copy source files if needed
 	if err := copySources(p, builddir); err != nil {
@@ -673,6 +1375,32 @@ func (p *Package) build(buildctx *buildContext) error {
 	buildctx.LimitConcurrentBuilds()
 	defer buildctx.ReleaseConcurrentBuild()
 
+	// Another package may have failed while we were waiting for a build slot
+	if err := buildctx.abortBuild(p); err != nil {
+		return err
+	}
+
+	// The timeout starts only now, s.t. neither building the dependencies nor waiting for a build slot counts
+	cmdctx, timeout := context.Background(), p.Timeout
+	if timeout == 0 {
+		timeout = buildctx.Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		cmdctx, cancel = context.WithTimeout(cmdctx, timeout)
+		defer cancel()
+		defer func() {
+			if err != nil && errors.Is(cmdctx.Err(), context.DeadlineExceeded) {
+				err = PkgTimeoutErr{Package: p, Timeout: timeout, Err: err}
+			}
+		}()
+	}
+
+	// Pre-build commands run before the build is planned, s.t. the build sees the files they generate
+	if err := runPreBuildCommands(cmdctx, buildctx, p, builddir); err != nil {
+		return err
+	}
+
 	// Build the package based on its type
 	var (
 		result, _ = buildctx.LocalCache.Location(p)
@@ -689,6 +1417,10 @@ func (p *Package) build(buildctx *buildContext) error {
 		bld, err = p.buildDocker(buildctx, builddir, result)
 	case GenericPackage:
 		bld, err = p.buildGeneric(buildctx, builddir, result)
+	case RustPackage:
+		bld, err = p.buildRust(buildctx, builddir, result)
+	case PythonPackage:
+		bld, err = p.buildPython(buildctx, builddir, result)
 	default:
 		return xerrors.Errorf("cannot build package type: %s", p.Type)
 	}
@@ -705,6 +1437,18 @@ func (p *Package) build(buildctx *buildContext) error {
 		}
 	}
 
+	// Link the dependencies into the build dir unless the build commands unpack them
+	linked, err := buildctx.assembleLayout(p, builddir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			// a failed build may have modified the dependencies it shares with other builds just as well
+			_ = buildctx.verifyLayout(p, linked)
+		}
+	}()
+
 	// Execute build phases
 	for _, phase := range []PackageBuildPhase{
 		PackageBuildPhasePrep,
@@ -713,10 +1457,13 @@ func (p *Package) build(buildctx *buildContext) error {
 		PackageBuildPhaseTest,
 		PackageBuildPhaseBuild,
 	} {
-		if err := executeBuildPhase(buildctx, p, builddir, bld, phase, pkgRep); err != nil {
+		if err := executeBuildPhase(cmdctx, buildctx, p, builddir, bld, phase, pkgRep); err != nil {
 			return err
 		}
 	}
+	if err := buildctx.verifyLayout(p, linked); err != nil {
+		return err
+	}
 
 	// Execute post-processing hook if available - this should run regardless of provenance settings
 	if bld.PostProcess != nil {
@@ -745,17 +1492,86 @@ func (p *Package) build(buildctx *buildContext) error {
 		pkgRep.FunctionsWithTest = funcsWithTest
 	}
 
+	// The result of an earlier build may be a hard link to content the cache shares between artifacts, hence it
+	// must never be written in place
+	if err := os.Remove(result); err != nil && !os.IsNotExist(err) {
+		return xerrors.Errorf("cannot remove previous build result: %w", err)
+	}
+
 	// Package the build results
 	if len(bld.Commands[PackageBuildPhasePackage]) > 0 {
-		if err := executeCommandsForPackage(buildctx, p, builddir, bld.Commands[PackageBuildPhasePackage]); err != nil {
+		if err := executeCommandsForPackage(cmdctx, buildctx, p, builddir, bld.Commands[PackageBuildPhasePackage]); err != nil {
 			return err
 		}
 	}
 
+	// Compressed build results get another extension than result has if nothing was cached before
+	artifact, exists := buildctx.LocalCache.Location(p)
+	if !exists {
+		return xerrors.Errorf("package did not produce a build result at %s", artifact)
+	}
+
+	// Post-build commands run before the checksum is recorded, s.t. they may alter the build result, e.g. to sign it
+	if err := runStagedPostBuildCommands(cmdctx, buildctx, p, artifact); err != nil {
+		return err
+	}
+
+	// Record the checksum of the build result s.t. corruption can be detected when it's used from the cache
+	if _, err := cache.WriteChecksum(artifact); err != nil {
+		return err
+	}
+
+	// Record the inputs of the build result s.t. a later rebuild can be explained
+	if err := writeInputManifest(p, artifact); err != nil {
+		return err
+	}
+
+	// Record the test output s.t. the tests need not run again for this version
+	if pkgRep.testLog != nil {
+		if err := writeTestLog(artifact, pkgRep.testLog); err != nil {
+			return err
+		}
+	}
+
+	dedupeCachedArtifact(buildctx.LocalCache, p)
+
 	// Register newly built package
 	return buildctx.RegisterNewlyBuilt(p)
 }
 
+// packageBuildDir prepares the directory a package is built in, i.e. where its sources are copied to and the layout
+// of its dependencies is assembled. The directory is keyed by the package name and version, s.t. packages built
+// concurrently never share one. It is locked against other blazedock processes using the same build dir until
+// release is called.
+func (c *buildContext) packageBuildDir(p *Package, version string) (dir string, release func(), err error) {
+	dir = filepath.Join(c.BuildDir(), p.FilesystemSafeName()+"."+version)
+
+	// the lock file lives next to the directory as the directory itself is removed and recreated
+	lock, err := os.OpenFile(dir+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return "", nil, xerrors.Errorf("cannot lock build directory %s: %w", dir, err)
+	}
+	err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		log.WithField("dir", dir).Info("build directory is in use by another blazedock process - waiting")
+		err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX)
+	}
+	if err != nil {
+		lock.Close()
+		return "", nil, xerrors.Errorf("cannot lock build directory %s: %w", dir, err)
+	}
+	release = func() {
+		_ = syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+		lock.Close()
+	}
+
+	if err := prepareDirectory(dir); err != nil {
+		release()
+		return "", nil, err
+	}
+	return dir, release, nil
+}
+
 func prepareDirectory(dir string) error {
 	if _, err := os.Stat(dir); !os.IsNotExist(err) {
 		if err := os.RemoveAll(dir); err != nil {
@@ -784,14 +1600,14 @@ func copySources(p *Package, builddir string) error {
 	if len(parentedFiles) > 0 {
 		args := append([]string{"--parents"}, parentedFiles...)
 		args = append(args, builddir)
-		if err := run(nil, p, nil, p.C.Origin, "cp", args...); err != nil {
+		if err := run(context.Background(), nil, p, nil, p.C.Origin, "cp", args...); err != nil {
 			return err
 		}
 	}
 
 	if len(notParentedFiles) > 0 {
 		args := append(notParentedFiles, builddir)
-		if err := run(nil, p, nil, p.C.Origin, "cp", args...); err != nil {
+		if err := run(context.Background(), nil, p, nil, p.C.Origin, "cp", args...); err != nil {
 			return err
 		}
 	}
@@ -799,7 +1615,7 @@ func copySources(p *Package, builddir string) error {
 	return nil
 }
 
-func executeBuildPhase(buildctx *buildContext, p *Package, builddir string, bld *packageBuild, phase PackageBuildPhase, pkgRep *PackageBuildReport) error {
+func executeBuildPhase(ctx context.Context, buildctx *buildContext, p *Package, builddir string, bld *packageBuild, phase PackageBuildPhase, pkgRep *PackageBuildReport) error {
 	cmds := bld.Commands[phase]
 	if len(cmds) == 0 {
 		return nil
@@ -812,12 +1628,77 @@ func executeBuildPhase(buildctx *buildContext, p *Package, builddir string, bld
 
 	log.WithField("phase", phase).WithField("package", p.FullName()).WithField("commands", bld.Commands[phase]).Debug("running commands")
 
-	err := executeCommandsForPackage(buildctx, p, builddir, cmds)
+	testPhase := bld.TestPhase
+	if testPhase == "" {
+		testPhase = PackageBuildPhaseTest
+	}
+	if phase == testPhase {
+		pkgRep.testPhase = testPhase
+		buildctx.testLogs.start(p)
+	}
+
+	var err error
+	if retries := buildctx.retries(p); retries > 0 && (phase == testPhase || phase == PackageBuildPhaseBuild) {
+		for _, cmd := range cmds {
+			err = executeCommandWithRetries(ctx, buildctx, p, builddir, cmd, retries, pkgRep)
+			if err != nil {
+				break
+			}
+		}
+	} else {
+		err = executeCommandsForPackage(ctx, buildctx, p, builddir, cmds)
+	}
 	pkgRep.phaseDone[phase] = time.Now()
+	if phase == testPhase {
+		pkgRep.testLog = buildctx.testLogs.stop(p)
+	}
 
 	return err
 }
 
+// retryBackoff is how long blazedock waits before it re-runs a failed command for the first time. The wait doubles
+// with every further attempt.
+var retryBackoff = 2 * time.Second
+
+// retries returns how often the failing build and test commands of a package are re-run
+func (c *buildContext) retries(p *Package) int {
+	if p.Retries > 0 {
+		return p.Retries
+	}
+	return c.TestRetries
+}
+
+// executeCommandWithRetries runs a command and re-runs it up to retries times if it fails. The build result is only
+// cached if the package build succeeds eventually, hence a flaky command never produces a result which failed.
+func executeCommandWithRetries(ctx context.Context, buildctx *buildContext, p *Package, wd string, cmd []string, retries int, pkgRep *PackageBuildReport) error {
+	var (
+		logger  = log.WithField("package", p.FullName()).WithField("command", strings.Join(cmd, " "))
+		backoff = retryBackoff
+	)
+	for attempt := 1; ; attempt++ {
+		err := executeCommandsForPackage(ctx, buildctx, p, wd, [][]string{cmd})
+		if err == nil {
+			if attempt > 1 {
+				logger.Warnf("command succeeded on attempt %d of %d", attempt, retries+1)
+			}
+			return nil
+		}
+		// there's no point in trying again once the package timed out
+		if attempt > retries || ctx.Err() != nil {
+			return err
+		}
+
+		logger.WithError(err).Warnf("command failed on attempt %d of %d, retrying in %s", attempt, retries+1, backoff)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		pkgRep.Retries++
+	}
+}
+
 func handleProvenance(p *Package, buildctx *buildContext, builddir string, bld *packageBuild, sources fileset, now time.Time) error {
 	var (
 		subjects  []in_toto.Subject
@@ -844,6 +1725,89 @@ func handleProvenance(p *Package, buildctx *buildContext, builddir string, bld *
 	return writeProvenance(p, buildctx, resultDir, subjects, now)
 }
 
+// verifyCachedArtifact checks a build artifact in the local cache against its recorded checksum.
+// Corrupted artifacts are removed from the cache and false is returned. Artifacts without
+// checksum, e.g. those built by older versions of blazedock, are trusted.
+func verifyCachedArtifact(p *Package, loc string, full bool) bool {
+	err := cache.VerifyChecksum(loc, full)
+	if err == nil || errors.Is(err, cache.ErrNoChecksum) {
+		return true
+	}
+
+	var mismatch *cache.ChecksumMismatchError
+	if !errors.As(err, &mismatch) {
+		log.WithError(err).WithField("package", p.FullName()).Warn("cannot verify cached build artifact")
+		return true
+	}
+
+	log.WithError(err).WithField("package", p.FullName()).Warn("cached build artifact is corrupted - removing it")
+	if err := cache.RemoveArtifact(loc); err != nil {
+		log.WithError(err).WithField("package", p.FullName()).Warn("cannot remove corrupted build artifact")
+	}
+	return false
+}
+
+// dedupeCachedArtifact stores the build artifact of a package only once if the local cache holds the same content
+// for another version already, provided the cache supports it
+func dedupeCachedArtifact(localCache cache.LocalCache, p *Package) {
+	dc, ok := localCache.(cache.DedupingCache)
+	if !ok {
+		return
+	}
+	loc, exists := localCache.Location(p)
+	if !exists {
+		return
+	}
+
+	deduped, err := dc.Dedupe(loc)
+	if err != nil {
+		// e.g. because the filesystem does not support hard links
+		log.WithError(err).WithField("package", p.FullName()).Debug("cannot deduplicate build artifact")
+		return
+	}
+	if deduped {
+		log.WithField("package", p.FullName()).Debug("build artifact is identical to one in the cache, storing it once")
+	}
+}
+
+// downloadVerified verifies the artifacts just downloaded from the remote cache and tries to download corrupted
+// ones once more. Returns the packages which are still not available intact afterwards and need to be built.
+// Remote caches which verify artifacts while they download them spare us hashing every artifact once more.
+func downloadVerified(ctx *buildContext, pkgs []*Package) (failed []*Package) {
+	full := !cache.VerifiesDownloads(ctx.RemoteCache)
+	corrupted := func(pkgs []*Package) (res []*Package) {
+		for _, p := range pkgs {
+			loc, exists := ctx.LocalCache.Location(p)
+			if !exists {
+				continue
+			}
+			if !verifyCachedArtifact(p, loc, full) {
+				res = append(res, p)
+			}
+		}
+		return res
+	}
+
+	refetch := corrupted(pkgs)
+	if len(refetch) == 0 {
+		return nil
+	}
+
+	log.WithField("count", len(refetch)).Info("downloading corrupted build artifacts again")
+	err := ctx.RemoteCache.Download(context.Background(), ctx.LocalCache, toPackageInterface(refetch))
+	if err != nil {
+		log.WithError(err).Warn("cannot download corrupted build artifacts again")
+	}
+	// corrupted removes artifacts which are still broken, hence everything which doesn't exist now needs building
+	corrupted(refetch)
+	for _, p := range refetch {
+		if _, exists := ctx.LocalCache.Location(p); !exists {
+			failed = append(failed, p)
+		}
+	}
+	return failed
+}
+
 // Collects the minimal set of packages to download from the remote cache
 // That is, a package will only be downloaded if it is needed to perform a build.
 //
@@ -875,8 +1839,8 @@ func (p *Package) packagesToDownload(inLocalCache map[*Package]struct{}, inRemot
 	// 			as we also need components/devpod-protocol:devpod-schema to be available on disk to perform the build.
 	case YarnPackage, GoPackage:
 		deps = p.GetTransitiveDependencies()
-	// For Generic and Docker packages it is sufficient to have the direct dependencies.
-	case GenericPackage, DockerPackage:
+	// For Generic, Docker, Rust and Python packages it is sufficient to have the direct dependencies.
+	case GenericPackage, DockerPackage, RustPackage, PythonPackage:
 		deps = p.GetDependencies()
 	}
 
@@ -913,6 +1877,9 @@ type packageBuild struct {
 	// This function is guaranteed to be called after the test phase has finished.
 	TestCoverage testCoverageFunc
 
+	// TestPhase is the phase whose output is recorded in the test cache. Defaults to PackageBuildPhaseTest.
+	TestPhase PackageBuildPhase
+
 	// PostProcess is called after all build phases complete but before packaging.
 	// It's used for post-build processing that needs to happen regardless of provenance settings,
 	// such as Docker image extraction.
@@ -1014,7 +1981,7 @@ func (p *Package) buildYarn(buildctx *buildContext, wd, result string) (bld *pac
 		}
 
 		var isTSLibrary bool
-		if deppkg.Type == YarnPackage {
+		if deppkg.Type == YarnPackage && cfg.PackageManager != PackageManagerPnpm {
 			cfg, ok := deppkg.Config.(YarnPkgConfig)
 			if ok && cfg.Packaging == YarnLibrary {
 				isTSLibrary = true
@@ -1022,7 +1989,7 @@ func (p *Package) buildYarn(buildctx *buildContext, wd, result string) (bld *pac
 		}
 		if isTSLibrary {
 			// make previously built package availabe through yarn lock
-			commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], []string{"sh", "-c", fmt.Sprintf("tar Ozfx %s package/%s | sed '/resolved /c\\  resolved \"file://%s\"' >> yarn.lock", builtpkg, pkgYarnLock, builtpkg)})
+			commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], []string{"sh", "-c", fmt.Sprintf("tar Oxf %s package/%s | sed '/resolved /c\\  resolved \"file://%s\"' >> yarn.lock", builtpkg, pkgYarnLock, builtpkg)})
 		} else {
 			untarCmd, err := BuildUnTarCommand(
 				WithInputFile(builtpkg),
@@ -1101,27 +2068,45 @@ func (p *Package) buildYarn(buildctx *buildContext, wd, result string) (bld *pac
 	// and we're just short of running yarn install. Good point to do other prep work.
 	commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], p.PreparationCommands...)
 
-	// The yarn cache cannot handly conccurency proplery and needs to be looked.
-	// Make sure that all our yarn install calls lock the yarn cache.
-	yarnMutex := os.Getenv(EnvvarYarnMutex)
-	if yarnMutex == "" {
-		log.Debugf("%s is not set, defaulting to \"network\"", EnvvarYarnMutex)
-		yarnMutex = "network"
+	var (
+		installCmd []string
+		buildCmd   = []string{"yarn", "build"}
+		testCmd    = []string{"yarn", "test"}
+	)
+	if cfg.PackageManager == PackageManagerPnpm {
+		// Unlike the yarn cache the pnpm store is safe for concurrent use, hence can be shared across builds.
+		pnpmStore := os.Getenv(EnvvarPnpmStoreDir)
+		if pnpmStore == "" {
+			pnpmStore = filepath.Join(buildctx.BuildDir(), "pnpm-store")
+			log.Debugf("%s is not set, defaulting to \"%s\"", EnvvarPnpmStoreDir, pnpmStore)
+		}
+		installCmd = []string{"pnpm", "install", "--store-dir", pnpmStore}
+		buildCmd = []string{"pnpm", "run", "build"}
+		testCmd = []string{"pnpm", "run", "test"}
+	} else {
+		// The yarn cache cannot handly conccurency proplery and needs to be looked.
+		// Make sure that all our yarn install calls lock the yarn cache.
+		yarnMutex := os.Getenv(EnvvarYarnMutex)
+		if yarnMutex == "" {
+			log.Debugf("%s is not set, defaulting to \"network\"", EnvvarYarnMutex)
+			yarnMutex = "network"
+		}
+		yarnCache := filepath.Join(buildctx.BuildDir(), fmt.Sprintf("yarn-cache-%s", buildctx.buildID))
+		installCmd = []string{"yarn", "install", "--mutex", yarnMutex, "--cache-folder", yarnCache}
 	}
-	yarnCache := filepath.Join(buildctx.BuildDir(), fmt.Sprintf("yarn-cache-%s", buildctx.buildID))
 	if len(cfg.Commands.Install) == 0 {
-		commands[PackageBuildPhasePull] = append(commands[PackageBuildPhasePull], []string{"yarn", "install", "--mutex", yarnMutex, "--cache-folder", yarnCache})
+		commands[PackageBuildPhasePull] = append(commands[PackageBuildPhasePull], installCmd)
 	} else {
 		commands[PackageBuildPhasePull] = append(commands[PackageBuildPhasePull], cfg.Commands.Install)
 	}
 	if len(cfg.Commands.Build) == 0 {
-		commands[PackageBuildPhaseBuild] = append(commands[PackageBuildPhaseBuild], []string{"yarn", "build"})
+		commands[PackageBuildPhaseBuild] = append(commands[PackageBuildPhaseBuild], buildCmd)
 	} else {
 		commands[PackageBuildPhaseBuild] = append(commands[PackageBuildPhaseBuild], cfg.Commands.Build)
 	}
 	if !cfg.DontTest && !buildctx.DontTest {
 		if len(cfg.Commands.Test) == 0 {
-			commands[PackageBuildPhaseTest] = append(commands[PackageBuildPhaseTest], []string{"yarn", "test"})
+			commands[PackageBuildPhaseTest] = append(commands[PackageBuildPhaseTest], testCmd)
 		} else {
 			commands[PackageBuildPhaseTest] = append(commands[PackageBuildPhaseTest], cfg.Commands.Test)
 		}
@@ -1157,7 +2142,7 @@ func (p *Package) buildYarn(buildctx *buildContext, wd, result string) (bld *pac
 			BuildTarCommand(
 				WithOutputFile(result),
 				WithWorkingDir("_mirror"),
-				WithCompression(!buildctx.DontCompress),
+				buildctx.archiveCompression(),
 			),
 		}...)
 		resultDir = "_mirror"
@@ -1185,14 +2170,14 @@ func (p *Package) buildYarn(buildctx *buildContext, wd, result string) (bld *pac
 			BuildTarCommand(
 				WithOutputFile(result),
 				WithWorkingDir("_pkg"),
-				WithCompression(!buildctx.DontCompress),
+				buildctx.archiveCompression(),
 			),
 		}...)
 		resultDir = "_pkg"
 	} else if cfg.Packaging == YarnArchive {
 		pkgCommands = append(pkgCommands, BuildTarCommand(
 			WithOutputFile(result),
-			WithCompression(!buildctx.DontCompress),
+			buildctx.archiveCompression(),
 		))
 	} else {
 		return nil, xerrors.Errorf("unknown Yarn packaging: %s", cfg.Packaging)
@@ -1257,13 +2242,25 @@ func (p *Package) buildGo(buildctx *buildContext, wd, result string) (res *packa
 		return nil, xerrors.Errorf("cannot read go.work file: %w", err)
 	}
 
-	var goCommand = "go"
+	var goCommand = []string{"go"}
 	if cfg.GoVersion != "" {
-		goCommand = cfg.GoVersion
+		goCommand = []string{cfg.GoVersion}
 		commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], [][]string{
 			{"sh", "-c", "GO111MODULE=off go get golang.org/dl/" + cfg.GoVersion},
-			{goCommand, "download"},
+			{cfg.GoVersion, "download"},
 		}...)
+	} else {
+		toolchain, err := goModToolchain(filepath.Join(wd, "go.mod"))
+		if err != nil {
+			return nil, err
+		}
+		if toolchain != "" {
+			// make sure we build with the toolchain the module asks for, rather than whatever go happens to be on the PATH
+			goCommand = []string{"env", "GOTOOLCHAIN=" + toolchain, "go"}
+		}
+	}
+	goCmd := func(args ...string) []string {
+		return append(append([]string{}, goCommand...), args...)
 	}
 
 	// We don't check if ephemeral packages in the transitive dependency tree have been built,
@@ -1278,7 +2275,7 @@ func (p *Package) buildGo(buildctx *buildContext, wd, result string) (res *packa
 
 	transdep := p.GetTransitiveDependencies()
 	if len(transdep) > 0 {
-		commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], []string{"mkdir", "_deps"})
+		commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], []string{"mkdir", "-p", "_deps"})
 
 		for _, dep := range transdep {
 			if dep.Ephemeral {
@@ -1291,38 +2288,30 @@ func (p *Package) buildGo(buildctx *buildContext, wd, result string) (res *packa
 			}
 
 			tgt := filepath.Join("_deps", p.BuildLayoutLocation(dep))
-			untarCmd, err := BuildUnTarCommand(
-				WithInputFile(builtpkg),
-				WithTargetDir(tgt),
-				WithAutoDetectCompression(true),
-			)
+			unpackCmds, err := p.unpackCommands(builtpkg, tgt)
 			if err != nil {
 				return nil, err
 			}
-
-			commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], [][]string{
-				{"mkdir", tgt},
-				untarCmd,
-			}...)
+			commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], unpackCmds...)
 
 			if dep.Type != GoPackage {
 				continue
 			}
 
 			if isGoWorkspace {
-				commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], []string{"go", "work", "use", tgt})
+				commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], goCmd("work", "use", tgt))
 			} else {
-				commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], []string{"sh", "-c", fmt.Sprintf("%s mod edit -replace $(cd %s; grep module go.mod | cut -d ' ' -f 2 | head -n1)=./%s", goCommand, tgt, tgt)})
+				commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], []string{"sh", "-c", fmt.Sprintf("%s mod edit -replace $(cd %s; grep module go.mod | cut -d ' ' -f 2 | head -n1)=./%s", strings.Join(goCommand, " "), tgt, tgt)})
 			}
 		}
 	}
 
 	commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], p.PreparationCommands...)
 	if cfg.Generate {
-		commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], []string{goCommand, "generate", "-v", "./..."})
+		commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], goCmd("generate", "-v", "./..."))
 	}
 
-	dlcmd := []string{goCommand, "mod", "download"}
+	dlcmd := goCmd("mod", "download")
 	if log.IsLevelEnabled(log.DebugLevel) {
 		dlcmd = append(dlcmd, "-x")
 	}
@@ -1340,7 +2329,7 @@ func (p *Package) buildGo(buildctx *buildContext, wd, result string) (res *packa
 	}
 	var reportCoverage testCoverageFunc
 	if !cfg.DontTest && !buildctx.DontTest {
-		testCommand := []string{goCommand, "test"}
+		testCommand := goCmd("test")
 		if log.IsLevelEnabled(log.DebugLevel) {
 			testCommand = append(testCommand, "-v")
 		}
@@ -1360,7 +2349,7 @@ func (p *Package) buildGo(buildctx *buildContext, wd, result string) (res *packa
 	if len(cfg.BuildCommand) > 0 {
 		buildCmd = cfg.BuildCommand
 	} else if cfg.Packaging == GoApp {
-		buildCmd = []string{goCommand, "build"}
+		buildCmd = goCmd("build")
 		buildCmd = append(buildCmd, cfg.BuildFlags...)
 		buildCmd = append(buildCmd, ".")
 	}
@@ -1372,7 +2361,7 @@ func (p *Package) buildGo(buildctx *buildContext, wd, result string) (res *packa
 	commands[PackageBuildPhasePackage] = append(commands[PackageBuildPhasePackage],
 		BuildTarCommand(
 			WithOutputFile(result),
-			WithCompression(!buildctx.DontCompress),
+			buildctx.archiveCompression(),
 		),
 	)
 	if !cfg.DontTest && !buildctx.DontTest {
@@ -1387,6 +2376,22 @@ func (p *Package) buildGo(buildctx *buildContext, wd, result string) (res *packa
 	}, nil
 }
 
+// goModToolchain returns the toolchain declared in a go.mod file, or an empty string if there is none
+func goModToolchain(fn string) (string, error) {
+	fc, err := os.ReadFile(fn)
+	if err != nil {
+		return "", err
+	}
+	gomod, err := modfile.Parse(fn, fc, nil)
+	if err != nil {
+		return "", xerrors.Errorf("cannot parse %s: %w", fn, err)
+	}
+	if gomod.Toolchain == nil {
+		return "", nil
+	}
+	return gomod.Toolchain.Name, nil
+}
+
 func collectGoTestCoverage(covfile string) testCoverageFunc {
 	return func() (coverage, funcsWithoutTest, funcsWithTest int, err error) {
 		// We need to collect the coverage for all packages in the module.
@@ -1439,6 +2444,245 @@ func parseGoCoverOutput(input string) (coverage, funcsWithoutTest, funcsWithTest
 	return
 }
 
+const (
+	// pythonEnvScript creates the virtualenv a Python package is built in, unless it exists already.
+	// Environments are shared between all packages with the same requirements and interpreter, hence
+	// we need to make sure concurrent builds don't create the same environment twice.
+	pythonEnvScript = `set -eu
+env="$1"; python="$2"; requirements="$3"
+
+mkdir -p "$(dirname "$env")"
+tries=0
+until mkdir "$env.lock" 2>/dev/null; do
+	tries=$((tries+1))
+	if [ "$tries" -gt 900 ]; then
+		echo "cannot lock $env - remove $env.lock if no other build is running" >&2
+		exit 1
+	fi
+	sleep 1
+done
+trap 'rmdir "$env.lock"' EXIT
+
+if [ ! -f "$env/.complete" ]; then
+	rm -rf "$env"
+	"$python" -m venv "$env"
+	"$env/bin/python" -m pip install --disable-pip-version-check --requirement "$requirements"
+	touch "$env/.complete"
+fi
+`
+
+	// pythonSitePackagesScript copies the site-packages of a virtualenv to the directory given as second argument
+	pythonSitePackagesScript = `set -eu
+cp -a "$("$1" -c 'import sysconfig; print(sysconfig.get_path("purelib"))')" "$2"
+`
+)
+
+// buildPython implements the build process for Python packages.
+// If you change anything in this process that's not backwards compatible, make sure you increment buildProcessVersions accordingly.
+func (p *Package) buildPython(buildctx *buildContext, wd, result string) (res *packageBuild, err error) {
+	cfg, ok := p.Config.(PythonPkgConfig)
+	if !ok {
+		return nil, xerrors.Errorf("package should have Python config")
+	}
+
+	requirements, err := os.ReadFile(filepath.Join(wd, cfg.Requirements))
+	if err != nil {
+		return nil, xerrors.Errorf("cannot read requirements file: %w", err)
+	}
+	var isInstallable bool
+	for _, fn := range []string{"pyproject.toml", "setup.py"} {
+		if _, err := os.Stat(filepath.Join(wd, fn)); err == nil {
+			isInstallable = true
+			break
+		}
+	}
+	if cfg.Packaging == PythonWheel && len(cfg.BuildCommand) == 0 && !isInstallable {
+		return nil, xerrors.Errorf("can only build wheels of Python projects (missing pyproject.toml or setup.py file)")
+	}
+
+	// The environment is keyed on the requirements rather than the package version, s.t. source changes
+	// don't cause a reinstall of all requirements.
+	envHash := sha256.New()
+	fmt.Fprintf(envHash, "%s\n", cfg.Interpreter())
+	envHash.Write(requirements)
+	var (
+		env       = filepath.Join(buildctx.buildDir, "python-env", hex.EncodeToString(envHash.Sum(nil)))
+		python    = filepath.Join(env, "bin", "python")
+		inEnv     = []string{"env", "VIRTUAL_ENV=" + env, "PATH=" + filepath.Join(env, "bin") + ":" + os.Getenv("PATH")}
+		commands  = make(map[PackageBuildPhase][][]string)
+		resultDir string
+	)
+
+	deps := p.GetDependencies()
+	if len(deps) > 0 {
+		commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], []string{"mkdir", "-p", "_deps"})
+	}
+	for _, dep := range deps {
+		builtpkg, ok := buildctx.LocalCache.Location(dep)
+		if !ok {
+			return nil, PkgNotBuiltErr{dep}
+		}
+
+		tgt := filepath.Join("_deps", p.BuildLayoutLocation(dep))
+		unpackCmds, err := p.unpackCommands(builtpkg, tgt)
+		if err != nil {
+			return nil, err
+		}
+		commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], unpackCmds...)
+	}
+	commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], p.PreparationCommands...)
+
+	commands[PackageBuildPhasePull] = append(commands[PackageBuildPhasePull], []string{"sh", "-c", pythonEnvScript, "sh", env, cfg.Interpreter(), cfg.Requirements})
+
+	switch cfg.Packaging {
+	case PythonSitePackages:
+		resultDir = "_site-packages"
+		commands[PackageBuildPhaseBuild] = append(commands[PackageBuildPhaseBuild], []string{"sh", "-c", pythonSitePackagesScript, "sh", python, resultDir})
+		if len(cfg.BuildCommand) > 0 {
+			commands[PackageBuildPhaseBuild] = append(commands[PackageBuildPhaseBuild], append(inEnv, cfg.BuildCommand...))
+		} else if isInstallable {
+			commands[PackageBuildPhaseBuild] = append(commands[PackageBuildPhaseBuild], []string{python, "-m", "pip", "install", "--disable-pip-version-check", "--no-deps", "--target", resultDir, "."})
+		}
+	case PythonWheel:
+		resultDir = "_dist"
+		if len(cfg.BuildCommand) > 0 {
+			commands[PackageBuildPhaseBuild] = append(commands[PackageBuildPhaseBuild], append(inEnv, cfg.BuildCommand...))
+		} else {
+			commands[PackageBuildPhaseBuild] = append(commands[PackageBuildPhaseBuild], []string{python, "-m", "pip", "wheel", "--disable-pip-version-check", "--wheel-dir", resultDir, "--requirement", cfg.Requirements, "."})
+		}
+	default:
+		return nil, xerrors.Errorf("unknown Python packaging: %s", cfg.Packaging)
+	}
+
+	commands[PackageBuildPhasePackage] = append(commands[PackageBuildPhasePackage], BuildTarCommand(
+		WithOutputFile(result),
+		WithWorkingDir(filepath.Join(wd, resultDir)),
+		buildctx.archiveCompression(),
+	))
+
+	return &packageBuild{
+		Commands: commands,
+		PostBuild: func(sources fileset) (subjects []in_toto.Subject, absResultDir string, err error) {
+			absResultDir = filepath.Join(wd, resultDir)
+			postBuild, err := computeFileset(absResultDir)
+			if err != nil {
+				return nil, absResultDir, err
+			}
+			subjects, err = postBuild.Sub(sources).Subjects(absResultDir)
+			return
+		},
+	}, nil
+}
+
+// rustResultDir is the directory within the build dir that the binaries of a Rust package are collected in
+const rustResultDir = "_bin"
+
+// buildRust implements the build process for Rust packages.
+// If you change anything in this process that's not backwards compatible, make sure you increment buildProcessVersions accordingly.
+func (p *Package) buildRust(buildctx *buildContext, wd, result string) (res *packageBuild, err error) {
+	cfg, ok := p.Config.(RustPkgConfig)
+	if !ok {
+		return nil, xerrors.Errorf("package should have Rust config")
+	}
+
+	if _, err := os.Stat(filepath.Join(wd, "Cargo.toml")); os.IsNotExist(err) {
+		return nil, xerrors.Errorf("can only build cargo packages (missing Cargo.toml file)")
+	}
+
+	commands := make(map[PackageBuildPhase][][]string)
+	deps := p.GetDependencies()
+	if len(deps) > 0 {
+		commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], []string{"mkdir", "-p", "_deps"})
+	}
+	for _, dep := range deps {
+		builtpkg, ok := buildctx.LocalCache.Location(dep)
+		if !ok {
+			return nil, PkgNotBuiltErr{dep}
+		}
+
+		tgt := filepath.Join("_deps", p.BuildLayoutLocation(dep))
+		unpackCmds, err := p.unpackCommands(builtpkg, tgt)
+		if err != nil {
+			return nil, err
+		}
+		commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], unpackCmds...)
+	}
+	commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], p.PreparationCommands...)
+
+	// --locked makes cargo fail rather than silently update Cargo.lock, which is part of the package version
+	commands[PackageBuildPhasePull] = append(commands[PackageBuildPhasePull], []string{"cargo", "fetch", "--locked"})
+
+	if !cfg.DontTest && !buildctx.DontTest {
+		commands[PackageBuildPhaseTest] = append(commands[PackageBuildPhaseTest], append([]string{"cargo", "test"}, cfg.cargoFlags()...))
+	}
+
+	buildCmd := append([]string{"cargo", "build"}, cfg.cargoFlags()...)
+	for _, b := range cfg.Binaries {
+		buildCmd = append(buildCmd, "--bin", b)
+	}
+	outputDir := cfg.outputDir()
+	var collectCmd []string
+	if len(cfg.Binaries) > 0 {
+		collectCmd = []string{"cp"}
+		for _, b := range cfg.Binaries {
+			collectCmd = append(collectCmd, filepath.Join(outputDir, b))
+		}
+		collectCmd = append(collectCmd, rustResultDir)
+	} else {
+		// without explicit binaries we take every executable cargo placed at the top of its output directory
+		collectCmd = []string{"find", outputDir, "-maxdepth", "1", "-type", "f", "-perm", "-u+x", "-exec", "cp", "{}", rustResultDir, ";"}
+	}
+	commands[PackageBuildPhaseBuild] = append(commands[PackageBuildPhaseBuild], [][]string{
+		buildCmd,
+		{"mkdir", "-p", rustResultDir},
+		collectCmd,
+	}...)
+
+	commands[PackageBuildPhasePackage] = append(commands[PackageBuildPhasePackage], BuildTarCommand(
+		WithOutputFile(result),
+		WithWorkingDir(filepath.Join(wd, rustResultDir)),
+		buildctx.archiveCompression(),
+	))
+
+	return &packageBuild{
+		Commands: commands,
+		PostBuild: func(sources fileset) (subjects []in_toto.Subject, absResultDir string, err error) {
+			absResultDir = filepath.Join(wd, rustResultDir)
+			postBuild, err := computeFileset(absResultDir)
+			if err != nil {
+				return nil, absResultDir, err
+			}
+			subjects, err = postBuild.Sub(sources).Subjects(absResultDir)
+			return
+		},
+	}, nil
+}
+
+// cargoFlags returns the flags shared by cargo build and cargo test
+func (cfg RustPkgConfig) cargoFlags() []string {
+	// We pin the target dir so that CARGO_TARGET_DIR in the environment cannot move the build results
+	res := []string{"--locked", "--target-dir", "target"}
+	if cfg.Profile == RustRelease {
+		res = append(res, "--release")
+	}
+	if cfg.Target != "" {
+		res = append(res, "--target", cfg.Target)
+	}
+	if cfg.NoDefaultFeatures {
+		res = append(res, "--no-default-features")
+	}
+	if len(cfg.Features) > 0 {
+		res = append(res, "--features", strings.Join(cfg.Features, ","))
+	}
+	return res
+}
+
+// outputDir returns the directory cargo places the build results in, relative to the package build dir
+func (cfg RustPkgConfig) outputDir() string {
+	// cargo only adds the target triple to the path if one was passed explicitly
+	return filepath.Join("target", cfg.Target, string(cfg.Profile))
+}
+
 // buildDocker implements the build process for Docker packages.
 // If you change anything in this process that's not backwards compatible, make sure you increment buildProcessVersions accordingly.
 func (p *Package) buildDocker(buildctx *buildContext, wd, result string) (res *packageBuild, err error) {
@@ -1467,19 +2711,11 @@ func (p *Package) buildDocker(buildctx *buildContext, wd, result string) (res *p
 			return nil, PkgNotBuiltErr{dep}
 		}
 
-		tgt := p.BuildLayoutLocation(dep)
-		untarCmd, err := BuildUnTarCommand(
-			WithInputFile(fn),
-			WithTargetDir(tgt),
-			WithAutoDetectCompression(true),
-		)
+		unpackCmds, err := p.unpackCommands(fn, p.BuildLayoutLocation(dep))
 		if err != nil {
 			return nil, err
 		}
-		commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], [][]string{
-			{"mkdir", tgt},
-			untarCmd,
-		}...)
+		commands[PackageBuildPhasePrep] = append(commands[PackageBuildPhasePrep], unpackCmds...)
 
 		if dep.Type != DockerPackage {
 			continue
@@ -1498,22 +2734,12 @@ func (p *Package) buildDocker(buildctx *buildContext, wd, result string) (res *p
 		return nil, err
 	}
 
-	buildcmd := []string{"docker", "build", "--pull", "-t", version}
-	for arg, val := range cfg.BuildArgs {
-		buildcmd = append(buildcmd, "--build-arg", fmt.Sprintf("%s=%s", arg, val))
-	}
-	for arg, val := range imageDependencies {
-		buildcmd = append(buildcmd, "--build-arg", fmt.Sprintf("DEP_%s=%s", arg, val))
-	}
-	buildcmd = append(buildcmd, "--build-arg", fmt.Sprintf("__GIT_COMMIT=%s", p.C.Git().Commit))
-	if cfg.Squash {
-		buildcmd = append(buildcmd, "--squash")
-	}
-	if buildctx.DockerBuildOptions != nil {
-		for opt, v := range *buildctx.DockerBuildOptions {
-			buildcmd = append(buildcmd, fmt.Sprintf("--%s=%s", opt, v))
-		}
+	if len(cfg.Platforms) > 0 {
+		return p.buildDockerMultiPlatform(buildctx, cfg, wd, result, version, commands, imageDependencies)
 	}
+
+	buildcmd := []string{"docker", "build", "--pull", "-t", version}
+	buildcmd = append(buildcmd, p.dockerBuildFlags(buildctx, cfg, imageDependencies)...)
 	buildcmd = append(buildcmd, ".")
 	commands[PackageBuildPhaseBuild] = append(commands[PackageBuildPhaseBuild], buildcmd)
 
@@ -1604,7 +2830,7 @@ func (p *Package) buildDocker(buildctx *buildContext, wd, result string) (res *p
 		pkgcmds = append(pkgcmds, BuildTarCommand(
 			WithOutputFile(result),
 			WithWorkingDir(containerDir),
-			WithCompression(!buildctx.DontCompress),
+			buildctx.archiveCompression(),
 		))
 
 		commands[PackageBuildPhasePackage] = pkgcmds
@@ -1619,37 +2845,11 @@ func (p *Package) buildDocker(buildctx *buildContext, wd, result string) (res *p
 			}...)
 		}
 
-		// We pushed the image which means we won't export it. We still need to place a marker the build cache.
-		// The proper thing would be to export the image, but that's rather expensive. We'll place a tar file which
-		// contains the names of the image we just pushed instead.
-		for _, img := range cfg.Image {
-			pkgCommands = append(pkgCommands,
-				[]string{"sh", "-c", fmt.Sprintf("echo %s >> %s", img, dockerImageNamesFiles)},
-				[]string{"sh", "-c", fmt.Sprintf("echo built and pushed image: %s", img)},
-			)
-		}
-
-		// Add metadata file with improved error handling
-		metadataContent, err := yaml.Marshal(cfg.Metadata)
+		markerCommands, err := p.pushedImageMarkerCommands(buildctx, cfg, result)
 		if err != nil {
-			return nil, xerrors.Errorf("failed to marshal metadata: %w", err)
-		}
-
-		encodedMetadata := base64.StdEncoding.EncodeToString(metadataContent)
-		pkgCommands = append(pkgCommands, []string{"sh", "-c", fmt.Sprintf("echo %s | base64 -d > %s", encodedMetadata, dockerMetadataFile)})
-
-		// Prepare for packaging
-		sourcePaths := []string{fmt.Sprintf("./%s", dockerImageNamesFiles), fmt.Sprintf("./%s", dockerMetadataFile)}
-		if p.C.W.Provenance.Enabled {
-			sourcePaths = append(sourcePaths, fmt.Sprintf("./%s", provenanceBundleFilename))
+			return nil, err
 		}
-
-		archiveCmd := BuildTarCommand(
-			WithOutputFile(result),
-			WithSourcePaths(sourcePaths...),
-			WithCompression(!buildctx.DontCompress),
-		)
-		pkgCommands = append(pkgCommands, archiveCmd)
+		pkgCommands = append(pkgCommands, markerCommands...)
 
 		commands[PackageBuildPhasePackage] = pkgCommands
 
@@ -1732,8 +2932,162 @@ func (p *Package) buildDocker(buildctx *buildContext, wd, result string) (res *p
 	return res, nil
 }
 
+// dockerBuildFlags returns the build args and options passed to docker build
+func (p *Package) dockerBuildFlags(buildctx *buildContext, cfg DockerPkgConfig, imageDependencies map[string]string) []string {
+	var res []string
+	for arg, val := range cfg.BuildArgs {
+		res = append(res, "--build-arg", fmt.Sprintf("%s=%s", arg, val))
+	}
+	for arg, val := range imageDependencies {
+		res = append(res, "--build-arg", fmt.Sprintf("DEP_%s=%s", arg, val))
+	}
+	res = append(res, "--build-arg", fmt.Sprintf("__GIT_COMMIT=%s", p.C.Git().Commit))
+	if cfg.Squash {
+		res = append(res, "--squash")
+	}
+	if buildctx.DockerBuildOptions != nil {
+		for opt, v := range *buildctx.DockerBuildOptions {
+			res = append(res, fmt.Sprintf("--%s=%s", opt, v))
+		}
+	}
+	return res
+}
+
+// pushedImageMarkerCommands produces the commands which package a Docker build whose images were pushed.
+func (p *Package) pushedImageMarkerCommands(buildctx *buildContext, cfg DockerPkgConfig, result string) ([][]string, error) {
+	var res [][]string
+
+	// We pushed the image which means we won't export it. We still need to place a marker the build cache.
+	// The proper thing would be to export the image, but that's rather expensive. We'll place a tar file which
+	// contains the names of the image we just pushed instead.
+	for _, img := range cfg.Image {
+		res = append(res,
+			[]string{"sh", "-c", fmt.Sprintf("echo %s >> %s", img, dockerImageNamesFiles)},
+			[]string{"sh", "-c", fmt.Sprintf("echo built and pushed image: %s", img)},
+		)
+	}
+
+	// Add metadata file with improved error handling
+	metadataContent, err := yaml.Marshal(cfg.Metadata)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	encodedMetadata := base64.StdEncoding.EncodeToString(metadataContent)
+	res = append(res, []string{"sh", "-c", fmt.Sprintf("echo %s | base64 -d > %s", encodedMetadata, dockerMetadataFile)})
+
+	// Prepare for packaging
+	sourcePaths := []string{fmt.Sprintf("./%s", dockerImageNamesFiles), fmt.Sprintf("./%s", dockerMetadataFile)}
+	if p.C.W.Provenance.Enabled {
+		sourcePaths = append(sourcePaths, fmt.Sprintf("./%s", provenanceBundleFilename))
+	}
+
+	res = append(res, BuildTarCommand(
+		WithOutputFile(result),
+		WithSourcePaths(sourcePaths...),
+		buildctx.archiveCompression(),
+	))
+	return res, nil
+}
+
+// buildxMetadataFile is the name of the file docker buildx writes the build result metadata to
+const buildxMetadataFile = "buildx-metadata.json"
+
+// buildDockerMultiPlatform builds a Docker package for all platforms listed in its config using docker buildx.
+// Multi-platform images cannot be loaded into the local Docker daemon. Hence, they're either pushed, or exported
+// as OCI image archive with an image index for all platforms if the package has no image config.
+func (p *Package) buildDockerMultiPlatform(buildctx *buildContext, cfg DockerPkgConfig, wd, result, version string, commands map[PackageBuildPhase][][]string, imageDependencies map[string]string) (*packageBuild, error) {
+	if cfg.Squash {
+		return nil, xerrors.Errorf("%s: squash is not supported for multi-platform builds", p.FullName())
+	}
+	if out, err := exec.Command("docker", "buildx", "version").CombinedOutput(); err != nil {
+		return nil, xerrors.Errorf("%s builds for %s, which requires docker buildx: %s\n"+
+			"Install the buildx plugin (https://docs.docker.com/build/install-buildx/) or remove the platforms from the package config to build for the host only.",
+			p.FullName(), strings.Join(cfg.Platforms, ", "), strings.TrimSpace(string(out)))
+	}
+
+	buildcmd := []string{"docker", "buildx", "build", "--pull", "--platform", strings.Join(cfg.Platforms, ","), "--metadata-file", buildxMetadataFile}
+	buildcmd = append(buildcmd, p.dockerBuildFlags(buildctx, cfg, imageDependencies)...)
+
+	res := &packageBuild{Commands: commands}
+	if len(cfg.Image) == 0 {
+		containerDir := filepath.Join(wd, "container")
+		buildcmd = append(buildcmd, "--output", fmt.Sprintf("type=oci,dest=%s", filepath.Join(containerDir, "image.tar")), ".")
+		commands[PackageBuildPhaseBuild] = append(commands[PackageBuildPhaseBuild], [][]string{
+			{"mkdir", "-p", containerDir},
+			buildcmd,
+		}...)
+
+		res.PostProcess = func(buildCtx *buildContext, pkg *Package, buildDir string) error {
+			return createDockerMetadataFiles(containerDir, version, cfg.Metadata)
+		}
+		res.PostBuild = func(sources fileset) (subj []in_toto.Subject, absResultDir string, err error) {
+			postBuild, err := computeFileset(containerDir)
+			if err != nil {
+				return nil, containerDir, xerrors.Errorf("failed to compute fileset: %w", err)
+			}
+			subj, err = postBuild.Sub(sources).Subjects(containerDir)
+			return subj, containerDir, err
+		}
+		commands[PackageBuildPhasePackage] = append(commands[PackageBuildPhasePackage], BuildTarCommand(
+			WithOutputFile(result),
+			WithWorkingDir(containerDir),
+			buildctx.archiveCompression(),
+		))
+		return res, nil
+	}
+
+	for _, img := range cfg.Image {
+		buildcmd = append(buildcmd, "-t", img)
+	}
+	buildcmd = append(buildcmd, "--push", ".")
+	commands[PackageBuildPhaseBuild] = append(commands[PackageBuildPhaseBuild], buildcmd)
+
+	pkgCommands, err := p.pushedImageMarkerCommands(buildctx, cfg, result)
+	if err != nil {
+		return nil, err
+	}
+	commands[PackageBuildPhasePackage] = pkgCommands
+
+	res.Subjects = func() ([]in_toto.Subject, error) {
+		digest, err := readBuildxDigest(filepath.Join(wd, buildxMetadataFile))
+		if err != nil {
+			return nil, err
+		}
+		result := make([]in_toto.Subject, 0, len(cfg.Image))
+		for _, tag := range cfg.Image {
+			result = append(result, in_toto.Subject{
+				Name:   tag,
+				Digest: digest,
+			})
+		}
+		return result, nil
+	}
+	return res, nil
+}
+
+// readBuildxDigest reads the digest of the image (index) docker buildx built from its metadata file
+func readBuildxDigest(fn string) (common.DigestSet, error) {
+	fc, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot read buildx metadata: %w", err)
+	}
+	var md struct {
+		Digest string `json:"containerimage.digest"`
+	}
+	err = json.Unmarshal(fc, &md)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot unmarshal buildx metadata: %w", err)
+	}
+	algo, hash, ok := strings.Cut(md.Digest, ":")
+	if !ok || hash == "" {
+		return nil, xerrors.Errorf("buildx metadata contains no image digest")
+	}
+	return common.DigestSet{algo: hash}, nil
+}
+
 // extractImageNameFromCache extracts the Docker image name of a previously built package
-// from the cache tar.gz file of that package.
+// from the cached build artifact of that package.
 func extractImageNameFromCache(pkgName, cacheBundleFN string) (imgname string, err error) {
 	defer func() {
 		if err != nil {
@@ -1741,19 +3095,13 @@ func extractImageNameFromCache(pkgName, cacheBundleFN string) (imgname string, e
 		}
 	}()
 
-	f, err := os.Open(cacheBundleFN)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-
-	gzin, err := gzip.NewReader(f)
+	in, err := openArchive(cacheBundleFN)
 	if err != nil {
 		return "", err
 	}
-	defer gzin.Close()
+	defer in.Close()
 
-	tarin := tar.NewReader(gzin)
+	tarin := tar.NewReader(in)
 	for {
 		hdr, err := tarin.Next()
 		if errors.Is(err, io.EOF) {
@@ -1805,20 +3153,11 @@ func (p *Package) buildGeneric(buildctx *buildContext, wd, result string) (res *
 				return nil, PkgNotBuiltErr{dep}
 			}
 
-			tgt := p.BuildLayoutLocation(dep)
-
-			untarCmd, err := BuildUnTarCommand(
-				WithInputFile(fn),
-				WithTargetDir(tgt),
-				WithAutoDetectCompression(true),
-			)
+			unpackCmds, err := p.unpackCommands(fn, p.BuildLayoutLocation(dep))
 			if err != nil {
 				return nil, err
 			}
-			commands = append(commands, [][]string{
-				{"mkdir", tgt},
-				untarCmd,
-			}...)
+			commands = append(commands, unpackCmds...)
 		}
 
 		// Use buildTarCommand directly which will handle compression internally
@@ -1827,7 +3166,7 @@ func (p *Package) buildGeneric(buildctx *buildContext, wd, result string) (res *
 			tarCmd = BuildTarCommand(
 				WithOutputFile(result),
 				WithSourcePaths(fmt.Sprintf("./%s", provenanceBundleFilename)),
-				WithCompression(!buildctx.DontCompress),
+				buildctx.archiveCompression(),
 			)
 			return &packageBuild{
 				Commands: map[PackageBuildPhase][][]string{
@@ -1838,6 +3177,10 @@ func (p *Package) buildGeneric(buildctx *buildContext, wd, result string) (res *
 		}
 
 		if len(commands) > 0 {
+			tarCmd = BuildTarCommand(
+				WithOutputFile(result),
+				buildctx.archiveCompression(),
+			)
 			return &packageBuild{
 				Commands: map[PackageBuildPhase][][]string{
 					PackageBuildPhaseBuild:   commands,
@@ -1848,8 +3191,9 @@ func (p *Package) buildGeneric(buildctx *buildContext, wd, result string) (res *
 
 		// Truly empty package with no dependencies
 		tarCmd = BuildTarCommand(
+			WithOutputFile(result),
 			WithFilesFrom("/dev/null"),
-			WithCompression(!buildctx.DontCompress),
+			buildctx.archiveCompression(),
 		)
 
 		return &packageBuild{
@@ -1866,27 +3210,20 @@ func (p *Package) buildGeneric(buildctx *buildContext, wd, result string) (res *
 			return nil, PkgNotBuiltErr{dep}
 		}
 
-		tgt := p.BuildLayoutLocation(dep)
-
-		untarCmd, err := BuildUnTarCommand(
-			WithInputFile(fn),
-			WithTargetDir(tgt),
-			WithAutoDetectCompression(true),
-		)
+		unpackCmds, err := p.unpackCommands(fn, p.BuildLayoutLocation(dep))
 		if err != nil {
 			return nil, err
 		}
-
-		commands = append(commands, [][]string{
-			{"mkdir", tgt},
-			untarCmd,
-		}...)
+		commands = append(commands, unpackCmds...)
 	}
 
 	commands = append(commands, p.PreparationCommands...)
 	commands = append(commands, cfg.Commands...)
-	if !cfg.DontTest && !buildctx.DontTest {
+	var testPhase PackageBuildPhase
+	if !cfg.DontTest && !buildctx.DontTest && len(cfg.Test) > 0 {
 		commands = append(commands, cfg.Test...)
+		// the tests run after the commands they test, hence the test cache records the output of the build phase
+		testPhase = PackageBuildPhaseBuild
 	}
 
 	return &packageBuild{
@@ -1895,28 +3232,29 @@ func (p *Package) buildGeneric(buildctx *buildContext, wd, result string) (res *
 			PackageBuildPhasePackage: {
 				BuildTarCommand(
 					WithOutputFile(result),
-					WithCompression(!buildctx.DontCompress),
+					buildctx.archiveCompression(),
 				),
 			},
 		},
+		TestPhase: testPhase,
 	}, nil
 }
 
-func executeCommandsForPackage(buildctx *buildContext, p *Package, wd string, commands [][]string) error {
+func executeCommandsForPackage(ctx context.Context, buildctx *buildContext, p *Package, wd string, commands [][]string) error {
 	if len(commands) == 0 {
 		return nil
 	}
 	if buildctx.JailedExecution {
-		return executeCommandsForPackageSafe(buildctx, p, wd, commands)
+		return executeCommandsForPackageSafe(ctx, buildctx, p, wd, commands)
 	}
 
-	env := append(os.Environ(), p.Environment...)
+	env := append(p.hostEnvironment(buildctx.Hermetic), p.Environment...)
 	env = append(env, fmt.Sprintf("BLAZEDOCK_WORKSPACE_ROOT=%s", p.C.W.Origin))
 	for _, cmd := range commands {
 		if len(cmd) == 0 {
 			continue // Skip empty commands
 		}
-		err := run(buildctx.Reporter, p, env, wd, cmd[0], cmd[1:]...)
+		err := run(ctx, buildctx.Reporter, p, env, wd, cmd[0], cmd[1:]...)
 		if err != nil {
 			return err
 		}
@@ -1924,21 +3262,147 @@ func executeCommandsForPackage(buildctx *buildContext, p *Package, wd string, co
 	return nil
 }
 
-func run(rep Reporter, p *Package, env []string, cwd, name string, args ...string) error {
+// runPreBuildCommands runs the pre-build commands of a package in its build directory, after the sources were copied.
+// The package version is computed before, hence the commands only get to see what's part of the version:
+// the sources, the package definition and the values of the argdeps, which are available as environment variables.
+func runPreBuildCommands(ctx context.Context, buildctx *buildContext, p *Package, builddir string) error {
+	if len(p.PreBuildCommands) == 0 {
+		return nil
+	}
+
+	env := append(p.hostEnvironment(buildctx.Hermetic), p.Environment...)
+	env = append(env, fmt.Sprintf("BLAZEDOCK_WORKSPACE_ROOT=%s", p.C.W.Origin))
+	for _, argdep := range p.ArgumentDependencies {
+		// argument dependencies have been resolved to "<name>: <value>" when the workspace was loaded
+		name, value, ok := strings.Cut(argdep, ": ")
+		if !ok || value == "<not-set>" {
+			continue
+		}
+		env = append(env, fmt.Sprintf("%s=%s", name, value))
+	}
+	return runHookCommands(ctx, buildctx, p, "pre-build", builddir, env, p.PreBuildCommands)
+}
+
+// runPostBuildCommands runs the post-build commands of a package in its component directory.
+// The commands learn about the package and its build result through environment variables.
+func runPostBuildCommands(ctx context.Context, buildctx *buildContext, p *Package, result string) error {
+	if len(p.PostBuildCommands) == 0 {
+		return nil
+	}
+
+	version, err := p.Version()
+	if err != nil {
+		return err
+	}
+	env := append(p.hostEnvironment(buildctx.Hermetic), p.Environment...)
+	env = append(env,
+		fmt.Sprintf("BLAZEDOCK_WORKSPACE_ROOT=%s", p.C.W.Origin),
+		fmt.Sprintf("BLAZEDOCK_PACKAGE=%s", p.FullName()),
+		fmt.Sprintf("BLAZEDOCK_PACKAGE_VERSION=%s", version),
+		fmt.Sprintf("BLAZEDOCK_ARTIFACT=%s", result),
+	)
+	return runHookCommands(ctx, buildctx, p, "post-build", p.C.Origin, env, p.PostBuildCommands)
+}
+
+// runStagedPostBuildCommands moves a fresh build result out of the cache while the post-build commands run on it.
+// The result only enters the cache again if all commands succeed, s.t. a failed command never leaves a half-processed
+// result behind.
+func runStagedPostBuildCommands(ctx context.Context, buildctx *buildContext, p *Package, artifact string) error {
+	if len(p.PostBuildCommands) == 0 {
+		return nil
+	}
+
+	// staging next to the artifact keeps the renames on the same filesystem
+	stagingDir, err := os.MkdirTemp(filepath.Dir(artifact), ".postbuild-*")
+	if err != nil {
+		return xerrors.Errorf("cannot stage build result for post-build commands: %w", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	staged := filepath.Join(stagingDir, filepath.Base(artifact))
+	if err := os.Rename(artifact, staged); err != nil {
+		return xerrors.Errorf("cannot stage build result for post-build commands: %w", err)
+	}
+	if err := runPostBuildCommands(ctx, buildctx, p, staged); err != nil {
+		return err
+	}
+	return os.Rename(staged, artifact)
+}
+
+// runCachedPostBuildCommands runs the post-build commands on a copy of a cached build result. The cached result
+// may be shared with other artifacts and its checksum is recorded already, hence the commands must never see it.
+func runCachedPostBuildCommands(ctx context.Context, buildctx *buildContext, p *Package, artifact string) error {
+	if len(p.PostBuildCommands) == 0 {
+		return nil
+	}
+
+	tmpdir, err := os.MkdirTemp("", "blazedock-postbuild-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	cpy := filepath.Join(tmpdir, filepath.Base(artifact))
+	if err := copyFile(artifact, cpy, 0644); err != nil {
+		return xerrors.Errorf("cannot copy build result for post-build commands: %w", err)
+	}
+	return runPostBuildCommands(ctx, buildctx, p, cpy)
+}
+
+func runHookCommands(ctx context.Context, buildctx *buildContext, p *Package, kind, wd string, env []string, commands [][]string) error {
+	for _, cmd := range commands {
+		if len(cmd) == 0 {
+			continue
+		}
+		err := run(ctx, buildctx.Reporter, p, env, wd, cmd[0], cmd[1:]...)
+		if err != nil {
+			return xerrors.Errorf("%s command \"%s\" failed: %w", kind, strings.Join(cmd, " "), err)
+		}
+	}
+	return nil
+}
+
+// run executes a command. If ctx has a deadline, the command runs in a process group of its own which is killed
+// as a whole once the deadline passes, s.t. no process the command started lingers.
+func run(ctx context.Context, rep Reporter, p *Package, env []string, cwd, name string, args ...string) error {
 	log.WithField("package", p.FullName()).WithField("command", strings.Join(append([]string{name}, args...), " ")).Debug("running")
 
-	cmd := exec.Command(name, args...)
+	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Stdout = &reporterStream{R: rep, P: p, IsErr: false}
 	cmd.Stderr = &reporterStream{R: rep, P: p, IsErr: true}
 	cmd.Dir = cwd
 	cmd.Env = env
-	err := cmd.Run()
+	if _, ok := ctx.Deadline(); !ok {
+		return cmd.Run()
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// processes which left the process group may hold on to stdout and stderr
+	cmd.WaitDelay = 5 * time.Second
 
+	err := cmd.Start()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// Processes in a group of their own don't get the signals sent to blazedock's group, e.g. when hitting Ctrl+C
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case sig := <-sigs:
+			_ = syscall.Kill(-cmd.Process.Pid, sig.(syscall.Signal))
+		case <-done:
+		}
+	}()
+
+	return cmd.Wait()
 }
 
 type reporterStream struct {
@@ -1,6 +1,7 @@
 package blazedock
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -25,6 +26,6 @@ func checkForCpCompatibility() error {
 	return fmt.Errorf("blazedock requires a GNU-compatible cp. Please install using `brew install coreutils`; make sure you update your PATH after installing.")
 }
 
-func executeCommandsForPackageSafe(buildctx *buildContext, p *Package, wd string, commands [][]string) error {
+func executeCommandsForPackageSafe(ctx context.Context, buildctx *buildContext, p *Package, wd string, commands [][]string) error {
 	return fmt.Errorf("not implemented")
 }
@@ -1,9 +1,24 @@
 package blazedock
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache/local"
 )
 
 func TestParseGoCoverOutput(t *testing.T) {
@@ -53,3 +68,1133 @@ func TestParseGoCoverOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildLockPropagatesFailure(t *testing.T) {
+	ctx := &buildContext{
+		pkgLockCond:  sync.NewCond(&sync.Mutex{}),
+		pkgLocks:     make(map[string]struct{}),
+		pkgBuildErrs: make(map[string]error),
+	}
+	pkg := &Package{C: &Component{Name: "comp"}, PackageInternal: PackageInternal{Name: "pkg"}}
+
+	if !ctx.ObtainBuildLock(pkg) {
+		t.Fatal("expected to obtain the build lock")
+	}
+
+	buildErr := errors.New("build failed")
+	var (
+		wg      sync.WaitGroup
+		waitErr error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if ctx.ObtainBuildLock(pkg) {
+			t.Error("obtained the build lock while the package was being built")
+			return
+		}
+		waitErr = ctx.BuildError(pkg)
+	}()
+	ctx.ReleaseBuildLock(pkg, buildErr)
+	wg.Wait()
+
+	if waitErr != buildErr {
+		t.Errorf("waiting build returned %v, expected %v", waitErr, buildErr)
+	}
+	if ctx.ObtainBuildLock(pkg) {
+		t.Error("obtained the build lock for a package which failed to build already")
+	}
+}
+
+func TestCheckBuildableOffline(t *testing.T) {
+	comp := &Component{Name: "comp"}
+	var (
+		generic = &Package{C: comp, PackageInternal: PackageInternal{Name: "generic", Type: GenericPackage}, Config: GenericPkgConfig{}}
+		docker  = &Package{C: comp, PackageInternal: PackageInternal{Name: "docker", Type: DockerPackage}, Config: DockerPkgConfig{}}
+		pushing = &Package{C: comp, PackageInternal: PackageInternal{Name: "pushing", Type: DockerPackage}, Config: DockerPkgConfig{Image: []string{"registry.example.com/app:latest"}}}
+	)
+
+	tests := []struct {
+		Name        string
+		Packages    []*Package
+		Expectation string
+	}{
+		{
+			Name:     "buildable",
+			Packages: []*Package{generic},
+		},
+		{
+			Name:        "pulls base images",
+			Packages:    []*Package{docker, generic},
+			Expectation: "offline build is impossible: the following packages are neither in the local cache nor buildable offline:\n  comp:docker (docker build pulls base images)",
+		},
+		{
+			Name:        "pushes images",
+			Packages:    []*Package{pushing, docker, generic},
+			Expectation: "offline build is impossible: the following packages are neither in the local cache nor buildable offline:\n  comp:docker (docker build pulls base images)\n  comp:pushing (pushes images to registry.example.com/app:latest)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var act string
+			if err := checkBuildableOffline(test.Packages); err != nil {
+				act = err.Error()
+			}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("checkBuildableOffline() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReadBuildxDigest(t *testing.T) {
+	type Expectation struct {
+		Error  bool
+		Digest common.DigestSet
+	}
+	tests := []struct {
+		Name        string
+		Metadata    string
+		Expectation Expectation
+	}{
+		{
+			Name:        "index digest",
+			Metadata:    `{"buildx.build.ref": "builder/builder0/abc", "containerimage.digest": "sha256:2b4a5bb2c1e4f5b6"}`,
+			Expectation: Expectation{Digest: common.DigestSet{"sha256": "2b4a5bb2c1e4f5b6"}},
+		},
+		{
+			Name:        "no digest",
+			Metadata:    `{"buildx.build.ref": "builder/builder0/abc"}`,
+			Expectation: Expectation{Error: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), buildxMetadataFile)
+			err := os.WriteFile(fn, []byte(test.Metadata), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var act Expectation
+			act.Digest, err = readBuildxDigest(fn)
+			act.Error = err != nil
+
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("readBuildxDigest() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTimingReport(t *testing.T) {
+	var (
+		comp     = &Component{Name: "comp"}
+		built    = &Package{C: comp, PackageInternal: PackageInternal{Name: "built"}}
+		slow     = &Package{C: comp, PackageInternal: PackageInternal{Name: "slow"}}
+		failed   = &Package{C: comp, PackageInternal: PackageInternal{Name: "failed"}}
+		local    = &Package{C: comp, PackageInternal: PackageInternal{Name: "local"}}
+		download = &Package{C: comp, PackageInternal: PackageInternal{Name: "download"}}
+	)
+
+	rep := newTimingReport()
+	rep.recordLookup(local, 2*time.Millisecond)
+	rep.recordLookup(download, time.Millisecond)
+	rep.recordCacheStatus(map[*Package]PackageBuildStatus{
+		local:    PackageBuilt,
+		download: PackageDownloaded,
+		built:    PackageNotBuiltYet,
+	})
+	rep.recordBuild(built, 3*time.Second, nil)
+	rep.recordBuild(slow, time.Minute, nil)
+	rep.recordBuild(failed, time.Second, errors.New("build failed"))
+	rep.finish()
+
+	expectation := []TimingReportEntry{
+		{Package: "comp:slow", Status: TimingStatusBuilt, Duration: time.Minute},
+		{Package: "comp:built", Status: TimingStatusBuilt, Duration: 3 * time.Second},
+		{Package: "comp:failed", Status: TimingStatusFailed, Duration: time.Second},
+		{Package: "comp:local", Status: TimingStatusLocalHit, Duration: 2 * time.Millisecond},
+		{Package: "comp:download", Status: TimingStatusRemoteHit, Duration: time.Millisecond},
+	}
+	if diff := cmp.Diff(expectation, rep.Entries); diff != "" {
+		t.Errorf("TimingReport mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuildIndependentOfCheckoutLocation(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reproducible archives require GNU tar")
+	}
+	t.Setenv(EnvvarBuildDir, t.TempDir())
+
+	files := map[string]string{
+		"WORKSPACE.yaml": "",
+		"comp/lib.txt":   "hello world\n",
+		"comp/BUILD.yaml": `packages:
+- name: lib
+  type: generic
+  srcs:
+  - lib.txt
+  config:
+    commands:
+    - ["sh", "-c", "tr a-z A-Z < lib.txt > lib.out"]
+- name: app
+  type: generic
+  deps:
+  - :lib
+  layout:
+    :lib: /vendor
+  config:
+    commands:
+    - ["sh", "-c", "cp vendor/lib.out app.out && pwd > workdir.txt"]
+`,
+	}
+	build := func() (version, digest string) {
+		checkout := t.TempDir()
+		for fn, content := range files {
+			fn = filepath.Join(checkout, fn)
+			err := os.MkdirAll(filepath.Dir(fn), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.WriteFile(fn, []byte(content), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		ws, err := FindWorkspace(checkout, Arguments{}, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg, ok := ws.Packages["comp:app"]
+		if !ok {
+			t.Fatal("package comp:app does not exist")
+		}
+		lc, err := local.NewFilesystemCache(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Build(pkg, WithLocalCache(lc), WithReporter(&NoopReporter{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		version, err = pkg.Version()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn, exists := lc.Location(pkg)
+		if !exists {
+			t.Fatal("build did not produce an artifact")
+		}
+		fc, err := os.ReadFile(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(fc)
+		return version, hex.EncodeToString(sum[:])
+	}
+
+	versionA, digestA := build()
+	// the second build runs later, hence file modification times differ as well
+	time.Sleep(1100 * time.Millisecond)
+	versionB, digestB := build()
+	if versionA != versionB {
+		t.Errorf("version differs between checkouts: %s != %s", versionA, versionB)
+	}
+	if digestA != digestB {
+		t.Errorf("artifact digest differs between checkouts: %s != %s", digestA, digestB)
+	}
+}
+
+func TestBuildCompression(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Package        string
+		DontCompress   bool
+		ExpectedSuffix string
+	}{
+		{Name: "compressed", Package: "comp:lib", ExpectedSuffix: ".tar.gz"},
+		{Name: "uncompressed", Package: "comp:lib", DontCompress: true, ExpectedSuffix: ".tar"},
+		{Name: "empty", Package: "comp:empty", ExpectedSuffix: ".tar.gz"},
+		{Name: "dependencies only", Package: "comp:deps-only", DontCompress: true, ExpectedSuffix: ".tar"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			t.Setenv(EnvvarBuildDir, t.TempDir())
+
+			loc := t.TempDir()
+			for fn, content := range map[string]string{
+				"WORKSPACE.yaml": "",
+				"comp/lib.txt":   "hello world\n",
+				"comp/BUILD.yaml": `packages:
+- name: lib
+  type: generic
+  srcs:
+  - lib.txt
+  config:
+    commands:
+    - ["sh", "-c", "cp lib.txt lib.out"]
+- name: empty
+  type: generic
+- name: deps-only
+  type: generic
+  deps:
+  - :lib
+`,
+			} {
+				fn = filepath.Join(loc, fn)
+				if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ws, err := FindWorkspace(loc, Arguments{}, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkg, ok := ws.Packages[test.Package]
+			if !ok {
+				t.Fatalf("package %s does not exist", test.Package)
+			}
+			lc, err := local.NewFilesystemCache(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = Build(pkg, WithLocalCache(lc), WithReporter(&NoopReporter{}), WithCompressionDisabled(test.DontCompress))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fn, exists := lc.Location(pkg)
+			if !exists {
+				t.Fatal("build did not produce an artifact")
+			}
+			if !strings.HasSuffix(fn, test.ExpectedSuffix) {
+				t.Errorf("artifact %s does not end in %s", fn, test.ExpectedSuffix)
+			}
+			if err := cache.VerifyChecksum(fn, true); err != nil {
+				t.Errorf("artifact checksum was not recorded: %v", err)
+			}
+		})
+	}
+}
+
+func TestPostBuildCommands(t *testing.T) {
+	t.Setenv(EnvvarBuildDir, t.TempDir())
+
+	const buildYAML = `packages:
+- name: lib
+  type: generic
+  srcs:
+  - lib.txt
+  config:
+    commands:
+    - ["sh", "-c", "cp lib.txt lib.out"]
+  postBuild:
+  - ["sh", "-c", "test -f $BLAZEDOCK_ARTIFACT && echo $BLAZEDOCK_PACKAGE $(basename $BLAZEDOCK_ARTIFACT) >> hooks.log"]
+`
+	var (
+		loc     = t.TempDir()
+		hookLog = filepath.Join(loc, "comp", "hooks.log")
+	)
+	writeFiles := func(files map[string]string) {
+		for fn, content := range files {
+			fn = filepath.Join(loc, fn)
+			err := os.MkdirAll(filepath.Dir(fn), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.WriteFile(fn, []byte(content), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	writeFiles(map[string]string{
+		"WORKSPACE.yaml":  "",
+		"comp/lib.txt":    "hello world\n",
+		"comp/BUILD.yaml": buildYAML,
+	})
+
+	lc, err := local.NewFilesystemCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	build := func() (version string, err error) {
+		ws, err := FindWorkspace(loc, Arguments{}, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg, ok := ws.Packages["comp:lib"]
+		if !ok {
+			t.Fatal("package comp:lib does not exist")
+		}
+		version, err = pkg.Version()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return version, Build(pkg, WithLocalCache(lc), WithReporter(&NoopReporter{}))
+	}
+	hookRuns := func() int {
+		fc, err := os.ReadFile(hookLog)
+		if os.IsNotExist(err) {
+			return 0
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Count(string(fc), "\n")
+	}
+
+	version, err := build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the hook only logs if the artifact it's given exists
+	if fc, _ := os.ReadFile(hookLog); string(fc) != "comp:lib "+version+".tar.gz\n" {
+		t.Errorf("unexpected post-build command output: %q", fc)
+	}
+
+	// a cache hit does not run the post-build commands
+	_, err = build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := hookRuns(); n != 1 {
+		t.Errorf("expected the post-build commands to run once, ran %d times", n)
+	}
+
+	// post-build commands do not change the version, hence this is a cache hit as well
+	writeFiles(map[string]string{"comp/BUILD.yaml": buildYAML + "  postBuildAlways: true\n"})
+	alwaysVersion, err := build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alwaysVersion != version {
+		t.Errorf("post-build commands changed the version: %s != %s", alwaysVersion, version)
+	}
+	if n := hookRuns(); n != 2 {
+		t.Errorf("expected the post-build commands to run twice, ran %d times", n)
+	}
+
+	// post-build commands of a cache hit run on a copy, s.t. they cannot alter the cached build result
+	writeFiles(map[string]string{"comp/BUILD.yaml": buildYAML + "  - [\"sh\", \"-c\", \"echo tampered >> $BLAZEDOCK_ARTIFACT\"]\n  postBuildAlways: true\n"})
+	_, err = build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.VerifyChecksum(filepath.Join(lc.Origin, version+".tar.gz"), true); err != nil {
+		t.Errorf("post-build commands altered the cached build result: %v", err)
+	}
+
+	writeFiles(map[string]string{"comp/BUILD.yaml": buildYAML + "  - [\"false\"]\n  postBuildAlways: true\n"})
+	_, err = build()
+	if err == nil {
+		t.Errorf("expected a failing post-build command to fail the build")
+	}
+
+	// a fresh build whose post-build commands fail leaves nothing in the cache
+	writeFiles(map[string]string{
+		"comp/lib.txt":    "hello again\n",
+		"comp/BUILD.yaml": buildYAML + "  - [\"false\"]\n",
+	})
+	failedVersion, err := build()
+	if err == nil {
+		t.Errorf("expected a failing post-build command to fail the build")
+	}
+	for _, ptn := range []string{failedVersion + "*", ".postbuild-*"} {
+		leftovers, err := filepath.Glob(filepath.Join(lc.Origin, ptn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(leftovers) > 0 {
+			t.Errorf("failed post-build commands left %v in the cache", leftovers)
+		}
+	}
+}
+
+func TestPreBuildCommands(t *testing.T) {
+	t.Setenv(EnvvarBuildDir, t.TempDir())
+
+	files := map[string]string{
+		"WORKSPACE.yaml":    "",
+		"comp/template.txt": "NAME world\n",
+		"comp/BUILD.yaml": `packages:
+- name: gen
+  type: generic
+  srcs:
+  - template.txt
+  argdeps:
+  - greeting
+  preBuild:
+  - ["sh", "-c", "sed \"s/NAME/$greeting/\" template.txt > gen.txt"]
+  config:
+    commands:
+    - ["sh", "-c", "cp gen.txt $BLAZEDOCK_WORKSPACE_ROOT/built.txt"]
+`,
+	}
+	loc := t.TempDir()
+	for fn, content := range files {
+		fn = filepath.Join(loc, fn)
+		err := os.MkdirAll(filepath.Dir(fn), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(fn, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lc, err := local.NewFilesystemCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	build := func(greeting string) (version string) {
+		ws, err := FindWorkspace(loc, Arguments{"greeting": greeting}, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg, ok := ws.Packages["comp:gen"]
+		if !ok {
+			t.Fatal("package comp:gen does not exist")
+		}
+		version, err = pkg.Version()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Build(pkg, WithLocalCache(lc), WithReporter(&NoopReporter{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return version
+	}
+
+	hello := build("hello")
+	fc, err := os.ReadFile(filepath.Join(loc, "built.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("hello world\n", string(fc)); diff != "" {
+		t.Errorf("generated file mismatch (-want +got):\n%s", diff)
+	}
+	// pre-build commands run in the build directory and leave the component's sources untouched
+	if _, err := os.Stat(filepath.Join(loc, "comp", "gen.txt")); !os.IsNotExist(err) {
+		t.Errorf("pre-build command modified the component directory")
+	}
+
+	// the version is computed before the pre-build commands run, but covers all their inputs
+	if hello != build("hello") {
+		t.Errorf("version changed by running the pre-build commands")
+	}
+	if hello == build("bonjour") {
+		t.Errorf("version does not depend on the arguments available to the pre-build commands")
+	}
+}
+
+func TestBuildFailFast(t *testing.T) {
+	t.Setenv(EnvvarBuildDir, t.TempDir())
+
+	files := map[string]string{
+		"WORKSPACE.yaml": "",
+		"comp/src.txt":   "hello world\n",
+		"comp/BUILD.yaml": `packages:
+- name: broken
+  type: generic
+  srcs:
+  - src.txt
+  config:
+    commands:
+    - ["false"]
+- name: ok
+  type: generic
+  srcs:
+  - src.txt
+  config:
+    commands:
+    - ["cp", "src.txt", "ok.txt"]
+- name: downstream
+  type: generic
+  srcs:
+  - src.txt
+  deps:
+  - :broken
+  config:
+    commands:
+    - ["cp", "src.txt", "downstream.txt"]
+- name: all
+  type: generic
+  srcs:
+  - src.txt
+  deps:
+  - :ok
+  - :downstream
+  config:
+    commands:
+    - ["cp", "src.txt", "all.txt"]
+`,
+	}
+	loc := t.TempDir()
+	for fn, content := range files {
+		fn = filepath.Join(loc, fn)
+		err := os.MkdirAll(filepath.Dir(fn), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(fn, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, failFast := range []bool{true, false} {
+		t.Run(fmt.Sprintf("fail-fast=%v", failFast), func(t *testing.T) {
+			ws, err := FindWorkspace(loc, Arguments{}, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			lc, err := local.NewFilesystemCache(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = Build(ws.Packages["comp:all"], WithLocalCache(lc), WithReporter(&NoopReporter{}), WithFailFast(failFast))
+			var failures *BuildFailedErr
+			if !errors.As(err, &failures) {
+				t.Fatalf("expected a BuildFailedErr, got %v", err)
+			}
+			var failed []string
+			for name := range failures.Failed {
+				failed = append(failed, name)
+			}
+			if diff := cmp.Diff([]string{"comp:broken"}, failed); diff != "" {
+				t.Errorf("failed packages mismatch (-want +got):\n%s", diff)
+			}
+			if _, ok := failures.Skipped["comp:all"]; !ok {
+				t.Errorf("expected comp:all to be skipped, got %v", failures.Skipped)
+			}
+			if failFast {
+				return
+			}
+
+			if diff := cmp.Diff([]string{"comp:broken"}, failures.Skipped["comp:downstream"].FailedDependencies); diff != "" {
+				t.Errorf("comp:downstream failed dependencies mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff([]string{"comp:broken"}, failures.Skipped["comp:all"].FailedDependencies); diff != "" {
+				t.Errorf("comp:all failed dependencies mismatch (-want +got):\n%s", diff)
+			}
+			if _, built := lc.Location(ws.Packages["comp:ok"]); !built {
+				t.Errorf("comp:ok was not built although its dependencies succeeded")
+			}
+		})
+	}
+}
+
+func TestBuildAll(t *testing.T) {
+	t.Setenv(EnvvarBuildDir, t.TempDir())
+
+	loc := t.TempDir()
+	for fn, content := range map[string]string{
+		"WORKSPACE.yaml": "",
+		"comp/src.txt":   "hello world\n",
+		"comp/BUILD.yaml": `packages:
+- name: shared
+  type: generic
+  srcs:
+  - src.txt
+  config:
+    commands:
+    - ["sh", "-c", "echo built >> ` + filepath.Join(loc, "shared.log") + `"]
+- name: broken
+  type: generic
+  deps:
+  - :shared
+  config:
+    commands:
+    - ["false"]
+- name: also-broken
+  type: generic
+  config:
+    commands:
+    - ["false"]
+- name: ok
+  type: generic
+  deps:
+  - :shared
+  config:
+    commands:
+    - ["true"]
+`,
+	} {
+		fn = filepath.Join(loc, fn)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ws, err := FindWorkspace(loc, Arguments{}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lc, err := local.NewFilesystemCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets := []*Package{ws.Packages["comp:broken"], ws.Packages["comp:ok"], ws.Packages["comp:also-broken"]}
+	err = BuildAll(targets, WithLocalCache(lc), WithReporter(&NoopReporter{}), WithFailFast(false))
+	var failures *BuildFailedErr
+	if !errors.As(err, &failures) {
+		t.Fatalf("expected a BuildFailedErr, got %v", err)
+	}
+	var failed []string
+	for name := range failures.Failed {
+		failed = append(failed, name)
+	}
+	sort.Strings(failed)
+	if diff := cmp.Diff([]string{"comp:also-broken", "comp:broken"}, failed); diff != "" {
+		t.Errorf("failed packages mismatch (-want +got):\n%s", diff)
+	}
+	if _, built := lc.Location(ws.Packages["comp:ok"]); !built {
+		t.Errorf("comp:ok was not built although another target failed")
+	}
+
+	builds, err := os.ReadFile(filepath.Join(loc, "shared.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("built\n", string(builds)); diff != "" {
+		t.Errorf("the shared dependency was not built exactly once (-want +got):\n%s", diff)
+	}
+}
+
+func TestKeepBuildDir(t *testing.T) {
+	buildDir := t.TempDir()
+	t.Setenv(EnvvarBuildDir, buildDir)
+
+	tests := []struct {
+		Name         string
+		Fail         bool
+		KeepBuildDir bool
+		KeepFailed   bool
+		Expectation  bool
+	}{
+		{Name: "success", Expectation: false},
+		{Name: "success with keep-build-dir", KeepBuildDir: true, Expectation: true},
+		{Name: "success with keep-failed", KeepFailed: true, Expectation: false},
+		{Name: "failure", Fail: true, Expectation: false},
+		{Name: "failure with keep-failed", Fail: true, KeepFailed: true, Expectation: true},
+		{Name: "failure with keep-build-dir", Fail: true, KeepBuildDir: true, Expectation: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			command := `["cp", "lib.txt", "lib.out"]`
+			if test.Fail {
+				command = `["sh", "-c", "cp lib.txt lib.out && false"]`
+			}
+			loc := t.TempDir()
+			for fn, content := range map[string]string{
+				"WORKSPACE.yaml":  "",
+				"comp/lib.txt":    "hello world\n",
+				"comp/BUILD.yaml": "packages:\n- name: lib\n  type: generic\n  srcs:\n  - lib.txt\n  config:\n    commands:\n    - " + command + "\n",
+			} {
+				fn = filepath.Join(loc, fn)
+				if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ws, err := FindWorkspace(loc, Arguments{}, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkg := ws.Packages["comp:lib"]
+			version, err := pkg.Version()
+			if err != nil {
+				t.Fatal(err)
+			}
+			lc, err := local.NewFilesystemCache(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = Build(pkg, WithLocalCache(lc), WithReporter(&NoopReporter{}), WithKeepBuildDir(test.KeepBuildDir), WithKeepFailed(test.KeepFailed))
+			if (err != nil) != test.Fail {
+				t.Fatalf("unexpected build error: %v", err)
+			}
+
+			_, err = os.Stat(filepath.Join(buildDir, pkg.FilesystemSafeName()+"."+version, "lib.out"))
+			if kept := err == nil; kept != test.Expectation {
+				t.Errorf("expected build directory to be kept: %v, was kept: %v", test.Expectation, kept)
+			}
+		})
+	}
+}
+
+func TestBuildTimeout(t *testing.T) {
+	t.Setenv(EnvvarBuildDir, t.TempDir())
+
+	files := map[string]string{
+		"WORKSPACE.yaml": "",
+		"comp/src.txt":   "hello world\n",
+		"comp/BUILD.yaml": `packages:
+- name: hung
+  type: generic
+  srcs:
+  - src.txt
+  timeout: 200ms
+  config:
+    commands:
+    - ["sh", "-c", "sleep 10 & sleep 10"]
+- name: slow
+  type: generic
+  srcs:
+  - src.txt
+  config:
+    commands:
+    - ["sleep", "0.5"]
+- name: patient
+  type: generic
+  srcs:
+  - src.txt
+  timeout: 5s
+  config:
+    commands:
+    - ["sleep", "0.5"]
+- name: after-slow
+  type: generic
+  srcs:
+  - src.txt
+  timeout: 300ms
+  deps:
+  - :slow
+  config:
+    commands:
+    - ["true"]
+`,
+	}
+	loc := t.TempDir()
+	for fn, content := range files {
+		fn = filepath.Join(loc, fn)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		Name     string
+		Package  string
+		Timeout  time.Duration
+		TimedOut bool
+	}{
+		{Name: "package timeout", Package: "comp:hung", TimedOut: true},
+		{Name: "default timeout", Package: "comp:slow", Timeout: 100 * time.Millisecond, TimedOut: true},
+		{Name: "package timeout takes precedence", Package: "comp:patient", Timeout: 100 * time.Millisecond},
+		{Name: "building dependencies does not count", Package: "comp:after-slow"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			ws, err := FindWorkspace(loc, Arguments{}, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			lc, err := local.NewFilesystemCache(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			err = Build(ws.Packages[test.Package], WithLocalCache(lc), WithReporter(&NoopReporter{}), WithTimeout(test.Timeout))
+			if !test.TimedOut {
+				if err != nil {
+					t.Fatalf("unexpected build error: %v", err)
+				}
+				return
+			}
+
+			var failures *BuildFailedErr
+			if !errors.As(err, &failures) {
+				t.Fatalf("expected a BuildFailedErr, got %v", err)
+			}
+			var timeoutErr PkgTimeoutErr
+			if !errors.As(failures.Failed[test.Package], &timeoutErr) {
+				t.Fatalf("expected %s to time out, got %v", test.Package, failures.Failed[test.Package])
+			}
+			// processes started in the background would keep the build waiting if only the command itself was killed
+			if dur := time.Since(start); dur > 4*time.Second {
+				t.Errorf("build took %s to time out", dur)
+			}
+		})
+	}
+}
+
+// errorRecorder records the build errors of the packages, which Build only summarises
+type errorRecorder struct {
+	NoopReporter
+
+	mu     sync.Mutex
+	errors map[string]error
+}
+
+func (r *errorRecorder) PackageBuildFinished(pkg *Package, rep *PackageBuildReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errors[pkg.FullName()] = rep.Error
+}
+
+func TestBuildLayoutHardlink(t *testing.T) {
+	buildDir := t.TempDir()
+	t.Setenv(EnvvarBuildDir, buildDir)
+
+	files := map[string]string{
+		"WORKSPACE.yaml": "",
+		"comp/BUILD.yaml": `packages:
+- name: lib
+  type: generic
+  config:
+    commands:
+    - ["sh", "-c", "echo hello > lib.txt"]
+- name: app
+  type: generic
+  deps:
+  - :lib
+  layout:
+    :lib: vendor
+  layoutAssembly: hardlink
+  config:
+    commands:
+    - ["sh", "-c", "cat vendor/lib.txt > app.txt && rm vendor/lib.txt"]
+- name: mutate
+  type: generic
+  deps:
+  - :lib
+  layout:
+    :lib: vendor
+  layoutAssembly: hardlink
+  config:
+    commands:
+    - ["sh", "-c", "echo more >> vendor/lib.txt"]
+- name: mutate-copy
+  type: generic
+  deps:
+  - :lib
+  layout:
+    :lib: vendor
+  config:
+    commands:
+    - ["sh", "-c", "echo more >> vendor/lib.txt"]
+`,
+	}
+	loc := t.TempDir()
+	for fn, content := range files {
+		fn = filepath.Join(loc, fn)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		Package  string
+		Error    string
+		Unpacked bool
+	}{
+		{Package: "comp:app", Unpacked: true},
+		{Package: "comp:mutate", Error: "the build modified lib.txt of dependency comp:lib in place"},
+		{Package: "comp:mutate-copy"},
+	}
+	for _, test := range tests {
+		t.Run(test.Package, func(t *testing.T) {
+			ws, err := FindWorkspace(loc, Arguments{}, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			lc, err := local.NewFilesystemCache(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			unpacked := filepath.Join(buildDir, "unpacked")
+			if err := os.RemoveAll(unpacked); err != nil {
+				t.Fatal(err)
+			}
+
+			rep := &errorRecorder{errors: make(map[string]error)}
+			err = Build(ws.Packages[test.Package], WithLocalCache(lc), WithReporter(rep))
+			if test.Error == "" && err != nil {
+				t.Fatalf("unexpected build error: %v", err)
+			}
+			if pkgErr := rep.errors[test.Package]; test.Error != "" && (err == nil || pkgErr == nil || !strings.Contains(pkgErr.Error(), test.Error)) {
+				t.Fatalf("expected build error containing %q, got %v", test.Error, pkgErr)
+			}
+
+			// the unpacked copy must stay intact for other builds to link, unless the build modified it
+			matches, _ := filepath.Glob(filepath.Join(unpacked, "comp--lib.*", "lib.txt"))
+			if (len(matches) > 0) != test.Unpacked {
+				t.Fatalf("unpacked dependency exists: %v; expected %v", len(matches) > 0, test.Unpacked)
+			}
+			if test.Unpacked {
+				fc, err := os.ReadFile(matches[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(fc) != "hello\n" {
+					t.Errorf("unpacked dependency was modified: %q", fc)
+				}
+			}
+		})
+	}
+}
+
+// retryRecorder records how often the failed commands of a package were re-run
+type retryRecorder struct {
+	NoopReporter
+
+	mu      sync.Mutex
+	retries map[string]int
+}
+
+func (r *retryRecorder) PackageBuildFinished(pkg *Package, rep *PackageBuildReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.retries[pkg.FullName()] = rep.Retries
+}
+
+func TestBuildRetries(t *testing.T) {
+	t.Setenv(EnvvarBuildDir, t.TempDir())
+	defer func(backoff time.Duration) { retryBackoff = backoff }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	// flaky fails the first two times it runs in a build directory
+	const flaky = `["sh", "-c", "echo x >> attempts && test $(wc -l < attempts) -ge 3"]`
+	files := map[string]string{
+		"WORKSPACE.yaml": "",
+		"comp/src.txt":   "hello world\n",
+		"comp/BUILD.yaml": `packages:
+- name: enough-retries
+  type: generic
+  srcs:
+  - src.txt
+  retries: 2
+  config:
+    commands:
+    - ` + flaky + `
+- name: too-few-retries
+  type: generic
+  srcs:
+  - src.txt
+  retries: 1
+  config:
+    commands:
+    - ` + flaky + `
+- name: no-retries
+  type: generic
+  srcs:
+  - src.txt
+  config:
+    commands:
+    - ` + flaky + `
+`,
+	}
+	loc := t.TempDir()
+	for fn, content := range files {
+		fn = filepath.Join(loc, fn)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		Name        string
+		Package     string
+		TestRetries int
+		Retries     int
+		Failed      bool
+	}{
+		{Name: "package retries", Package: "comp:enough-retries", Retries: 2},
+		{Name: "too few retries", Package: "comp:too-few-retries", Retries: 1, Failed: true},
+		{Name: "package retries take precedence", Package: "comp:too-few-retries", TestRetries: 5, Retries: 1, Failed: true},
+		{Name: "no retries", Package: "comp:no-retries", Failed: true},
+		{Name: "test retries", Package: "comp:no-retries", TestRetries: 2, Retries: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			ws, err := FindWorkspace(loc, Arguments{}, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			lc, err := local.NewFilesystemCache(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkg := ws.Packages[test.Package]
+
+			rep := &retryRecorder{retries: make(map[string]int)}
+			err = Build(pkg, WithLocalCache(lc), WithReporter(rep), WithTestRetries(test.TestRetries))
+			if failed := err != nil; failed != test.Failed {
+				t.Fatalf("Build() error = %v; expected failure: %v", err, test.Failed)
+			}
+			if retries := rep.retries[test.Package]; retries != test.Retries {
+				t.Errorf("package build was retried %d times; expected %d", retries, test.Retries)
+			}
+			if _, cached := lc.Location(pkg); cached == test.Failed {
+				t.Errorf("package is in the local cache: %v; expected %v", cached, !test.Failed)
+			}
+		})
+	}
+}
+
+func TestPackageBuildDirLock(t *testing.T) {
+	ctx := &buildContext{buildDir: t.TempDir()}
+	pkg := &Package{C: &Component{Name: "comp"}, PackageInternal: PackageInternal{Name: "lib"}}
+
+	_, release, err := ctx.packageBuildDir(pkg, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		defer close(acquired)
+		_, release, err := ctx.packageBuildDir(pkg, "v1")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		release()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("build directory was acquired twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("build directory was not acquired after it was released")
+	}
+}
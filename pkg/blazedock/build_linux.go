@@ -1,12 +1,14 @@
 package blazedock
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/opencontainers/runc/libcontainer/specconv"
@@ -19,7 +21,7 @@ func checkForCpCompatibility() error {
 	return nil
 }
 
-func executeCommandsForPackageSafe(buildctx *buildContext, p *Package, wd string, commands [][]string) error {
+func executeCommandsForPackageSafe(ctx context.Context, buildctx *buildContext, p *Package, wd string, commands [][]string) error {
 	tmpdir, err := os.MkdirTemp("", "blazedock-*")
 	if err != nil {
 		return err
@@ -100,14 +102,27 @@ func executeCommandsForPackageSafe(buildctx *buildContext, p *Package, wd string
 		spec.Mounts = append(spec.Mounts, specs.Mount{Destination: p, Source: p, Type: "bind", Options: []string{"bind", "private"}})
 	}
 
-	var env []string
-	for _, e := range []string{"PATH", "TERM", "GOROOT", "GOPATH"} {
+	var (
+		env       []string
+		jailedEnv = []string{"PATH", "TERM", "GOROOT", "GOPATH"}
+	)
+	for _, e := range jailedEnv {
 		val := os.Getenv(e)
 		if val == "" {
 			continue
 		}
 		env = append(env, fmt.Sprintf("%s=%s", e, val))
 	}
+	if allowlist := p.envPassthrough(buildctx.Hermetic); allowlist != nil {
+		passthrough, _ := filterEnvironment(os.Environ(), allowlist)
+		for _, e := range passthrough {
+			if name, _, _ := strings.Cut(e, "="); slices.Contains(jailedEnv, name) {
+				// passed in above already
+				continue
+			}
+			env = append(env, e)
+		}
+	}
 
 	spec.Hostname = name
 	spec.Process.Terminal = false
@@ -140,7 +155,7 @@ func executeCommandsForPackageSafe(buildctx *buildContext, p *Package, wd string
 		"run", name,
 	)
 
-	cmd := exec.Command("runc", args...)
+	cmd := exec.CommandContext(ctx, "runc", args...)
 	cmd.Dir = tmpdir
 	cmd.Stdout = &reporterStream{R: buildctx.Reporter, P: p, IsErr: false}
 	cmd.Stderr = &reporterStream{R: buildctx.Reporter, P: p, IsErr: true}
@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumSuffix is appended to the name of a build artifact to form the name of its checksum file
+const ChecksumSuffix = ".sha256"
+
+// InputManifestSuffix is appended to the name of a build artifact to form the name of the file which lists the
+// inputs its version was computed from
+const InputManifestSuffix = ".inputs.yaml"
+
+// TestLogSuffix is appended to the name of a build artifact to form the name of the file which holds the output of
+// the tests which passed when the artifact was built
+const TestLogSuffix = ".test.log"
+
+// ErrNoChecksum is returned when a build artifact has no checksum file, e.g. because it was
+// produced by a version of blazedock which did not record checksums yet
+var ErrNoChecksum = errors.New("no checksum recorded")
+
+// ChecksumMismatchError is returned when a build artifact does not match its recorded checksum
+type ChecksumMismatchError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s is corrupted: expected sha256 %s, got %s", e.Path, e.Expected, e.Actual)
+}
+
+// ChecksumFilename returns the name of the checksum file belonging to a build artifact
+func ChecksumFilename(artifact string) string {
+	return artifact + ChecksumSuffix
+}
+
+// InputManifestFilename returns the name of the input manifest belonging to a build artifact
+func InputManifestFilename(artifact string) string {
+	return artifact + InputManifestSuffix
+}
+
+// TestLogFilename returns the name of the test output belonging to a build artifact
+func TestLogFilename(artifact string) string {
+	return artifact + TestLogSuffix
+}
+
+// ArtifactFiles returns the build artifact together with all files which belong to it, i.e. its checksum file, input
+// manifest and test output. Whoever removes an artifact must remove those files as well.
+func ArtifactFiles(artifact string) []string {
+	return []string{artifact, ChecksumFilename(artifact), InputManifestFilename(artifact), TestLogFilename(artifact)}
+}
+
+// WriteChecksum computes the sha256 of a build artifact and stores it next to the artifact,
+// in the format produced by sha256sum.
+func WriteChecksum(artifact string) (sum string, err error) {
+	sum, err = computeChecksum(artifact)
+	if err != nil {
+		return "", err
+	}
+	err = writeChecksumFile(artifact, sum)
+	if err != nil {
+		return "", err
+	}
+	return sum, nil
+}
+
+// WriteArtifact stores a build artifact read from r, e.g. while it's downloaded from the remote cache. The artifact
+// is hashed while it's written, hence it need not be read once more to verify it. The artifact only appears at its
+// location once it's complete.
+//
+// If expected is not empty, the artifact must match this sha256. Otherwise nothing is stored and a
+// *ChecksumMismatchError is returned. The expected checksum is recorded next to the artifact.
+func WriteArtifact(artifact string, r io.Reader, expected string) (n int64, err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(artifact), filepath.Base(artifact)+".download-*")
+	if err != nil {
+		return 0, fmt.Errorf("cannot create %s: %w", artifact, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err = io.Copy(io.MultiWriter(tmp, h), r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return n, fmt.Errorf("cannot write %s: %w", artifact, err)
+	}
+	actual := hex.EncodeToString(h.Sum(nil))
+	if expected != "" && actual != expected {
+		return n, &ChecksumMismatchError{Path: artifact, Expected: expected, Actual: actual}
+	}
+
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return n, err
+	}
+	err = os.Rename(tmp.Name(), artifact)
+	if err != nil {
+		return n, fmt.Errorf("cannot move %s in place: %w", artifact, err)
+	}
+	if expected == "" {
+		return n, nil
+	}
+	// the checksum is written after the artifact, s.t. VerifyChecksum need not hash the artifact again
+	return n, writeChecksumFile(artifact, expected)
+}
+
+func writeChecksumFile(artifact, sum string) error {
+	content := fmt.Sprintf("%s  %s\n", sum, filepath.Base(artifact))
+	err := os.WriteFile(ChecksumFilename(artifact), []byte(content), 0644)
+	if err != nil {
+		return fmt.Errorf("cannot write checksum of %s: %w", artifact, err)
+	}
+	return nil
+}
+
+// ReadChecksum returns the checksum recorded for a build artifact.
+// Returns ErrNoChecksum if no checksum was recorded.
+func ReadChecksum(artifact string) (string, error) {
+	fc, err := os.ReadFile(ChecksumFilename(artifact))
+	if os.IsNotExist(err) {
+		return "", ErrNoChecksum
+	}
+	if err != nil {
+		return "", err
+	}
+	return ParseChecksum(fc, artifact)
+}
+
+// ParseChecksum returns the checksum recorded in the content of the checksum file of a build artifact
+func ParseChecksum(content []byte, artifact string) (string, error) {
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("checksum file of %s is empty", artifact)
+	}
+	sum := strings.ToLower(fields[0])
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != 2*sha256.Size {
+		return "", fmt.Errorf("checksum file of %s does not contain a sha256", artifact)
+	}
+	return sum, nil
+}
+
+// VerifyChecksum checks a build artifact against its recorded checksum and returns a *ChecksumMismatchError
+// if they differ. Artifacts without recorded checksum yield ErrNoChecksum.
+//
+// Unless full is set, the artifact is only hashed if it was modified after its checksum was recorded.
+// Artifacts don't change once they're in the cache, hence this avoids reading the whole archive on every access.
+func VerifyChecksum(artifact string, full bool) error {
+	expected, err := ReadChecksum(artifact)
+	if err != nil {
+		return err
+	}
+
+	if !full {
+		artifactInfo, err := os.Stat(artifact)
+		if err != nil {
+			return err
+		}
+		sumInfo, err := os.Stat(ChecksumFilename(artifact))
+		if err != nil {
+			return err
+		}
+		if !artifactInfo.ModTime().After(sumInfo.ModTime()) {
+			return nil
+		}
+	}
+
+	actual, err := computeChecksum(artifact)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return &ChecksumMismatchError{Path: artifact, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// Checksum returns the sha256 of a build artifact. The recorded checksum is used if the artifact was not
+// modified since it was recorded, otherwise the artifact is hashed. Returns a *ChecksumMismatchError if the
+// artifact does not match its recorded checksum.
+func Checksum(artifact string) (string, error) {
+	err := VerifyChecksum(artifact, false)
+	if errors.Is(err, ErrNoChecksum) {
+		return computeChecksum(artifact)
+	}
+	if err != nil {
+		return "", err
+	}
+	return ReadChecksum(artifact)
+}
+
+// RemoveArtifact removes a build artifact together with its checksum file, input manifest and test output
+func RemoveArtifact(artifact string) error {
+	for _, fn := range ArtifactFiles(artifact) {
+		err := os.Remove(fn)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func computeChecksum(fn string) (string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("cannot compute checksum of %s: %w", fn, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,98 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+)
+
+// blobDir is the directory in the cache which holds the content-addressed store. Every distinct build result is
+// stored there once, named after its sha256. The build artifacts of the packages are hard links to those blobs, hence
+// byte-identical artifacts take up space only once no matter how many versions produce them.
+const blobDir = "blobs"
+
+// blobPath returns where the content with the given sha256 is stored
+func (fsc *FilesystemCache) blobPath(sum string) string {
+	return filepath.Join(fsc.Origin, blobDir, "sha256", sum[:2], sum)
+}
+
+// Dedupe moves a build artifact into the content-addressed store of the cache. If the store holds the same content
+// already, e.g. because another version of a package produced the same build result, the artifact is replaced by a
+// hard link to it. Returns true if the artifact was replaced, i.e. if it no longer takes up space of its own.
+//
+// Artifacts without recorded checksum, e.g. those cached by older versions of blazedock, are left alone. Artifacts
+// which were stored before the cache was content-addressed are moved into the store when they're deduplicated, which
+// happens when they're garbage collected.
+func (fsc *FilesystemCache) Dedupe(artifact string) (deduped bool, err error) {
+	err = cache.VerifyChecksum(artifact, false)
+	if errors.Is(err, cache.ErrNoChecksum) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	sum, err := cache.ReadChecksum(artifact)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(artifact)
+	if err != nil {
+		return false, err
+	}
+
+	blob := fsc.blobPath(sum)
+	blobInfo, err := os.Stat(blob)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err == nil {
+		if os.SameFile(info, blobInfo) {
+			return false, nil
+		}
+
+		// a corrupted blob would corrupt every artifact linking to it, hence it's checked before it's shared
+		actual, err := cache.Checksum(blob)
+		if err != nil {
+			return false, err
+		}
+		if actual == sum {
+			err = linkInPlace(blob, artifact)
+			if err != nil {
+				return false, fmt.Errorf("cannot link %s to %s: %w", artifact, blob, err)
+			}
+			return true, nil
+		}
+	}
+
+	err = os.MkdirAll(filepath.Dir(blob), 0755)
+	if err != nil {
+		return false, err
+	}
+	err = linkInPlace(artifact, blob)
+	if err != nil {
+		return false, fmt.Errorf("cannot store %s as %s: %w", artifact, blob, err)
+	}
+	return false, nil
+}
+
+// linkInPlace atomically replaces dst by a hard link to src, s.t. readers of dst never miss the file
+func linkInPlace(src, dst string) error {
+	tmp := fmt.Sprintf("%s.%d.link", dst, os.Getpid())
+	err := os.Remove(tmp)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Link(src, tmp)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, dst)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
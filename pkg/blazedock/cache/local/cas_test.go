@@ -0,0 +1,287 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+)
+
+func writeArtifact(t *testing.T, fn, content string, withChecksum bool) {
+	t.Helper()
+
+	err := os.WriteFile(fn, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !withChecksum {
+		return
+	}
+	_, err = cache.WriteChecksum(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sameFile(t *testing.T, a, b string) bool {
+	t.Helper()
+
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ai, bi)
+}
+
+func TestDedupe(t *testing.T) {
+	t.Parallel()
+
+	type Expectation struct {
+		Deduped    bool
+		Error      bool
+		Stored     bool
+		SameAsOrig bool
+	}
+
+	tests := []struct {
+		Name        string
+		Prepare     func(t *testing.T, fsc *FilesystemCache, orig, artifact string)
+		Expectation Expectation
+	}{
+		{
+			Name: "without checksum",
+			Prepare: func(t *testing.T, fsc *FilesystemCache, orig, artifact string) {
+				writeArtifact(t, artifact, "content", false)
+			},
+		},
+		{
+			Name: "first of its content",
+			Prepare: func(t *testing.T, fsc *FilesystemCache, orig, artifact string) {
+				writeArtifact(t, artifact, "content", true)
+			},
+			Expectation: Expectation{Stored: true},
+		},
+		{
+			Name: "identical content",
+			Prepare: func(t *testing.T, fsc *FilesystemCache, orig, artifact string) {
+				writeArtifact(t, orig, "content", true)
+				if _, err := fsc.Dedupe(orig); err != nil {
+					t.Fatal(err)
+				}
+				writeArtifact(t, artifact, "content", true)
+			},
+			Expectation: Expectation{Deduped: true, Stored: true, SameAsOrig: true},
+		},
+		{
+			Name: "different content",
+			Prepare: func(t *testing.T, fsc *FilesystemCache, orig, artifact string) {
+				writeArtifact(t, orig, "other content", true)
+				if _, err := fsc.Dedupe(orig); err != nil {
+					t.Fatal(err)
+				}
+				writeArtifact(t, artifact, "content", true)
+			},
+			Expectation: Expectation{Stored: true},
+		},
+		{
+			Name: "corrupted content in store",
+			Prepare: func(t *testing.T, fsc *FilesystemCache, orig, artifact string) {
+				writeArtifact(t, orig, "content", true)
+				if _, err := fsc.Dedupe(orig); err != nil {
+					t.Fatal(err)
+				}
+				// corrupts orig as well, which verification finds
+				sum, _ := cache.ReadChecksum(orig)
+				writeArtifact(t, fsc.blobPath(sum), "c0ntent", false)
+				writeArtifact(t, artifact, "content", true)
+			},
+			Expectation: Expectation{Stored: true},
+		},
+		{
+			Name: "corrupted artifact",
+			Prepare: func(t *testing.T, fsc *FilesystemCache, orig, artifact string) {
+				writeArtifact(t, artifact, "content", true)
+				writeArtifact(t, artifact, "c0ntent", false)
+				future := time.Now().Add(time.Hour)
+				if err := os.Chtimes(artifact, future, future); err != nil {
+					t.Fatal(err)
+				}
+			},
+			Expectation: Expectation{Error: true},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			fsc := &FilesystemCache{Origin: t.TempDir()}
+			var (
+				orig     = filepath.Join(fsc.Origin, "orig.tar.gz")
+				artifact = filepath.Join(fsc.Origin, "artifact.tar.gz")
+			)
+			test.Prepare(t, fsc, orig, artifact)
+
+			var act Expectation
+			deduped, err := fsc.Dedupe(artifact)
+			act.Deduped = deduped
+			act.Error = err != nil
+			if sum, err := cache.ReadChecksum(artifact); err == nil {
+				act.Stored = sameFile(t, artifact, fsc.blobPath(sum))
+			}
+			act.SameAsOrig = sameFile(t, artifact, orig)
+
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("Dedupe() mismatch (-want +got):\n%s", diff)
+			}
+			if fc, err := os.ReadFile(artifact); err == nil && string(fc) != "content" && !act.Error {
+				t.Errorf("Dedupe() changed the content of the artifact to %q", string(fc))
+			}
+		})
+	}
+}
+
+func TestGCDedupe(t *testing.T) {
+	t.Parallel()
+
+	type Expectation struct {
+		Evicted   []string
+		Reclaimed int64
+		Remaining int64
+		Deduped   int64
+		Blobs     int
+	}
+
+	tests := []struct {
+		Name        string
+		Stored      []string
+		Live        []string
+		Orphan      bool
+		Opts        GCOptions
+		Expectation Expectation
+	}{
+		{
+			Name:   "identical entries are stored once",
+			Stored: []string{"a"},
+			Live:   []string{"a", "b", "c"},
+			Expectation: Expectation{
+				Remaining: 20,
+				Deduped:   10,
+				Blobs:     2,
+			},
+		},
+		{
+			Name:   "shared content is kept while referenced",
+			Stored: []string{"a", "b"},
+			Live:   []string{"a"},
+			Expectation: Expectation{
+				Evicted:   []string{"b.tar.gz", "c.tar.gz"},
+				Reclaimed: 10,
+				Remaining: 10,
+				Blobs:     1,
+			},
+		},
+		{
+			Name:   "shared content is reclaimed once",
+			Stored: []string{"a", "b"},
+			Live:   []string{"c"},
+			Expectation: Expectation{
+				Evicted:   []string{"a.tar.gz", "b.tar.gz"},
+				Reclaimed: 10,
+				Remaining: 10,
+				Blobs:     1,
+			},
+		},
+		{
+			Name:   "unreferenced content is removed from the store",
+			Stored: []string{"a"},
+			Live:   []string{"a", "b", "c"},
+			Orphan: true,
+			Expectation: Expectation{
+				Evicted:   []string{"orphan"},
+				Reclaimed: 6,
+				Remaining: 20,
+				Deduped:   10,
+				Blobs:     2,
+			},
+		},
+		{
+			Name:   "max size counts shared content once",
+			Stored: []string{"a"},
+			Live:   []string{"a", "b", "c"},
+			Opts:   GCOptions{MaxSize: 20},
+			Expectation: Expectation{
+				Remaining: 20,
+				Deduped:   10,
+				Blobs:     2,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			fsc := &FilesystemCache{Origin: t.TempDir()}
+			// a and b are identical, entries which are not stored were cached before the cache was content-addressed
+			for _, e := range []struct{ Name, Content string }{{"a", "0123456789"}, {"b", "0123456789"}, {"c", "abcdefghij"}} {
+				writeArtifact(t, filepath.Join(fsc.Origin, gzFilename(e.Name)), e.Content, true)
+			}
+			for _, name := range test.Stored {
+				if _, err := fsc.Dedupe(filepath.Join(fsc.Origin, gzFilename(name))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.Orphan {
+				orphan := filepath.Join(fsc.Origin, blobDir, "sha256", "or", "orphan")
+				if err := os.MkdirAll(filepath.Dir(orphan), 0755); err != nil {
+					t.Fatal(err)
+				}
+				writeArtifact(t, orphan, "orphan", false)
+			}
+			live := make([]cache.Package, 0, len(test.Live))
+			for _, v := range test.Live {
+				live = append(live, mockPackage{version: v})
+			}
+
+			res, err := fsc.GC(live, test.Opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			act := Expectation{
+				Reclaimed: res.ReclaimedBytes,
+				Remaining: res.RemainingBytes,
+				Deduped:   res.DedupedBytes,
+			}
+			for _, e := range res.Evicted {
+				act.Evicted = append(act.Evicted, filepath.Base(e.Path))
+			}
+			sort.Strings(act.Evicted)
+			err = filepath.WalkDir(filepath.Join(fsc.Origin, blobDir), func(path string, d os.DirEntry, err error) error {
+				if err == nil && d.Type().IsRegular() {
+					act.Blobs++
+				}
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("GC() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -40,13 +40,13 @@ func (fsc *FilesystemCache) Location(pkg cache.Package) (path string, exists boo
 	}
 
 	// Check for .tar.gz file first
-	gzPath := filepath.Join(fsc.Origin, fmt.Sprintf("%s.tar.gz", version))
+	gzPath := filepath.Join(fsc.Origin, gzFilename(version))
 	if fileExists(gzPath) {
 		return gzPath, true
 	}
 
 	// Fall back to .tar file
-	tarPath := filepath.Join(fsc.Origin, fmt.Sprintf("%s.tar", version))
+	tarPath := filepath.Join(fsc.Origin, tarFilename(version))
 	exists = fileExists(tarPath)
 
 	// Always ensure the parent directory exists for this path
@@ -58,6 +58,38 @@ func (fsc *FilesystemCache) Location(pkg cache.Package) (path string, exists boo
 	return tarPath, exists
 }
 
+// Remove deletes the build artifact of a package, its checksum, input manifest and test output from the cache and returns the
+// paths of the files it removed. Removing a package which is not cached is no error.
+func (fsc *FilesystemCache) Remove(pkg cache.Package) (removed []string, err error) {
+	version, err := pkg.Version()
+	if err != nil {
+		return nil, fmt.Errorf("cannot compute version of %s: %w", pkg.FullName(), err)
+	}
+
+	for _, fn := range []string{gzFilename(version), tarFilename(version)} {
+		artifact := filepath.Join(fsc.Origin, fn)
+		for _, path := range cache.ArtifactFiles(artifact) {
+			err := os.Remove(path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			removed = append(removed, path)
+		}
+	}
+	return removed, nil
+}
+
+func gzFilename(version string) string {
+	return fmt.Sprintf("%s.tar.gz", version)
+}
+
+func tarFilename(version string) string {
+	return fmt.Sprintf("%s.tar", version)
+}
+
 // fileExists checks if a file exists and is not a directory
 func fileExists(filename string) bool {
 	info, err := os.Stat(filename)
@@ -220,3 +220,52 @@ func TestFileExists(t *testing.T) {
 		})
 	}
 }
+
+func TestRemove(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	for _, fn := range []string{"v1.tar.gz", "v1.tar.gz.sha256", "v2.tar"} {
+		err := os.WriteFile(filepath.Join(tmpDir, fn), []byte("test"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsc := &FilesystemCache{Origin: tmpDir}
+
+	tests := []struct {
+		Name        string
+		Version     string
+		Expectation []string
+	}{
+		{
+			Name:        "artifact with checksum",
+			Version:     "v1",
+			Expectation: []string{filepath.Join(tmpDir, "v1.tar.gz"), filepath.Join(tmpDir, "v1.tar.gz.sha256")},
+		},
+		{
+			Name:        "artifact without checksum",
+			Version:     "v2",
+			Expectation: []string{filepath.Join(tmpDir, "v2.tar")},
+		},
+		{
+			Name:    "not cached",
+			Version: "v3",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			removed, err := fsc.Remove(mockPackage{version: test.Version})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.Expectation, removed); diff != "" {
+				t.Errorf("Remove() mismatch (-want +got):\n%s", diff)
+			}
+			if _, exists := fsc.Location(mockPackage{version: test.Version}); exists {
+				t.Errorf("Remove() left the artifact of %s behind", test.Version)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package local
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+	log "github.com/sirupsen/logrus"
+)
+
+// GCOptions configures a garbage collection run on the filesystem cache
+type GCOptions struct {
+	// MaxAge evicts entries which were last modified longer than MaxAge ago, even if they're still referenced.
+	// Zero disables the age limit.
+	MaxAge time.Duration
+
+	// MaxSize evicts the least recently used entries until the cache holds no more than MaxSize bytes.
+	// Zero disables the size limit.
+	MaxSize int64
+
+	// DryRun computes the entries to evict without actually deleting them
+	DryRun bool
+}
+
+// GCReason explains why an entry was evicted
+type GCReason string
+
+const (
+	// GCUnreferenced marks entries which no package references
+	GCUnreferenced GCReason = "unreferenced"
+	// GCMaxAge marks entries older than the configured max age
+	GCMaxAge GCReason = "max-age"
+	// GCMaxSize marks entries evicted to meet the configured max size
+	GCMaxSize GCReason = "max-size"
+)
+
+// GCEntry is a single cache entry evicted during garbage collection
+type GCEntry struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	Reason  GCReason
+}
+
+// GCResult summarises a garbage collection run
+type GCResult struct {
+	Evicted        []GCEntry
+	ReclaimedBytes int64
+	RemainingBytes int64
+
+	// DedupedBytes is how much more space the remaining entries would take up if identical artifacts were not stored
+	// only once
+	DedupedBytes int64
+}
+
+// gcCandidate is a build artifact in the cache which garbage collection considers for eviction
+type gcCandidate struct {
+	GCEntry
+
+	// Content identifies what the artifact stores. Artifacts which share their content take up space only once.
+	Content string
+}
+
+// GC evicts all build artifacts from the cache which do not belong to one of the live packages.
+// Depending on the options, referenced entries are evicted as well if they're too old or exceed the size budget.
+// Files in the cache directory which don't look like build artifacts are never touched.
+//
+// Unless it's a dry run, the remaining entries are moved into the content-addressed store of the cache, which stores
+// identical artifacts only once, and content which no entry refers to anymore is removed from the store.
+func (fsc *FilesystemCache) GC(live []cache.Package, opts GCOptions) (*GCResult, error) {
+	referenced := make(map[string]struct{}, 2*len(live))
+	for _, pkg := range live {
+		version, err := pkg.Version()
+		if err != nil {
+			return nil, fmt.Errorf("cannot compute version of %s: %w", pkg.FullName(), err)
+		}
+		referenced[gzFilename(version)] = struct{}{}
+		referenced[tarFilename(version)] = struct{}{}
+	}
+
+	dirents, err := os.ReadDir(fsc.Origin)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		res     GCResult
+		kept    []gcCandidate
+		evicted []gcCandidate
+		now     = time.Now()
+	)
+	for _, de := range dirents {
+		name := de.Name()
+		if !de.Type().IsRegular() || !(strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tar")) {
+			continue
+		}
+		path := filepath.Join(fsc.Origin, name)
+
+		var reason GCReason
+		if _, ok := referenced[name]; !ok {
+			reason = GCUnreferenced
+		} else if !opts.DryRun {
+			_, err := fsc.Dedupe(path)
+			if err != nil {
+				log.WithError(err).WithField("path", path).Warn("cannot deduplicate cache entry")
+			}
+		}
+
+		entry, err := fsc.gcCandidate(path)
+		if err != nil {
+			return nil, err
+		}
+		if reason == "" && opts.MaxAge > 0 && now.Sub(entry.ModTime) > opts.MaxAge {
+			reason = GCMaxAge
+		}
+		if reason != "" {
+			entry.Reason = reason
+			evicted = append(evicted, entry)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+
+	// refs counts the remaining entries which share some content
+	refs := make(map[string]int, len(kept))
+	for _, entry := range kept {
+		if refs[entry.Content] == 0 {
+			res.RemainingBytes += entry.Size
+		} else {
+			res.DedupedBytes += entry.Size
+		}
+		refs[entry.Content]++
+	}
+
+	if opts.MaxSize > 0 && res.RemainingBytes > opts.MaxSize {
+		// least recently used first
+		sort.Slice(kept, func(i, j int) bool { return kept[i].ModTime.Before(kept[j].ModTime) })
+		for _, entry := range kept {
+			if res.RemainingBytes <= opts.MaxSize {
+				break
+			}
+			entry.Reason = GCMaxSize
+			evicted = append(evicted, entry)
+			refs[entry.Content]--
+			if refs[entry.Content] == 0 {
+				res.RemainingBytes -= entry.Size
+			} else {
+				res.DedupedBytes -= entry.Size
+			}
+		}
+	}
+
+	// content only takes up space until the last entry which refers to it is gone
+	reclaimed := make(map[string]struct{}, len(evicted))
+	for _, entry := range evicted {
+		res.Evicted = append(res.Evicted, entry.GCEntry)
+		if _, done := reclaimed[entry.Content]; refs[entry.Content] > 0 || done {
+			continue
+		}
+		reclaimed[entry.Content] = struct{}{}
+		res.ReclaimedBytes += entry.Size
+	}
+	orphans, err := fsc.unreferencedBlobs(refs, reclaimed)
+	if err != nil {
+		return nil, err
+	}
+	for _, blob := range orphans {
+		res.Evicted = append(res.Evicted, blob)
+		res.ReclaimedBytes += blob.Size
+	}
+
+	for _, entry := range res.Evicted {
+		if opts.DryRun {
+			continue
+		}
+
+		err := cache.RemoveArtifact(entry.Path)
+		if err != nil {
+			return &res, fmt.Errorf("cannot evict %s: %w", entry.Path, err)
+		}
+		log.WithField("path", entry.Path).WithField("reason", entry.Reason).Debug("evicted cache entry")
+	}
+	if opts.DryRun {
+		return &res, nil
+	}
+	for content := range reclaimed {
+		err := os.Remove(content)
+		if err != nil && !os.IsNotExist(err) {
+			return &res, fmt.Errorf("cannot remove %s from the cache: %w", content, err)
+		}
+	}
+
+	return &res, nil
+}
+
+// gcCandidate describes a build artifact in the cache for garbage collection
+func (fsc *FilesystemCache) gcCandidate(path string) (gcCandidate, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return gcCandidate{}, err
+	}
+	res := gcCandidate{
+		GCEntry: GCEntry{
+			Path:    path,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		},
+		Content: path,
+	}
+
+	// Deduplicated artifacts share the modification time of their content, which is as old as the first artifact
+	// which stored it. The checksum is recorded when the artifact itself is cached.
+	sumInfo, err := os.Stat(cache.ChecksumFilename(path))
+	if err == nil && sumInfo.ModTime().After(res.ModTime) {
+		res.ModTime = sumInfo.ModTime()
+	}
+
+	sum, err := cache.ReadChecksum(path)
+	if err != nil {
+		// without checksum the artifact cannot be in the content-addressed store
+		return res, nil
+	}
+	blob := fsc.blobPath(sum)
+	blobInfo, err := os.Stat(blob)
+	if err == nil && os.SameFile(info, blobInfo) {
+		res.Content = blob
+	}
+	return res, nil
+}
+
+// unreferencedBlobs lists the content in the content-addressed store which no build artifact refers to anymore,
+// e.g. because the artifacts were removed by verification. Content which is reclaimed already is not listed.
+func (fsc *FilesystemCache) unreferencedBlobs(refs map[string]int, reclaimed map[string]struct{}) ([]GCEntry, error) {
+	var res []GCEntry
+	err := filepath.WalkDir(filepath.Join(fsc.Origin, blobDir), func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		// temporary links are named <blob>.<pid>.link
+		if !d.Type().IsRegular() || strings.Contains(d.Name(), ".") {
+			return nil
+		}
+		if _, ok := reclaimed[path]; ok || refs[path] > 0 {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		res = append(res, GCEntry{
+			Path:    path,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Reason:  GCUnreferenced,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,145 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+)
+
+func TestGC(t *testing.T) {
+	t.Parallel()
+
+	type entry struct {
+		Name string
+		Size int
+		Age  time.Duration
+	}
+	type Expectation struct {
+		Evicted   []string
+		Remaining []string
+		Reclaimed int64
+	}
+
+	tests := []struct {
+		Name        string
+		Entries     []entry
+		Live        []string
+		Opts        GCOptions
+		Expectation Expectation
+	}{
+		{
+			Name: "unreferenced entries",
+			Entries: []entry{
+				{Name: "live.tar.gz", Size: 10},
+				{Name: "dead.tar.gz", Size: 20},
+				{Name: "dead2.tar", Size: 5},
+				{Name: "not-an-artifact.txt", Size: 100},
+			},
+			Live: []string{"live"},
+			Expectation: Expectation{
+				Evicted:   []string{"dead.tar.gz", "dead2.tar"},
+				Remaining: []string{"live.tar.gz", "not-an-artifact.txt"},
+				Reclaimed: 25,
+			},
+		},
+		{
+			Name: "dry run",
+			Entries: []entry{
+				{Name: "live.tar.gz", Size: 10},
+				{Name: "dead.tar.gz", Size: 20},
+			},
+			Live: []string{"live"},
+			Opts: GCOptions{DryRun: true},
+			Expectation: Expectation{
+				Evicted:   []string{"dead.tar.gz"},
+				Remaining: []string{"dead.tar.gz", "live.tar.gz"},
+				Reclaimed: 20,
+			},
+		},
+		{
+			Name: "max age",
+			Entries: []entry{
+				{Name: "new.tar.gz", Size: 10},
+				{Name: "old.tar.gz", Size: 20, Age: 48 * time.Hour},
+			},
+			Live: []string{"new", "old"},
+			Opts: GCOptions{MaxAge: 24 * time.Hour},
+			Expectation: Expectation{
+				Evicted:   []string{"old.tar.gz"},
+				Remaining: []string{"new.tar.gz"},
+				Reclaimed: 20,
+			},
+		},
+		{
+			Name: "max size evicts least recently used",
+			Entries: []entry{
+				{Name: "a.tar.gz", Size: 10, Age: 3 * time.Hour},
+				{Name: "b.tar.gz", Size: 10, Age: 2 * time.Hour},
+				{Name: "c.tar.gz", Size: 10, Age: 1 * time.Hour},
+			},
+			Live: []string{"a", "b", "c"},
+			Opts: GCOptions{MaxSize: 15},
+			Expectation: Expectation{
+				Evicted:   []string{"a.tar.gz", "b.tar.gz"},
+				Remaining: []string{"c.tar.gz"},
+				Reclaimed: 20,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			for _, e := range test.Entries {
+				fn := filepath.Join(tmpDir, e.Name)
+				err := os.WriteFile(fn, make([]byte, e.Size), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				mtime := time.Now().Add(-e.Age)
+				err = os.Chtimes(fn, mtime, mtime)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			live := make([]cache.Package, 0, len(test.Live))
+			for _, v := range test.Live {
+				live = append(live, mockPackage{version: v})
+			}
+
+			fsc := &FilesystemCache{Origin: tmpDir}
+			res, err := fsc.GC(live, test.Opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var act Expectation
+			for _, e := range res.Evicted {
+				act.Evicted = append(act.Evicted, filepath.Base(e.Path))
+			}
+			sort.Strings(act.Evicted)
+			act.Reclaimed = res.ReclaimedBytes
+
+			dirents, err := os.ReadDir(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, de := range dirents {
+				act.Remaining = append(act.Remaining, de.Name())
+			}
+
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("GC() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
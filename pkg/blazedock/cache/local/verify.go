@@ -0,0 +1,76 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+	log "github.com/sirupsen/logrus"
+)
+
+// VerifyStatus is the outcome of verifying a single cache entry
+type VerifyStatus string
+
+const (
+	// VerifyOK marks entries which match their recorded checksum
+	VerifyOK VerifyStatus = "ok"
+	// VerifyNoChecksum marks entries without recorded checksum, which therefore cannot be verified
+	VerifyNoChecksum VerifyStatus = "no-checksum"
+	// VerifyCorrupted marks entries which do not match their recorded checksum
+	VerifyCorrupted VerifyStatus = "corrupted"
+)
+
+// VerifyEntry is a single cache entry checked during verification
+type VerifyEntry struct {
+	Path   string
+	Status VerifyStatus
+	Error  error
+}
+
+// Verify checks all build artifacts in the cache against their recorded checksums.
+// Unless dryRun is set, corrupted entries are removed from the cache s.t. they're rebuilt or downloaded again.
+func (fsc *FilesystemCache) Verify(dryRun bool) ([]VerifyEntry, error) {
+	dirents, err := os.ReadDir(fsc.Origin)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []VerifyEntry
+	for _, de := range dirents {
+		name := de.Name()
+		if !de.Type().IsRegular() || !(strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tar")) {
+			continue
+		}
+
+		entry := VerifyEntry{Path: filepath.Join(fsc.Origin, name), Status: VerifyOK}
+		err := cache.VerifyChecksum(entry.Path, true)
+		var mismatch *cache.ChecksumMismatchError
+		switch {
+		case err == nil:
+		case errors.Is(err, cache.ErrNoChecksum):
+			entry.Status = VerifyNoChecksum
+		case errors.As(err, &mismatch):
+			entry.Status = VerifyCorrupted
+			entry.Error = err
+		default:
+			return res, fmt.Errorf("cannot verify %s: %w", entry.Path, err)
+		}
+		res = append(res, entry)
+
+		if entry.Status != VerifyCorrupted || dryRun {
+			continue
+		}
+		err = cache.RemoveArtifact(entry.Path)
+		if err != nil {
+			return res, fmt.Errorf("cannot remove corrupted %s: %w", entry.Path, err)
+		}
+		log.WithField("path", entry.Path).Debug("removed corrupted cache entry")
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+
+	return res, nil
+}
@@ -0,0 +1,124 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+)
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	type entry struct {
+		Name        string
+		Content     string
+		Checksummed bool
+		Corrupt     bool
+	}
+	type Expectation struct {
+		Status    map[string]VerifyStatus
+		Remaining []string
+	}
+
+	tests := []struct {
+		Name        string
+		Entries     []entry
+		DryRun      bool
+		Expectation Expectation
+	}{
+		{
+			Name: "intact and legacy entries",
+			Entries: []entry{
+				{Name: "a.tar.gz", Content: "a", Checksummed: true},
+				{Name: "b.tar", Content: "b"},
+				{Name: "not-an-artifact.txt", Content: "c"},
+			},
+			Expectation: Expectation{
+				Status:    map[string]VerifyStatus{"a.tar.gz": VerifyOK, "b.tar": VerifyNoChecksum},
+				Remaining: []string{"a.tar.gz", "a.tar.gz.sha256", "b.tar", "not-an-artifact.txt"},
+			},
+		},
+		{
+			Name: "corrupted entries are removed",
+			Entries: []entry{
+				{Name: "a.tar.gz", Content: "a", Checksummed: true},
+				{Name: "b.tar.gz", Content: "b", Checksummed: true, Corrupt: true},
+			},
+			Expectation: Expectation{
+				Status:    map[string]VerifyStatus{"a.tar.gz": VerifyOK, "b.tar.gz": VerifyCorrupted},
+				Remaining: []string{"a.tar.gz", "a.tar.gz.sha256"},
+			},
+		},
+		{
+			Name: "dry run",
+			Entries: []entry{
+				{Name: "b.tar.gz", Content: "b", Checksummed: true, Corrupt: true},
+			},
+			DryRun: true,
+			Expectation: Expectation{
+				Status:    map[string]VerifyStatus{"b.tar.gz": VerifyCorrupted},
+				Remaining: []string{"b.tar.gz", "b.tar.gz.sha256"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			for _, e := range test.Entries {
+				fn := filepath.Join(tmpDir, e.Name)
+				err := os.WriteFile(fn, []byte(e.Content), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !e.Checksummed {
+					continue
+				}
+				_, err = cache.WriteChecksum(fn)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if e.Corrupt {
+					err = os.WriteFile(fn, []byte(e.Content+"corrupted"), 0644)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			fsc := &FilesystemCache{Origin: tmpDir}
+			res, err := fsc.Verify(test.DryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			act := Expectation{Status: make(map[string]VerifyStatus)}
+			for _, e := range res {
+				act.Status[filepath.Base(e.Path)] = e.Status
+				if (e.Status == VerifyCorrupted) != (e.Error != nil) {
+					t.Errorf("%s: status %s but error %v", e.Path, e.Status, e.Error)
+				}
+			}
+			dirents, err := os.ReadDir(tmpDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, de := range dirents {
+				act.Remaining = append(act.Remaining, de.Name())
+			}
+			sort.Strings(act.Remaining)
+
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("Verify() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -19,15 +19,23 @@ import (
 // GSUtilCache uses the gsutil command to implement a remote cache
 type GSUtilCache struct {
 	BucketName string
+	// Prefix is the path all objects are placed under. It's either empty or ends with a slash.
+	Prefix string
 }
 
 // NewGSUtilCache creates a new GSUtil cache implementation
 func NewGSUtilCache(cfg *cache.RemoteConfig) *GSUtilCache {
 	return &GSUtilCache{
 		BucketName: cfg.BucketName,
+		Prefix:     normalizePrefix(cfg.Prefix),
 	}
 }
 
+// objectURL returns the gs:// URL of an object in the cache
+func (rs *GSUtilCache) objectURL(name string) string {
+	return fmt.Sprintf("gs://%s/%s%s", rs.BucketName, rs.Prefix, name)
+}
+
 // ExistingPackages returns existing cached build artifacts in the remote cache
 func (rs *GSUtilCache) ExistingPackages(ctx context.Context, pkgs []cache.Package) (map[cache.Package]struct{}, error) {
 	fmt.Printf("☁️  checking remote cache for past build artifacts for %d packages\n", len(pkgs))
@@ -49,8 +57,8 @@ func (rs *GSUtilCache) ExistingPackages(ctx context.Context, pkgs []cache.Packag
 		}
 
 		pair := urlPair{
-			gzURL:  fmt.Sprintf("gs://%s/%s.tar.gz", rs.BucketName, version),
-			tarURL: fmt.Sprintf("gs://%s/%s.tar", rs.BucketName, version),
+			gzURL:  rs.objectURL(version + ".tar.gz"),
+			tarURL: rs.objectURL(version + ".tar"),
 		}
 		packageToURLMap[p] = pair
 		urls = append(urls, pair.gzURL, pair.tarURL)
@@ -109,7 +117,10 @@ func (rs *GSUtilCache) Download(ctx context.Context, dst cache.LocalCache, pkgs
 			return fmt.Errorf("gsutil only supports one target folder, not %s and %s", dest, filepath.Dir(fn))
 		}
 
-		files = append(files, fmt.Sprintf("gs://%s/%s", rs.BucketName, filepath.Base(fn)))
+		files = append(files,
+			rs.objectURL(filepath.Base(fn)),
+			rs.objectURL(cache.ChecksumFilename(filepath.Base(fn))),
+		)
 	}
 	return gsutilTransfer(dest, files)
 }
@@ -124,8 +135,59 @@ func (rs *GSUtilCache) Upload(ctx context.Context, src cache.LocalCache, pkgs []
 			continue
 		}
 		files = append(files, file)
+		if _, err := os.Stat(cache.ChecksumFilename(file)); err == nil {
+			files = append(files, cache.ChecksumFilename(file))
+		}
+	}
+	// with a trailing slash gsutil copies into the prefix rather than to an object named like it
+	return gsutilTransfer(rs.objectURL(""), files)
+}
+
+// Delete removes the build artifacts of the packages from the remote cache
+func (rs *GSUtilCache) Delete(ctx context.Context, pkgs []cache.Package) error {
+	var urls []string
+	for _, p := range pkgs {
+		version, err := p.Version()
+		if err != nil {
+			return fmt.Errorf("cannot compute version of %s: %w", p.FullName(), err)
+		}
+		for _, fn := range []string{version + ".tar.gz", version + ".tar"} {
+			urls = append(urls,
+				rs.objectURL(fn),
+				rs.objectURL(cache.ChecksumFilename(fn)),
+			)
+		}
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+
+	// a package is cached as either .tar.gz or .tar and may lack a checksum, hence some URLs never match
+	cmd := exec.CommandContext(ctx, "gsutil", append([]string{"stat"}, urls...)...)
+	var stdout, stderr strings.Builder
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil && !strings.Contains(stderr.String(), "No URLs matched") {
+		return fmt.Errorf("gsutil stat failed: %w: %s", err, stderr.String())
+	}
+	existing := parseGSUtilStatOutput(strings.NewReader(stdout.String()))
+	if len(existing) == 0 {
+		return nil
 	}
-	return gsutilTransfer(fmt.Sprintf("gs://%s", rs.BucketName), files)
+
+	args := []string{"-m", "rm"}
+	for _, u := range urls {
+		if _, ok := existing[u]; ok {
+			args = append(args, u)
+		}
+	}
+	cmd = exec.CommandContext(ctx, "gsutil", args...)
+	stderr.Reset()
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("gsutil rm failed: %w: %s", err, stderr.String())
+	}
+	return nil
 }
 
 func parseGSUtilStatOutput(reader io.Reader) map[string]struct{} {
@@ -0,0 +1,287 @@
+package remote
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+)
+
+const (
+	// defaultHTTPMaxAttempts is the default number of attempts made for a request to the HTTP remote cache
+	defaultHTTPMaxAttempts = 3
+	// defaultHTTPBackoff is the default time to wait before retrying a failed request to the HTTP remote cache
+	defaultHTTPBackoff = 200 * time.Millisecond
+)
+
+// HTTPConfig holds the configuration for HTTPCache
+type HTTPConfig struct {
+	// Token is sent as bearer token with every request, unless it's empty
+	Token string
+	// MaxAttempts is the number of attempts made for requests which fail due to a network or server error
+	MaxAttempts int
+	// Backoff is the time to wait before the first retry. It doubles with every further retry.
+	Backoff time.Duration
+}
+
+// AuthError is returned when the HTTP remote cache rejects a request because of missing or invalid credentials.
+// Other than a cache miss this is a configuration problem.
+type AuthError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("remote cache denied access to %s (%d %s) - check the bearer token", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// HTTPCache implements RemoteCache using a plain HTTP server which serves build artifacts at <base URL>/<key>
+// and accepts uploads using PUT. Downloads and uploads work exactly like for S3, only the storage differs.
+type HTTPCache struct {
+	*S3Cache
+}
+
+// NewHTTPCache creates a new HTTP cache implementation. The bucket name of the config is the base URL of the server.
+func NewHTTPCache(cfg *cache.RemoteConfig, httpCfg HTTPConfig) (*HTTPCache, error) {
+	storage, err := NewHTTPStorage(cfg.BucketName, cfg.InsecureSkipVerify, httpCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HTTPCache{
+		S3Cache: &S3Cache{
+			storage:     withPrefix(storage, cfg.Prefix),
+			cfg:         cfg,
+			workerCount: defaultWorkerCount,
+		},
+	}, nil
+}
+
+// HTTPStorage implements ObjectStorage using plain HTTP GET, HEAD, PUT and DELETE requests
+type HTTPStorage struct {
+	baseURL *url.URL
+	client  *http.Client
+	cfg     HTTPConfig
+
+	authWarning sync.Once
+}
+
+// NewHTTPStorage creates a new HTTP storage implementation
+func NewHTTPStorage(baseURL string, insecureSkipVerify bool, cfg HTTPConfig) (*HTTPStorage, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("remote cache URL must be an absolute http(s) URL: %s", baseURL)
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultHTTPMaxAttempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultHTTPBackoff
+	}
+
+	client := &http.Client{}
+	if insecureSkipVerify {
+		log.Warn("TLS certificate verification for the remote cache is disabled")
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		//nolint:gosec
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		client.Transport = tr
+	}
+
+	return &HTTPStorage{
+		baseURL: u,
+		client:  client,
+		cfg:     cfg,
+	}, nil
+}
+
+func (s *HTTPStorage) objectURL(key string) string {
+	u := *s.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	return u.String()
+}
+
+// do sends a request and retries it with exponential backoff if it fails due to a network or server error.
+// Because a request body can only be read once, body is called anew for every attempt.
+func (s *HTTPStorage) do(ctx context.Context, method, key string, body func() (io.ReadCloser, int64, error)) (*http.Response, error) {
+	var (
+		target  = s.objectURL(key)
+		backoff = s.cfg.Backoff
+		lastErr error
+	)
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Body, req.ContentLength, err = body()
+			if err != nil {
+				return nil, err
+			}
+		}
+		if s.cfg.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+s.cfg.Token)
+		}
+
+		resp, err := s.client.Do(req)
+		switch {
+		case err != nil:
+			lastErr = err
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			resp.Body.Close()
+			authErr := &AuthError{URL: target, StatusCode: resp.StatusCode}
+			// the caching logic treats failures as cache misses, hence we make sure this does not go unnoticed
+			s.authWarning.Do(func() { log.Warn(authErr.Error()) })
+			return nil, authErr
+		case resp.StatusCode >= 500:
+			resp.Body.Close()
+			lastErr = fmt.Errorf("%s %s: %s", method, target, resp.Status)
+		default:
+			return resp, nil
+		}
+
+		if attempt >= s.cfg.MaxAttempts {
+			return nil, fmt.Errorf("%s %s failed after %d attempts: %w", method, target, attempt, lastErr)
+		}
+		log.WithError(lastErr).WithField("attempt", attempt).Debug("remote cache request failed, retrying")
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// HasObject implements ObjectStorage
+func (s *HTTPStorage) HasObject(ctx context.Context, key string) (bool, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return true, nil
+	default:
+		return false, fmt.Errorf("HEAD %s: %s", s.objectURL(key), resp.Status)
+	}
+}
+
+// OpenObject implements ObjectStorage. The remote cache streams the body into the local cache, see S3Cache.downloadArtifact.
+func (s *HTTPStorage) OpenObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("object not found: GET %s: %s", s.objectURL(key), resp.Status)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download object: GET %s: %s", s.objectURL(key), resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// GetObject implements ObjectStorage
+func (s *HTTPStorage) GetObject(ctx context.Context, key string, dest string) (int64, error) {
+	body, err := s.OpenObject(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create parent directory: %w", err)
+	}
+	// download to a temporary file first, s.t. an interrupted download does not end up in the cache
+	tmp, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".download-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to download object: %w", err)
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("downloaded object validation failed: %s is empty", s.objectURL(key))
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return 0, fmt.Errorf("failed to move downloaded object in place: %w", err)
+	}
+
+	return n, nil
+}
+
+// UploadObject implements ObjectStorage
+func (s *HTTPStorage) UploadObject(ctx context.Context, key string, src string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, func() (io.ReadCloser, int64, error) {
+		f, err := os.Open(src)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to open source file: %w", err)
+		}
+		stat, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return f, stat.Size(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to upload object: PUT %s: %s", s.objectURL(key), resp.Status)
+	}
+	return nil
+}
+
+// ListObjects implements ObjectStorage. Plain HTTP servers offer no way to list objects.
+func (s *HTTPStorage) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	return nil, fmt.Errorf("the HTTP remote cache does not support listing objects")
+}
+
+// DeleteObject implements ObjectStorage
+func (s *HTTPStorage) DeleteObject(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to delete object: DELETE %s: %s", s.objectURL(key), resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+)
+
+// fakeArtifactServer is a minimal artifact server which stores objects in memory
+type fakeArtifactServer struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	token    string
+	failures int
+	requests []string
+}
+
+func (f *fakeArtifactServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	if f.token != "" && r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if f.failures > 0 {
+		f.failures--
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/cache/")
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		content, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(content)
+		}
+	case http.MethodPut:
+		content, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		f.objects[key] = content
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if _, ok := f.objects[key]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestHTTPStorage(t *testing.T, srv *fakeArtifactServer, token string) *HTTPStorage {
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	storage, err := NewHTTPStorage(ts.URL+"/cache/", false, HTTPConfig{Token: token, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage
+}
+
+func TestHTTPStorage(t *testing.T) {
+	var (
+		ctx = context.Background()
+		srv = &fakeArtifactServer{
+			objects: map[string][]byte{"v1.tar.gz": []byte("v1 content")},
+			token:   "secret",
+		}
+		storage = newTestHTTPStorage(t, srv, "secret")
+		tmpdir  = t.TempDir()
+	)
+
+	exists, err := storage.HasObject(ctx, "v1.tar.gz")
+	if err != nil || !exists {
+		t.Errorf("HasObject(v1.tar.gz) = %v, %v; expected true, nil", exists, err)
+	}
+	exists, err = storage.HasObject(ctx, "v2.tar.gz")
+	if err != nil || exists {
+		t.Errorf("HasObject(v2.tar.gz) = %v, %v; expected false, nil", exists, err)
+	}
+
+	dest := filepath.Join(tmpdir, "v1.tar.gz")
+	n, err := storage.GetObject(ctx, "v1.tar.gz", dest)
+	if err != nil {
+		t.Fatalf("GetObject(v1.tar.gz) error = %v", err)
+	}
+	if fc, _ := os.ReadFile(dest); n != 10 || string(fc) != "v1 content" {
+		t.Errorf("GetObject(v1.tar.gz) downloaded %d bytes: %q", n, fc)
+	}
+	_, err = storage.GetObject(ctx, "v2.tar.gz", filepath.Join(tmpdir, "v2.tar.gz"))
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("GetObject(v2.tar.gz) error = %v; expected a not found error", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpdir, "v2.tar.gz")); !os.IsNotExist(err) {
+		t.Errorf("GetObject(v2.tar.gz) left a file behind")
+	}
+
+	src := filepath.Join(tmpdir, "v3.tar.gz")
+	err = os.WriteFile(src, []byte("v3 content"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.UploadObject(ctx, "v3.tar.gz", src)
+	if err != nil {
+		t.Fatalf("UploadObject(v3.tar.gz) error = %v", err)
+	}
+	if diff := cmp.Diff("v3 content", string(srv.objects["v3.tar.gz"])); diff != "" {
+		t.Errorf("UploadObject(v3.tar.gz) mismatch (-want +got):\n%s", diff)
+	}
+
+	err = storage.DeleteObject(ctx, "v3.tar.gz")
+	if err != nil {
+		t.Fatalf("DeleteObject(v3.tar.gz) error = %v", err)
+	}
+	if _, ok := srv.objects["v3.tar.gz"]; ok {
+		t.Errorf("DeleteObject(v3.tar.gz) did not delete the object")
+	}
+	err = storage.DeleteObject(ctx, "v3.tar.gz")
+	if err != nil {
+		t.Errorf("DeleteObject(v3.tar.gz) of a missing object error = %v; expected nil", err)
+	}
+}
+
+func TestHTTPStorageRetries(t *testing.T) {
+	srv := &fakeArtifactServer{
+		objects:  map[string][]byte{"v1.tar.gz": []byte("v1 content")},
+		failures: 2,
+	}
+	storage := newTestHTTPStorage(t, srv, "")
+
+	exists, err := storage.HasObject(context.Background(), "v1.tar.gz")
+	if err != nil || !exists {
+		t.Errorf("HasObject(v1.tar.gz) = %v, %v; expected true, nil", exists, err)
+	}
+	if len(srv.requests) != 3 {
+		t.Errorf("expected 3 requests, got %v", srv.requests)
+	}
+
+	srv.failures = 5
+	srv.requests = nil
+	_, err = storage.HasObject(context.Background(), "v1.tar.gz")
+	if err == nil {
+		t.Errorf("HasObject(v1.tar.gz) succeeded despite the server failing")
+	}
+	if len(srv.requests) != defaultHTTPMaxAttempts {
+		t.Errorf("expected %d requests, got %v", defaultHTTPMaxAttempts, srv.requests)
+	}
+}
+
+func TestHTTPStorageAuthError(t *testing.T) {
+	srv := &fakeArtifactServer{
+		objects: map[string][]byte{"v1.tar.gz": []byte("v1 content")},
+		token:   "secret",
+	}
+	storage := newTestHTTPStorage(t, srv, "wrong")
+
+	exists, err := storage.HasObject(context.Background(), "v1.tar.gz")
+	var authErr *AuthError
+	if exists || !errors.As(err, &authErr) {
+		t.Fatalf("HasObject(v1.tar.gz) = %v, %v; expected an auth error", exists, err)
+	}
+	if authErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, authErr.StatusCode)
+	}
+	if len(srv.requests) != 1 {
+		t.Errorf("auth errors must not be retried, got requests %v", srv.requests)
+	}
+}
+
+func TestHTTPCacheExistingPackages(t *testing.T) {
+	srv := &fakeArtifactServer{
+		objects: map[string][]byte{
+			"v1.tar.gz": []byte("v1 content"),
+			"v2.tar":    []byte("v2 content"),
+		},
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	rc, err := NewHTTPCache(&cache.RemoteConfig{BucketName: ts.URL + "/cache"}, HTTPConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs := []cache.Package{
+		s3TestPackage{versionStr: "v1", fullName: "pkg1"},
+		s3TestPackage{versionStr: "v2", fullName: "pkg2"},
+		s3TestPackage{versionStr: "v3", fullName: "pkg3"},
+	}
+	existing, err := rc.ExistingPackages(context.Background(), pkgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var act []string
+	for _, p := range pkgs {
+		if _, ok := existing[p]; ok {
+			act = append(act, p.FullName())
+		}
+	}
+	if diff := cmp.Diff([]string{"pkg1", "pkg2"}, act); diff != "" {
+		t.Errorf("ExistingPackages() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewHTTPStorageInvalidURL(t *testing.T) {
+	for _, u := range []string{"", "my-bucket", "ftp://example.com/cache", "http://"} {
+		_, err := NewHTTPStorage(u, false, HTTPConfig{})
+		if err == nil {
+			t.Errorf("NewHTTPStorage(%q) succeeded, expected an error", u)
+		}
+	}
+}
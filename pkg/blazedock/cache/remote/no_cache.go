@@ -2,6 +2,7 @@ package remote
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
 )
@@ -28,3 +29,8 @@ func (NoRemoteCache) Download(ctx context.Context, dst cache.LocalCache, pkgs []
 func (NoRemoteCache) Upload(ctx context.Context, src cache.LocalCache, pkgs []cache.Package) error {
 	return nil
 }
+
+// Delete fails as there is no remote cache the build artifacts could be deleted from
+func (NoRemoteCache) Delete(ctx context.Context, pkgs []cache.Package) error {
+	return fmt.Errorf("cannot delete from the remote cache: no remote cache is configured")
+}
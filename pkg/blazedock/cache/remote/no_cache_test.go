@@ -118,6 +118,16 @@ func TestUpload(t *testing.T) {
 	}
 }
 
+func TestDelete(t *testing.T) {
+	t.Parallel()
+
+	noCache := NewNoRemoteCache()
+	err := noCache.Delete(context.Background(), []cache.Package{mockPackage{version: "test1"}})
+	if err == nil {
+		t.Error("Delete() succeeded, expected an error as there is no remote cache")
+	}
+}
+
 func TestNoRemoteCacheImplementsInterface(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,72 @@
+package remote
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+)
+
+// normalizePrefix turns a remote cache prefix into a path prefix, s.t. "team-a", "team-a/" and "/team-a/" all place
+// objects under team-a/. An empty prefix stays empty.
+func normalizePrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// prefixedStorage places all objects of an ObjectStorage under a common prefix
+type prefixedStorage struct {
+	cache.ObjectStorage
+	prefix string
+}
+
+// withPrefix places all objects of the storage under the prefix. Without a prefix the storage is returned as is.
+func withPrefix(storage cache.ObjectStorage, prefix string) cache.ObjectStorage {
+	prefix = normalizePrefix(prefix)
+	if prefix == "" {
+		return storage
+	}
+	return &prefixedStorage{ObjectStorage: storage, prefix: prefix}
+}
+
+// HasObject implements ObjectStorage
+func (s *prefixedStorage) HasObject(ctx context.Context, key string) (bool, error) {
+	return s.ObjectStorage.HasObject(ctx, s.prefix+key)
+}
+
+// GetObject implements ObjectStorage
+func (s *prefixedStorage) GetObject(ctx context.Context, key string, dest string) (int64, error) {
+	return s.ObjectStorage.GetObject(ctx, s.prefix+key, dest)
+}
+
+// OpenObject implements ObjectStorage
+func (s *prefixedStorage) OpenObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.ObjectStorage.OpenObject(ctx, s.prefix+key)
+}
+
+// UploadObject implements ObjectStorage
+func (s *prefixedStorage) UploadObject(ctx context.Context, key string, src string) error {
+	return s.ObjectStorage.UploadObject(ctx, s.prefix+key, src)
+}
+
+// ListObjects implements ObjectStorage. The keys are returned without the prefix.
+func (s *prefixedStorage) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := s.ObjectStorage.ListObjects(ctx, s.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, strings.TrimPrefix(key, s.prefix))
+	}
+	return res, nil
+}
+
+// DeleteObject implements ObjectStorage
+func (s *prefixedStorage) DeleteObject(ctx context.Context, key string) error {
+	return s.ObjectStorage.DeleteObject(ctx, s.prefix+key)
+}
@@ -0,0 +1,87 @@
+package remote
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+)
+
+func TestNormalizePrefix(t *testing.T) {
+	tests := []struct {
+		Prefix      string
+		Expectation string
+	}{
+		{Prefix: "", Expectation: ""},
+		{Prefix: "/", Expectation: ""},
+		{Prefix: "team-a", Expectation: "team-a/"},
+		{Prefix: "/team-a/", Expectation: "team-a/"},
+		{Prefix: "teams/a", Expectation: "teams/a/"},
+	}
+	for _, test := range tests {
+		if act := normalizePrefix(test.Prefix); act != test.Expectation {
+			t.Errorf("normalizePrefix(%q) = %q; expected %q", test.Prefix, act, test.Expectation)
+		}
+	}
+}
+
+func TestHTTPCachePrefix(t *testing.T) {
+	var (
+		ctx = context.Background()
+		srv = &fakeArtifactServer{
+			objects: map[string][]byte{
+				"team-a/v1.tar.gz": []byte("v1 content"),
+				"v2.tar.gz":        []byte("v2 content of another team"),
+			},
+		}
+		localDir = t.TempDir()
+	)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	rc, err := NewHTTPCache(&cache.RemoteConfig{BucketName: ts.URL + "/cache", Prefix: "team-a"}, HTTPConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		pkg1 = s3TestPackage{versionStr: "v1", fullName: "pkg1"}
+		pkg2 = s3TestPackage{versionStr: "v2", fullName: "pkg2"}
+		pkg3 = s3TestPackage{versionStr: "v3", fullName: "pkg3"}
+	)
+	existing, err := rc.ExistingPackages(ctx, []cache.Package{pkg1, pkg2, pkg3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[cache.Package]struct{}{pkg1: {}}, existing); diff != "" {
+		t.Errorf("ExistingPackages() mismatch (-want +got):\n%s", diff)
+	}
+
+	err = os.WriteFile(filepath.Join(localDir, "v3.tar.gz"), []byte("v3 content"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.Upload(ctx, &testLocalCache{baseDir: localDir}, []cache.Package{pkg3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rc.Delete(ctx, []cache.Package{pkg1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for key := range srv.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if diff := cmp.Diff([]string{"team-a/v3.tar.gz", "v2.tar.gz"}, keys); diff != "" {
+		t.Errorf("remote cache objects mismatch (-want +got):\n%s", diff)
+	}
+}
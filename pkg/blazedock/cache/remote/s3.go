@@ -2,9 +2,12 @@ package remote
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,6 +15,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -58,10 +62,30 @@ func NewS3Cache(cfg *cache.RemoteConfig) (*S3Cache, error) {
 	if cfg.Region != "" {
 		awsCfg.Region = cfg.Region
 	}
+	if cfg.InsecureSkipVerify {
+		log.Warn("TLS certificate verification for the remote cache is disabled")
+		awsCfg.HTTPClient = awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+			if tr.TLSClientConfig == nil {
+				tr.TLSClientConfig = &tls.Config{}
+			}
+			//nolint:gosec
+			tr.TLSClientConfig.InsecureSkipVerify = true
+		})
+	}
 
-	storage := NewS3Storage(cfg.BucketName, &awsCfg)
+	var optFns []func(*s3.Options)
+	if cfg.Endpoint != "" {
+		// S3-compatible services (e.g. MinIO) usually don't support virtual-hosted-style bucket addressing
+		optFns = append(optFns, func(o *s3.Options) {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			o.UsePathStyle = true
+		})
+		log.WithField("endpoint", cfg.Endpoint).Debug("using custom S3 endpoint")
+	}
+
+	storage := NewS3Storage(cfg.BucketName, &awsCfg, optFns...)
 	return &S3Cache{
-		storage:     storage,
+		storage:     withPrefix(storage, cfg.Prefix),
 		cfg:         cfg,
 		workerCount: defaultWorkerCount,
 	}, nil
@@ -250,10 +274,7 @@ func (s *S3Cache) Download(ctx context.Context, dst cache.LocalCache, pkgs []cac
 
 		// Try downloading .tar.gz first with retry
 		gzKey := fmt.Sprintf("%s.tar.gz", version)
-		gzErr := withRetry(3, func() error {
-			_, err := s.storage.GetObject(ctx, gzKey, localPath)
-			return err
-		})
+		gzErr := s.downloadArtifact(ctx, gzKey, localPath)
 
 		if gzErr == nil {
 			log.WithFields(log.Fields{
@@ -280,10 +301,7 @@ func (s *S3Cache) Download(ctx context.Context, dst cache.LocalCache, pkgs []cac
 
 		// Try .tar if .tar.gz fails, also with retry
 		tarKey := fmt.Sprintf("%s.tar", version)
-		tarErr := withRetry(3, func() error {
-			_, err := s.storage.GetObject(ctx, tarKey, localPath)
-			return err
-		})
+		tarErr := s.downloadArtifact(ctx, tarKey, localPath)
 
 		if tarErr != nil {
 			// Check if this is a "not found" error
@@ -327,6 +345,61 @@ func (s *S3Cache) Download(ctx context.Context, dst cache.LocalCache, pkgs []cac
 	return nil
 }
 
+// VerifiesDownloads implements VerifyingRemoteCache
+func (s *S3Cache) VerifiesDownloads() bool {
+	return true
+}
+
+// downloadArtifact streams an artifact from the remote cache into the local cache. It's verified against the
+// checksum recorded in the remote cache while it's written, hence a corrupted download never ends up in the local
+// cache but is attempted again.
+//
+// The download is not piped into the extraction of the artifact: artifacts are extracted only once a package which
+// depends on them is built, possibly several times and by later builds, and always from the local cache.
+func (s *S3Cache) downloadArtifact(ctx context.Context, key, localPath string) error {
+	return withRetry(3, func() error {
+		body, err := s.storage.OpenObject(ctx, key)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		n, err := cache.WriteArtifact(localPath, body, s.remoteChecksum(ctx, key))
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			_ = cache.RemoveArtifact(localPath)
+			return fmt.Errorf("downloaded object validation failed: %s is empty", key)
+		}
+		return nil
+	})
+}
+
+// remoteChecksum fetches the checksum recorded for an artifact, if there is one. Artifacts uploaded by
+// older versions of blazedock don't have a checksum, hence a failure here is not an error.
+func (s *S3Cache) remoteChecksum(ctx context.Context, key string) string {
+	sumKey := cache.ChecksumFilename(key)
+	body, err := s.storage.OpenObject(ctx, sumKey)
+	if err != nil {
+		log.WithError(err).WithField("key", sumKey).Debug("no checksum found in remote cache")
+		return ""
+	}
+	defer body.Close()
+
+	// a checksum file holds a single line, anything longer is not one
+	content, err := io.ReadAll(io.LimitReader(body, 4096))
+	if err == nil {
+		var sum string
+		sum, err = cache.ParseChecksum(content, key)
+		if err == nil {
+			return sum
+		}
+	}
+	log.WithError(err).WithField("key", sumKey).Warn("cannot read checksum from remote cache")
+	return ""
+}
+
 // Upload implements RemoteCache
 func (s *S3Cache) Upload(ctx context.Context, src cache.LocalCache, pkgs []cache.Package) error {
 	var uploadErrors []error
@@ -348,6 +421,17 @@ func (s *S3Cache) Upload(ctx context.Context, src cache.LocalCache, pkgs []cache
 			return nil // Don't fail the entire operation
 		}
 
+		sumPath := cache.ChecksumFilename(localPath)
+		if _, err := os.Stat(sumPath); err == nil {
+			sumKey := cache.ChecksumFilename(key)
+			if err := s.storage.UploadObject(ctx, sumKey, sumPath); err != nil {
+				log.WithError(err).WithFields(log.Fields{
+					"package": p.FullName(),
+					"key":     sumKey,
+				}).Warn("failed to upload checksum to remote cache - continuing")
+			}
+		}
+
 		log.WithFields(log.Fields{
 			"package": p.FullName(),
 			"key":     key,
@@ -367,6 +451,27 @@ func (s *S3Cache) Upload(ctx context.Context, src cache.LocalCache, pkgs []cache
 	return nil // Always return nil to allow the build to continue
 }
 
+// Delete implements RemoteCache
+func (s *S3Cache) Delete(ctx context.Context, pkgs []cache.Package) error {
+	return s.processPackages(ctx, pkgs, func(ctx context.Context, p cache.Package) error {
+		version, err := p.Version()
+		if err != nil {
+			return fmt.Errorf("cannot compute version: %w", err)
+		}
+
+		for _, key := range []string{fmt.Sprintf("%s.tar.gz", version), fmt.Sprintf("%s.tar", version)} {
+			for _, k := range []string{key, cache.ChecksumFilename(key)} {
+				if err := s.storage.DeleteObject(ctx, k); err != nil {
+					return err
+				}
+			}
+		}
+
+		log.WithField("package", p.FullName()).Debug("deleted package from remote cache")
+		return nil
+	})
+}
+
 // s3ClientAPI is a subset of the S3 client interface we need
 type s3ClientAPI interface {
 	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
@@ -377,6 +482,7 @@ type s3ClientAPI interface {
 	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
 	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
 	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
 }
 
 // S3Storage implements ObjectStorage using AWS S3
@@ -386,10 +492,11 @@ type S3Storage struct {
 }
 
 // NewS3Storage creates a new S3 storage implementation
-func NewS3Storage(bucketName string, cfg *aws.Config) *S3Storage {
-	client := s3.NewFromConfig(*cfg, func(o *s3.Options) {
+func NewS3Storage(bucketName string, cfg *aws.Config, optFns ...func(*s3.Options)) *S3Storage {
+	optFns = append([]func(*s3.Options){func(o *s3.Options) {
 		o.DisableLogOutputChecksumValidationSkipped = true
-	})
+	}}, optFns...)
+	client := s3.NewFromConfig(*cfg, optFns...)
 	return &S3Storage{
 		client:     client,
 		bucketName: bucketName,
@@ -497,6 +604,23 @@ func (s *S3Storage) GetObject(ctx context.Context, key string, dest string) (int
 	return n, nil
 }
 
+// OpenObject implements ObjectStorage. Other than GetObject, which downloads large objects in parallel parts, the
+// object is streamed in a single request.
+func (s *S3Storage) OpenObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) || strings.Contains(err.Error(), "NotFound") || strings.Contains(err.Error(), "404") {
+			return nil, fmt.Errorf("object not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to download object: %w", err)
+	}
+	return out.Body, nil
+}
+
 // UploadObject implements ObjectStorage
 func (s *S3Storage) UploadObject(ctx context.Context, key string, src string) error {
 	file, err := os.Open(src)
@@ -560,3 +684,16 @@ func (s *S3Storage) ListObjects(ctx context.Context, prefix string) ([]string, e
 
 	return result, nil
 }
+
+// DeleteObject implements ObjectStorage
+func (s *S3Storage) DeleteObject(ctx context.Context, key string) error {
+	// S3 does not fail when deleting a key which does not exist
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	return nil
+}
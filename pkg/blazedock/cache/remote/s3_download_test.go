@@ -1,8 +1,12 @@
 package remote
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -66,6 +70,22 @@ func (m *mockS3Storage) GetObject(ctx context.Context, key string, dest string)
 	return int64(len(data)), nil
 }
 
+func (m *mockS3Storage) OpenObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	if m.failDownload {
+		return nil, errors.New("simulated download failure")
+	}
+
+	if m.downloadDelay > 0 {
+		time.Sleep(m.downloadDelay)
+	}
+
+	data, exists := m.objects[key]
+	if !exists {
+		return nil, errors.New("NotFound: object does not exist")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
 func (m *mockS3Storage) UploadObject(ctx context.Context, key string, src string) error {
 	data, err := os.ReadFile(src)
 	if err != nil {
@@ -86,6 +106,11 @@ func (m *mockS3Storage) ListObjects(ctx context.Context, prefix string) ([]strin
 	return result, nil
 }
 
+func (m *mockS3Storage) DeleteObject(ctx context.Context, key string) error {
+	delete(m.objects, key)
+	return nil
+}
+
 func TestS3CacheDownload(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -213,3 +238,59 @@ func TestS3CacheDownload(t *testing.T) {
 		})
 	}
 }
+
+func TestS3CacheDownloadVerifiesChecksum(t *testing.T) {
+	content := []byte("test data")
+	sum := sha256.Sum256(content)
+
+	tests := []struct {
+		name           string
+		checksum       string
+		wantDownloaded bool
+		wantChecksum   bool
+	}{
+		{name: "matching checksum", checksum: hex.EncodeToString(sum[:]) + "  v1.tar.gz\n", wantDownloaded: true, wantChecksum: true},
+		{name: "without checksum", wantDownloaded: true},
+		{name: "corrupted download", checksum: strings.Repeat("0", 64) + "  v1.tar.gz\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			localCache, err := local.NewFilesystemCache(t.TempDir())
+			if err != nil {
+				t.Fatalf("failed to create local cache: %v", err)
+			}
+			objects := map[string][]byte{"v1.tar.gz": content}
+			if tt.checksum != "" {
+				objects["v1.tar.gz.sha256"] = []byte(tt.checksum)
+			}
+			s3Cache := &S3Cache{
+				storage:     &mockS3Storage{objects: objects},
+				workerCount: 1,
+			}
+
+			pkg := s3TestPackage{versionStr: "v1", fullName: "pkg1"}
+			err = s3Cache.Download(context.Background(), localCache, []cache.Package{pkg})
+			if err != nil {
+				t.Fatalf("expected no error but got %v", err)
+			}
+
+			path, downloaded := localCache.Location(pkg)
+			if downloaded != tt.wantDownloaded {
+				t.Fatalf("downloaded = %v, expected %v", downloaded, tt.wantDownloaded)
+			}
+			if _, err := os.Stat(cache.ChecksumFilename(path)); (err == nil) != tt.wantChecksum {
+				t.Errorf("checksum recorded = %v, expected %v", err == nil, tt.wantChecksum)
+			}
+			if tt.wantChecksum {
+				if err := cache.VerifyChecksum(path, true); err != nil {
+					t.Errorf("downloaded artifact does not match its checksum: %v", err)
+				}
+			}
+			leftovers, _ := filepath.Glob(filepath.Join(localCache.Origin, "*.download-*"))
+			if len(leftovers) > 0 {
+				t.Errorf("download left temporary files behind: %v", leftovers)
+			}
+		})
+	}
+}
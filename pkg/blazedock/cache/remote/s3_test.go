@@ -68,6 +68,10 @@ func (m *mockS3Client) ListObjectsV2(ctx context.Context, params *s3.ListObjects
 	return &s3.ListObjectsV2Output{}, nil
 }
 
+func (m *mockS3Client) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	return &s3.DeleteObjectOutput{}, nil
+}
+
 func TestS3Cache_ExistingPackages(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -463,3 +467,47 @@ func (c *testLocalCache) Location(pkg cache.Package) (path string, exists bool)
 	// Neither exists - return the tar.gz path for future creation
 	return gzPath, false
 }
+
+func TestNewS3Cache_Endpoint(t *testing.T) {
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	tests := []struct {
+		name         string
+		endpoint     string
+		wantEndpoint *string
+		wantPath     bool
+	}{
+		{
+			name: "real S3",
+		},
+		{
+			name:         "S3-compatible endpoint",
+			endpoint:     "https://minio.internal:9000",
+			wantEndpoint: aws.String("https://minio.internal:9000"),
+			wantPath:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewS3Cache(&cache.RemoteConfig{BucketName: "test-bucket", Endpoint: tt.endpoint})
+			if err != nil {
+				t.Fatalf("NewS3Cache() error = %v", err)
+			}
+
+			client, ok := c.storage.(*S3Storage).client.(*s3.Client)
+			if !ok {
+				t.Fatalf("unexpected S3 client type %T", c.storage.(*S3Storage).client)
+			}
+			opts := client.Options()
+			if diff := cmp.Diff(tt.wantEndpoint, opts.BaseEndpoint); diff != "" {
+				t.Errorf("BaseEndpoint mismatch (-want +got):\n%s", diff)
+			}
+			if opts.UsePathStyle != tt.wantPath {
+				t.Errorf("UsePathStyle = %v, want %v", opts.UsePathStyle, tt.wantPath)
+			}
+		})
+	}
+}
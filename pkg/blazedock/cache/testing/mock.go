@@ -1,8 +1,10 @@
 package testing
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"path/filepath"
 	"sync"
 
@@ -85,6 +87,19 @@ func (m *MockObjectStorage) GetObject(ctx context.Context, key string, dest stri
 	return int64(len(content)), nil
 }
 
+// OpenObject implements ObjectStorage
+func (m *MockObjectStorage) OpenObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	content, exists := m.objects[key]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("object not found: %s", key)
+	}
+
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
 // UploadObject implements ObjectStorage
 func (m *MockObjectStorage) UploadObject(ctx context.Context, key string, src string) error {
 	m.mu.Lock()
@@ -107,6 +122,14 @@ func (m *MockObjectStorage) ListObjects(ctx context.Context, prefix string) ([]s
 	return result, nil
 }
 
+// DeleteObject implements ObjectStorage
+func (m *MockObjectStorage) DeleteObject(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
 // AddObject adds an object to the mock storage
 func (m *MockObjectStorage) AddObject(key string, content []byte) {
 	m.mu.Lock()
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"io"
 )
 
 // Package represents a build package that can be cached
@@ -18,6 +19,15 @@ type LocalCache interface {
 	Location(pkg Package) (path string, exists bool)
 }
 
+// DedupingCache is a LocalCache which stores byte-identical build artifacts only once
+type DedupingCache interface {
+	LocalCache
+
+	// Dedupe moves a build artifact into the content-addressed store of the cache and returns true
+	// if the store held the same content already
+	Dedupe(artifact string) (deduped bool, err error)
+}
+
 // RemoteCache can download and upload build artifacts into a local cache
 type RemoteCache interface {
 	// ExistingPackages returns existing cached build artifacts in the remote cache
@@ -29,6 +39,25 @@ type RemoteCache interface {
 
 	// Upload makes a best effort to upload the build artifacts to a remote cache
 	Upload(ctx context.Context, src LocalCache, pkgs []Package) error
+
+	// Delete removes the build artifacts of the packages from the remote cache.
+	// Packages which are not cached do not constitute an error.
+	Delete(ctx context.Context, pkgs []Package) error
+}
+
+// VerifyingRemoteCache is a RemoteCache which verifies build artifacts against their recorded checksum
+// while it downloads them, hence they need not be read once more to verify them
+type VerifyingRemoteCache interface {
+	RemoteCache
+
+	// VerifiesDownloads returns true if downloads are verified
+	VerifiesDownloads() bool
+}
+
+// VerifiesDownloads returns true if the remote cache verifies build artifacts while it downloads them
+func VerifiesDownloads(rc RemoteCache) bool {
+	vrc, ok := rc.(VerifyingRemoteCache)
+	return ok && vrc.VerifiesDownloads()
 }
 
 // ObjectStorage represents a generic object storage interface
@@ -40,11 +69,18 @@ type ObjectStorage interface {
 	// GetObject downloads an object to a local file
 	GetObject(ctx context.Context, key string, dest string) (int64, error)
 
+	// OpenObject returns the content of an object. It's downloaded as it's read, hence the caller can
+	// process it while it streams in. The caller must close the reader.
+	OpenObject(ctx context.Context, key string) (io.ReadCloser, error)
+
 	// UploadObject uploads a local file to remote storage
 	UploadObject(ctx context.Context, key string, src string) error
 
 	// ListObjects lists objects with the given prefix
 	ListObjects(ctx context.Context, prefix string) ([]string, error)
+
+	// DeleteObject removes an object. Deleting an object which does not exist is no error.
+	DeleteObject(ctx context.Context, key string) error
 }
 
 // Config holds configuration for cache implementations
@@ -66,4 +102,12 @@ type RemoteConfig struct {
 
 	// Endpoint for the remote service
 	Endpoint string
+
+	// InsecureSkipVerify disables TLS certificate verification of the remote service,
+	// e.g. for internal deployments using self-signed certificates
+	InsecureSkipVerify bool
+
+	// Prefix is prepended to the path of every object, s.t. several workspaces can share a bucket
+	// without their artifacts colliding. Empty places the objects at the root of the bucket.
+	Prefix string
 }
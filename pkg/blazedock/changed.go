@@ -0,0 +1,125 @@
+package blazedock
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// ChangedFiles returns the files which changed since the merge base of ref and HEAD, including uncommitted changes and
+// untracked files. Paths are relative to the working copy. Renamed files are listed under their old and new path.
+func (info *GitInfo) ChangedFiles(ref string) ([]string, error) {
+	if info.WorkingCopyLoc == "" {
+		return nil, xerrors.Errorf("not a Git working copy")
+	}
+
+	base, err := executeGitCommand(info.WorkingCopyLoc, "merge-base", ref, "HEAD")
+	if err != nil {
+		return nil, xerrors.Errorf("cannot find the merge base of %s and HEAD: %w", ref, err)
+	}
+	diff, err := executeGitCommand(info.WorkingCopyLoc, "-c", "core.quotePath=false", "diff", "--name-only", "--no-renames", base)
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := executeGitCommand(info.WorkingCopyLoc, "-c", "core.quotePath=false", "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+
+	idx := make(map[string]struct{})
+	for _, fn := range strings.Split(diff+"\n"+untracked, "\n") {
+		if fn == "" {
+			continue
+		}
+		idx[fn] = struct{}{}
+	}
+	res := make([]string, 0, len(idx))
+	for fn := range idx {
+		res = append(res, fn)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// AffectedPackages returns the packages affected by changes to files, sorted by their full name. Files are absolute paths.
+// A package is affected directly if one of its sources or the BUILD.yaml of its component changed. Changes to the
+// WORKSPACE.yaml affect all packages. Files which no longer exist are attributed to all packages of the component
+// they were in, as they may have been sources of those packages. Unless directOnly is set, all packages which
+// transitively depend on an affected package are affected as well.
+func (ws *Workspace) AffectedPackages(files []string, directOnly bool) []*Package {
+	sources := make(map[string][]*Package)
+	for _, pkg := range ws.Packages {
+		for _, src := range pkg.Sources {
+			sources[src] = append(sources[src], pkg)
+		}
+	}
+
+	affected := make(map[*Package]struct{})
+	for _, fn := range files {
+		if fn == filepath.Join(ws.Origin, "WORKSPACE.yaml") {
+			for _, pkg := range ws.Packages {
+				affected[pkg] = struct{}{}
+			}
+			break
+		}
+		for _, pkg := range sources[fn] {
+			affected[pkg] = struct{}{}
+		}
+
+		comp := ws.componentOf(fn)
+		if comp == nil {
+			continue
+		}
+		_, err := os.Stat(fn)
+		if fn == filepath.Join(comp.Origin, "BUILD.yaml") || os.IsNotExist(err) {
+			for _, pkg := range comp.Packages {
+				affected[pkg] = struct{}{}
+			}
+		}
+	}
+
+	res := make([]*Package, 0, len(affected))
+	for pkg := range affected {
+		res = append(res, pkg)
+	}
+	if !directOnly {
+		return WithTransitiveDependants(res)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].FullName() < res[j].FullName() })
+	return res
+}
+
+// WithTransitiveDependants returns the packages and all packages which transitively depend on them, sorted by their full name
+func WithTransitiveDependants(pkgs []*Package) []*Package {
+	idx := make(map[string]*Package, len(pkgs))
+	for _, pkg := range pkgs {
+		idx[pkg.FullName()] = pkg
+		for _, dep := range pkg.TransitiveDependants() {
+			idx[dep.FullName()] = dep
+		}
+	}
+
+	res := make([]*Package, 0, len(idx))
+	for _, pkg := range idx {
+		res = append(res, pkg)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].FullName() < res[j].FullName() })
+	return res
+}
+
+// componentOf returns the component whose origin is the closest parent directory of the file
+func (ws *Workspace) componentOf(fn string) *Component {
+	var res *Component
+	for _, comp := range ws.Components {
+		if fn != comp.Origin && !strings.HasPrefix(fn, comp.Origin+string(filepath.Separator)) {
+			continue
+		}
+		if res == nil || len(comp.Origin) > len(res.Origin) {
+			res = comp
+		}
+	}
+	return res
+}
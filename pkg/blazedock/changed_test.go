@@ -0,0 +1,133 @@
+package blazedock
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGitInfoChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	loc := t.TempDir()
+	git := func(args ...string) {
+		args = append([]string{"-c", "user.name=blazedock", "-c", "user.email=blazedock@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = loc
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+		}
+	}
+	writeFile := func(fn, content string) {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(loc, fn)), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(loc, fn), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("lib/lib.go", "package lib\n")
+	writeFile("lib/old.go", "package lib\n")
+	writeFile("app/main.go", "package main\n")
+	git("init", "-q", "-b", "main")
+	git("add", ".")
+	git("commit", "-qm", "base")
+	git("checkout", "-q", "-b", "feature")
+	writeFile("lib/lib.go", "package lib\n\nconst Version = 2\n")
+	git("mv", "lib/old.go", "lib/new.go")
+	git("commit", "-qam", "change lib")
+	writeFile("app/main.go", "package main\n\nfunc main() {}\n")
+	writeFile("app/untracked.go", "package main\n")
+
+	nfo, err := GetGitInfo(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	act, err := nfo.ChangedFiles("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectation := []string{"app/main.go", "app/untracked.go", "lib/lib.go", "lib/new.go", "lib/old.go"}
+	if diff := cmp.Diff(expectation, act); diff != "" {
+		t.Errorf("ChangedFiles() mismatch (-want +got):\n%s", diff)
+	}
+
+	_, err = nfo.ChangedFiles("does-not-exist")
+	if err == nil {
+		t.Errorf("ChangedFiles() accepted a ref which does not exist")
+	}
+}
+
+func TestAffectedPackages(t *testing.T) {
+	loc := t.TempDir()
+	for _, fn := range []string{"WORKSPACE.yaml", "lib/BUILD.yaml", "lib/lib.go", "app/BUILD.yaml", "app/main.go", "app/README.md", "docs/BUILD.yaml", "docs/index.md"} {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(loc, fn)), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(loc, fn), nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ws := &Workspace{Origin: loc, Components: make(map[string]*Component), Packages: make(map[string]*Package)}
+	addPackage := func(comp, name string, sources []string, deps ...*Package) *Package {
+		c, ok := ws.Components[comp]
+		if !ok {
+			c = &Component{W: ws, Name: comp, Origin: filepath.Join(loc, comp)}
+			ws.Components[comp] = c
+		}
+		pkg := &Package{C: c, PackageInternal: PackageInternal{Name: name}, dependencies: deps}
+		for _, src := range sources {
+			pkg.Sources = append(pkg.Sources, filepath.Join(loc, comp, src))
+		}
+		c.Packages = append(c.Packages, pkg)
+		ws.Packages[pkg.FullName()] = pkg
+		return pkg
+	}
+	lib := addPackage("lib", "lib", []string{"lib.go"})
+	addPackage("app", "app", []string{"main.go"}, lib)
+	addPackage("docs", "docs", []string{"index.md"})
+
+	tests := []struct {
+		Name        string
+		Files       []string
+		DirectOnly  bool
+		Expectation []string
+	}{
+		{Name: "source", Files: []string{"lib/lib.go"}, Expectation: []string{"app:app", "lib:lib"}},
+		{Name: "source direct only", Files: []string{"lib/lib.go"}, DirectOnly: true, Expectation: []string{"lib:lib"}},
+		{Name: "build file", Files: []string{"docs/BUILD.yaml"}, Expectation: []string{"docs:docs"}},
+		{Name: "removed file", Files: []string{"lib/removed.go"}, Expectation: []string{"app:app", "lib:lib"}},
+		{Name: "no source", Files: []string{"app/README.md", "README.md"}, Expectation: []string{}},
+		{Name: "workspace file", Files: []string{"WORKSPACE.yaml"}, DirectOnly: true, Expectation: []string{"app:app", "docs:docs", "lib:lib"}},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			files := make([]string, 0, len(test.Files))
+			for _, fn := range test.Files {
+				files = append(files, filepath.Join(loc, fn))
+			}
+			pkgs := ws.AffectedPackages(files, test.DirectOnly)
+
+			act := make([]string, 0, len(pkgs))
+			for _, p := range pkgs {
+				act = append(act, p.FullName())
+			}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("AffectedPackages() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,11 +1,16 @@
 package blazedock
 
 import (
+	"bufio"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 var (
@@ -19,8 +24,9 @@ func init() {
 	// Check for pigz (parallel gzip) for faster compression
 	pigz, err := exec.LookPath("pigz")
 	if err == nil {
-		// Use all available CPU cores by default
-		compressor = fmt.Sprintf("%s -p %d", pigz, cpuCores)
+		// Use all available CPU cores by default. Unlike gzip, pigz stores the current time in the header
+		// when compressing stdin - which would make the archive differ between otherwise identical builds.
+		compressor = fmt.Sprintf("%s -n -p %d", pigz, cpuCores)
 	}
 }
 
@@ -33,6 +39,19 @@ const (
 	NoCompr CompressionAlgorithm = "none"
 )
 
+// ParseCompressionAlgorithm parses the name of a compression algorithm, e.g. from BLAZEDOCK_CACHE_COMPRESSION.
+// An empty name selects gzip.
+func ParseCompressionAlgorithm(name string) (CompressionAlgorithm, error) {
+	switch algo := CompressionAlgorithm(strings.ToLower(strings.TrimSpace(name))); algo {
+	case "":
+		return Gzip, nil
+	case Gzip, Zstd, NoCompr:
+		return algo, nil
+	default:
+		return "", fmt.Errorf("unknown compression algorithm %q: must be one of gzip, zstd or none", name)
+	}
+}
+
 // TarOptions represents configuration options for creating tar archives
 type TarOptions struct {
 	// OutputFile is the path to the output .tar or .tar.gz file
@@ -53,6 +72,10 @@ type TarOptions struct {
 	// CompressionLevel allows setting compression level (1-9 for gzip/pigz)
 	CompressionLevel int
 
+	// OutputExtension is appended to OutputFile of compressed archives instead of the extension
+	// that belongs to the compression algorithm
+	OutputExtension string
+
 	// FilesFrom specifies a file containing a list of files to include
 	FilesFrom string
 
@@ -102,6 +125,13 @@ func WithCompressionLevel(level int) func(*TarOptions) {
 	}
 }
 
+// WithOutputExtension overrides the extension appended to the output file of compressed archives
+func WithOutputExtension(ext string) func(*TarOptions) {
+	return func(opts *TarOptions) {
+		opts.OutputExtension = ext
+	}
+}
+
 // WithFilesFrom specifies a file containing the list of files to archive
 func WithFilesFrom(filePath string) func(*TarOptions) {
 	return func(opts *TarOptions) {
@@ -138,8 +168,20 @@ func getCompressionCommand(algo CompressionAlgorithm, level int) string {
 func getDecompressionCommand(filename string) string {
 	switch {
 	case strings.HasSuffix(filename, ".gz"):
-		return decompressor
+		return getDecompressionCommandFor(Gzip)
 	case strings.HasSuffix(filename, ".zst"):
+		return getDecompressionCommandFor(Zstd)
+	default:
+		return ""
+	}
+}
+
+// getDecompressionCommandFor returns the decompression command of a compression algorithm
+func getDecompressionCommandFor(algo CompressionAlgorithm) string {
+	switch algo {
+	case Gzip:
+		return decompressor
+	case Zstd:
 		return "zstd -d"
 	default:
 		return ""
@@ -181,6 +223,9 @@ func BuildTarCommand(options ...func(*TarOptions)) []string {
 	// Add Linux-specific optimizations
 	if runtime.GOOS == "linux" {
 		cmd = append(cmd, "--sparse")
+		// Produce the same archive for the same content, regardless of when and by whom it was built.
+		// Otherwise the artifact digest differs between machines even though the package version is the same.
+		cmd = append(cmd, "--sort=name", "--mtime=@0", "--owner=0", "--group=0", "--numeric-owner")
 	}
 
 	// Handle files-from case specially
@@ -193,7 +238,10 @@ func BuildTarCommand(options ...func(*TarOptions)) []string {
 
 	// Add file extension based on compression algorithm if needed
 	if opts.UseCompression && opts.CompressionAlgorithm != NoCompr {
-		ext := getFileExtension(opts.CompressionAlgorithm)
+		ext := opts.OutputExtension
+		if ext == "" {
+			ext = getFileExtension(opts.CompressionAlgorithm)
+		}
 		if !strings.HasSuffix(opts.OutputFile, ext) {
 			opts.OutputFile = opts.OutputFile + ext
 		}
@@ -306,17 +354,70 @@ func isCompressedFile(filepath string) (CompressionAlgorithm, error) {
 		return NoCompr, fmt.Errorf("failed to read file header: %w", err)
 	}
 
+	return detectCompression(header), nil
+}
+
+// detectCompression determines the compression algorithm from the first bytes of a file
+func detectCompression(header []byte) CompressionAlgorithm {
 	// Check for gzip magic number (1F 8B)
-	if header[0] == 0x1F && header[1] == 0x8B {
-		return Gzip, nil
+	if len(header) >= 2 && header[0] == 0x1F && header[1] == 0x8B {
+		return Gzip
 	}
 
 	// Check for zstd magic number (28 b5 2f fd)
-	if header[0] == 0x28 && header[1] == 0xb5 && header[2] == 0x2f && header[3] == 0xfd {
-		return Zstd, nil
+	if len(header) >= 4 && header[0] == 0x28 && header[1] == 0xb5 && header[2] == 0x2f && header[3] == 0xfd {
+		return Zstd
+	}
+
+	return NoCompr
+}
+
+// openArchive opens a build artifact for reading and decompresses it according to its header.
+// Artifacts don't carry their compression in their name, hence we must not rely on the file extension.
+func openArchive(fn string) (io.ReadCloser, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	in := bufio.NewReader(f)
+	header, err := in.Peek(4)
+	if err != nil && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+
+	switch detectCompression(header) {
+	case Gzip:
+		gzin, err := gzip.NewReader(in)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &archiveReader{Reader: gzin, close: func() { gzin.Close() }, f: f}, nil
+	case Zstd:
+		zin, err := zstd.NewReader(in)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &archiveReader{Reader: zin, close: zin.Close, f: f}, nil
+	default:
+		return &archiveReader{Reader: in, f: f}, nil
 	}
+}
 
-	return NoCompr, nil
+type archiveReader struct {
+	io.Reader
+	close func()
+	f     *os.File
+}
+
+func (r *archiveReader) Close() error {
+	if r.close != nil {
+		r.close()
+	}
+	return r.f.Close()
 }
 
 // BuildUnTarCommand creates a command to extract tar archives
@@ -364,22 +465,17 @@ func BuildUnTarCommand(options ...func(*UnTarOptions)) ([]string, error) {
 
 	// Handle compression if needed
 	if opts.AutoDetectCompression {
-		// First check by file extension for efficiency
-		decomprCmd := getDecompressionCommand(opts.InputFile)
-
-		// If no match by extension, try to detect by file header
-		if decomprCmd == "" {
-			comprAlgo, err := isCompressedFile(opts.InputFile)
-			if err != nil {
+		// The header is authoritative because cache artifacts keep their name regardless of their compression.
+		// Only if we cannot read the file yet, we go by its extension.
+		var decomprCmd string
+		comprAlgo, err := isCompressedFile(opts.InputFile)
+		if err == nil {
+			decomprCmd = getDecompressionCommandFor(comprAlgo)
+		} else {
+			decomprCmd = getDecompressionCommand(opts.InputFile)
+			if decomprCmd == "" {
 				return nil, err
 			}
-
-			switch comprAlgo {
-			case Gzip:
-				decomprCmd = decompressor
-			case Zstd:
-				decomprCmd = "zstd -d"
-			}
 		}
 
 		if decomprCmd != "" {
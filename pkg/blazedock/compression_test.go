@@ -0,0 +1,209 @@
+package blazedock
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestOpenArchive(t *testing.T) {
+	const content = "hello world"
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	err := tw.WriteHeader(&tar.Header{Name: "./hello.txt", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = tw.Write([]byte(content))
+	tw.Close()
+
+	tests := []struct {
+		Name        string
+		Compress    func(w io.Writer) (io.WriteCloser, error)
+		Expectation CompressionAlgorithm
+	}{
+		{
+			Name:        "gzip",
+			Compress:    func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+			Expectation: Gzip,
+		},
+		{
+			Name:        "zstd",
+			Compress:    func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+			Expectation: Zstd,
+		},
+		{
+			Name:        "none",
+			Expectation: NoCompr,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			// all artifacts carry the same name, regardless of their compression
+			fn := filepath.Join(t.TempDir(), "artifact.tar.gz")
+			f, err := os.Create(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var w io.WriteCloser = f
+			if test.Compress != nil {
+				w, err = test.Compress(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err = w.Write(archive.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Close()
+			f.Close()
+
+			algo, err := isCompressedFile(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if algo != test.Expectation {
+				t.Errorf("isCompressedFile() = %s, want %s", algo, test.Expectation)
+			}
+
+			in, err := openArchive(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer in.Close()
+
+			tr := tar.NewReader(in)
+			hdr, err := tr.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			act, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(content, string(act)); hdr.Name != "./hello.txt" || diff != "" {
+				t.Errorf("openArchive() mismatch in %s (-want +got):\n%s", hdr.Name, diff)
+			}
+		})
+	}
+}
+
+func TestParseCompressionAlgorithm(t *testing.T) {
+	tests := []struct {
+		Input       string
+		Expectation CompressionAlgorithm
+		Error       bool
+	}{
+		{Input: "", Expectation: Gzip},
+		{Input: "gzip", Expectation: Gzip},
+		{Input: "ZSTD", Expectation: Zstd},
+		{Input: "none", Expectation: NoCompr},
+		{Input: "brotli", Error: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			act, err := ParseCompressionAlgorithm(test.Input)
+			if (err != nil) != test.Error {
+				t.Fatalf("ParseCompressionAlgorithm() error = %v, want error %v", err, test.Error)
+			}
+			if act != test.Expectation {
+				t.Errorf("ParseCompressionAlgorithm() = %s, want %s", act, test.Expectation)
+			}
+		})
+	}
+}
+
+// BenchmarkArchiveCompression compares the pack and unpack throughput of the cache compression algorithms
+// using the same tar commands a build uses.
+func BenchmarkArchiveCompression(b *testing.B) {
+	src := b.TempDir()
+	// Mimic a Go binary: a mix of incompressible and repetitive content
+	rnd := rand.New(rand.NewSource(42))
+	var (
+		content = make([]byte, 32<<20)
+		size    int64
+	)
+	for i := 0; i < len(content); i += 4096 {
+		if rnd.Intn(3) == 0 {
+			rnd.Read(content[i:min(i+4096, len(content))])
+		} else {
+			copy(content[i:], bytes.Repeat([]byte("blazedock"), 4096/9))
+		}
+	}
+	for i := 0; i < 4; i++ {
+		err := os.WriteFile(filepath.Join(src, fmt.Sprintf("bin%d", i)), content, 0755)
+		if err != nil {
+			b.Fatal(err)
+		}
+		size += int64(len(content))
+	}
+
+	for _, algo := range []CompressionAlgorithm{Gzip, Zstd, NoCompr} {
+		if cmd := getCompressionCommand(algo, 0); cmd != "" {
+			if _, err := exec.LookPath(strings.Fields(cmd)[0]); err != nil {
+				b.Logf("skipping %s: %v", algo, err)
+				continue
+			}
+		}
+
+		dst := filepath.Join(b.TempDir(), "artifact.tar")
+		pack := BuildTarCommand(
+			WithOutputFile(dst),
+			WithWorkingDir(src),
+			WithCompression(algo != NoCompr),
+			WithCompressionAlgorithm(algo),
+			WithOutputExtension(".gz"),
+		)
+		archive := dst
+		if algo != NoCompr {
+			archive += ".gz"
+		}
+
+		b.Run(fmt.Sprintf("pack/%s", algo), func(b *testing.B) {
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				out, err := exec.Command(pack[0], pack[1:]...).CombinedOutput()
+				if err != nil {
+					b.Fatalf("%v: %s", err, out)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("unpack/%s", algo), func(b *testing.B) {
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				tgt := b.TempDir()
+				unpack, err := BuildUnTarCommand(WithInputFile(archive), WithTargetDir(tgt))
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+
+				out, err := exec.Command(unpack[0], unpack[1:]...).CombinedOutput()
+				if err != nil {
+					b.Fatalf("%v: %s", err, out)
+				}
+			}
+		})
+
+		if fi, err := os.Stat(archive); err == nil {
+			b.Logf("%s: %d bytes packed to %d bytes", algo, size, fi.Size())
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package blazedock
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// hermeticEnvironment lists the host environment variables hermetic builds keep in addition to the allowlist
+var hermeticEnvironment = []string{"PATH", "HOME"}
+
+// envPassthrough returns the names of the host environment variables the build commands of the package get to see.
+// A nil result means that the complete host environment is passed through.
+func (p *Package) envPassthrough(hermetic bool) []string {
+	allowlist := p.EnvPassthrough
+	if allowlist == nil {
+		allowlist = p.C.W.EnvPassthrough
+	}
+	if hermetic {
+		return append(append([]string{}, hermeticEnvironment...), allowlist...)
+	}
+	return allowlist
+}
+
+// hostEnvironment returns the part of the host environment the build commands of the package run with
+func (p *Package) hostEnvironment(hermetic bool) []string {
+	allowlist := p.envPassthrough(hermetic)
+	if allowlist == nil {
+		return os.Environ()
+	}
+	env, _ := filterEnvironment(os.Environ(), allowlist)
+	return env
+}
+
+// PassedThroughEnvironment returns the names of the allowlisted host environment variables which are set,
+// or nil if the package has no allowlist and the build is not hermetic.
+func (p *Package) PassedThroughEnvironment(hermetic bool) []string {
+	allowlist := p.envPassthrough(hermetic)
+	if allowlist == nil {
+		return nil
+	}
+	_, names := filterEnvironment(os.Environ(), allowlist)
+	return names
+}
+
+// filterEnvironment returns the entries of environ whose names are on the allowlist, and their sorted names.
+// Allowlist entries ending in * match all names starting with what comes before it.
+func filterEnvironment(environ []string, allowlist []string) (env []string, names []string) {
+	names = []string{}
+	for _, e := range environ {
+		name, _, ok := strings.Cut(e, "=")
+		if !ok || !envAllowed(name, allowlist) {
+			continue
+		}
+		env = append(env, e)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return env, names
+}
+
+func envAllowed(name string, allowlist []string) bool {
+	for _, a := range allowlist {
+		if prefix, ok := strings.CutSuffix(a, "*"); ok && strings.HasPrefix(name, prefix) {
+			return true
+		}
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package blazedock
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFilterEnvironment(t *testing.T) {
+	environ := []string{"PATH=/usr/bin", "HOME=/root", "NPM_TOKEN=secret", "NPM_CONFIG_REGISTRY=https://npm.example.com", "AWS_REGION=eu-west-1"}
+	tests := []struct {
+		Name      string
+		Allowlist []string
+		Env       []string
+		Names     []string
+	}{
+		{"empty allowlist", []string{}, nil, []string{}},
+		{"exact names", []string{"HOME", "PATH", "UNSET"}, []string{"PATH=/usr/bin", "HOME=/root"}, []string{"HOME", "PATH"}},
+		{"prefix", []string{"NPM_*"}, []string{"NPM_TOKEN=secret", "NPM_CONFIG_REGISTRY=https://npm.example.com"}, []string{"NPM_CONFIG_REGISTRY", "NPM_TOKEN"}},
+		{"name is not a prefix", []string{"NPM"}, nil, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			env, names := filterEnvironment(environ, test.Allowlist)
+			if diff := cmp.Diff(test.Env, env); diff != "" {
+				t.Errorf("filterEnvironment() env mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.Names, names); diff != "" {
+				t.Errorf("filterEnvironment() names mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEnvPassthroughOverride(t *testing.T) {
+	t.Setenv("BLAZEDOCK_TEST_ALLOWED", "yes")
+	t.Setenv("BLAZEDOCK_TEST_OVERRIDE", "yes")
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("HOME", "/root")
+
+	ws := &Workspace{EnvPassthrough: []string{"BLAZEDOCK_TEST_ALLOWED"}}
+	tests := []struct {
+		Name        string
+		Workspace   *Workspace
+		Passthrough []string
+		Hermetic    bool
+		Expectation []string
+	}{
+		{"no allowlist", &Workspace{}, nil, false, nil},
+		{"workspace allowlist", ws, nil, false, []string{"BLAZEDOCK_TEST_ALLOWED"}},
+		{"package overrides workspace", ws, []string{"BLAZEDOCK_TEST_OVERRIDE"}, false, []string{"BLAZEDOCK_TEST_OVERRIDE"}},
+		{"package passes nothing", ws, []string{}, false, []string{}},
+		{"hermetic without allowlist", &Workspace{}, nil, true, []string{"HOME", "PATH"}},
+		{"hermetic with allowlist", ws, nil, true, []string{"BLAZEDOCK_TEST_ALLOWED", "HOME", "PATH"}},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			pkg := &Package{
+				C:               &Component{W: test.Workspace},
+				PackageInternal: PackageInternal{EnvPassthrough: test.Passthrough},
+			}
+			act := pkg.PassedThroughEnvironment(test.Hermetic)
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("PassedThroughEnvironment() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package blazedock
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+)
+
+// InputManifest lists the inputs the version of a package was computed from. It is stored next to the build
+// artifact in the local cache, s.t. a later rebuild of the package can be explained.
+type InputManifest struct {
+	Package string `yaml:"package"`
+	Version string `yaml:"version"`
+	// Inputs are the lines of the version manifest, with the values of secret build arguments replaced by their hash
+	Inputs []string `yaml:"inputs"`
+	// Definition is the package definition the definition hash of the version manifest is computed from
+	Definition map[string]interface{} `yaml:"definition,omitempty"`
+}
+
+// InputManifest produces the input manifest of the package
+func (p *Package) InputManifest() (*InputManifest, error) {
+	version, err := p.Version()
+	if err != nil {
+		return nil, err
+	}
+	var manifest bytes.Buffer
+	err = p.WriteVersionManifest(&manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &InputManifest{
+		Package: p.FullName(),
+		Version: version,
+	}
+	for _, line := range strings.Split(manifest.String(), "\n") {
+		if line == "" {
+			continue
+		}
+		if arg, ok := strings.CutPrefix(line, "arg "); ok {
+			if name, value, ok := strings.Cut(arg, ": "); ok && p.C.W.IsSecretArgument(name) {
+				// the manifest must not disclose the secret, but still tell if it changed
+				sum := sha256.Sum256([]byte(value))
+				line = fmt.Sprintf("arg %s: sha256:%s", name, hex.EncodeToString(sum[:]))
+			}
+		}
+		res.Inputs = append(res.Inputs, line)
+	}
+	err = yaml.Unmarshal(p.Definition, &res.Definition)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot parse definition of %s: %w", p.FullName(), err)
+	}
+	return res, nil
+}
+
+// writeInputManifest stores the input manifest of a package next to its build artifact
+func writeInputManifest(p *Package, artifact string) error {
+	mf, err := p.InputManifest()
+	if err != nil {
+		return err
+	}
+	fc, err := yaml.Marshal(mf)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cache.InputManifestFilename(artifact), fc, 0644)
+}
+
+// previousInputManifests returns the most recently written input manifest of each package in the cache directory,
+// indexed by package name. Packages which were never built using this cache have none.
+func previousInputManifests(cacheDir string) (map[string]*InputManifest, error) {
+	fns, err := filepath.Glob(filepath.Join(cacheDir, "*"+cache.InputManifestSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		res     = make(map[string]*InputManifest)
+		resTime = make(map[string]int64)
+	)
+	for _, fn := range fns {
+		stat, err := os.Stat(fn)
+		if err != nil {
+			continue
+		}
+		fc, err := os.ReadFile(fn)
+		if err != nil {
+			return nil, err
+		}
+		var mf InputManifest
+		err = yaml.Unmarshal(fc, &mf)
+		if err != nil {
+			log.WithError(err).WithField("path", fn).Debug("ignoring unreadable input manifest")
+			continue
+		}
+		if t, ok := resTime[mf.Package]; ok && stat.ModTime().UnixNano() <= t {
+			continue
+		}
+		res[mf.Package], resTime[mf.Package] = &mf, stat.ModTime().UnixNano()
+	}
+	return res, nil
+}
+
+// InputKind classifies the inputs of a package
+type InputKind string
+
+const (
+	// InputSource is a source file of the package
+	InputSource InputKind = "source"
+	// InputDependency is a package the package depends on
+	InputDependency InputKind = "dependency"
+	// InputArgument is a build argument the package depends on
+	InputArgument InputKind = "argument"
+	// InputDefinition is a field of the package definition in the BUILD.yaml, e.g. config.commands
+	InputDefinition InputKind = "definition"
+	// InputEnvironment is the environment manifest of the workspace, e.g. the versions of the tools
+	InputEnvironment InputKind = "environment"
+	// InputBuildProcess covers the build process and provenance settings of blazedock itself
+	InputBuildProcess InputKind = "build process"
+	// InputCacheSalt is the cache salt of the workspace or $BLAZEDOCK_CACHE_SALT
+	InputCacheSalt InputKind = "cache salt"
+)
+
+// InputChange is an input of a package which differs between two versions of the package
+type InputChange struct {
+	Kind InputKind
+	Name string
+	// Old is empty if the input was added
+	Old string
+	// New is empty if the input was removed
+	New string
+}
+
+func (c InputChange) String() string {
+	name := string(c.Kind)
+	if c.Name != "" {
+		name += " " + c.Name
+	}
+	switch {
+	case c.Old == "":
+		return name + " was added"
+	case c.New == "":
+		return name + " was removed"
+	case c.Kind == InputSource:
+		// content hashes don't mean anything to the reader
+		return name + " changed"
+	default:
+		return fmt.Sprintf("%s changed: %s -> %s", name, c.Old, c.New)
+	}
+}
+
+type manifestInput struct {
+	Kind  InputKind
+	Name  string
+	Value string
+}
+
+// parseManifestInput classifies a line of the version manifest (see WriteVersionManifest)
+func parseManifestInput(line string) manifestInput {
+	for prefix, kind := range map[string]InputKind{
+		"buildProcessVersion: ": InputBuildProcess,
+		"provenance: ":          InputBuildProcess,
+		"environment: ":         InputEnvironment,
+		"cacheSalt: ":           InputCacheSalt,
+		"envCacheSalt: ":        InputCacheSalt,
+		"definition: ":          InputDefinition,
+		"platforms: ":           InputDefinition,
+		"layout: ":              InputDefinition,
+	} {
+		if value, ok := strings.CutPrefix(line, prefix); ok {
+			return manifestInput{Kind: kind, Name: strings.TrimSuffix(prefix, ": "), Value: value}
+		}
+	}
+	if arg, ok := strings.CutPrefix(line, "arg "); ok {
+		name, value, _ := strings.Cut(arg, ": ")
+		return manifestInput{Kind: InputArgument, Name: name, Value: value}
+	}
+
+	// sources are listed as <path>:<content hash>, dependencies as <component>:<package>.<version>
+	if idx := strings.LastIndex(line, ":"); idx > 0 {
+		if _, err := hex.DecodeString(line[idx+1:]); err == nil {
+			return manifestInput{Kind: InputSource, Name: line[:idx], Value: line[idx+1:]}
+		}
+	}
+	if idx := strings.LastIndex(line, "."); idx > 0 {
+		return manifestInput{Kind: InputDependency, Name: line[:idx], Value: line[idx+1:]}
+	}
+	return manifestInput{Kind: InputBuildProcess, Value: line}
+}
+
+// DiffInputManifests lists the inputs which differ between the previous and the current input manifest of a package
+func DiffInputManifests(prev, cur *InputManifest) []InputChange {
+	index := func(mf *InputManifest) map[string]manifestInput {
+		res := make(map[string]manifestInput, len(mf.Inputs))
+		for _, line := range mf.Inputs {
+			in := parseManifestInput(line)
+			res[string(in.Kind)+" "+in.Name] = in
+		}
+		return res
+	}
+	var (
+		prevInputs = index(prev)
+		curInputs  = index(cur)
+		res        []InputChange
+	)
+	for key, c := range curInputs {
+		p, ok := prevInputs[key]
+		if ok && p.Value == c.Value {
+			continue
+		}
+		if c.Kind == InputDefinition && c.Name == "definition" {
+			// the definition hash tells us nothing, the fields of the definition do
+			continue
+		}
+		res = append(res, InputChange{Kind: c.Kind, Name: c.Name, Old: p.Value, New: c.Value})
+	}
+	for key, p := range prevInputs {
+		if _, ok := curInputs[key]; !ok {
+			res = append(res, InputChange{Kind: p.Kind, Name: p.Name, Old: p.Value})
+		}
+	}
+
+	prevFields, curFields := make(map[string]string), make(map[string]string)
+	flattenDefinition("", prev.Definition, prevFields)
+	flattenDefinition("", cur.Definition, curFields)
+	for name, c := range curFields {
+		if p := prevFields[name]; p != c {
+			res = append(res, InputChange{Kind: InputDefinition, Name: name, Old: p, New: c})
+		}
+	}
+	for name, p := range prevFields {
+		if _, ok := curFields[name]; !ok {
+			res = append(res, InputChange{Kind: InputDefinition, Name: name, Old: p})
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Kind != res[j].Kind {
+			return res[i].Kind < res[j].Kind
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// flattenDefinition lists the fields of a package definition under their dotted path. Lists are compared as a whole.
+func flattenDefinition(path string, def map[string]interface{}, res map[string]string) {
+	for k, v := range def {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			flattenDefinition(p, m, res)
+			continue
+		}
+		res[p] = fmt.Sprint(v)
+	}
+}
+
+// explainRebuilds writes why the packages which are about to be built are not in the local cache
+func explainRebuilds(out io.Writer, pkgs []*Package, status map[*Package]PackageBuildStatus, localCache cache.LocalCache) error {
+	// the input manifests are read once per cache directory, and only if a package is rebuilt at all
+	previous := make(map[string]map[string]*InputManifest)
+	for _, p := range pkgs {
+		if status[p] != PackageNotBuiltYet {
+			continue
+		}
+		if p.Ephemeral {
+			fmt.Fprintf(out, "%s is ephemeral and always rebuilt\n", p.FullName())
+			continue
+		}
+
+		loc, _ := localCache.Location(p)
+		if loc == "" {
+			continue
+		}
+		dir := filepath.Dir(loc)
+		idx, ok := previous[dir]
+		if !ok {
+			var err error
+			idx, err = previousInputManifests(dir)
+			if err != nil {
+				return err
+			}
+			previous[dir] = idx
+		}
+		prev := idx[p.FullName()]
+		if prev == nil {
+			fmt.Fprintf(out, "%s is built for the first time: no previous build in the local cache\n", p.FullName())
+			continue
+		}
+		cur, err := p.InputManifest()
+		if err != nil {
+			return err
+		}
+		if prev.Version == cur.Version {
+			fmt.Fprintf(out, "%s is rebuilt although its inputs did not change: the artifact is missing from the local cache or corrupted\n", p.FullName())
+			continue
+		}
+
+		fmt.Fprintf(out, "%s is rebuilt because its inputs changed since the build of %s:\n", p.FullName(), prev.Version)
+		changes := DiffInputManifests(prev, cur)
+		if len(changes) == 0 {
+			fmt.Fprintf(out, "  no input differs, the version manifest format may have changed\n")
+		}
+		for _, c := range changes {
+			fmt.Fprintf(out, "  %s\n", c)
+		}
+	}
+	return nil
+}
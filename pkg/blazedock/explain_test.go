@@ -0,0 +1,161 @@
+package blazedock
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache/local"
+)
+
+func TestDiffInputManifests(t *testing.T) {
+	prev := &InputManifest{
+		Inputs: []string{
+			"buildProcessVersion: 1",
+			"environment: env1",
+			"definition: def1",
+			"arg version: 1.0",
+			"comp:dep.aaaa",
+			"comp/main.go:0123",
+			"comp/old.go:4567",
+		},
+		Definition: map[string]interface{}{
+			"name":   "lib",
+			"config": map[string]interface{}{"commands": []interface{}{"make"}, "dontTest": false},
+		},
+	}
+	cur := &InputManifest{
+		Inputs: []string{
+			"buildProcessVersion: 1",
+			"environment: env1",
+			"definition: def2",
+			"arg version: 1.1",
+			"comp:dep.bbbb",
+			"comp/main.go:89ab",
+			"comp/new.go:cdef",
+		},
+		Definition: map[string]interface{}{
+			"name":   "lib",
+			"config": map[string]interface{}{"commands": []interface{}{"make", "all"}, "dontTest": false},
+		},
+	}
+
+	expectation := []string{
+		"argument version changed: 1.0 -> 1.1",
+		"definition config.commands changed: [make] -> [make all]",
+		"dependency comp:dep changed: aaaa -> bbbb",
+		"source comp/main.go changed",
+		"source comp/new.go was added",
+		"source comp/old.go was removed",
+	}
+	var act []string
+	for _, c := range DiffInputManifests(prev, cur) {
+		act = append(act, c.String())
+	}
+	if diff := cmp.Diff(expectation, act); diff != "" {
+		t.Errorf("DiffInputManifests() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestExplainRebuild(t *testing.T) {
+	t.Setenv(EnvvarBuildDir, t.TempDir())
+
+	loc := t.TempDir()
+	writeFiles := func(files map[string]string) {
+		for fn, content := range files {
+			fn = filepath.Join(loc, fn)
+			if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	writeFiles(map[string]string{
+		"WORKSPACE.yaml":  "",
+		"comp/lib.txt":    "hello world\n",
+		"comp/BUILD.yaml": "packages:\n- name: lib\n  type: generic\n  srcs:\n  - lib.txt\n  config:\n    commands:\n    - [\"cp\", \"lib.txt\", \"lib.out\"]\n",
+	})
+
+	lc, err := local.NewFilesystemCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	build := func() string {
+		ws, err := FindWorkspace(loc, Arguments{}, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		err = Build(ws.Packages["comp:lib"], WithLocalCache(lc), WithReporter(&NoopReporter{}), WithExplain(&out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	if out := build(); !strings.Contains(out, "comp:lib is built for the first time") {
+		t.Errorf("unexpected explanation of the first build: %q", out)
+	}
+	manifests, _ := filepath.Glob(filepath.Join(lc.Origin, "*"+cache.InputManifestSuffix))
+	if len(manifests) != 1 {
+		t.Fatalf("expected one input manifest, found %v", manifests)
+	}
+	if _, err := os.Stat(strings.TrimSuffix(manifests[0], cache.InputManifestSuffix)); err != nil {
+		t.Errorf("input manifest %s is not next to the artifact: %v", manifests[0], err)
+	}
+	if out := build(); out != "" {
+		t.Errorf("expected no explanation for a cache hit, got %q", out)
+	}
+
+	writeFiles(map[string]string{"comp/lib.txt": "hello again\n"})
+	if out := build(); !strings.Contains(out, "source comp/lib.txt changed") {
+		t.Errorf("expected the explanation to name the changed source, got %q", out)
+	}
+}
+
+func TestPreviousInputManifests(t *testing.T) {
+	dir := t.TempDir()
+	manifests := []struct {
+		Artifact string
+		Content  string
+		Age      time.Duration
+	}{
+		{"v1.tar.gz", "package: comp:app\nversion: v1\n", 2 * time.Hour},
+		{"v2.tar.gz", "package: comp:app\nversion: v2\n", time.Hour},
+		{"v3.tar.gz", "package: comp:lib\nversion: v3\n", 3 * time.Hour},
+		{"v4.tar.gz", "package: [\n", 0},
+	}
+	for _, mf := range manifests {
+		fn := cache.InputManifestFilename(filepath.Join(dir, mf.Artifact))
+		err := os.WriteFile(fn, []byte(mf.Content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-mf.Age)
+		err = os.Chtimes(fn, mtime, mtime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx, err := previousInputManifests(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	act := make(map[string]string, len(idx))
+	for name, mf := range idx {
+		act[name] = mf.Version
+	}
+	expected := map[string]string{"comp:app": "v2", "comp:lib": "v3"}
+	if diff := cmp.Diff(expected, act); diff != "" {
+		t.Errorf("previousInputManifests() mismatch (-want +got):\n%s", diff)
+	}
+}
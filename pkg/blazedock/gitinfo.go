@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -14,6 +16,9 @@ import (
 const (
 	gitDirName     = ".git"
 	gitStatusError = 128
+
+	// shortCommitLength is the number of hex digits of an abbreviated commit hash, as used by git by default
+	shortCommitLength = 7
 )
 
 // GitError represents an error that occurred during a Git operation
@@ -33,13 +38,30 @@ type GitInfo struct {
 	WorkingCopyLoc string
 	// Commit is the current HEAD commit hash
 	Commit string
+	// ShortCommit is the abbreviated HEAD commit hash
+	ShortCommit string
 	// Origin is the remote origin URL
 	Origin string
+	// DirtyPaths lists the modified and untracked files relative to the component origin.
+	// It is only populated by Component.Git when asked to using WithDirtyPaths.
+	DirtyPaths []string
+	// Submodules lists the initialized submodules of the working copy, including nested ones
+	Submodules []GitSubmodule
 
 	dirty      bool
 	dirtyFiles map[string]struct{}
 }
 
+// GitSubmodule is a submodule checked out in a Git working copy
+type GitSubmodule struct {
+	// Path is the location of the submodule relative to the working copy
+	Path string
+	// Commit is the commit checked out in the submodule
+	Commit string
+	// Origin is the remote origin URL of the submodule
+	Origin string
+}
+
 // executeGitCommand is a helper function to execute Git commands and handle their output
 func executeGitCommand(dir string, args ...string) (string, error) {
 	cmd := exec.Command("git", args...)
@@ -71,12 +93,32 @@ func GetGitInfo(loc string) (*GitInfo, error) {
 		WorkingCopyLoc: loc,
 		Commit:         commit,
 	}
+	if len(commit) > shortCommitLength {
+		res.ShortCommit = commit[:shortCommitLength]
+	}
 
 	origin, err := executeGitCommand(loc, "config", "--get", "remote.origin.url")
 	if err == nil {
 		res.Origin = origin
 	}
 
+	if _, err := os.Stat(filepath.Join(loc, ".gitmodules")); err == nil {
+		status, err := executeGitCommand(loc, "submodule", "status", "--recursive")
+		if err != nil {
+			return nil, err
+		}
+		res.Submodules = parseGitSubmoduleStatus(status)
+		for i, sm := range res.Submodules {
+			// submodules are cloned with their absolute URL as origin, even if .gitmodules lists a relative one
+			origin, err := executeGitCommand(filepath.Join(loc, sm.Path), "config", "--get", "remote.origin.url")
+			if err != nil {
+				log.WithField("submodule", sm.Path).WithError(err).Debug("submodule has no origin")
+				continue
+			}
+			res.Submodules[i].Origin = origin
+		}
+	}
+
 	status, err := executeGitCommand(loc, "status", "--porcelain")
 	if serr, ok := err.(*exec.ExitError); ok && serr.ExitCode() != gitStatusError {
 		log.WithFields(log.Fields{
@@ -125,6 +167,27 @@ func parseGitStatus(out []byte) (files map[string]struct{}, err error) {
 	return
 }
 
+// parseGitSubmoduleStatus parses the output of "git submodule status". Submodules which are not initialized are skipped.
+func parseGitSubmoduleStatus(out string) []GitSubmodule {
+	var res []GitSubmodule
+	for _, l := range strings.Split(out, "\n") {
+		if len(l) < 2 || l[0] == '-' {
+			continue
+		}
+		// the first character denotes the state of the submodule, followed by the commit, path and optionally its description.
+		// The output is trimmed, hence the first line may have lost its state if it was a blank.
+		if strings.ContainsRune(" +U", rune(l[0])) {
+			l = l[1:]
+		}
+		segs := strings.Fields(l)
+		if len(segs) < 2 {
+			continue
+		}
+		res = append(res, GitSubmodule{Path: segs[1], Commit: segs[0]})
+	}
+	return res
+}
+
 // IsDirty returns whether the working copy has any modifications
 func (info *GitInfo) IsDirty() bool {
 	return info.dirty
@@ -141,8 +204,33 @@ func (info *GitInfo) HasDirtyFile(file string) bool {
 
 	file = strings.TrimPrefix(file, info.WorkingCopyLoc)
 	file = strings.TrimPrefix(file, "/")
-	_, isDirty := info.dirtyFiles[file]
-	return isDirty
+	if _, isDirty := info.dirtyFiles[file]; isDirty {
+		return true
+	}
+	// git status lists modified submodules and untracked directories rather than the files therein
+	for dir := path.Dir(file); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if _, isDirty := info.dirtyFiles[dir]; isDirty {
+			return true
+		}
+		if _, isDirty := info.dirtyFiles[dir+"/"]; isDirty {
+			return true
+		}
+	}
+	return false
+}
+
+// dirtyPathsIn returns the dirty files below dir, relative to dir
+func (info *GitInfo) dirtyPathsIn(dir string) []string {
+	var res []string
+	for f := range info.dirtyFiles {
+		rel, err := filepath.Rel(dir, filepath.Join(info.WorkingCopyLoc, f))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		res = append(res, rel)
+	}
+	sort.Strings(res)
+	return res
 }
 
 // DirtyFiles returns true if a single file of the file list is dirty
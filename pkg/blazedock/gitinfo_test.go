@@ -2,6 +2,10 @@ package blazedock
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -213,6 +217,28 @@ func TestGitInfoHasDirtyFile(t *testing.T) {
 			file: "bar",
 			want: false,
 		},
+		{
+			name: "file in modified submodule",
+			info: &GitInfo{
+				dirty: true,
+				dirtyFiles: map[string]struct{}{
+					"vendor/lib": {},
+				},
+			},
+			file: "vendor/lib/pkg/foo.go",
+			want: true,
+		},
+		{
+			name: "file in untracked directory",
+			info: &GitInfo{
+				dirty: true,
+				dirtyFiles: map[string]struct{}{
+					"generated/": {},
+				},
+			},
+			file: "generated/foo.go",
+			want: true,
+		},
 		{
 			name: "dirty working copy with file in working copy",
 			info: &GitInfo{
@@ -235,3 +261,147 @@ func TestGitInfoHasDirtyFile(t *testing.T) {
 		})
 	}
 }
+
+func TestComponentGitDirtyPaths(t *testing.T) {
+	nfo := &GitInfo{
+		WorkingCopyLoc: "/workspace",
+		dirty:          true,
+		dirtyFiles: map[string]struct{}{
+			"components/foo/main.go":       {},
+			"components/foo/pkg/util.go":   {},
+			"components/foobar/BUILD.yaml": {},
+			"README.md":                    {},
+		},
+	}
+
+	tests := []struct {
+		Name        string
+		Origin      string
+		Options     []GitOption
+		Expectation []string
+	}{
+		{
+			Name:   "without option",
+			Origin: "/workspace/components/foo",
+		},
+		{
+			Name:        "component",
+			Origin:      "/workspace/components/foo",
+			Options:     []GitOption{WithDirtyPaths()},
+			Expectation: []string{"main.go", "pkg/util.go"},
+		},
+		{
+			Name:        "workspace root",
+			Origin:      "/workspace",
+			Options:     []GitOption{WithDirtyPaths()},
+			Expectation: []string{"README.md", "components/foo/main.go", "components/foo/pkg/util.go", "components/foobar/BUILD.yaml"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			comp := &Component{Origin: test.Origin, git: nfo}
+			act := comp.Git(test.Options...).DirtyPaths
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("Git().DirtyPaths mismatch (-want +got):\n%s", diff)
+			}
+			if nfo.DirtyPaths != nil {
+				t.Errorf("Git() modified the shared Git info")
+			}
+		})
+	}
+}
+
+func TestParseGitSubmoduleStatus(t *testing.T) {
+	// executeGitCommand trims the output, which drops the state of the first submodule
+	in := strings.TrimSpace(strings.Join([]string{
+		" a5a7879776d564341e8dba483039b1398dcc848f vendor/lib (heads/main)",
+		"+b5a7879776d564341e8dba483039b1398dcc848f vendor/modified (v1.0.0-1-gb5a7879)",
+		"-c5a7879776d564341e8dba483039b1398dcc848f vendor/uninitialized",
+		" d5a7879776d564341e8dba483039b1398dcc848f vendor/lib/nested",
+	}, "\n"))
+	expectation := []GitSubmodule{
+		{Path: "vendor/lib", Commit: "a5a7879776d564341e8dba483039b1398dcc848f"},
+		{Path: "vendor/modified", Commit: "b5a7879776d564341e8dba483039b1398dcc848f"},
+		{Path: "vendor/lib/nested", Commit: "d5a7879776d564341e8dba483039b1398dcc848f"},
+	}
+	if diff := cmp.Diff(expectation, parseGitSubmoduleStatus(in)); diff != "" {
+		t.Errorf("parseGitSubmoduleStatus() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGitSubmoduleMaterials(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	git := func(dir string, args ...string) string {
+		args = append([]string{"-c", "user.name=blazedock", "-c", "user.email=blazedock@example.com", "-c", "protocol.file.allow=always"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	writeFile := func(fn, content string) {
+		err := os.MkdirAll(filepath.Dir(fn), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(fn, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		base = t.TempDir()
+		lib  = filepath.Join(base, "lib")
+		loc  = filepath.Join(base, "workspace")
+	)
+	writeFile(filepath.Join(lib, "lib.go"), "package lib\n")
+	git(base, "init", "-q", lib)
+	git(lib, "add", ".")
+	git(lib, "commit", "-qm", "lib")
+	libCommit := git(lib, "rev-parse", "HEAD")
+
+	writeFile(filepath.Join(loc, "WORKSPACE.yaml"), "")
+	git(base, "init", "-q", loc)
+	git(loc, "remote", "add", "origin", "https://git.example.com/workspace")
+	git(loc, "submodule", "add", "-q", lib, "vendor/lib")
+	git(loc, "add", ".")
+	git(loc, "commit", "-qm", "workspace")
+
+	nfo, err := GetGitInfo(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]GitSubmodule{{Path: "vendor/lib", Commit: libCommit, Origin: lib}}, nfo.Submodules); diff != "" {
+		t.Fatalf("GetGitInfo() submodules mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, test := range []struct {
+		Name        string
+		Sources     []string
+		Expectation []string
+	}{
+		{"sources in submodule", []string{filepath.Join(loc, "WORKSPACE.yaml"), filepath.Join(loc, "vendor", "lib", "lib.go")}, []string{"git+" + lib + "@" + libCommit}},
+		{"no sources in submodule", []string{filepath.Join(loc, "WORKSPACE.yaml")}, nil},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			pkg := &Package{PackageInternal: PackageInternal{Sources: test.Sources}}
+			materials, err := pkg.gitSubmoduleMaterials(nfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var act []string
+			for _, m := range materials {
+				act = append(act, m.URI+"@"+m.Digest["sha256"])
+			}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("gitSubmoduleMaterials() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package blazedock
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+)
+
+// JUnitReporter writes the test results of all packages built during a run to a JUnit XML file. Each package with
+// tests becomes a testsuite. Packages whose tests are served from the test cache are reported as passed, packages
+// taken from the cache without test results as skipped.
+type JUnitReporter struct {
+	NoopReporter
+
+	filename   string
+	localCache cache.LocalCache
+
+	mu     sync.Mutex
+	logs   map[*Package]*bytes.Buffer
+	suites map[string]junitTestSuite
+}
+
+// NewJUnitReporter produces a new JUnit reporter. The local cache is consulted for the test results of cached packages.
+func NewJUnitReporter(filename string, localCache cache.LocalCache) *JUnitReporter {
+	return &JUnitReporter{
+		filename:   filename,
+		localCache: localCache,
+		logs:       make(map[*Package]*bytes.Buffer),
+		suites:     make(map[string]junitTestSuite),
+	}
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       float64         `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+func newJUnitTestSuite(pkg *Package, tc junitTestCase, cached bool) junitTestSuite {
+	tc.Name = "test"
+	tc.ClassName = pkg.FullName()
+
+	res := junitTestSuite{
+		Name:      pkg.FullName(),
+		Tests:     1,
+		Time:      tc.Time,
+		TestCases: []junitTestCase{tc},
+	}
+	if tc.Failure != nil {
+		res.Failures = 1
+	}
+	if tc.Error != nil {
+		res.Errors = 1
+	}
+	if tc.Skipped != nil {
+		res.Skipped = 1
+	}
+	if version, err := pkg.Version(); err == nil {
+		res.Properties = append(res.Properties, junitProperty{Name: "version", Value: version})
+	}
+	if cached {
+		res.Properties = append(res.Properties, junitProperty{Name: "cached", Value: "true"})
+	}
+	return res
+}
+
+// BuildStarted implements Reporter
+func (r *JUnitReporter) BuildStarted(pkg *Package, status map[*Package]PackageBuildStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for p, s := range status {
+		if s == PackageNotBuiltYet || !p.HasTests() {
+			continue
+		}
+		if _, exists := r.suites[p.FullName()]; exists {
+			// the package was built by a previous build of this run, which reported its actual result
+			continue
+		}
+
+		testLog, err := cachedTestLog(p, r.localCache)
+		if err != nil {
+			log.WithError(err).WithField("package", p.FullName()).Warn("cannot read cached test results")
+		}
+		var tc junitTestCase
+		if testLog != nil {
+			tc.SystemOut = string(testLog)
+		} else {
+			tc.Skipped = &junitMessage{Message: "taken from the cache without test results"}
+		}
+		r.suites[p.FullName()] = newJUnitTestSuite(p, tc, true)
+	}
+}
+
+// PackageBuildStarted implements Reporter
+func (r *JUnitReporter) PackageBuildStarted(pkg *Package) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs[pkg] = new(bytes.Buffer)
+}
+
+// PackageBuildLog implements Reporter
+func (r *JUnitReporter) PackageBuildLog(pkg *Package, isErr bool, buf []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if out, ok := r.logs[pkg]; ok {
+		out.Write(buf)
+	}
+}
+
+// PackageBuildFinished implements Reporter
+func (r *JUnitReporter) PackageBuildFinished(pkg *Package, rep *PackageBuildReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := r.logs[pkg]
+	delete(r.logs, pkg)
+	if !pkg.HasTests() {
+		return
+	}
+
+	var tc junitTestCase
+	switch {
+	case rep.Error != nil && rep.testLog != nil && rep.LastPhase() == rep.testPhase:
+		tc.Time = rep.PhaseDuration(rep.testPhase).Seconds()
+		tc.Failure = &junitMessage{Message: rep.Error.Error(), Content: string(rep.testLog)}
+	case rep.Error != nil:
+		// the build failed before the tests could run
+		tc.Error = &junitMessage{Message: rep.Error.Error()}
+		if out != nil {
+			tc.Error.Content = out.String()
+		}
+	case rep.testLog != nil:
+		tc.Time = rep.PhaseDuration(rep.testPhase).Seconds()
+		tc.SystemOut = string(rep.testLog)
+	default:
+		tc.Skipped = &junitMessage{Message: "tests are disabled"}
+	}
+	r.suites[pkg.FullName()] = newJUnitTestSuite(pkg, tc, false)
+}
+
+// BuildFinished implements Reporter
+func (r *JUnitReporter) BuildFinished(pkg *Package, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.write(); err != nil {
+		log.WithError(err).WithField("filename", r.filename).Error("cannot write JUnit test report")
+	}
+}
+
+func (r *JUnitReporter) write() error {
+	var res junitTestSuites
+	for _, suite := range r.suites {
+		res.Suites = append(res.Suites, suite)
+		res.Tests += suite.Tests
+		res.Failures += suite.Failures
+		res.Errors += suite.Errors
+		res.Skipped += suite.Skipped
+		res.Time += suite.Time
+	}
+	sort.Slice(res.Suites, func(i, j int) bool { return res.Suites[i].Name < res.Suites[j].Name })
+
+	fc, err := xml.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.filename, append([]byte(xml.Header), append(fc, '\n')...), 0644)
+}
+
+var _ Reporter = &JUnitReporter{}
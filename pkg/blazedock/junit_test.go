@@ -0,0 +1,132 @@
+package blazedock
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache/local"
+)
+
+func TestJUnitReporter(t *testing.T) {
+	t.Setenv(EnvvarBuildDir, t.TempDir())
+
+	loc := t.TempDir()
+	for fn, content := range map[string]string{
+		"WORKSPACE.yaml":  "",
+		"comp/lib.txt":    "hello world\n",
+		"comp/BUILD.yaml": "packages:\n- name: lib\n  type: generic\n  srcs:\n  - lib.txt\n  config:\n    commands:\n    - [\"cp\", \"lib.txt\", \"lib.out\"]\n    test:\n    - [\"echo\", \"testing lib\"]\n- name: broken\n  type: generic\n  config:\n    commands:\n    - [\"true\"]\n    test:\n    - [\"sh\", \"-c\", \"echo testing broken; exit 1\"]\n- name: untested\n  type: generic\n  deps:\n  - :lib\n  config:\n    commands:\n    - [\"true\"]\n",
+	} {
+		fn = filepath.Join(loc, fn)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lc, err := local.NewFilesystemCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := FindWorkspace(loc, Arguments{}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		Suite   string
+		Status  string
+		Output  bool
+		Cached  bool
+		Message string
+	}
+	readReport := func(fn string) []result {
+		fc, err := os.ReadFile(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var report junitTestSuites
+		if err := xml.Unmarshal(fc, &report); err != nil {
+			t.Fatal(err)
+		}
+		var res []result
+		for _, suite := range report.Suites {
+			if len(suite.TestCases) != 1 {
+				t.Fatalf("expected a single testcase for %s, got %d", suite.Name, len(suite.TestCases))
+			}
+			tc := suite.TestCases[0]
+			out := tc.SystemOut
+			r := result{Suite: suite.Name, Status: "passed"}
+			switch {
+			case tc.Failure != nil:
+				r.Status, out = "failed", tc.Failure.Content
+			case tc.Error != nil:
+				r.Status, r.Message = "error", tc.Error.Message
+			case tc.Skipped != nil:
+				r.Status, r.Message = "skipped", tc.Skipped.Message
+			}
+			r.Output = strings.Contains(out, "testing "+strings.TrimPrefix(suite.Name, "comp:"))
+			for _, prop := range suite.Properties {
+				if prop.Name == "cached" {
+					r.Cached = true
+				}
+			}
+			res = append(res, r)
+		}
+		return res
+	}
+
+	fn := filepath.Join(t.TempDir(), "report.xml")
+	rep := NewJUnitReporter(fn, lc)
+	err = Build(ws.Packages["comp:untested"], WithLocalCache(lc), WithReporter(rep), WithDontTest(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Build(ws.Packages["comp:broken"], WithLocalCache(lc), WithReporter(rep))
+	if err == nil {
+		t.Fatal("expected the build of comp:broken to fail")
+	}
+	expectation := []result{
+		{Suite: "comp:broken", Status: "failed", Output: true},
+		{Suite: "comp:lib", Status: "skipped", Message: "tests are disabled"},
+	}
+	if diff := cmp.Diff(expectation, readReport(fn)); diff != "" {
+		t.Errorf("test report mismatch (-want +got):\n%s", diff)
+	}
+
+	fn = filepath.Join(t.TempDir(), "report.xml")
+	rep = NewJUnitReporter(fn, lc)
+	err = Build(ws.Packages["comp:lib"], WithLocalCache(lc), WithReporter(rep), WithTestCache(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Build(ws.Packages["comp:untested"], WithLocalCache(lc), WithReporter(rep))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectation = []result{
+		{Suite: "comp:lib", Status: "passed", Output: true},
+	}
+	if diff := cmp.Diff(expectation, readReport(fn)); diff != "" {
+		t.Errorf("test report mismatch (-want +got):\n%s", diff)
+	}
+
+	fn = filepath.Join(t.TempDir(), "report.xml")
+	rep = NewJUnitReporter(fn, lc)
+	err = Build(ws.Packages["comp:untested"], WithLocalCache(lc), WithReporter(rep))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectation = []result{
+		{Suite: "comp:lib", Status: "passed", Output: true, Cached: true},
+	}
+	if diff := cmp.Diff(expectation, readReport(fn)); diff != "" {
+		t.Errorf("test report mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,274 @@
+package blazedock
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+)
+
+// LayoutAssembly selects how the dependencies of a package are placed in its build dir
+type LayoutAssembly string
+
+const (
+	// LayoutCopy unpacks the build result of every dependency in the build dir of each package which depends on it.
+	// This is the default.
+	LayoutCopy LayoutAssembly = "copy"
+
+	// LayoutHardlink unpacks the build result of a dependency once and hard links its files into the build dirs of
+	// the packages which depend on it. Files are copied where the filesystem does not support hard links.
+	LayoutHardlink LayoutAssembly = "hardlink"
+)
+
+// UnmarshalYAML unmarshals and validates a layout assembly
+func (l *LayoutAssembly) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var val string
+	err := unmarshal(&val)
+	if err != nil {
+		return err
+	}
+
+	switch LayoutAssembly(val) {
+	case "", LayoutCopy, LayoutHardlink:
+		*l = LayoutAssembly(val)
+		return nil
+	case "symlink":
+		// most packages archive their build dir, which would then contain links to files which don't exist elsewhere
+		return xerrors.Errorf("layout assembly symlink is not supported as build results would contain the symlinks, use hardlink instead")
+	default:
+		return xerrors.Errorf("invalid layout assembly: %s", val)
+	}
+}
+
+// layoutAssembly returns how the dependencies of the package are placed in its build dir. Yarn packages always
+// copy, as their build adds some dependencies to the yarn.lock rather than unpacking them.
+func (p *Package) layoutAssembly() LayoutAssembly {
+	if p.Type == YarnPackage {
+		return LayoutCopy
+	}
+	if p.LayoutAssembly != "" {
+		return p.LayoutAssembly
+	}
+	if p.C.W.LayoutAssembly != "" {
+		return p.C.W.LayoutAssembly
+	}
+	return LayoutCopy
+}
+
+// unpackCommands returns the commands which unpack the build result of a dependency at tgt. If the package links its
+// layout, there are none as assembleLayout places the dependency before the build commands run.
+func (p *Package) unpackCommands(artifact, tgt string) ([][]string, error) {
+	if p.layoutAssembly() == LayoutHardlink {
+		return nil, nil
+	}
+
+	untarCmd, err := BuildUnTarCommand(
+		WithInputFile(artifact),
+		WithTargetDir(tgt),
+		WithAutoDetectCompression(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return [][]string{
+		{"mkdir", tgt},
+		untarCmd,
+	}, nil
+}
+
+// linkedFile is a file of an unpacked dependency which was hard linked into a build dir, and its state at that time
+type linkedFile struct {
+	Dependency *Package
+	Path       string
+	Size       int64
+	Mode       fs.FileMode
+	ModTime    time.Time
+}
+
+// assembleLayout hard links the dependencies of a package into its build dir, if the package links its layout.
+// It returns the files which were linked, s.t. verifyLayout can tell if the build modified them.
+func (c *buildContext) assembleLayout(p *Package, builddir string) (linked []linkedFile, err error) {
+	if p.layoutAssembly() != LayoutHardlink {
+		return nil, nil
+	}
+
+	var copied bool
+	for _, e := range p.BuildLayout() {
+		artifact, ok := c.LocalCache.Location(e.Dependency)
+		if !ok {
+			return nil, PkgNotBuiltErr{e.Dependency}
+		}
+		src, err := c.unpackedDependency(e.Dependency, artifact)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot unpack %s: %w", e.Dependency.FullName(), err)
+		}
+
+		err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(src, path)
+			if err != nil {
+				return err
+			}
+			dst := filepath.Join(builddir, e.Location, rel)
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			switch {
+			case d.IsDir():
+				// we need to be able to link into read-only directories
+				return os.MkdirAll(dst, info.Mode().Perm()|0700)
+			case d.Type()&fs.ModeSymlink != 0:
+				tgt, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				return replaceFile(dst, func() error { return os.Symlink(tgt, dst) })
+			case !d.Type().IsRegular():
+				return xerrors.Errorf("cannot link %s: unsupported file type %s", rel, d.Type())
+			}
+
+			err = replaceFile(dst, func() error { return os.Link(path, dst) })
+			if err != nil {
+				// e.g. because the filesystem doesn't support hard links
+				copied = true
+				return replaceFile(dst, func() error { return copyFile(path, dst, info.Mode().Perm()) })
+			}
+			linked = append(linked, linkedFile{
+				Dependency: e.Dependency,
+				Path:       path,
+				Size:       info.Size(),
+				Mode:       info.Mode(),
+				ModTime:    info.ModTime(),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, xerrors.Errorf("cannot link %s into the build dir: %w", e.Dependency.FullName(), err)
+		}
+	}
+	if copied {
+		log.WithField("package", p.FullName()).Debug("cannot hard link all dependency files, copied some instead")
+	}
+	return linked, nil
+}
+
+// verifyLayout checks that the build did not modify the files of its dependencies which it shares with other builds
+// through hard links. The unpacked copy of a modified dependency is removed, s.t. later builds unpack it anew.
+func (c *buildContext) verifyLayout(p *Package, linked []linkedFile) error {
+	var modified error
+	for _, f := range linked {
+		info, err := os.Lstat(f.Path)
+		if err == nil && info.Size() == f.Size && info.Mode() == f.Mode && info.ModTime().Equal(f.ModTime) {
+			continue
+		}
+		dir := c.unpackedDependencyDir(f.Dependency)
+		if modified == nil {
+			rel, _ := filepath.Rel(dir, f.Path)
+			modified = xerrors.Errorf("the build modified %s of dependency %s in place, which is hard linked from %s: set layoutAssembly: copy for this package",
+				rel, f.Dependency.FullName(), f.Path)
+		}
+		if rerr := os.RemoveAll(dir); rerr != nil {
+			log.WithError(rerr).WithField("dependency", f.Dependency.FullName()).Warn("cannot remove modified dependency")
+		}
+	}
+	return modified
+}
+
+// unpackedDependencyDir is where the build result of a dependency is unpacked for linking
+func (c *buildContext) unpackedDependencyDir(dep *Package) string {
+	version, err := dep.Version()
+	if err != nil {
+		// the version has been computed before the dependency was built
+		version = "unknown"
+	}
+	return filepath.Join(c.BuildDir(), "unpacked", dep.FilesystemSafeName()+"."+version)
+}
+
+// unpackedDependency unpacks the build result of a dependency in the build dir once, s.t. its files can be hard linked
+// into the build dirs of all packages which depend on it. It's unpacked elsewhere first and moved into place, hence
+// blazedock processes sharing the build dir never see a partially unpacked dependency.
+func (c *buildContext) unpackedDependency(dep *Package, artifact string) (string, error) {
+	dir := c.unpackedDependencyDir(dep)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(dir), 0755)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".unpack-*")
+	if err != nil {
+		return "", err
+	}
+	// unpacking in place would create the directory with the default permissions
+	err = os.Chmod(tmp, 0755)
+	if err != nil {
+		_ = os.RemoveAll(tmp)
+		return "", err
+	}
+	untarCmd, err := BuildUnTarCommand(
+		WithInputFile(artifact),
+		WithTargetDir(tmp),
+		WithAutoDetectCompression(true),
+	)
+	if err == nil {
+		err = run(context.Background(), nil, dep, nil, tmp, untarCmd[0], untarCmd[1:]...)
+	}
+	if err != nil {
+		_ = os.RemoveAll(tmp)
+		return "", err
+	}
+
+	err = os.Rename(tmp, dir)
+	if err != nil {
+		_ = os.RemoveAll(tmp)
+		if _, serr := os.Stat(dir); serr == nil {
+			// someone else was quicker
+			return dir, nil
+		}
+		return "", err
+	}
+	return dir, nil
+}
+
+// replaceFile creates a file using create and replaces an existing file if need be, like unpacking would
+func replaceFile(fn string, create func() error) error {
+	err := create()
+	if !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	err = os.Remove(fn)
+	if err != nil {
+		return err
+	}
+	return create()
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/minio/highwayhash"
 	log "github.com/sirupsen/logrus"
@@ -115,16 +116,46 @@ type Component struct {
 	Constants Arguments  `yaml:"const"`
 	Packages  []*Package `yaml:"packages"`
 	Scripts   []*Script  `yaml:"scripts"`
+
+	// License is the SPDX license identifier of this component
+	License string `yaml:"license,omitempty"`
+}
+
+// GitOption configures the Git info returned by Component.Git
+type GitOption func(*gitOptions)
+
+type gitOptions struct {
+	DirtyPaths bool
+}
+
+// WithDirtyPaths lists the dirty files of the component in GitInfo.DirtyPaths. Clean working copies
+// have no dirty files, hence this only costs anything if the working copy is dirty.
+func WithDirtyPaths() GitOption {
+	return func(opts *gitOptions) {
+		opts.DirtyPaths = true
+	}
 }
 
-// GitCommit returns the git commit of this component or the workspace. Returns an empty string if
+// Git returns the Git info of this component or the workspace. The returned info is empty if
 // neither the component, nor the workspace are part of a working copy.
-func (c *Component) Git() *GitInfo {
+func (c *Component) Git(opts ...GitOption) *GitInfo {
 	res := c.git
 	if res == nil {
 		res = &c.W.Git
 	}
-	return res
+
+	var options gitOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if !options.DirtyPaths || !res.dirty {
+		return res
+	}
+
+	// the Git info may be shared with other components, hence we must not modify it
+	cpy := *res
+	cpy.DirtyPaths = res.dirtyPathsIn(c.Origin)
+	return &cpy
 }
 
 // PackageNotFoundErr is used when something references a package we don't know about
@@ -138,15 +169,35 @@ func (n PackageNotFoundErr) Error() string {
 
 // PackageInternal is the YAML serialised content of a package
 type PackageInternal struct {
-	Name                 string            `yaml:"name"`
-	Type                 PackageType       `yaml:"type"`
-	Sources              []string          `yaml:"srcs,omitempty"`
-	Dependencies         []string          `yaml:"deps,omitempty"`
-	Layout               map[string]string `yaml:"layout,omitempty"`
-	ArgumentDependencies []string          `yaml:"argdeps,omitempty"`
-	Environment          []string          `yaml:"env,omitempty"`
-	Ephemeral            bool              `yaml:"ephemeral,omitempty"`
-	PreparationCommands  [][]string        `yaml:"prep,omitempty"`
+	Name                 string                  `yaml:"name"`
+	Type                 PackageType             `yaml:"type"`
+	Sources              []string                `yaml:"srcs,omitempty"`
+	ExcludeSources       []string                `yaml:"excludeSources,omitempty"`
+	Dependencies         []string                `yaml:"deps,omitempty"`
+	ConditionalDeps      []ConditionalDependency `yaml:"conditionalDeps,omitempty"`
+	Layout               map[string]string       `yaml:"layout,omitempty"`
+	LayoutAssembly       LayoutAssembly          `yaml:"layoutAssembly,omitempty"`
+	ArgumentDependencies []string                `yaml:"argdeps,omitempty"`
+	Environment          []string                `yaml:"env,omitempty"`
+	EnvPassthrough       []string                `yaml:"envPassthrough,omitempty"`
+	Ephemeral            bool                    `yaml:"ephemeral,omitempty"`
+	PreparationCommands  [][]string              `yaml:"prep,omitempty"`
+	PreBuildCommands     [][]string              `yaml:"preBuild,omitempty"`
+	PostBuildCommands    [][]string              `yaml:"postBuild,omitempty"`
+	PostBuildAlways      bool                    `yaml:"postBuildAlways,omitempty"`
+	Timeout              time.Duration           `yaml:"timeout,omitempty"`
+	Retries              int                     `yaml:"retries,omitempty"`
+}
+
+// ConditionalDependency is a dependency which only applies if the selected variants or build arguments match.
+// If both a variant and an argument condition are given, both must hold.
+type ConditionalDependency struct {
+	Dependency string `yaml:"dep"`
+	// Variant applies the dependency if the variant is selected, or - if prefixed with ! - unless it is selected
+	Variant string `yaml:"variant,omitempty"`
+	// Arg applies the dependency if the build argument has a value (name=value), or is set to a non-empty value (name).
+	// Prefixed with ! the condition is negated.
+	Arg string `yaml:"arg,omitempty"`
 }
 
 // Package represents a package in a workspace
@@ -158,7 +209,7 @@ type Package struct {
 
 	PackageInternal `yaml:"_,inline"`
 	Config          PackageConfig `yaml:"config,omitempty"`
-	// Definition is the raw package definition YAML
+	// Definition is the raw package definition YAML without the fields which don't influence the build result
 	Definition []byte `yaml:"-"`
 
 	dependencies     []*Package
@@ -275,6 +326,70 @@ func (p *Package) GetTransitiveDependencies() []*Package {
 	return res
 }
 
+// DependencyPath returns the shortest chain of dependencies leading from this package to dst, including both ends.
+// Returns nil if this package does not depend on dst.
+func (p *Package) DependencyPath(dst *Package) []*Package {
+	parent := map[string]*Package{p.FullName(): nil}
+	queue := []*Package{p}
+	for len(queue) != 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+
+		if pkg.FullName() == dst.FullName() {
+			var res []*Package
+			for ; pkg != nil; pkg = parent[pkg.FullName()] {
+				res = append([]*Package{pkg}, res...)
+			}
+			return res
+		}
+
+		for _, dep := range pkg.dependencies {
+			if _, ok := parent[dep.FullName()]; ok {
+				continue
+			}
+			parent[dep.FullName()] = pkg
+			queue = append(queue, dep)
+		}
+	}
+	return nil
+}
+
+// DependencyPaths returns all distinct chains of dependencies leading from this package to dst which are at most
+// maxDepth edges long, shortest first.
+func (p *Package) DependencyPaths(dst *Package, maxDepth int) [][]*Package {
+	var (
+		res    [][]*Package
+		path   []*Package
+		onPath = make(map[string]struct{})
+		walk   func(pkg *Package)
+	)
+	walk = func(pkg *Package) {
+		path = append(path, pkg)
+		defer func() { path = path[:len(path)-1] }()
+
+		if pkg.FullName() == dst.FullName() {
+			res = append(res, append([]*Package(nil), path...))
+			return
+		}
+		if len(path) > maxDepth {
+			return
+		}
+
+		onPath[pkg.FullName()] = struct{}{}
+		defer delete(onPath, pkg.FullName())
+		for _, dep := range pkg.dependencies {
+			if _, ok := onPath[dep.FullName()]; ok {
+				continue
+			}
+			walk(dep)
+		}
+	}
+	walk(p)
+
+	sort.SliceStable(res, func(i, j int) bool { return len(res[i]) < len(res[j]) })
+	return res
+}
+
 // Dependants() returns a list of packages directly dependant on this package
 func (p *Package) Dependants() []*Package {
 	var res []*Package
@@ -315,19 +430,110 @@ func (p *Package) TransitiveDependants() []*Package {
 	return res
 }
 
+// HasTests returns true if building the package runs tests, unless tests are disabled for the whole build
+func (p *Package) HasTests() bool {
+	switch cfg := p.Config.(type) {
+	case GoPkgConfig:
+		return !cfg.DontTest
+	case YarnPkgConfig:
+		return !cfg.DontTest
+	case RustPkgConfig:
+		return !cfg.DontTest
+	case GenericPkgConfig:
+		return !cfg.DontTest && len(cfg.Test) > 0
+	default:
+		return false
+	}
+}
+
 // BuildLayoutLocation returns the filesystem path a dependency is expected at during the build.
 // This path will always be relative. If the provided package is not a depedency of this package,
-// we'll still return a valid path.
+// we'll still return a valid path. The layout may also place transitive dependencies, which Go and
+// Yarn packages unpack in their build dir.
 func (p *Package) BuildLayoutLocation(dependency *Package) (loc string) {
 	var ok bool
 	loc, ok = p.layout[dependency]
 	if ok {
 		return loc
 	}
+	loc, ok = p.Layout[dependency.FullName()]
+	if ok {
+		return loc
+	}
 
 	return dependency.FilesystemSafeName()
 }
 
+// LayoutEntry is a dependency of a package and the location in the package's build dir it is unpacked at
+type LayoutEntry struct {
+	Dependency *Package
+	Location   string
+}
+
+// BuildLayout lists the dependencies which are unpacked in the build dir of the package and where, sorted by location.
+// Which dependencies are unpacked depends on the package type: Go and Yarn packages get all their transitive
+// dependencies except ephemeral ones, all other packages their direct dependencies. Go, Rust and Python packages
+// unpack them in _deps. Yarn libraries a Yarn package depends on are not unpacked but added to its yarn.lock.
+func (p *Package) BuildLayout() []LayoutEntry {
+	var (
+		deps       = p.GetDependencies()
+		transitive = p.Type == GoPackage || p.Type == YarnPackage
+		prefix     string
+	)
+	if transitive {
+		deps = p.GetTransitiveDependencies()
+	}
+	switch p.Type {
+	case GoPackage, RustPackage, PythonPackage:
+		prefix = "_deps"
+	}
+
+	res := make([]LayoutEntry, 0, len(deps))
+	for _, dep := range deps {
+		if transitive && dep.Ephemeral {
+			continue
+		}
+		if p.isYarnLockDependency(dep) {
+			continue
+		}
+		res = append(res, LayoutEntry{Dependency: dep, Location: filepath.Join(prefix, p.BuildLayoutLocation(dep))})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Location != res[j].Location {
+			return res[i].Location < res[j].Location
+		}
+		return res[i].Dependency.FullName() < res[j].Dependency.FullName()
+	})
+	return res
+}
+
+// isYarnLockDependency returns true if dep is a Yarn library which the build of this package adds to its yarn.lock
+func (p *Package) isYarnLockDependency(dep *Package) bool {
+	cfg, ok := p.Config.(YarnPkgConfig)
+	if !ok || cfg.PackageManager == PackageManagerPnpm || dep.Type != YarnPackage {
+		return false
+	}
+	depcfg, ok := dep.Config.(YarnPkgConfig)
+	return ok && depcfg.Packaging == YarnLibrary
+}
+
+// sanitizeLayoutLocation turns a layout location into a clean path relative to the build dir.
+// Absolute locations are taken relative to the build dir, so that the layout does not depend on the machine
+// a package is built on. Relative locations outside of the build dir are an error.
+func sanitizeLayoutLocation(loc string) (string, error) {
+	if !filepath.IsAbs(loc) {
+		if rel := filepath.Clean(loc); rel == ".." || strings.HasPrefix(rel, "../") {
+			return "", xerrors.Errorf("location %q is outside of the build dir", loc)
+		}
+	}
+
+	res := strings.TrimPrefix(filepath.Clean("/"+loc), "/")
+	if res == "" {
+		res = "."
+	}
+	return res, nil
+}
+
 // UnmarshalYAML unmarshals the package definition
 func (p *Package) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var tpe PackageInternal
@@ -401,24 +607,57 @@ func unmarshalTypeDependentConfig(tpe PackageType, unmarshal func(interface{}) e
 			return nil, err
 		}
 		return cfg.Config, nil
+	case PythonPackage:
+		var cfg struct {
+			Config PythonPkgConfig `yaml:"config"`
+		}
+		if err := unmarshal(&cfg); err != nil {
+			return nil, err
+		}
+		if cfg.Config.Packaging == "" {
+			cfg.Config.Packaging = PythonSitePackages
+		}
+		if cfg.Config.Requirements == "" {
+			cfg.Config.Requirements = "requirements.txt"
+		}
+		if err := cfg.Config.Validate(); err != nil {
+			return nil, err
+		}
+		return cfg.Config, nil
+	case RustPackage:
+		var cfg struct {
+			Config RustPkgConfig `yaml:"config"`
+		}
+		if err := unmarshal(&cfg); err != nil {
+			return nil, err
+		}
+		if cfg.Config.Profile == "" {
+			cfg.Config.Profile = RustRelease
+		}
+		if err := cfg.Config.Validate(); err != nil {
+			return nil, err
+		}
+		return cfg.Config, nil
 	default:
 		return nil, xerrors.Errorf("unknown package type \"%s\"", tpe)
 	}
 }
 
 // PackageConfig is the YAML unmarshalling config type of packages.
-// This is one of YarnPkgConfig, GoPkgConfig, DockerPkgConfig or GenericPkgConfig.
+// This is one of YarnPkgConfig, GoPkgConfig, DockerPkgConfig, GenericPkgConfig, RustPkgConfig or PythonPkgConfig.
 type PackageConfig interface {
 	AdditionalSources(workspaceOrigin string) []string
 }
 
 // YarnPkgConfig configures a yarn package
 type YarnPkgConfig struct {
-	YarnLock  string        `yaml:"yarnLock,omitempty"`
-	TSConfig  string        `yaml:"tsconfig"`
-	Packaging YarnPackaging `yaml:"packaging,omitempty"`
-	DontTest  bool          `yaml:"dontTest,omitempty"`
-	Commands  struct {
+	YarnLock       string           `yaml:"yarnLock,omitempty"`
+	PnpmLock       string           `yaml:"pnpmLock,omitempty"`
+	TSConfig       string           `yaml:"tsconfig"`
+	Packaging      YarnPackaging    `yaml:"packaging,omitempty"`
+	PackageManager JSPackageManager `yaml:"packageManager,omitempty"`
+	DontTest       bool             `yaml:"dontTest,omitempty"`
+	Commands       struct {
 		Install []string `yaml:"install,omitempty"`
 		Build   []string `yaml:"build,omitempty"`
 		Test    []string `yaml:"test,omitempty"`
@@ -436,9 +675,29 @@ func (cfg YarnPkgConfig) Validate() error {
 		return xerrors.Errorf("unknown packaging: %s", cfg.Packaging)
 	}
 
+	switch cfg.PackageManager {
+	case "", PackageManagerYarn:
+	case PackageManagerPnpm:
+		if cfg.Packaging != YarnArchive {
+			return xerrors.Errorf("packaging %s is not supported with pnpm, use %s instead", cfg.Packaging, YarnArchive)
+		}
+	default:
+		return xerrors.Errorf("unknown package manager: %s", cfg.PackageManager)
+	}
+
 	return nil
 }
 
+// JSPackageManager selects the package manager used to install and build a yarn package
+type JSPackageManager string
+
+const (
+	// PackageManagerYarn uses yarn. This is the default.
+	PackageManagerYarn JSPackageManager = "yarn"
+	// PackageManagerPnpm uses pnpm. Packages built with pnpm must use archive packaging.
+	PackageManagerPnpm JSPackageManager = "pnpm"
+)
+
 // YarnPackaging configures the packaging method of a yarn package
 type YarnPackaging string
 
@@ -456,7 +715,11 @@ const (
 // AdditionalSources returns a list of unresolved sources coming in through this configuration
 func (cfg YarnPkgConfig) AdditionalSources(workspaceOrigin string) []string {
 	var res []string
-	if cfg.YarnLock != "" {
+	if cfg.PackageManager == PackageManagerPnpm {
+		if cfg.PnpmLock != "" {
+			res = append(res, cfg.PnpmLock)
+		}
+	} else if cfg.YarnLock != "" {
 		res = append(res, cfg.YarnLock)
 	}
 	if cfg.TSConfig != "" {
@@ -545,6 +808,7 @@ type DockerPkgConfig struct {
 	BuildArgs  map[string]string `yaml:"buildArgs,omitempty"`
 	Squash     bool              `yaml:"squash,omitempty"`
 	Metadata   map[string]string `yaml:"metadata,omitempty"`
+	Platforms  []string          `yaml:"platforms,omitempty"`
 }
 
 // AdditionalSources returns a list of unresolved sources coming in through this configuration
@@ -564,6 +828,111 @@ func (cfg GenericPkgConfig) AdditionalSources(workspaceOrigin string) []string {
 	return []string{}
 }
 
+// RustPkgConfig configures a Rust package
+type RustPkgConfig struct {
+	Profile           RustProfile `yaml:"profile,omitempty"`
+	Target            string      `yaml:"target,omitempty"`
+	Features          []string    `yaml:"features,omitempty"`
+	NoDefaultFeatures bool        `yaml:"noDefaultFeatures,omitempty"`
+	Binaries          []string    `yaml:"binaries,omitempty"`
+	DontTest          bool        `yaml:"dontTest,omitempty"`
+}
+
+// Validate ensures this config can be acted upon/is valid
+func (cfg RustPkgConfig) Validate() error {
+	switch cfg.Profile {
+	case RustRelease:
+	case RustDebug:
+	default:
+		return xerrors.Errorf("unknown profile: %s", cfg.Profile)
+	}
+
+	if strings.ContainsAny(cfg.Target, "/ ") {
+		return xerrors.Errorf("target must be a target triple, e.g. x86_64-unknown-linux-musl")
+	}
+	for _, f := range cfg.Features {
+		if f == "" || strings.ContainsAny(f, ", ") {
+			return xerrors.Errorf("invalid feature %q: list each feature separately", f)
+		}
+	}
+	for _, b := range cfg.Binaries {
+		if b == "" || strings.ContainsAny(b, "/ ") {
+			return xerrors.Errorf("invalid binary name %q", b)
+		}
+	}
+
+	return nil
+}
+
+// RustProfile selects the cargo profile a Rust package is built with
+type RustProfile string
+
+const (
+	// RustRelease builds with optimisations (cargo build --release)
+	RustRelease RustProfile = "release"
+	// RustDebug builds without optimisations and with debug info
+	RustDebug RustProfile = "debug"
+)
+
+// AdditionalSources returns a list of unresolved sources coming in through this configuration.
+// Cargo.toml and Cargo.lock determine what cargo builds, hence they're always part of the package version.
+func (cfg RustPkgConfig) AdditionalSources(workspaceOrigin string) []string {
+	return []string{"Cargo.toml", "Cargo.lock"}
+}
+
+// PythonPkgConfig configures a Python package
+type PythonPkgConfig struct {
+	Packaging     PythonPackaging `yaml:"packaging,omitempty"`
+	PythonVersion string          `yaml:"pythonVersion,omitempty"`
+	Requirements  string          `yaml:"requirements,omitempty"`
+	BuildCommand  []string        `yaml:"buildCommand,omitempty"`
+}
+
+var pythonVersionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+
+// Validate ensures this config can be acted upon/is valid
+func (cfg PythonPkgConfig) Validate() error {
+	switch cfg.Packaging {
+	case PythonSitePackages:
+	case PythonWheel:
+	default:
+		return xerrors.Errorf("unknown packaging: %s", cfg.Packaging)
+	}
+
+	if cfg.PythonVersion != "" && !pythonVersionPattern.MatchString(cfg.PythonVersion) {
+		return xerrors.Errorf("pythonVersion must be a version number, e.g. 3.11")
+	}
+	// the requirements file is copied into the build dir alongside the package sources
+	if filepath.IsAbs(cfg.Requirements) || strings.HasPrefix(filepath.Clean(cfg.Requirements), "..") {
+		return xerrors.Errorf("requirements must be within the component")
+	}
+
+	return nil
+}
+
+// Interpreter returns the name of the Python interpreter used to build the package
+func (cfg PythonPkgConfig) Interpreter() string {
+	if cfg.PythonVersion == "" {
+		return "python3"
+	}
+	return "python" + cfg.PythonVersion
+}
+
+// PythonPackaging configures the packaging method of a Python package
+type PythonPackaging string
+
+const (
+	// PythonSitePackages installs the requirements and the package itself into a site-packages directory and tars it
+	PythonSitePackages PythonPackaging = "site-packages"
+	// PythonWheel builds wheels of the package and all its requirements
+	PythonWheel PythonPackaging = "wheel"
+)
+
+// AdditionalSources returns a list of unresolved sources coming in through this configuration
+func (cfg PythonPkgConfig) AdditionalSources(workspaceOrigin string) []string {
+	return []string{cfg.Requirements}
+}
+
 // PackageType describes the way a package is built and what it produces
 type PackageType string
 
@@ -579,6 +948,12 @@ const (
 
 	// GenericPackage runs an arbitary shell command
 	GenericPackage PackageType = "generic"
+
+	// RustPackage runs cargo build and produces the crate's binaries
+	RustPackage PackageType = "rust"
+
+	// PythonPackage installs its requirements into a virtualenv and produces site-packages or wheels
+	PythonPackage PackageType = "python"
 )
 
 // UnmarshalYAML unmarshals and validates a package type
@@ -591,7 +966,7 @@ func (p *PackageType) UnmarshalYAML(unmarshal func(interface{}) error) (err erro
 
 	*p = PackageType(val)
 	switch *p {
-	case YarnPackage, GoPackage, DockerPackage, GenericPackage:
+	case YarnPackage, GoPackage, DockerPackage, GenericPackage, RustPackage, PythonPackage:
 	default:
 		return fmt.Errorf("invalid package type: %s", err)
 	}
@@ -607,8 +982,9 @@ type packageVariantInternal struct {
 	Components struct {
 		Exclude []string `yaml:"exclude"`
 	} `yaml:"components"`
-	Environment []string                  `yaml:"env"`
-	RawConfig   map[PackageType]yaml.Node `yaml:"config"`
+	Environment      []string                  `yaml:"env"`
+	ArgumentDefaults map[string]string         `yaml:"defaultArgs"`
+	RawConfig        map[PackageType]yaml.Node `yaml:"config"`
 }
 
 // PackageVariant provides a variation point for a package's sources,
@@ -627,11 +1003,26 @@ func (v *PackageVariant) UnmarshalYAML(unmarshal func(interface{}) error) error
 		return err
 	}
 
+	config, err := unmarshalVariantConfig(vi.RawConfig)
+	if err != nil {
+		return err
+	}
+
+	*v = PackageVariant{
+		packageVariantInternal: vi,
+		config:                 config,
+	}
+
+	return nil
+}
+
+// unmarshalVariantConfig turns the raw config of a variant into the config of each package type
+func unmarshalVariantConfig(raw map[PackageType]yaml.Node) (map[PackageType]PackageConfig, error) {
 	config := make(map[PackageType]PackageConfig)
-	for k, rc := range vi.RawConfig {
+	for k, rc := range raw {
 		b, err := yaml.Marshal(&rc)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		cfg, err := unmarshalTypeDependentConfig(k, func(dst interface{}) error {
 			lines := strings.Split(string(b), "\n")
@@ -642,17 +1033,11 @@ func (v *PackageVariant) UnmarshalYAML(unmarshal func(interface{}) error) error
 			return yaml.Unmarshal(b, dst)
 		})
 		if err != nil {
-			return err
+			return nil, err
 		}
 		config[k] = cfg
 	}
-
-	*v = PackageVariant{
-		packageVariantInternal: vi,
-		config:                 config,
-	}
-
-	return nil
+	return config, nil
 }
 
 // Config returns this package variants configuration
@@ -736,7 +1121,7 @@ const (
 	CacheRemotePull CacheLevel = "remote-pull"
 )
 
-// UnmarshalYAML unmarshals and validates a package type
+// UnmarshalYAML unmarshals and validates a cache level
 func (c *CacheLevel) UnmarshalYAML(unmarshal func(interface{}) error) (err error) {
 	var val string
 	err = unmarshal(&val)
@@ -746,9 +1131,9 @@ func (c *CacheLevel) UnmarshalYAML(unmarshal func(interface{}) error) (err error
 
 	*c = CacheLevel(val)
 	switch *c {
-	case CacheUnspecified, CacheNone, CacheLocal, CacheRemote, CacheRemotePush:
+	case CacheUnspecified, CacheNone, CacheLocal, CacheRemote, CacheRemotePush, CacheRemotePull:
 	default:
-		return fmt.Errorf("invalid package type: %s", err)
+		return fmt.Errorf("invalid cache level: %s", val)
 	}
 	return
 }
@@ -865,6 +1250,7 @@ func (p *Package) DefinitionHash() (string, error) {
 }
 
 // ContentManifest produces an ordered list of content hashes (<filename>:<hash>) for each source file.
+// Only the content of files is hashed, not their modification time, s.t. fresh checkouts produce the same versions.
 // Expects the sources to be resolved.
 func (p *Package) ContentManifest() ([]string, error) {
 	key, err := hex.DecodeString(contentHashKey)
@@ -940,18 +1326,47 @@ func (p *Package) WriteVersionManifest(out io.Writer) error {
 		bundle = append(bundle, fmt.Sprintf("provenance: version=%d", provenanceProcessVersion))
 		if p.C.W.Provenance.SLSA {
 			bundle = append(bundle, " slsa")
+			if v := p.C.W.Provenance.SLSAVersion; v != "" && v != SLSAVersion02 {
+				bundle = append(bundle, "="+string(v))
+			}
 		}
-		if p.C.W.Provenance.key != nil {
+		if p.C.W.Provenance.signer != nil {
+			bundle = append(bundle, " keyless")
+		} else if p.C.W.Provenance.kmsSigner != nil {
+			bundle = append(bundle, fmt.Sprintf(" kms:%s", p.C.W.Provenance.kmsSigner.URI))
+		} else if p.C.W.Provenance.key != nil {
 			bundle = append(bundle, fmt.Sprintf(" key:%s", p.C.W.Provenance.key.KeyID))
 		}
 		bundle = append(bundle, "\n")
 	}
 
+	if salt := p.C.W.CacheSalt; salt != "" {
+		bundle = append(bundle, fmt.Sprintf("cacheSalt: %s\n", salt))
+	}
+	if salt := p.C.W.envCacheSalt; salt != "" {
+		bundle = append(bundle, fmt.Sprintf("envCacheSalt: %s\n", salt))
+	}
 	bundle = append(bundle, fmt.Sprintf("environment: %s\n", envhash))
 	bundle = append(bundle, fmt.Sprintf("definition: %s\n", defhash))
 	for _, argdep := range p.ArgumentDependencies {
 		bundle = append(bundle, fmt.Sprintf("arg %s\n", argdep))
 	}
+	if len(p.Layout) > 0 {
+		// the definition holds the layout as written, whereas the build uses it with fully qualified dependency
+		// names and sanitized locations
+		layout := make([]string, 0, len(p.Layout))
+		for dep, loc := range p.Layout {
+			layout = append(layout, dep+"="+loc)
+		}
+		sort.Strings(layout)
+		bundle = append(bundle, fmt.Sprintf("layout: %s\n", strings.Join(layout, ",")))
+	}
+	if cfg, ok := p.Config.(DockerPkgConfig); ok && len(cfg.Platforms) > 0 {
+		// platforms can also come in through variants, hence the definition hash alone does not suffice
+		platforms := append([]string{}, cfg.Platforms...)
+		sort.Strings(platforms)
+		bundle = append(bundle, fmt.Sprintf("platforms: %s\n", strings.Join(platforms, ",")))
+	}
 	for _, dep := range p.dependencies {
 		ver, err := dep.Version()
 		if err != nil {
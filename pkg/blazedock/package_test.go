@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
 )
 
 func TestResolveBuiltinGitVariables(t *testing.T) {
@@ -291,6 +294,92 @@ func BenchmarkFindCycle(b *testing.B) {
 
 }
 
+func TestDependencyPaths(t *testing.T) {
+	// a -> b -> d -> e
+	// a -> c -> d
+	// a -> e
+	// e -> a
+	ps := make(map[string]*Package)
+	for _, n := range []string{"a", "b", "c", "d", "e", "f"} {
+		p := NewTestPackage(n)
+		if len(ps) > 0 {
+			p.C = ps["a"].C
+		}
+		p.C.W.Packages[p.FullName()] = p
+		ps[n] = p
+	}
+	ps["a"].dependencies = []*Package{ps["b"], ps["c"], ps["e"]}
+	ps["b"].dependencies = []*Package{ps["d"]}
+	ps["c"].dependencies = []*Package{ps["d"]}
+	ps["d"].dependencies = []*Package{ps["e"]}
+	ps["e"].dependencies = []*Package{ps["a"]}
+
+	names := func(path []*Package) string {
+		res := make([]string, len(path))
+		for i, p := range path {
+			res[i] = p.Name
+		}
+		return strings.Join(res, " ")
+	}
+
+	tests := []struct {
+		Name     string
+		From, To string
+		MaxDepth int
+		Shortest string
+		All      []string
+	}{
+		{Name: "direct", From: "b", To: "d", MaxDepth: 10, Shortest: "b d", All: []string{"b d"}},
+		{Name: "transitive", From: "a", To: "d", MaxDepth: 10, Shortest: "a b d", All: []string{"a b d", "a c d"}},
+		{Name: "shortcut", From: "a", To: "e", MaxDepth: 10, Shortest: "a e", All: []string{"a e", "a b d e", "a c d e"}},
+		{Name: "depth limit", From: "a", To: "e", MaxDepth: 2, Shortest: "a e", All: []string{"a e"}},
+		{Name: "through cycle", From: "d", To: "c", MaxDepth: 10, Shortest: "d e a c", All: []string{"d e a c"}},
+		{Name: "self", From: "a", To: "a", MaxDepth: 10, Shortest: "a", All: []string{"a"}},
+		{Name: "no path", From: "a", To: "f", MaxDepth: 10},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			from, to := ps[test.From], ps[test.To]
+
+			assert.Equal(t, test.Shortest, names(from.DependencyPath(to)))
+
+			var all []string
+			for _, path := range from.DependencyPaths(to, test.MaxDepth) {
+				all = append(all, names(path))
+			}
+			assert.Equal(t, test.All, all)
+		})
+	}
+}
+
+func TestSanitizeLayoutLocation(t *testing.T) {
+	tests := []struct {
+		Location    string
+		Expectation string
+		Error       bool
+	}{
+		{Location: "vendor/lib", Expectation: "vendor/lib"},
+		{Location: "/vendor/lib", Expectation: "vendor/lib"},
+		{Location: "./vendor//lib/", Expectation: "vendor/lib"},
+		{Location: "/../vendor", Expectation: "vendor"},
+		{Location: "vendor/../lib", Expectation: "lib"},
+		{Location: "/", Expectation: "."},
+		{Location: "../lib", Error: true},
+		{Location: "vendor/../../lib", Error: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Location, func(t *testing.T) {
+			act, err := sanitizeLayoutLocation(test.Location)
+			if test.Error {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.Expectation, act)
+		})
+	}
+}
+
 func NewTestPackage(name string) *Package {
 	return &Package{
 		C: &Component{
@@ -310,6 +399,78 @@ func NewTestPackage(name string) *Package {
 	}
 }
 
+func TestBuildLayout(t *testing.T) {
+	// a -> b -> d
+	// a -> c (ephemeral)
+	// a -> lib (yarn library)
+	ps := make(map[string]*Package)
+	for _, n := range []string{"a", "b", "c", "d", "lib"} {
+		p := NewTestPackage(n)
+		if len(ps) > 0 {
+			p.C = ps["a"].C
+		}
+		ps[n] = p
+	}
+	ps["c"].Ephemeral = true
+	ps["lib"].Type = YarnPackage
+	ps["lib"].Config = YarnPkgConfig{Packaging: YarnLibrary}
+	ps["a"].dependencies = []*Package{ps["b"], ps["c"], ps["lib"]}
+	ps["a"].layout = map[*Package]string{ps["b"]: "z/b"}
+	// the layout may place transitive dependencies as well
+	ps["a"].Layout = map[string]string{"testcomp:b": "z/b", "testcomp:d": "y/d"}
+	ps["b"].dependencies = []*Package{ps["d"]}
+
+	tests := []struct {
+		Name        string
+		Type        PackageType
+		Config      PackageConfig
+		Expectation []string
+	}{
+		{
+			Name:        "generic",
+			Type:        GenericPackage,
+			Config:      GenericPkgConfig{},
+			Expectation: []string{"testcomp--c: testcomp:c", "testcomp--lib: testcomp:lib", "z/b: testcomp:b"},
+		},
+		{
+			Name:        "go",
+			Type:        GoPackage,
+			Config:      GoPkgConfig{},
+			Expectation: []string{"_deps/testcomp--lib: testcomp:lib", "_deps/y/d: testcomp:d", "_deps/z/b: testcomp:b"},
+		},
+		{
+			Name:        "rust",
+			Type:        RustPackage,
+			Config:      RustPkgConfig{},
+			Expectation: []string{"_deps/testcomp--c: testcomp:c", "_deps/testcomp--lib: testcomp:lib", "_deps/z/b: testcomp:b"},
+		},
+		{
+			Name:        "yarn",
+			Type:        YarnPackage,
+			Config:      YarnPkgConfig{},
+			Expectation: []string{"y/d: testcomp:d", "z/b: testcomp:b"},
+		},
+		{
+			Name:        "pnpm",
+			Type:        YarnPackage,
+			Config:      YarnPkgConfig{PackageManager: PackageManagerPnpm},
+			Expectation: []string{"testcomp--lib: testcomp:lib", "y/d: testcomp:d", "z/b: testcomp:b"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			pkg := ps["a"]
+			pkg.Type, pkg.Config = test.Type, test.Config
+
+			var act []string
+			for _, e := range pkg.BuildLayout() {
+				act = append(act, e.Location+": "+e.Dependency.FullName())
+			}
+			assert.Equal(t, test.Expectation, act)
+		})
+	}
+}
+
 func TestCodecovComponentName(t *testing.T) {
 	tests := []struct {
 		Test     string
@@ -329,3 +490,477 @@ func TestCodecovComponentName(t *testing.T) {
 		}
 	}
 }
+
+func TestPackageHasTests(t *testing.T) {
+	tests := []struct {
+		Test     string
+		Config   PackageConfig
+		Expected bool
+	}{
+		{"go", GoPkgConfig{}, true},
+		{"go without tests", GoPkgConfig{DontTest: true}, false},
+		{"yarn", YarnPkgConfig{}, true},
+		{"rust without tests", RustPkgConfig{DontTest: true}, false},
+		{"generic with test commands", GenericPkgConfig{Test: [][]string{{"make", "test"}}}, true},
+		{"generic without test commands", GenericPkgConfig{Commands: [][]string{{"make"}}}, false},
+		{"docker", DockerPkgConfig{}, false},
+	}
+
+	for _, test := range tests {
+		pkg := &Package{Config: test.Config}
+		if act := pkg.HasTests(); act != test.Expected {
+			t.Errorf("%s: expected: %v, actual: %v", test.Test, test.Expected, act)
+		}
+	}
+}
+
+func TestYarnPkgConfigPackageManager(t *testing.T) {
+	tests := []struct {
+		Test            string
+		Cfg             YarnPkgConfig
+		ExpectedErr     bool
+		ExpectedSources []string
+	}{
+		{"default is yarn", YarnPkgConfig{Packaging: YarnApp, YarnLock: "yarn.lock", PnpmLock: "pnpm-lock.yaml"}, false, []string{"yarn.lock"}},
+		{"pnpm uses pnpm lock", YarnPkgConfig{Packaging: YarnArchive, PackageManager: PackageManagerPnpm, YarnLock: "yarn.lock", PnpmLock: "pnpm-lock.yaml"}, false, []string{"pnpm-lock.yaml"}},
+		{"pnpm requires archive packaging", YarnPkgConfig{Packaging: YarnLibrary, PackageManager: PackageManagerPnpm}, true, nil},
+		{"unknown package manager", YarnPkgConfig{Packaging: YarnApp, PackageManager: "npm"}, true, nil},
+	}
+
+	for _, test := range tests {
+		err := test.Cfg.Validate()
+		if (err != nil) != test.ExpectedErr {
+			t.Errorf("%s: expected error: %v, actual: %v", test.Test, test.ExpectedErr, err)
+			continue
+		}
+		if test.ExpectedErr {
+			continue
+		}
+		assert.Equal(t, test.ExpectedSources, test.Cfg.AdditionalSources(""), test.Test)
+	}
+}
+
+func TestRustPkgConfig(t *testing.T) {
+	tests := []struct {
+		Test              string
+		Cfg               RustPkgConfig
+		ExpectedErr       bool
+		ExpectedFlags     []string
+		ExpectedOutputDir string
+	}{
+		{"release", RustPkgConfig{Profile: RustRelease}, false, []string{"--locked", "--target-dir", "target", "--release"}, "target/release"},
+		{"debug with target", RustPkgConfig{Profile: RustDebug, Target: "x86_64-unknown-linux-musl"}, false, []string{"--locked", "--target-dir", "target", "--target", "x86_64-unknown-linux-musl"}, "target/x86_64-unknown-linux-musl/debug"},
+		{"features", RustPkgConfig{Profile: RustRelease, Features: []string{"a", "b"}, NoDefaultFeatures: true}, false, []string{"--locked", "--target-dir", "target", "--release", "--no-default-features", "--features", "a,b"}, "target/release"},
+		{"unknown profile", RustPkgConfig{Profile: "bench"}, true, nil, ""},
+		{"comma separated features", RustPkgConfig{Profile: RustRelease, Features: []string{"a,b"}}, true, nil, ""},
+		{"target is a path", RustPkgConfig{Profile: RustRelease, Target: "targets/custom.json"}, true, nil, ""},
+	}
+
+	for _, test := range tests {
+		err := test.Cfg.Validate()
+		if (err != nil) != test.ExpectedErr {
+			t.Errorf("%s: expected error: %v, actual: %v", test.Test, test.ExpectedErr, err)
+			continue
+		}
+		if test.ExpectedErr {
+			continue
+		}
+		assert.Equal(t, test.ExpectedFlags, test.Cfg.cargoFlags(), test.Test)
+		assert.Equal(t, test.ExpectedOutputDir, test.Cfg.outputDir(), test.Test)
+	}
+}
+
+func TestPythonPkgConfig(t *testing.T) {
+	tests := []struct {
+		Test                string
+		Cfg                 PythonPkgConfig
+		ExpectedErr         bool
+		ExpectedInterpreter string
+	}{
+		{"default interpreter", PythonPkgConfig{Packaging: PythonSitePackages, Requirements: "requirements.txt"}, false, "python3"},
+		{"versioned interpreter", PythonPkgConfig{Packaging: PythonWheel, Requirements: "requirements.txt", PythonVersion: "3.11"}, false, "python3.11"},
+		{"unknown packaging", PythonPkgConfig{Packaging: "egg", Requirements: "requirements.txt"}, true, ""},
+		{"invalid version", PythonPkgConfig{Packaging: PythonWheel, Requirements: "requirements.txt", PythonVersion: "3.11; rm"}, true, ""},
+		{"requirements outside component", PythonPkgConfig{Packaging: PythonWheel, Requirements: "../requirements.txt"}, true, ""},
+	}
+
+	for _, test := range tests {
+		err := test.Cfg.Validate()
+		if (err != nil) != test.ExpectedErr {
+			t.Errorf("%s: expected error: %v, actual: %v", test.Test, test.ExpectedErr, err)
+			continue
+		}
+		if test.ExpectedErr {
+			continue
+		}
+		assert.Equal(t, test.ExpectedInterpreter, test.Cfg.Interpreter(), test.Test)
+		assert.Equal(t, []string{test.Cfg.Requirements}, test.Cfg.AdditionalSources(""), test.Test)
+	}
+}
+
+func TestSelectVariants(t *testing.T) {
+	const wsYAML = `variants:
+- name: debug
+  srcs:
+    include: ["debug.go"]
+  env: ["LOG_LEVEL=debug", "DEBUG=true"]
+  config:
+    go:
+      generate: true
+      buildFlags: ["-gcflags=all=-N -l"]
+- name: fips
+  components:
+    exclude: ["crypto-legacy"]
+  env: ["LOG_LEVEL=info"]
+  config:
+    go:
+      buildFlags: ["-tags=fips"]
+- name: release
+- name: reproducible
+  config:
+    go:
+      generate: false
+exclusiveVariants:
+- [debug, release]
+`
+	var ws Workspace
+	err := yaml.Unmarshal([]byte(wsYAML), &ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Test        string
+		Variants    []string
+		ExpectedErr bool
+		ExpectedEnv []string
+		ExpectedCfg GoPkgConfig
+	}{
+		{"single variant", []string{"debug"}, false, []string{"LOG_LEVEL=debug", "DEBUG=true"}, GoPkgConfig{Packaging: GoApp, Generate: true, BuildFlags: []string{"-gcflags=all=-N -l"}}},
+		{"later variants override earlier ones", []string{"debug", "fips"}, false, []string{"LOG_LEVEL=debug", "DEBUG=true", "LOG_LEVEL=info"}, GoPkgConfig{Packaging: GoApp, Generate: true, BuildFlags: []string{"-tags=fips"}}},
+		{"declaration order wins over flag order", []string{"fips", "debug"}, false, []string{"LOG_LEVEL=debug", "DEBUG=true", "LOG_LEVEL=info"}, GoPkgConfig{Packaging: GoApp, Generate: true, BuildFlags: []string{"-tags=fips"}}},
+		{"later variants reset fields to their zero value", []string{"debug", "reproducible"}, false, []string{"LOG_LEVEL=debug", "DEBUG=true"}, GoPkgConfig{Packaging: GoApp, Generate: false, BuildFlags: []string{"-gcflags=all=-N -l"}}},
+		{"unknown variant", []string{"debug", "fisp"}, true, nil, GoPkgConfig{}},
+		{"mutually exclusive variants", []string{"debug", "fips", "release"}, true, nil, GoPkgConfig{}},
+	}
+
+	for _, test := range tests {
+		vnt, err := ws.selectVariants(test.Variants)
+		if (err != nil) != test.ExpectedErr {
+			t.Errorf("%s: expected error: %v, actual: %v", test.Test, test.ExpectedErr, err)
+			continue
+		}
+		if test.ExpectedErr {
+			continue
+		}
+		assert.Equal(t, test.ExpectedEnv, vnt.Environment, test.Test)
+		cfg, ok := vnt.Config(GoPackage)
+		assert.True(t, ok, test.Test)
+		assert.Equal(t, test.ExpectedCfg, cfg, test.Test)
+	}
+
+	vnt, err := ws.selectVariants([]string{"fips", "debug"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "debug+fips", vnt.Name)
+	assert.Equal(t, []string{"debug.go"}, vnt.Sources.Include)
+	assert.True(t, vnt.ExcludeComponent("crypto-legacy"))
+
+	var rawCfg map[string]interface{}
+	rawNode := vnt.RawConfig[GoPackage]
+	err = rawNode.Decode(&rawCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{"generate": true, "buildFlags": []interface{}{"-tags=fips"}}, rawCfg)
+}
+
+func TestVersionIgnoresModificationTime(t *testing.T) {
+	files := map[string]string{
+		"WORKSPACE.yaml": "",
+		"comp/lib.txt":   "hello world\n",
+		"comp/BUILD.yaml": `packages:
+- name: lib
+  type: generic
+  srcs:
+  - lib.txt
+  config:
+    commands:
+    - ["cat", "lib.txt"]
+- name: app
+  type: generic
+  deps:
+  - :lib
+  config:
+    commands:
+    - ["echo", "app"]
+`,
+	}
+	loc := t.TempDir()
+	for fn, content := range files {
+		fn = filepath.Join(loc, fn)
+		err := os.MkdirAll(filepath.Dir(fn), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(fn, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions := func() map[string]string {
+		// every load starts from scratch, s.t. no cached version survives
+		ws, err := FindWorkspace(loc, Arguments{}, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := make(map[string]string)
+		for _, name := range []string{"comp:lib", "comp:app"} {
+			pkg, ok := ws.Packages[name]
+			if !ok {
+				t.Fatalf("package %s does not exist", name)
+			}
+			res[name], err = pkg.Version()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return res
+	}
+
+	expected := versions()
+	for i, fn := range []string{"WORKSPACE.yaml", "comp/lib.txt", "comp/BUILD.yaml"} {
+		// like a fresh checkout on another machine, every file gets a different modification time
+		mtime := time.Now().Add(time.Duration(i+1) * -24 * time.Hour)
+		err := os.Chtimes(filepath.Join(loc, fn), mtime, mtime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, expected, versions(), "versions changed with the modification time of the sources")
+
+	err := os.WriteFile(filepath.Join(loc, "comp/lib.txt"), []byte("hello world!\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := versions()
+	assert.NotEqual(t, expected["comp:lib"], changed["comp:lib"], "version of comp:lib did not change with its content")
+	assert.NotEqual(t, expected["comp:app"], changed["comp:app"], "version of comp:app did not change with the content of its dependency")
+}
+
+func TestVersionChangesWithCacheSalt(t *testing.T) {
+	loc := t.TempDir()
+	writeWorkspace := func(workspaceYAML string) {
+		for fn, content := range map[string]string{
+			"WORKSPACE.yaml":  workspaceYAML,
+			"comp/lib.txt":    "hello world\n",
+			"comp/BUILD.yaml": "packages:\n- name: lib\n  type: generic\n  srcs:\n  - lib.txt\n  config:\n    commands:\n    - [\"cat\", \"lib.txt\"]\n",
+		} {
+			fn = filepath.Join(loc, fn)
+			err := os.MkdirAll(filepath.Dir(fn), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.WriteFile(fn, []byte(content), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	version := func(workspaceYAML, envSalt string) string {
+		writeWorkspace(workspaceYAML)
+		t.Setenv(EnvvarCacheSalt, envSalt)
+		ws, err := FindWorkspace(loc, Arguments{}, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := ws.Packages["comp:lib"].Version()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	tests := []struct {
+		Name          string
+		WorkspaceYAML string
+		EnvSalt       string
+	}{
+		{Name: "no salt"},
+		{Name: "workspace salt", WorkspaceYAML: "cacheSalt: toolchain-1\n"},
+		{Name: "bumped workspace salt", WorkspaceYAML: "cacheSalt: toolchain-2\n"},
+		{Name: "env salt", EnvSalt: "toolchain-1"},
+		{Name: "workspace and env salt", WorkspaceYAML: "cacheSalt: toolchain-1\n", EnvSalt: "toolchain-1"},
+	}
+	seen := make(map[string]string)
+	for _, test := range tests {
+		act := version(test.WorkspaceYAML, test.EnvSalt)
+		if other, exists := seen[act]; exists {
+			t.Errorf("%s: version is the same as with %s", test.Name, other)
+		}
+		seen[act] = test.Name
+
+		if again := version(test.WorkspaceYAML, test.EnvSalt); again != act {
+			t.Errorf("%s: version is not stable: %s != %s", test.Name, act, again)
+		}
+	}
+}
+
+func TestFindWorkspaceReportsAllComponentErrors(t *testing.T) {
+	files := map[string]string{
+		"WORKSPACE.yaml":   "",
+		"good/BUILD.yaml":  "packages:\n- name: pkg\n  type: generic\n",
+		"bad1/BUILD.yaml":  "packages: [",
+		"bad2/BUILD.yaml":  "packages:\n- name: pkg\n  type: unknown\n",
+		"other/BUILD.yaml": "packages:\n- name: pkg\n  type: generic\n",
+	}
+	loc := t.TempDir()
+	for fn, content := range files {
+		fn = filepath.Join(loc, fn)
+		err := os.MkdirAll(filepath.Dir(fn), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(fn, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := FindWorkspace(loc, Arguments{}, "", "")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	msg := err.Error()
+	for _, comp := range []string{"bad1", "bad2"} {
+		assert.Contains(t, msg, filepath.Join(loc, comp, "BUILD.yaml"))
+	}
+	assert.Less(t, strings.Index(msg, "bad1"), strings.Index(msg, "bad2"), "errors must be reported in the order of the component paths")
+}
+
+func BenchmarkFindWorkspace(b *testing.B) {
+	loc := b.TempDir()
+	err := os.WriteFile(filepath.Join(loc, "WORKSPACE.yaml"), nil, 0644)
+	if err != nil {
+		b.Fatal(err)
+	}
+	const size = 600
+	for i := 0; i < size; i++ {
+		dir := filepath.Join(loc, "components", fmt.Sprintf("comp-%03d", i))
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			b.Fatal(err)
+		}
+		buildYAML := "packages:\n- name: lib\n  type: generic\n  srcs:\n  - \"*.txt\"\n"
+		if i > 0 {
+			buildYAML += fmt.Sprintf("  deps:\n  - components/comp-%03d:lib\n", i-1)
+		}
+		err = os.WriteFile(filepath.Join(dir, "BUILD.yaml"), []byte(buildYAML), 0644)
+		if err != nil {
+			b.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(dir, "src.txt"), []byte(dir), 0644)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	defaultLoads := maxConcurrentComponentLoads
+	b.Cleanup(func() { maxConcurrentComponentLoads = defaultLoads })
+	for _, loads := range []int{1, defaultLoads} {
+		b.Run(fmt.Sprintf("loads-%02d", loads), func(b *testing.B) {
+			maxConcurrentComponentLoads = loads
+			for n := 0; n < b.N; n++ {
+				ws, err := FindWorkspace(loc, Arguments{}, "", "")
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(ws.Packages) != size {
+					b.Fatalf("expected %d packages, found %d", size, len(ws.Packages))
+				}
+			}
+		})
+	}
+}
+
+func TestCheckArguments(t *testing.T) {
+	ws := Workspace{
+		Arguments: map[string]ArgumentSpec{
+			"registry": {Required: true},
+			"replicas": {Type: ArgumentTypeInt, Default: "1"},
+			"debug":    {Type: ArgumentTypeBool},
+		},
+	}
+
+	tests := []struct {
+		Name        string
+		Args        Arguments
+		Expectation Arguments
+		Error       string
+	}{
+		{
+			Name:        "defaults",
+			Args:        Arguments{"registry": "example.com"},
+			Expectation: Arguments{"registry": "example.com", "replicas": "1"},
+		},
+		{
+			Name:        "passed in",
+			Args:        Arguments{"registry": "example.com", "replicas": "3", "debug": "true"},
+			Expectation: Arguments{"registry": "example.com", "replicas": "3", "debug": "true"},
+		},
+		{
+			Name:  "missing required",
+			Args:  Arguments{"replicas": "3"},
+			Error: "build argument registry is required",
+		},
+		{
+			Name:  "invalid int",
+			Args:  Arguments{"registry": "example.com", "replicas": "three"},
+			Error: "invalid build argument replicas: \"three\" is not a valid int",
+		},
+		{
+			Name:  "invalid bool",
+			Args:  Arguments{"registry": "example.com", "debug": "yes"},
+			Error: "invalid build argument debug: \"yes\" is not a valid bool",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			err := ws.checkArguments(test.Args)
+			if test.Error != "" {
+				assert.ErrorContains(t, err, test.Error)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.Expectation, test.Args)
+		})
+	}
+
+	assert.Equal(t, []string{"regsitry"}, ws.UnknownArguments(Arguments{"regsitry": "example.com", "debug": "true"}))
+	assert.Nil(t, (&Workspace{}).UnknownArguments(Arguments{"regsitry": "example.com"}), "workspaces without declared arguments accept all arguments")
+}
+
+func TestFindWorkspaceUnknownArguments(t *testing.T) {
+	loc := t.TempDir()
+	err := os.WriteFile(filepath.Join(loc, "WORKSPACE.yaml"), []byte("args:\n  registry: {}\n  debug: {}\ndefaultArgs:\n  undeclared: default\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = FindWorkspace(loc, Arguments{"regsitry": "example.com"}, "", "")
+	var unknown *UnknownArgumentsError
+	if assert.ErrorAs(t, err, &unknown) {
+		assert.Equal(t, &UnknownArgumentsError{Unknown: []string{"regsitry"}, Known: []string{"debug", "registry"}}, unknown)
+		assert.EqualError(t, err, "unknown build arguments regsitry: valid arguments are debug, registry")
+	}
+
+	ws, err := FindWorkspace(loc, Arguments{"regsitry": "example.com"}, "", "", WithAllowUnknownArguments(true))
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", ws.BuildArguments["regsitry"])
+
+	// defaults need not be declared
+	_, err = FindWorkspace(loc, Arguments{"registry": "example.com"}, "", "")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,244 @@
+package blazedock
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gookit/color"
+)
+
+const (
+	// progressStatusWidth is the maximum width of the status line. Lines wider than the terminal wrap, and a wrapped
+	// line cannot be cleared anymore.
+	progressStatusWidth = 80
+
+	// progressInterval is how often the spinner of the status line moves
+	progressInterval = 100 * time.Millisecond
+
+	// progressClearLine moves the cursor to the start of the line and erases the line
+	progressClearLine = "\r\033[K"
+)
+
+var progressSpinner = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// NewProgressReporter creates a reporter which draws a status line to out. Out must be an interactive terminal.
+func NewProgressReporter(out io.Writer) *ProgressReporter {
+	return &ProgressReporter{
+		out:      out,
+		interval: progressInterval,
+	}
+}
+
+// ProgressReporter shows a status line below the build output while packages build. The line lists how many of the
+// packages which need building are done and which packages build right now.
+//
+// The status line shares the terminal with all other output. Everything printed while packages build must go through
+// a writer obtained from Writer, which takes the status line out of the way before it prints complete lines.
+type ProgressReporter struct {
+	NoopReporter
+
+	out      io.Writer
+	interval time.Duration
+
+	mu       sync.Mutex
+	builds   int
+	total    int
+	done     int
+	building []string
+	started  time.Time
+	frame    int
+	shown    bool
+	writers  []*progressWriter
+	stop     chan struct{}
+}
+
+// Writer returns a writer to out which does not garble the status line. It holds back incomplete lines while the
+// status line is shown.
+func (r *ProgressReporter) Writer(out io.Writer) io.Writer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w := &progressWriter{r: r, out: out}
+	r.writers = append(r.writers, w)
+	return w
+}
+
+// BuildStarted implements Reporter
+func (r *ProgressReporter) BuildStarted(pkg *Package, status map[*Package]PackageBuildStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range status {
+		if s != PackageBuilt && s != PackageDownloaded {
+			r.total++
+		}
+	}
+	r.builds++
+	if r.builds > 1 {
+		return
+	}
+
+	r.started = time.Now()
+	r.stop = make(chan struct{})
+	go r.spin(r.stop)
+}
+
+// PackageBuildStarted implements Reporter
+func (r *ProgressReporter) PackageBuildStarted(pkg *Package) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.building = append(r.building, pkg.FullName())
+	r.draw()
+}
+
+// PackageBuildFinished implements Reporter
+func (r *ProgressReporter) PackageBuildFinished(pkg *Package, rep *PackageBuildReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := pkg.FullName()
+	for i, n := range r.building {
+		if n == name {
+			r.building = append(r.building[:i], r.building[i+1:]...)
+			break
+		}
+	}
+	r.done++
+	r.draw()
+}
+
+// BuildFinished implements Reporter
+func (r *ProgressReporter) BuildFinished(pkg *Package, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.builds--
+	if r.builds > 0 {
+		return
+	}
+
+	close(r.stop)
+	r.building = nil
+	r.clear()
+	for _, w := range r.writers {
+		//nolint:errcheck
+		w.flush()
+	}
+}
+
+// spin moves the spinner until stop is closed
+func (r *ProgressReporter) spin(stop chan struct{}) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			r.frame++
+			r.draw()
+			r.mu.Unlock()
+		}
+	}
+}
+
+// active returns true if the status line is to be shown. It's hidden while no package builds, e.g. while the build
+// results are uploaded, because not all output of the build goes through our writers. Callers must hold mu.
+func (r *ProgressReporter) active() bool {
+	return r.builds > 0 && len(r.building) > 0
+}
+
+// draw replaces the status line. Callers must hold mu.
+func (r *ProgressReporter) draw() {
+	if !r.active() {
+		r.clear()
+		return
+	}
+
+	//nolint:errcheck
+	io.WriteString(r.out, progressClearLine+r.status())
+	r.shown = true
+}
+
+// clear removes the status line. Callers must hold mu.
+func (r *ProgressReporter) clear() {
+	if !r.shown {
+		return
+	}
+
+	//nolint:errcheck
+	io.WriteString(r.out, progressClearLine)
+	r.shown = false
+}
+
+// status renders the status line, listing as many of the building packages as fit
+func (r *ProgressReporter) status() string {
+	spinner := progressSpinner[r.frame%len(progressSpinner)]
+	head := fmt.Sprintf("[%d/%d] %s building ", r.done, r.total, time.Since(r.started).Truncate(time.Second))
+
+	var (
+		width = utf8.RuneCountInString(spinner) + 1 + utf8.RuneCountInString(head)
+		names []string
+	)
+	for i, name := range r.building {
+		var more string
+		if rest := len(r.building) - i - 1; rest > 0 {
+			more = fmt.Sprintf(" and %d more", rest)
+		}
+		if i > 0 && width+len(name)+2+len(more) > progressStatusWidth {
+			names = append(names, fmt.Sprintf("and %d more", len(r.building)-i))
+			break
+		}
+		names = append(names, name)
+		width += len(name) + 2
+	}
+	return color.Sprintf("<yellow>%s</> %s<gray>%s</>", spinner, head, strings.Join(names, ", "))
+}
+
+// progressWriter holds back incomplete lines while the status line is shown, s.t. the status line never ends up in
+// the middle of a line
+type progressWriter struct {
+	r   *ProgressReporter
+	out io.Writer
+	buf []byte
+}
+
+func (w *progressWriter) Write(p []byte) (n int, err error) {
+	w.r.mu.Lock()
+	defer w.r.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	if !w.r.active() {
+		return len(p), w.flush()
+	}
+
+	idx := bytes.LastIndexByte(w.buf, '\n')
+	if idx < 0 {
+		return len(p), nil
+	}
+	w.r.clear()
+	_, err = w.out.Write(w.buf[:idx+1])
+	w.buf = append(w.buf[:0], w.buf[idx+1:]...)
+	w.r.draw()
+	return len(p), err
+}
+
+// flush writes everything held back. Callers must hold the reporter's mu.
+func (w *progressWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.out.Write(w.buf)
+	w.buf = w.buf[:0]
+	return err
+}
+
+var _ Reporter = &ProgressReporter{}
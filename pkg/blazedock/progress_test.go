@@ -0,0 +1,81 @@
+package blazedock
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gookit/color"
+)
+
+func TestProgressReporter(t *testing.T) {
+	var (
+		comp   = &Component{Name: "comp"}
+		a      = &Package{C: comp, PackageInternal: PackageInternal{Name: "a", Type: GenericPackage}}
+		b      = &Package{C: comp, PackageInternal: PackageInternal{Name: "b", Type: GenericPackage}}
+		cached = &Package{C: comp, PackageInternal: PackageInternal{Name: "cached", Type: GenericPackage}}
+		out    bytes.Buffer
+	)
+
+	r := NewProgressReporter(&out)
+	r.interval = time.Hour
+	w := r.Writer(&out)
+
+	r.BuildStarted(b, map[*Package]PackageBuildStatus{cached: PackageBuilt, a: PackageNotBuiltYet, b: PackageNotBuiltYet})
+	r.PackageBuildStarted(a)
+	if act := color.ClearCode(out.String()); !strings.Contains(act, "[0/2]") || !strings.Contains(act, "comp:a") {
+		t.Errorf("status line %q does not show the progress and comp:a", act)
+	}
+
+	// the prefix writers of the console reporter write the prefix and the line separately
+	_, _ = w.Write([]byte("[comp:a] "))
+	_, _ = w.Write([]byte("hello\n[comp:a] wor"))
+	r.PackageBuildStarted(b)
+	r.PackageBuildFinished(a, &PackageBuildReport{})
+	if act := color.ClearCode(out.String()); !strings.HasSuffix(act, "[1/2] 0s building comp:b") {
+		t.Errorf("status line %q does not show the progress and comp:b", act)
+	}
+	r.PackageBuildFinished(b, &PackageBuildReport{})
+	r.BuildFinished(b, nil)
+	_, _ = w.Write([]byte("ld\n"))
+
+	act := out.String()
+	if !strings.Contains(act, progressClearLine+"[comp:a] hello\n") {
+		t.Errorf("complete lines are not printed in place of the status line: %q", act)
+	}
+	if !strings.HasSuffix(act, progressClearLine+"[comp:a] world\n") {
+		t.Errorf("incomplete lines are not printed once the status line is gone: %q", act)
+	}
+}
+
+func TestProgressReporterStatus(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Building int
+		Expected string
+	}{
+		{Name: "single package", Building: 1, Expected: "comp:package-0"},
+		{Name: "all fit", Building: 3, Expected: "comp:package-0, comp:package-1, comp:package-2"},
+		{Name: "too many", Building: 10, Expected: "comp:package-0, comp:package-1, comp:package-2, and 7 more"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			r := NewProgressReporter(&bytes.Buffer{})
+			r.started = time.Now()
+			for i := 0; i < test.Building; i++ {
+				r.building = append(r.building, fmt.Sprintf("comp:package-%d", i))
+			}
+
+			act := color.ClearCode(r.status())
+			if !strings.HasSuffix(act, " building "+test.Expected) {
+				t.Errorf("status() = %q; expected it to end with %q", act, test.Expected)
+			}
+			if w := utf8.RuneCountInString(act); w > progressStatusWidth {
+				t.Errorf("status() is %d wide; expected at most %d", w, progressStatusWidth)
+			}
+		})
+	}
+}
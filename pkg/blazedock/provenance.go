@@ -3,11 +3,12 @@ package blazedock
 import (
 	"archive/tar"
 	"bufio"
-	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -19,9 +20,13 @@ import (
 	"github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
 	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	slsav1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/xerrors"
 	"sigs.k8s.io/bom/pkg/provenance"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
 )
 
 const (
@@ -37,10 +42,14 @@ const (
 	// provenanceProcessVersion is the version of the provenance generating process.
 	// If provenance is enabled in a workspace, this version becomes part of the manifest,
 	// hence changing it will invalidate previously built packages.
-	provenanceProcessVersion = 3
+	provenanceProcessVersion = 4
 
 	// ProvenanceBuilderID is the prefix we use as Builder ID when issuing provenance
 	ProvenanceBuilderID = "github.com/khulnasoft/blazedock"
+
+	// StatementInTotoV1 is the statement type of in-toto attestation framework v1 statements, which SLSA v1.0
+	// provenance is issued as. in-toto-golang only knows the v0.1 statement type.
+	StatementInTotoV1 = "https://in-toto.io/Statement/v1"
 )
 
 // writeProvenance produces a provenanceWriter which ought to be used during package builds
@@ -103,7 +112,8 @@ func (p *Package) getDependenciesProvenanceBundles(buildctx *buildContext, dst *
 var ErrNoAttestationBundle error = fmt.Errorf("no attestation bundle found")
 
 // AccessAttestationBundleInCachedArchive provides access to the attestation bundle in a cached build artifact.
-// If no such bundle exists, ErrNoAttestationBundle is returned.
+// If no such bundle exists, ErrNoAttestationBundle is returned. If the archive does not match its recorded
+// checksum, a *cache.ChecksumMismatchError is returned.
 func AccessAttestationBundleInCachedArchive(fn string, handler func(bundle io.Reader) error) (err error) {
 	defer func() {
 		if err != nil {
@@ -111,20 +121,19 @@ func AccessAttestationBundleInCachedArchive(fn string, handler func(bundle io.Re
 		}
 	}()
 
-	f, err := os.Open(fn)
-	if err != nil {
+	err = cache.VerifyChecksum(fn, false)
+	if err != nil && !errors.Is(err, cache.ErrNoChecksum) {
 		return err
 	}
-	defer f.Close()
 
-	g, err := gzip.NewReader(f)
+	in, err := openArchive(fn)
 	if err != nil {
 		return err
 	}
-	defer g.Close()
+	defer in.Close()
 
 	var bundleFound bool
-	a := tar.NewReader(g)
+	a := tar.NewReader(in)
 	var hdr *tar.Header
 	for {
 		hdr, err = a.Next()
@@ -218,6 +227,11 @@ func (p *Package) produceSLSAEnvelope(buildctx *buildContext, subjects []in_toto
 		pred.Materials = []common.ProvenanceMaterial{
 			{URI: "git+" + git.Origin, Digest: common.DigestSet{"sha256": git.Commit}},
 		}
+		submodules, err := p.gitSubmoduleMaterials(git)
+		if err != nil {
+			return nil, err
+		}
+		pred.Materials = append(pred.Materials, submodules...)
 	}
 
 	pred.Builder = common.ProvenanceBuilder{
@@ -233,37 +247,170 @@ func (p *Package) produceSLSAEnvelope(buildctx *buildContext, subjects []in_toto
 		BuildStartedOn:  &buildStarted,
 		BuildFinishedOn: &now,
 	}
+	params := map[string]interface{}{
+		"args":      p.C.W.redactCommandLine(os.Args),
+		"buildArgs": p.C.W.RedactedArguments(),
+	}
+	if vnt := p.C.W.SelectedVariant; vnt != nil {
+		params["variant"] = vnt.Name
+	}
+	env := map[string]interface{}{
+		"manifest": p.C.W.EnvironmentManifest,
+		"hermetic": buildctx.Hermetic,
+	}
+	if passthrough := p.PassedThroughEnvironment(buildctx.Hermetic); passthrough != nil {
+		// we only record which of the allowlisted variables were set - their values may well be secrets
+		env["passthrough"] = passthrough
+	}
 	pred.Invocation = slsa.ProvenanceInvocation{
 		ConfigSource: slsa.ConfigSource{
 			URI:        fmt.Sprintf("https://github.com/khulnasoft/blazedock/build@%s:%d", p.Type, buildProcessVersions[p.Type]),
 			Digest:     map[string]string{},
 			EntryPoint: p.FullName(),
 		},
-		Parameters: map[string]interface{}{
-			"args": os.Args,
-		},
-		Environment: map[string]interface{}{
-			"manifest": p.C.W.EnvironmentManifest,
-		},
+		Parameters:  params,
+		Environment: env,
 	}
 
-	stmt := provenance.NewSLSAStatement()
-	stmt.Subject = subjects
-	stmt.PredicateType = slsa.PredicateSLSAProvenance
-	stmt.Predicate = pred
+	var stmt interface{}
+	if p.C.W.Provenance.SLSAVersion == SLSAVersion1 {
+		stmt = in_toto.ProvenanceStatementSLSA1{
+			StatementHeader: in_toto.StatementHeader{
+				Type:          StatementInTotoV1,
+				PredicateType: slsav1.PredicateSLSAProvenance,
+				Subject:       subjects,
+			},
+			Predicate: slsaV1Predicate(pred.Builder.ID, pred.Materials, pred.Invocation, pred.Metadata),
+		}
+	} else {
+		s := provenance.NewSLSAStatement()
+		s.Subject = subjects
+		s.PredicateType = slsa.PredicateSLSAProvenance
+		s.Predicate = pred
+		stmt = s
+	}
 
 	payload, err := json.MarshalIndent(stmt, "", "  ")
 	if err != nil {
 		return nil, fmt.Errorf("cannot marshal provenance for %s: %w", p.FullName(), err)
 	}
 
-	var sigs []interface{}
-	if p.C.W.Provenance.key != nil {
-		sig, err := in_toto.GenerateSignature(payload, *p.C.W.Provenance.key)
+	sigs, err := p.C.W.Provenance.sign(payload)
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign provenance for %s: %w", p.FullName(), err)
+	}
+
+	return &provenance.Envelope{
+		PayloadType: in_toto.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  sigs,
+	}, nil
+}
+
+// sign signs an in-toto statement payload keyless, with a KMS key or with the in-toto key of the workspace, whichever
+// is configured. Without any of them the payload remains unsigned.
+func (wp *WorkspaceProvenance) sign(payload []byte) ([]interface{}, error) {
+	// keyless signatures carry the signing certificate, hence they're of another type than the others
+	var (
+		sig interface{}
+		err error
+	)
+	switch {
+	case wp.signer != nil:
+		sig, err = wp.signer.Sign(context.Background(), in_toto.PayloadType, payload)
+	case wp.kmsSigner != nil:
+		sig, err = wp.kmsSigner.Sign(context.Background(), in_toto.PayloadType, payload)
+	case wp.key != nil:
+		sig, err = SignEnvelopePayload(in_toto.PayloadType, payload, *wp.key)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{sig}, nil
+}
+
+// AggregateProvenance produces a single provenance envelope covering the build of several packages, e.g. of all
+// packages a target pattern matches. The subjects are the cached build artifacts of the packages named by the
+// packages' full names, the materials are given by the caller. The envelope is signed like the provenance of each
+// package is.
+func AggregateProvenance(target string, pkgs []*Package, loc cache.LocalCache, materials []common.ProvenanceMaterial) (*provenance.Envelope, error) {
+	if len(pkgs) == 0 {
+		return nil, xerrors.Errorf("cannot aggregate provenance of no packages")
+	}
+	ws := pkgs[0].C.W
+	if !ws.Provenance.Enabled {
+		return nil, xerrors.Errorf("provenance is disabled in this workspace")
+	}
+
+	subjects := make([]in_toto.Subject, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		fn, ok := loc.Location(pkg)
+		if !ok {
+			return nil, xerrors.Errorf("%s is not built", pkg.FullName())
+		}
+		hash, err := sha256Hash(fn)
 		if err != nil {
-			return nil, fmt.Errorf("cannot sign provenance for %s: %w", p.FullName(), err)
+			return nil, xerrors.Errorf("cannot compute digest of %s: %w", pkg.FullName(), err)
+		}
+		subjects = append(subjects, in_toto.Subject{
+			Name:   pkg.FullName(),
+			Digest: common.DigestSet{"sha256": hash},
+		})
+	}
+
+	builderID := ProvenanceBuilderID + ":" + Version
+	if self, err := os.Executable(); err == nil {
+		if hash, err := sha256Hash(self); err == nil {
+			builderID += "@sha256:" + hash
+		}
+	}
+	invocation := slsa.ProvenanceInvocation{
+		ConfigSource: slsa.ConfigSource{
+			URI:        fmt.Sprintf("https://github.com/khulnasoft/blazedock/aggregate@%d", provenanceProcessVersion),
+			Digest:     map[string]string{},
+			EntryPoint: target,
+		},
+		Parameters: map[string]interface{}{
+			"args":      ws.redactCommandLine(os.Args),
+			"buildArgs": ws.RedactedArguments(),
+		},
+		Environment: map[string]interface{}{
+			"manifest": ws.EnvironmentManifest,
+		},
+	}
+
+	var stmt interface{}
+	if ws.Provenance.SLSAVersion == SLSAVersion1 {
+		stmt = in_toto.ProvenanceStatementSLSA1{
+			StatementHeader: in_toto.StatementHeader{
+				Type:          StatementInTotoV1,
+				PredicateType: slsav1.PredicateSLSAProvenance,
+				Subject:       subjects,
+			},
+			Predicate: slsaV1Predicate(builderID, materials, invocation, nil),
 		}
-		sigs = append(sigs, sig)
+	} else {
+		pred := provenance.NewSLSAPredicate()
+		pred.Builder = common.ProvenanceBuilder{ID: builderID}
+		pred.Invocation = invocation
+		pred.Materials = materials
+
+		s := provenance.NewSLSAStatement()
+		s.Subject = subjects
+		s.PredicateType = slsa.PredicateSLSAProvenance
+		s.Predicate = pred
+		stmt = s
+	}
+
+	payload, err := json.MarshalIndent(stmt, "", "  ")
+	if err != nil {
+		return nil, xerrors.Errorf("cannot marshal aggregate provenance: %w", err)
+	}
+	sigs, err := ws.Provenance.sign(payload)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot sign aggregate provenance: %w", err)
 	}
 
 	return &provenance.Envelope{
@@ -273,6 +420,90 @@ func (p *Package) produceSLSAEnvelope(buildctx *buildContext, subjects []in_toto
 	}, nil
 }
 
+// gitSubmoduleMaterials returns a material for every submodule which contains sources of the package.
+// The commit of the working copy does not identify the content of its submodules, hence they're materials of their own.
+func (p *Package) gitSubmoduleMaterials(git *GitInfo) ([]common.ProvenanceMaterial, error) {
+	var res []common.ProvenanceMaterial
+	for _, sm := range git.Submodules {
+		dir := filepath.Join(git.WorkingCopyLoc, sm.Path) + string(filepath.Separator)
+		var used bool
+		for _, src := range p.Sources {
+			if strings.HasPrefix(src, dir) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			continue
+		}
+		if sm.Origin == "" {
+			return nil, xerrors.Errorf("Git provenance is unclear - submodule %s has no origin", sm.Path)
+		}
+		res = append(res, common.ProvenanceMaterial{
+			URI:    "git+" + sm.Origin,
+			Digest: common.DigestSet{"sha256": sm.Commit},
+		})
+	}
+	return res, nil
+}
+
+// slsaV1Predicate maps the parts of a SLSA v0.2 predicate to their SLSA v1.0 counterparts: the config source
+// becomes the build type, the invocation parameters become external parameters and the materials become
+// resolved dependencies.
+func slsaV1Predicate(builderID string, materials []common.ProvenanceMaterial, invocation slsa.ProvenanceInvocation, metadata *slsa.ProvenanceMetadata) slsav1.ProvenancePredicate {
+	deps := make([]slsav1.ResourceDescriptor, 0, len(materials))
+	for _, m := range materials {
+		deps = append(deps, slsav1.ResourceDescriptor{URI: m.URI, Digest: m.Digest})
+	}
+
+	externalParams := map[string]interface{}{
+		"entryPoint": invocation.ConfigSource.EntryPoint,
+	}
+	if params, ok := invocation.Parameters.(map[string]interface{}); ok {
+		for k, v := range params {
+			externalParams[k] = v
+		}
+	}
+
+	res := slsav1.ProvenancePredicate{
+		BuildDefinition: slsav1.ProvenanceBuildDefinition{
+			BuildType:            invocation.ConfigSource.URI,
+			ExternalParameters:   externalParams,
+			InternalParameters:   invocation.Environment,
+			ResolvedDependencies: deps,
+		},
+		RunDetails: slsav1.ProvenanceRunDetails{
+			Builder: slsav1.Builder{ID: builderID},
+		},
+	}
+	if metadata != nil {
+		res.RunDetails.BuildMetadata = slsav1.BuildMetadata{
+			StartedOn:  metadata.BuildStartedOn,
+			FinishedOn: metadata.BuildFinishedOn,
+		}
+	}
+	return res
+}
+
+// SignEnvelopePayload produces a DSSE signature over the pre-authentication encoding of the payload type and the
+// (not base64 encoded) payload of an envelope
+func SignEnvelopePayload(payloadType string, payload []byte, key in_toto.Key) (*dsse.Signature, error) {
+	sig, err := in_toto.GenerateSignature(dsse.PAE(payloadType, payload), key)
+	if err != nil {
+		return nil, err
+	}
+
+	// in-toto hex-encodes signatures, DSSE expects them base64 encoded
+	raw, err := hex.DecodeString(sig.Sig)
+	if err != nil {
+		return nil, err
+	}
+	return &dsse.Signature{
+		KeyID: sig.KeyID,
+		Sig:   base64.StdEncoding.EncodeToString(raw),
+	}, nil
+}
+
 func (p *Package) inTotoMaterials() ([]common.ProvenanceMaterial, error) {
 	res := make([]common.ProvenanceMaterial, 0, len(p.Sources))
 	for _, src := range p.Sources {
@@ -0,0 +1,150 @@
+package blazedock
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RedactedValue replaces the values of build arguments, config fields and environment variables which are secret
+const RedactedValue = "<redacted>"
+
+// secretNameIndicators are the name fragments of build arguments, config fields and environment variables which likely hold secrets
+var secretNameIndicators = []string{"token", "secret", "password", "passwd", "credential", "apikey", "api_key", "privatekey", "private_key"}
+
+// LooksLikeSecret returns true if the name of a build argument, config field or environment variable suggests it holds a secret
+func LooksLikeSecret(name string) bool {
+	name = strings.ToLower(name)
+	for _, ind := range secretNameIndicators {
+		if strings.Contains(name, ind) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSecretArgument returns true if the value of the build argument must not be disclosed, i.e. if it is declared
+// secret in the WORKSPACE.yaml or its name suggests it holds a secret
+func (ws *Workspace) IsSecretArgument(name string) bool {
+	return ws.Arguments[name].Secret || LooksLikeSecret(name)
+}
+
+// RedactedArguments returns the build arguments the workspace was loaded with, with the values of secret arguments redacted
+func (ws *Workspace) RedactedArguments() map[string]string {
+	return ws.redactArguments(ws.BuildArguments)
+}
+
+func (ws *Workspace) redactArguments(args map[string]string) map[string]string {
+	res := make(map[string]string, len(args))
+	for name, value := range args {
+		if ws.IsSecretArgument(name) {
+			value = RedactedValue
+		}
+		res[name] = value
+	}
+	return res
+}
+
+// secretValues returns the values of the secret build arguments, longest first s.t. no secret is partially redacted
+func (ws *Workspace) secretValues() []string {
+	var res []string
+	for name, value := range ws.BuildArguments {
+		if value == "" || !ws.IsSecretArgument(name) {
+			continue
+		}
+		res = append(res, value)
+	}
+	sort.Slice(res, func(i, j int) bool { return len(res[i]) > len(res[j]) })
+	return res
+}
+
+// Redact replaces the values of secret build arguments in s, e.g. where they were substituted into a command
+func (ws *Workspace) Redact(s string) string {
+	return redactValues(s, ws.secretValues())
+}
+
+func redactValues(s string, secrets []string) string {
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, RedactedValue)
+	}
+	return s
+}
+
+// redactCommandLine redacts the values of secret build arguments passed on the command line using -D or --build-arg
+func (ws *Workspace) redactCommandLine(args []string) []string {
+	res := make([]string, len(args))
+	copy(res, args)
+
+	redact := func(arg string) string {
+		name, _, ok := strings.Cut(arg, "=")
+		if !ok || !ws.IsSecretArgument(name) {
+			return arg
+		}
+		return name + "=" + RedactedValue
+	}
+	for i, arg := range res {
+		switch {
+		case (arg == "-D" || arg == "--build-arg") && i+1 < len(res):
+			res[i+1] = redact(res[i+1])
+		case strings.HasPrefix(arg, "--build-arg="):
+			res[i] = "--build-arg=" + redact(strings.TrimPrefix(arg, "--build-arg="))
+		case strings.HasPrefix(arg, "-D") && len(arg) > 2:
+			res[i] = "-D" + redact(strings.TrimPrefix(arg, "-D"))
+		}
+	}
+	return res
+}
+
+// RedactionHook is a logrus hook which redacts the values of the secret build arguments of a workspace from
+// all log messages and fields, including errors
+type RedactionHook struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// SetWorkspace makes the hook redact the secret build arguments of ws
+func (h *RedactionHook) SetWorkspace(ws *Workspace) {
+	secrets := ws.secretValues()
+
+	h.mu.Lock()
+	h.secrets = secrets
+	h.mu.Unlock()
+}
+
+// Levels implements log.Hook
+func (h *RedactionHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements log.Hook
+func (h *RedactionHook) Fire(entry *log.Entry) error {
+	h.mu.RLock()
+	secrets := h.secrets
+	h.mu.RUnlock()
+	if len(secrets) == 0 {
+		return nil
+	}
+
+	entry.Message = redactValues(entry.Message, secrets)
+	for k, v := range entry.Data {
+		switch v := v.(type) {
+		case string:
+			entry.Data[k] = redactValues(v, secrets)
+		case error:
+			if msg := redactValues(v.Error(), secrets); msg != v.Error() {
+				entry.Data[k] = errors.New(msg)
+			}
+		default:
+			if s := fmt.Sprint(v); redactValues(s, secrets) != s {
+				entry.Data[k] = redactValues(s, secrets)
+			}
+		}
+	}
+	return nil
+}
+
+var _ log.Hook = &RedactionHook{}
@@ -0,0 +1,109 @@
+package blazedock
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestRedactedArguments(t *testing.T) {
+	ws := &Workspace{BuildArguments: Arguments{"version": "1.0", "npmToken": "s3cr3t", "DB_PASSWORD": "hunter2"}}
+
+	expectation := map[string]string{"version": "1.0", "npmToken": RedactedValue, "DB_PASSWORD": RedactedValue}
+	if diff := cmp.Diff(expectation, ws.RedactedArguments()); diff != "" {
+		t.Errorf("RedactedArguments() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRedactCommandLine(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Args        []string
+		Expectation []string
+	}{
+		{
+			Name:        "no build args",
+			Args:        []string{"blazedock", "build", "-v", "comp:pkg"},
+			Expectation: []string{"blazedock", "build", "-v", "comp:pkg"},
+		},
+		{
+			Name:        "short flag",
+			Args:        []string{"blazedock", "build", "-Dversion=1.0", "-DnpmToken=s3cr3t"},
+			Expectation: []string{"blazedock", "build", "-Dversion=1.0", "-DnpmToken=" + RedactedValue},
+		},
+		{
+			Name:        "separate value",
+			Args:        []string{"blazedock", "build", "-D", "npmToken=s3cr3t", "--build-arg", "version=1.0"},
+			Expectation: []string{"blazedock", "build", "-D", "npmToken=" + RedactedValue, "--build-arg", "version=1.0"},
+		},
+		{
+			Name:        "long flag",
+			Args:        []string{"blazedock", "build", "--build-arg=npmToken=s3cr3t"},
+			Expectation: []string{"blazedock", "build", "--build-arg=npmToken=" + RedactedValue},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act := (&Workspace{}).redactCommandLine(test.Args)
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("redactCommandLine() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSecretArguments(t *testing.T) {
+	ws := &Workspace{
+		Arguments: map[string]ArgumentSpec{
+			"registryAuth": {Secret: true},
+			"port":         {Type: ArgumentTypeInt, Secret: true},
+			"version":      {},
+		},
+		BuildArguments: Arguments{"registryAuth": "dXNlcjpzM2NyM3Q=", "version": "1.0"},
+	}
+
+	if !ws.IsSecretArgument("registryAuth") {
+		t.Errorf("declared secret argument is not secret")
+	}
+	if ws.IsSecretArgument("version") {
+		t.Errorf("argument which is not declared secret is secret")
+	}
+	if act := ws.Redact("docker login --auth dXNlcjpzM2NyM3Q= registry/1.0"); act != "docker login --auth "+RedactedValue+" registry/1.0" {
+		t.Errorf("Redact() = %q", act)
+	}
+
+	err := ws.checkArguments(Arguments{"port": "s3cr3t"})
+	if err == nil {
+		t.Fatalf("checkArguments() accepted an invalid value")
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("checkArguments() error discloses the secret value: %v", err)
+	}
+}
+
+func TestRedactionHook(t *testing.T) {
+	ws := &Workspace{
+		Arguments:      map[string]ArgumentSpec{"registryAuth": {Secret: true}},
+		BuildArguments: Arguments{"registryAuth": "dXNlcjpzM2NyM3Q=", "version": "1.0"},
+	}
+	hook := &RedactionHook{}
+	hook.SetWorkspace(ws)
+
+	var out bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	logger.SetLevel(log.DebugLevel)
+	logger.AddHook(hook)
+
+	logger.WithField("args", map[string]string(ws.BuildArguments)).WithError(fmt.Errorf("cannot log in using dXNlcjpzM2NyM3Q=")).Debug("running docker login --auth dXNlcjpzM2NyM3Q=")
+	if strings.Contains(out.String(), "dXNlcjpzM2NyM3Q=") {
+		t.Errorf("log output discloses the secret value: %s", out.String())
+	}
+	if !strings.Contains(out.String(), "version:1.0") {
+		t.Errorf("log output lacks the arguments which are not secret: %s", out.String())
+	}
+}
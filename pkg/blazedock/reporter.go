@@ -51,9 +51,15 @@ type Reporter interface {
 type PackageBuildReport struct {
 	phaseEnter map[PackageBuildPhase]time.Time
 	phaseDone  map[PackageBuildPhase]time.Time
+	// testLog is the output of the test phase, nil if the package was built without running tests
+	testLog []byte
+	// testPhase is the phase the tests of the package ran in
+	testPhase PackageBuildPhase
 
 	Phases []PackageBuildPhase
 	Error  error
+	// Retries is how often failed build and test commands were re-run
+	Retries int
 
 	TestCoverageAvailable  bool
 	TestCoveragePercentage int
@@ -92,9 +98,13 @@ func (rep *PackageBuildReport) TotalTime() time.Duration {
 
 // ConsoleReporter reports build progress by printing to stdout/stderr
 type ConsoleReporter struct {
-	writer map[string]io.Writer
+	out    io.Writer
+	outMu  sync.Mutex
+	writer map[string]*packageWriter
 	times  map[string]time.Time
-	mu     sync.RWMutex
+	// retried counts the retries of the packages which built successfully only after re-running failed commands
+	retried map[string]int
+	mu      sync.RWMutex
 }
 
 // exclusiveWriter makes a write an exclusive resource by protecting Write calls with a mutex.
@@ -110,15 +120,48 @@ func (w *exclusiveWriter) Write(p []byte) (n int, err error) {
 	return w.O.Write(p)
 }
 
+// packageWriter prefixes every line of a package's output with the package name. The writers of all packages
+// share one mutex, s.t. the lines of packages built concurrently interleave, but never mix.
+type packageWriter struct {
+	w  *textio.PrefixWriter
+	mu *sync.Mutex
+}
+
+func (w *packageWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.w.Write(p)
+}
+
+// finishLine terminates an incomplete last line, which the prefix writer would hold back otherwise.
+func (w *packageWriter) finishLine() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.w.Buffered()) == 0 {
+		return nil
+	}
+	_, err := w.w.Write([]byte("\n"))
+	return err
+}
+
 // NewConsoleReporter produces a new console logger
 func NewConsoleReporter() *ConsoleReporter {
+	return NewConsoleReporterTo(os.Stdout)
+}
+
+// NewConsoleReporterTo produces a new console logger which prints to out instead of stdout
+func NewConsoleReporterTo(out io.Writer) *ConsoleReporter {
 	return &ConsoleReporter{
-		writer: make(map[string]io.Writer),
-		times:  make(map[string]time.Time),
+		out:     out,
+		writer:  make(map[string]*packageWriter),
+		times:   make(map[string]time.Time),
+		retried: make(map[string]int),
 	}
 }
 
-func (r *ConsoleReporter) getWriter(pkg *Package) io.Writer {
+func (r *ConsoleReporter) getWriter(pkg *Package) *packageWriter {
 	name := pkg.FullName()
 
 	r.mu.RLock()
@@ -134,7 +177,7 @@ func (r *ConsoleReporter) getWriter(pkg *Package) io.Writer {
 			return res
 		}
 
-		res = &exclusiveWriter{O: textio.NewPrefixWriter(os.Stdout, getRunPrefix(pkg))}
+		res = &packageWriter{w: textio.NewPrefixWriter(r.out, getRunPrefix(pkg)), mu: &r.outMu}
 		r.writer[name] = res
 		r.mu.Unlock()
 	}
@@ -166,7 +209,7 @@ func (r *ConsoleReporter) BuildStarted(pkg *Package, status map[*Package]Package
 		i++
 	}
 	sort.Slice(lines, func(i, j int) bool { return lines[i] < lines[j] })
-	tw := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	tw := tabwriter.NewWriter(r.out, 0, 2, 2, ' ', 0)
 	fmt.Fprintln(tw, strings.Join(lines, ""))
 	tw.Flush()
 }
@@ -174,11 +217,34 @@ func (r *ConsoleReporter) BuildStarted(pkg *Package, status map[*Package]Package
 // BuildFinished is called when the build of a package which was started by the user has finished.
 func (r *ConsoleReporter) BuildFinished(pkg *Package, err error) {
 	if err != nil {
-		color.Printf("<red>build failed</>\n<white>Reason:</> %s\n", err)
+		color.Fprintf(r.out, "<red>build failed</>\n<white>Reason:</> %s\n", err)
 		return
 	}
 
-	color.Println("\n<green>build succeeded</>")
+	r.mu.Lock()
+	retried := r.retried
+	r.retried = make(map[string]int)
+	r.mu.Unlock()
+	if len(retried) > 0 {
+		names := make([]string, 0, len(retried))
+		for name := range retried {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		color.Fprintf(r.out, "\n<yellow>%d packages only built after retrying failed commands:</>\n", len(names))
+		for _, name := range names {
+			color.Fprintf(r.out, "  %s <gray>(%s)</>\n", name, pluralizeRetries(retried[name]))
+		}
+	}
+
+	color.Fprintln(r.out, "\n<green>build succeeded</>")
+}
+
+func pluralizeRetries(n int) string {
+	if n == 1 {
+		return "1 retry"
+	}
+	return fmt.Sprintf("%d retries", n)
 }
 
 // PackageBuildStarted is called when a package build actually gets underway.
@@ -213,17 +279,31 @@ func (r *ConsoleReporter) PackageBuildFinished(pkg *Package, rep *PackageBuildRe
 	dur := time.Since(r.times[nme])
 	delete(r.writer, nme)
 	delete(r.times, nme)
+	if rep.Error == nil && rep.Retries > 0 {
+		r.retried[nme] = rep.Retries
+	}
 	r.mu.Unlock()
 
+	//nolint:errcheck
+	out.finishLine()
+
 	var msg string
   if rep.Error != nil {
-		msg = color.Sprintf("<red>package build failed while %sing</>\n<white>Reason:</> %s\n", rep.LastPhase(), rep.Error)
+		var retries string
+		if rep.Retries > 0 {
+			retries = color.Sprintf(" <gray>(gave up after %s)</>", pluralizeRetries(rep.Retries))
+		}
+		msg = color.Sprintf("<red>package build failed while %sing</>%s\n<white>Reason:</> %s\n", rep.LastPhase(), retries, rep.Error)
 	} else {
 		var coverage string
 		if rep.TestCoverageAvailable {
 			coverage = color.Sprintf("<fg=yellow>test coverage: %d%%</> <gray>(%d of %d functions have tests)</>\n", rep.TestCoveragePercentage, rep.FunctionsWithTest, rep.FunctionsWithTest+rep.FunctionsWithoutTest)
 		}
-		msg = color.Sprintf("%s<green>package build succeded</> <gray>(%.2fs)</>\n", coverage, dur.Seconds())
+		var retries string
+		if rep.Retries > 0 {
+			retries = color.Sprintf(" <yellow>after %s</>", pluralizeRetries(rep.Retries))
+		}
+		msg = color.Sprintf("%s<green>package build succeded</>%s <gray>(%.2fs)</>\n", coverage, retries, dur.Seconds())
 	}
 	//nolint:errcheck
 	io.WriteString(out, msg)
@@ -659,3 +739,315 @@ func (sr *GitHubActionReporter) PackageBuildFinished(pkg *Package, rep *PackageB
 	}
 	fmt.Fprintf(f, "%s=%v\n", pkg.FilesystemSafeName(), success)
 }
+
+// NewChromeTraceReporter creates a reporter which writes the package builds as Chrome Trace Event JSON to filename.
+// The file can be opened in chrome://tracing or https://ui.perfetto.dev.
+func NewChromeTraceReporter(filename string) *ChromeTraceReporter {
+	return &ChromeTraceReporter{
+		filename: filename,
+		start:    time.Now(),
+		active:   make(map[string]chromeTraceActiveBuild),
+	}
+}
+
+// ChromeTraceReporter records a duration event for each package build. Packages built concurrently are placed on
+// separate lanes, s.t. parallel builds render side by side. Cache hits are recorded as instant events on a lane of their own.
+type ChromeTraceReporter struct {
+	NoopReporter
+
+	filename string
+	start    time.Time
+
+	mu     sync.Mutex
+	events []chromeTraceEvent
+	lanes  []bool
+	active map[string]chromeTraceActiveBuild
+}
+
+type chromeTraceActiveBuild struct {
+	Lane  int
+	Start time.Time
+}
+
+// chromeTraceEvent is an event of the Chrome Trace Event format. Timestamps and durations are in microseconds.
+// See https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+type chromeTraceEvent struct {
+	Name      string            `json:"name"`
+	Category  string            `json:"cat,omitempty"`
+	Phase     string            `json:"ph"`
+	Timestamp int64             `json:"ts"`
+	Duration  int64             `json:"dur,omitempty"`
+	PID       int               `json:"pid"`
+	TID       int               `json:"tid"`
+	Scope     string            `json:"s,omitempty"`
+	Args      map[string]string `json:"args,omitempty"`
+}
+
+// chromeTraceCacheLane is the lane cache hits are recorded on. Package builds use the lanes above it.
+const chromeTraceCacheLane = 0
+
+func (r *ChromeTraceReporter) timestamp(t time.Time) int64 {
+	return t.Sub(r.start).Microseconds()
+}
+
+// BuildStarted implements Reporter
+func (r *ChromeTraceReporter) BuildStarted(pkg *Package, status map[*Package]PackageBuildStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.events) == 0 {
+		r.events = append(r.events, chromeTraceEvent{Name: "thread_name", Phase: "M", PID: 1, TID: chromeTraceCacheLane, Args: map[string]string{"name": "cache"}})
+	}
+
+	ts := r.timestamp(time.Now())
+	var hits []chromeTraceEvent
+	for p, s := range status {
+		var cat string
+		switch s {
+		case PackageBuilt:
+			cat = "local-cache-hit"
+		case PackageDownloaded:
+			cat = "remote-cache-hit"
+		default:
+			continue
+		}
+		hits = append(hits, chromeTraceEvent{Name: p.FullName(), Category: cat, Phase: "i", Scope: "t", Timestamp: ts, PID: 1, TID: chromeTraceCacheLane})
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Name < hits[j].Name })
+	r.events = append(r.events, hits...)
+}
+
+// PackageBuildStarted implements Reporter
+func (r *ChromeTraceReporter) PackageBuildStarted(pkg *Package) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lane := -1
+	for i, busy := range r.lanes {
+		if !busy {
+			lane = i
+			break
+		}
+	}
+	if lane == -1 {
+		lane = len(r.lanes)
+		r.lanes = append(r.lanes, false)
+		r.events = append(r.events, chromeTraceEvent{Name: "thread_name", Phase: "M", PID: 1, TID: lane + 1, Args: map[string]string{"name": fmt.Sprintf("worker %d", lane+1)}})
+	}
+	r.lanes[lane] = true
+	r.active[pkg.FullName()] = chromeTraceActiveBuild{Lane: lane, Start: time.Now()}
+}
+
+// PackageBuildFinished implements Reporter
+func (r *ChromeTraceReporter) PackageBuildFinished(pkg *Package, rep *PackageBuildReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bld, ok := r.active[pkg.FullName()]
+	if !ok {
+		return
+	}
+	delete(r.active, pkg.FullName())
+	r.lanes[bld.Lane] = false
+
+	args := map[string]string{"type": string(pkg.Type)}
+	if version, err := pkg.Version(); err == nil {
+		args["version"] = version
+	}
+	for _, phase := range rep.Phases {
+		args[string(phase)] = rep.PhaseDuration(phase).String()
+	}
+	if rep.Error != nil {
+		args["error"] = rep.Error.Error()
+	}
+	r.events = append(r.events, chromeTraceEvent{
+		Name:      pkg.FullName(),
+		Category:  "build",
+		Phase:     "X",
+		Timestamp: r.timestamp(bld.Start),
+		Duration:  time.Since(bld.Start).Microseconds(),
+		PID:       1,
+		TID:       bld.Lane + 1,
+		Args:      args,
+	})
+}
+
+// BuildFinished implements Reporter
+func (r *ChromeTraceReporter) BuildFinished(pkg *Package, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fc, err := json.Marshal(struct {
+		TraceEvents     []chromeTraceEvent `json:"traceEvents"`
+		DisplayTimeUnit string             `json:"displayTimeUnit"`
+	}{
+		TraceEvents:     r.events,
+		DisplayTimeUnit: "ms",
+	})
+	if err != nil {
+		log.WithError(err).Warn("cannot marshal build trace")
+		return
+	}
+	err = os.WriteFile(r.filename, fc, 0644)
+	if err != nil {
+		log.WithField("fn", r.filename).WithError(err).Warn("cannot write build trace")
+	}
+}
+
+var _ Reporter = &ChromeTraceReporter{}
+
+// NewJSONReporter creates a reporter which writes build lifecycle events as newline-delimited JSON to out.
+func NewJSONReporter(out io.Writer) *JSONReporter {
+	return &JSONReporter{
+		log: &log.Logger{
+			Out:       &exclusiveWriter{O: out},
+			Formatter: &log.JSONFormatter{TimestampFormat: time.RFC3339Nano},
+			Hooks:     make(log.LevelHooks),
+			Level:     log.InfoLevel,
+		},
+		starts: make(map[string]time.Time),
+	}
+}
+
+// JSONReporter emits one JSON object per line for every build lifecycle event, s.t. CI systems and dashboards can
+// consume the build progress without parsing the console output. Each event carries an "event" field and the package
+// it concerns. Build output is emitted as package-build-log events, one per line.
+type JSONReporter struct {
+	log *log.Logger
+
+	mu     sync.Mutex
+	starts map[string]time.Time
+}
+
+// Events emitted by the JSONReporter
+const (
+	JSONEventBuildStarted         = "build-started"
+	JSONEventBuildFinished        = "build-finished"
+	JSONEventBuildFailed          = "build-failed"
+	JSONEventPackageCacheHit      = "package-cache-hit"
+	JSONEventPackageBuildStarted  = "package-build-started"
+	JSONEventPackageBuildLog      = "package-build-log"
+	JSONEventPackageBuildFinished = "package-build-finished"
+	JSONEventPackageBuildFailed   = "package-build-failed"
+)
+
+func (r *JSONReporter) event(event string, pkg *Package) *log.Entry {
+	return r.log.WithFields(log.Fields{
+		"event":   event,
+		"package": pkg.FullName(),
+	})
+}
+
+func (r *JSONReporter) start(name string) {
+	r.mu.Lock()
+	r.starts[name] = time.Now()
+	r.mu.Unlock()
+}
+
+// duration returns the time since name was started and forgets about its start
+func (r *JSONReporter) duration(name string) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start, ok := r.starts[name]
+	if !ok {
+		return 0
+	}
+	delete(r.starts, name)
+	return time.Since(start)
+}
+
+// BuildStarted implements Reporter
+func (r *JSONReporter) BuildStarted(pkg *Package, status map[*Package]PackageBuildStatus) {
+	r.start("build:" + pkg.FullName())
+
+	var (
+		hits    []*Package
+		toBuild int
+	)
+	for p, s := range status {
+		if s == PackageBuilt || s == PackageDownloaded {
+			hits = append(hits, p)
+		} else {
+			toBuild++
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].FullName() < hits[j].FullName() })
+	r.event(JSONEventBuildStarted, pkg).WithFields(log.Fields{
+		"packages":  len(status),
+		"cacheHits": len(hits),
+		"toBuild":   toBuild,
+	}).Info("build started")
+
+	for _, p := range hits {
+		cache := "local"
+		if status[p] == PackageDownloaded {
+			cache = "remote"
+		}
+		e := r.event(JSONEventPackageCacheHit, p).WithField("cache", cache)
+		if version, err := p.Version(); err == nil {
+			e = e.WithField("version", version)
+		}
+		e.Info("package found in cache")
+	}
+}
+
+// PackageBuildStarted implements Reporter
+func (r *JSONReporter) PackageBuildStarted(pkg *Package) {
+	r.start(pkg.FullName())
+
+	e := r.event(JSONEventPackageBuildStarted, pkg).WithField("type", string(pkg.Type))
+	if version, err := pkg.Version(); err == nil {
+		e = e.WithField("version", version)
+	}
+	e.Info("package build started")
+}
+
+// PackageBuildLog implements Reporter
+func (r *JSONReporter) PackageBuildLog(pkg *Package, isErr bool, buf []byte) {
+	stream := "stdout"
+	if isErr {
+		stream = "stderr"
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(buf), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		r.event(JSONEventPackageBuildLog, pkg).WithField("stream", stream).Info(line)
+	}
+}
+
+// PackageBuildFinished implements Reporter
+func (r *JSONReporter) PackageBuildFinished(pkg *Package, rep *PackageBuildReport) {
+	dur := r.duration(pkg.FullName())
+
+	phases := make(map[string]float64, len(rep.Phases))
+	for _, phase := range rep.Phases {
+		phases[string(phase)] = rep.PhaseDuration(phase).Seconds()
+	}
+	e := r.event(JSONEventPackageBuildFinished, pkg).WithFields(log.Fields{
+		"duration": dur.Seconds(),
+		"phases":   phases,
+		"retries":  rep.Retries,
+	})
+	if version, err := pkg.Version(); err == nil {
+		e = e.WithField("version", version)
+	}
+	if rep.Error != nil {
+		e.WithField("event", JSONEventPackageBuildFailed).WithField("phase", string(rep.LastPhase())).WithError(rep.Error).Error("package build failed")
+		return
+	}
+	e.Info("package build finished")
+}
+
+// BuildFinished implements Reporter
+func (r *JSONReporter) BuildFinished(pkg *Package, err error) {
+	e := r.event(JSONEventBuildFinished, pkg).WithField("duration", r.duration("build:"+pkg.FullName()).Seconds())
+	if err != nil {
+		e.WithField("event", JSONEventBuildFailed).WithError(err).Error("build failed")
+		return
+	}
+	e.Info("build finished")
+}
+
+var _ Reporter = &JSONReporter{}
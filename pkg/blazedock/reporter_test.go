@@ -0,0 +1,162 @@
+package blazedock
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChromeTraceReporter(t *testing.T) {
+	var (
+		comp  = &Component{Name: "comp"}
+		a     = &Package{C: comp, PackageInternal: PackageInternal{Name: "a", Type: GenericPackage}}
+		b     = &Package{C: comp, PackageInternal: PackageInternal{Name: "b", Type: GenericPackage}}
+		c     = &Package{C: comp, PackageInternal: PackageInternal{Name: "c", Type: GenericPackage}}
+		cache = &Package{C: comp, PackageInternal: PackageInternal{Name: "cached", Type: GenericPackage}}
+		fn    = filepath.Join(t.TempDir(), "trace.json")
+	)
+
+	r := NewChromeTraceReporter(fn)
+	r.BuildStarted(c, map[*Package]PackageBuildStatus{cache: PackageBuilt, a: PackageNotBuiltYet})
+	// a and b are built concurrently, c is built once a has finished and reuses its lane
+	r.PackageBuildStarted(a)
+	r.PackageBuildStarted(b)
+	r.PackageBuildFinished(a, &PackageBuildReport{})
+	r.PackageBuildStarted(c)
+	r.PackageBuildFinished(c, &PackageBuildReport{})
+	r.PackageBuildFinished(b, &PackageBuildReport{})
+	r.BuildFinished(c, nil)
+
+	fc, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []chromeTraceEvent `json:"traceEvents"`
+	}
+	err = json.Unmarshal(fc, &trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type event struct {
+		Name  string
+		Phase string
+		TID   int
+	}
+	var act []event
+	for _, e := range trace.TraceEvents {
+		act = append(act, event{Name: e.Name, Phase: e.Phase, TID: e.TID})
+	}
+	expectation := []event{
+		{Name: "thread_name", Phase: "M", TID: 0},
+		{Name: "comp:cached", Phase: "i", TID: 0},
+		{Name: "thread_name", Phase: "M", TID: 1},
+		{Name: "thread_name", Phase: "M", TID: 2},
+		{Name: "comp:a", Phase: "X", TID: 1},
+		{Name: "comp:c", Phase: "X", TID: 1},
+		{Name: "comp:b", Phase: "X", TID: 2},
+	}
+	if diff := cmp.Diff(expectation, act); diff != "" {
+		t.Errorf("ChromeTraceReporter mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestJSONReporter(t *testing.T) {
+	var (
+		comp   = &Component{Name: "comp"}
+		a      = &Package{C: comp, PackageInternal: PackageInternal{Name: "a", Type: GenericPackage}}
+		b      = &Package{C: comp, PackageInternal: PackageInternal{Name: "b", Type: GenericPackage}}
+		cached = &Package{C: comp, PackageInternal: PackageInternal{Name: "cached", Type: GenericPackage}}
+		out    bytes.Buffer
+	)
+
+	r := NewJSONReporter(&out)
+	r.BuildStarted(b, map[*Package]PackageBuildStatus{cached: PackageDownloaded, a: PackageNotBuiltYet, b: PackageNotBuiltYet})
+	r.PackageBuildStarted(a)
+	r.PackageBuildLog(a, false, []byte("hello\nworld\n"))
+	r.PackageBuildFinished(a, &PackageBuildReport{})
+	r.PackageBuildStarted(b)
+	r.PackageBuildLog(b, true, []byte("oops"))
+	r.PackageBuildFinished(b, &PackageBuildReport{Error: errors.New("failed")})
+	r.BuildFinished(b, errors.New("failed"))
+
+	type event struct {
+		Event   string `json:"event"`
+		Package string `json:"package"`
+		Msg     string `json:"msg"`
+		Stream  string `json:"stream,omitempty"`
+		Cache   string `json:"cache,omitempty"`
+		Error   string `json:"error,omitempty"`
+	}
+	var act []event
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var e event
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			t.Fatalf("cannot unmarshal %q: %v", scanner.Text(), err)
+		}
+		act = append(act, e)
+	}
+	expectation := []event{
+		{Event: JSONEventBuildStarted, Package: "comp:b", Msg: "build started"},
+		{Event: JSONEventPackageCacheHit, Package: "comp:cached", Msg: "package found in cache", Cache: "remote"},
+		{Event: JSONEventPackageBuildStarted, Package: "comp:a", Msg: "package build started"},
+		{Event: JSONEventPackageBuildLog, Package: "comp:a", Msg: "hello", Stream: "stdout"},
+		{Event: JSONEventPackageBuildLog, Package: "comp:a", Msg: "world", Stream: "stdout"},
+		{Event: JSONEventPackageBuildFinished, Package: "comp:a", Msg: "package build finished"},
+		{Event: JSONEventPackageBuildStarted, Package: "comp:b", Msg: "package build started"},
+		{Event: JSONEventPackageBuildLog, Package: "comp:b", Msg: "oops", Stream: "stderr"},
+		{Event: JSONEventPackageBuildFailed, Package: "comp:b", Msg: "package build failed", Error: "failed"},
+		{Event: JSONEventBuildFailed, Package: "comp:b", Msg: "build failed", Error: "failed"},
+	}
+	if diff := cmp.Diff(expectation, act); diff != "" {
+		t.Errorf("JSONReporter mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestConsoleReporter(t *testing.T) {
+	var (
+		comp = &Component{Name: "comp"}
+		a    = &Package{C: comp, PackageInternal: PackageInternal{Name: "a", Type: GenericPackage}}
+		b    = &Package{C: comp, PackageInternal: PackageInternal{Name: "b", Type: GenericPackage}}
+		out  bytes.Buffer
+	)
+
+	// a and b are built concurrently and write partial lines
+	r := NewConsoleReporterTo(&out)
+	r.PackageBuildLog(a, false, []byte("hello "))
+	r.PackageBuildLog(b, false, []byte("foo\nba"))
+	r.PackageBuildLog(a, false, []byte("world\n"))
+	r.PackageBuildLog(b, true, []byte("r\nunterminated"))
+	r.PackageBuildFinished(b, &PackageBuildReport{})
+	r.PackageBuildFinished(a, &PackageBuildReport{})
+
+	var act []string
+	for _, line := range strings.Split(strings.TrimSpace(ansiEscape.ReplaceAllString(out.String(), "")), "\n") {
+		if strings.Contains(line, "package build succeded") {
+			continue
+		}
+		act = append(act, line)
+	}
+	expectation := []string{
+		"[comp:b] foo",
+		"[comp:a] hello world",
+		"[comp:b] bar",
+		"[comp:b] unterminated",
+	}
+	if diff := cmp.Diff(expectation, act); diff != "" {
+		t.Errorf("ConsoleReporter mismatch (-want +got):\n%s", diff)
+	}
+}
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
@@ -0,0 +1,78 @@
+package blazedock
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/khulnasoft/blazedock/pkg/doublestar"
+)
+
+// IsTargetPattern returns true if the target names a set of packages rather than a single package or component,
+// e.g. components/api/... or some-component:*
+func IsTargetPattern(target string) bool {
+	return target == "..." || strings.HasSuffix(target, "/...") || strings.ContainsAny(target, "*?[")
+}
+
+// FindPackages returns the packages matching a target pattern, sorted by their full name. Patterns are either
+//   - <component path>/... which matches all packages of the components at or below that path. ... alone matches all packages.
+//   - <component glob>:<package glob>, e.g. some-component:* or components/**:test-*. ** matches any number of
+//     component path segments. Without the :<package glob> all packages of the matching components match.
+//
+// Patterns which match no package are an error.
+func (ws *Workspace) FindPackages(pattern string) ([]*Package, error) {
+	match, err := targetMatcher(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*Package
+	for _, pkg := range ws.Packages {
+		if match(pkg.C.Name, pkg.Name) {
+			res = append(res, pkg)
+		}
+	}
+	if len(res) == 0 {
+		return nil, xerrors.Errorf("no package matches %s", pattern)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].FullName() < res[j].FullName() })
+	return res, nil
+}
+
+func targetMatcher(pattern string) (func(comp, pkg string) bool, error) {
+	if pattern == "..." {
+		return func(comp, pkg string) bool { return true }, nil
+	}
+	if base, ok := strings.CutSuffix(pattern, "/..."); ok {
+		return func(comp, pkg string) bool {
+			return comp == base || strings.HasPrefix(comp, base+"/")
+		}, nil
+	}
+
+	compPattern, pkgPattern, hasPkg := strings.Cut(pattern, ":")
+	if !hasPkg {
+		pkgPattern = "*"
+	}
+	// check the syntax of the pattern once, s.t. matching cannot fail later on
+	for _, p := range []string{compPattern, pkgPattern} {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, xerrors.Errorf("invalid target pattern %s: %w", pattern, err)
+		}
+	}
+	return func(comp, pkg string) bool {
+		var ok bool
+		if strings.Contains(compPattern, "**") {
+			ok, _ = doublestar.Match(compPattern, comp)
+		} else {
+			// unlike doublestar.Match, path.Match does not match components below the pattern
+			ok, _ = path.Match(compPattern, comp)
+		}
+		if !ok {
+			return false
+		}
+		ok, _ = path.Match(pkgPattern, pkg)
+		return ok
+	}, nil
+}
@@ -0,0 +1,72 @@
+package blazedock
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindPackages(t *testing.T) {
+	ws := &Workspace{Packages: make(map[string]*Package)}
+	for comp, pkgs := range map[string][]string{
+		"":                        {"root"},
+		"components/api":          {"app", "lib"},
+		"components/api/client":   {"lib", "test-e2e"},
+		"components/apiserver":    {"app"},
+		"components/web":          {"app"},
+		"components/web/frontend": {"test-unit"},
+	} {
+		c := &Component{Name: comp}
+		for _, name := range pkgs {
+			pkg := &Package{C: c, PackageInternal: PackageInternal{Name: name}}
+			ws.Packages[pkg.FullName()] = pkg
+		}
+	}
+
+	tests := []struct {
+		Pattern     string
+		Expectation []string
+		Error       bool
+	}{
+		{Pattern: "...", Expectation: []string{":root", "components/api/client:lib", "components/api/client:test-e2e", "components/api:app", "components/api:lib", "components/apiserver:app", "components/web/frontend:test-unit", "components/web:app"}},
+		{Pattern: "components/api/...", Expectation: []string{"components/api/client:lib", "components/api/client:test-e2e", "components/api:app", "components/api:lib"}},
+		{Pattern: "components/api:*", Expectation: []string{"components/api:app", "components/api:lib"}},
+		{Pattern: "components/*", Expectation: []string{"components/api:app", "components/api:lib", "components/apiserver:app", "components/web:app"}},
+		{Pattern: "components/*:app", Expectation: []string{"components/api:app", "components/apiserver:app", "components/web:app"}},
+		{Pattern: "components/**:test-*", Expectation: []string{"components/api/client:test-e2e", "components/web/frontend:test-unit"}},
+		{Pattern: "components/db/...", Error: true},
+		{Pattern: "components/api:test-*", Error: true},
+		{Pattern: "components/[api", Error: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Pattern, func(t *testing.T) {
+			if !IsTargetPattern(test.Pattern) {
+				t.Errorf("%s is not a target pattern", test.Pattern)
+			}
+
+			pkgs, err := ws.FindPackages(test.Pattern)
+			if test.Error {
+				if err == nil {
+					t.Errorf("expected an error, got %d packages", len(pkgs))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			act := make([]string, 0, len(pkgs))
+			for _, p := range pkgs {
+				act = append(act, p.FullName())
+			}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("FindPackages() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	for _, target := range []string{"components/api:app", "components/api", "."} {
+		if IsTargetPattern(target) {
+			t.Errorf("%s is a target pattern", target)
+		}
+	}
+}
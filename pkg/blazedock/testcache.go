@@ -0,0 +1,78 @@
+package blazedock
+
+import (
+	"bytes"
+	"os"
+	"sync"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+)
+
+// testLogRecorder records the output of the test phase of packages. The output is stored in the local cache next to
+// the build artifact, s.t. it can be replayed when the tests are served from the test cache.
+type testLogRecorder struct {
+	NoopReporter
+
+	mu   sync.Mutex
+	logs map[*Package]*bytes.Buffer
+}
+
+func newTestLogRecorder() *testLogRecorder {
+	return &testLogRecorder{logs: make(map[*Package]*bytes.Buffer)}
+}
+
+func (r *testLogRecorder) start(p *Package) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs[p] = new(bytes.Buffer)
+}
+
+// stop ends the recording and returns the recorded output. The result is never nil, even if the tests produced no output.
+func (r *testLogRecorder) stop(p *Package) []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buf, ok := r.logs[p]
+	if !ok {
+		return []byte{}
+	}
+	delete(r.logs, p)
+	res := make([]byte, buf.Len())
+	copy(res, buf.Bytes())
+	return res
+}
+
+// PackageBuildLog implements Reporter
+func (r *testLogRecorder) PackageBuildLog(p *Package, isErr bool, buf []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if log, ok := r.logs[p]; ok {
+		log.Write(buf)
+	}
+}
+
+var _ Reporter = &testLogRecorder{}
+
+// writeTestLog stores the test output of a package next to its build artifact. Its presence tells that the tests of
+// the package passed for this version.
+func writeTestLog(artifact string, testLog []byte) error {
+	return os.WriteFile(cache.TestLogFilename(artifact), testLog, 0644)
+}
+
+// cachedTestLog returns the output of the tests of a package, if the package is in the local cache and its tests
+// passed when it was built. It returns nil otherwise, e.g. if the package was built without running its tests or
+// comes from the remote cache.
+func cachedTestLog(p *Package, localCache cache.LocalCache) ([]byte, error) {
+	loc, exists := localCache.Location(p)
+	if !exists {
+		return nil, nil
+	}
+	res, err := os.ReadFile(cache.TestLogFilename(loc))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
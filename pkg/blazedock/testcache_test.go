@@ -0,0 +1,98 @@
+package blazedock
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache"
+	"github.com/khulnasoft/blazedock/pkg/blazedock/cache/local"
+)
+
+type logRecordingReporter struct {
+	NoopReporter
+
+	mu  sync.Mutex
+	out strings.Builder
+}
+
+func (r *logRecordingReporter) PackageBuildLog(pkg *Package, isErr bool, buf []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.out.Write(buf)
+}
+
+func TestTestCache(t *testing.T) {
+	t.Setenv(EnvvarBuildDir, t.TempDir())
+
+	var (
+		loc     = t.TempDir()
+		testRun = filepath.Join(t.TempDir(), "test-runs")
+	)
+	for fn, content := range map[string]string{
+		"WORKSPACE.yaml":  "",
+		"comp/lib.txt":    "hello world\n",
+		"comp/BUILD.yaml": "packages:\n- name: lib\n  type: generic\n  srcs:\n  - lib.txt\n  config:\n    commands:\n    - [\"cp\", \"lib.txt\", \"lib.out\"]\n    test:\n    - [\"sh\", \"-c\", \"echo testing lib; echo run >> " + testRun + "\"]\n",
+	} {
+		fn = filepath.Join(loc, fn)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lc, err := local.NewFilesystemCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	build := func(opts ...BuildOption) (runs int, out string) {
+		ws, err := FindWorkspace(loc, Arguments{}, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rep := &logRecordingReporter{}
+		err = Build(ws.Packages["comp:lib"], append([]BuildOption{WithLocalCache(lc), WithReporter(rep)}, opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fc, _ := os.ReadFile(testRun)
+		return strings.Count(string(fc), "run"), rep.out.String()
+	}
+
+	if runs, _ := build(WithDontTest(true)); runs != 0 {
+		t.Fatalf("tests ran %d times although they were disabled", runs)
+	}
+	if runs, out := build(WithTestCache(true)); runs != 1 || !strings.Contains(out, "testing lib") {
+		t.Errorf("expected the tests to run for the package built without tests, got %d runs and output %q", runs, out)
+	}
+	if runs, out := build(WithTestCache(true)); runs != 1 || !strings.Contains(out, "testing lib") {
+		t.Errorf("expected the test output to be replayed from the test cache, got %d runs and output %q", runs, out)
+	}
+	if runs, _ := build(WithTestCache(true), WithForceTest(true)); runs != 2 {
+		t.Errorf("expected --force-test to rerun the tests, got %d runs", runs)
+	}
+	if runs, out := build(); runs != 2 || strings.Contains(out, "testing lib") {
+		t.Errorf("expected a regular build to take the package from the cache, got %d runs and output %q", runs, out)
+	}
+
+	// a package built into an empty cache has no artifact whose name the test log could follow
+	lc, err = local.NewFilesystemCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs, _ := build(WithTestCache(true)); runs != 3 {
+		t.Errorf("expected the tests to run for the package built into an empty cache, got %d runs", runs)
+	}
+	if logs, _ := filepath.Glob(filepath.Join(lc.Origin, "*"+cache.TestLogSuffix)); len(logs) != 1 {
+		t.Errorf("expected one test log in the cache, got %v", logs)
+	} else if _, err := os.Stat(strings.TrimSuffix(logs[0], cache.TestLogSuffix)); err != nil {
+		t.Errorf("test log %s does not sit next to the artifact: %v", logs[0], err)
+	}
+	if runs, out := build(WithTestCache(true)); runs != 3 || !strings.Contains(out, "testing lib") {
+		t.Errorf("expected the test output to be replayed from the test cache, got %d runs and output %q", runs, out)
+	}
+}
@@ -0,0 +1,173 @@
+package blazedock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// TimingStatus describes how a package was produced during a build
+type TimingStatus string
+
+const (
+	// TimingStatusBuilt means the package was built
+	TimingStatusBuilt TimingStatus = "built"
+	// TimingStatusFailed means the package build failed
+	TimingStatusFailed TimingStatus = "failed"
+	// TimingStatusLocalHit means the package was found in the local cache
+	TimingStatusLocalHit TimingStatus = "local-hit"
+	// TimingStatusRemoteHit means the package was downloaded from the remote cache
+	TimingStatusRemoteHit TimingStatus = "remote-hit"
+)
+
+// TimingReport records how long it took to produce each package of a build.
+// For cache hits the duration is the time spent looking up the package in the local cache.
+// Remote cache lookups and downloads happen in bulk and are recorded for the build as a whole.
+type TimingReport struct {
+	Entries      []TimingReportEntry `json:"packages"`
+	RemoteLookup time.Duration       `json:"-"`
+	Download     time.Duration       `json:"-"`
+	Total        time.Duration       `json:"-"`
+
+	mu      sync.Mutex
+	started time.Time
+	lookups map[*Package]time.Duration
+}
+
+// TimingReportEntry is the time it took to produce a single package
+type TimingReportEntry struct {
+	Package  string        `json:"package"`
+	Status   TimingStatus  `json:"status"`
+	Duration time.Duration `json:"-"`
+}
+
+func newTimingReport() *TimingReport {
+	return &TimingReport{
+		started: time.Now(),
+		lookups: make(map[*Package]time.Duration),
+	}
+}
+
+// recordLookup records the time it took to check the local cache for a package
+func (rep *TimingReport) recordLookup(p *Package, dt time.Duration) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	rep.lookups[p] = dt
+}
+
+// recordCacheStatus adds an entry for all packages which were satisfied from a cache
+func (rep *TimingReport) recordCacheStatus(status map[*Package]PackageBuildStatus) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	for p, s := range status {
+		var ts TimingStatus
+		switch s {
+		case PackageBuilt:
+			ts = TimingStatusLocalHit
+		case PackageDownloaded:
+			ts = TimingStatusRemoteHit
+		default:
+			continue
+		}
+		rep.Entries = append(rep.Entries, TimingReportEntry{
+			Package:  p.FullName(),
+			Status:   ts,
+			Duration: rep.lookups[p],
+		})
+	}
+}
+
+// recordBuild adds an entry for a package which was built
+func (rep *TimingReport) recordBuild(p *Package, dt time.Duration, err error) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	status := TimingStatusBuilt
+	if err != nil {
+		status = TimingStatusFailed
+	}
+	rep.Entries = append(rep.Entries, TimingReportEntry{
+		Package:  p.FullName(),
+		Status:   status,
+		Duration: dt,
+	})
+}
+
+// finish stops the clock and sorts the entries, slowest first
+func (rep *TimingReport) finish() {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	rep.Total = time.Since(rep.started)
+	sort.SliceStable(rep.Entries, func(i, j int) bool {
+		if rep.Entries[i].Duration != rep.Entries[j].Duration {
+			return rep.Entries[i].Duration > rep.Entries[j].Duration
+		}
+		return rep.Entries[i].Package < rep.Entries[j].Package
+	})
+}
+
+// Write prints the report as table followed by a summary line
+func (rep *TimingReport) Write(out io.Writer) error {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	var (
+		counts    = make(map[TimingStatus]int)
+		buildTime time.Duration
+	)
+	tw := tabwriter.NewWriter(out, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tSTATUS\tDURATION")
+	for _, e := range rep.Entries {
+		counts[e.Status]++
+		if e.Status == TimingStatusBuilt || e.Status == TimingStatusFailed {
+			buildTime += e.Duration
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Package, e.Status, e.Duration.Round(time.Millisecond))
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(out, "\nbuilt: %d (%s), failed: %d, cache hits: %d, remote lookup: %s, download: %s, total: %s\n",
+		counts[TimingStatusBuilt], buildTime.Round(time.Millisecond), counts[TimingStatusFailed],
+		counts[TimingStatusLocalHit]+counts[TimingStatusRemoteHit],
+		rep.RemoteLookup.Round(time.Millisecond), rep.Download.Round(time.Millisecond), rep.Total.Round(time.Millisecond))
+	return err
+}
+
+// WriteJSON writes the report as JSON. All durations are in milliseconds.
+func (rep *TimingReport) WriteJSON(out io.Writer) error {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	type jsonEntry struct {
+		TimingReportEntry
+		DurationMS int64 `json:"durationMs"`
+	}
+	res := struct {
+		Packages       []jsonEntry `json:"packages"`
+		RemoteLookupMS int64       `json:"remoteLookupMs"`
+		DownloadMS     int64       `json:"downloadMs"`
+		TotalMS        int64       `json:"totalMs"`
+	}{
+		Packages:       make([]jsonEntry, 0, len(rep.Entries)),
+		RemoteLookupMS: rep.RemoteLookup.Milliseconds(),
+		DownloadMS:     rep.Download.Milliseconds(),
+		TotalMS:        rep.Total.Milliseconds(),
+	}
+	for _, e := range rep.Entries {
+		res.Packages = append(res.Packages, jsonEntry{TimingReportEntry: e, DurationMS: e.Duration.Milliseconds()})
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
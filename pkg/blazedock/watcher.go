@@ -80,12 +80,16 @@ func WatchSources(ctx context.Context, pkgs []*Package, debounceDuration time.Du
 					go func() {
 						<-time.After(debounceDuration)
 						mu.RLock()
-						defer mu.RUnlock()
+						latest := c
+						mu.RUnlock()
 
-						if c != key {
+						if latest != key {
 							return
 						}
-						chng <- struct{}{}
+						select {
+						case chng <- struct{}{}:
+						case <-ctx.Done():
+						}
 					}()
 				}
 			}
@@ -110,11 +114,12 @@ func WatchSources(ctx context.Context, pkgs []*Package, debounceDuration time.Du
 				}
 				if !matches {
 					log.WithField("path", evt.Name).Debug("dismissed file event that did not match source globs")
-					return
+					continue
 				}
 
 				dfn := filepath.Dir(evt.Name)
 				if _, ok := folders[dfn]; !ok {
+					folders[dfn] = nil
 					matcher = append(matcher, &pathMatcher{
 						Base:     dfn,
 						Patterns: patterns,
@@ -125,9 +130,16 @@ func WatchSources(ctx context.Context, pkgs []*Package, debounceDuration time.Du
 				}
 
 				log.WithField("path", evt.Name).Debug("source file changed")
-				rawChng <- struct{}{}
+				select {
+				case rawChng <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
 			case err := <-watcher.Errors:
-				errchan <- err
+				select {
+				case errchan <- err:
+				default:
+				}
 			case <-ctx.Done():
 				return
 			}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -14,8 +15,8 @@ import (
 	"runtime"
 	"runtime/trace"
 	"sort"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/dop251/goja"
@@ -28,17 +29,29 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/khulnasoft/blazedock/pkg/doublestar"
+	"github.com/khulnasoft/blazedock/pkg/kms"
+	"github.com/khulnasoft/blazedock/pkg/sigstore"
 )
 
 // Workspace is the root container of all compoments. All components are named relative
 // to the origin of this workspace.
 type Workspace struct {
-	DefaultTarget       string              `yaml:"defaultTarget,omitempty"`
-	ArgumentDefaults    map[string]string   `yaml:"defaultArgs,omitempty"`
-	DefaultVariant      *PackageVariant     `yaml:"defaultVariant,omitempty"`
-	Variants            []*PackageVariant   `yaml:"variants,omitempty"`
-	EnvironmentManifest EnvironmentManifest `yaml:"environmentManifest,omitempty"`
-	Provenance          WorkspaceProvenance `yaml:"provenance,omitempty"`
+	DefaultTarget       string                  `yaml:"defaultTarget,omitempty"`
+	ArgumentDefaults    map[string]string       `yaml:"defaultArgs,omitempty"`
+	Arguments           map[string]ArgumentSpec `yaml:"args,omitempty"`
+	DefaultVariant      *PackageVariant         `yaml:"defaultVariant,omitempty"`
+	DefaultCacheLevel   CacheLevel              `yaml:"defaultCacheLevel,omitempty"`
+	Variants            []*PackageVariant       `yaml:"variants,omitempty"`
+	ExclusiveVariants   [][]string              `yaml:"exclusiveVariants,omitempty"`
+	EnvironmentManifest EnvironmentManifest     `yaml:"environmentManifest,omitempty"`
+	EnvPassthrough      []string                `yaml:"envPassthrough,omitempty"`
+	Provenance          WorkspaceProvenance     `yaml:"provenance,omitempty"`
+	Profiles            map[string]Profile      `yaml:"profiles,omitempty"`
+	RemoteCache         RemoteCacheConfig       `yaml:"remoteCache,omitempty"`
+	Vet                 VetConfig               `yaml:"vet,omitempty"`
+	Link                LinkConfig              `yaml:"link,omitempty"`
+	LayoutAssembly      LayoutAssembly          `yaml:"layoutAssembly,omitempty"`
+	CacheSalt           string                  `yaml:"cacheSalt,omitempty"`
 
 	Origin          string                `yaml:"-"`
 	Components      map[string]*Component `yaml:"-"`
@@ -46,16 +59,225 @@ type Workspace struct {
 	Scripts         map[string]*Script    `yaml:"-"`
 	SelectedVariant *PackageVariant       `yaml:"-"`
 	Git             GitInfo               `yaml:"-"`
+	BuildArguments  Arguments             `yaml:"-"`
 
-	ignores []string
+	ignoreSource    doublestar.IgnoreFunc
+	ignoreComponent doublestar.IgnoreFunc
+	// envCacheSalt is the salt set through $BLAZEDOCK_CACHE_SALT. It's mixed into the versions in addition to CacheSalt.
+	envCacheSalt string
 }
 
 type WorkspaceProvenance struct {
 	Enabled bool `yaml:"enabled"`
 	SLSA    bool `yaml:"slsa"`
+	// SLSAVersion selects the SLSA provenance predicate version. Defaults to SLSAVersion02.
+	SLSAVersion SLSAVersion `yaml:"slsaVersion,omitempty"`
 
 	KeyPath string       `yaml:"key"`
 	key     *in_toto.Key `yaml:"-"`
+	// KeyURI references a key held in a cloud KMS, e.g. gcpkms://projects/... or awskms:///arn:aws:kms:...,
+	// as an alternative to KeyPath
+	KeyURI    string      `yaml:"keyURI,omitempty"`
+	kmsSigner *kms.Signer `yaml:"-"`
+
+	// SigningMode selects how attestations are signed. Defaults to ProvenanceSigningKey.
+	SigningMode ProvenanceSigningMode `yaml:"signingMode,omitempty"`
+	// FulcioURL is the Fulcio instance issuing certificates in keyless mode. Defaults to the public instance.
+	FulcioURL string `yaml:"fulcioURL,omitempty"`
+	// RekorURL is the transparency log keyless signatures are recorded in. Defaults to the public instance.
+	RekorURL string                  `yaml:"rekorURL,omitempty"`
+	signer   *sigstore.KeylessSigner `yaml:"-"`
+}
+
+// ProvenanceSigningMode determines how attestations are signed
+type ProvenanceSigningMode string
+
+const (
+	// ProvenanceSigningKey signs attestations with the in-toto key found at the key path, if there is one
+	ProvenanceSigningKey ProvenanceSigningMode = "key"
+	// ProvenanceSigningKeyless signs attestations with short-lived certificates issued by Fulcio for
+	// the OIDC identity of the build
+	ProvenanceSigningKeyless ProvenanceSigningMode = "keyless"
+)
+
+// SLSAVersion is a version of the SLSA provenance predicate
+type SLSAVersion string
+
+const (
+	// SLSAVersion02 produces SLSA v0.2 provenance predicates
+	SLSAVersion02 SLSAVersion = "v0.2"
+	// SLSAVersion1 produces SLSA v1.0 provenance predicates
+	SLSAVersion1 SLSAVersion = "v1"
+)
+
+// ArgumentType is the type of a build argument's value
+type ArgumentType string
+
+const (
+	// ArgumentTypeString accepts any value. This is the default.
+	ArgumentTypeString ArgumentType = "string"
+	// ArgumentTypeBool accepts the values understood by strconv.ParseBool, e.g. true or false
+	ArgumentTypeBool ArgumentType = "bool"
+	// ArgumentTypeInt accepts integers
+	ArgumentTypeInt ArgumentType = "int"
+)
+
+// ArgumentSpec declares a build argument of the workspace. Once a workspace declares its arguments,
+// required arguments must be set and all values must match their type.
+type ArgumentSpec struct {
+	Description string       `yaml:"description,omitempty"`
+	Type        ArgumentType `yaml:"type,omitempty"`
+	// Required arguments must be passed in, unless there is a default
+	Required bool `yaml:"required,omitempty"`
+	// Default is used unless the argument is passed in or set in defaultArgs
+	Default string `yaml:"default,omitempty"`
+	// Secret arguments are used in the build, but their values are redacted from logs, errors and provenance
+	Secret bool `yaml:"secret,omitempty"`
+}
+
+// validate checks if value is a valid value of the argument
+func (spec ArgumentSpec) validate(value string) error {
+	var err error
+	switch spec.Type {
+	case "", ArgumentTypeString:
+	case ArgumentTypeBool:
+		_, err = strconv.ParseBool(value)
+	case ArgumentTypeInt:
+		_, err = strconv.Atoi(value)
+	default:
+		return xerrors.Errorf("unknown argument type %s: valid types are %s, %s and %s", spec.Type, ArgumentTypeString, ArgumentTypeBool, ArgumentTypeInt)
+	}
+	if err != nil {
+		return xerrors.Errorf("\"%s\" is not a valid %s", value, spec.Type)
+	}
+	return nil
+}
+
+// checkArguments applies the defaults of the declared arguments and validates the arguments against their declaration
+func (ws *Workspace) checkArguments(args Arguments) error {
+	names := make([]string, 0, len(ws.Arguments))
+	for name := range ws.Arguments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		spec := ws.Arguments[name]
+		value, ok := args[name]
+		if !ok && spec.Default != "" {
+			value, ok = spec.Default, true
+			args[name] = value
+		}
+		if !ok {
+			if spec.Required {
+				return xerrors.Errorf("build argument %s is required: use -D%s=value to set it", name, name)
+			}
+			continue
+		}
+		if err := spec.validate(value); err != nil {
+			if ws.IsSecretArgument(name) && value != "" {
+				return xerrors.Errorf("invalid build argument %s: %s", name, redactValues(err.Error(), []string{value}))
+			}
+			return xerrors.Errorf("invalid build argument %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// UnknownArguments returns the names of the arguments which the workspace does not declare, sorted by name.
+// Workspaces which don't declare their arguments accept all arguments.
+func (ws *Workspace) UnknownArguments(args Arguments) []string {
+	if len(ws.Arguments) == 0 {
+		return nil
+	}
+
+	var res []string
+	for name := range args {
+		if _, known := ws.Arguments[name]; !known {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// UnknownArgumentsError is returned by FindWorkspace if build arguments are passed which the workspace does not declare
+type UnknownArgumentsError struct {
+	Unknown []string
+	Known   []string
+}
+
+func (e *UnknownArgumentsError) Error() string {
+	return fmt.Sprintf("unknown build arguments %s: valid arguments are %s", strings.Join(e.Unknown, ", "), strings.Join(e.Known, ", "))
+}
+
+// checkUnknownArguments fails if args contains arguments the workspace does not declare
+func (ws *Workspace) checkUnknownArguments(args Arguments) error {
+	unknown := ws.UnknownArguments(args)
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	known := make([]string, 0, len(ws.Arguments))
+	for name := range ws.Arguments {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+	return &UnknownArgumentsError{Unknown: unknown, Known: known}
+}
+
+// Profile is a named set of command line flag defaults. Other than variants, profiles
+// do not influence the build graph but only how blazedock is invoked.
+type Profile struct {
+	Description string            `yaml:"description,omitempty"`
+	Flags       map[string]string `yaml:"flags,omitempty"`
+}
+
+// RemoteCacheConfig configures the remote cache of a workspace. Environment variables take precedence.
+type RemoteCacheConfig struct {
+	// Endpoint points the AWS remote cache to an S3-compatible service, e.g. MinIO or Ceph
+	Endpoint string `yaml:"endpoint,omitempty"`
+}
+
+// VetConfig configures blazedock vet for a workspace
+type VetConfig struct {
+	ExternalChecks []ExternalVetCheck `yaml:"externalChecks,omitempty"`
+	Licenses       LicensePolicy      `yaml:"licenses,omitempty"`
+	// GoVersion is the go directive all go.mod files of the workspace are expected to have, e.g. 1.22
+	GoVersion string `yaml:"goVersion,omitempty"`
+	// GoToolchain is the toolchain directive all go.mod files of the workspace are expected to have, e.g. go1.22.4
+	GoToolchain string `yaml:"goToolchain,omitempty"`
+}
+
+// LinkConfig configures blazedock link for a workspace
+type LinkConfig struct {
+	// AllowLocalReplaces makes linking accept replace directives which were written by hand, as long as they point
+	// to the directory of the module blazedock would link. Otherwise such replace directives are a conflict.
+	AllowLocalReplaces bool `yaml:"allowLocalReplaces,omitempty"`
+	// ExternalGoModules are directories of Go modules outside the workspace which LinkGoWorkspace adds to the go.work
+	// file. Relative paths are resolved against the workspace root.
+	ExternalGoModules []string `yaml:"externalGoModules,omitempty"`
+}
+
+// LicensePolicy determines which component licenses a package may depend on. Both maps are keyed by the SPDX
+// identifier of the depending package's license and list patterns (see path.Match) of dependency licenses.
+type LicensePolicy struct {
+	// Allow lists the only licenses dependencies may have. Licenses without an entry allow all dependency licenses.
+	Allow map[string][]string `yaml:"allow,omitempty"`
+	// Deny lists licenses dependencies must not have
+	Deny map[string][]string `yaml:"deny,omitempty"`
+}
+
+// ExternalVetCheck is a vet check implemented by an executable. See the vet package for the protocol it has to speak.
+type ExternalVetCheck struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	// Command is the executable and its arguments. Relative paths are resolved against the workspace root.
+	Command []string `yaml:"command"`
+	// Component makes this a component check. Otherwise the check runs on packages.
+	Component bool `yaml:"component,omitempty"`
+	// PackageType limits a package check to packages of this type. If empty, the check runs on all packages.
+	PackageType PackageType `yaml:"packageType,omitempty"`
 }
 
 func DiscoverWorkspaceRoot() (string, error) {
@@ -159,23 +381,35 @@ var defaultEnvManifestEntries = map[PackageType]EnvironmentManifest{
 		{Name: "yarn", Command: []string{"yarn", "-v"}},
 		{Name: "node", Command: []string{"node", "--version"}},
 	},
+	RustPackage: []EnvironmentManifestEntry{
+		{Name: "cargo", Command: []string{"cargo", "--version"}},
+		{Name: "rustc", Command: []string{"rustc", "--version"}},
+	},
+	PythonPackage: []EnvironmentManifestEntry{
+		{Name: "python", Command: []string{"python3", "--version"}},
+	},
 }
 
 // ShouldIgnoreComponent returns true if a file should be ignored for a component listing
 func (ws *Workspace) ShouldIgnoreComponent(path string) bool {
-	return ws.ShouldIgnoreSource(path)
+	if ws.ignoreComponent == nil {
+		return ws.ShouldIgnoreSource(path)
+	}
+	return ws.ignoreComponent(path)
 }
 
 // ShouldIgnoreSource returns true if a file should be ignored for a source listing
 func (ws *Workspace) ShouldIgnoreSource(path string) bool {
-	for _, ptn := range ws.ignores {
-		if strings.Contains(path, ptn) {
-			return true
-		}
+	if ws.ignoreSource == nil {
+		return false
 	}
-	return false
+	return ws.ignoreSource(path)
 }
 
+// defaultComponentIgnores are directories which are never searched for components, unless .blazedockignore
+// re-includes them, e.g. using !vendor/
+var defaultComponentIgnores = []string{".git/", "node_modules/", "vendor/"}
+
 // loadWorkspaceYAML loads a workspace's YAML file only - does not linking or processing of any kind.
 // Probably you want to use loadWorkspace instead.
 func loadWorkspaceYAML(path string) (Workspace, error) {
@@ -196,13 +430,86 @@ func loadWorkspaceYAML(path string) (Workspace, error) {
 	return workspace, nil
 }
 
+// LoadWorkspaceConfig reads the WORKSPACE.yaml at path. Other than FindWorkspace this does not load
+// any components, which makes it usable for configuration needed before the workspace proper is loaded.
+func LoadWorkspaceConfig(path string) (Workspace, error) {
+	return loadWorkspaceYAML(path)
+}
+
+// loadIgnores computes which files are ignored for source and component listings
+func (ws *Workspace) loadIgnores() error {
+	// .blazedockignore uses the gitignore syntax and applies to sources and components alike
+	var ignores []string
+	ignoresFile := filepath.Join(ws.Origin, ".blazedockignore")
+	if _, err := os.Stat(ignoresFile); !os.IsNotExist(err) {
+		fc, err := os.ReadFile(ignoresFile)
+		if err != nil {
+			return err
+		}
+		ignores = strings.Split(string(fc), "\n")
+	}
+	componentIgnores := append(append([]string{}, defaultComponentIgnores...), ignores...)
+	ws.ignoreComponent = doublestar.IgnorePatterns(ws.Origin, componentIgnores)
+
+	// nested workspaces are ignored altogether. Directories which are pruned for components are not searched for them.
+	otherWS, err := doublestar.Glob(ws.Origin, "**/WORKSPACE.yaml", ws.ShouldIgnoreComponent)
+	if err != nil {
+		return err
+	}
+	for _, ows := range otherWS {
+		dir := filepath.Dir(ows)
+		if dir == ws.Origin {
+			continue
+		}
+		rel, err := filepath.Rel(ws.Origin, dir)
+		if err != nil {
+			return err
+		}
+
+		ignores = append(ignores, "/"+filepath.ToSlash(rel)+"/")
+		componentIgnores = append(componentIgnores, "/"+filepath.ToSlash(rel)+"/")
+	}
+	ws.ignoreSource = doublestar.IgnorePatterns(ws.Origin, ignores)
+	ws.ignoreComponent = doublestar.IgnorePatterns(ws.Origin, componentIgnores)
+	log.WithField("ignores", ignores).WithField("componentIgnores", componentIgnores).Debug("computed workspace ignores")
+	return nil
+}
+
+// ComponentFiles returns the sorted paths of all BUILD.yaml files of the workspace at path, honouring the
+// .blazedockignore file and nested workspaces. Other than FindWorkspace this does not load any components.
+func ComponentFiles(path string) ([]string, error) {
+	ws, err := loadWorkspaceYAML(path)
+	if err != nil {
+		return nil, err
+	}
+	err = ws.loadIgnores()
+	if err != nil {
+		return nil, err
+	}
+	pths, err := doublestar.Glob(ws.Origin, "**/BUILD.yaml", ws.ShouldIgnoreComponent)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(pths))
+	for _, pth := range pths {
+		if ws.ShouldIgnoreComponent(pth) {
+			continue
+		}
+		res = append(res, pth)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
 type loadWorkspaceOpts struct {
 	PrelinkModifier   func(map[string]*Package)
 	ArgumentDefaults  map[string]string
 	ProvenanceKeyPath string
+	Variants          []string
+	AllowUnknownArgs  bool
 }
 
-func loadWorkspace(ctx context.Context, path string, args Arguments, variant string, opts *loadWorkspaceOpts) (Workspace, error) {
+func loadWorkspace(ctx context.Context, path string, args Arguments, variants []string, opts *loadWorkspaceOpts) (Workspace, error) {
 	ctx, task := trace.NewTask(ctx, "loadWorkspace")
 	defer task.End()
 
@@ -210,48 +517,30 @@ func loadWorkspace(ctx context.Context, path string, args Arguments, variant str
 	if err != nil {
 		return Workspace{}, err
 	}
+	workspace.envCacheSalt = os.Getenv(EnvvarCacheSalt)
 
-	if variant != "" {
-		for _, vnt := range workspace.Variants {
-			if vnt.Name == variant {
-				workspace.SelectedVariant = vnt
-				break
-			}
+	// the arguments are checked before the defaults are added, which the workspace need not declare
+	if !opts.AllowUnknownArgs {
+		err = workspace.checkUnknownArguments(args)
+		if err != nil {
+			return Workspace{}, err
 		}
-	} else if workspace.DefaultVariant != nil {
-		workspace.SelectedVariant = workspace.DefaultVariant
-		log.WithField("defaults", *workspace.SelectedVariant).Debug("applying default variant")
 	}
 
-	var ignores []string
-	ignoresFile := filepath.Join(workspace.Origin, ".blazedockignore")
-	if _, err := os.Stat(ignoresFile); !os.IsNotExist(err) {
-		fc, err := os.ReadFile(ignoresFile)
+	if len(variants) > 0 {
+		workspace.SelectedVariant, err = workspace.selectVariants(variants)
 		if err != nil {
 			return Workspace{}, err
 		}
-		split := strings.Split(string(fc), "\n")
-		for _, s := range split {
-			if s == "" {
-				continue
-			}
-			ignores = append(ignores, s)
-		}
+	} else if workspace.DefaultVariant != nil {
+		workspace.SelectedVariant = workspace.DefaultVariant
+		log.WithField("defaults", *workspace.SelectedVariant).Debug("applying default variant")
 	}
-	otherWS, err := doublestar.Glob(workspace.Origin, "**/WORKSPACE.yaml", workspace.ShouldIgnoreSource)
+
+	err = workspace.loadIgnores()
 	if err != nil {
 		return Workspace{}, err
 	}
-	for _, ows := range otherWS {
-		dir := filepath.Dir(ows)
-		if dir == workspace.Origin {
-			continue
-		}
-
-		ignores = append(ignores, dir)
-	}
-	workspace.ignores = ignores
-	log.WithField("ignores", workspace.ignores).Debug("computed workspace ignores")
 
 	if workspace.ArgumentDefaults == nil {
 		workspace.ArgumentDefaults = make(map[string]string)
@@ -260,7 +549,7 @@ func loadWorkspace(ctx context.Context, path string, args Arguments, variant str
 		for k, v := range opts.ArgumentDefaults {
 			workspace.ArgumentDefaults[k] = v
 		}
-		log.WithField("rootDefaultArgs", opts.ArgumentDefaults).Debug("installed root workspace defaults")
+		log.WithField("rootDefaultArgs", workspace.redactArguments(opts.ArgumentDefaults)).Debug("installed root workspace defaults")
 	}
 
 	defaultArgsFN := filepath.Join(path, "WORKSPACE.args.yaml")
@@ -273,14 +562,22 @@ func loadWorkspace(ctx context.Context, path string, args Arguments, variant str
 		for k, v := range defargs {
 			workspace.ArgumentDefaults[k] = v
 		}
-		log.WithField("content", defargs).WithField("filename", defaultArgsFN).Debug("applied workspace default args file")
+		log.WithField("content", workspace.redactArguments(defargs)).WithField("filename", defaultArgsFN).Debug("applied workspace default args file")
 	} else if os.IsNotExist(err) {
 		// ignore
 	} else {
 		return Workspace{}, xerrors.Errorf("cannot read %s: %w", defaultArgsFN, err)
 	}
 
-	log.WithField("defaultArgs", workspace.ArgumentDefaults).Debug("applying workspace defaults")
+	if vnt := workspace.SelectedVariant; vnt != nil && len(vnt.ArgumentDefaults) > 0 {
+		// the selected variant overrides the workspace defaults, but not the arguments passed in explicitly
+		for k, v := range vnt.ArgumentDefaults {
+			workspace.ArgumentDefaults[k] = v
+		}
+		log.WithField("variant", vnt.Name).WithField("defaultArgs", workspace.redactArguments(vnt.ArgumentDefaults)).Debug("applied variant default args")
+	}
+
+	log.WithField("defaultArgs", workspace.redactArguments(workspace.ArgumentDefaults)).Debug("applying workspace defaults")
 	for key, val := range workspace.ArgumentDefaults {
 		if args == nil {
 			args = make(map[string]string)
@@ -294,6 +591,18 @@ func loadWorkspace(ctx context.Context, path string, args Arguments, variant str
 		args[key] = val
 	}
 
+	if len(workspace.Arguments) > 0 {
+		if args == nil {
+			args = make(map[string]string)
+		}
+		err = workspace.checkArguments(args)
+		if err != nil {
+			return Workspace{}, err
+		}
+	}
+
+	workspace.BuildArguments = args
+
 	comps, err := discoverComponents(ctx, &workspace, args, workspace.SelectedVariant, opts)
 	if err != nil {
 		return workspace, err
@@ -373,11 +682,37 @@ func loadWorkspace(ctx context.Context, path string, args Arguments, variant str
 	// if the workspace has provenance enabled and a keypath specified (or the loadOpts specify one),
 	// try and load the key
 	if workspace.Provenance.Enabled {
-		if opts.ProvenanceKeyPath != "" {
-			workspace.Provenance.KeyPath = opts.ProvenanceKeyPath
+		switch workspace.Provenance.SLSAVersion {
+		case "", SLSAVersion02, SLSAVersion1:
+		default:
+			return workspace, xerrors.Errorf("unsupported provenance slsaVersion %q - valid values are %s and %s", workspace.Provenance.SLSAVersion, SLSAVersion02, SLSAVersion1)
+		}
+
+		switch workspace.Provenance.SigningMode {
+		case "", ProvenanceSigningKey:
+		case ProvenanceSigningKeyless:
+			workspace.Provenance.signer = sigstore.NewKeylessSigner(workspace.Provenance.FulcioURL, sigstore.TokenFromEnvironment)
+			workspace.Provenance.signer.Rekor = sigstore.NewRekor(workspace.Provenance.RekorURL)
+		default:
+			return workspace, xerrors.Errorf("unsupported provenance signingMode %q - valid values are %s and %s", workspace.Provenance.SigningMode, ProvenanceSigningKey, ProvenanceSigningKeyless)
+		}
+
+		if kms.IsKeyURI(opts.ProvenanceKeyPath) {
+			workspace.Provenance.KeyPath, workspace.Provenance.KeyURI = "", opts.ProvenanceKeyPath
+		} else if opts.ProvenanceKeyPath != "" {
+			workspace.Provenance.KeyPath, workspace.Provenance.KeyURI = opts.ProvenanceKeyPath, ""
+		}
+		if workspace.Provenance.KeyPath != "" && workspace.Provenance.KeyURI != "" {
+			return workspace, xerrors.Errorf("provenance key and keyURI are mutually exclusive")
+		}
+		if uri := workspace.Provenance.KeyURI; uri != "" && workspace.Provenance.signer == nil {
+			workspace.Provenance.kmsSigner, err = kms.NewSigner(uri)
+			if err != nil {
+				return workspace, xerrors.Errorf("cannot use workspace provenance signature key: %w", err)
+			}
 		}
 		fn := workspace.Provenance.KeyPath
-		if fn != "" {
+		if fn != "" && workspace.Provenance.signer == nil {
 			var key in_toto.Key
 			err = key.LoadKeyDefaults(fn)
 			if err != nil {
@@ -439,64 +774,154 @@ func buildEnvironmentManifest(entries EnvironmentManifest, pkgtpes map[PackageTy
 	return
 }
 
+// WorkspaceOption configures how FindWorkspace loads a workspace
+type WorkspaceOption func(*loadWorkspaceOpts)
+
+// WithVariants selects additional variants. They're merged with the variant passed to FindWorkspace
+// (see selectVariants).
+func WithVariants(names ...string) WorkspaceOption {
+	return func(opts *loadWorkspaceOpts) {
+		opts.Variants = append(opts.Variants, names...)
+	}
+}
+
+// WithAllowUnknownArguments accepts build arguments the workspace does not declare. By default they're an error
+// (see UnknownArgumentsError).
+func WithAllowUnknownArguments(allow bool) WorkspaceOption {
+	return func(opts *loadWorkspaceOpts) {
+		opts.AllowUnknownArgs = allow
+	}
+}
+
 // FindWorkspace looks for a WORKSPACE.yaml file within the path. If multiple such files are found,
 // an error is returned.
-func FindWorkspace(path string, args Arguments, variant, provenanceKey string) (Workspace, error) {
-	return loadWorkspace(context.Background(), path, args, variant, &loadWorkspaceOpts{ProvenanceKeyPath: provenanceKey})
+func FindWorkspace(path string, args Arguments, variant, provenanceKey string, options ...WorkspaceOption) (Workspace, error) {
+	opts := &loadWorkspaceOpts{ProvenanceKeyPath: provenanceKey}
+	for _, o := range options {
+		o(opts)
+	}
+
+	var variants []string
+	if variant != "" {
+		variants = append(variants, variant)
+	}
+	variants = append(variants, opts.Variants...)
+	return loadWorkspace(context.Background(), path, args, variants, opts)
+}
+
+// selectVariants finds the named variants and merges them in the order they're declared in the workspace,
+// regardless of the order they were given in. It fails if a name does not match any variant, or
+// if two of the variants are declared mutually exclusive.
+func (ws *Workspace) selectVariants(names []string) (*PackageVariant, error) {
+	requested := make(map[string]bool, len(names))
+	for _, name := range names {
+		requested[name] = true
+	}
+
+	var (
+		selected []*PackageVariant
+		found    = make(map[string]bool, len(names))
+	)
+	for _, vnt := range ws.Variants {
+		if requested[vnt.Name] && !found[vnt.Name] {
+			selected = append(selected, vnt)
+			found[vnt.Name] = true
+		}
+	}
+	for _, name := range names {
+		if found[name] {
+			continue
+		}
+		if len(ws.Variants) == 0 {
+			return nil, xerrors.Errorf("unknown variant \"%s\": the workspace does not define any variants", name)
+		}
+		valid := make([]string, len(ws.Variants))
+		for i, vnt := range ws.Variants {
+			valid[i] = vnt.Name
+		}
+		return nil, xerrors.Errorf("unknown variant \"%s\": valid variants are %s", name, strings.Join(valid, ", "))
+	}
+
+	for _, group := range ws.ExclusiveVariants {
+		var conflicting []string
+		for _, name := range group {
+			if found[name] {
+				conflicting = append(conflicting, name)
+			}
+		}
+		if len(conflicting) > 1 {
+			return nil, xerrors.Errorf("variants %s are mutually exclusive and cannot be selected together", strings.Join(conflicting, ", "))
+		}
+	}
+
+	return mergeVariants(selected)
 }
 
+// maxConcurrentComponentLoads limits the number of components which are loaded concurrently. Loading a component
+// is partly IO bound, hence we use more goroutines than there are CPUs.
+var maxConcurrentComponentLoads = 2 * runtime.GOMAXPROCS(0)
+
 // discoverComponents discovers components in a workspace
 func discoverComponents(ctx context.Context, workspace *Workspace, args Arguments, variant *PackageVariant, opts *loadWorkspaceOpts) ([]*Component, error) {
 	defer trace.StartRegion(context.Background(), "discoverComponents").End()
 
 	path := workspace.Origin
-	pths, err := doublestar.Glob(path, "**/BUILD.yaml", workspace.ShouldIgnoreSource)
+	pths, err := doublestar.Glob(path, "**/BUILD.yaml", workspace.ShouldIgnoreComponent)
 	if err != nil {
 		return nil, err
 	}
+	sort.Strings(pths)
 
-	eg, ctx := errgroup.WithContext(ctx)
-	cchan := make(chan *Component, 20)
-
-	for _, pth := range pths {
+	// Components are loaded concurrently, but collected in the order of their paths s.t. loading them is deterministic.
+	// Loading one component must not stop the others, so that all broken components can be reported at once.
+	var (
+		loaded = make([]*Component, len(pths))
+		errs   = make([]error, len(pths))
+		eg     errgroup.Group
+	)
+	eg.SetLimit(maxConcurrentComponentLoads)
+	for i, pth := range pths {
 		if workspace.ShouldIgnoreComponent(pth) {
 			continue
 		}
 
-		pth := pth
 		eg.Go(func() error {
 			comp, err := loadComponent(ctx, workspace, pth, args, variant)
 			if err != nil {
-				return err
+				errs[i] = err
+				return nil
 			}
-			cchan <- &comp
+			loaded[i] = &comp
 			return nil
 		})
 	}
-	var (
-		comps []*Component
-		wg    sync.WaitGroup
-	)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-
-		for c := range cchan {
-			// filter variant-excluded components and all their packages
-			if filterExcludedComponents(variant, c) {
-				continue
-			}
+	_ = eg.Wait()
 
-			comps = append(comps, c)
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
 		}
-	}()
-	err = eg.Wait()
-	close(cchan)
-	if err != nil {
-		return nil, err
 	}
-	wg.Wait()
+	if len(failed) == 1 {
+		return nil, failed[0]
+	}
+	if len(failed) > 1 {
+		return nil, xerrors.Errorf("cannot load %d components:\n%w", len(failed), errors.Join(failed...))
+	}
 
+	comps := make([]*Component, 0, len(loaded))
+	for _, c := range loaded {
+		if c == nil {
+			continue
+		}
+		// filter variant-excluded components and all their packages
+		if filterExcludedComponents(variant, c) {
+			continue
+		}
+
+		comps = append(comps, c)
+	}
 	return comps, nil
 }
 
@@ -630,7 +1055,7 @@ func loadComponent(ctx context.Context, workspace *Workspace, path string, args
 	for i, pkg := range comp.Packages {
 		pkg.C = &comp
 
-		pkg.Definition, err = yaml.Marshal(&rawcomp.Packages[i])
+		pkg.Definition, err = yaml.Marshal(withoutVersionIrrelevantFields(&rawcomp.Packages[i]))
 		if err != nil {
 			return comp, xerrors.Errorf("%s: %w", comp.Name, err)
 		}
@@ -678,6 +1103,16 @@ func loadComponent(ctx context.Context, workspace *Workspace, path string, args
 			}
 			log.WithField("pkg", pkg.Name).WithField("variant", variant).WithField("excl", excl).WithField("incl", incl).WithField("package", pkg.FullName()).Debug("applying variant")
 		}
+		if len(pkg.ExcludeSources) > 0 {
+			// excluded files neither end up in the version nor in the build dir or provenance
+			excl, err := resolveSources(pkg.C.W, pkg.C.Origin, pkg.ExcludeSources, false)
+			if err != nil {
+				return comp, xerrors.Errorf("%s: %w", comp.Name, err)
+			}
+			for _, src := range excl {
+				delete(completeSources, src)
+			}
+		}
 		pkg.Sources = make([]string, len(completeSources))
 		i := 0
 		for src := range completeSources {
@@ -697,6 +1132,18 @@ func loadComponent(ctx context.Context, workspace *Workspace, path string, args
 			pkg.ArgumentDependencies[i] = fmt.Sprintf("%s: %s", argdep, val)
 		}
 
+		// conditional dependencies are resolved here, s.t. the dependency graph and hence the versions reflect them
+		for _, cdep := range pkg.ConditionalDeps {
+			applies, err := cdep.applies(pkg.C.W, pkg.C.Constants, args)
+			if err != nil {
+				return comp, xerrors.Errorf("%s: conditional dependency %s: %w", pkg.FullName(), cdep.Dependency, err)
+			}
+			if !applies {
+				continue
+			}
+			pkg.Dependencies = append(pkg.Dependencies, cdep.Dependency)
+		}
+
 		// make all dependencies fully qualified
 		for idx, dep := range pkg.Dependencies {
 			if !strings.HasPrefix(dep, ":") {
@@ -705,18 +1152,20 @@ func loadComponent(ctx context.Context, workspace *Workspace, path string, args
 
 			pkg.Dependencies[idx] = comp.Name + dep
 		}
-		// make all layout entries full qualified
-		if pkg.Layout == nil {
-			pkg.Layout = make(map[string]string)
-		}
+		// make all layout entries full qualified and relative to the build dir
+		layout := make(map[string]string, len(pkg.Layout))
 		for dep, loc := range pkg.Layout {
-			if !strings.HasPrefix(dep, ":") {
-				continue
+			if strings.HasPrefix(dep, ":") {
+				dep = comp.Name + dep
 			}
 
-			delete(pkg.Layout, dep)
-			pkg.Layout[comp.Name+dep] = loc
+			loc, err := sanitizeLayoutLocation(loc)
+			if err != nil {
+				return comp, xerrors.Errorf("%s: layout of %s: %w", pkg.FullName(), dep, err)
+			}
+			layout[dep] = loc
 		}
+		pkg.Layout = layout
 
 		// apply variant config
 		if vnt := pkg.C.W.SelectedVariant; vnt != nil {
@@ -758,6 +1207,172 @@ func loadComponent(ctx context.Context, workspace *Workspace, path string, args
 	return comp, nil
 }
 
+// applies evaluates the condition of the dependency. Build arguments which are not set never have a value,
+// i.e. "name=value" and "name" are false for them and their negations are true.
+func (d ConditionalDependency) applies(ws *Workspace, consts map[string]string, args Arguments) (bool, error) {
+	if d.Dependency == "" {
+		return false, xerrors.Errorf("dep is missing")
+	}
+	if d.Variant == "" && d.Arg == "" {
+		return false, xerrors.Errorf("needs a variant or arg condition")
+	}
+
+	if d.Variant != "" {
+		name, negate := strings.CutPrefix(d.Variant, "!")
+		if ws.isVariantSelected(name) == negate {
+			return false, nil
+		}
+	}
+	if d.Arg != "" {
+		cond, negate := strings.CutPrefix(d.Arg, "!")
+		name, expected, hasValue := strings.Cut(cond, "=")
+		val, ok := consts[name]
+		if !ok {
+			val, ok = args[name]
+		}
+		matches := ok && val != ""
+		if hasValue {
+			matches = ok && val == expected
+		}
+		if matches == negate {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// isVariantSelected returns true if the variant is one of the variants selected when the workspace was loaded
+func (ws *Workspace) isVariantSelected(name string) bool {
+	if ws.SelectedVariant == nil {
+		return false
+	}
+	for _, n := range strings.Split(ws.SelectedVariant.Name, "+") {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// versionIrrelevantFields are package fields which don't influence the build result. They are not part of the
+// package definition and hence changing them does not change the package version.
+var versionIrrelevantFields = map[string]struct{}{
+	"postBuild":       {},
+	"postBuildAlways": {},
+	"timeout":         {},
+	"retries":         {},
+	"layoutAssembly":  {},
+}
+
+// withoutVersionIrrelevantFields returns a copy of a package definition node without the versionIrrelevantFields.
+// Nodes which don't contain any of those fields are returned as is, s.t. their marshalled form remains unchanged.
+func withoutVersionIrrelevantFields(nd *yaml.Node) *yaml.Node {
+	if nd.Kind == yaml.DocumentNode && len(nd.Content) == 1 {
+		res := *nd
+		res.Content = []*yaml.Node{withoutVersionIrrelevantFields(nd.Content[0])}
+		return &res
+	}
+	if nd.Kind != yaml.MappingNode {
+		return nd
+	}
+
+	var (
+		content = make([]*yaml.Node, 0, len(nd.Content))
+		found   bool
+	)
+	for i := 0; i+1 < len(nd.Content); i += 2 {
+		if _, irrelevant := versionIrrelevantFields[nd.Content[i].Value]; irrelevant {
+			found = true
+			continue
+		}
+		content = append(content, nd.Content[i], nd.Content[i+1])
+	}
+	if !found {
+		return nd
+	}
+
+	res := *nd
+	res.Content = content
+	return &res
+}
+
+// mergeVariants combines variants into a single one. Sources, excluded components and environment variables
+// accumulate, whereas config fields and default args set by more than one variant take the value of the last variant.
+// Environment variables set by more than one variant behave the same way, since mergeEnv applies them in order.
+func mergeVariants(vnts []*PackageVariant) (*PackageVariant, error) {
+	if len(vnts) == 1 {
+		return vnts[0], nil
+	}
+
+	var (
+		res   = &PackageVariant{config: make(map[PackageType]PackageConfig)}
+		names = make([]string, 0, len(vnts))
+	)
+	for _, vnt := range vnts {
+		names = append(names, vnt.Name)
+		res.Sources.Include = append(res.Sources.Include, vnt.Sources.Include...)
+		res.Sources.Exclude = append(res.Sources.Exclude, vnt.Sources.Exclude...)
+		res.Components.Exclude = append(res.Components.Exclude, vnt.Components.Exclude...)
+		res.Environment = append(res.Environment, vnt.Environment...)
+		for k, v := range vnt.ArgumentDefaults {
+			if res.ArgumentDefaults == nil {
+				res.ArgumentDefaults = make(map[string]string)
+			}
+			res.ArgumentDefaults[k] = v
+		}
+
+		// Config is merged field by field on the YAML level, s.t. a later variant can set a field back to its zero value
+		for t, node := range vnt.RawConfig {
+			if res.RawConfig == nil {
+				res.RawConfig = make(map[PackageType]yaml.Node)
+			}
+			prev, ok := res.RawConfig[t]
+			if !ok {
+				res.RawConfig[t] = node
+				continue
+			}
+			res.RawConfig[t] = mergeYAMLNodes(prev, node)
+		}
+	}
+	var err error
+	res.config, err = unmarshalVariantConfig(res.RawConfig)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot merge the config of variants %s: %w", strings.Join(names, ", "), err)
+	}
+	res.Name = strings.Join(names, "+")
+
+	return res, nil
+}
+
+// mergeYAMLNodes merges the mapping src into dst, where the values of src take precedence. Nested mappings are
+// merged recursively, all other values are replaced. Neither dst nor src are modified.
+func mergeYAMLNodes(dst, src yaml.Node) yaml.Node {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return src
+	}
+
+	res := dst
+	res.Content = append([]*yaml.Node{}, dst.Content...)
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, val := src.Content[i], src.Content[i+1]
+
+		var found bool
+		for j := 0; j+1 < len(res.Content); j += 2 {
+			if res.Content[j].Value != key.Value {
+				continue
+			}
+			merged := mergeYAMLNodes(*res.Content[j+1], *val)
+			res.Content[j+1] = &merged
+			found = true
+			break
+		}
+		if !found {
+			res.Content = append(res.Content, key, val)
+		}
+	}
+	return res
+}
+
 func mergeConfig(pkg *Package, src PackageConfig) error {
 	if src == nil {
 		return nil
@@ -808,6 +1423,28 @@ func mergeConfig(pkg *Package, src PackageConfig) error {
 			return err
 		}
 		pkg.Config = dst
+	case RustPkgConfig:
+		dst := pkg.Config.(RustPkgConfig)
+		in, ok := src.(RustPkgConfig)
+		if !ok {
+			return xerrors.Errorf("cannot merge %s onto %s", reflect.TypeOf(src).String(), reflect.TypeOf(dst).String())
+		}
+		err := mergo.Merge(&dst, in)
+		if err != nil {
+			return err
+		}
+		pkg.Config = dst
+	case PythonPkgConfig:
+		dst := pkg.Config.(PythonPkgConfig)
+		in, ok := src.(PythonPkgConfig)
+		if !ok {
+			return xerrors.Errorf("cannot merge %s onto %s", reflect.TypeOf(src).String(), reflect.TypeOf(dst).String())
+		}
+		err := mergo.Merge(&dst, in)
+		if err != nil {
+			return err
+		}
+		pkg.Config = dst
 	default:
 		return xerrors.Errorf("unknown config type %s", reflect.ValueOf(pkg.Config).Elem().Type().String())
 	}
@@ -12,6 +12,75 @@ import (
 	"github.com/khulnasoft/blazedock/pkg/testutil"
 )
 
+const workspaceVariants = `variants:
+- name: nogo
+  srcs:
+    exclude:
+    - "**/*.go"
+  config:
+    go:
+      buildFlags:
+      - -tags foo
+`
+
+const workspaceDefaultArgs = `defaultArgs:
+  registry: registry.example.com
+variants:
+- name: staging
+  defaultArgs:
+    registry: staging.example.com
+`
+
+const workspaceDeclaredArgs = `args:
+  registry:
+    description: the registry images are pushed to
+    default: registry.example.com
+`
+
+const buildYAMLRegistryPkg = "packages:\n- name: pkg\n  type: generic\n  config:\n    commands:\n    - [\"echo\", \"${registry}\"]\n"
+
+const buildYAMLGenericPkg = "packages:\n- name: pkg\n  type: generic\n"
+
+const buildYAMLConditionalDeps = `packages:
+- name: pkg
+  type: generic
+  conditionalDeps:
+  - dep: :crypto-fips
+    variant: fips
+  - dep: :crypto
+    variant: "!fips"
+  - dep: :debug
+    arg: debug=true
+- name: crypto
+  type: generic
+- name: crypto-fips
+  type: generic
+- name: debug
+  type: generic
+`
+
+// expectDependencies checks the dependencies printed by "describe package"
+func expectDependencies(expected ...string) func(t *testing.T, stdout, stderr string) {
+	return func(t *testing.T, stdout, stderr string) {
+		// the output is aligned using spaces, hence the dependencies are told apart by their fields
+		printed := make(map[string]struct{})
+		for _, l := range strings.Split(stdout, "\n") {
+			if segs := strings.Fields(l); len(segs) > 0 {
+				printed[segs[0]] = struct{}{}
+			}
+		}
+		var act []string
+		for _, dep := range []string{"app:crypto", "app:crypto-fips", "app:debug"} {
+			if _, ok := printed[dep]; ok {
+				act = append(act, dep)
+			}
+		}
+		if strings.Join(act, ",") != strings.Join(expected, ",") {
+			t.Errorf("expected dependencies %v, got %v:\n%s", expected, act, stdout)
+		}
+	}
+}
+
 func TestFixtureLoadWorkspace(t *testing.T) {
 	testutil.RunDUT()
 
@@ -66,6 +135,152 @@ func TestFixtureLoadWorkspace(t *testing.T) {
 				},
 			},
 		},
+		{
+			Name:              "variant default args",
+			T:                 t,
+			Args:              []string{"describe", "comp:pkg", "--variant", "staging"},
+			NoNestedWorkspace: true,
+			ExitCode:          0,
+			StdoutSubs:        []string{"staging.example.com"},
+			Fixture: &testutil.Setup{
+				Files: map[string]string{
+					"WORKSPACE.yaml":  workspaceDefaultArgs,
+					"comp/BUILD.yaml": buildYAMLRegistryPkg,
+				},
+			},
+		},
+		{
+			Name:              "command line args override variant default args",
+			T:                 t,
+			Args:              []string{"describe", "comp:pkg", "--variant", "staging", "-Dregistry=cli.example.com"},
+			NoNestedWorkspace: true,
+			ExitCode:          0,
+			StdoutSubs:        []string{"cli.example.com"},
+			Fixture: &testutil.Setup{
+				Files: map[string]string{
+					"WORKSPACE.yaml":  workspaceDefaultArgs,
+					"comp/BUILD.yaml": buildYAMLRegistryPkg,
+				},
+			},
+		},
+		{
+			Name:              "unknown build argument",
+			T:                 t,
+			Args:              []string{"describe", "comp:pkg", "-Dregsitry=cli.example.com"},
+			NoNestedWorkspace: true,
+			ExitCode:          1,
+			StderrSub:         "unknown build arguments regsitry: valid arguments are registry",
+			Fixture: &testutil.Setup{
+				Files: map[string]string{
+					"WORKSPACE.yaml":  workspaceDeclaredArgs,
+					"comp/BUILD.yaml": buildYAMLRegistryPkg,
+				},
+			},
+		},
+		{
+			Name:              "allow unknown build argument",
+			T:                 t,
+			Args:              []string{"describe", "comp:pkg", "--allow-unknown-args", "-Dregsitry=cli.example.com"},
+			NoNestedWorkspace: true,
+			ExitCode:          0,
+			StdoutSubs:        []string{"registry.example.com"},
+			Fixture: &testutil.Setup{
+				Files: map[string]string{
+					"WORKSPACE.yaml":  workspaceDeclaredArgs,
+					"comp/BUILD.yaml": buildYAMLRegistryPkg,
+				},
+			},
+		},
+		{
+			Name:              "workspace profiles",
+			T:                 t,
+			Args:              []string{"describe", "profiles"},
+			NoNestedWorkspace: true,
+			ExitCode:          0,
+			StdoutSubs:        []string{"release:", "--cache=local"},
+			Fixture: &testutil.Setup{
+				Workspace: blazedock.Workspace{
+					Profiles: map[string]blazedock.Profile{
+						"release": {
+							Description: "release builds",
+							Flags:       map[string]string{"cache": "local"},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:              "workspace variants",
+			T:                 t,
+			Args:              []string{"describe", "variants"},
+			NoNestedWorkspace: true,
+			ExitCode:          0,
+			StdoutSubs:        []string{"nogo:\n", "-src **/*.go", "go.buildFlags: [-tags foo]"},
+			Fixture: &testutil.Setup{
+				Files: map[string]string{"WORKSPACE.yaml": workspaceVariants},
+			},
+		},
+		{
+			Name:              "unknown variant",
+			T:                 t,
+			Args:              []string{"collect", "--variant", "nogi"},
+			NoNestedWorkspace: true,
+			ExitCode:          1,
+			StderrSub:         `unknown variant \"nogi\": valid variants are nogo`,
+			Fixture: &testutil.Setup{
+				Files: map[string]string{"WORKSPACE.yaml": workspaceVariants},
+			},
+		},
+		{
+			Name:              "conditional dependencies",
+			T:                 t,
+			Args:              []string{"describe", "package", "app:pkg"},
+			NoNestedWorkspace: true,
+			ExitCode:          0,
+			Eval:              expectDependencies("app:crypto"),
+			Fixture: &testutil.Setup{
+				Files: map[string]string{
+					"WORKSPACE.yaml": "variants:\n- name: fips\n",
+					"app/BUILD.yaml": buildYAMLConditionalDeps,
+				},
+			},
+		},
+		{
+			Name:              "conditional dependencies with variant and arg",
+			T:                 t,
+			Args:              []string{"describe", "package", "app:pkg", "--variant", "fips", "-Ddebug=true"},
+			NoNestedWorkspace: true,
+			ExitCode:          0,
+			Eval:              expectDependencies("app:crypto-fips", "app:debug"),
+			Fixture: &testutil.Setup{
+				Files: map[string]string{
+					"WORKSPACE.yaml": "variants:\n- name: fips\n",
+					"app/BUILD.yaml": buildYAMLConditionalDeps,
+				},
+			},
+		},
+		{
+			Name:              "ignored component directories",
+			T:                 t,
+			Args:              []string{"collect", "components"},
+			NoNestedWorkspace: true,
+			ExitCode:          0,
+			Eval: func(t *testing.T, stdout, stderr string) {
+				// node_modules is ignored by default, generated through .blazedockignore which also re-includes vendor
+				if act := strings.TrimSpace(stdout); act != "app\nvendor/lib" {
+					t.Errorf("unexpected components: %q", act)
+				}
+			},
+			Fixture: &testutil.Setup{
+				Files: map[string]string{
+					".blazedockignore":            "# generated code is not part of the build\ngenerated/\n!vendor/\n",
+					"app/BUILD.yaml":              buildYAMLGenericPkg,
+					"generated/BUILD.yaml":        buildYAMLGenericPkg,
+					"node_modules/dep/BUILD.yaml": buildYAMLGenericPkg,
+					"vendor/lib/BUILD.yaml":       buildYAMLGenericPkg,
+				},
+			},
+		},
 		{
 			Name: "environment manifest",
 			T:    t,
@@ -0,0 +1,97 @@
+package doublestar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type ignorePattern struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// IgnorePatterns ignores all paths below base which match the patterns. Patterns follow the gitignore syntax:
+// blank lines and lines starting with # are skipped, a leading ! re-includes what an earlier pattern ignored,
+// a trailing / matches directories only and patterns containing a / are anchored at base. Other patterns match
+// at any depth. Everything below an ignored directory is ignored as well.
+func IgnorePatterns(base string, patterns []string) IgnoreFunc {
+	var ptns []ignorePattern
+	for _, p := range patterns {
+		p = strings.TrimRight(p, " \t\r")
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+
+		var ptn ignorePattern
+		if strings.HasPrefix(p, "!") {
+			ptn.negate = true
+			p = p[1:]
+		} else if strings.HasPrefix(p, `\#`) || strings.HasPrefix(p, `\!`) {
+			p = p[1:]
+		}
+		if strings.HasSuffix(p, "/") {
+			ptn.dirOnly = true
+			p = strings.TrimSuffix(p, "/")
+		}
+		if strings.Contains(p, "/") {
+			p = strings.TrimPrefix(p, "/")
+		} else {
+			p = "**/" + p
+		}
+		if p == "" || p == "**/" {
+			continue
+		}
+		ptn.pattern = p
+		ptns = append(ptns, ptn)
+	}
+	if len(ptns) == 0 {
+		return IgnoreNone
+	}
+
+	// ignored reports if a path relative to base is ignored by the last pattern matching it
+	ignored := func(rel string, isDir func() bool) bool {
+		var res bool
+		for _, ptn := range ptns {
+			if ptn.negate != res {
+				// this pattern can't change the outcome
+				continue
+			}
+			if m, _ := Match(ptn.pattern, rel); !m {
+				continue
+			}
+			if ptn.dirOnly && !isDir() {
+				continue
+			}
+			res = !ptn.negate
+		}
+		return res
+	}
+
+	return func(path string) bool {
+		rel, err := filepath.Rel(base, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+			return false
+		}
+		rel = filepath.ToSlash(rel)
+
+		// like Git, we don't look at the contents of ignored directories, hence nothing below them can be re-included
+		segs := strings.Split(rel, "/")
+		for i := 1; i < len(segs); i++ {
+			if ignored(strings.Join(segs[:i], "/"), func() bool { return true }) {
+				return true
+			}
+		}
+
+		var isDir *bool
+		return ignored(rel, func() bool {
+			if isDir == nil {
+				stat, err := os.Stat(path)
+				dir := err == nil && stat.IsDir()
+				isDir = &dir
+			}
+			return *isDir
+		})
+	}
+}
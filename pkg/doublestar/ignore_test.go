@@ -0,0 +1,60 @@
+package doublestar_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/khulnasoft/blazedock/pkg/doublestar"
+)
+
+func TestIgnorePatterns(t *testing.T) {
+	base := t.TempDir()
+	for _, dir := range []string{"build", "src/build"} {
+		err := os.MkdirAll(filepath.Join(base, dir), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.WriteFile(filepath.Join(base, "src", "build.log"), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name     string
+		Patterns []string
+		Path     string
+		Ignored  bool
+	}{
+		{"no patterns", nil, "node_modules/foo", false},
+		{"comments and blank lines", []string{"# node_modules", "", "  "}, "node_modules/foo", false},
+		{"name at top level", []string{"node_modules"}, "node_modules", true},
+		{"name at any depth", []string{"node_modules"}, "a/b/node_modules", true},
+		{"below ignored dir", []string{"node_modules"}, "a/node_modules/b/BUILD.yaml", true},
+		{"name is not a substring", []string{"node_modules"}, "my_node_modules/BUILD.yaml", false},
+		{"anchored", []string{"/vendor"}, "vendor/BUILD.yaml", true},
+		{"anchored not at depth", []string{"/vendor"}, "a/vendor/BUILD.yaml", false},
+		{"pattern with slash is anchored", []string{"a/vendor"}, "b/a/vendor", false},
+		{"glob", []string{"*.log"}, "src/build.log", true},
+		{"double star", []string{"docs/**/*.md"}, "docs/a/b/c.md", true},
+		{"dir only matches dir", []string{"build/"}, "src/build", true},
+		{"dir only does not match file", []string{"build.log/"}, "src/build.log", false},
+		{"dir only parent", []string{"build/"}, "build/out.txt", true},
+		{"negation", []string{"vendor", "!vendor"}, "vendor/BUILD.yaml", false},
+		{"negation of file", []string{"*.log", "!build.log"}, "src/build.log", false},
+		{"negation order", []string{"!vendor", "vendor"}, "vendor", true},
+		{"no re-include below ignored dir", []string{"build", "!build/keep"}, "build/keep", true},
+		{"escaped hash", []string{`\#foo`}, "#foo", true},
+		{"outside of base", []string{"**"}, "../foo", false},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			ignore := doublestar.IgnorePatterns(base, test.Patterns)
+			act := ignore(filepath.Join(base, test.Path))
+			if act != test.Ignored {
+				t.Errorf("IgnorePatterns(%q)(%s) = %v, expected %v", test.Patterns, test.Path, act, test.Ignored)
+			}
+		})
+	}
+}
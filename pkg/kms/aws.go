@@ -0,0 +1,193 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// awsSigningAlgorithms are the signing algorithms over SHA-256 digests we can verify, in order of preference
+var awsSigningAlgorithms = []string{"ECDSA_SHA_256", "RSASSA_PKCS1_V1_5_SHA_256", "RSASSA_PSS_SHA_256"}
+
+// awsAccessDeniedErrors are the error types AWS reports for missing or insufficient credentials
+var awsAccessDeniedErrors = []string{"AccessDeniedException", "UnrecognizedClientException", "InvalidSignatureException", "ExpiredTokenException", "IncompleteSignature", "MissingAuthenticationToken"}
+
+// CredentialsSource provides AWS credentials
+type CredentialsSource func(ctx context.Context) (aws.Credentials, error)
+
+// awsKey is an asymmetric key in AWS KMS
+type awsKey struct {
+	ARN         string
+	Region      string
+	Endpoint    string
+	Credentials CredentialsSource
+	Client      *http.Client
+
+	mu        sync.Mutex
+	algorithm string
+	pub       []byte
+}
+
+func newAWSKey(ref string) (*awsKey, error) {
+	host, arn, ok := strings.Cut(ref, "/")
+	if !ok || arn == "" {
+		return nil, fmt.Errorf("expected awskms:///<key ARN> or awskms://<endpoint>/<key ARN>")
+	}
+	// arn:aws:kms:<region>:<account>:key/<id> or arn:aws:kms:<region>:<account>:alias/<name>
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "kms" || parts[3] == "" {
+		return nil, fmt.Errorf("%s is not the ARN of a KMS key or alias", arn)
+	}
+	region := parts[3]
+
+	endpoint := "https://kms." + region + ".amazonaws.com"
+	if host != "" {
+		endpoint = "https://" + host
+	}
+	return &awsKey{
+		ARN:         arn,
+		Region:      region,
+		Endpoint:    endpoint,
+		Credentials: awsCredentialsFromEnvironment(region),
+		Client:      &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (k *awsKey) uri() string {
+	return SchemeAWS + "/" + k.ARN
+}
+
+func (k *awsKey) signDigest(ctx context.Context, digest []byte) ([]byte, error) {
+	algorithm, _, err := k.describe(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Signature string `json:"Signature"`
+	}
+	err = k.do(ctx, "Sign", map[string]string{
+		"KeyId":            k.ARN,
+		"Message":          base64.StdEncoding.EncodeToString(digest),
+		"MessageType":      "DIGEST",
+		"SigningAlgorithm": algorithm,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Signature)
+}
+
+func (k *awsKey) publicKey(ctx context.Context) ([]byte, error) {
+	_, pub, err := k.describe(ctx)
+	return pub, err
+}
+
+// describe fetches the public key of the key and picks the algorithm we sign with
+func (k *awsKey) describe(ctx context.Context) (algorithm string, pub []byte, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.pub != nil {
+		return k.algorithm, k.pub, nil
+	}
+
+	var resp struct {
+		PublicKey         string   `json:"PublicKey"`
+		SigningAlgorithms []string `json:"SigningAlgorithms"`
+	}
+	err = k.do(ctx, "GetPublicKey", map[string]string{"KeyId": k.ARN}, &resp)
+	if err != nil {
+		return "", nil, err
+	}
+	pub, err = base64.StdEncoding.DecodeString(resp.PublicKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot decode public key of %s: %w", k.uri(), err)
+	}
+	for _, alg := range awsSigningAlgorithms {
+		for _, supported := range resp.SigningAlgorithms {
+			if alg == supported {
+				algorithm = alg
+				break
+			}
+		}
+		if algorithm != "" {
+			break
+		}
+	}
+	if algorithm == "" {
+		return "", nil, fmt.Errorf("%s supports none of the signing algorithms %s", k.uri(), strings.Join(awsSigningAlgorithms, ", "))
+	}
+
+	k.algorithm, k.pub = algorithm, pub
+	return algorithm, pub, nil
+}
+
+// do calls an action of the AWS KMS JSON API using a SigV4 signed request
+func (k *awsKey) do(ctx context.Context, action string, body, out interface{}) error {
+	creds, err := k.Credentials(ctx)
+	if err != nil {
+		return &AccessError{URI: k.uri(), Message: fmt.Sprintf("no AWS credentials available: %v", err)}
+	}
+
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.Endpoint+"/", bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+
+	payloadHash := sha256.Sum256(raw)
+	err = v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(payloadHash[:]), "kms", k.Region, time.Now())
+	if err != nil {
+		return err
+	}
+	return do(k.Client, req, k.uri(), out)
+}
+
+// awsCredentialsFromEnvironment obtains credentials using the default AWS credential chain, i.e. environment
+// variables, shared config files and instance roles
+func awsCredentialsFromEnvironment(region string) CredentialsSource {
+	return func(ctx context.Context) (aws.Credentials, error) {
+		cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+		if err != nil {
+			return aws.Credentials{}, err
+		}
+		return cfg.Credentials.Retrieve(ctx)
+	}
+}
+
+// isAWSAccessDenied returns true if an AWS error response reports missing or insufficient credentials.
+// AWS answers such requests with 400 Bad Request and names the error in the __type field of the body.
+func isAWSAccessDenied(statusCode int, body []byte) bool {
+	if statusCode != http.StatusBadRequest {
+		return false
+	}
+	var resp struct {
+		Type string `json:"__type"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return false
+	}
+	for _, tpe := range awsAccessDeniedErrors {
+		if strings.HasSuffix(resp.Type, tpe) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+)
+
+// DefaultGCPEndpoint is the endpoint of the Google Cloud KMS API
+const DefaultGCPEndpoint = "https://cloudkms.googleapis.com"
+
+// gcpMetadataTokenURL is where workloads running on Google Cloud obtain an access token for their service account
+const gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+var gcpKeyVersionName = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+/cryptoKeyVersions/[^/]+$`)
+
+// TokenSource provides an OAuth2 access token
+type TokenSource func(ctx context.Context) (string, error)
+
+// gcpKey is a key version in Google Cloud KMS
+type gcpKey struct {
+	Name     string
+	Endpoint string
+	Token    TokenSource
+	Client   *http.Client
+}
+
+func newGCPKey(name string) (*gcpKey, error) {
+	if !gcpKeyVersionName.MatchString(name) {
+		return nil, fmt.Errorf("expected projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>/cryptoKeyVersions/<version>")
+	}
+	return &gcpKey{
+		Name:     name,
+		Endpoint: DefaultGCPEndpoint,
+		Token:    GCPTokenFromEnvironment,
+		Client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (k *gcpKey) uri() string {
+	return SchemeGCP + k.Name
+}
+
+func (k *gcpKey) signDigest(ctx context.Context, digest []byte) ([]byte, error) {
+	var req struct {
+		Digest struct {
+			SHA256 string `json:"sha256"`
+		} `json:"digest"`
+	}
+	req.Digest.SHA256 = base64.StdEncoding.EncodeToString(digest)
+
+	var resp struct {
+		Signature string `json:"signature"`
+	}
+	err := k.do(ctx, http.MethodPost, "/v1/"+k.Name+":asymmetricSign", req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Signature)
+}
+
+func (k *gcpKey) publicKey(ctx context.Context) ([]byte, error) {
+	var resp struct {
+		PEM string `json:"pem"`
+	}
+	err := k.do(ctx, http.MethodGet, "/v1/"+k.Name+"/publicKey", nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+	blk, _ := pem.Decode([]byte(resp.PEM))
+	if blk == nil {
+		return nil, fmt.Errorf("KMS returned no public key for %s", k.uri())
+	}
+	return blk.Bytes, nil
+}
+
+func (k *gcpKey) do(ctx context.Context, method, path string, body, out interface{}) error {
+	token, err := k.Token(ctx)
+	if err != nil {
+		return &AccessError{URI: k.uri(), Message: err.Error()}
+	}
+
+	var in io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		in = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, k.Endpoint+path, in)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	return do(k.Client, req, k.uri(), out)
+}
+
+// GCPTokenFromEnvironment obtains an access token from the GOOGLE_OAUTH_ACCESS_TOKEN environment variable,
+// or from the metadata server when running on Google Cloud
+func GCPTokenFromEnvironment(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpMetadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("no access token available - set GOOGLE_OAUTH_ACCESS_TOKEN (e.g. to the output of gcloud auth print-access-token) or run on Google Cloud")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server token request returned %s", resp.Status)
+	}
+	var res struct {
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return "", err
+	}
+	return res.AccessToken, nil
+}
@@ -0,0 +1,200 @@
+// Package kms signs and verifies DSSE envelopes with asymmetric keys held in a cloud key management service.
+// Keys are referenced by URI:
+//
+//	gcpkms://projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>/cryptoKeyVersions/<version>
+//	awskms:///<key ARN>
+//	awskms://<endpoint>/<key ARN>
+//
+// The private key never leaves the KMS: signing sends the SHA-256 digest of the DSSE pre-authentication encoding
+// to the KMS, verification uses the public key the KMS hands out.
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+const (
+	// SchemeGCP is the URI scheme of keys held in Google Cloud KMS
+	SchemeGCP = "gcpkms://"
+	// SchemeAWS is the URI scheme of keys held in AWS KMS
+	SchemeAWS = "awskms://"
+)
+
+// IsKeyURI returns true if s references a KMS key rather than a file
+func IsKeyURI(s string) bool {
+	return strings.HasPrefix(s, SchemeGCP) || strings.HasPrefix(s, SchemeAWS)
+}
+
+// UnavailableError is returned if the KMS cannot be reached, e.g. due to a network error or an outage
+type UnavailableError struct {
+	URI string
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("cannot reach the KMS holding %s: %v - check the network connection and that the KMS endpoint is reachable", e.URI, e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error { return e.Err }
+
+// AccessError is returned if the KMS refuses to use the key, e.g. because credentials are missing or lack permissions
+type AccessError struct {
+	URI        string
+	StatusCode int
+	Message    string
+}
+
+func (e *AccessError) Error() string {
+	msg := fmt.Sprintf("KMS denied access to %s", e.URI)
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (%d %s)", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg + " - check the credentials and that they may sign with the key and read its public key"
+}
+
+// key is an asymmetric key held in a KMS
+type key interface {
+	// signDigest signs the SHA-256 digest of a message
+	signDigest(ctx context.Context, digest []byte) ([]byte, error)
+	// publicKey returns the DER encoded public key
+	publicKey(ctx context.Context) ([]byte, error)
+}
+
+// Signer signs and verifies DSSE payloads with a KMS key. It is safe for concurrent use.
+type Signer struct {
+	URI string
+
+	key key
+
+	mu  sync.Mutex
+	pub crypto.PublicKey
+}
+
+// NewSigner creates a signer for the key the URI references. The KMS is not contacted before the first signature
+// or verification.
+func NewSigner(uri string) (*Signer, error) {
+	var (
+		k   key
+		err error
+	)
+	switch {
+	case strings.HasPrefix(uri, SchemeGCP):
+		k, err = newGCPKey(strings.TrimPrefix(uri, SchemeGCP))
+	case strings.HasPrefix(uri, SchemeAWS):
+		k, err = newAWSKey(strings.TrimPrefix(uri, SchemeAWS))
+	default:
+		return nil, fmt.Errorf("unsupported KMS key URI %s: must start with %s or %s", uri, SchemeGCP, SchemeAWS)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid KMS key URI %s: %w", uri, err)
+	}
+	return &Signer{URI: uri, key: k}, nil
+}
+
+// Sign produces a signature over the DSSE pre-authentication encoding of payloadType and payload. The key ID of
+// the signature is the key URI.
+func (s *Signer) Sign(ctx context.Context, payloadType string, payload []byte) (*dsse.Signature, error) {
+	digest := sha256.Sum256(dsse.PAE(payloadType, payload))
+	sig, err := s.key.signDigest(ctx, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	return &dsse.Signature{
+		KeyID: s.URI,
+		Sig:   base64.StdEncoding.EncodeToString(sig),
+	}, nil
+}
+
+// Verify checks that sig is a signature over the DSSE pre-authentication encoding of payloadType and payload made
+// with the key. Errors reaching the KMS are returned as UnavailableError or AccessError.
+func (s *Signer) Verify(ctx context.Context, payloadType string, payload []byte, sig dsse.Signature) error {
+	pub, err := s.publicKey(ctx)
+	if err != nil {
+		return err
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig.Sig)
+	if err != nil {
+		return fmt.Errorf("cannot decode signature: %w", err)
+	}
+
+	digest := sha256.Sum256(dsse.PAE(payloadType, payload))
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest[:], raw) {
+			return fmt.Errorf("signature does not match %s", s.URI)
+		}
+	case *rsa.PublicKey:
+		// the key does not tell which padding it signs with, hence we accept both
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], raw) != nil && rsa.VerifyPSS(pub, crypto.SHA256, digest[:], raw, nil) != nil {
+			return fmt.Errorf("signature does not match %s", s.URI)
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T of %s", pub, s.URI)
+	}
+	return nil
+}
+
+func (s *Signer) publicKey(ctx context.Context) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pub != nil {
+		return s.pub, nil
+	}
+
+	der, err := s.key.publicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse public key of %s: %w", s.URI, err)
+	}
+	s.pub = pub
+	return pub, nil
+}
+
+// do sends a JSON request to a KMS and decodes the response into out. Failures to reach the KMS and denied
+// access are reported as UnavailableError and AccessError respectively.
+func do(client *http.Client, req *http.Request, uri string, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return &UnavailableError{URI: uri, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		err = json.NewDecoder(resp.Body).Decode(out)
+		if err != nil {
+			return fmt.Errorf("cannot decode KMS response for %s: %w", uri, err)
+		}
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || isAWSAccessDenied(resp.StatusCode, msg):
+		return &AccessError{URI: uri, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	case resp.StatusCode >= 500:
+		return &UnavailableError{URI: uri, Err: fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))}
+	default:
+		return fmt.Errorf("KMS request for %s failed with %s: %s", uri, resp.Status, bytes.TrimSpace(msg))
+	}
+}
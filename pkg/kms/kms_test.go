@@ -0,0 +1,218 @@
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	testGCPKey = "projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"
+	testAWSKey = "arn:aws:kms:eu-west-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+)
+
+// fakeGCPKMS signs digests with an ECDSA key, like Google Cloud KMS would for an EC_SIGN_P256_SHA256 key
+type fakeGCPKMS struct {
+	key    *ecdsa.PrivateKey
+	denied bool
+}
+
+func (f *fakeGCPKMS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.denied || r.Header.Get("Authorization") != "Bearer token" {
+		http.Error(w, `{"error": {"status": "PERMISSION_DENIED"}}`, http.StatusForbidden)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/" + testGCPKey + ":asymmetricSign":
+		var req struct {
+			Digest struct {
+				SHA256 []byte `json:"sha256"`
+			} `json:"digest"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sig, err := ecdsa.SignASN1(rand.Reader, f.key, req.Digest.SHA256)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"signature": base64.StdEncoding.EncodeToString(sig)})
+	case "/v1/" + testGCPKey + "/publicKey":
+		der, _ := x509.MarshalPKIXPublicKey(f.key.Public())
+		_ = json.NewEncoder(w).Encode(map[string]string{"pem": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fakeAWSKMS signs digests with an RSA key, like AWS KMS would for an RSA_2048 key
+type fakeAWSKMS struct {
+	key    *rsa.PrivateKey
+	denied bool
+}
+
+func (f *fakeAWSKMS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.denied || !strings.Contains(r.Header.Get("Authorization"), "Credential=AKID/") {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type": "AccessDeniedException", "message": "not allowed"}`))
+		return
+	}
+
+	var req struct {
+		KeyId            string
+		Message          []byte
+		SigningAlgorithm string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.KeyId != testAWSKey {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type": "NotFoundException"}`))
+		return
+	}
+	switch r.Header.Get("X-Amz-Target") {
+	case "TrentService.Sign":
+		if req.SigningAlgorithm != "RSASSA_PKCS1_V1_5_SHA_256" {
+			http.Error(w, "unexpected signing algorithm "+req.SigningAlgorithm, http.StatusBadRequest)
+			return
+		}
+		sig, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, req.Message)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"Signature": base64.StdEncoding.EncodeToString(sig)})
+	case "TrentService.GetPublicKey":
+		der, _ := x509.MarshalPKIXPublicKey(f.key.Public())
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"PublicKey":         base64.StdEncoding.EncodeToString(der),
+			"SigningAlgorithms": []string{"RSASSA_PSS_SHA_256", "RSASSA_PKCS1_V1_5_SHA_256"},
+		})
+	default:
+		http.Error(w, "unknown action", http.StatusBadRequest)
+	}
+}
+
+func newTestSigners(t *testing.T) (gcpKMS *fakeGCPKMS, awsKMS *fakeAWSKMS, signers map[string]*Signer) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcpKMS, awsKMS = &fakeGCPKMS{key: ecKey}, &fakeAWSKMS{key: rsaKey}
+	gcpSrv, awsSrv := httptest.NewServer(gcpKMS), httptest.NewServer(awsKMS)
+	t.Cleanup(gcpSrv.Close)
+	t.Cleanup(awsSrv.Close)
+
+	gcpSigner, err := NewSigner(SchemeGCP + testGCPKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gk := gcpSigner.key.(*gcpKey)
+	gk.Endpoint = gcpSrv.URL
+	gk.Token = func(ctx context.Context) (string, error) { return "token", nil }
+
+	awsSigner, err := NewSigner(SchemeAWS + "/" + testAWSKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ak := awsSigner.key.(*awsKey)
+	ak.Endpoint = awsSrv.URL
+	ak.Credentials = staticCredentials
+
+	return gcpKMS, awsKMS, map[string]*Signer{"gcp": gcpSigner, "aws": awsSigner}
+}
+
+func staticCredentials(ctx context.Context) (aws.Credentials, error) {
+	return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+}
+
+func TestSignAndVerify(t *testing.T) {
+	_, _, signers := newTestSigners(t)
+
+	ctx := context.Background()
+	for name, signer := range signers {
+		t.Run(name, func(t *testing.T) {
+			sig, err := signer.Sign(ctx, "application/vnd.in-toto+json", []byte("payload"))
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			if sig.KeyID != signer.URI {
+				t.Errorf("Sign() key ID = %q; expected %q", sig.KeyID, signer.URI)
+			}
+
+			err = signer.Verify(ctx, "application/vnd.in-toto+json", []byte("payload"), *sig)
+			if err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+			err = signer.Verify(ctx, "application/vnd.in-toto+json", []byte("tampered"), *sig)
+			if err == nil {
+				t.Errorf("Verify() accepted a signature over a different payload")
+			}
+		})
+	}
+}
+
+func TestKMSErrors(t *testing.T) {
+	gcpKMS, awsKMS, signers := newTestSigners(t)
+	gcpKMS.denied, awsKMS.denied = true, true
+
+	ctx := context.Background()
+	for name, signer := range signers {
+		_, err := signer.Sign(ctx, "application/vnd.in-toto+json", []byte("payload"))
+		var accessErr *AccessError
+		if !errors.As(err, &accessErr) {
+			t.Errorf("%s: Sign() error = %v; expected an AccessError", name, err)
+		}
+	}
+
+	unreachable, err := NewSigner(SchemeAWS + "127.0.0.1:1/" + testAWSKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable.key.(*awsKey).Credentials = staticCredentials
+	_, err = unreachable.Sign(ctx, "application/vnd.in-toto+json", []byte("payload"))
+	var unavailableErr *UnavailableError
+	if !errors.As(err, &unavailableErr) {
+		t.Errorf("Sign() error = %v; expected an UnavailableError", err)
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	tests := []struct {
+		URI   string
+		Valid bool
+	}{
+		{URI: SchemeGCP + testGCPKey, Valid: true},
+		{URI: SchemeGCP + "projects/p/locations/global/keyRings/r/cryptoKeys/k"},
+		{URI: SchemeAWS + "/" + testAWSKey, Valid: true},
+		{URI: SchemeAWS + "localhost:4566/" + testAWSKey, Valid: true},
+		{URI: SchemeAWS + "/arn:aws:kms:eu-west-1:111122223333:alias/provenance", Valid: true},
+		{URI: SchemeAWS + "/1234abcd-12ab-34cd-56ef-1234567890ab"},
+		{URI: "hashivault://provenance"},
+	}
+	for _, test := range tests {
+		_, err := NewSigner(test.URI)
+		if valid := err == nil; valid != test.Valid {
+			t.Errorf("NewSigner(%q) error = %v; expected valid = %v", test.URI, err, test.Valid)
+		}
+	}
+}
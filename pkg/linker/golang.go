@@ -1,12 +1,18 @@
 package linker
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
 
+	"github.com/pmezard/go-difflib/difflib"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/module"
@@ -15,40 +21,147 @@ import (
 	"github.com/khulnasoft/blazedock/pkg/blazedock"
 )
 
-// LinkGoWorkspace updates a go.work file to include all Go components.
+// LinkOption configures the behaviour of the Go linker
+type LinkOption func(*linkOptions)
+
+type linkOptions struct {
+	DryRun             io.Writer
+	Force              bool
+	OnlyChangedDeps    bool
+	AllowLocalReplaces bool
+
+	root      string
+	conflicts []ReplaceConflict
+}
+
+// WithDryRun makes the linker write a unified diff of its changes to out instead of modifying any file
+func WithDryRun(out io.Writer) LinkOption {
+	return func(opts *linkOptions) {
+		opts.DryRun = out
+	}
+}
+
+// WithForce makes the linker override replace directives it did not add itself, and tag them as blazedock-managed
+func WithForce(force bool) LinkOption {
+	return func(opts *linkOptions) {
+		opts.Force = force
+	}
+}
+
+// WithOnlyChangedDeps makes the linker leave go.mod files alone whose blazedock replace directives are up to date
+func WithOnlyChangedDeps(onlyChanged bool) LinkOption {
+	return func(opts *linkOptions) {
+		opts.OnlyChangedDeps = onlyChanged
+	}
+}
+
+// ReplaceConflict describes a replace directive blazedock wanted to add, but which already exists and is not managed by blazedock
+type ReplaceConflict struct {
+	Package  string
+	Module   module.Version
+	Existing module.Version
+}
+
+func (c *ReplaceConflict) Error() string {
+	return fmt.Sprintf("replacement for %s exists already (=> %s), but was not added by blazedock", c.Module.String(), c.Existing.String())
+}
+
+// ReplaceConflictError is returned when linking finds replace directives that were not added by blazedock
+type ReplaceConflictError struct {
+	Conflicts []ReplaceConflict
+}
+
+func (e *ReplaceConflictError) Error() string {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "found %d replace directives which were not added by blazedock (use --force to override them):", len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		fmt.Fprintf(&msg, "\n  %s: %s => %s", c.Package, c.Module.String(), c.Existing.String())
+	}
+	return msg.String()
+}
+
+func applyLinkOpts(workspace *blazedock.Workspace, opts []LinkOption) *linkOptions {
+	res := &linkOptions{root: workspace.Origin, AllowLocalReplaces: workspace.Link.AllowLocalReplaces}
+	for _, o := range opts {
+		o(res)
+	}
+	return res
+}
+
+// writeFile writes the new content of fn, or in dry-run mode prints how the content would change
+func (opts *linkOptions) writeFile(fn string, old, new []byte) error {
+	if opts.DryRun == nil {
+		if bytes.Equal(old, new) {
+			return nil
+		}
+		return os.WriteFile(fn, new, 0644)
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(fn, opts.root), "/")
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(old)),
+		B:        difflib.SplitLines(string(new)),
+		FromFile: "a/" + name,
+		ToFile:   "b/" + name,
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		return nil
+	}
+
+	var direct, indirect, dropped int
+	for _, l := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(l, "+++ ") || strings.HasPrefix(l, "--- ") || !strings.Contains(l, "// blazedock") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(l, "-"):
+			dropped++
+		case strings.HasPrefix(l, "+") && strings.Contains(l, "// blazedock indirect "):
+			indirect++
+		case strings.HasPrefix(l, "+"):
+			direct++
+		}
+	}
+	if direct+indirect+dropped > 0 {
+		_, err := fmt.Fprintf(opts.DryRun, "# %s: %d direct, %d indirect blazedock entries added, %d dropped\n", name, direct, indirect, dropped)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(opts.DryRun, diff)
+	return err
+}
+
+// LinkGoWorkspace updates a go.work file to include all Go components, as well as the Go modules outside the
+// workspace listed in its link config.
 // Returns an error if `go.work` does not exist yet.
-func LinkGoWorkspace(workspace *blazedock.Workspace) error {
+func LinkGoWorkspace(workspace *blazedock.Workspace, opts ...LinkOption) error {
+	options := applyLinkOpts(workspace, opts)
+
 	workFN := filepath.Join(workspace.Origin, "go.work")
 	if _, err := os.Stat(workFN); err != nil {
 		return fmt.Errorf("not a Go workspace: %v", err)
 	}
 
 	// update workspace file
-	fc, err := os.ReadFile(workFN)
+	origFC, err := os.ReadFile(workFN)
 	if err != nil {
 		return err
 	}
-	workFile, err := modfile.ParseWork(workFN, fc, nil)
+	workFile, err := modfile.ParseWork(workFN, origFC, nil)
 	if err != nil {
 		return err
 	}
 
-	for _, use := range workFile.Use {
-		if ok, _ := isBlazedockReplace(use.Syntax); ok {
-			err = workFile.DropUse(use.Path)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	goModules := make(map[string]struct{}, len(workspace.Components))
-	for _, pkg := range workspace.Packages {
-		if pkg.Type != blazedock.GoPackage {
-			continue
-		}
-		fn := strings.TrimPrefix(strings.TrimPrefix(pkg.C.Origin, workspace.Origin), "/")
-		goModules[fn] = struct{}{}
+	err = dropBlazedockUses(workFile, nil)
+	if err != nil {
+		return err
 	}
+	goModules := goWorkModules(workspace)
 	sortedPaths := make([]string, 0, len(workspace.Components))
 	for p := range goModules {
 		sortedPaths = append(sortedPaths, p)
@@ -70,8 +183,7 @@ func LinkGoWorkspace(workspace *blazedock.Workspace) error {
 	workFile.SortBlocks()
 	workFile.Cleanup()
 
-	fc = modfile.Format(workFile.Syntax)
-	err = os.WriteFile(workFN, fc, 0644)
+	err = options.writeFile(workFN, origFC, modfile.Format(workFile.Syntax))
 	if err != nil {
 		return err
 	}
@@ -82,23 +194,279 @@ func LinkGoWorkspace(workspace *blazedock.Workspace) error {
 			continue
 		}
 
-		err := removeBlazedockReplaceRules(p)
+		err := removeBlazedockReplaceRules(p, options)
 		if err != nil {
 			return err
 		}
 	}
 
+	if options.DryRun != nil {
+		return nil
+	}
+	return pruneGoWorkSum(workspace.Origin)
+}
+
+// goWorkModules returns the paths of all Go modules LinkGoWorkspace adds use directives for
+func goWorkModules(workspace *blazedock.Workspace) map[string]struct{} {
+	res := make(map[string]struct{}, len(workspace.Components))
+	for _, pkg := range workspace.Packages {
+		if pkg.Type != blazedock.GoPackage {
+			continue
+		}
+		fn := strings.TrimPrefix(strings.TrimPrefix(pkg.C.Origin, workspace.Origin), "/")
+		res[fn] = struct{}{}
+	}
+	for _, pth := range workspace.Link.ExternalGoModules {
+		pth = filepath.Clean(pth)
+		dir := pth
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workspace.Origin, dir)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err != nil {
+			log.WithError(err).WithField("path", pth).Warn("external Go module has no go.mod - not adding it to go.work")
+			continue
+		}
+		res[filepath.ToSlash(pth)] = struct{}{}
+	}
+	return res
+}
+
+// DiffGoWorkspace compares the use directives blazedock added to the go.work file with the Go modules of the workspace.
+// It returns the paths of the modules LinkGoWorkspace would add use directives for, and the paths of the use directives
+// it would remove. Use directives which were not added by blazedock are ignored.
+func DiffGoWorkspace(workspace *blazedock.Workspace) (missing, stale []string, err error) {
+	workFN := filepath.Join(workspace.Origin, "go.work")
+	fc, err := os.ReadFile(workFN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a Go workspace: %v", err)
+	}
+	workFile, err := modfile.ParseWork(workFN, fc, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	used := make(map[string]struct{}, len(workFile.Use))
+	for _, use := range workFile.Use {
+		if ok, _ := isBlazedockReplace(use.Syntax); !ok {
+			continue
+		}
+		used[filepath.ToSlash(filepath.Clean(use.Path))] = struct{}{}
+	}
+	modules := make(map[string]struct{})
+	for pth := range goWorkModules(workspace) {
+		modules[filepath.ToSlash(filepath.Clean(pth))] = struct{}{}
+	}
+
+	for pth := range modules {
+		if _, ok := used[pth]; !ok {
+			missing = append(missing, pth)
+		}
+	}
+	for pth := range used {
+		if _, ok := modules[pth]; !ok {
+			stale = append(stale, pth)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	return missing, stale, nil
+}
+
+// UnlinkGoModules removes all replace directives blazedock added to the go.mod files of the workspace's Go packages,
+// as well as all use directives blazedock added to the go.work file. If target is not nil, only the target is unlinked.
+// Replace directives which were not added by blazedock, or are marked as "blazedock ignore", remain untouched.
+func UnlinkGoModules(workspace *blazedock.Workspace, target *blazedock.Package, opts ...LinkOption) error {
+	options := applyLinkOpts(workspace, opts)
+
+	workFN := filepath.Join(workspace.Origin, "go.work")
+	_, err := os.Stat(workFN)
+	hasGoWork := err == nil
+	if hasGoWork {
+		var only map[string]struct{}
+		if target != nil {
+			only = map[string]struct{}{
+				strings.TrimPrefix(strings.TrimPrefix(target.C.Origin, workspace.Origin), "/"): {},
+			}
+		}
+		err = modifyGoWork(workFN, options, func(workFile *modfile.WorkFile) error {
+			return dropBlazedockUses(workFile, only)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, p := range workspace.Packages {
+		if p.Type != blazedock.GoPackage {
+			continue
+		}
+		if target != nil && p.FullName() != target.FullName() {
+			continue
+		}
+
+		err = removeBlazedockReplaceRules(p, options)
+		if errors.Is(err, os.ErrNotExist) {
+			log.WithField("pkg", p.FullName()).Warn("did not find go.mod for this package - skipping")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		log.WithField("pkg", p.FullName()).Debug("unlinked Go module")
+	}
+
+	if hasGoWork && options.DryRun == nil {
+		return pruneGoWorkSum(workspace.Origin)
+	}
+	return nil
+}
+
+// pruneGoWorkSum drops all entries from the go.work.sum in dir which refer to modules that are no longer
+// part of the workspace's module graph, e.g. because linking removed the module which required them.
+// The go command adds missing entries by itself, but never removes stale ones.
+func pruneGoWorkSum(dir string) error {
+	sumFN := filepath.Join(dir, "go.work.sum")
+	if _, err := os.Stat(sumFN); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		log.WithField("fn", sumFN).Warn("go is not available - cannot prune go.work.sum")
+		return nil
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", "mod", "graph")
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return xerrors.Errorf("cannot compute module graph to prune %s: %w: %s", sumFN, err, strings.TrimSpace(stderr.String()))
+	}
+	var (
+		graph   = make(map[string]struct{})
+		modules = make(map[string]struct{})
+	)
+	for _, mod := range strings.Fields(string(out)) {
+		idx := strings.LastIndex(mod, "@")
+		if idx < 0 {
+			// main modules have no version
+			continue
+		}
+		graph[mod[:idx]+" "+mod[idx+1:]] = struct{}{}
+		modules[mod[:idx]] = struct{}{}
+	}
+
+	// go mod graph may have added entries itself, hence we read the sum file only now
+	fc, err := os.ReadFile(sumFN)
+	if err != nil {
+		return err
+	}
+	var (
+		lines   = strings.Split(strings.TrimSpace(string(fc)), "\n")
+		kept    = make([]string, 0, len(lines))
+		dropped int
+	)
+	for _, line := range lines {
+		segs := strings.Fields(line)
+		if len(segs) != 3 {
+			continue
+		}
+		if version, ok := strings.CutSuffix(segs[1], "/go.mod"); ok {
+			// the go command checks the go.mod of other versions than the ones the pruned graph lists, e.g. during
+			// minimal version selection, hence those are kept as long as the module is part of the graph
+			if _, ok := modules[segs[0]]; !ok {
+				dropped++
+				continue
+			}
+		} else if _, ok := graph[segs[0]+" "+version]; !ok {
+			dropped++
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if dropped == 0 {
+		return nil
+	}
+	log.WithField("fn", sumFN).WithField("dropped", dropped).Debug("pruned stale go.work.sum entries")
+
+	var res string
+	if len(kept) > 0 {
+		res = strings.Join(kept, "\n") + "\n"
+	}
+	return os.WriteFile(sumFN, []byte(res), 0644)
+}
+
+func modifyGoWork(workFN string, opts *linkOptions, mod func(workFile *modfile.WorkFile) error) error {
+	fc, err := os.ReadFile(workFN)
+	if err != nil {
+		return err
+	}
+	workFile, err := modfile.ParseWork(workFN, fc, nil)
+	if err != nil {
+		return err
+	}
+
+	err = mod(workFile)
+	if err != nil {
+		return err
+	}
+	workFile.Cleanup()
+
+	return opts.writeFile(workFN, fc, modfile.Format(workFile.Syntax))
+}
+
+// dropBlazedockUses removes all use directives added by blazedock. If only is not nil, just the
+// use directives of those paths are removed.
+func dropBlazedockUses(workFile *modfile.WorkFile, only map[string]struct{}) error {
+	for _, use := range workFile.Use {
+		if ok, _ := isBlazedockReplace(use.Syntax); !ok {
+			continue
+		}
+		if _, ok := only[use.Path]; only != nil && !ok {
+			continue
+		}
+
+		err := workFile.DropUse(use.Path)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // LinkGoModules produces the neccesary "replace"ments in all of the package's
 // go.mod files, s.t. the packages link in the workspace/work with Go's tooling in-situ.
-func LinkGoModules(workspace *blazedock.Workspace, target *blazedock.Package) error {
+func LinkGoModules(workspace *blazedock.Workspace, target *blazedock.Package, opts ...LinkOption) error {
+	options := applyLinkOpts(workspace, opts)
+
 	mods, err := collectReplacements(workspace)
 	if err != nil {
 		return err
 	}
 
+	if !options.Force {
+		// find all conflicts before we touch any go.mod file so that we can report them at once
+		check := *options
+		check.DryRun = io.Discard
+		err = linkGoModules(workspace, target, mods, &check)
+		if err != nil {
+			return err
+		}
+		if len(check.conflicts) > 0 {
+			sort.Slice(check.conflicts, func(i, j int) bool {
+				if check.conflicts[i].Package != check.conflicts[j].Package {
+					return check.conflicts[i].Package < check.conflicts[j].Package
+				}
+				return check.conflicts[i].Module.String() < check.conflicts[j].Module.String()
+			})
+			return &ReplaceConflictError{Conflicts: check.conflicts}
+		}
+	}
+
+	return linkGoModules(workspace, target, mods, options)
+}
+
+func linkGoModules(workspace *blazedock.Workspace, target *blazedock.Package, mods map[string]goModule, options *linkOptions) error {
 	for _, p := range workspace.Packages {
 		if p.Type != blazedock.GoPackage {
 			continue
@@ -126,7 +494,7 @@ func LinkGoModules(workspace *blazedock.Workspace, target *blazedock.Package) er
 			return apmods[i].Name < apmods[j].Name
 		})
 
-		err = linkGoModule(p, apmods)
+		err := linkGoModule(p, apmods, options)
 		if err != nil {
 			return err
 		}
@@ -135,16 +503,20 @@ func LinkGoModules(workspace *blazedock.Workspace, target *blazedock.Package) er
 	return nil
 }
 
-func modifyGoMod(dst *blazedock.Package, mod func(goModFN string, gomod *modfile.File) error) error {
-	var goModFn string
-	for _, f := range dst.Sources {
+// goModFile returns the go.mod file among the sources of a Go package
+func goModFile(pkg *blazedock.Package) (string, error) {
+	for _, f := range pkg.Sources {
 		if strings.HasSuffix(f, "go.mod") {
-			goModFn = f
-			break
+			return f, nil
 		}
 	}
-	if goModFn == "" {
-		return xerrors.Errorf("%w: go.mod not found", os.ErrNotExist)
+	return "", xerrors.Errorf("%w: go.mod not found", os.ErrNotExist)
+}
+
+func modifyGoMod(dst *blazedock.Package, opts *linkOptions, mod func(goModFN string, gomod *modfile.File) error) error {
+	goModFn, err := goModFile(dst)
+	if err != nil {
+		return err
 	}
 	fc, err := os.ReadFile(goModFn)
 	if err != nil {
@@ -161,44 +533,72 @@ func modifyGoMod(dst *blazedock.Package, mod func(goModFN string, gomod *modfile
 	}
 	gomod.Cleanup()
 
-	fc, err = gomod.Format()
+	newFC, err := gomod.Format()
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(goModFn, fc, 0644)
+	// Formatting normalises the whole file. If mod did not change anything, we keep the file as it was rather
+	// than producing a diff that's all whitespace.
+	orig, err := modfile.Parse(goModFn, fc, nil)
 	if err != nil {
 		return err
 	}
+	if origFC, err := orig.Format(); err == nil && bytes.Equal(origFC, newFC) {
+		newFC = fc
+	}
 
-	return nil
+	return opts.writeFile(goModFn, fc, newFC)
 }
 
-func linkGoModule(dst *blazedock.Package, mods []goModule) error {
-	err := removeBlazedockReplaceRules(dst)
+func linkGoModule(dst *blazedock.Package, mods []goModule, opts *linkOptions) error {
+	goModFN, err := goModFile(dst)
 	if err != nil {
 		return err
 	}
+	replaces, err := goModReplaces(goModFN, mods)
+	if err != nil {
+		return err
+	}
+	if opts.OnlyChangedDeps {
+		upToDate, err := hasBlazedockReplaces(goModFN, replaces)
+		if err != nil {
+			return err
+		}
+		if upToDate {
+			log.WithField("dst", dst.FullName()).Debug("Go module is linked already - skipping")
+			return nil
+		}
+	}
 
-	return modifyGoMod(dst, func(goModFN string, gomod *modfile.File) error {
-		for _, mod := range mods {
-			relpath, err := filepath.Rel(filepath.Dir(goModFN), mod.OriginPath)
-			if err != nil {
-				return err
-			}
+	return modifyGoMod(dst, opts, func(_ string, gomod *modfile.File) error {
+		if opts.AllowLocalReplaces {
+			replaces = skipLocalReplaces(dst, goModFN, gomod, replaces)
+		}
+
+		// replaces we still want are updated in place below, so that they keep their position and comments
+		keep := make(map[module.Version]struct{}, len(replaces))
+		for _, r := range replaces {
+			keep[r.Old] = struct{}{}
+		}
+		err := dropBlazedockReplaces(gomod, keep)
+		if err != nil {
+			return err
+		}
 
-			err = addReplace(gomod, module.Version{Path: mod.Name}, module.Version{Path: relpath}, true, mod.OriginPackage)
+		for _, r := range replaces {
+			err := addReplace(gomod, r.Old, r.New, r.Source == "", r.Source, opts.Force)
+			var conflict *ReplaceConflict
+			if errors.As(err, &conflict) {
+				conflict.Package = dst.FullName()
+				opts.conflicts = append(opts.conflicts, *conflict)
+				continue
+			}
 			if err != nil {
 				return err
 			}
-			log.WithField("dst", dst.FullName()).WithField("dep", mod.Name).Debug("linked Go modules")
-		}
-		for _, mod := range mods {
-			for _, r := range mod.Replacements {
-				err = addReplace(gomod, r.Old, r.New, false, mod.OriginPackage)
-				if err != nil {
-					return err
-				}
+			if r.Source == "" {
+				log.WithField("dst", dst.FullName()).WithField("dep", r.Old.Path).Debug("linked Go modules")
 			}
 		}
 
@@ -206,57 +606,208 @@ func linkGoModule(dst *blazedock.Package, mods []goModule) error {
 	})
 }
 
-func removeBlazedockReplaceRules(dst *blazedock.Package) error {
-	return modifyGoMod(dst, func(_ string, gomod *modfile.File) error {
+// skipLocalReplaces drops the replaces for which the go.mod file has a replace directive already, that was written
+// by hand and points to the same directory
+func skipLocalReplaces(dst *blazedock.Package, goModFN string, gomod *modfile.File, replaces []goReplace) []goReplace {
+	dir := filepath.Dir(goModFN)
+	localPath := func(pth string) string {
+		if !filepath.IsAbs(pth) {
+			pth = filepath.Join(dir, pth)
+		}
+		return filepath.Clean(pth)
+	}
+
+	res := make([]goReplace, 0, len(replaces))
+	for _, r := range replaces {
+		var compatible bool
 		for _, rep := range gomod.Replace {
-			if ok, tpe := isBlazedockReplace(rep.Syntax); !ok || tpe == blazedockReplaceIgnore {
+			if rep.Syntax == nil || rep.Old != r.Old {
 				continue
 			}
-
-			log.WithField("replace", rep).Debug("dropping replace")
-			err := gomod.DropReplace(rep.Old.Path, rep.Old.Version)
-			if err != nil {
-				return err
+			if ok, _ := isBlazedockReplace(rep.Syntax); ok {
+				continue
 			}
+			compatible = r.New.Version == "" && rep.New.Version == "" && modfile.IsDirectoryPath(rep.New.Path) &&
+				localPath(rep.New.Path) == localPath(r.New.Path)
 		}
-		return nil
+		if compatible {
+			log.WithField("dst", dst.FullName()).WithField("replace", r.Old.String()).Info("keeping local replace which was not added by blazedock")
+			continue
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// goReplace is a replace directive blazedock adds to a go.mod file. Source is the package an indirect replace
+// was taken from, and empty for direct replaces.
+type goReplace struct {
+	Old    module.Version
+	New    module.Version
+	Source string
+}
+
+func (r goReplace) comment() string {
+	if r.Source == "" {
+		return "// blazedock"
+	}
+	return "// blazedock indirect from " + r.Source
+}
+
+// goModReplaces computes the replace directives linking the go.mod file goModFN against mods: first the modules
+// themselves, then the replace directives of those modules
+func goModReplaces(goModFN string, mods []goModule) ([]goReplace, error) {
+	var res []goReplace
+	for _, mod := range mods {
+		relpath, err := filepath.Rel(filepath.Dir(goModFN), mod.OriginPath)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, goReplace{Old: module.Version{Path: mod.Name}, New: module.Version{Path: relpath}})
+	}
+	for _, mod := range mods {
+		for _, r := range mod.Replacements {
+			res = append(res, goReplace{Old: r.Old, New: r.New, Source: mod.OriginPackage})
+		}
+	}
+	return res, nil
+}
+
+// hasBlazedockReplaces returns true if the blazedock replace directives of the go.mod file goModFN are exactly
+// the given ones. Later replaces of the same module override earlier ones, just like they do when linking.
+func hasBlazedockReplaces(goModFN string, replaces []goReplace) (bool, error) {
+	fc, err := os.ReadFile(goModFN)
+	if err != nil {
+		return false, err
+	}
+	gomod, err := modfile.Parse(goModFN, fc, nil)
+	if err != nil {
+		return false, err
+	}
+
+	desired := make(map[module.Version]string, len(replaces))
+	for _, r := range replaces {
+		desired[r.Old] = r.New.String() + " " + r.comment()
+	}
+	existing := make(map[module.Version]string, len(gomod.Replace))
+	for _, rep := range gomod.Replace {
+		if ok, tpe := isBlazedockReplace(rep.Syntax); !ok || tpe == blazedockReplaceIgnore {
+			continue
+		}
+		var comment string
+		for _, c := range rep.Syntax.Suffix {
+			comment = c.Token
+		}
+		existing[rep.Old] = rep.New.String() + " " + comment
+	}
+	return reflect.DeepEqual(desired, existing), nil
+}
+
+func removeBlazedockReplaceRules(dst *blazedock.Package, opts *linkOptions) error {
+	return modifyGoMod(dst, opts, func(_ string, gomod *modfile.File) error {
+		return dropBlazedockReplaces(gomod, nil)
 	})
 }
 
-func addReplace(gomod *modfile.File, old, new module.Version, direct bool, source string) error {
+// dropBlazedockReplaces removes all replace directives added by blazedock, except for those replacing
+// a module in keep
+func dropBlazedockReplaces(gomod *modfile.File, keep map[module.Version]struct{}) error {
 	for _, rep := range gomod.Replace {
-		if rep.Old.Path != old.Path || rep.Old.Version != old.Version {
+		if ok, tpe := isBlazedockReplace(rep.Syntax); !ok || tpe == blazedockReplaceIgnore {
 			continue
 		}
-		if ok, tpe := isBlazedockReplace(rep.Syntax); ok && tpe != blazedockReplaceIgnore {
-			err := gomod.DropReplace(old.Path, old.Version)
-			if err != nil {
-				return err
-			}
+		if _, ok := keep[rep.Old]; ok {
+			continue
+		}
+
+		log.WithField("replace", rep).Debug("dropping replace")
+		err := gomod.DropReplace(rep.Old.Path, rep.Old.Version)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// addReplace adds a blazedock replace directive for old, or updates the existing one in place. Replace directives
+// which were not added by blazedock are a conflict, unless force is set.
+func addReplace(gomod *modfile.File, old, new module.Version, direct bool, source string, force bool) error {
+	var existing *modfile.Replace
+	for _, rep := range gomod.Replace {
+		if rep.Syntax == nil || rep.Old != old {
 			continue
 		}
+		if ok, tpe := isBlazedockReplace(rep.Syntax); !(ok && tpe != blazedockReplaceIgnore) {
+			if !force {
+				// replacement already exists - cannot replace
+				return &ReplaceConflict{Module: old, Existing: rep.New}
+			}
+			log.WithField("replace", rep.Old.String()).WithField("existing", rep.New.String()).Warn("overriding replace which was not added by blazedock")
+		}
+		existing = rep
+		break
+	}
 
-		// replacement already exists - cannot replace
-		return xerrors.Errorf("replacement for %s exists already, but was not added by blazedock", old.String())
+	tokens := []string{modfile.AutoQuote(old.Path)}
+	if old.Version != "" {
+		tokens = append(tokens, old.Version)
+	}
+	tokens = append(tokens, "=>", modfile.AutoQuote(new.Path))
+	if new.Version != "" {
+		tokens = append(tokens, new.Version)
 	}
 
-	err := gomod.AddReplace(old.Path, old.Version, new.Path, new.Version)
-	if err != nil {
-		return err
+	if existing == nil {
+		existing = &modfile.Replace{Old: old, Syntax: addBlazedockReplaceLine(gomod.Syntax)}
+		gomod.Replace = append(gomod.Replace, existing)
 	}
+	if !existing.Syntax.InBlock {
+		tokens = append([]string{"replace"}, tokens...)
+	}
+	existing.New = new
+	existing.Syntax.Token = tokens
 
 	comment := "// blazedock"
 	if !direct {
 		comment += " indirect from " + source
 	}
-	for _, rep := range gomod.Replace {
-		if rep.Old.Path == old.Path && rep.Old.Version == old.Version {
-			rep.Syntax.InBlock = true
-			rep.Syntax.Comments.Suffix = []modfile.Comment{{Token: comment, Suffix: true}}
+	existing.Syntax.Comments.Suffix = []modfile.Comment{{Token: comment, Suffix: true}}
+	return nil
+}
+
+// addBlazedockReplaceLine adds an empty replace directive after the last one added by blazedock, or to the end of the
+// file if there is none. This way the replace directives blazedock adds stay together and do not end up amidst
+// those which were written by hand.
+func addBlazedockReplaceLine(syntax *modfile.FileSyntax) *modfile.Line {
+	for i := len(syntax.Stmt) - 1; i >= 0; i-- {
+		switch stmt := syntax.Stmt[i].(type) {
+		case *modfile.LineBlock:
+			if stmt.Token[0] != "replace" {
+				continue
+			}
+			for _, l := range stmt.Line {
+				if ok, tpe := isBlazedockReplace(l); ok && tpe != blazedockReplaceIgnore && l.Token != nil {
+					line := &modfile.Line{InBlock: true}
+					stmt.Line = append(stmt.Line, line)
+					return line
+				}
+			}
+		case *modfile.Line:
+			if len(stmt.Token) == 0 || stmt.Token[0] != "replace" {
+				continue
+			}
+			if ok, tpe := isBlazedockReplace(stmt); !ok || tpe == blazedockReplaceIgnore {
+				continue
+			}
+			line := &modfile.Line{}
+			syntax.Stmt = append(syntax.Stmt[:i+1], append([]modfile.Expr{line}, syntax.Stmt[i+1:]...)...)
+			return line
 		}
 	}
-	return nil
+
+	line := &modfile.Line{}
+	syntax.Stmt = append(syntax.Stmt, line)
+	return line
 }
 
 type goModule struct {
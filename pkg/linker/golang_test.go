@@ -0,0 +1,497 @@
+package linker
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func TestLinkGoWorkspaceSharedDependency(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not available")
+	}
+	// everything in this workspace is local - make sure we never hit the network
+	t.Setenv("GOFLAGS", "")
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GOWORK", "")
+
+	const staleSum = "example.com/stale v1.0.0 h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+	files := map[string]string{
+		"WORKSPACE.yaml":    "",
+		"go.work":           "go 1.21\n",
+		"go.work.sum":       staleSum + "\n",
+		"a/BUILD.yaml":      "packages:\n- name: app\n  type: go\n  srcs: [\"go.mod\", \"*.go\"]\n  deps: [\"shared:lib\"]\n",
+		"a/go.mod":          "module example.com/a\n\ngo 1.21\n",
+		"a/main.go":         "package main\n\nimport \"example.com/shared\"\n\nfunc main() { shared.Hello() }\n",
+		"b/BUILD.yaml":      "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\", \"*.go\"]\n  deps: [\"shared:lib\"]\n",
+		"b/go.mod":          "module example.com/b\n\ngo 1.21\n",
+		"b/b.go":            "package b\n\nimport \"example.com/shared\"\n\nfunc B() { shared.Hello() }\n",
+		"shared/BUILD.yaml": "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\", \"*.go\"]\n",
+		"shared/go.mod":     "module example.com/shared\n\ngo 1.21\n",
+		"shared/shared.go":  "package shared\n\nfunc Hello() {}\n",
+	}
+
+	loc, ws := writeWorkspace(t, files)
+	err := LinkGoWorkspace(&ws)
+	if err != nil {
+		t.Fatalf("LinkGoWorkspace() error = %v", err)
+	}
+
+	cmd := exec.Command("go", "build", "example.com/a", "example.com/b")
+	cmd.Dir = loc
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build failed after linking: %v\n%s", err, out)
+	}
+
+	sum, err := os.ReadFile(filepath.Join(loc, "go.work.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sum), "example.com/stale") {
+		t.Errorf("go.work.sum still contains stale entry:\n%s", sum)
+	}
+}
+
+func TestLinkGoWorkspaceExternalDependency(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not available")
+	}
+	// the external dependency is served from a directory - make sure we never hit the network
+	proxy := t.TempDir()
+	for fn, content := range map[string]string{
+		"example.com/dep/@v/list":        "v1.0.0\n",
+		"example.com/dep/@v/v1.0.0.info": `{"Version":"v1.0.0"}`,
+		"example.com/dep/@v/v1.0.0.mod":  "module example.com/dep\n\ngo 1.21\n",
+	} {
+		fn = filepath.Join(proxy, fn)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("GOFLAGS", "-modcacherw")
+	t.Setenv("GOPROXY", "file://"+filepath.ToSlash(proxy))
+	t.Setenv("GOSUMDB", "off")
+	t.Setenv("GOWORK", "")
+	t.Setenv("GOMODCACHE", t.TempDir())
+
+	const depModSum = "example.com/dep v1.0.0/go.mod h1:+QWJ4TaKK0+5my1i1gpAnAdlLGkNg3weRsq3J1AghTg="
+	tests := []struct {
+		Name        string
+		Sum         []string
+		Expectation []string
+		Error       string
+	}{
+		{
+			Name: "prunes modules which are not part of the graph",
+			Sum: []string{
+				depModSum,
+				"example.com/dep v0.9.0/go.mod h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+				"example.com/dep v0.9.0 h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+				"example.com/stale v1.0.0/go.mod h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+				"example.com/stale v1.0.0 h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+			},
+			Expectation: []string{
+				depModSum,
+				"example.com/dep v0.9.0/go.mod h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+			},
+		},
+		{
+			Name:  "reports why the graph cannot be computed",
+			Sum:   []string{"example.com/dep v1.0.0/go.mod h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="},
+			Error: "checksum mismatch",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			loc, ws := writeWorkspace(t, map[string]string{
+				"WORKSPACE.yaml": "",
+				"go.work":        "go 1.21\n",
+				"go.work.sum":    strings.Join(test.Sum, "\n") + "\n",
+				"a/BUILD.yaml":   "packages:\n- name: app\n  type: go\n  srcs: [\"go.mod\"]\n",
+				"a/go.mod":       "module example.com/a\n\ngo 1.21\n\nrequire example.com/dep v1.0.0\n",
+			})
+
+			err := LinkGoWorkspace(&ws)
+			if test.Error != "" {
+				if err == nil || !strings.Contains(err.Error(), test.Error) {
+					t.Fatalf("expected LinkGoWorkspace() error containing %q, got %v", test.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LinkGoWorkspace() error = %v", err)
+			}
+
+			fc, err := os.ReadFile(filepath.Join(loc, "go.work.sum"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := strings.Join(test.Expectation, "\n") + "\n"
+			if string(fc) != expected {
+				t.Errorf("go.work.sum mismatch: want\n%s\ngot\n%s", expected, fc)
+			}
+		})
+	}
+}
+
+func TestLinkGoWorkspaceExternalModules(t *testing.T) {
+	external := t.TempDir()
+	err := os.WriteFile(filepath.Join(external, "go.mod"), []byte("module example.com/external\n\ngo 1.21\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loc, ws := writeWorkspace(t, map[string]string{
+		"WORKSPACE.yaml":         "link:\n  externalGoModules: [\"third_party/lib/\", \"" + external + "\", \"missing\"]\n",
+		"go.work":                "go 1.21\n\nuse ./tools\n",
+		"a/BUILD.yaml":           "packages:\n- name: app\n  type: go\n  srcs: [\"go.mod\"]\n",
+		"a/go.mod":               "module example.com/a\n\ngo 1.21\n",
+		"third_party/lib/go.mod": "module example.com/lib\n\ngo 1.21\n",
+		"tools/go.mod":           "module example.com/tools\n\ngo 1.21\n",
+	})
+
+	err = LinkGoWorkspace(&ws)
+	if err != nil {
+		t.Fatalf("LinkGoWorkspace() error = %v", err)
+	}
+	fc, err := os.ReadFile(filepath.Join(loc, "go.work"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workFile, err := modfile.ParseWork("go.work", fc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uses []string
+	for _, use := range workFile.Use {
+		if ok, _ := isBlazedockReplace(use.Syntax); ok {
+			uses = append(uses, use.Path)
+		}
+	}
+	sort.Strings(uses)
+	expected := []string{external, "a", "third_party/lib"}
+	sort.Strings(expected)
+	if diff := cmp.Diff(expected, uses); diff != "" {
+		t.Errorf("blazedock uses mismatch (-want +got):\n%s", diff)
+	}
+
+	err = UnlinkGoModules(&ws, nil)
+	if err != nil {
+		t.Fatalf("UnlinkGoModules() error = %v", err)
+	}
+	fc, err = os.ReadFile(filepath.Join(loc, "go.work"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("go 1.21\n\nuse ./tools\n", string(fc)); diff != "" {
+		t.Errorf("unlinked go.work mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLinkGoModulesDryRun(t *testing.T) {
+	files := map[string]string{
+		"WORKSPACE.yaml":    "",
+		"a/BUILD.yaml":      "packages:\n- name: app\n  type: go\n  srcs: [\"go.mod\"]\n  deps: [\"b:lib\"]\n",
+		"a/go.mod":          "module example.com/a\n\ngo 1.21\n",
+		"b/BUILD.yaml":      "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n  deps: [\"shared:lib\"]\n",
+		"b/go.mod":          "module example.com/b\n\ngo 1.21\n\nreplace example.com/ext => example.com/fork v1.0.0\n",
+		"shared/BUILD.yaml": "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n",
+		"shared/go.mod":     "module example.com/shared\n\ngo 1.21\n",
+	}
+	loc, ws := writeWorkspace(t, files)
+
+	var out bytes.Buffer
+	err := LinkGoModules(&ws, nil, WithDryRun(&out))
+	if err != nil {
+		t.Fatalf("LinkGoModules() error = %v", err)
+	}
+
+	for fn, content := range files {
+		fc, err := os.ReadFile(filepath.Join(loc, fn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(fc) != content {
+			t.Errorf("dry-run modified %s:\n%s", fn, fc)
+		}
+	}
+
+	diff := out.String()
+	for _, expected := range []string{
+		"--- a/a/go.mod\n+++ b/a/go.mod\n",
+		"# a/go.mod: 2 direct, 1 indirect blazedock entries added, 0 dropped\n",
+		"+replace example.com/b => ../b // blazedock\n",
+		"+replace example.com/shared => ../shared // blazedock\n",
+		"+replace example.com/ext => example.com/fork v1.0.0 // blazedock indirect from b:lib\n",
+	} {
+		if !strings.Contains(diff, expected) {
+			t.Errorf("dry-run output does not contain %q:\n%s", expected, diff)
+		}
+	}
+}
+
+func TestLinkGoModulesPreservesToolchain(t *testing.T) {
+	files := map[string]string{
+		"WORKSPACE.yaml": "",
+		"a/BUILD.yaml":   "packages:\n- name: app\n  type: go\n  srcs: [\"go.mod\"]\n  deps: [\"b:lib\"]\n",
+		"a/go.mod":       "module example.com/a\n\ngo 1.22\n\ntoolchain go1.22.0\n",
+		"b/BUILD.yaml":   "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n",
+		"b/go.mod":       "module example.com/b\n\ngo 1.22\n",
+	}
+	loc, ws := writeWorkspace(t, files)
+
+	err := LinkGoModules(&ws, nil)
+	if err != nil {
+		t.Fatalf("LinkGoModules() error = %v", err)
+	}
+
+	fc, err := os.ReadFile(filepath.Join(loc, "a", "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gomod, err := modfile.Parse("go.mod", fc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gomod.Toolchain == nil || gomod.Toolchain.Name != "go1.22.0" {
+		t.Errorf("toolchain directive did not survive linking:\n%s", fc)
+	}
+	if !strings.Contains(string(fc), "replace example.com/b => ../b // blazedock\n") {
+		t.Errorf("go.mod was not linked:\n%s", fc)
+	}
+}
+
+func TestLinkGoModulesOnlyChangedDeps(t *testing.T) {
+	files := map[string]string{
+		"WORKSPACE.yaml":    "",
+		"a/BUILD.yaml":      "packages:\n- name: app\n  type: go\n  srcs: [\"go.mod\"]\n  deps: [\"b:lib\"]\n",
+		"a/go.mod":          "module example.com/a\n\ngo 1.21\n",
+		"b/BUILD.yaml":      "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n  deps: [\"shared:lib\"]\n",
+		"b/go.mod":          "module example.com/b\n\ngo 1.21\n\nreplace example.com/ext => example.com/fork v1.0.0\n",
+		"shared/BUILD.yaml": "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n",
+		"shared/go.mod":     "module example.com/shared\n\ngo 1.21\n",
+	}
+	loc, ws := writeWorkspace(t, files)
+
+	err := LinkGoModules(&ws, nil, WithOnlyChangedDeps(true))
+	if err != nil {
+		t.Fatalf("LinkGoModules() error = %v", err)
+	}
+
+	// backdate all go.mod files, so that we notice any write of the second link
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, fn := range []string{"a/go.mod", "b/go.mod", "shared/go.mod"} {
+		err = os.Chtimes(filepath.Join(loc, fn), past, past)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = LinkGoModules(&ws, nil, WithOnlyChangedDeps(true))
+	if err != nil {
+		t.Fatalf("LinkGoModules() error = %v", err)
+	}
+	for _, fn := range []string{"a/go.mod", "b/go.mod", "shared/go.mod"} {
+		stat, err := os.Stat(filepath.Join(loc, fn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !stat.ModTime().Equal(past) {
+			t.Errorf("no-op link wrote %s", fn)
+		}
+	}
+}
+
+func TestLinkGoModulesGolden(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Input  string
+		Golden string
+	}{
+		{Name: "relinking is a no-op", Input: "testdata/linked.go.mod", Golden: "testdata/linked.go.mod.golden"},
+		{Name: "linking keeps the rest of the file", Input: "testdata/unlinked.go.mod", Golden: "testdata/unlinked.go.mod.golden"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			input, err := os.ReadFile(test.Input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden, err := os.ReadFile(test.Golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			loc, ws := writeWorkspace(t, map[string]string{
+				"WORKSPACE.yaml":    "",
+				"a/BUILD.yaml":      "packages:\n- name: app\n  type: go\n  srcs: [\"go.mod\"]\n  deps: [\"b:lib\"]\n",
+				"a/go.mod":          string(input),
+				"b/BUILD.yaml":      "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n  deps: [\"shared:lib\"]\n",
+				"b/go.mod":          "module example.com/b\n\ngo 1.21\n\nreplace example.com/ext2 => example.com/fork2 v1.0.0\n",
+				"shared/BUILD.yaml": "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n",
+				"shared/go.mod":     "module example.com/shared\n\ngo 1.21\n",
+			})
+			pkg := ws.Packages["a:app"]
+
+			err = LinkGoModules(&ws, pkg)
+			if err != nil {
+				t.Fatalf("LinkGoModules() error = %v", err)
+			}
+			act, err := os.ReadFile(filepath.Join(loc, "a", "go.mod"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(string(golden), string(act)); diff != "" {
+				t.Errorf("linked go.mod mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLinkGoModulesConflicts(t *testing.T) {
+	files := map[string]string{
+		"WORKSPACE.yaml":    "",
+		"a/BUILD.yaml":      "packages:\n- name: app\n  type: go\n  srcs: [\"go.mod\"]\n  deps: [\"b:lib\", \"shared:lib\"]\n",
+		"a/go.mod":          "module example.com/a\n\ngo 1.21\n\nreplace example.com/b => ../forks/b\n",
+		"b/BUILD.yaml":      "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n  deps: [\"shared:lib\"]\n",
+		"b/go.mod":          "module example.com/b\n\ngo 1.21\n\nreplace example.com/shared v1.0.0 => example.com/shared v1.1.0\n\nreplace example.com/shared => ../forks/shared\n",
+		"shared/BUILD.yaml": "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n",
+		"shared/go.mod":     "module example.com/shared\n\ngo 1.21\n",
+	}
+	loc, ws := writeWorkspace(t, files)
+
+	err := LinkGoModules(&ws, nil)
+	var conflicts *ReplaceConflictError
+	if !errors.As(err, &conflicts) {
+		t.Fatalf("LinkGoModules() error = %v, expected a ReplaceConflictError", err)
+	}
+	expected := []ReplaceConflict{
+		{Package: "a:app", Module: module.Version{Path: "example.com/b"}, Existing: module.Version{Path: "../forks/b"}},
+		{Package: "b:lib", Module: module.Version{Path: "example.com/shared"}, Existing: module.Version{Path: "../forks/shared"}},
+	}
+	if diff := cmp.Diff(expected, conflicts.Conflicts); diff != "" {
+		t.Errorf("LinkGoModules() conflicts mismatch (-want +got):\n%s", diff)
+	}
+	for fn, content := range files {
+		fc, err := os.ReadFile(filepath.Join(loc, fn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(fc) != content {
+			t.Errorf("conflicting link modified %s:\n%s", fn, fc)
+		}
+	}
+
+	err = LinkGoModules(&ws, nil, WithForce(true))
+	if err != nil {
+		t.Fatalf("LinkGoModules() with force error = %v", err)
+	}
+	fc, err := os.ReadFile(filepath.Join(loc, "a", "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(fc), "replace example.com/b => ../b // blazedock\n") || strings.Contains(string(fc), "forks/b") {
+		t.Errorf("forced link did not override the existing replace:\n%s", fc)
+	}
+}
+
+func TestLinkGoModulesLocalReplaces(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Workspace   string
+		GoMod       string
+		Conflict    bool
+		Expectation string
+	}{
+		{
+			Name:     "not allowed",
+			GoMod:    "module example.com/a\n\ngo 1.21\n\nreplace example.com/b => ../b\n",
+			Conflict: true,
+		},
+		{
+			Name:        "same directory",
+			Workspace:   "link:\n  allowLocalReplaces: true\n",
+			GoMod:       "module example.com/a\n\ngo 1.21\n\nreplace example.com/b => ./../b/\n",
+			Expectation: "module example.com/a\n\ngo 1.21\n\nreplace example.com/b => ./../b/\n\nreplace example.com/shared => ../shared // blazedock\n",
+		},
+		{
+			Name:      "different directory",
+			Workspace: "link:\n  allowLocalReplaces: true\n",
+			GoMod:     "module example.com/a\n\ngo 1.21\n\nreplace example.com/b => ../forks/b\n",
+			Conflict:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			loc, ws := writeWorkspace(t, map[string]string{
+				"WORKSPACE.yaml":    test.Workspace,
+				"a/BUILD.yaml":      "packages:\n- name: app\n  type: go\n  srcs: [\"go.mod\"]\n  deps: [\"b:lib\"]\n",
+				"a/go.mod":          test.GoMod,
+				"b/BUILD.yaml":      "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n  deps: [\"shared:lib\"]\n",
+				"b/go.mod":          "module example.com/b\n\ngo 1.21\n",
+				"shared/BUILD.yaml": "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n",
+				"shared/go.mod":     "module example.com/shared\n\ngo 1.21\n",
+			})
+
+			err := LinkGoModules(&ws, ws.Packages["a:app"])
+			var conflicts *ReplaceConflictError
+			if conflict := errors.As(err, &conflicts); conflict != test.Conflict {
+				t.Fatalf("LinkGoModules() error = %v, expected conflict = %v", err, test.Conflict)
+			}
+			if test.Conflict {
+				return
+			}
+			if err != nil {
+				t.Fatalf("LinkGoModules() error = %v", err)
+			}
+
+			fc, err := os.ReadFile(filepath.Join(loc, "a", "go.mod"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.Expectation, string(fc)); diff != "" {
+				t.Errorf("linked go.mod mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func writeWorkspace(t *testing.T, files map[string]string) (string, blazedock.Workspace) {
+	t.Helper()
+
+	loc := t.TempDir()
+	for fn, content := range files {
+		fn = filepath.Join(loc, fn)
+		err := os.MkdirAll(filepath.Dir(fn), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(fn, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ws, err := blazedock.FindWorkspace(loc, blazedock.Arguments{}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc, ws
+}
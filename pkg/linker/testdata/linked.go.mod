@@ -0,0 +1,21 @@
+// Package a is the app
+module example.com/a
+
+go 1.21
+
+require (
+	example.com/zzz v1.0.0
+	// keep this one
+	example.com/aaa v1.2.0 // indirect
+)
+require example.com/late v0.1.0
+
+// b is developed in the workspace
+replace example.com/b => ../b // blazedock
+
+replace example.com/ext => example.com/fork v1.0.0
+
+replace (
+	example.com/shared => ../shared // blazedock
+	example.com/ext2 => example.com/fork2 v1.0.0 // blazedock indirect from b:lib
+)
@@ -0,0 +1,17 @@
+// Package a is the app
+module example.com/a
+
+go 1.21
+
+require (
+	example.com/zzz v1.0.0
+	// keep this one
+	example.com/aaa v1.2.0 // indirect
+)
+
+// local fork, not managed by blazedock
+replace example.com/ext => example.com/fork v1.0.0
+
+replace example.com/old => ../old // blazedock
+
+require example.com/late v0.1.0
@@ -1,16 +1,21 @@
 package linker
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/xerrors"
 
 	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/doublestar"
 )
 
 // LinkYarnPackagesWithYarn2 uses `yarn link` to link all TS packages in-situ.
@@ -129,3 +134,364 @@ func LinkYarnPackagesWithYarn2(workspace *blazedock.Workspace) error {
 
 	return lerr
 }
+
+// blazedockWorkspacesKey is the package.json field which lists the workspaces entries added by blazedock
+const blazedockWorkspacesKey = "blazedockWorkspaces"
+
+// LinkYarnWorkspace adds all yarn components to the workspaces of the package.json in the workspace root,
+// s.t. they resolve each other in-situ. Returns an error if that package.json does not exist yet.
+func LinkYarnWorkspace(workspace *blazedock.Workspace, opts ...LinkOption) error {
+	options := applyLinkOpts(workspace, opts)
+
+	var components []string
+	for _, p := range workspace.Packages {
+		if p.Type != blazedock.YarnPackage {
+			continue
+		}
+
+		loc, err := filepath.Rel(workspace.Origin, p.C.Origin)
+		if err != nil {
+			return err
+		}
+		if loc == "." {
+			continue
+		}
+		components = append(components, filepath.ToSlash(loc))
+	}
+
+	return modifyRootPackageJSON(workspace, options, func(workspaces []string, managed map[string]struct{}) []string {
+		var (
+			res     []string
+			present = make(map[string]struct{})
+		)
+		for _, ws := range workspaces {
+			if isBlazedockWorkspace(ws, managed) {
+				continue
+			}
+			res = append(res, ws)
+			present[ws] = struct{}{}
+		}
+		for k := range managed {
+			delete(managed, k)
+		}
+
+		sort.Strings(components)
+		for _, c := range components {
+			if _, exists := present[c]; exists {
+				continue
+			}
+			if coveredByWorkspaces(c, res) {
+				continue
+			}
+			res = append(res, c)
+			present[c] = struct{}{}
+			managed[c] = struct{}{}
+		}
+		log.WithField("workspaces", res).Debug("linked yarn workspace")
+		return res
+	})
+}
+
+// UnlinkYarnWorkspace removes all workspaces entries added by LinkYarnWorkspace from the package.json in the workspace root
+func UnlinkYarnWorkspace(workspace *blazedock.Workspace, opts ...LinkOption) error {
+	options := applyLinkOpts(workspace, opts)
+
+	return modifyRootPackageJSON(workspace, options, func(workspaces []string, managed map[string]struct{}) []string {
+		var res []string
+		for _, ws := range workspaces {
+			if isBlazedockWorkspace(ws, managed) {
+				continue
+			}
+			res = append(res, ws)
+		}
+		for k := range managed {
+			delete(managed, k)
+		}
+		return res
+	})
+}
+
+// coveredByWorkspaces returns true if one of the workspaces globs matches loc already
+func coveredByWorkspaces(loc string, workspaces []string) bool {
+	for _, ws := range workspaces {
+		if ok, _ := doublestar.Match(path.Clean(ws), loc); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlazedockWorkspace(entry string, managed map[string]struct{}) bool {
+	_, ok := managed[entry]
+	return ok
+}
+
+// modifyRootPackageJSON updates the workspaces of the package.json in the workspace root. The workspaces can either
+// be a list or an object with a packages list. managed contains the entries previously added by blazedock, and is
+// expected to contain the entries added by blazedock after mod returns.
+func modifyRootPackageJSON(workspace *blazedock.Workspace, opts *linkOptions, mod func(workspaces []string, managed map[string]struct{}) []string) error {
+	fn := filepath.Join(workspace.Origin, "package.json")
+	fc, err := os.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	var pkgjson map[string]interface{}
+	err = json.Unmarshal(fc, &pkgjson)
+	if err != nil {
+		return xerrors.Errorf("cannot parse %s: %w", fn, err)
+	}
+
+	var (
+		workspaces []string
+		container  map[string]interface{}
+		key        = "workspaces"
+	)
+	switch ws := pkgjson["workspaces"].(type) {
+	case nil:
+	case []interface{}:
+		workspaces, err = toStringSlice(ws)
+	case map[string]interface{}:
+		container, key = ws, "packages"
+		if pkgs, ok := ws["packages"].([]interface{}); ok {
+			workspaces, err = toStringSlice(pkgs)
+		} else if ws["packages"] != nil {
+			err = xerrors.Errorf("workspaces.packages is not a list")
+		}
+	default:
+		err = xerrors.Errorf("workspaces is neither a list nor an object")
+	}
+	if err != nil {
+		return xerrors.Errorf("%s: %w", fn, err)
+	}
+
+	managed := make(map[string]struct{})
+	if m, ok := pkgjson[blazedockWorkspacesKey].([]interface{}); ok {
+		entries, err := toStringSlice(m)
+		if err != nil {
+			return xerrors.Errorf("%s: %s: %w", fn, blazedockWorkspacesKey, err)
+		}
+		for _, e := range entries {
+			managed[e] = struct{}{}
+		}
+	}
+
+	origWorkspaces, origManaged := strings.Join(workspaces, "\n"), sortedKeys(managed)
+	workspaces = mod(workspaces, managed)
+	if strings.Join(workspaces, "\n") == origWorkspaces && strings.Join(sortedKeys(managed), "\n") == strings.Join(origManaged, "\n") {
+		// nothing to do - don't rewrite the package.json
+		return nil
+	}
+
+	// We patch the fields we manage in place, s.t. the rest of the package.json keeps its order and formatting.
+	out := fc
+	if container == nil {
+		if len(workspaces) > 0 {
+			out, err = setJSONField(out, 0, "workspaces", workspaces)
+		} else {
+			out, err = deleteJSONField(out, 0, "workspaces")
+		}
+	} else {
+		var obj jsonObject
+		obj, err = parseJSONObject(out, 0)
+		if err != nil {
+			return xerrors.Errorf("cannot parse %s: %w", fn, err)
+		}
+		wsField, _ := obj.Field("workspaces")
+		delete(container, key)
+		switch {
+		case len(workspaces) > 0:
+			out, err = setJSONField(out, wsField.Start, key, workspaces)
+		case len(container) == 0:
+			out, err = deleteJSONField(out, 0, "workspaces")
+		default:
+			out, err = deleteJSONField(out, wsField.Start, key)
+		}
+	}
+	if err != nil {
+		return xerrors.Errorf("cannot update %s: %w", fn, err)
+	}
+	if len(managed) > 0 {
+		out, err = setJSONField(out, 0, blazedockWorkspacesKey, sortedKeys(managed))
+	} else {
+		out, err = deleteJSONField(out, 0, blazedockWorkspacesKey)
+	}
+	if err != nil {
+		return xerrors.Errorf("cannot update %s: %w", fn, err)
+	}
+	return opts.writeFile(fn, fc, out)
+}
+
+// jsonObject is the location of a JSON object and its fields within a document
+type jsonObject struct {
+	// Open and Close are the offsets of the object's braces
+	Open, Close int
+	Fields      []jsonField
+}
+
+// jsonField is the location of an object field. KeyStart is the offset of its key, [Start, End) delimits its value.
+type jsonField struct {
+	Key        string
+	KeyStart   int
+	Start, End int
+}
+
+// Field returns the field named key and its index, or -1 if the object has no such field
+func (obj jsonObject) Field(key string) (jsonField, int) {
+	for i, f := range obj.Fields {
+		if f.Key == key {
+			return f, i
+		}
+	}
+	return jsonField{}, -1
+}
+
+// parseJSONObject finds the fields of the JSON object which starts at offset in doc
+func parseJSONObject(doc []byte, offset int) (jsonObject, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc[offset:]))
+	tkn, err := dec.Token()
+	if err != nil {
+		return jsonObject{}, err
+	}
+	if d, ok := tkn.(json.Delim); !ok || d != '{' {
+		return jsonObject{}, xerrors.Errorf("expected an object at offset %d", offset)
+	}
+	res := jsonObject{Open: offset + int(dec.InputOffset()) - 1}
+	for dec.More() {
+		keyStart := offset + int(dec.InputOffset())
+		for keyStart < len(doc) && doc[keyStart] != '"' {
+			// skip the whitespace and comma preceding the key
+			keyStart++
+		}
+		tkn, err := dec.Token()
+		if err != nil {
+			return jsonObject{}, err
+		}
+		var val json.RawMessage
+		err = dec.Decode(&val)
+		if err != nil {
+			return jsonObject{}, err
+		}
+		end := offset + int(dec.InputOffset())
+		res.Fields = append(res.Fields, jsonField{
+			Key:      tkn.(string),
+			KeyStart: keyStart,
+			Start:    end - len(bytes.TrimLeft(val, " \t\r\n")),
+			End:      end,
+		})
+	}
+	_, err = dec.Token()
+	if err != nil {
+		return jsonObject{}, err
+	}
+	res.Close = offset + int(dec.InputOffset()) - 1
+	return res, nil
+}
+
+// setJSONField sets the field key of the object at offset in doc to value, leaving the rest of doc untouched.
+// New fields are appended to the object.
+func setJSONField(doc []byte, offset int, key string, value interface{}) ([]byte, error) {
+	obj, err := parseJSONObject(doc, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	// we follow the indentation of the existing fields
+	var (
+		outer  = lineIndent(doc, obj.Open)
+		indent = outer + "  "
+		unit   = "  "
+	)
+	if len(obj.Fields) > 0 {
+		indent = lineIndent(doc, obj.Fields[0].KeyStart)
+		if len(indent) > len(outer) && strings.HasPrefix(indent, outer) {
+			unit = indent[len(outer):]
+		}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent(indent, unit)
+	err = enc.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	raw := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	qkey, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		start, end = obj.Close, obj.Close
+		repl       []byte
+	)
+	if f, idx := obj.Field(key); idx >= 0 {
+		start, end, repl = f.Start, f.End, raw
+	} else if len(obj.Fields) > 0 {
+		start = obj.Fields[len(obj.Fields)-1].End
+		end = start
+		repl = []byte(fmt.Sprintf(",\n%s%s: %s", indent, qkey, raw))
+	} else {
+		start = obj.Open + 1
+		repl = []byte(fmt.Sprintf("\n%s%s: %s\n%s", indent, qkey, raw, outer))
+	}
+	return splice(doc, start, end, repl), nil
+}
+
+// deleteJSONField removes the field key from the object at offset in doc, leaving the rest of doc untouched
+func deleteJSONField(doc []byte, offset int, key string) ([]byte, error) {
+	obj, err := parseJSONObject(doc, offset)
+	if err != nil {
+		return nil, err
+	}
+	f, idx := obj.Field(key)
+	switch {
+	case idx < 0:
+		return doc, nil
+	case idx > 0:
+		// remove the separator before the field along with it
+		return splice(doc, obj.Fields[idx-1].End, f.End, nil), nil
+	case len(obj.Fields) > 1:
+		return splice(doc, f.KeyStart, obj.Fields[1].KeyStart, nil), nil
+	default:
+		return splice(doc, obj.Open+1, obj.Close, nil), nil
+	}
+}
+
+// lineIndent returns the leading whitespace of the line containing offset
+func lineIndent(doc []byte, offset int) string {
+	start := bytes.LastIndexByte(doc[:offset], '\n') + 1
+	end := start
+	for end < offset && (doc[end] == ' ' || doc[end] == '\t') {
+		end++
+	}
+	return string(doc[start:end])
+}
+
+func splice(doc []byte, start, end int, repl []byte) []byte {
+	res := make([]byte, 0, len(doc)-(end-start)+len(repl))
+	res = append(res, doc[:start]...)
+	res = append(res, repl...)
+	return append(res, doc[end:]...)
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	res := make([]string, 0, len(set))
+	for e := range set {
+		res = append(res, e)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func toStringSlice(in []interface{}) ([]string, error) {
+	res := make([]string, 0, len(in))
+	for _, e := range in {
+		s, ok := e.(string)
+		if !ok {
+			return nil, xerrors.Errorf("expected a list of strings, found %v", e)
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
@@ -0,0 +1,133 @@
+package linker
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func TestLinkYarnWorkspace(t *testing.T) {
+	if _, err := exec.LookPath("yarn"); err != nil {
+		t.Skip("yarn is not available")
+	}
+	files := map[string]string{
+		"WORKSPACE.yaml":          "",
+		"package.json":            `{"name": "root", "private": true, "workspaces": ["tools/*"]}`,
+		"tools/lint/BUILD.yaml":   "packages:\n- name: lib\n  type: yarn\n  srcs: [\"package.json\"]\n",
+		"tools/lint/package.json": `{"name": "lint", "version": "0.0.0"}`,
+		"web/app/BUILD.yaml":      "packages:\n- name: app\n  type: yarn\n  srcs: [\"package.json\"]\n",
+		"web/app/package.json":    `{"name": "app", "version": "0.0.0"}`,
+	}
+	loc, ws := writeWorkspace(t, files)
+
+	readPackageJSON := func() map[string]interface{} {
+		fc, err := os.ReadFile(filepath.Join(loc, "package.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res map[string]interface{}
+		err = json.Unmarshal(fc, &res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	linked := map[string]interface{}{
+		"name":                 "root",
+		"private":              true,
+		"workspaces":           []interface{}{"tools/*", "web/app"},
+		blazedockWorkspacesKey: []interface{}{"web/app"},
+	}
+	for i := 0; i < 2; i++ {
+		err := LinkYarnWorkspace(&ws)
+		if err != nil {
+			t.Fatalf("LinkYarnWorkspace() error = %v", err)
+		}
+		if diff := cmp.Diff(linked, readPackageJSON()); diff != "" {
+			t.Errorf("LinkYarnWorkspace() run %d mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+
+	err := UnlinkYarnWorkspace(&ws)
+	if err != nil {
+		t.Fatalf("UnlinkYarnWorkspace() error = %v", err)
+	}
+	unlinked := map[string]interface{}{
+		"name":       "root",
+		"private":    true,
+		"workspaces": []interface{}{"tools/*"},
+	}
+	if diff := cmp.Diff(unlinked, readPackageJSON()); diff != "" {
+		t.Errorf("UnlinkYarnWorkspace() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestModifyRootPackageJSON(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Input  string
+		Golden string
+	}{
+		{Name: "workspaces list", Input: "testdata/workspaces-list.package.json", Golden: "testdata/workspaces-list.package.json.golden"},
+		{Name: "workspaces object", Input: "testdata/workspaces-object.package.json", Golden: "testdata/workspaces-object.package.json.golden"},
+	}
+
+	link := func(workspaces []string, managed map[string]struct{}) []string {
+		managed["web/app"] = struct{}{}
+		return append(workspaces, "web/app")
+	}
+	unlink := func(workspaces []string, managed map[string]struct{}) []string {
+		delete(managed, "web/app")
+		return workspaces[:len(workspaces)-1]
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			input, err := os.ReadFile(test.Input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden, err := os.ReadFile(test.Golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loc := t.TempDir()
+			fn := filepath.Join(loc, "package.json")
+			err = os.WriteFile(fn, input, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ws := &blazedock.Workspace{Origin: loc}
+			readPackageJSON := func() string {
+				fc, err := os.ReadFile(fn)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return string(fc)
+			}
+
+			err = modifyRootPackageJSON(ws, applyLinkOpts(ws, nil), link)
+			if err != nil {
+				t.Fatalf("modifyRootPackageJSON() error = %v", err)
+			}
+			if diff := cmp.Diff(string(golden), readPackageJSON()); diff != "" {
+				t.Errorf("linked package.json mismatch (-want +got):\n%s", diff)
+			}
+
+			err = modifyRootPackageJSON(ws, applyLinkOpts(ws, nil), unlink)
+			if err != nil {
+				t.Fatalf("modifyRootPackageJSON() error = %v", err)
+			}
+			if diff := cmp.Diff(string(input), readPackageJSON()); diff != "" {
+				t.Errorf("unlinked package.json mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
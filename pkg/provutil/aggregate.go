@@ -0,0 +1,50 @@
+package provutil
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+)
+
+// UnionMaterials returns the materials of all statements, sorted by URI. Materials which several statements share
+// are listed once. A material with different digests in different statements, e.g. a repository two packages were
+// built from at different commits, is listed once per digest.
+func UnionMaterials(stmts []*Statement) []common.ProvenanceMaterial {
+	var (
+		res  []common.ProvenanceMaterial
+		seen = make(map[string]struct{})
+	)
+	for _, s := range stmts {
+		for _, m := range s.Materials {
+			key := m.URI + "\x00" + digestKey(m.Digest)
+			if _, exists := seen[key]; exists {
+				continue
+			}
+			seen[key] = struct{}{}
+			res = append(res, m)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].URI != res[j].URI {
+			return res[i].URI < res[j].URI
+		}
+		return digestKey(res[i].Digest) < digestKey(res[j].Digest)
+	})
+	return res
+}
+
+// digestKey renders a digest set independent of the order of its entries
+func digestKey(d common.DigestSet) string {
+	algs := make([]string, 0, len(d))
+	for alg := range d {
+		algs = append(algs, alg)
+	}
+	sort.Strings(algs)
+
+	var res strings.Builder
+	for _, alg := range algs {
+		res.WriteString(alg + ":" + d[alg] + ";")
+	}
+	return res.String()
+}
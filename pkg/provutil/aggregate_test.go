@@ -0,0 +1,49 @@
+package provutil
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+)
+
+func TestUnionMaterials(t *testing.T) {
+	material := func(uri, digest string) common.ProvenanceMaterial {
+		return common.ProvenanceMaterial{URI: uri, Digest: common.DigestSet{"sha256": digest}}
+	}
+
+	tests := []struct {
+		Name        string
+		Statements  []*Statement
+		Expectation []common.ProvenanceMaterial
+	}{
+		{
+			Name: "no statements",
+		},
+		{
+			Name: "shared materials",
+			Statements: []*Statement{
+				{EntryPoint: "comp:app", Materials: []common.ProvenanceMaterial{material("git+https://b", "1"), material("git+https://a", "1")}},
+				{EntryPoint: "comp:lib", Materials: []common.ProvenanceMaterial{material("git+https://a", "1")}},
+			},
+			Expectation: []common.ProvenanceMaterial{material("git+https://a", "1"), material("git+https://b", "1")},
+		},
+		{
+			Name: "differing digests",
+			Statements: []*Statement{
+				{EntryPoint: "comp:app", Materials: []common.ProvenanceMaterial{material("git+https://a", "2")}},
+				{EntryPoint: "comp:lib", Materials: []common.ProvenanceMaterial{material("git+https://a", "1")}},
+			},
+			Expectation: []common.ProvenanceMaterial{material("git+https://a", "1"), material("git+https://a", "2")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act := UnionMaterials(test.Statements)
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("UnionMaterials() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,12 +1,21 @@
 package provutil
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"sort"
 	"strings"
 
 	"github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/kms"
+	"github.com/khulnasoft/blazedock/pkg/sigstore"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
 	log "github.com/sirupsen/logrus"
 	"sigs.k8s.io/bom/pkg/provenance"
 )
@@ -14,13 +23,13 @@ import (
 type Assertion struct {
 	Name        string
 	Description string
-	Run         func(stmt *provenance.Statement) []Violation
+	Run         func(stmt *Statement) []Violation
 	RunBundle   func(bundle *provenance.Envelope) []Violation
 }
 
 type Violation struct {
 	Assertion *Assertion
-	Statement *provenance.Statement
+	Statement *Statement
 	Desc      string
 }
 
@@ -29,8 +38,7 @@ func (v Violation) String() string {
 		return fmt.Sprintf("failed %s: %s", v.Assertion.Name, v.Desc)
 	}
 
-	pred := v.Statement.Predicate
-	return fmt.Sprintf("%s failed %s: %s", pred.Invocation.ConfigSource.EntryPoint, v.Assertion.Name, v.Desc)
+	return fmt.Sprintf("%s failed %s: %s", v.Statement.EntryPoint, v.Assertion.Name, v.Desc)
 }
 
 type Assertions []*Assertion
@@ -50,7 +58,7 @@ func (a Assertions) AssertBundle(bundle *provenance.Envelope) (failed []Violatio
 	return
 }
 
-func (a Assertions) AssertStatement(stmt *provenance.Statement) (failed []Violation) {
+func (a Assertions) AssertStatement(stmt *Statement) (failed []Violation) {
 	// we must not keep a reference to stmt around - it will change for each invocation
 	s := *stmt
 	for _, as := range a {
@@ -71,9 +79,8 @@ func (a Assertions) AssertStatement(stmt *provenance.Statement) (failed []Violat
 var AssertBuiltWithBlazedock = &Assertion{
 	Name:        "built-with-blazedock",
 	Description: "ensures all bundle entries have been built with blazedock",
-	Run: func(stmt *provenance.Statement) []Violation {
-		pred := stmt.Predicate
-		if strings.HasPrefix(pred.Builder.ID, blazedock.ProvenanceBuilderID) {
+	Run: func(stmt *Statement) []Violation {
+		if strings.HasPrefix(stmt.BuilderID, blazedock.ProvenanceBuilderID) {
 			return nil
 		}
 
@@ -87,14 +94,13 @@ func AssertBuiltWithBlazedockVersion(version string) *Assertion {
 	return &Assertion{
 		Name:        "built-with-blazedock-version",
 		Description: "ensures all bundle entries which have been built using blazedock, used version " + version,
-		Run: func(stmt *provenance.Statement) []Violation {
-			pred := stmt.Predicate
-			if !strings.HasPrefix(pred.Builder.ID, blazedock.ProvenanceBuilderID) {
+		Run: func(stmt *Statement) []Violation {
+			if !strings.HasPrefix(stmt.BuilderID, blazedock.ProvenanceBuilderID) {
 				return nil
 			}
 
-			if pred.Builder.ID != blazedock.ProvenanceBuilderID+":"+version {
-				return []Violation{{Desc: "was built using blazedock version " + strings.TrimPrefix(pred.Builder.ID, blazedock.ProvenanceBuilderID+":")}}
+			if stmt.BuilderID != blazedock.ProvenanceBuilderID+":"+version {
+				return []Violation{{Desc: "was built using blazedock version " + strings.TrimPrefix(stmt.BuilderID, blazedock.ProvenanceBuilderID+":")}}
 			}
 
 			return nil
@@ -102,49 +108,299 @@ func AssertBuiltWithBlazedockVersion(version string) *Assertion {
 	}
 }
 
+// AssertGitMaterialOnly ensures all materials are Git repositories, including the submodules the subjects were
+// built from. Each of them must name the commit it was built from.
 var AssertGitMaterialOnly = &Assertion{
 	Name:        "git-material-only",
 	Description: "ensures all subjects were built from Git material only",
-	Run: func(stmt *provenance.Statement) []Violation {
-		pred := stmt.Predicate
-		for _, m := range pred.Materials {
-			if strings.HasPrefix(m.URI, "git+") || strings.HasPrefix(m.URI, "git://") {
-				continue
+	Run: func(stmt *Statement) []Violation {
+		for _, m := range stmt.Materials {
+			if !strings.HasPrefix(m.URI, "git+") && !strings.HasPrefix(m.URI, "git://") {
+				return []Violation{{
+					Desc: "contains non-Git material, e.g. " + m.URI,
+				}}
+			}
+			if m.Digest["sha256"] == "" && m.Digest["sha1"] == "" {
+				return []Violation{{
+					Desc: "contains Git material without commit, e.g. " + m.URI,
+				}}
 			}
-
-			return []Violation{{
-				Desc: "contains non-Git material, e.g. " + m.URI,
-			}}
 		}
 		return nil
 	},
 }
 
+// AssertSubjectsHaveDigests ensures a statement names at least one subject and every subject carries a sha256 digest
+var AssertSubjectsHaveDigests = &Assertion{
+	Name:        "subjects-have-digests",
+	Description: "ensures all subjects have a sha256 digest",
+	Run: func(stmt *Statement) []Violation {
+		if len(stmt.Subject) == 0 {
+			return []Violation{{Desc: "has no subjects"}}
+		}
+
+		var res []Violation
+		for _, s := range stmt.Subject {
+			if s.Digest["sha256"] != "" {
+				continue
+			}
+			res = append(res, Violation{Desc: "subject " + s.Name + " has no sha256 digest"})
+		}
+		return res
+	},
+}
+
+// AssertBuildArg ensures all entries which were built by blazedock were built with the build argument set to value.
+// The values of secret build arguments are redacted in the provenance, hence they cannot be asserted.
+func AssertBuildArg(key, value string) *Assertion {
+	return &Assertion{
+		Name:        "build-arg",
+		Description: "ensures all bundle entries were built with " + key + "=" + value,
+		Run: func(stmt *Statement) []Violation {
+			if !strings.HasPrefix(stmt.BuilderID, blazedock.ProvenanceBuilderID) {
+				return nil
+			}
+
+			args, _ := stmt.Parameters["buildArgs"].(map[string]interface{})
+			act, ok := args[key]
+			if !ok {
+				return []Violation{{Desc: "was built without build argument " + key}}
+			}
+			if act == blazedock.RedactedValue {
+				return []Violation{{Desc: "build argument " + key + " is secret and cannot be asserted"}}
+			}
+			if act != value {
+				return []Violation{{Desc: fmt.Sprintf("was built with %s=%v", key, act)}}
+			}
+			return nil
+		},
+	}
+}
+
+// AssertMaterialsFromDomains ensures all materials originate from one of the given hosts. Material URIs may be
+// prefixed with git+, e.g. git+https://github.com/khulnasoft/blazedock. Statements without materials violate
+// this assertion, as we cannot tell where they were built from.
+func AssertMaterialsFromDomains(domains []string) *Assertion {
+	allowed := make(map[string]struct{}, len(domains))
+	for _, d := range domains {
+		allowed[strings.ToLower(d)] = struct{}{}
+	}
+
+	return &Assertion{
+		Name:        "materials-from-domains",
+		Description: "ensures all material originates from " + strings.Join(domains, ", "),
+		Run: func(stmt *Statement) []Violation {
+			if len(stmt.Materials) == 0 {
+				return []Violation{{Desc: "has no material"}}
+			}
+
+			var res []Violation
+			for _, m := range stmt.Materials {
+				host := materialHost(m.URI)
+				if host == "" {
+					res = append(res, Violation{Desc: "cannot determine the host of material " + m.URI})
+					continue
+				}
+				if _, ok := allowed[host]; ok {
+					continue
+				}
+				res = append(res, Violation{Desc: "contains material from " + host + ": " + m.URI})
+			}
+			return res
+		},
+	}
+}
+
+// AssertReproducible ensures the subjects of a statement carry the same digests as the subjects of a rebuild of
+// the same package. Statements are matched to the rebuild by their entry point; statements of packages which
+// were not rebuilt are not checked.
+func AssertReproducible(rebuilt []*Statement) *Assertion {
+	idx := make(map[string]*Statement, len(rebuilt))
+	for _, r := range rebuilt {
+		idx[r.EntryPoint] = r
+	}
+
+	return &Assertion{
+		Name:        "reproducible",
+		Description: "ensures rebuilding a package produces identical subjects",
+		Run: func(stmt *Statement) []Violation {
+			r, ok := idx[stmt.EntryPoint]
+			if !ok {
+				return nil
+			}
+
+			digests := make(map[string]string, len(r.Subject))
+			for _, s := range r.Subject {
+				digests[s.Name] = s.Digest["sha256"]
+			}
+
+			var differing []string
+			for _, s := range stmt.Subject {
+				d, ok := digests[s.Name]
+				delete(digests, s.Name)
+				if ok && d == s.Digest["sha256"] {
+					continue
+				}
+				differing = append(differing, s.Name)
+			}
+			for name := range digests {
+				differing = append(differing, name)
+			}
+			if len(differing) == 0 {
+				return nil
+			}
+			sort.Strings(differing)
+			return []Violation{{Desc: "rebuild produced different subjects: " + strings.Join(differing, ", ")}}
+		},
+	}
+}
+
+// materialHost returns the lower-cased host of a material URI without port, or an empty string if it has none.
+// File materials, which blazedock produces for dirty working copies, are relative to the workspace and have no host.
+func materialHost(uri string) string {
+	u, err := url.Parse(strings.TrimPrefix(uri, "git+"))
+	if err != nil || u.Scheme == "file" {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// AssertSignedWith ensures every bundle entry carries a valid DSSE signature under the given key. Signatures are
+// verified over the pre-authentication encoding of the payload type and the decoded payload.
 func AssertSignedWith(key in_toto.Key) *Assertion {
 	return &Assertion{
 		Name:        "signed-with",
 		Description: "ensures all bundles are signed with the given key",
 		RunBundle: func(bundle *provenance.Envelope) []Violation {
+			payload, sigs, err := bundleSignatures(bundle)
+			if err != nil {
+				return []Violation{{Desc: "assertion error: " + err.Error()}}
+			}
+			pae := dsse.PAE(bundle.PayloadType, payload)
+
+			for _, sig := range sigs {
+				err = verifyDSSESignature(key, sig, pae)
+				if err != nil {
+					log.WithError(err).WithField("signature", sig).Debug("signature does not match")
+					continue
+				}
+
+				return nil
+			}
+			return []Violation{{Desc: "not signed with the given key"}}
+		},
+	}
+}
+
+// AssertSignedWithKMS ensures every bundle entry carries a valid DSSE signature under a key held in a KMS.
+// If the KMS cannot be reached or denies access, that's reported rather than a missing signature.
+func AssertSignedWithKMS(signer *kms.Signer) *Assertion {
+	return &Assertion{
+		Name:        "signed-with",
+		Description: "ensures all bundles are signed with " + signer.URI,
+		RunBundle: func(bundle *provenance.Envelope) []Violation {
+			payload, sigs, err := bundleSignatures(bundle)
+			if err != nil {
+				return []Violation{{Desc: "assertion error: " + err.Error()}}
+			}
+
+			for _, sig := range sigs {
+				err = signer.Verify(context.Background(), bundle.PayloadType, payload, sig)
+				var (
+					unavailableErr *kms.UnavailableError
+					accessErr      *kms.AccessError
+				)
+				if errors.As(err, &unavailableErr) || errors.As(err, &accessErr) {
+					return []Violation{{Desc: "assertion error: " + err.Error()}}
+				}
+				if err != nil {
+					log.WithError(err).WithField("signature", sig).Debug("signature does not match")
+					continue
+				}
+
+				return nil
+			}
+			return []Violation{{Desc: "not signed with " + signer.URI}}
+		},
+	}
+}
+
+// bundleSignatures decodes the payload and the DSSE signatures of a bundle entry
+func bundleSignatures(bundle *provenance.Envelope) (payload []byte, sigs []dsse.Signature, err error) {
+	payload, err = base64.StdEncoding.DecodeString(bundle.Payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot decode payload: %w", err)
+	}
+	for _, s := range bundle.Signatures {
+		raw, err := json.Marshal(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		var sig dsse.Signature
+		err = json.Unmarshal(raw, &sig)
+		if err != nil {
+			return nil, nil, err
+		}
+		sigs = append(sigs, sig)
+	}
+	return payload, sigs, nil
+}
+
+// AssertSignedByIdentity ensures every bundle entry carries a valid keyless signature whose certificate was issued
+// by the public Fulcio instance for the given OIDC issuer and subject
+func AssertSignedByIdentity(issuer, subject string) *Assertion {
+	return AssertSignedByIdentityWithTrustRoot(sigstore.NewTrustRoot(sigstore.DefaultFulcioURL), issuer, subject)
+}
+
+// AssertSignedByIdentityWithTrustRoot is AssertSignedByIdentity for certificates issued by a particular trust root
+func AssertSignedByIdentityWithTrustRoot(root *sigstore.TrustRoot, issuer, subject string) *Assertion {
+	return &Assertion{
+		Name:        "signed-by-identity",
+		Description: fmt.Sprintf("ensures all bundles are signed by %s as issued by %s", subject, issuer),
+		RunBundle: func(bundle *provenance.Envelope) []Violation {
+			payload, err := base64.StdEncoding.DecodeString(bundle.Payload)
+			if err != nil {
+				return []Violation{{Desc: "assertion error: cannot decode payload: " + err.Error()}}
+			}
+
+			var identities []string
 			for _, s := range bundle.Signatures {
 				raw, err := json.Marshal(s)
 				if err != nil {
 					return []Violation{{Desc: "assertion error: " + err.Error()}}
 				}
-				var sig in_toto.Signature
+				var sig sigstore.Signature
 				err = json.Unmarshal(raw, &sig)
 				if err != nil {
 					return []Violation{{Desc: "assertion error: " + err.Error()}}
 				}
+				if sig.Cert == "" {
+					continue
+				}
 
-				err = in_toto.VerifySignature(key, sig, []byte(bundle.Payload))
+				id, err := root.Verify(context.Background(), sig, bundle.PayloadType, payload)
 				if err != nil {
-					log.WithError(err).WithField("signature", sig).Debug("signature does not match")
+					log.WithError(err).Debug("keyless signature is invalid")
 					continue
 				}
-
-				return nil
+				if id.Issuer == issuer && id.Subject == subject {
+					return nil
+				}
+				identities = append(identities, id.Subject+" ("+id.Issuer+")")
 			}
-			return []Violation{{Desc: "not signed with the given key"}}
+			if len(identities) > 0 {
+				return []Violation{{Desc: "signed by " + strings.Join(identities, ", ") + " only"}}
+			}
+			return []Violation{{Desc: "not signed by " + subject}}
 		},
 	}
 }
+
+// verifyDSSESignature verifies a base64 encoded DSSE signature using in-toto, which expects hex encoded signatures
+func verifyDSSESignature(key in_toto.Key, sig dsse.Signature, pae []byte) error {
+	raw, err := base64.StdEncoding.DecodeString(sig.Sig)
+	if err != nil {
+		return fmt.Errorf("cannot decode signature: %w", err)
+	}
+	return in_toto.VerifySignature(key, in_toto.Signature{KeyID: sig.KeyID, Sig: hex.EncodeToString(raw)}, pae)
+}
@@ -0,0 +1,263 @@
+package provutil
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func TestAssertMaterialsFromDomains(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Domains     []string
+		Materials   []string
+		Expectation []string
+	}{
+		{
+			Name:    "allowed hosts",
+			Domains: []string{"git.example.com"},
+			Materials: []string{
+				"git+https://git.example.com/foo/bar",
+				"git://git.example.com/foo/bar",
+				"https://GIT.example.com:8443/foo/bar",
+			},
+		},
+		{
+			Name:    "disallowed hosts",
+			Domains: []string{"git.example.com", "mirror.example.com"},
+			Materials: []string{
+				"git+https://github.com/foo/bar",
+				"git+https://mirror.example.com/foo/bar",
+				"file://components/foo/BUILD.yaml",
+			},
+			Expectation: []string{
+				"contains material from github.com: git+https://github.com/foo/bar",
+				"cannot determine the host of material file://components/foo/BUILD.yaml",
+			},
+		},
+		{
+			Name:        "no material",
+			Domains:     []string{"git.example.com"},
+			Expectation: []string{"has no material"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			stmt := &Statement{}
+			for _, m := range test.Materials {
+				stmt.Materials = append(stmt.Materials, common.ProvenanceMaterial{URI: m})
+			}
+
+			var act []string
+			for _, v := range AssertMaterialsFromDomains(test.Domains).Run(stmt) {
+				act = append(act, v.Desc)
+			}
+
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("AssertMaterialsFromDomains() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAssertGitMaterialOnly(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Materials   []common.ProvenanceMaterial
+		Expectation []string
+	}{
+		{
+			Name: "repository and submodule",
+			Materials: []common.ProvenanceMaterial{
+				{URI: "git+https://git.example.com/workspace", Digest: common.DigestSet{"sha256": "a5a7879"}},
+				{URI: "git+https://git.example.com/lib", Digest: common.DigestSet{"sha256": "b5a7879"}},
+			},
+		},
+		{
+			Name: "non-Git material",
+			Materials: []common.ProvenanceMaterial{
+				{URI: "git+https://git.example.com/workspace", Digest: common.DigestSet{"sha256": "a5a7879"}},
+				{URI: "file://components/foo/BUILD.yaml", Digest: common.DigestSet{"sha256": "c5a7879"}},
+			},
+			Expectation: []string{"contains non-Git material, e.g. file://components/foo/BUILD.yaml"},
+		},
+		{
+			Name: "submodule without commit",
+			Materials: []common.ProvenanceMaterial{
+				{URI: "git+https://git.example.com/workspace", Digest: common.DigestSet{"sha256": "a5a7879"}},
+				{URI: "git+https://git.example.com/lib"},
+			},
+			Expectation: []string{"contains Git material without commit, e.g. git+https://git.example.com/lib"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var act []string
+			for _, v := range AssertGitMaterialOnly.Run(&Statement{Materials: test.Materials}) {
+				act = append(act, v.Desc)
+			}
+
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("AssertGitMaterialOnly() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAssertSubjectsHaveDigests(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Subjects    []in_toto.Subject
+		Expectation []string
+	}{
+		{
+			Name: "all digests present",
+			Subjects: []in_toto.Subject{
+				{Name: "a.txt", Digest: common.DigestSet{"sha256": "aaa"}},
+				{Name: "b.txt", Digest: common.DigestSet{"sha256": "bbb", "sha512": "bbbb"}},
+			},
+		},
+		{
+			Name: "missing and empty digests",
+			Subjects: []in_toto.Subject{
+				{Name: "a.txt", Digest: common.DigestSet{"sha256": "aaa"}},
+				{Name: "b.txt"},
+				{Name: "c.txt", Digest: common.DigestSet{"sha256": ""}},
+				{Name: "d.txt", Digest: common.DigestSet{"sha512": "dddd"}},
+			},
+			Expectation: []string{
+				"subject b.txt has no sha256 digest",
+				"subject c.txt has no sha256 digest",
+				"subject d.txt has no sha256 digest",
+			},
+		},
+		{
+			Name:        "no subjects",
+			Expectation: []string{"has no subjects"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var act []string
+			for _, v := range AssertSubjectsHaveDigests.Run(&Statement{Subject: test.Subjects}) {
+				act = append(act, v.Desc)
+			}
+
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("AssertSubjectsHaveDigests() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAssertBuildArg(t *testing.T) {
+	builderID := blazedock.ProvenanceBuilderID + ":dev"
+	tests := []struct {
+		Name        string
+		Statement   *Statement
+		Expectation []string
+	}{
+		{
+			Name:      "matching value",
+			Statement: &Statement{BuilderID: builderID, Parameters: map[string]interface{}{"buildArgs": map[string]interface{}{"version": "1.0"}}},
+		},
+		{
+			Name:        "different value",
+			Statement:   &Statement{BuilderID: builderID, Parameters: map[string]interface{}{"buildArgs": map[string]interface{}{"version": "2.0"}}},
+			Expectation: []string{"was built with version=2.0"},
+		},
+		{
+			Name:        "missing argument",
+			Statement:   &Statement{BuilderID: builderID, Parameters: map[string]interface{}{"buildArgs": map[string]interface{}{}}},
+			Expectation: []string{"was built without build argument version"},
+		},
+		{
+			Name:        "no build arguments recorded",
+			Statement:   &Statement{BuilderID: builderID},
+			Expectation: []string{"was built without build argument version"},
+		},
+		{
+			Name:        "redacted value",
+			Statement:   &Statement{BuilderID: builderID, Parameters: map[string]interface{}{"buildArgs": map[string]interface{}{"version": blazedock.RedactedValue}}},
+			Expectation: []string{"build argument version is secret and cannot be asserted"},
+		},
+		{
+			Name:      "not built by blazedock",
+			Statement: &Statement{BuilderID: "https://example.com/builder"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var act []string
+			for _, v := range AssertBuildArg("version", "1.0").Run(test.Statement) {
+				act = append(act, v.Desc)
+			}
+
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("AssertBuildArg() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAssertReproducible(t *testing.T) {
+	subject := func(name, digest string) in_toto.Subject {
+		return in_toto.Subject{Name: name, Digest: map[string]string{"sha256": digest}}
+	}
+	rebuilt := []*Statement{
+		{
+			EntryPoint: "components/foo:lib",
+			Subject:    []in_toto.Subject{subject("a.txt", "aaa"), subject("b.txt", "bbb"), subject("c.txt", "ccc")},
+		},
+	}
+
+	tests := []struct {
+		Name        string
+		Statement   *Statement
+		Expectation []string
+	}{
+		{
+			Name: "identical",
+			Statement: &Statement{
+				EntryPoint: "components/foo:lib",
+				Subject:    []in_toto.Subject{subject("c.txt", "ccc"), subject("a.txt", "aaa"), subject("b.txt", "bbb")},
+			},
+		},
+		{
+			Name: "different digests and names",
+			Statement: &Statement{
+				EntryPoint: "components/foo:lib",
+				Subject:    []in_toto.Subject{subject("a.txt", "aaa"), subject("b.txt", "xxx"), subject("d.txt", "ddd")},
+			},
+			Expectation: []string{"rebuild produced different subjects: b.txt, c.txt, d.txt"},
+		},
+		{
+			Name: "not rebuilt",
+			Statement: &Statement{
+				EntryPoint: "components/bar:lib",
+				Subject:    []in_toto.Subject{subject("a.txt", "xxx")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var act []string
+			for _, v := range AssertReproducible(rebuilt).Run(test.Statement) {
+				act = append(act, v.Desc)
+			}
+
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("AssertReproducible() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
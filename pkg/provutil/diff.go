@@ -0,0 +1,167 @@
+package provutil
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+)
+
+// BundleDiff describes how the statements of two attestation bundles differ. Statements are matched by entry point,
+// i.e. by the package they were produced for.
+type BundleDiff struct {
+	Added   []string        `json:"added,omitempty" yaml:"added,omitempty"`
+	Removed []string        `json:"removed,omitempty" yaml:"removed,omitempty"`
+	Changed []StatementDiff `json:"changed,omitempty" yaml:"changed,omitempty"`
+}
+
+// Empty returns true if the bundles did not differ
+func (d *BundleDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// StatementDiff describes how two statements for the same entry point differ
+type StatementDiff struct {
+	EntryPoint string            `json:"entryPoint" yaml:"entryPoint"`
+	BuilderID  *ValueChange      `json:"builderID,omitempty" yaml:"builderID,omitempty"`
+	Materials  []MaterialChange  `json:"materials,omitempty" yaml:"materials,omitempty"`
+	Parameters []ParameterChange `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+}
+
+// MaterialChangeKind classifies the change of a material
+type MaterialChangeKind string
+
+const (
+	// MaterialAdded marks materials only present in the second statement
+	MaterialAdded MaterialChangeKind = "added"
+	// MaterialRemoved marks materials only present in the first statement
+	MaterialRemoved MaterialChangeKind = "removed"
+	// MaterialDigestChanged marks materials present in both statements with different digests
+	MaterialDigestChanged MaterialChangeKind = "digest-changed"
+)
+
+// MaterialChange is a material which was added, removed or whose digest changed
+type MaterialChange struct {
+	URI  string             `json:"uri" yaml:"uri"`
+	Kind MaterialChangeKind `json:"kind" yaml:"kind"`
+	Old  common.DigestSet   `json:"old,omitempty" yaml:"old,omitempty"`
+	New  common.DigestSet   `json:"new,omitempty" yaml:"new,omitempty"`
+}
+
+// ValueChange is a value which differs between two statements
+type ValueChange struct {
+	Old string `json:"old" yaml:"old"`
+	New string `json:"new" yaml:"new"`
+}
+
+// ParameterChange is an invocation parameter which differs between two statements. Old or New are nil if
+// the parameter is missing in the respective statement.
+type ParameterChange struct {
+	Name string      `json:"name" yaml:"name"`
+	Old  interface{} `json:"old,omitempty" yaml:"old,omitempty"`
+	New  interface{} `json:"new,omitempty" yaml:"new,omitempty"`
+}
+
+// DiffBundles compares the statements of two attestation bundles
+func DiffBundles(a, b []*Statement) *BundleDiff {
+	var (
+		res  BundleDiff
+		idxA = indexStatements(a)
+		idxB = indexStatements(b)
+	)
+	for ep, sa := range idxA {
+		sb, ok := idxB[ep]
+		if !ok {
+			res.Removed = append(res.Removed, ep)
+			continue
+		}
+		if d := DiffStatements(sa, sb); d != nil {
+			res.Changed = append(res.Changed, *d)
+		}
+	}
+	for ep := range idxB {
+		if _, ok := idxA[ep]; !ok {
+			res.Added = append(res.Added, ep)
+		}
+	}
+
+	sort.Strings(res.Added)
+	sort.Strings(res.Removed)
+	sort.Slice(res.Changed, func(i, j int) bool { return res.Changed[i].EntryPoint < res.Changed[j].EntryPoint })
+	return &res
+}
+
+// indexStatements maps statements to their entry point. Bundles can contain the same statement more than once,
+// in which case the first one wins.
+func indexStatements(stmts []*Statement) map[string]*Statement {
+	res := make(map[string]*Statement, len(stmts))
+	for _, s := range stmts {
+		if _, exists := res[s.EntryPoint]; exists {
+			continue
+		}
+		res[s.EntryPoint] = s
+	}
+	return res
+}
+
+// DiffStatements compares two statements. Returns nil if their builder, materials and parameters are the same.
+func DiffStatements(a, b *Statement) *StatementDiff {
+	res := StatementDiff{EntryPoint: b.EntryPoint}
+	if a.BuilderID != b.BuilderID {
+		res.BuilderID = &ValueChange{Old: a.BuilderID, New: b.BuilderID}
+	}
+
+	matA, matB := make(map[string]common.DigestSet), make(map[string]common.DigestSet)
+	for _, m := range a.Materials {
+		matA[m.URI] = m.Digest
+	}
+	for _, m := range b.Materials {
+		matB[m.URI] = m.Digest
+	}
+	for uri, da := range matA {
+		db, ok := matB[uri]
+		if !ok {
+			res.Materials = append(res.Materials, MaterialChange{URI: uri, Kind: MaterialRemoved, Old: da})
+		} else if !reflect.DeepEqual(da, db) {
+			res.Materials = append(res.Materials, MaterialChange{URI: uri, Kind: MaterialDigestChanged, Old: da, New: db})
+		}
+	}
+	for uri, db := range matB {
+		if _, ok := matA[uri]; !ok {
+			res.Materials = append(res.Materials, MaterialChange{URI: uri, Kind: MaterialAdded, New: db})
+		}
+	}
+	sort.Slice(res.Materials, func(i, j int) bool { return res.Materials[i].URI < res.Materials[j].URI })
+
+	names := make(map[string]struct{})
+	for n := range a.Parameters {
+		names[n] = struct{}{}
+	}
+	for n := range b.Parameters {
+		names[n] = struct{}{}
+	}
+	for n := range names {
+		pa, pb := a.Parameters[n], b.Parameters[n]
+		if sameParameter(pa, pb) {
+			continue
+		}
+		res.Parameters = append(res.Parameters, ParameterChange{Name: n, Old: pa, New: pb})
+	}
+	sort.Slice(res.Parameters, func(i, j int) bool { return res.Parameters[i].Name < res.Parameters[j].Name })
+
+	if res.BuilderID == nil && len(res.Materials) == 0 && len(res.Parameters) == 0 {
+		return nil
+	}
+	return &res
+}
+
+// sameParameter compares parameters by their JSON encoding, as that's what they were decoded from
+func sameParameter(a, b interface{}) bool {
+	ja, erra := json.Marshal(a)
+	jb, errb := json.Marshal(b)
+	if erra != nil || errb != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return string(ja) == string(jb)
+}
@@ -0,0 +1,86 @@
+package provutil
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+)
+
+func TestDiffBundles(t *testing.T) {
+	material := func(uri, digest string) common.ProvenanceMaterial {
+		return common.ProvenanceMaterial{URI: uri, Digest: common.DigestSet{"sha256": digest}}
+	}
+
+	tests := []struct {
+		Name        string
+		A, B        []*Statement
+		Expectation *BundleDiff
+	}{
+		{
+			Name: "identical",
+			A: []*Statement{
+				{EntryPoint: "comp:app", BuilderID: "blazedock", Materials: []common.ProvenanceMaterial{material("git+https://a", "1")}},
+			},
+			B: []*Statement{
+				{EntryPoint: "comp:app", BuilderID: "blazedock", Materials: []common.ProvenanceMaterial{material("git+https://a", "1")}},
+			},
+			Expectation: &BundleDiff{},
+		},
+		{
+			Name: "added and removed statements",
+			A: []*Statement{
+				{EntryPoint: "comp:app"},
+				{EntryPoint: "comp:old"},
+			},
+			B: []*Statement{
+				{EntryPoint: "comp:app"},
+				{EntryPoint: "comp:new"},
+			},
+			Expectation: &BundleDiff{Added: []string{"comp:new"}, Removed: []string{"comp:old"}},
+		},
+		{
+			Name: "changed statement",
+			A: []*Statement{{
+				EntryPoint: "comp:app",
+				BuilderID:  "blazedock:1",
+				Materials: []common.ProvenanceMaterial{
+					material("file://a.go", "1"),
+					material("file://b.go", "2"),
+				},
+				Parameters: map[string]interface{}{"args": []interface{}{"build"}, "removed": "x"},
+			}},
+			B: []*Statement{{
+				EntryPoint: "comp:app",
+				BuilderID:  "blazedock:2",
+				Materials: []common.ProvenanceMaterial{
+					material("file://a.go", "3"),
+					material("file://c.go", "4"),
+				},
+				Parameters: map[string]interface{}{"args": []interface{}{"build", "--jobs"}},
+			}},
+			Expectation: &BundleDiff{Changed: []StatementDiff{{
+				EntryPoint: "comp:app",
+				BuilderID:  &ValueChange{Old: "blazedock:1", New: "blazedock:2"},
+				Materials: []MaterialChange{
+					{URI: "file://a.go", Kind: MaterialDigestChanged, Old: common.DigestSet{"sha256": "1"}, New: common.DigestSet{"sha256": "3"}},
+					{URI: "file://b.go", Kind: MaterialRemoved, Old: common.DigestSet{"sha256": "2"}},
+					{URI: "file://c.go", Kind: MaterialAdded, New: common.DigestSet{"sha256": "4"}},
+				},
+				Parameters: []ParameterChange{
+					{Name: "args", Old: []interface{}{"build"}, New: []interface{}{"build", "--jobs"}},
+					{Name: "removed", Old: "x"},
+				},
+			}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act := DiffBundles(test.A, test.B)
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("DiffBundles() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package provutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/khulnasoft/blazedock/pkg/sigstore"
+	"sigs.k8s.io/bom/pkg/provenance"
+)
+
+// DefaultRekorURL is the public Rekor instance operated by the Sigstore project
+const DefaultRekorURL = sigstore.DefaultRekorURL
+
+// AssertInRekor ensures all bundle entries are signed and their signatures are recorded in the Rekor transparency
+// log at rekorURL. Entries are looked up by the sha256 of their payload, which Rekor indexes for in-toto and DSSE
+// entries. Log entries only count if their signed entry timestamp and inclusion proof verify against the log's key.
+func AssertInRekor(rekorURL string) *Assertion {
+	rekor := sigstore.NewRekor(rekorURL)
+	return &Assertion{
+		Name:        "in-rekor",
+		Description: "ensures all bundle entries are signed and recorded in the Rekor transparency log at " + rekor.URL,
+		RunBundle: func(bundle *provenance.Envelope) []Violation {
+			if len(bundle.Signatures) == 0 {
+				return []Violation{{Desc: "entry is not signed and cannot be recorded in Rekor"}}
+			}
+
+			payload, err := base64.StdEncoding.DecodeString(bundle.Payload)
+			if err != nil {
+				return []Violation{{Desc: "assertion error: " + err.Error()}}
+			}
+			hash := sha256.Sum256(payload)
+			digest := "sha256:" + hex.EncodeToString(hash[:])
+
+			entries, err := rekor.Entries(context.Background(), hash)
+			if err != nil {
+				return []Violation{{Desc: "cannot query Rekor at " + rekor.URL + ": " + err.Error()}}
+			}
+			for _, s := range bundle.Signatures {
+				raw, err := json.Marshal(s)
+				if err != nil {
+					return []Violation{{Desc: "assertion error: " + err.Error()}}
+				}
+				var sig sigstore.Signature
+				err = json.Unmarshal(raw, &sig)
+				if err != nil {
+					return []Violation{{Desc: "assertion error: " + err.Error()}}
+				}
+				for _, e := range entries {
+					if e.Records(sig) {
+						return nil
+					}
+				}
+			}
+			return []Violation{{Desc: "entry with payload " + digest + " is not recorded in Rekor at " + rekor.URL}}
+		},
+	}
+}
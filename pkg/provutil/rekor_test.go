@@ -0,0 +1,109 @@
+package provutil
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sigstoretesting "github.com/khulnasoft/blazedock/pkg/sigstore/testing"
+	"sigs.k8s.io/bom/pkg/provenance"
+)
+
+func TestAssertInRekor(t *testing.T) {
+	const (
+		recordedPayload = `{"recorded":true}`
+		otherPayload    = `{"recorded":"by someone else"}`
+		sig             = "c2ln"
+	)
+
+	rekor, err := sigstoretesting.NewFakeRekor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rekor.Add([]byte(recordedPayload), []byte(`{"spec":{"signatures":[{"signature":"`+sig+`"}]}}`), time.Now())
+	rekor.Add([]byte(otherPayload), []byte(`{"spec":{"signatures":[{"signature":"b3RoZXI="}]}}`), time.Now())
+	srv := httptest.NewServer(rekor)
+	defer srv.Close()
+
+	// forged serves the entries of the log, but claims a different public key
+	forger, err := sigstoretesting.NewFakeRekor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/log/publicKey" {
+			forger.ServeHTTP(w, r)
+			return
+		}
+		rekor.ServeHTTP(w, r)
+	}))
+	defer forged.Close()
+
+	tests := []struct {
+		Name        string
+		RekorURL    string
+		Payload     string
+		Unsigned    bool
+		Expectation string
+	}{
+		{
+			Name:     "recorded",
+			RekorURL: srv.URL,
+			Payload:  recordedPayload,
+		},
+		{
+			Name:        "not recorded",
+			RekorURL:    srv.URL,
+			Payload:     `{"recorded":false}`,
+			Expectation: "entry with payload sha256:",
+		},
+		{
+			Name:        "recorded with a different signature",
+			RekorURL:    srv.URL,
+			Payload:     otherPayload,
+			Expectation: "entry with payload sha256:",
+		},
+		{
+			Name:        "unsigned",
+			RekorURL:    srv.URL,
+			Payload:     recordedPayload,
+			Unsigned:    true,
+			Expectation: "entry is not signed",
+		},
+		{
+			Name:        "unverifiable log entries",
+			RekorURL:    forged.URL,
+			Payload:     recordedPayload,
+			Expectation: "cannot query Rekor at ",
+		},
+		{
+			Name:        "unreachable",
+			RekorURL:    srv.URL + "/does-not-exist",
+			Payload:     recordedPayload,
+			Expectation: "cannot query Rekor at ",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			env := &provenance.Envelope{Payload: base64.StdEncoding.EncodeToString([]byte(test.Payload))}
+			if !test.Unsigned {
+				env.Signatures = []interface{}{map[string]string{"sig": sig}}
+			}
+
+			violations := AssertInRekor(test.RekorURL).RunBundle(env)
+			if test.Expectation == "" {
+				if len(violations) != 0 {
+					t.Errorf("expected no violations, got %v", violations)
+				}
+				return
+			}
+			if len(violations) != 1 || !strings.HasPrefix(violations[0].Desc, test.Expectation) {
+				t.Errorf("expected a violation starting with %q, got %v", test.Expectation, violations)
+			}
+		})
+	}
+}
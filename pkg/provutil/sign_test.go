@@ -0,0 +1,116 @@
+package provutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"sigs.k8s.io/bom/pkg/provenance"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func TestAssertSignedWith(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const payload = `{"_type":"https://in-toto.io/Statement/v0.1"}`
+
+	tests := []struct {
+		Name        string
+		SignWith    crypto.PrivateKey
+		VerifyWith  crypto.PrivateKey
+		Tamper      func(env *provenance.Envelope)
+		Expectation bool
+	}{
+		{Name: "ed25519", SignWith: ed25519Key, VerifyWith: ed25519Key, Expectation: true},
+		{Name: "ecdsa", SignWith: ecdsaKey, VerifyWith: ecdsaKey, Expectation: true},
+		{Name: "different key", SignWith: ed25519Key, VerifyWith: otherKey},
+		{
+			Name:       "tampered payload",
+			SignWith:   ed25519Key,
+			VerifyWith: ed25519Key,
+			Tamper: func(env *provenance.Envelope) {
+				env.Payload = base64.StdEncoding.EncodeToString([]byte(`{"_type":"tampered"}`))
+			},
+		},
+		{
+			Name:       "tampered payload type",
+			SignWith:   ecdsaKey,
+			VerifyWith: ecdsaKey,
+			Tamper: func(env *provenance.Envelope) {
+				env.PayloadType = "application/json"
+			},
+		},
+		{
+			Name:       "unsigned",
+			SignWith:   ed25519Key,
+			VerifyWith: ed25519Key,
+			Tamper: func(env *provenance.Envelope) {
+				env.Signatures = nil
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			signKey := loadTestKey(t, test.SignWith)
+			sig, err := blazedock.SignEnvelopePayload(in_toto.PayloadType, []byte(payload), signKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			env := &provenance.Envelope{
+				PayloadType: in_toto.PayloadType,
+				Payload:     base64.StdEncoding.EncodeToString([]byte(payload)),
+				Signatures:  []interface{}{sig},
+			}
+			if test.Tamper != nil {
+				test.Tamper(env)
+			}
+
+			violations := AssertSignedWith(loadTestKey(t, test.VerifyWith)).RunBundle(env)
+			if act := len(violations) == 0; act != test.Expectation {
+				t.Errorf("expected signature to be valid: %v, got violations %v", test.Expectation, violations)
+			}
+		})
+	}
+}
+
+// loadTestKey writes the key to a PEM file and loads it like blazedock loads keys from BLAZEDOCK_PROVENANCE_KEYPATH
+func loadTestKey(t *testing.T, key crypto.PrivateKey) in_toto.Key {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := filepath.Join(t.TempDir(), "key.pem")
+	err = os.WriteFile(fn, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res in_toto.Key
+	err = res.LoadKeyDefaults(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
@@ -0,0 +1,74 @@
+package provutil
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	slsav1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+)
+
+// Statement is the part of a provenance statement assertions are made about.
+// It is independent of the SLSA predicate version the statement was produced with.
+type Statement struct {
+	PredicateType string
+	Subject       []in_toto.Subject
+	BuilderID     string
+	EntryPoint    string
+	Materials     []common.ProvenanceMaterial
+	// Parameters are the invocation parameters of the build, i.e. the external parameters in SLSA v1.0
+	Parameters map[string]interface{}
+}
+
+// ParseStatement decodes the payload of an in-toto envelope carrying either a SLSA v0.2 or v1.0 provenance predicate.
+// Statements without predicate type are treated as v0.2 statements.
+func ParseStatement(payload []byte) (*Statement, error) {
+	var hdr in_toto.StatementHeader
+	err := json.Unmarshal(payload, &hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	switch hdr.PredicateType {
+	case "", slsa.PredicateSLSAProvenance:
+		var stmt in_toto.ProvenanceStatementSLSA02
+		err = json.Unmarshal(payload, &stmt)
+		if err != nil {
+			return nil, err
+		}
+		return &Statement{
+			PredicateType: hdr.PredicateType,
+			Subject:       stmt.Subject,
+			BuilderID:     stmt.Predicate.Builder.ID,
+			EntryPoint:    stmt.Predicate.Invocation.ConfigSource.EntryPoint,
+			Materials:     stmt.Predicate.Materials,
+			Parameters:    toParameters(stmt.Predicate.Invocation.Parameters),
+		}, nil
+	case slsav1.PredicateSLSAProvenance:
+		var stmt in_toto.ProvenanceStatementSLSA1
+		err = json.Unmarshal(payload, &stmt)
+		if err != nil {
+			return nil, err
+		}
+		res := &Statement{
+			PredicateType: hdr.PredicateType,
+			Subject:       stmt.Subject,
+			BuilderID:     stmt.Predicate.RunDetails.Builder.ID,
+		}
+		res.Parameters = toParameters(stmt.Predicate.BuildDefinition.ExternalParameters)
+		res.EntryPoint, _ = res.Parameters["entryPoint"].(string)
+		for _, dep := range stmt.Predicate.BuildDefinition.ResolvedDependencies {
+			res.Materials = append(res.Materials, common.ProvenanceMaterial{URI: dep.URI, Digest: dep.Digest})
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unsupported predicate type %s", hdr.PredicateType)
+	}
+}
+
+func toParameters(params interface{}) map[string]interface{} {
+	res, _ := params.(map[string]interface{})
+	return res
+}
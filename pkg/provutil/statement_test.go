@@ -0,0 +1,94 @@
+package provutil
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+)
+
+func TestParseStatement(t *testing.T) {
+	type Expectation struct {
+		Statement *Statement
+		Error     string
+	}
+
+	tests := []struct {
+		Name        string
+		Payload     string
+		Expectation Expectation
+	}{
+		{
+			Name: "slsa v0.2",
+			Payload: `{
+				"_type": "https://in-toto.io/Statement/v0.1",
+				"predicateType": "https://slsa.dev/provenance/v0.2",
+				"subject": [{"name": "foo", "digest": {"sha256": "abc"}}],
+				"predicate": {
+					"builder": {"id": "github.com/khulnasoft/blazedock:dev"},
+					"invocation": {"configSource": {"entryPoint": "comp:pkg"}},
+					"materials": [{"uri": "git+https://github.com/khulnasoft/blazedock", "digest": {"sha256": "def"}}]
+				}
+			}`,
+			Expectation: Expectation{
+				Statement: &Statement{
+					PredicateType: "https://slsa.dev/provenance/v0.2",
+					Subject:       []in_toto.Subject{{Name: "foo", Digest: common.DigestSet{"sha256": "abc"}}},
+					BuilderID:     "github.com/khulnasoft/blazedock:dev",
+					EntryPoint:    "comp:pkg",
+					Materials:     []common.ProvenanceMaterial{{URI: "git+https://github.com/khulnasoft/blazedock", Digest: common.DigestSet{"sha256": "def"}}},
+				},
+			},
+		},
+		{
+			Name: "slsa v1.0",
+			Payload: `{
+				"_type": "https://in-toto.io/Statement/v1",
+				"predicateType": "https://slsa.dev/provenance/v1",
+				"subject": [{"name": "foo", "digest": {"sha256": "abc"}}],
+				"predicate": {
+					"buildDefinition": {
+						"buildType": "https://github.com/khulnasoft/blazedock/build@go:1",
+						"externalParameters": {"entryPoint": "comp:pkg", "args": ["blazedock", "build"]},
+						"resolvedDependencies": [{"uri": "git+https://github.com/khulnasoft/blazedock", "digest": {"sha256": "def"}}]
+					},
+					"runDetails": {"builder": {"id": "github.com/khulnasoft/blazedock:dev"}}
+				}
+			}`,
+			Expectation: Expectation{
+				Statement: &Statement{
+					PredicateType: "https://slsa.dev/provenance/v1",
+					Subject:       []in_toto.Subject{{Name: "foo", Digest: common.DigestSet{"sha256": "abc"}}},
+					BuilderID:     "github.com/khulnasoft/blazedock:dev",
+					EntryPoint:    "comp:pkg",
+					Materials:     []common.ProvenanceMaterial{{URI: "git+https://github.com/khulnasoft/blazedock", Digest: common.DigestSet{"sha256": "def"}}},
+					Parameters:    map[string]interface{}{"entryPoint": "comp:pkg", "args": []interface{}{"blazedock", "build"}},
+				},
+			},
+		},
+		{
+			Name:    "unsupported predicate",
+			Payload: `{"predicateType": "https://spdx.dev/Document"}`,
+			Expectation: Expectation{
+				Error: "unsupported predicate type https://spdx.dev/Document",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var act Expectation
+			stmt, err := ParseStatement([]byte(test.Payload))
+			if err != nil {
+				act.Error = err.Error()
+			} else {
+				act.Statement = stmt
+			}
+
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("ParseStatement() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,419 @@
+package sigstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+// DefaultRekorURL is the public Rekor instance operated by the Sigstore project
+const DefaultRekorURL = "https://rekor.sigstore.dev"
+
+// Rekor looks up entries in a Rekor transparency log. Every entry it returns has been verified against
+// the log's public key: the signed entry timestamp vouches for the time the entry was integrated, and the
+// inclusion proof is checked against a checkpoint signed by the log.
+type Rekor struct {
+	URL    string
+	Client *http.Client
+
+	once sync.Once
+	key  *ecdsa.PublicKey
+	err  error
+}
+
+// NewRekor creates a Rekor client which fetches the public key of the log on first use
+func NewRekor(rekorURL string) *Rekor {
+	if rekorURL == "" {
+		rekorURL = DefaultRekorURL
+	}
+	return &Rekor{
+		URL:    strings.TrimSuffix(rekorURL, "/"),
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// NewStaticRekor creates a Rekor client for a log with a known public key
+func NewStaticRekor(rekorURL string, key *ecdsa.PublicKey) *Rekor {
+	res := NewRekor(rekorURL)
+	res.key = key
+	res.once.Do(func() {})
+	return res
+}
+
+// LogEntry is a verified Rekor entry
+type LogEntry struct {
+	UUID           string
+	Body           []byte
+	IntegratedTime time.Time
+}
+
+// Records returns true if the entry records sig, i.e. it contains the signature or the leaf certificate it was made with
+func (e *LogEntry) Records(sig Signature) bool {
+	var leaf []byte
+	if blk, _ := pem.Decode([]byte(sig.Cert)); blk != nil {
+		leaf = blk.Bytes
+	}
+
+	var body interface{}
+	if json.Unmarshal(e.Body, &body) != nil {
+		return false
+	}
+	var found bool
+	walkStrings(body, func(s string) {
+		// Rekor entry types base64 encode signatures and PEM encoded certificates, some of them twice
+		candidates := []string{s}
+		if dec, err := base64.StdEncoding.DecodeString(s); err == nil {
+			candidates = append(candidates, string(dec))
+		}
+		for _, c := range candidates {
+			if sig.Sig != "" && c == sig.Sig {
+				found = true
+			}
+			if blk, _ := pem.Decode([]byte(c)); blk != nil && leaf != nil && bytes.Equal(blk.Bytes, leaf) {
+				found = true
+			}
+		}
+	})
+	return found
+}
+
+func walkStrings(v interface{}, f func(string)) {
+	switch v := v.(type) {
+	case string:
+		f(v)
+	case []interface{}:
+		for _, e := range v {
+			walkStrings(e, f)
+		}
+	case map[string]interface{}:
+		for _, e := range v {
+			walkStrings(e, f)
+		}
+	}
+}
+
+// Entries returns all entries which Rekor indexes under the sha256 digest of a payload.
+// Fails if any of the entries cannot be verified.
+func (r *Rekor) Entries(ctx context.Context, payloadDigest [sha256.Size]byte) ([]LogEntry, error) {
+	key, err := r.publicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var uuids []string
+	err = r.do(ctx, http.MethodPost, "/api/v1/index/retrieve", map[string]string{"hash": "sha256:" + hex.EncodeToString(payloadDigest[:])}, &uuids)
+	if err != nil {
+		return nil, fmt.Errorf("index lookup failed: %w", err)
+	}
+
+	res := make([]LogEntry, 0, len(uuids))
+	for _, uuid := range uuids {
+		var entries map[string]rekorEntry
+		err = r.do(ctx, http.MethodGet, "/api/v1/log/entries/"+url.PathEscape(uuid), nil, &entries)
+		if err != nil {
+			return nil, fmt.Errorf("entry %s lookup failed: %w", uuid, err)
+		}
+		for id, e := range entries {
+			entry, err := e.verify(key)
+			if err != nil {
+				return nil, fmt.Errorf("entry %s is invalid: %w", id, err)
+			}
+			entry.UUID = id
+			res = append(res, *entry)
+		}
+	}
+	return res, nil
+}
+
+// Upload records a DSSE signature over payload in the log. The entry holds the signature and the leaf certificate
+// it was made with, and Rekor indexes it under the sha256 digest of payload.
+func (r *Rekor) Upload(ctx context.Context, payloadType string, payload []byte, sig Signature) error {
+	blk, _ := pem.Decode([]byte(sig.Cert))
+	if blk == nil {
+		return fmt.Errorf("signature carries no certificate")
+	}
+	env, err := json.Marshal(dsse.Envelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []dsse.Signature{{KeyID: sig.KeyID, Sig: sig.Sig}},
+	})
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Spec       struct {
+			ProposedContent struct {
+				Envelope  string   `json:"envelope"`
+				Verifiers []string `json:"verifiers"`
+			} `json:"proposedContent"`
+		} `json:"spec"`
+	}
+	req.APIVersion, req.Kind = "0.0.1", "dsse"
+	req.Spec.ProposedContent.Envelope = string(env)
+	req.Spec.ProposedContent.Verifiers = []string{base64.StdEncoding.EncodeToString(pem.EncodeToMemory(blk))}
+
+	var entries map[string]json.RawMessage
+	err = r.do(ctx, http.MethodPost, "/api/v1/log/entries", req, &entries)
+	if err != nil {
+		return fmt.Errorf("cannot record signature in %s: %w", r.URL, err)
+	}
+	return nil
+}
+
+func (r *Rekor) publicKey(ctx context.Context) (*ecdsa.PublicKey, error) {
+	r.once.Do(func() {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL+"/api/v1/log/publicKey", nil)
+		if err != nil {
+			r.err = err
+			return
+		}
+		resp, err := r.Client.Do(req)
+		if err != nil {
+			r.err = fmt.Errorf("cannot fetch public key of %s: %w", r.URL, err)
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			r.err = fmt.Errorf("cannot fetch public key of %s: %s", r.URL, resp.Status)
+			return
+		}
+		raw, err := io.ReadAll(resp.Body)
+		if err != nil {
+			r.err = fmt.Errorf("cannot fetch public key of %s: %w", r.URL, err)
+			return
+		}
+		r.key, r.err = parsePublicKey(raw)
+	})
+	return r.key, r.err
+}
+
+func parsePublicKey(raw []byte) (*ecdsa.PublicKey, error) {
+	blk, _ := pem.Decode(raw)
+	if blk == nil {
+		return nil, fmt.Errorf("public key is not PEM encoded")
+	}
+	pub, err := x509.ParsePKIXPublicKey(blk.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+	return key, nil
+}
+
+func (r *Rekor) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var rd io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.URL+path, rd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("%s %s returned %s", method, path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// rekorEntry is a log entry as returned by the Rekor API
+type rekorEntry struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+	Verification   struct {
+		InclusionProof *struct {
+			Checkpoint string   `json:"checkpoint"`
+			Hashes     []string `json:"hashes"`
+			LogIndex   int64    `json:"logIndex"`
+			RootHash   string   `json:"rootHash"`
+			TreeSize   int64    `json:"treeSize"`
+		} `json:"inclusionProof"`
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"verification"`
+}
+
+// verify checks the signed entry timestamp and inclusion proof of the entry
+func (e *rekorEntry) verify(key *ecdsa.PublicKey) (*LogEntry, error) {
+	body, err := base64.StdEncoding.DecodeString(e.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode body: %w", err)
+	}
+
+	// the signed entry timestamp is a signature over the canonical JSON of these fields
+	set, err := json.Marshal(struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+	}{e.Body, e.IntegratedTime, e.LogID, e.LogIndex})
+	if err != nil {
+		return nil, err
+	}
+	setSig, err := base64.StdEncoding.DecodeString(e.Verification.SignedEntryTimestamp)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode signed entry timestamp: %w", err)
+	}
+	digest := sha256.Sum256(set)
+	if !ecdsa.VerifyASN1(key, digest[:], setSig) {
+		return nil, fmt.Errorf("invalid signed entry timestamp")
+	}
+
+	proof := e.Verification.InclusionProof
+	if proof == nil {
+		return nil, fmt.Errorf("no inclusion proof")
+	}
+	root, err := hex.DecodeString(proof.RootHash)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode root hash: %w", err)
+	}
+	err = verifyCheckpoint(key, proof.Checkpoint, proof.TreeSize, root)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([][]byte, 0, len(proof.Hashes))
+	for _, h := range proof.Hashes {
+		raw, err := hex.DecodeString(h)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode inclusion proof: %w", err)
+		}
+		hashes = append(hashes, raw)
+	}
+	err = verifyInclusion(proof.LogIndex, proof.TreeSize, leafHash(body), hashes, root)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LogEntry{Body: body, IntegratedTime: time.Unix(e.IntegratedTime, 0)}, nil
+}
+
+// verifyCheckpoint verifies a checkpoint in signed note format, signed by the log, commits to root at treeSize
+func verifyCheckpoint(key *ecdsa.PublicKey, checkpoint string, treeSize int64, root []byte) error {
+	text, sigs, ok := strings.Cut(checkpoint, "\n\n")
+	if !ok {
+		return fmt.Errorf("checkpoint is malformed")
+	}
+	text += "\n"
+
+	var signed bool
+	for _, line := range strings.Split(strings.TrimSpace(sigs), "\n") {
+		// signature lines are "— <origin> <base64 of key hint and signature>"
+		idx := strings.LastIndex(line, " ")
+		if idx < 0 {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(line[idx+1:])
+		if err != nil || len(raw) <= 4 {
+			continue
+		}
+		digest := sha256.Sum256([]byte(text))
+		if ecdsa.VerifyASN1(key, digest[:], raw[4:]) {
+			signed = true
+			break
+		}
+	}
+	if !signed {
+		return fmt.Errorf("checkpoint is not signed by the log")
+	}
+
+	lines := strings.Split(text, "\n")
+	if len(lines) < 3 {
+		return fmt.Errorf("checkpoint is malformed")
+	}
+	size, err := strconv.ParseInt(lines[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("checkpoint is malformed: %w", err)
+	}
+	hash, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil {
+		return fmt.Errorf("checkpoint is malformed: %w", err)
+	}
+	if size != treeSize || !bytes.Equal(hash, root) {
+		return fmt.Errorf("inclusion proof does not match the checkpoint")
+	}
+	return nil
+}
+
+// leafHash is the RFC 6962 hash of a Merkle tree leaf
+func leafHash(data []byte) []byte {
+	h := sha256.Sum256(append([]byte{0}, data...))
+	return h[:]
+}
+
+func nodeHash(left, right []byte) []byte {
+	buf := make([]byte, 0, 1+len(left)+len(right))
+	buf = append(buf, 1)
+	buf = append(buf, left...)
+	buf = append(buf, right...)
+	h := sha256.Sum256(buf)
+	return h[:]
+}
+
+// verifyInclusion checks the Merkle audit path of the leaf at index in a tree of size leaves
+// using the algorithm from RFC 9162, section 2.1.3.2
+func verifyInclusion(index, size int64, leaf []byte, proof [][]byte, root []byte) error {
+	if index < 0 || index >= size {
+		return fmt.Errorf("inclusion proof index %d is outside of tree of size %d", index, size)
+	}
+
+	fn, sn := index, size-1
+	res := leaf
+	for _, p := range proof {
+		if sn == 0 {
+			return fmt.Errorf("inclusion proof is too long")
+		}
+		if fn&1 == 1 || fn == sn {
+			res = nodeHash(p, res)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			res = nodeHash(res, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 {
+		return fmt.Errorf("inclusion proof is too short")
+	}
+	if !bytes.Equal(res, root) {
+		return fmt.Errorf("inclusion proof does not lead to the root hash")
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package sigstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	sigstoretesting "github.com/khulnasoft/blazedock/pkg/sigstore/testing"
+)
+
+func TestRekorEntries(t *testing.T) {
+	integrated := time.Unix(1700000000, 0)
+
+	// inclusion proofs differ for every position in trees of different sizes
+	for size := 1; size <= 9; size++ {
+		rekor, err := sigstoretesting.NewFakeRekor()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < size; i++ {
+			rekor.Add([]byte(fmt.Sprintf("payload %d", i)), []byte(fmt.Sprintf(`{"entry":%d}`, i)), integrated)
+		}
+		srv := httptest.NewServer(rekor)
+
+		for i := 0; i < size; i++ {
+			entries, err := NewRekor(srv.URL).Entries(context.Background(), sha256.Sum256([]byte(fmt.Sprintf("payload %d", i))))
+			if err != nil {
+				t.Errorf("Entries() of entry %d in log of size %d: %v", i, size, err)
+				continue
+			}
+			var act []string
+			for _, e := range entries {
+				if !e.IntegratedTime.Equal(integrated) {
+					t.Errorf("Entries() of entry %d in log of size %d: integrated time is %v", i, size, e.IntegratedTime)
+				}
+				act = append(act, string(e.Body))
+			}
+			if diff := cmp.Diff([]string{fmt.Sprintf(`{"entry":%d}`, i)}, act); diff != "" {
+				t.Errorf("Entries() of entry %d in log of size %d mismatch (-want +got):\n%s", i, size, diff)
+			}
+		}
+		srv.Close()
+	}
+}
+
+func TestRekorEntriesTampered(t *testing.T) {
+	const payload = "payload"
+
+	rekor, err := sigstoretesting.NewFakeRekor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		rekor.Add([]byte(payload), []byte(fmt.Sprintf(`{"entry":%d}`, i)), time.Now())
+	}
+
+	tests := []struct {
+		Name   string
+		Tamper func(entry map[string]interface{})
+	}{
+		{
+			Name:   "integrated time",
+			Tamper: func(entry map[string]interface{}) { entry["integratedTime"] = 1 },
+		},
+		{
+			Name: "inclusion proof",
+			Tamper: func(entry map[string]interface{}) {
+				proof := entry["verification"].(map[string]interface{})["inclusionProof"].(map[string]interface{})
+				hashes := proof["hashes"].([]interface{})
+				hashes[0] = fmt.Sprintf("%x", sha256.Sum256([]byte("forged")))
+			},
+		},
+		{
+			Name: "missing inclusion proof",
+			Tamper: func(entry map[string]interface{}) {
+				delete(entry["verification"].(map[string]interface{}), "inclusionProof")
+			},
+		},
+		{
+			Name: "root hash",
+			Tamper: func(entry map[string]interface{}) {
+				proof := entry["verification"].(map[string]interface{})["inclusionProof"].(map[string]interface{})
+				proof["rootHash"] = fmt.Sprintf("%x", sha256.Sum256([]byte("forged")))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rec := httptest.NewRecorder()
+				rekor.ServeHTTP(rec, r)
+				if r.Method != http.MethodGet || r.URL.Path == "/api/v1/log/publicKey" {
+					_, _ = w.Write(rec.Body.Bytes())
+					return
+				}
+
+				var entries map[string]map[string]interface{}
+				err := json.NewDecoder(bytes.NewReader(rec.Body.Bytes())).Decode(&entries)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				for _, e := range entries {
+					test.Tamper(e)
+				}
+				_ = json.NewEncoder(w).Encode(entries)
+			}))
+			defer srv.Close()
+
+			_, err := NewRekor(srv.URL).Entries(context.Background(), sha256.Sum256([]byte(payload)))
+			if err == nil {
+				t.Errorf("Entries() succeeded for tampered entries")
+			}
+		})
+	}
+}
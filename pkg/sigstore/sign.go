@@ -0,0 +1,303 @@
+// Package sigstore implements keyless signing and verification of DSSE envelopes using short-lived
+// certificates issued by a Sigstore Fulcio instance. Signatures are recorded in a Rekor transparency log.
+package sigstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+// DefaultFulcioURL is the public Fulcio instance operated by the Sigstore project
+const DefaultFulcioURL = "https://fulcio.sigstore.dev"
+
+// Signature is a DSSE signature which carries the certificate it was made with
+type Signature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+	// Cert is the PEM encoded certificate chain, leaf first
+	Cert string `json:"cert,omitempty"`
+}
+
+// TokenSource provides an OIDC identity token with the sigstore audience
+type TokenSource func(ctx context.Context) (string, error)
+
+// KeylessSigner signs payloads with an ephemeral key whose certificate is issued by Fulcio
+// for the identity of an OIDC token. It is safe for concurrent use.
+type KeylessSigner struct {
+	FulcioURL string
+	Token     TokenSource
+	Client    *http.Client
+	// Rekor is the transparency log signatures are recorded in. The log entry vouches for the time a signature was
+	// made, which verifiers need once the short-lived certificate has expired.
+	Rekor *Rekor
+
+	mu    sync.Mutex
+	key   *ecdsa.PrivateKey
+	chain []*x509.Certificate
+}
+
+// NewKeylessSigner creates a new signer. No key or certificate is obtained before the first signature.
+func NewKeylessSigner(fulcioURL string, token TokenSource) *KeylessSigner {
+	if fulcioURL == "" {
+		fulcioURL = DefaultFulcioURL
+	}
+	return &KeylessSigner{
+		FulcioURL: strings.TrimSuffix(fulcioURL, "/"),
+		Token:     token,
+		Client:    &http.Client{Timeout: 30 * time.Second},
+		Rekor:     NewRekor(DefaultRekorURL),
+	}
+}
+
+// Sign produces a signature over the DSSE pre-authentication encoding of payloadType and payload and records it
+// in the transparency log. Certificates are short-lived, hence a new one is requested once the current one is
+// about to expire.
+func (s *KeylessSigner) Sign(ctx context.Context, payloadType string, payload []byte) (*Signature, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.chain) == 0 || time.Until(s.chain[0].NotAfter) < time.Minute {
+		err := s.requestCertificate(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	digest := sha256.Sum256(dsse.PAE(payloadType, payload))
+	sig, err := ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	var chain bytes.Buffer
+	for _, c := range s.chain {
+		err = pem.Encode(&chain, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+		if err != nil {
+			return nil, err
+		}
+	}
+	res := &Signature{
+		Sig:  base64.StdEncoding.EncodeToString(sig),
+		Cert: chain.String(),
+	}
+	if s.Rekor != nil {
+		err = s.Rekor.Upload(ctx, payloadType, payload, *res)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// requestCertificate generates a new ephemeral key and has Fulcio issue a certificate for it
+func (s *KeylessSigner) requestCertificate(ctx context.Context) error {
+	token, err := s.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot obtain OIDC identity token: %w", err)
+	}
+	subject, err := tokenSubject(token)
+	if err != nil {
+		return err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	pub, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return err
+	}
+	// Fulcio expects proof that we possess the key in form of a signature over the token's subject
+	subjectDigest := sha256.Sum256([]byte(subject))
+	proof, err := ecdsa.SignASN1(rand.Reader, key, subjectDigest[:])
+	if err != nil {
+		return err
+	}
+
+	var req fulcioSigningCertRequest
+	req.Credentials.OIDCIdentityToken = token
+	req.PublicKeyRequest.PublicKey.Algorithm = "ECDSA"
+	req.PublicKeyRequest.PublicKey.Content = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+	req.PublicKeyRequest.ProofOfPossession = base64.StdEncoding.EncodeToString(proof)
+
+	var resp fulcioSigningCertResponse
+	err = s.do(ctx, http.MethodPost, "/api/v2/signingCert", req, &resp)
+	if err != nil {
+		return fmt.Errorf("cannot obtain signing certificate from %s: %w", s.FulcioURL, err)
+	}
+	certs := resp.SignedCertificateEmbeddedSct.Chain.Certificates
+	if len(certs) == 0 {
+		certs = resp.SignedCertificateDetachedSct.Chain.Certificates
+	}
+	chain, err := parseCertificates(strings.Join(certs, ""))
+	if err != nil {
+		return err
+	}
+	if len(chain) == 0 {
+		return fmt.Errorf("%s did not issue a certificate", s.FulcioURL)
+	}
+	if certKey, ok := chain[0].PublicKey.(*ecdsa.PublicKey); !ok || !certKey.Equal(key.Public()) {
+		return fmt.Errorf("%s issued a certificate for a different key", s.FulcioURL)
+	}
+
+	s.key, s.chain = key, chain
+	return nil
+}
+
+func (s *KeylessSigner) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var in io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		in = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.FulcioURL+path, in)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type fulcioSigningCertRequest struct {
+	Credentials struct {
+		OIDCIdentityToken string `json:"oidcIdentityToken"`
+	} `json:"credentials"`
+	PublicKeyRequest struct {
+		PublicKey struct {
+			Algorithm string `json:"algorithm"`
+			Content   string `json:"content"`
+		} `json:"publicKey"`
+		ProofOfPossession string `json:"proofOfPossession"`
+	} `json:"publicKeyRequest"`
+}
+
+type fulcioCertificateChain struct {
+	Chain struct {
+		Certificates []string `json:"certificates"`
+	} `json:"chain"`
+}
+
+type fulcioSigningCertResponse struct {
+	SignedCertificateEmbeddedSct fulcioCertificateChain `json:"signedCertificateEmbeddedSct"`
+	SignedCertificateDetachedSct fulcioCertificateChain `json:"signedCertificateDetachedSct"`
+}
+
+// tokenSubject extracts the sub claim of a JWT without verifying it - that's Fulcio's job
+func tokenSubject(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("OIDC identity token is not a JWT")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", fmt.Errorf("cannot decode OIDC identity token: %w", err)
+	}
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	err = json.Unmarshal(raw, &claims)
+	if err != nil {
+		return "", fmt.Errorf("cannot decode OIDC identity token: %w", err)
+	}
+	if claims.Subject == "" {
+		return "", fmt.Errorf("OIDC identity token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// TokenFromEnvironment obtains an OIDC identity token from the SIGSTORE_ID_TOKEN environment variable,
+// or from GitHub Actions if the workflow has the id-token: write permission.
+func TokenFromEnvironment(ctx context.Context) (string, error) {
+	if token := os.Getenv("SIGSTORE_ID_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	reqURL, reqToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"), os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if reqURL == "" || reqToken == "" {
+		return "", fmt.Errorf("no OIDC identity token available - set SIGSTORE_ID_TOKEN or run in GitHub Actions with id-token: write permission")
+	}
+	u, err := url.Parse(reqURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("audience", "sigstore")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+reqToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GitHub Actions token request returned %s", resp.Status)
+	}
+	var res struct {
+		Value string `json:"value"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return "", err
+	}
+	return res.Value, nil
+}
+
+func parseCertificates(pemData string) ([]*x509.Certificate, error) {
+	var (
+		res  []*x509.Certificate
+		rest = []byte(pemData)
+	)
+	for {
+		var blk *pem.Block
+		blk, rest = pem.Decode(rest)
+		if blk == nil {
+			break
+		}
+		if blk.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(blk.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse certificate: %w", err)
+		}
+		res = append(res, cert)
+	}
+	return res, nil
+}
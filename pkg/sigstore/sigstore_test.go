@@ -0,0 +1,237 @@
+package sigstore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	sigstoretesting "github.com/khulnasoft/blazedock/pkg/sigstore/testing"
+)
+
+const (
+	testIssuer  = "https://token.actions.githubusercontent.com"
+	testSubject = "ci@example.com"
+)
+
+// fakeFulcio issues certificates for testSubject to whoever asks
+type fakeFulcio struct {
+	ca    *x509.Certificate
+	caKey *ecdsa.PrivateKey
+}
+
+func newFakeFulcio(t *testing.T) *fakeFulcio {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake fulcio"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeFulcio{ca: ca, caKey: key}
+}
+
+func (f *fakeFulcio) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: f.ca.Raw}))
+	switch r.URL.Path {
+	case "/api/v2/trustBundle":
+		_, _ = w.Write([]byte(`{"chains": [{"certificates": [` + jsonString(caPEM) + `]}]}`))
+	case "/api/v2/signingCert":
+		var req fulcioSigningCertRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		blk, _ := pem.Decode([]byte(req.PublicKeyRequest.PublicKey.Content))
+		if blk == nil {
+			http.Error(w, "invalid public key", http.StatusBadRequest)
+			return
+		}
+		pub, err := x509.ParsePKIXPublicKey(blk.Bytes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		issuer, _ := asn1.MarshalWithParams(testIssuer, "utf8")
+		tmpl := &x509.Certificate{
+			SerialNumber:    big.NewInt(2),
+			NotBefore:       time.Now().Add(-time.Minute),
+			NotAfter:        time.Now().Add(10 * time.Minute),
+			KeyUsage:        x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+			EmailAddresses:  []string{testSubject},
+			ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: issuer}},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, f.ca, pub, f.caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		leafPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+		_, _ = w.Write([]byte(`{"signedCertificateEmbeddedSct": {"chain": {"certificates": [` + jsonString(leafPEM) + `, ` + jsonString(caPEM) + `]}}}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func jsonString(s string) string {
+	res, _ := json.Marshal(s)
+	return string(res)
+}
+
+func testToken(ctx context.Context) (string, error) {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub": "` + testSubject + `", "iss": "` + testIssuer + `"}`))
+	return "e30." + claims + ".c2ln", nil
+}
+
+func TestKeylessSignAndVerify(t *testing.T) {
+	const (
+		payloadType = "application/vnd.in-toto+json"
+		payload     = `{"_type":"https://in-toto.io/Statement/v0.1"}`
+	)
+
+	fulcio := newFakeFulcio(t)
+	srv := httptest.NewServer(fulcio)
+	defer srv.Close()
+	rekor, err := sigstoretesting.NewFakeRekor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rekorSrv := httptest.NewServer(rekor)
+	defer rekorSrv.Close()
+	emptyRekor, err := sigstoretesting.NewFakeRekor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	emptyRekorSrv := httptest.NewServer(emptyRekor)
+	defer emptyRekorSrv.Close()
+
+	signer := NewKeylessSigner(srv.URL, testToken)
+	signer.Rekor = NewRekor(rekorSrv.URL)
+	sig, err := signer.Sign(context.Background(), payloadType, []byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// late records the signature of another payload only once its certificate has expired
+	const latePayload = `{"_type":"late"}`
+	lateSigner := NewKeylessSigner(srv.URL, testToken)
+	lateSigner.Rekor = nil
+	lateSig, err := lateSigner.Sign(context.Background(), payloadType, []byte(latePayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rekor.Add([]byte(latePayload), []byte(`{"spec":{"signatures":[{"signature":"`+lateSig.Sig+`"}]}}`), time.Now().Add(time.Hour))
+
+	trustRoot := func(rekorURL string) *TrustRoot {
+		res := NewTrustRoot(srv.URL)
+		res.Rekor = NewRekor(rekorURL)
+		return res
+	}
+
+	type Expectation struct {
+		Identity *Identity
+		Error    bool
+	}
+	tests := []struct {
+		Name        string
+		TrustRoot   *TrustRoot
+		Signature   *Signature
+		PayloadType string
+		Payload     string
+		Expectation Expectation
+	}{
+		{
+			Name:        "valid",
+			TrustRoot:   trustRoot(rekorSrv.URL),
+			Signature:   sig,
+			PayloadType: payloadType,
+			Payload:     payload,
+			Expectation: Expectation{Identity: &Identity{Issuer: testIssuer, Subject: testSubject}},
+		},
+		{
+			Name:        "tampered payload",
+			TrustRoot:   trustRoot(rekorSrv.URL),
+			Signature:   sig,
+			PayloadType: payloadType,
+			Payload:     `{"_type":"tampered"}`,
+			Expectation: Expectation{Error: true},
+		},
+		{
+			Name:        "untrusted certificate",
+			TrustRoot:   NewStaticTrustRoot(x509.NewCertPool(), x509.NewCertPool(), NewRekor(rekorSrv.URL)),
+			Signature:   sig,
+			PayloadType: payloadType,
+			Payload:     payload,
+			Expectation: Expectation{Error: true},
+		},
+		{
+			Name:        "not recorded in the transparency log",
+			TrustRoot:   trustRoot(emptyRekorSrv.URL),
+			Signature:   sig,
+			PayloadType: payloadType,
+			Payload:     payload,
+			Expectation: Expectation{Error: true},
+		},
+		{
+			Name:        "no transparency log",
+			TrustRoot:   NewStaticTrustRoot(x509.NewCertPool(), x509.NewCertPool(), nil),
+			Signature:   sig,
+			PayloadType: payloadType,
+			Payload:     payload,
+			Expectation: Expectation{Error: true},
+		},
+		{
+			Name:        "recorded after the certificate expired",
+			TrustRoot:   trustRoot(rekorSrv.URL),
+			Signature:   lateSig,
+			PayloadType: payloadType,
+			Payload:     latePayload,
+			Expectation: Expectation{Error: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var act Expectation
+			id, err := test.TrustRoot.Verify(context.Background(), *test.Signature, test.PayloadType, []byte(test.Payload))
+			if err != nil {
+				act.Error = true
+			}
+			act.Identity = id
+
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("Verify() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package testing
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeRekor implements the parts of the Rekor API used for verification. Entries are kept in memory and
+// come with valid signed entry timestamps, inclusion proofs and checkpoints.
+type FakeRekor struct {
+	key *ecdsa.PrivateKey
+
+	mu      sync.RWMutex
+	entries []fakeEntry
+}
+
+type fakeEntry struct {
+	Digest         string
+	Body           []byte
+	IntegratedTime int64
+}
+
+// NewFakeRekor creates a fake Rekor instance with a fresh log key
+func NewFakeRekor() (*FakeRekor, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &FakeRekor{key: key}, nil
+}
+
+// PublicKey returns the key the log signs with
+func (f *FakeRekor) PublicKey() *ecdsa.PublicKey {
+	return &f.key.PublicKey
+}
+
+// Add records an entry with body in the log and indexes it under the sha256 digest of payload
+func (f *FakeRekor) Add(payload, body []byte, integratedTime time.Time) {
+	f.add(payload, body, integratedTime)
+}
+
+func (f *FakeRekor) add(payload, body []byte, integratedTime time.Time) (idx int) {
+	digest := sha256.Sum256(payload)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries = append(f.entries, fakeEntry{
+		Digest:         "sha256:" + hex.EncodeToString(digest[:]),
+		Body:           body,
+		IntegratedTime: integratedTime.Unix(),
+	})
+	return len(f.entries) - 1
+}
+
+// ServeHTTP implements http.Handler
+func (f *FakeRekor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && r.URL.Path == "/api/v1/log/entries" {
+		f.upload(w, r)
+		return
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	switch {
+	case r.URL.Path == "/api/v1/log/publicKey":
+		der, err := x509.MarshalPKIXPublicKey(f.key.Public())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_ = pem.Encode(w, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	case r.URL.Path == "/api/v1/index/retrieve":
+		var req struct {
+			Hash string `json:"hash"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		uuids := []string{}
+		for i, e := range f.entries {
+			if e.Digest == req.Hash {
+				uuids = append(uuids, entryUUID(i))
+			}
+		}
+		_ = json.NewEncoder(w).Encode(uuids)
+	case strings.HasPrefix(r.URL.Path, "/api/v1/log/entries/"):
+		uuid := strings.TrimPrefix(r.URL.Path, "/api/v1/log/entries/")
+		for i := range f.entries {
+			if entryUUID(i) != uuid {
+				continue
+			}
+			entry, err := f.entry(i)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{uuid: entry})
+			return
+		}
+		http.NotFound(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// upload records a DSSE entry the way Rekor does: the entry body keeps the payload hash, the signatures and
+// their verifiers, but not the payload itself.
+func (f *FakeRekor) upload(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Kind string `json:"kind"`
+		Spec struct {
+			ProposedContent struct {
+				Envelope  string   `json:"envelope"`
+				Verifiers []string `json:"verifiers"`
+			} `json:"proposedContent"`
+		} `json:"spec"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Kind != "dsse" || len(req.Spec.ProposedContent.Verifiers) == 0 {
+		http.Error(w, "invalid dsse entry", http.StatusBadRequest)
+		return
+	}
+	var env struct {
+		Payload    string `json:"payload"`
+		Signatures []struct {
+			Sig string `json:"sig"`
+		} `json:"signatures"`
+	}
+	err = json.Unmarshal([]byte(req.Spec.ProposedContent.Envelope), &env)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	type signature struct {
+		Signature string `json:"signature"`
+		Verifier  string `json:"verifier"`
+	}
+	var sigs []signature
+	for _, s := range env.Signatures {
+		sigs = append(sigs, signature{Signature: s.Sig, Verifier: req.Spec.ProposedContent.Verifiers[0]})
+	}
+	digest := sha256.Sum256(payload)
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "dsse",
+		"spec": map[string]interface{}{
+			"payloadHash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(digest[:])},
+			"signatures":  sigs,
+		},
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	idx := f.add(payload, body, time.Now())
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	entry, err := f.entry(idx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{entryUUID(idx): entry})
+}
+
+func entryUUID(idx int) string {
+	return fmt.Sprintf("%016x", idx)
+}
+
+// entry produces the API representation of the entry at idx, including its verification material
+func (f *FakeRekor) entry(idx int) (map[string]interface{}, error) {
+	e := f.entries[idx]
+	body := base64.StdEncoding.EncodeToString(e.Body)
+	logID := sha256.Sum256([]byte("fake rekor"))
+
+	set, err := json.Marshal(map[string]interface{}{
+		"body":           body,
+		"integratedTime": e.IntegratedTime,
+		"logID":          hex.EncodeToString(logID[:]),
+		"logIndex":       idx,
+	})
+	if err != nil {
+		return nil, err
+	}
+	setSig, err := f.sign(set)
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := make([][]byte, len(f.entries))
+	for i, e := range f.entries {
+		leaves[i] = leafHash(e.Body)
+	}
+	root := treeHash(leaves)
+	var hashes []string
+	for _, h := range auditPath(idx, leaves) {
+		hashes = append(hashes, hex.EncodeToString(h))
+	}
+
+	note := fmt.Sprintf("fake rekor\n%d\n%s\n", len(leaves), base64.StdEncoding.EncodeToString(root))
+	noteSig, err := f.sign([]byte(note))
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := note + "\n— fake rekor " + base64.StdEncoding.EncodeToString(append([]byte{0, 0, 0, 0}, noteSig...)) + "\n"
+
+	return map[string]interface{}{
+		"body":           body,
+		"integratedTime": e.IntegratedTime,
+		"logID":          hex.EncodeToString(logID[:]),
+		"logIndex":       idx,
+		"verification": map[string]interface{}{
+			"inclusionProof": map[string]interface{}{
+				"checkpoint": checkpoint,
+				"hashes":     hashes,
+				"logIndex":   idx,
+				"rootHash":   hex.EncodeToString(root),
+				"treeSize":   len(leaves),
+			},
+			"signedEntryTimestamp": base64.StdEncoding.EncodeToString(setSig),
+		},
+	}, nil
+}
+
+func (f *FakeRekor) sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	return ecdsa.SignASN1(rand.Reader, f.key, digest[:])
+}
+
+func leafHash(data []byte) []byte {
+	h := sha256.Sum256(append([]byte{0}, data...))
+	return h[:]
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.Sum256(append(append([]byte{1}, left...), right...))
+	return h[:]
+}
+
+// split returns the largest power of two smaller than n
+func split(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// treeHash computes the RFC 6962 Merkle tree hash of leaves
+func treeHash(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+	k := split(len(leaves))
+	return nodeHash(treeHash(leaves[:k]), treeHash(leaves[k:]))
+}
+
+// auditPath computes the RFC 6962 Merkle audit path of the leaf at idx
+func auditPath(idx int, leaves [][]byte) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+	k := split(len(leaves))
+	if idx < k {
+		return append(auditPath(idx, leaves[:k]), treeHash(leaves[k:]))
+	}
+	return append(auditPath(idx-k, leaves[k:]), treeHash(leaves[:k]))
+}
@@ -0,0 +1,193 @@
+package sigstore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+var (
+	// oidIssuerV2 is the Fulcio certificate extension holding the OIDC issuer as DER encoded UTF8String
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+	// oidIssuerV1 is the deprecated Fulcio certificate extension holding the OIDC issuer as raw string
+	oidIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+)
+
+// Identity is the OIDC identity a Fulcio certificate was issued for
+type Identity struct {
+	Issuer  string
+	Subject string
+}
+
+// TrustRoot provides the certificates Fulcio issues signing certificates with
+type TrustRoot struct {
+	FulcioURL string
+	// Rekor is the transparency log which establishes when a signature was made
+	Rekor *Rekor
+
+	once          sync.Once
+	roots         *x509.CertPool
+	intermediates *x509.CertPool
+	err           error
+}
+
+// NewTrustRoot creates a trust root which fetches the trust bundle of a Fulcio instance on first use
+func NewTrustRoot(fulcioURL string) *TrustRoot {
+	if fulcioURL == "" {
+		fulcioURL = DefaultFulcioURL
+	}
+	return &TrustRoot{FulcioURL: strings.TrimSuffix(fulcioURL, "/"), Rekor: NewRekor(DefaultRekorURL)}
+}
+
+// NewStaticTrustRoot creates a trust root from known certificates and the transparency log rekor
+func NewStaticTrustRoot(roots, intermediates *x509.CertPool, rekor *Rekor) *TrustRoot {
+	res := &TrustRoot{roots: roots, intermediates: intermediates, Rekor: rekor}
+	res.once.Do(func() {})
+	return res
+}
+
+func (t *TrustRoot) pools(ctx context.Context) (roots, intermediates *x509.CertPool, err error) {
+	t.once.Do(func() {
+		signer := &KeylessSigner{FulcioURL: t.FulcioURL, Client: &http.Client{Timeout: 30 * time.Second}}
+		var bundle struct {
+			Chains []struct {
+				Certificates []string `json:"certificates"`
+			} `json:"chains"`
+		}
+		t.err = signer.do(ctx, http.MethodGet, "/api/v2/trustBundle", nil, &bundle)
+		if t.err != nil {
+			t.err = fmt.Errorf("cannot fetch trust bundle from %s: %w", t.FulcioURL, t.err)
+			return
+		}
+
+		t.roots, t.intermediates = x509.NewCertPool(), x509.NewCertPool()
+		for _, c := range bundle.Chains {
+			chain, err := parseCertificates(strings.Join(c.Certificates, ""))
+			if err != nil {
+				t.err = err
+				return
+			}
+			for i, cert := range chain {
+				// chains are ordered leaf to root
+				if i == len(chain)-1 {
+					t.roots.AddCert(cert)
+				} else {
+					t.intermediates.AddCert(cert)
+				}
+			}
+		}
+	})
+	return t.roots, t.intermediates, t.err
+}
+
+// Verify checks that sig is a valid signature over the DSSE pre-authentication encoding of payloadType
+// and payload, made with a certificate issued by the trust root. Returns the identity of the certificate.
+//
+// Signing certificates are only valid for a few minutes, hence the certificate chain is verified at the time the
+// transparency log integrated the signature. Signatures which are not recorded in the log are rejected, since we
+// cannot tell when they were made. RFC 3161 timestamps are not supported.
+func (t *TrustRoot) Verify(ctx context.Context, sig Signature, payloadType string, payload []byte) (*Identity, error) {
+	if t.Rekor == nil {
+		return nil, fmt.Errorf("no transparency log configured - cannot tell when the signature was made")
+	}
+	roots, intermediates, err := t.pools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := parseCertificates(sig.Cert)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("signature carries no certificate")
+	}
+	leaf := chain[0]
+
+	pub, ok := leaf.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported certificate key type %T", leaf.PublicKey)
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig.Sig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode signature: %w", err)
+	}
+	digest := sha256.Sum256(dsse.PAE(payloadType, payload))
+	if !ecdsa.VerifyASN1(pub, digest[:], raw) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+
+	signedAt, err := t.signingTime(ctx, sig, payload)
+	if err != nil {
+		return nil, err
+	}
+	pool := intermediates.Clone()
+	for _, c := range chain[1:] {
+		pool.AddCert(c)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: pool,
+		CurrentTime:   signedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("certificate is not trusted at %s: %w", signedAt.UTC().Format(time.RFC3339), err)
+	}
+
+	return CertificateIdentity(leaf)
+}
+
+// signingTime returns the time the transparency log integrated sig
+func (t *TrustRoot) signingTime(ctx context.Context, sig Signature, payload []byte) (time.Time, error) {
+	entries, err := t.Rekor.Entries(ctx, sha256.Sum256(payload))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot look up the signature in %s: %w", t.Rekor.URL, err)
+	}
+	for _, e := range entries {
+		if e.Records(sig) {
+			return e.IntegratedTime, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("signature is not recorded in %s - cannot tell when it was made", t.Rekor.URL)
+}
+
+// CertificateIdentity returns the OIDC identity a Fulcio certificate was issued for
+func CertificateIdentity(cert *x509.Certificate) (*Identity, error) {
+	var res Identity
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerV2):
+			var issuer string
+			_, err := asn1.UnmarshalWithParams(ext.Value, &issuer, "utf8")
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse issuer extension: %w", err)
+			}
+			res.Issuer = issuer
+		case ext.Id.Equal(oidIssuerV1) && res.Issuer == "":
+			res.Issuer = string(ext.Value)
+		}
+	}
+
+	switch {
+	case len(cert.EmailAddresses) > 0:
+		res.Subject = cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		res.Subject = cert.URIs[0].String()
+	}
+
+	if res.Issuer == "" || res.Subject == "" {
+		return nil, fmt.Errorf("certificate carries no OIDC identity")
+	}
+	return &res, nil
+}
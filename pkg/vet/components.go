@@ -28,7 +28,7 @@ func checkComponentsFmt(comp *blazedock.Component) ([]Finding, error) {
 		return nil, err
 	}
 
-	if bytes.EqualFold(buf.Bytes(), fc) {
+	if bytes.Equal(buf.Bytes(), fc) {
 		return nil, nil
 	}
 
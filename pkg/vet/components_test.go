@@ -0,0 +1,61 @@
+package vet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func TestCheckComponentsFmt(t *testing.T) {
+	tests := []struct {
+		Name        string
+		BuildYAML   string
+		Expectation []string
+	}{
+		{
+			Name:      "formatted",
+			BuildYAML: "packages:\n  - name: app\n    type: generic\n",
+		},
+		{
+			Name: "empty",
+		},
+		{
+			Name:        "unformatted",
+			BuildYAML:   "packages:\n- name: app\n  type: generic\n",
+			Expectation: []string{"component's BUILD.yaml is not formated using `blazedock fmt`"},
+		},
+		{
+			// blazedock fmt writes escape sequences in upper case
+			Name:        "differs only in case",
+			BuildYAML:   "packages:\n  - name: app\n    type: generic\n    env:\n      - \"SEP=\\x7f\"\n",
+			Expectation: []string{"component's BUILD.yaml is not formated using `blazedock fmt`"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			loc := t.TempDir()
+			err := os.WriteFile(filepath.Join(loc, "BUILD.yaml"), []byte(test.BuildYAML), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			findings, err := checkComponentsFmt(&blazedock.Component{Origin: loc, Name: "comp"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var act []string
+			for _, f := range findings {
+				act = append(act, f.Description)
+			}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("checkComponentsFmt() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package vet
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strings"
@@ -14,6 +15,8 @@ import (
 
 func init() {
 	register(PackageCheck("copy-from-pacakge", "attempts to find broken package paths in COPY and ADD statements", blazedock.DockerPackage, checkDockerCopyFromPackage))
+	register(PackageCheck("base-image-digest", "ensures base images which are pinned to a tag are pinned to a digest as well", blazedock.DockerPackage, checkDockerBaseImageDigest))
+	register(PackageCheck("base-image-tag", "ensures base images are pinned to a tag other than latest or to a digest", blazedock.DockerPackage, checkDockerBaseImageTag))
 }
 
 var (
@@ -28,12 +31,7 @@ func checkDockerCopyFromPackage(pkg *blazedock.Package) ([]Finding, error) {
 		return nil, fmt.Errorf("Docker package does not have docker package config")
 	}
 
-	var dockerfileFN string
-	for _, src := range pkg.Sources {
-		if strings.HasSuffix(src, "/"+cfg.Dockerfile) {
-			dockerfileFN = src
-		}
-	}
+	dockerfileFN := findDockerfile(pkg, cfg)
 	if dockerfileFN == "" {
 		return []Finding{{
 			Component:   pkg.C,
@@ -96,3 +94,143 @@ func checkDockerCopyFromPackage(pkg *blazedock.Package) ([]Finding, error) {
 
 	return findings, nil
 }
+
+// findDockerfile returns the location of the Dockerfile of a package or an empty string if the package has none
+func findDockerfile(pkg *blazedock.Package, cfg blazedock.DockerPkgConfig) string {
+	var res string
+	for _, src := range pkg.Sources {
+		if strings.HasSuffix(src, "/"+cfg.Dockerfile) {
+			res = src
+		}
+	}
+	return res
+}
+
+func checkDockerBaseImageDigest(pkg *blazedock.Package) ([]Finding, error) {
+	return checkDockerBaseImages(pkg, baseImageDigestFinding)
+}
+
+func checkDockerBaseImageTag(pkg *blazedock.Package) ([]Finding, error) {
+	return checkDockerBaseImages(pkg, baseImageTagFinding)
+}
+
+// baseImageDigestFinding reports images which are pinned to a tag, but not to a digest. Images without a tag or with
+// the latest tag are left to baseImageTagFinding, s.t. both checks together report every image at most once.
+func baseImageDigestFinding(img baseImage) *Finding {
+	if img.Digest || img.Tag == "" || img.Tag == "latest" {
+		return nil
+	}
+	return &Finding{
+		Description: fmt.Sprintf("base image %s is pinned to a tag but not to a digest", img.Ref),
+		Error:       true,
+	}
+}
+
+// baseImageTagFinding reports images which are neither pinned to a digest nor to a tag other than latest
+func baseImageTagFinding(img baseImage) *Finding {
+	if img.Digest || (img.Tag != "" && img.Tag != "latest") {
+		return nil
+	}
+	return &Finding{
+		Description: fmt.Sprintf("base image %s is not pinned to a tag other than latest", img.Ref),
+		Error:       true,
+	}
+}
+
+func checkDockerBaseImages(pkg *blazedock.Package, check func(img baseImage) *Finding) ([]Finding, error) {
+	cfg, ok := pkg.Config.(blazedock.DockerPkgConfig)
+	if !ok {
+		return nil, fmt.Errorf("Docker package does not have docker package config")
+	}
+
+	dockerfileFN := findDockerfile(pkg, cfg)
+	if dockerfileFN == "" {
+		// reported by the copy-from-package check already
+		return nil, nil
+	}
+	f, err := os.Open(dockerfileFN)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	imgs, err := externalBaseImages(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, img := range imgs {
+		finding := check(img)
+		if finding == nil {
+			continue
+		}
+		finding.Component = pkg.C
+		finding.Package = pkg
+		findings = append(findings, *finding)
+	}
+	return findings, nil
+}
+
+// baseImage is an image a Dockerfile builds upon
+type baseImage struct {
+	Ref    string
+	Tag    string
+	Digest bool
+}
+
+// externalBaseImages returns the images of all FROM instructions of a Dockerfile which do not refer to an earlier
+// build stage. Images which are set through build arguments, e.g. those of package dependencies, and scratch
+// are not included.
+func externalBaseImages(in io.Reader) ([]baseImage, error) {
+	var (
+		res     []baseImage
+		stages  = make(map[string]struct{})
+		scanner = bufio.NewScanner(in)
+		line    string
+	)
+	for scanner.Scan() {
+		txt := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(txt, "#") {
+			continue
+		}
+		line += txt
+		if strings.HasSuffix(line, "\\") {
+			line = strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		segs := strings.Fields(line)
+		line = ""
+
+		if len(segs) < 2 || !strings.EqualFold(segs[0], "from") {
+			continue
+		}
+		segs = segs[1:]
+		for len(segs) > 0 && strings.HasPrefix(segs[0], "--") {
+			// e.g. --platform=linux/amd64
+			segs = segs[1:]
+		}
+		if len(segs) == 0 {
+			continue
+		}
+		ref := segs[0]
+		_, isStage := stages[strings.ToLower(ref)]
+		if len(segs) >= 3 && strings.EqualFold(segs[1], "as") {
+			stages[strings.ToLower(segs[2])] = struct{}{}
+		}
+		if isStage || strings.Contains(ref, "$") || strings.EqualFold(ref, "scratch") {
+			continue
+		}
+
+		img := baseImage{Ref: ref, Digest: strings.Contains(ref, "@sha256:")}
+		name := strings.SplitN(ref, "@", 2)[0]
+		if idx := strings.LastIndex(name, ":"); idx > strings.LastIndex(name, "/") {
+			img.Tag = name[idx+1:]
+		}
+		res = append(res, img)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
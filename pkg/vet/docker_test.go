@@ -110,3 +110,99 @@ ADD from-some-pkg--build/hello.txt hello.txt`,
 		})
 	}
 }
+
+func TestExternalBaseImages(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Dockerfile  string
+		Expectation []baseImage
+	}{
+		{
+			Name:        "digest",
+			Dockerfile:  "FROM alpine:3.19@sha256:c5b1261d6d3e43071626931fc004f70149baeba2c8ec672bd4f27761f8e1ad6b",
+			Expectation: []baseImage{{Ref: "alpine:3.19@sha256:c5b1261d6d3e43071626931fc004f70149baeba2c8ec672bd4f27761f8e1ad6b", Tag: "3.19", Digest: true}},
+		},
+		{
+			Name:       "tags",
+			Dockerfile: "FROM alpine\nFROM ubuntu:latest\nfrom --platform=linux/amd64 registry.example.com:5000/team/app:1.2.3",
+			Expectation: []baseImage{
+				{Ref: "alpine"},
+				{Ref: "ubuntu:latest", Tag: "latest"},
+				{Ref: "registry.example.com:5000/team/app:1.2.3", Tag: "1.2.3"},
+			},
+		},
+		{
+			Name: "multi-stage",
+			Dockerfile: `# syntax=docker/dockerfile:1
+FROM golang:1.22 AS Builder
+RUN go build -o /app .
+
+FROM builder as tester
+RUN go test ./...
+
+FROM \
+    scratch
+COPY --from=builder /app /app`,
+			Expectation: []baseImage{{Ref: "golang:1.22", Tag: "1.22"}},
+		},
+		{
+			Name:       "build arguments",
+			Dockerfile: "ARG BASE=alpine:latest\nFROM ${BASE}\nFROM $DEP_COMP__PKG0",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act, err := externalBaseImages(strings.NewReader(test.Dockerfile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("externalBaseImages() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBaseImageFindings(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Image       baseImage
+		Expectation []string
+	}{
+		{
+			Name:  "digest",
+			Image: baseImage{Ref: "alpine:3.19@sha256:c5b1261d6d3e43071626931fc004f70149baeba2c8ec672bd4f27761f8e1ad6b", Tag: "3.19", Digest: true},
+		},
+		{
+			Name:        "tag",
+			Image:       baseImage{Ref: "alpine:3.19", Tag: "3.19"},
+			Expectation: []string{"base-image-digest: base image alpine:3.19 is pinned to a tag but not to a digest"},
+		},
+		{
+			Name:        "latest",
+			Image:       baseImage{Ref: "alpine:latest", Tag: "latest"},
+			Expectation: []string{"base-image-tag: base image alpine:latest is not pinned to a tag other than latest"},
+		},
+		{
+			Name:        "untagged",
+			Image:       baseImage{Ref: "alpine"},
+			Expectation: []string{"base-image-tag: base image alpine is not pinned to a tag other than latest"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var act []string
+			if f := baseImageDigestFinding(test.Image); f != nil {
+				act = append(act, "base-image-digest: "+f.Description)
+			}
+			if f := baseImageTagFinding(test.Image); f != nil {
+				act = append(act, "base-image-tag: "+f.Description)
+			}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("base image findings mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package vet
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+// ExternalCheckProtocolVersion is the version of the protocol blazedock speaks with external checks.
+// An external check receives an ExternalCheckRequest as JSON on stdin, and must write an ExternalCheckResponse
+// as JSON to stdout. Checks should fail if they don't understand the protocol version of the request.
+const ExternalCheckProtocolVersion = 1
+
+// ExternalCheckRequest is passed to external checks on stdin
+type ExternalCheckRequest struct {
+	ProtocolVersion int                    `json:"protocolVersion"`
+	Workspace       string                 `json:"workspace"`
+	Component       ExternalCheckComponent `json:"component"`
+	Package         *ExternalCheckPackage  `json:"package,omitempty"`
+}
+
+// ExternalCheckComponent describes the component an external check runs on
+type ExternalCheckComponent struct {
+	Name      string            `json:"name"`
+	Origin    string            `json:"origin"`
+	Constants map[string]string `json:"constants,omitempty"`
+}
+
+// ExternalCheckPackage describes the package an external check runs on
+type ExternalCheckPackage struct {
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	Sources      []string `json:"sources"`
+	Dependencies []string `json:"dependencies"`
+}
+
+// ExternalCheckResponse is expected from external checks on stdout
+type ExternalCheckResponse struct {
+	ProtocolVersion int                    `json:"protocolVersion"`
+	Findings        []ExternalCheckFinding `json:"findings"`
+}
+
+// ExternalCheckFinding is a finding produced by an external check
+type ExternalCheckFinding struct {
+	Description string `json:"description"`
+	Error       bool   `json:"error"`
+}
+
+// LoadExternalChecks registers the external checks configured in the workspace
+func LoadExternalChecks(ws blazedock.Workspace) error {
+	for _, ec := range ws.Vet.ExternalChecks {
+		ec := ec
+		if ec.Name == "" {
+			return xerrors.Errorf("external vet check has no name")
+		}
+		if len(ec.Command) == 0 {
+			return xerrors.Errorf("external vet check %s has no command", ec.Name)
+		}
+
+		var c Check
+		if ec.Component {
+			c = ComponentCheck(ec.Name, ec.Description, func(comp *blazedock.Component) ([]Finding, error) {
+				return runExternalCheck(ws.Origin, ec, comp, nil)
+			})
+		} else {
+			c = PackageCheck(ec.Name, ec.Description, ec.PackageType, func(pkg *blazedock.Package) ([]Finding, error) {
+				return runExternalCheck(ws.Origin, ec, pkg.C, pkg)
+			})
+		}
+		if _, exists := _checks[c.Info().Name]; exists {
+			return xerrors.Errorf("external vet check %s conflicts with an existing check", c.Info().Name)
+		}
+		register(c)
+	}
+	return nil
+}
+
+func runExternalCheck(wsOrigin string, ec blazedock.ExternalVetCheck, comp *blazedock.Component, pkg *blazedock.Package) ([]Finding, error) {
+	req := ExternalCheckRequest{
+		ProtocolVersion: ExternalCheckProtocolVersion,
+		Workspace:       wsOrigin,
+		Component: ExternalCheckComponent{
+			Name:      comp.Name,
+			Origin:    comp.Origin,
+			Constants: comp.Constants,
+		},
+	}
+	if pkg != nil {
+		req.Package = &ExternalCheckPackage{
+			Name:         pkg.FullName(),
+			Type:         string(pkg.Type),
+			Sources:      pkg.Sources,
+			Dependencies: []string{},
+		}
+		for _, dep := range pkg.GetDependencies() {
+			req.Package.Dependencies = append(req.Package.Dependencies, dep.FullName())
+		}
+	}
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	bin := ec.Command[0]
+	if strings.Contains(bin, "/") && !filepath.IsAbs(bin) {
+		bin = filepath.Join(wsOrigin, bin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(bin, ec.Command[1:]...)
+	cmd.Dir = wsOrigin
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		return nil, xerrors.Errorf("external check failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var resp ExternalCheckResponse
+	err = json.Unmarshal(stdout.Bytes(), &resp)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot parse external check output: %w", err)
+	}
+	if resp.ProtocolVersion != ExternalCheckProtocolVersion {
+		return nil, xerrors.Errorf("external check speaks protocol version %d, but blazedock expects %d", resp.ProtocolVersion, ExternalCheckProtocolVersion)
+	}
+
+	res := make([]Finding, 0, len(resp.Findings))
+	for _, f := range resp.Findings {
+		res = append(res, Finding{
+			Component:   comp,
+			Package:     pkg,
+			Description: f.Description,
+			Error:       f.Error,
+		})
+	}
+	return res, nil
+}
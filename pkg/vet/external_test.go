@@ -0,0 +1,81 @@
+package vet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func TestRunExternalCheck(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Script   string
+		Findings []ExternalCheckFinding
+		Error    bool
+	}{
+		{
+			Name: "findings",
+			Script: `#!/bin/sh
+in=$(cat)
+echo "$in" | grep -q '"protocolVersion":1' || exit 1
+echo "$in" | grep -q '"name":"comp:pkg"' || exit 1
+echo '{"protocolVersion":1,"findings":[{"description":"foo","error":true},{"description":"bar"}]}'
+`,
+			Findings: []ExternalCheckFinding{
+				{Description: "foo", Error: true},
+				{Description: "bar"},
+			},
+		},
+		{
+			Name:     "no findings",
+			Script:   "#!/bin/sh\necho '{\"protocolVersion\":1}'\n",
+			Findings: []ExternalCheckFinding{},
+		},
+		{
+			Name:   "protocol mismatch",
+			Script: "#!/bin/sh\necho '{\"protocolVersion\":2,\"findings\":[]}'\n",
+			Error:  true,
+		},
+		{
+			Name:   "failing check",
+			Script: "#!/bin/sh\necho broken >&2\nexit 1\n",
+			Error:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			ws := t.TempDir()
+			err := os.WriteFile(filepath.Join(ws, "check.sh"), []byte(test.Script), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			comp := &blazedock.Component{Name: "comp", Origin: filepath.Join(ws, "comp")}
+			pkg := &blazedock.Package{C: comp, PackageInternal: blazedock.PackageInternal{Name: "pkg", Type: blazedock.GenericPackage}}
+			check := blazedock.ExternalVetCheck{Name: "test", Command: []string{"./check.sh"}}
+
+			findings, err := runExternalCheck(ws, check, comp, pkg)
+			if (err != nil) != test.Error {
+				t.Fatalf("runExternalCheck() error = %v, expected error: %v", err, test.Error)
+			}
+			if test.Error {
+				return
+			}
+			act := make([]ExternalCheckFinding, 0, len(findings))
+			for _, f := range findings {
+				if f.Component != comp || f.Package != pkg {
+					t.Errorf("finding %q does not point to the checked package", f.Description)
+				}
+				act = append(act, ExternalCheckFinding{Description: f.Description, Error: f.Error})
+			}
+			if diff := cmp.Diff(test.Findings, act); diff != "" {
+				t.Errorf("runExternalCheck() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
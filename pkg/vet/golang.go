@@ -2,14 +2,26 @@ package vet
 
 import (
 	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+
 	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/linker"
 )
 
 func init() {
 	register(PackageCheck("has-gomod", "ensures all Go packages have a go.mod file in their source list", blazedock.GoPackage, checkGolangHasGomod))
 	register(PackageCheck("has-buildflags", "checks for use of deprecated buildFlags config", blazedock.GoPackage, checkGolangHasBuildFlags))
+	register(PackageCheck("unused-deps", "finds Go package dependencies which are never imported", blazedock.GoPackage, checkGolangUnusedDeps))
+	register(&checkGoModGoVersion{})
+	register(&checkGoWorkUses{})
 }
 
 func checkGolangHasGomod(pkg *blazedock.Package) ([]Finding, error) {
@@ -66,3 +78,260 @@ func checkGolangHasBuildFlags(pkg *blazedock.Package) ([]Finding, error) {
 
 	return nil, nil
 }
+
+func checkGolangUnusedDeps(pkg *blazedock.Package) ([]Finding, error) {
+	// We parse all Go files regardless of their build constraints, s.t. imports which are only used
+	// with some build tags count as well.
+	imports := make(map[string]struct{})
+	fset := token.NewFileSet()
+	for _, src := range pkg.Sources {
+		if !strings.HasSuffix(src, ".go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, src, nil, parser.ImportsOnly)
+		if err != nil {
+			return nil, err
+		}
+		for _, imp := range f.Imports {
+			path, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				return nil, err
+			}
+			imports[path] = struct{}{}
+		}
+	}
+
+	var findings []Finding
+	for _, dep := range pkg.GetDependencies() {
+		if dep.Type != blazedock.GoPackage {
+			// we can only tell if Go dependencies are used
+			continue
+		}
+
+		mod, err := goModulePath(dep)
+		if err != nil {
+			return nil, err
+		}
+		if mod == "" {
+			continue
+		}
+
+		var used bool
+		for imp := range imports {
+			if imp == mod || strings.HasPrefix(imp, mod+"/") {
+				used = true
+				break
+			}
+		}
+		if used {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Component:   pkg.C,
+			Description: fmt.Sprintf("dependency %s is declared but module %s is never imported", dep.FullName(), mod),
+			Error:       false,
+			Package:     pkg,
+		})
+	}
+	return findings, nil
+}
+
+// goModulePath returns the module path from the go.mod in the package sources, or an empty string if there's none
+func goModulePath(pkg *blazedock.Package) (string, error) {
+	for _, src := range pkg.Sources {
+		if !strings.HasSuffix(src, "/go.mod") {
+			continue
+		}
+
+		fc, err := os.ReadFile(src)
+		if err != nil {
+			return "", err
+		}
+		return modfile.ModulePath(fc), nil
+	}
+	return "", nil
+}
+
+// goModFile parses the go.mod in the package sources. Returns nil if there is none.
+func goModFile(pkg *blazedock.Package) (*modfile.File, error) {
+	for _, src := range pkg.Sources {
+		if !strings.HasSuffix(src, "/go.mod") {
+			continue
+		}
+
+		fc, err := os.ReadFile(src)
+		if err != nil {
+			return nil, err
+		}
+		return modfile.Parse(src, fc, nil)
+	}
+	return nil, nil
+}
+
+// checkGoModGoVersion ensures all go.mod files declare the same Go version and toolchain. Unless the WORKSPACE.yaml
+// sets the expected version, go.mod files are compared against the version most Go packages of the workspace declare.
+type checkGoModGoVersion struct {
+	uses []goVersionUse
+}
+
+type goVersionUse struct {
+	Version string
+	Users   int
+}
+
+func (c *checkGoModGoVersion) Info() CheckInfo {
+	tpe := blazedock.GoPackage
+	return CheckInfo{
+		Name:          fmt.Sprintf("%s:gomod-go-version", tpe),
+		Description:   "ensures all go.mod files declare the same Go version and toolchain",
+		AppliesToType: &tpe,
+		PackageCheck:  true,
+	}
+}
+
+// Init counts the go directives of all Go packages of the workspace
+func (c *checkGoModGoVersion) Init(ws blazedock.Workspace) error {
+	c.uses = nil
+	if ws.Vet.GoVersion != "" {
+		// the expected version is set explicitly
+		return nil
+	}
+
+	idx := make(map[string]int)
+	for _, pkg := range ws.Packages {
+		if pkg.Type != blazedock.GoPackage {
+			continue
+		}
+		mod, err := goModFile(pkg)
+		if err != nil {
+			return err
+		}
+		if mod == nil || mod.Go == nil {
+			continue
+		}
+		idx[mod.Go.Version]++
+	}
+
+	c.uses = make([]goVersionUse, 0, len(idx))
+	for v, n := range idx {
+		c.uses = append(c.uses, goVersionUse{Version: v, Users: n})
+	}
+	return nil
+}
+
+// prevalentGoVersion returns the go directive most Go packages of the workspace declare. If there's a tie, it's the
+// newest of those versions.
+func (c *checkGoModGoVersion) prevalentGoVersion() (version string, users, total int) {
+	for _, u := range c.uses {
+		total += u.Users
+		if u.Users > users || (u.Users == users && semver.Compare("v"+u.Version, "v"+version) > 0) {
+			version, users = u.Version, u.Users
+		}
+	}
+	return version, users, total
+}
+
+func (c *checkGoModGoVersion) RunCmp(*blazedock.Component) ([]Finding, error) {
+	return nil, fmt.Errorf("has no component check")
+}
+
+func (c *checkGoModGoVersion) RunPkg(pkg *blazedock.Package) ([]Finding, error) {
+	mod, err := goModFile(pkg)
+	if err != nil {
+		return nil, err
+	}
+	if mod == nil {
+		// reported by the has-gomod check
+		return nil, nil
+	}
+	var version, toolchain string
+	if mod.Go != nil {
+		version = mod.Go.Version
+	}
+	if mod.Toolchain != nil {
+		toolchain = mod.Toolchain.Name
+	}
+
+	var (
+		findings []Finding
+		report   = func(err bool, format string, args ...interface{}) {
+			findings = append(findings, Finding{
+				Component:   pkg.C,
+				Description: fmt.Sprintf(format, args...),
+				Error:       err,
+				Package:     pkg,
+			})
+		}
+		cfg = pkg.C.W.Vet
+	)
+	switch {
+	case cfg.GoVersion != "" && version == "":
+		report(true, "go.mod has no go directive, expected go %s", cfg.GoVersion)
+	case cfg.GoVersion != "" && version != cfg.GoVersion:
+		report(true, "go.mod declares go %s, expected go %s as set in the WORKSPACE.yaml", version, cfg.GoVersion)
+	case cfg.GoVersion == "" && version != "":
+		expected, users, total := c.prevalentGoVersion()
+		if version != expected {
+			report(false, "go.mod declares go %s, whereas %d of %d Go packages declare go %s", version, users, total, expected)
+		}
+	}
+
+	switch {
+	case cfg.GoToolchain != "" && toolchain == "":
+		report(true, "go.mod has no toolchain directive, expected toolchain %s", cfg.GoToolchain)
+	case cfg.GoToolchain != "" && toolchain != cfg.GoToolchain:
+		report(true, "go.mod declares toolchain %s, expected toolchain %s as set in the WORKSPACE.yaml", toolchain, cfg.GoToolchain)
+	}
+
+	return findings, nil
+}
+
+// checkGoWorkUses ensures the use directives blazedock added to the go.work file match the Go modules of the
+// workspace, i.e. that blazedock link would not change the go.work file
+type checkGoWorkUses struct{}
+
+func (c *checkGoWorkUses) Info() CheckInfo {
+	return CheckInfo{
+		Name:        "workspace:gowork-uses",
+		Description: "ensures the go.work file uses all Go modules of the workspace",
+	}
+}
+
+func (c *checkGoWorkUses) Init(blazedock.Workspace) error { return nil }
+
+func (c *checkGoWorkUses) RunPkg(*blazedock.Package) ([]Finding, error) {
+	return nil, fmt.Errorf("not a package check")
+}
+
+// RunCmp does nothing - go.work is checked once for the whole workspace
+func (c *checkGoWorkUses) RunCmp(*blazedock.Component) ([]Finding, error) {
+	return nil, nil
+}
+
+func (c *checkGoWorkUses) RunWorkspace(ws blazedock.Workspace) ([]Finding, error) {
+	if _, err := os.Stat(filepath.Join(ws.Origin, "go.work")); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	missing, stale, err := linker.DiffGoWorkspace(&ws)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, pth := range missing {
+		findings = append(findings, Finding{
+			Description: fmt.Sprintf("go.work does not use the Go module at %s - run blazedock link to add it", pth),
+			Error:       true,
+		})
+	}
+	for _, pth := range stale {
+		findings = append(findings, Finding{
+			Description: fmt.Sprintf("go.work uses %s, which is not a Go module of the workspace - run blazedock link to remove it", pth),
+			Error:       true,
+		})
+	}
+	return findings, nil
+}
@@ -0,0 +1,219 @@
+package vet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func TestCheckGolangUnusedDeps(t *testing.T) {
+	goLib := "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n  config:\n    packaging: library\n"
+	files := map[string]string{
+		"WORKSPACE.yaml": "",
+		"a/BUILD.yaml":   goLib,
+		"a/go.mod":       "module example.com/a\n\ngo 1.21\n",
+		"b/BUILD.yaml":   goLib,
+		"b/go.mod":       "module example.com/b\n\ngo 1.21\n",
+		"c/BUILD.yaml":   goLib,
+		"c/go.mod":       "module example.com/c\n\ngo 1.21\n",
+		"d/BUILD.yaml":   "packages:\n- name: lib\n  type: generic\n",
+		"app/BUILD.yaml": "packages:\n- name: app\n  type: go\n  srcs: [\"go.mod\", \"*.go\"]\n  deps: [\"a:lib\", \"b:lib\", \"c:lib\", \"d:lib\"]\n",
+		"app/go.mod":     "module example.com/app\n\ngo 1.21\n",
+		"app/main.go":    "package main\n\nimport \"example.com/a/sub\"\n\nfunc main() { sub.Do() }\n",
+		"app/tagged.go":  "//go:build special\n\npackage main\n\nimport _ \"example.com/c\"\n",
+	}
+
+	failOnErr := func(err error) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tmpdir := t.TempDir()
+	for fn, content := range files {
+		fn = filepath.Join(tmpdir, fn)
+		failOnErr(os.MkdirAll(filepath.Dir(fn), 0755))
+		failOnErr(os.WriteFile(fn, []byte(content), 0644))
+	}
+
+	ws, err := blazedock.FindWorkspace(tmpdir, blazedock.Arguments{}, "", "")
+	failOnErr(err)
+	pkg, ok := ws.Packages["app:app"]
+	if !ok {
+		t.Fatalf("cannot find test package: app:app")
+	}
+
+	findings, err := checkGolangUnusedDeps(pkg)
+	failOnErr(err)
+
+	var fs []string
+	for _, f := range findings {
+		fs = append(fs, f.Description)
+	}
+	expected := []string{"dependency b:lib is declared but module example.com/b is never imported"}
+	if diff := cmp.Diff(expected, fs); diff != "" {
+		t.Errorf("checkGolangUnusedDeps() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCheckGolangGoVersion(t *testing.T) {
+	goLib := "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n  config:\n    packaging: library\n"
+	tests := []struct {
+		Name        string
+		Workspace   string
+		Expectation map[string][]string
+	}{
+		{
+			Name: "prevalent version",
+			Expectation: map[string][]string{
+				"c:lib": {"go.mod declares go 1.21, whereas 2 of 4 Go packages declare go 1.22"},
+				"e:lib": {"go.mod declares go 1.20, whereas 2 of 4 Go packages declare go 1.22"},
+			},
+		},
+		{
+			Name:      "expected version",
+			Workspace: "vet:\n  goVersion: \"1.21\"\n",
+			Expectation: map[string][]string{
+				"a:lib": {"go.mod declares go 1.22, expected go 1.21 as set in the WORKSPACE.yaml"},
+				"b:lib": {"go.mod declares go 1.22, expected go 1.21 as set in the WORKSPACE.yaml"},
+				"d:lib": {"go.mod has no go directive, expected go 1.21"},
+				"e:lib": {"go.mod declares go 1.20, expected go 1.21 as set in the WORKSPACE.yaml"},
+			},
+		},
+		{
+			Name:      "expected toolchain",
+			Workspace: "vet:\n  goVersion: \"1.22\"\n  goToolchain: go1.22.4\n",
+			Expectation: map[string][]string{
+				"a:lib": {"go.mod has no toolchain directive, expected toolchain go1.22.4"},
+				"c:lib": {
+					"go.mod declares go 1.21, expected go 1.22 as set in the WORKSPACE.yaml",
+					"go.mod declares toolchain go1.21.0, expected toolchain go1.22.4 as set in the WORKSPACE.yaml",
+				},
+				"d:lib": {
+					"go.mod has no go directive, expected go 1.22",
+					"go.mod has no toolchain directive, expected toolchain go1.22.4",
+				},
+				"e:lib": {
+					"go.mod declares go 1.20, expected go 1.22 as set in the WORKSPACE.yaml",
+					"go.mod has no toolchain directive, expected toolchain go1.22.4",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			files := map[string]string{
+				"WORKSPACE.yaml": test.Workspace,
+				"a/BUILD.yaml":   goLib,
+				"a/go.mod":       "module example.com/a\n\ngo 1.22\n",
+				"b/BUILD.yaml":   goLib,
+				"b/go.mod":       "module example.com/b\n\ngo 1.22\n\ntoolchain go1.22.4\n",
+				"c/BUILD.yaml":   goLib,
+				"c/go.mod":       "module example.com/c\n\ngo 1.21\n\ntoolchain go1.21.0\n",
+				"d/BUILD.yaml":   goLib,
+				"d/go.mod":       "module example.com/d\n",
+				"e/BUILD.yaml":   goLib,
+				"e/go.mod":       "module example.com/e\n\ngo 1.20\n",
+			}
+
+			failOnErr := func(err error) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			tmpdir := t.TempDir()
+			for fn, content := range files {
+				fn = filepath.Join(tmpdir, fn)
+				failOnErr(os.MkdirAll(filepath.Dir(fn), 0755))
+				failOnErr(os.WriteFile(fn, []byte(content), 0644))
+			}
+
+			ws, err := blazedock.FindWorkspace(tmpdir, blazedock.Arguments{}, "", "")
+			failOnErr(err)
+
+			var check checkGoModGoVersion
+			failOnErr(check.Init(ws))
+
+			act := make(map[string][]string)
+			for name, pkg := range ws.Packages {
+				findings, err := check.RunPkg(pkg)
+				failOnErr(err)
+				for _, f := range findings {
+					act[name] = append(act[name], f.Description)
+				}
+			}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("checkGoModGoVersion.RunPkg() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckGoWorkUses(t *testing.T) {
+	goLib := "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n"
+	tests := []struct {
+		Name        string
+		GoWork      string
+		Expectation []string
+	}{
+		{
+			Name:   "in sync",
+			GoWork: "go 1.21\n\nuse (\n\t./tools\n\ta // blazedock\n\tb // blazedock\n)\n",
+		},
+		{
+			Name:   "drifted",
+			GoWork: "go 1.21\n\nuse (\n\t./tools\n\t./a // blazedock\n\told // blazedock\n)\n",
+			Expectation: []string{
+				"go.work does not use the Go module at b - run blazedock link to add it",
+				"go.work uses old, which is not a Go module of the workspace - run blazedock link to remove it",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			files := map[string]string{
+				"WORKSPACE.yaml": "",
+				"go.work":        test.GoWork,
+				"a/BUILD.yaml":   goLib,
+				"a/go.mod":       "module example.com/a\n\ngo 1.21\n",
+				"b/BUILD.yaml":   goLib,
+				"b/go.mod":       "module example.com/b\n\ngo 1.21\n",
+				"c/BUILD.yaml":   "packages:\n- name: lib\n  type: generic\n",
+				"tools/go.mod":   "module example.com/tools\n\ngo 1.21\n",
+			}
+			tmpdir := t.TempDir()
+			for fn, content := range files {
+				fn = filepath.Join(tmpdir, fn)
+				if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ws, err := blazedock.FindWorkspace(tmpdir, blazedock.Arguments{}, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			findings, err := (&checkGoWorkUses{}).RunWorkspace(ws)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var act []string
+			for _, f := range findings {
+				act = append(act, f.Description)
+			}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("checkGoWorkUses.RunWorkspace() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package vet
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func init() {
+	register(ComponentCheck("license-compatibility", "ensures packages only depend on components with compatible licenses", checkLicenseCompatibility))
+}
+
+func checkLicenseCompatibility(comp *blazedock.Component) ([]Finding, error) {
+	if comp.W == nil {
+		return nil, nil
+	}
+	policy := comp.W.Vet.Licenses
+	if len(policy.Allow) == 0 && len(policy.Deny) == 0 {
+		// no policy, nothing to check
+		return nil, nil
+	}
+
+	if comp.License == "" {
+		return []Finding{{
+			Component:   comp,
+			Description: "component declares no license - cannot check license compatibility",
+		}}, nil
+	}
+
+	var findings []Finding
+	for _, pkg := range comp.Packages {
+		var (
+			incompatible = make(map[string]string)
+			unlicensed   []string
+		)
+		for _, dep := range pkg.GetTransitiveDependencies() {
+			if dep.C == nil || dep.C.Name == comp.Name {
+				continue
+			}
+			if dep.C.License == "" {
+				unlicensed = append(unlicensed, dep.FullName())
+				continue
+			}
+			if licenseCompatible(policy, comp.License, dep.C.License) {
+				continue
+			}
+			incompatible[dep.FullName()] = dep.C.License
+		}
+
+		deps := make([]string, 0, len(incompatible))
+		for dep := range incompatible {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			findings = append(findings, Finding{
+				Component:   comp,
+				Package:     pkg,
+				Description: fmt.Sprintf("%s (%s) depends on %s whose license %s is incompatible", pkg.FullName(), comp.License, dep, incompatible[dep]),
+				Error:       true,
+			})
+		}
+
+		sort.Strings(unlicensed)
+		for _, dep := range unlicensed {
+			findings = append(findings, Finding{
+				Component:   comp,
+				Package:     pkg,
+				Description: fmt.Sprintf("%s depends on %s whose component declares no license - cannot check license compatibility", pkg.FullName(), dep),
+			})
+		}
+	}
+	return findings, nil
+}
+
+func licenseCompatible(policy blazedock.LicensePolicy, license, depLicense string) bool {
+	matchesAny := func(patterns []string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, depLicense); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if allowed, ok := policy.Allow[license]; ok && !matchesAny(allowed) {
+		return false
+	}
+	if matchesAny(policy.Deny[license]) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package vet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func TestLicenseCompatible(t *testing.T) {
+	policy := blazedock.LicensePolicy{
+		Allow: map[string][]string{
+			"MIT": {"MIT", "Apache-2.0", "BSD-*"},
+		},
+		Deny: map[string][]string{
+			"Apache-2.0": {"GPL-*"},
+		},
+	}
+
+	tests := []struct {
+		License    string
+		DepLicense string
+		Expected   bool
+	}{
+		{"MIT", "MIT", true},
+		{"MIT", "BSD-3-Clause", true},
+		{"MIT", "GPL-3.0-only", false},
+		{"Apache-2.0", "MIT", true},
+		{"Apache-2.0", "GPL-2.0-only", false},
+		{"GPL-3.0-only", "GPL-2.0-only", true},
+	}
+
+	for _, test := range tests {
+		act := licenseCompatible(policy, test.License, test.DepLicense)
+		if act != test.Expected {
+			t.Errorf("licenseCompatible(%s, %s): expected %v, actual %v", test.License, test.DepLicense, test.Expected, act)
+		}
+	}
+}
+
+func TestCheckLicenseCompatibility(t *testing.T) {
+	files := map[string]string{
+		"WORKSPACE.yaml":      "vet:\n  licenses:\n    allow:\n      MIT: [\"MIT\", \"Apache-2.0\"]\n",
+		"app/BUILD.yaml":      "license: MIT\npackages:\n- name: app\n  type: generic\n  deps: [\"lib/a:lib\", \"lib/gpl:lib\", \"lib/none:lib\"]\n",
+		"lib/a/BUILD.yaml":    "license: Apache-2.0\npackages:\n- name: lib\n  type: generic\n",
+		"lib/gpl/BUILD.yaml":  "license: GPL-3.0-only\npackages:\n- name: lib\n  type: generic\n",
+		"lib/none/BUILD.yaml": "packages:\n- name: lib\n  type: generic\n",
+	}
+
+	failOnErr := func(err error) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tmpdir := t.TempDir()
+	for fn, content := range files {
+		fn = filepath.Join(tmpdir, fn)
+		failOnErr(os.MkdirAll(filepath.Dir(fn), 0755))
+		failOnErr(os.WriteFile(fn, []byte(content), 0644))
+	}
+
+	ws, err := blazedock.FindWorkspace(tmpdir, blazedock.Arguments{}, "", "")
+	failOnErr(err)
+
+	type finding struct {
+		Description string
+		Error       bool
+	}
+	expectations := map[string][]finding{
+		"app": {
+			{Description: "app:app (MIT) depends on lib/gpl:lib whose license GPL-3.0-only is incompatible", Error: true},
+			{Description: "app:app depends on lib/none:lib whose component declares no license - cannot check license compatibility"},
+		},
+		"lib/a": nil,
+		"lib/none": {
+			{Description: "component declares no license - cannot check license compatibility"},
+		},
+	}
+	for name, expected := range expectations {
+		comp, ok := ws.Components[name]
+		if !ok {
+			t.Fatalf("cannot find test component: %s", name)
+		}
+
+		findings, err := checkLicenseCompatibility(comp)
+		failOnErr(err)
+
+		var act []finding
+		for _, f := range findings {
+			act = append(act, finding{Description: f.Description, Error: f.Error})
+		}
+		if diff := cmp.Diff(expected, act); diff != "" {
+			t.Errorf("checkLicenseCompatibility(%s) mismatch (-want +got):\n%s", name, diff)
+		}
+	}
+}
@@ -2,25 +2,80 @@ package vet
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/khulnasoft/blazedock/pkg/blazedock"
+	"github.com/khulnasoft/blazedock/pkg/doublestar"
 )
 
 func init() {
 	register(PackageCheck("build-layout", "validates the build layout of all packages", "", checkBuildLayout))
+	register(PackageCheck("build-layout-collisions", "ensures no two dependencies of a package are placed at the same build-time location", "", checkBuildLayoutCollisions))
+	register(PackageCheck("exclude-sources", "finds excludeSources patterns which do not match any file", "", checkExcludeSources))
 }
 
 func checkBuildLayout(pkg *blazedock.Package) (findings []Finding, err error) {
+	deps := make([]string, 0, len(pkg.Layout))
+	for dep := range pkg.Layout {
+		deps = append(deps, dep)
+	}
+	sort.Strings(deps)
+
+	unpacked := make(map[string]struct{})
+	for _, e := range pkg.BuildLayout() {
+		unpacked[e.Dependency.FullName()] = struct{}{}
+	}
+
 	layoutIdx := make(map[string]string)
-	for dep, loc := range pkg.Layout {
+	for _, dep := range deps {
+		if _, ok := unpacked[dep]; !ok {
+			// most likely a typo or a dependency which was removed, which would go unnoticed otherwise
+			findings = append(findings, Finding{
+				Description: fmt.Sprintf("layout entry for %v has no effect: it is not unpacked in the build dir of this package", dep),
+				Component:   pkg.C,
+				Package:     pkg,
+			})
+		}
+
+		loc := pkg.Layout[dep]
+		otherdep, taken := layoutIdx[loc]
+		if !taken {
+			layoutIdx[loc] = dep
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Description: fmt.Sprintf("build-time location %v is used by %v and %v", loc, otherdep, dep),
+			Component:   pkg.C,
+			Error:       true,
+			Package:     pkg,
+		})
+	}
+	return
+}
+
+// checkBuildLayoutCollisions finds dependencies which end up at the same location in the build dir, where
+// the one unpacked last would silently overwrite the other. Unlike checkBuildLayout this considers all dependencies
+// the package type unpacks, including transitive ones and their default locations.
+func checkBuildLayoutCollisions(pkg *blazedock.Package) (findings []Finding, err error) {
+	layoutIdx := make(map[string]*blazedock.Package)
+	for _, entry := range pkg.BuildLayout() {
+		dep, loc := entry.Dependency, entry.Location
 		otherdep, taken := layoutIdx[loc]
 		if !taken {
 			layoutIdx[loc] = dep
 			continue
 		}
 
+		_, explicit := pkg.Layout[dep.FullName()]
+		_, otherExplicit := pkg.Layout[otherdep.FullName()]
+		if explicit && otherExplicit {
+			// already reported by checkBuildLayout
+			continue
+		}
+
 		findings = append(findings, Finding{
-			Description: fmt.Sprintf("build-time location %v is used by %v and %v", loc, dep, otherdep),
+			Description: fmt.Sprintf("dependencies %v and %v are both placed at build-time location %v", otherdep.FullName(), dep.FullName(), loc),
 			Component:   pkg.C,
 			Error:       true,
 			Package:     pkg,
@@ -28,3 +83,23 @@ func checkBuildLayout(pkg *blazedock.Package) (findings []Finding, err error) {
 	}
 	return
 }
+
+// checkExcludeSources finds stale excludeSources patterns, e.g. ones left behind after the files they excluded were moved.
+func checkExcludeSources(pkg *blazedock.Package) (findings []Finding, err error) {
+	for _, ptn := range pkg.ExcludeSources {
+		matches, err := doublestar.Glob(pkg.C.Origin, ptn, pkg.C.W.ShouldIgnoreSource)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) > 0 {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Description: fmt.Sprintf("excludeSources pattern %s does not match any file", ptn),
+			Component:   pkg.C,
+			Package:     pkg,
+		})
+	}
+	return
+}
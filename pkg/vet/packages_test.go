@@ -0,0 +1,180 @@
+package vet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func TestCheckBuildLayout(t *testing.T) {
+	files := map[string]string{
+		"WORKSPACE.yaml": "",
+		"a/BUILD.yaml":   "packages:\n- name: lib\n  type: generic\n  deps: [\"b:lib\"]\n",
+		"b/BUILD.yaml":   "packages:\n- name: lib\n  type: generic\n",
+		"c/BUILD.yaml":   "packages:\n- name: lib\n  type: generic\n",
+		"app/BUILD.yaml": "packages:\n" +
+			"- name: explicit\n  type: generic\n  deps: [\"a:lib\", \"c:lib\"]\n  layout:\n    a:lib: shared\n    c:lib: shared\n" +
+			"- name: unused\n  type: generic\n  deps: [\"a:lib\"]\n  layout:\n    b:lib: vendor/b\n    c:lbi: vendor/c\n" +
+			"- name: transitive\n  type: go\n  deps: [\"a:lib\"]\n  layout:\n    b:lib: vendor/b\n",
+	}
+
+	failOnErr := func(err error) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tmpdir := t.TempDir()
+	for fn, content := range files {
+		fn = filepath.Join(tmpdir, fn)
+		failOnErr(os.MkdirAll(filepath.Dir(fn), 0755))
+		failOnErr(os.WriteFile(fn, []byte(content), 0644))
+	}
+
+	ws, err := blazedock.FindWorkspace(tmpdir, blazedock.Arguments{}, "", "")
+	failOnErr(err)
+
+	expectations := map[string][]string{
+		"app:explicit": {"build-time location shared is used by a:lib and c:lib"},
+		// generic packages only get their direct dependencies
+		"app:unused": {
+			"layout entry for b:lib has no effect: it is not unpacked in the build dir of this package",
+			"layout entry for c:lbi has no effect: it is not unpacked in the build dir of this package",
+		},
+		// Go packages get their transitive dependencies
+		"app:transitive": nil,
+	}
+	for name, expected := range expectations {
+		pkg, ok := ws.Packages[name]
+		if !ok {
+			t.Fatalf("cannot find test package: %s", name)
+		}
+
+		findings, err := checkBuildLayout(pkg)
+		failOnErr(err)
+
+		var fs []string
+		for _, f := range findings {
+			fs = append(fs, f.Description)
+		}
+		if diff := cmp.Diff(expected, fs); diff != "" {
+			t.Errorf("checkBuildLayout(%s) mismatch (-want +got):\n%s", name, diff)
+		}
+	}
+}
+
+func TestCheckBuildLayoutCollisions(t *testing.T) {
+	files := map[string]string{
+		"WORKSPACE.yaml": "",
+		"a/BUILD.yaml":   "packages:\n- name: lib\n  type: generic\n  deps: [\"b:lib\"]\n",
+		"b/BUILD.yaml":   "packages:\n- name: lib\n  type: generic\n",
+		"c/BUILD.yaml":   "packages:\n- name: lib\n  type: generic\n",
+		"g/BUILD.yaml":   "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n  deps: [\"h:lib\"]\n  config:\n    packaging: library\n",
+		"g/go.mod":       "module example.com/g\n",
+		"h/BUILD.yaml":   "packages:\n- name: lib\n  type: go\n  srcs: [\"go.mod\"]\n  config:\n    packaging: library\n",
+		"h/go.mod":       "module example.com/h\n",
+		"app/BUILD.yaml": "packages:\n" +
+			"- name: default\n  type: generic\n  deps: [\"a:lib\", \"c:lib\"]\n" +
+			"- name: transitive\n  type: generic\n  deps: [\"a:lib\", \"c:lib\"]\n  layout:\n    a:lib: b--lib\n" +
+			"- name: explicit\n  type: generic\n  deps: [\"a:lib\", \"c:lib\"]\n  layout:\n    a:lib: shared\n    c:lib: shared\n" +
+			"- name: go\n  type: go\n  srcs: [\"go.mod\"]\n  deps: [\"g:lib\"]\n  layout:\n    g:lib: h--lib\n",
+		"app/go.mod": "module example.com/app\n",
+	}
+
+	failOnErr := func(err error) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tmpdir := t.TempDir()
+	for fn, content := range files {
+		fn = filepath.Join(tmpdir, fn)
+		failOnErr(os.MkdirAll(filepath.Dir(fn), 0755))
+		failOnErr(os.WriteFile(fn, []byte(content), 0644))
+	}
+
+	ws, err := blazedock.FindWorkspace(tmpdir, blazedock.Arguments{}, "", "")
+	failOnErr(err)
+
+	expectations := map[string][]string{
+		// the transitive dependency b:lib shares its name with the direct ones, but is not unpacked for generic packages
+		"app:default":    nil,
+		"app:transitive": nil,
+		// collisions between explicit layout entries are reported by checkBuildLayout
+		"app:explicit": nil,
+		// Go packages unpack their transitive dependencies as well
+		"app:go": {"dependencies g:lib and h:lib are both placed at build-time location _deps/h--lib"},
+	}
+	for name, expected := range expectations {
+		pkg, ok := ws.Packages[name]
+		if !ok {
+			t.Fatalf("cannot find test package: %s", name)
+		}
+
+		findings, err := checkBuildLayoutCollisions(pkg)
+		failOnErr(err)
+
+		var fs []string
+		for _, f := range findings {
+			fs = append(fs, f.Description)
+		}
+		if diff := cmp.Diff(expected, fs); diff != "" {
+			t.Errorf("checkBuildLayoutCollisions(%s) mismatch (-want +got):\n%s", name, diff)
+		}
+	}
+}
+
+func TestCheckExcludeSources(t *testing.T) {
+	files := map[string]string{
+		"WORKSPACE.yaml":           "",
+		"app/main.go":              "package main",
+		"app/testdata/a.go":        "package testdata",
+		"app/testdata/nested/b.go": "package nested",
+		"app/BUILD.yaml":           "packages:\n- name: app\n  type: generic\n  srcs: [\"**/*.go\"]\n  excludeSources: [\"testdata/**\", \"generated/*.go\"]\n",
+	}
+
+	failOnErr := func(err error) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tmpdir := t.TempDir()
+	for fn, content := range files {
+		fn = filepath.Join(tmpdir, fn)
+		failOnErr(os.MkdirAll(filepath.Dir(fn), 0755))
+		failOnErr(os.WriteFile(fn, []byte(content), 0644))
+	}
+
+	ws, err := blazedock.FindWorkspace(tmpdir, blazedock.Arguments{}, "", "")
+	failOnErr(err)
+	pkg, ok := ws.Packages["app:app"]
+	if !ok {
+		t.Fatalf("cannot find test package: app:app")
+	}
+
+	var srcs []string
+	for _, src := range pkg.Sources {
+		rel, err := filepath.Rel(pkg.C.Origin, src)
+		failOnErr(err)
+		srcs = append(srcs, rel)
+	}
+	if diff := cmp.Diff([]string{"main.go"}, srcs); diff != "" {
+		t.Errorf("package sources mismatch (-want +got):\n%s", diff)
+	}
+
+	findings, err := checkExcludeSources(pkg)
+	failOnErr(err)
+	var fs []string
+	for _, f := range findings {
+		fs = append(fs, f.Description)
+	}
+	if diff := cmp.Diff([]string{"excludeSources pattern generated/*.go does not match any file"}, fs); diff != "" {
+		t.Errorf("checkExcludeSources() mismatch (-want +got):\n%s", diff)
+	}
+}
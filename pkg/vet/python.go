@@ -0,0 +1,91 @@
+package vet
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func init() {
+	register(PackageCheck("has-requirements", "ensures all Python packages have a requirements file which pins every requirement", blazedock.PythonPackage, checkPythonHasRequirements))
+}
+
+func checkPythonHasRequirements(pkg *blazedock.Package) ([]Finding, error) {
+	cfg, ok := pkg.Config.(blazedock.PythonPkgConfig)
+	if !ok {
+		return nil, fmt.Errorf("Python package does not have python package config")
+	}
+
+	f, err := os.Open(filepath.Join(pkg.C.Origin, cfg.Requirements))
+	if os.IsNotExist(err) {
+		return []Finding{{
+			Component:   pkg.C,
+			Description: fmt.Sprintf("requirements file %s does not exist", cfg.Requirements),
+			Error:       true,
+			Package:     pkg,
+		}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	unpinned, err := unpinnedRequirements(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Finding
+	for _, req := range unpinned {
+		res = append(res, Finding{
+			Component:   pkg.C,
+			Description: fmt.Sprintf("requirement %s in %s is not pinned to an exact version", req, cfg.Requirements),
+			Error:       true,
+			Package:     pkg,
+		})
+	}
+	return res, nil
+}
+
+// unpinnedRequirements returns all requirements of a pip requirements file which are neither pinned
+// to an exact version (==, without wildcards) nor refer to a direct URL (@). Options like --hash or -r are ignored.
+func unpinnedRequirements(in io.Reader) ([]string, error) {
+	var (
+		res     []string
+		scanner = bufio.NewScanner(in)
+		line    string
+	)
+	for scanner.Scan() {
+		line += scanner.Text()
+		if strings.HasSuffix(line, "\\") {
+			line = strings.TrimSuffix(line, "\\")
+			continue
+		}
+		req := line
+		line = ""
+
+		if idx := strings.Index(req, "#"); idx >= 0 && (idx == 0 || req[idx-1] == ' ' || req[idx-1] == '\t') {
+			req = req[:idx]
+		}
+		req = strings.TrimSpace(req)
+		if req == "" || strings.HasPrefix(req, "-") {
+			continue
+		}
+		// hashes and environment markers follow the requirement itself
+		spec := strings.SplitN(req, ";", 2)[0]
+		spec = strings.SplitN(spec, " --", 2)[0]
+		if (strings.Contains(spec, "==") && !strings.Contains(spec, "*")) || strings.Contains(spec, " @ ") {
+			continue
+		}
+		res = append(res, strings.TrimSpace(spec))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,46 @@
+package vet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnpinnedRequirements(t *testing.T) {
+	tests := []struct {
+		Name         string
+		Requirements string
+		Expectation  []string
+	}{
+		{
+			Name:         "pinned",
+			Requirements: "# comment\nrequests==2.31.0\nnumpy==1.26.4 ; python_version >= \"3.9\"\n",
+		},
+		{
+			Name:         "options and direct references",
+			Requirements: "--index-url https://pypi.example.com/simple\n-r base.txt\nmylib @ https://example.com/mylib-1.0.tar.gz\n",
+		},
+		{
+			Name:         "hashes on continuation lines",
+			Requirements: "requests==2.31.0 \\\n    --hash=sha256:58cd2187c01e70e6e26505bca751777aa9f2ee0b7f4300988b709f44e013003f\n",
+		},
+		{
+			Name:         "unpinned",
+			Requirements: "requests>=2.0\nflask\nnumpy==1.*  # wildcard\n",
+			Expectation:  []string{"requests>=2.0", "flask", "numpy==1.*"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act, err := unpinnedRequirements(strings.NewReader(test.Requirements))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("unpinnedRequirements() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
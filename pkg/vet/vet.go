@@ -40,13 +40,17 @@ func (cf *checkFunc) RunCmp(pkg *blazedock.Component) ([]Finding, error) {
 	return cf.runCmp(pkg)
 }
 
-// PackageCheck produces a new check for a blazedock package
+// PackageCheck produces a new check for a blazedock package. If tpe is empty, the check applies to all package types.
 func PackageCheck(name, desc string, tpe blazedock.PackageType, chk func(pkg *blazedock.Package) ([]Finding, error)) Check {
+	var appliesTo *blazedock.PackageType
+	if tpe != "" {
+		appliesTo = &tpe
+	}
 	return &checkFunc{
 		info: CheckInfo{
 			Name:          fmt.Sprintf("%s:%s", tpe, name),
 			Description:   desc,
-			AppliesToType: &tpe,
+			AppliesToType: appliesTo,
 			PackageCheck:  true,
 		},
 		runPkg: chk,
@@ -74,6 +78,14 @@ type Check interface {
 	RunCmp(pkg *blazedock.Component) ([]Finding, error)
 }
 
+// WorkspaceCheck is a check which validates the workspace as a whole rather than its components or packages.
+// Its findings have neither a component nor a package.
+type WorkspaceCheck interface {
+	Check
+
+	RunWorkspace(ws blazedock.Workspace) ([]Finding, error)
+}
+
 // CheckInfo describes a check
 type CheckInfo struct {
 	Name          string
@@ -82,7 +94,8 @@ type CheckInfo struct {
 	AppliesToType *blazedock.PackageType
 }
 
-// Finding describes a check finding. If the package is nil, the finding applies to the component
+// Finding describes a check finding. If the package is nil, the finding applies to the component.
+// If the component is nil as well, it applies to the workspace.
 type Finding struct {
 	Check       string
 	Component   *blazedock.Component
@@ -101,9 +114,14 @@ func (f Finding) MarshalJSON() ([]byte, error) {
 		Error       bool   `json:"error"`
 	}
 	p.Check = f.Check
-	p.Component = f.Component.Name
+	if f.Component != nil {
+		p.Component = f.Component.Name
+	}
 	if f.Package != nil {
 		p.Package = f.Package.FullName()
+		if f.Component == nil && f.Package.C != nil {
+			p.Component = f.Package.C.Name
+		}
 	}
 	p.Description = f.Description
 	p.Error = f.Error
@@ -267,6 +285,19 @@ func Run(workspace blazedock.Workspace, options ...RunOpt) ([]Finding, []error)
 			for _, pkg := range workspace.Packages {
 				runPkgCheck(check, pkg)
 			}
+
+			if wc, ok := check.(WorkspaceCheck); ok {
+				log.WithField("check", wc.Info().Name).Debug("running workspace check")
+				f, err := wc.RunWorkspace(workspace)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("[%s] %w", wc.Info().Name, err))
+					continue
+				}
+				for i := range f {
+					f[i].Check = wc.Info().Name
+				}
+				findings = append(findings, f...)
+			}
 		}
 	}
 
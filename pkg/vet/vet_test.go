@@ -0,0 +1,49 @@
+package vet
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/khulnasoft/blazedock/pkg/blazedock"
+)
+
+func TestFindingMarshalJSON(t *testing.T) {
+	comp := &blazedock.Component{Name: "comp"}
+	pkg := &blazedock.Package{C: comp, PackageInternal: blazedock.PackageInternal{Name: "pkg"}}
+
+	tests := []struct {
+		Name     string
+		Finding  Finding
+		Expected string
+	}{
+		{
+			Name:     "component finding",
+			Finding:  Finding{Check: "component:foo", Component: comp, Description: "bar"},
+			Expected: `{"check":"component:foo","component":"comp","description":"bar","error":false}`,
+		},
+		{
+			Name:     "package finding",
+			Finding:  Finding{Check: "go:foo", Component: comp, Package: pkg, Description: "bar", Error: true},
+			Expected: `{"check":"go:foo","component":"comp","package":"comp:pkg","description":"bar","error":true}`,
+		},
+		{
+			Name:     "package finding without component",
+			Finding:  Finding{Check: "go:foo", Package: pkg, Description: "bar"},
+			Expected: `{"check":"go:foo","component":"comp","package":"comp:pkg","description":"bar","error":false}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act, err := json.Marshal(test.Finding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.Expected, string(act)); diff != "" {
+				t.Errorf("MarshalJSON() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}